@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/util"
+)
+
+// Defaults for `magabot bench`.
+const (
+	defaultBenchPrompt  = "Explain in two sentences what a hash table is."
+	defaultBenchTimeout = 60 * time.Second
+	benchPreviewLen     = 60 // response characters shown in the table
+)
+
+// benchOptions are the parsed `magabot bench` flags.
+type benchOptions struct {
+	prompt  string
+	timeout time.Duration
+	json    bool
+}
+
+// benchEntry is one provider's result in `magabot bench --json` output.
+type benchEntry struct {
+	Provider     string   `json:"provider"`
+	Model        string   `json:"model"`
+	LatencyMS    int64    `json:"latency_ms"`
+	InputTokens  int      `json:"input_tokens"`
+	OutputTokens int      `json:"output_tokens"`
+	CostUSD      *float64 `json:"cost_usd"` // null when the model's price is unknown
+	Response     string   `json:"response,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// cmdBench sends one prompt to every configured provider and compares them:
+// magabot bench [--prompt <text>] [--timeout <duration>] [--json]
+func cmdBench() {
+	opts, err := parseBenchArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n\nUsage: magabot bench [--prompt <text>] [--timeout <duration>] [--json]\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	if secretsMgr := loadSecrets(cfg, logger); secretsMgr != nil {
+		defer secretsMgr.Stop()
+	}
+
+	llmRouter := llm.NewRouter(&llm.Config{Main: cfg.LLM.Main, Logger: logger})
+	registerLLMProviders(llmRouter, cfg, logger)
+	if len(llmRouter.Providers()) == 0 {
+		fmt.Fprintln(os.Stderr, "No LLM providers are configured. Run 'magabot setup llm' first.")
+		os.Exit(1)
+	}
+
+	if !opts.json {
+		fmt.Printf("⏱  Benchmarking %d provider(s)...\n\n", len(llmRouter.Providers()))
+	}
+	results := llmRouter.Bench(context.Background(), opts.prompt, opts.timeout)
+
+	if opts.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(benchEntries(results))
+		return
+	}
+	fmt.Print(renderBench(results))
+}
+
+// parseBenchArgs reads --prompt, --timeout and --json, in --flag value or
+// --flag=value form.
+func parseBenchArgs(args []string) (benchOptions, error) {
+	opts := benchOptions{prompt: defaultBenchPrompt, timeout: defaultBenchTimeout}
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch name {
+		case "--json":
+			opts.json = true
+			continue
+		case "--prompt", "--timeout":
+		default:
+			return opts, fmt.Errorf("unknown argument %q", args[i])
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "--prompt":
+			if strings.TrimSpace(value) == "" {
+				return opts, fmt.Errorf("--prompt is empty")
+			}
+			opts.prompt = value
+		case "--timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return opts, fmt.Errorf("invalid timeout %q (e.g. 30s, 2m)", value)
+			}
+			opts.timeout = d
+		}
+	}
+	return opts, nil
+}
+
+func benchEntries(results []llm.BenchResult) []benchEntry {
+	entries := make([]benchEntry, 0, len(results))
+	for _, r := range results {
+		e := benchEntry{
+			Provider:     r.Provider,
+			Model:        r.Model,
+			LatencyMS:    r.Latency.Milliseconds(),
+			InputTokens:  r.InputTokens,
+			OutputTokens: r.OutputTokens,
+			Response:     r.Response,
+		}
+		if r.CostKnown {
+			e.CostUSD = &r.Cost
+		}
+		if r.Err != nil {
+			e.Error = r.Err.Error()
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// renderBench formats results as a table, with each response shortened to
+// one line.
+func renderBench(results []llm.BenchResult) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PROVIDER\tMODEL\tLATENCY\tIN\tOUT\tCOST\tRESPONSE")
+	for _, r := range results {
+		latency := r.Latency.Round(time.Millisecond).String()
+		if r.Err != nil {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\t❌ %s\n", r.Provider, r.Model, latency, oneLine(r.Err.Error(), benchPreviewLen))
+			continue
+		}
+		cost := "?"
+		if r.CostKnown {
+			cost = fmt.Sprintf("$%.5f", r.Cost)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", r.Provider, r.Model, latency,
+			r.InputTokens, r.OutputTokens, cost, oneLine(r.Response, benchPreviewLen))
+	}
+	_ = w.Flush()
+	b.WriteString("\nCosts are estimates from list prices; ? means the model's price is unknown.\n")
+	return b.String()
+}
+
+// oneLine collapses whitespace in s and shortens it to n characters.
+func oneLine(s string, n int) string {
+	return util.TruncateRunes(strings.Join(strings.Fields(s), " "), n)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/llm"
+)
+
+func TestParseBenchArgs(t *testing.T) {
+	opts, err := parseBenchArgs(nil)
+	if err != nil || opts.prompt != defaultBenchPrompt || opts.timeout != defaultBenchTimeout || opts.json {
+		t.Errorf("defaults = %+v, %v", opts, err)
+	}
+
+	opts, err = parseBenchArgs([]string{"--prompt", "Say hi", "--timeout=30s", "--json"})
+	if err != nil || opts.prompt != "Say hi" || opts.timeout != 30*time.Second || !opts.json {
+		t.Errorf("parsed = %+v, %v", opts, err)
+	}
+
+	for _, args := range [][]string{
+		{"--prompt"},
+		{"--prompt="},
+		{"--timeout", "soon"},
+		{"--timeout=-1s"},
+		{"--verbose"},
+	} {
+		if _, err := parseBenchArgs(args); err == nil {
+			t.Errorf("parseBenchArgs(%q) should fail", args)
+		}
+	}
+}
+
+func TestRenderBench(t *testing.T) {
+	out := renderBench([]llm.BenchResult{
+		{Provider: "openai", Model: "gpt-4o", Latency: 1200 * time.Millisecond, InputTokens: 12, OutputTokens: 40,
+			Cost: 0.00043, CostKnown: true, Response: "A hash table\nmaps keys to values."},
+		{Provider: "local", Model: "llama3", Latency: 3 * time.Second, Response: "ok"},
+		{Provider: "glm", Model: "glm-4.6", Latency: time.Second, Err: errors.New("timeout")},
+	})
+	for _, want := range []string{"$0.00043", "A hash table maps keys to values.", "1.2s", "?", "❌ timeout"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	entries := benchEntries([]llm.BenchResult{{Provider: "local"}, {Provider: "openai", Cost: 0.1, CostKnown: true}})
+	if entries[0].CostUSD != nil || entries[1].CostUSD == nil || *entries[1].CostUSD != 0.1 {
+		t.Errorf("cost_usd = %v, %v; want null and 0.1", entries[0].CostUSD, entries[1].CostUSD)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/kusa/magabot/internal/i18n"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/session"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+// handleCheckpointCommand handles "/checkpoint save|load|delete <name>" and
+// "/checkpoint list" for the chat's current session.
+func handleCheckpointCommand(args []string, lang string, msg *router.Message, store *storage.Store, sessionMgr *session.Manager, logger *slog.Logger) string {
+	if len(args) == 0 {
+		return i18n.T(lang, "checkpoint.usage")
+	}
+	sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+	action := strings.ToLower(args[0])
+
+	if action == "list" {
+		return formatCheckpoints(lang, sessionMgr.ListCheckpoints(sess))
+	}
+	if len(args) != 2 {
+		return i18n.T(lang, "checkpoint.usage")
+	}
+	name := args[1]
+
+	switch action {
+	case "save":
+		cp, err := sessionMgr.SaveCheckpoint(sess, name)
+		switch {
+		case errors.Is(err, session.ErrCheckpointLimit):
+			return i18n.T(lang, "checkpoint.limit", session.MaxCheckpoints)
+		case errors.Is(err, session.ErrCheckpointName):
+			return i18n.T(lang, "checkpoint.bad_name")
+		case err != nil:
+			return i18n.T(lang, "error", err)
+		}
+		if err := store.SaveCheckpoint(toStoredCheckpoint(sess.ID, cp)); err != nil {
+			logger.Warn("persist checkpoint failed", "error", err)
+		}
+		return i18n.T(lang, "checkpoint.saved", cp.Name, len(cp.Messages))
+
+	case "load":
+		cp, err := sessionMgr.LoadCheckpoint(sess, name)
+		if err != nil {
+			return i18n.T(lang, "checkpoint.not_found", name)
+		}
+		if err := store.ReplaceConversationHistory(sess.ID, toStoredCheckpoint(sess.ID, cp).Messages); err != nil {
+			logger.Warn("persist checkpoint load failed", "error", err)
+		}
+		return i18n.T(lang, "checkpoint.restored", cp.Name, len(cp.Messages))
+
+	case "delete":
+		if !sessionMgr.DeleteCheckpoint(sess, name) {
+			return i18n.T(lang, "checkpoint.not_found", name)
+		}
+		if err := store.DeleteCheckpoint(sess.ID, name); err != nil {
+			logger.Warn("delete checkpoint failed", "error", err)
+		}
+		return i18n.T(lang, "checkpoint.deleted", name)
+	}
+	return i18n.T(lang, "checkpoint.usage")
+}
+
+// formatCheckpoints renders a session's checkpoints in lang.
+func formatCheckpoints(lang string, cps []session.Checkpoint) string {
+	if len(cps) == 0 {
+		return i18n.T(lang, "checkpoint.none")
+	}
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "checkpoint.list", len(cps), session.MaxCheckpoints) + "\n")
+	for i, cp := range cps {
+		sb.WriteString(fmt.Sprintf("\n%d. ", i+1) + i18n.T(lang, "checkpoint.item", cp.Name, len(cp.Messages), cp.CreatedAt.Format("2006-01-02 15:04")))
+	}
+	return sb.String()
+}
+
+// toStoredCheckpoint converts a session checkpoint for the database.
+func toStoredCheckpoint(sessionKey string, cp session.Checkpoint) *storage.Checkpoint {
+	out := &storage.Checkpoint{SessionKey: sessionKey, Name: cp.Name, CreatedAt: cp.CreatedAt}
+	for _, m := range cp.Messages {
+		out.Messages = append(out.Messages, storage.ConversationMessage{
+			SessionKey: sessionKey, Role: m.Role, Content: m.Content, Timestamp: m.Timestamp,
+		})
+	}
+	return out
+}
+
+// sessionLoader reads sessions back from the database: the last maxHistory
+// messages and the saved checkpoints.
+func sessionLoader(store *storage.Store, maxHistory int) session.Loader {
+	return func(key string) ([]session.Message, []session.Checkpoint, error) {
+		history, err := store.GetConversationHistory(key, maxHistory)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load history: %w", err)
+		}
+		stored, err := store.GetCheckpoints(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load checkpoints: %w", err)
+		}
+		cps := make([]session.Checkpoint, len(stored))
+		for i, c := range stored {
+			cps[i] = session.Checkpoint{Name: c.Name, Messages: fromStoredMessages(c.Messages), CreatedAt: c.CreatedAt}
+		}
+		return fromStoredMessages(history), cps, nil
+	}
+}
+
+func fromStoredMessages(stored []storage.ConversationMessage) []session.Message {
+	msgs := make([]session.Message, len(stored))
+	for i, m := range stored {
+		msgs[i] = session.Message{Role: m.Role, Content: m.Content, Timestamp: m.Timestamp}
+	}
+	return msgs
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/session"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+func TestHandleCheckpointCommand(t *testing.T) {
+	store, err := storage.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	logger := slog.Default()
+	mgr := session.NewManager(nil, 50, logger)
+	msg := &router.Message{Platform: "telegram", ChatID: "c1", UserID: "u1"}
+	sess := mgr.GetOrCreateThread("telegram", "c1", "", "u1")
+
+	mgr.AddMessage(sess, "user", "first")
+	if got := handleCheckpointCommand([]string{"save", "base"}, "en", msg, store, mgr, logger); !strings.Contains(got, "1 messages") {
+		t.Fatalf("save = %q", got)
+	}
+	mgr.AddMessage(sess, "user", "second")
+	if got := handleCheckpointCommand([]string{"load", "base"}, "en", msg, store, mgr, logger); !strings.Contains(got, "Restored") {
+		t.Fatalf("load = %q", got)
+	}
+	if n := len(mgr.GetHistory(sess, 0)); n != 1 {
+		t.Errorf("history after load = %d messages, want 1", n)
+	}
+	if got := handleCheckpointCommand([]string{"load", "nope"}, "en", msg, store, mgr, logger); !strings.Contains(got, "No checkpoint") {
+		t.Errorf("load missing = %q", got)
+	}
+
+	// Checkpoints survive a restart
+	fresh := session.NewManager(nil, 50, logger)
+	fresh.SetLoader(sessionLoader(store, 50))
+	if got := handleCheckpointCommand([]string{"list"}, "en", msg, store, fresh, logger); !strings.Contains(got, "`base` — 1 messages") {
+		t.Errorf("list after restore = %q", got)
+	}
+}
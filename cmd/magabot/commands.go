@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/kusa/magabot/internal/config"
-	"github.com/kusa/magabot/internal/security"
 	"github.com/kusa/magabot/internal/storage"
 	"github.com/kusa/magabot/internal/util"
 	"github.com/kusa/magabot/internal/version"
@@ -360,13 +359,6 @@ func cmdUninstall() {
 	fmt.Println("   To reinstall, run: magabot setup")
 }
 
-// cmdGenKey generates an encryption key
-func cmdGenKey() {
-	key := security.GenerateKey()
-	fmt.Printf("Generated key: %s\n", key)
-	fmt.Println("Add this to your config.yaml under security.encryption_key")
-}
-
 // cmdQR displays the WhatsApp QR code for pairing
 func cmdQR() {
 	// Load config to get the actual data_dir (may differ from default)
@@ -39,6 +39,15 @@ func stopProcess(pid int) error {
 	return process.Signal(syscall.SIGTERM)
 }
 
+// signalTokenReload asks the running daemon to reload webhook bearer tokens.
+func signalTokenReload(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGUSR1)
+}
+
 func processExists(pid int) bool {
 	process, err := os.FindProcess(pid)
 	if err != nil {
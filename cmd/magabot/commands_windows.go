@@ -35,6 +35,10 @@ func stopProcess(pid int) error {
 	return exec.Command("taskkill", "/F", "/PID", fmt.Sprintf("%d", pid)).Run()
 }
 
+func signalTokenReload(_ int) error {
+	return fmt.Errorf("live reload is not supported on Windows, run 'magabot restart'")
+}
+
 func processExists(pid int) bool {
 	cmd := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH")
 	output, err := cmd.Output()
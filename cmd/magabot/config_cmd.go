@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,7 +13,7 @@ import (
 
 func cmdConfig() {
 	if len(os.Args) < 3 {
-		cmdConfigShow()
+		cmdConfigShow(nil)
 		return
 	}
 
@@ -20,7 +21,7 @@ func cmdConfig() {
 
 	switch subCmd {
 	case "show", "status":
-		cmdConfigShow()
+		cmdConfigShow(os.Args[3:])
 	case "edit":
 		cmdConfigEdit()
 	case "admin":
@@ -36,13 +37,31 @@ func cmdConfig() {
 	}
 }
 
-func cmdConfigShow() {
+func cmdConfigShow(args []string) {
+	full, reveal := false, false
+	for _, a := range args {
+		switch a {
+		case "--full":
+			full = true
+		case "--reveal":
+			full, reveal = true, true
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown flag: %s\n", a)
+			os.Exit(1)
+		}
+	}
+
 	cfg, err := config.Load(configFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
+	if full {
+		cmdConfigShowFull(cfg, reveal)
+		return
+	}
+
 	fmt.Println("📁 Config File:", configFile)
 	fmt.Println()
 
@@ -105,6 +124,37 @@ func cmdConfigShow() {
 	}
 }
 
+// cmdConfigShowFull prints the whole config as YAML. Secrets are masked
+// unless --reveal was given and the user confirms.
+func cmdConfigShowFull(cfg *config.Config, reveal bool) {
+	if reveal {
+		fmt.Println("⚠️  This prints API keys and tokens in plaintext.")
+		if !askYesNo(bufio.NewReader(os.Stdin), "Reveal secrets?", false) {
+			reveal = false
+		}
+		fmt.Println()
+	}
+
+	var data []byte
+	var err error
+	if reveal {
+		data, err = os.ReadFile(configFile) // #nosec G304 -- fixed config path
+	} else {
+		data, err = cfg.RedactedYAML()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("📁 Config File:", configFile)
+	if !reveal {
+		fmt.Println("   (secrets masked — use --reveal to show them)")
+	}
+	fmt.Println()
+	fmt.Print(string(data))
+}
+
 func cmdConfigEdit() {
 	// Find editor
 	editor := os.Getenv("EDITOR")
@@ -295,6 +345,8 @@ Usage: magabot config <command>
 
 Commands:
   show          Show current configuration summary
+  show --full   Print the whole config with secrets masked
+  show --reveal Print the whole config with secrets in plaintext (asks first)
   edit          Edit config.yaml in $EDITOR
   admin <cmd>   Manage platform admins
   path          Print config file path
@@ -138,8 +138,21 @@ func cmdCronAdd() {
 		os.Exit(1)
 	}
 
+	// Kind
+	fmt.Print("\nKind [message/llm_digest] (default: message): ")
+	kind, _ := reader.ReadString('\n')
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind != "" && kind != cron.KindMessage && kind != cron.KindLLMDigest {
+		fmt.Printf("Error: Unknown kind %q\n", kind)
+		os.Exit(1)
+	}
+
 	// Message
-	fmt.Print("\nMessage to send: ")
+	if kind == cron.KindLLMDigest {
+		fmt.Print("\nPrompt for the LLM (its answer is posted): ")
+	} else {
+		fmt.Print("\nMessage to send: ")
+	}
 	message, _ := reader.ReadString('\n')
 	message = strings.TrimSpace(message)
 	if message == "" {
@@ -147,6 +160,14 @@ func cmdCronAdd() {
 		os.Exit(1)
 	}
 
+	// Memory user
+	var memoryUser string
+	if kind == cron.KindLLMDigest {
+		fmt.Print("Memory user, whose memories are added as context (optional): ")
+		memoryUser, _ = reader.ReadString('\n')
+		memoryUser = strings.TrimSpace(memoryUser)
+	}
+
 	// Channels
 	fmt.Println("\nChannel format: type:target")
 	fmt.Println("  telegram:123456789     - Telegram chat ID")
@@ -194,7 +215,9 @@ func cmdCronAdd() {
 		Name:        name,
 		Description: desc,
 		Schedule:    schedule,
+		Kind:        kind,
 		Message:     message,
+		MemoryUser:  memoryUser,
 		Channels:    channels,
 		Enabled:     enabled,
 	}
@@ -271,6 +294,16 @@ func cmdCronEdit() {
 		job.Message = message
 	}
 
+	// Memory user
+	if job.IsDigest() {
+		fmt.Printf("Memory user [%s]: ", job.MemoryUser)
+		memoryUser, _ := reader.ReadString('\n')
+		memoryUser = strings.TrimSpace(memoryUser)
+		if memoryUser != "" {
+			job.MemoryUser = memoryUser
+		}
+	}
+
 	if err := store.Update(job); err != nil {
 		fmt.Fprintf(os.Stderr, "Error updating job: %v\n", err)
 		os.Exit(1)
@@ -424,7 +457,15 @@ func cmdCronShow() {
 	fmt.Printf("Description: %s\n", job.Description)
 	fmt.Printf("Schedule:    %s\n", job.Schedule)
 	fmt.Printf("Status:      %s\n", status)
-	fmt.Printf("Message:     %s\n", job.Message)
+	if job.IsDigest() {
+		fmt.Printf("Kind:        %s\n", job.Kind)
+		fmt.Printf("Prompt:      %s\n", job.Message)
+		if job.MemoryUser != "" {
+			fmt.Printf("Memory user: %s\n", job.MemoryUser)
+		}
+	} else {
+		fmt.Printf("Message:     %s\n", job.Message)
+	}
 	fmt.Println("Channels:")
 	for _, ch := range job.Channels {
 		fmt.Printf("  - %s: %s\n", ch.Type, ch.Target)
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/bot"
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/cron"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/memory"
+	"github.com/kusa/magabot/internal/platform/webhook"
+	"github.com/kusa/magabot/internal/router"
+)
+
+// digestTimeout bounds one llm_digest generation.
+const digestTimeout = 3 * time.Minute
+
+// startCronScheduler runs the jobs managed by `magabot cron` inside the
+// daemon, with llm_digest jobs answered by llmRouter and sends paced by
+// rtr's per-platform send limits and held for its quiet hours. It returns
+// nil when cron.enabled is off.
+func startCronScheduler(cfg *config.Config, rtr *router.Router, llmRouter *llm.Router, memoryH *bot.MemoryHandler, prompts *systemPrompts, logger *slog.Logger) *cron.Scheduler {
+	if !cfg.Cron.Enabled {
+		return nil
+	}
+	store, err := cron.NewJobStore(dataDir)
+	if err != nil {
+		logger.Warn("cron disabled, cannot load jobs", "error", err)
+		return nil
+	}
+
+	var nc cron.NotifierConfig
+	if cfg.Platforms.Telegram != nil {
+		nc.TelegramToken = cfg.Platforms.Telegram.BotToken
+	}
+	if cfg.Platforms.Slack != nil {
+		nc.SlackToken = cfg.Platforms.Slack.BotToken
+	}
+	if cfg.Platforms.Discord != nil {
+		nc.DiscordToken = cfg.Platforms.Discord.Token
+	}
+
+	notifier := cron.NewNotifier(nc)
+	notifier.SetThrottle(rtr.WaitToSend)
+	notifier.SetHold(rtr.Hold)
+	scheduler := cron.NewScheduler(store, notifier)
+	scheduler.SetGenerator(digestGenerator(cfg, llmRouter, memoryH, prompts))
+	if err := scheduler.Start(); err != nil {
+		logger.Warn("start cron scheduler failed", "error", err)
+		return nil
+	}
+	return scheduler
+}
+
+// webhookCronRunner runs the jobs the webhook's cron endpoint triggers on
+// scheduler, the way `magabot cron run` does.
+func webhookCronRunner(scheduler *cron.Scheduler) webhook.CronRunner {
+	return func(ctx context.Context, id string) (string, error) {
+		message, err := scheduler.RunJob(ctx, id)
+		if errors.Is(err, cron.ErrJobNotFound) {
+			return "", fmt.Errorf("%w: %s", webhook.ErrUnknownCronJob, id)
+		}
+		return message, err
+	}
+}
+
+// digestGenerator sends an llm_digest job's message to the LLM as a prompt,
+// prefixed with the memory_user's relevant memories when set.
+func digestGenerator(cfg *config.Config, llmRouter *llm.Router, memoryH *bot.MemoryHandler, prompts *systemPrompts) cron.Generator {
+	return func(ctx context.Context, job *cron.Job) (string, error) {
+		prompt := job.Message
+		var sources []memory.Source
+		if job.MemoryUser != "" && memoryH != nil {
+			var mem string
+			mem, sources = memoryH.Recall(ctx, job.MemoryUser, job.Message, memoryContextLimit(cfg))
+			if mem != "" {
+				prompt = mem + memory.CitationInstruction + "\n\n" + prompt
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, digestTimeout)
+		defer cancel()
+
+		req := &llm.Request{
+			UserID:       "cron:" + job.ID,
+			Messages:     []llm.Message{{Role: "user", Content: prompt}},
+			SystemPrompt: llm.BuildSystemPrompt(prompts.expand(nil, job.MemoryUser, ""), ""),
+		}
+		resolveParams(cfg, req, profileDigest, 0)
+		resolveReasoning(cfg, req, llmRouter.MainProvider(), "")
+		ch, err := llmRouter.StreamRequest(ctx, req)
+		if err != nil {
+			return "", err
+		}
+
+		var sb strings.Builder
+		for chunk := range ch {
+			if chunk.Error != nil {
+				return "", fmt.Errorf("llm: %w", chunk.Error)
+			}
+			sb.WriteString(chunk.Content)
+		}
+		answer := strings.TrimSpace(sb.String())
+		return withSources(answer, memory.Cited(answer, sources)), nil
+	}
+}
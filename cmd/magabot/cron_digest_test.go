@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kusa/magabot/internal/bot"
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/cron"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/memory"
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/allmtest"
+)
+
+func TestDigestGenerator(t *testing.T) {
+	mock := allmtest.NewMockProvider("test",
+		allmtest.WithResponse(&allm.Response{Content: "3 new items today."}),
+	)
+	llmRouter := llm.NewRouter(&llm.Config{Main: "test"})
+	llmRouter.Register("test", allm.New(mock))
+
+	memoryH := bot.NewMemoryHandler(t.TempDir(), nil)
+	if _, err := memoryH.HandleCommand("user1", "telegram", []string{"add", "I follow the Go release feed"}); err != nil {
+		t.Fatalf("add memory: %v", err)
+	}
+
+	cfg := &config.Config{}
+	prompts, err := parseSystemPrompts(cfg)
+	if err != nil {
+		t.Fatalf("parseSystemPrompts: %v", err)
+	}
+	generate := digestGenerator(cfg, llmRouter, memoryH, prompts)
+
+	job := &cron.Job{ID: "j1", Kind: cron.KindLLMDigest, Message: "Summarize the Go release feed", MemoryUser: "user1"}
+	got, err := generate(context.Background(), job)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if got != "3 new items today." {
+		t.Errorf("digest = %q", got)
+	}
+
+	req := mock.LastRequest()
+	if req == nil || len(req.Messages) == 0 {
+		t.Fatal("No request captured")
+	}
+	prompt := req.Messages[len(req.Messages)-1].Content
+	if !strings.Contains(prompt, "Summarize the Go release feed") {
+		t.Errorf("prompt missing job message: %q", prompt)
+	}
+	if !strings.Contains(prompt, "I follow the Go release feed") {
+		t.Errorf("prompt missing memory context: %q", prompt)
+	}
+}
+
+func TestDigestGenerator_Sources(t *testing.T) {
+	memoryH := bot.NewMemoryHandler(t.TempDir(), nil)
+	if _, err := memoryH.HandleCommand("user1", "telegram", []string{"add", "I follow the Go release feed"}); err != nil {
+		t.Fatalf("add memory: %v", err)
+	}
+	store, err := memoryH.GetStore("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := memory.CiteID(store.List("")[0].ID)
+
+	mock := allmtest.NewMockProvider("test",
+		allmtest.WithResponse(&allm.Response{Content: "Go 1.99 is out [" + id + "]."}),
+	)
+	llmRouter := llm.NewRouter(&llm.Config{Main: "test"})
+	llmRouter.Register("test", allm.New(mock))
+
+	cfg := &config.Config{}
+	prompts, err := parseSystemPrompts(cfg)
+	if err != nil {
+		t.Fatalf("parseSystemPrompts: %v", err)
+	}
+	job := &cron.Job{ID: "j1", Kind: cron.KindLLMDigest, Message: "Summarize the Go release feed", MemoryUser: "user1"}
+	got, err := digestGenerator(cfg, llmRouter, memoryH, prompts)(context.Background(), job)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	prompt := mock.LastRequest().Messages[0].Content
+	if !strings.Contains(prompt, "- ["+id+"] ") || !strings.Contains(prompt, memory.CitationInstruction) {
+		t.Errorf("prompt missing cite IDs: %q", prompt)
+	}
+	want := "Go 1.99 is out [" + id + "].\n\n📚 *Sources:*\n[" + id + "] I follow the Go release feed"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("digest = %q, want prefix %q", got, want)
+	}
+}
@@ -3,12 +3,18 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,8 +24,11 @@ import (
 	"github.com/kusa/magabot/internal/backup"
 	"github.com/kusa/magabot/internal/bot"
 	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/embedding"
 	"github.com/kusa/magabot/internal/hooks"
+	"github.com/kusa/magabot/internal/i18n"
 	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/memory"
 	"github.com/kusa/magabot/internal/platform/slack"
 	"github.com/kusa/magabot/internal/platform/telegram"
 	"github.com/kusa/magabot/internal/platform/webhook"
@@ -30,6 +39,7 @@ import (
 	"github.com/kusa/magabot/internal/session"
 	"github.com/kusa/magabot/internal/skills"
 	"github.com/kusa/magabot/internal/storage"
+	"github.com/kusa/magabot/internal/telemetry"
 	"github.com/kusa/magabot/internal/updater"
 	"github.com/kusa/magabot/internal/util"
 	"github.com/kusa/magabot/internal/version"
@@ -74,23 +84,20 @@ func runDaemon() {
 		logLevel = slog.LevelError
 	}
 
-	logOpts := &slog.HandlerOptions{Level: logLevel}
-	var logHandler slog.Handler
-
-	if cfg.Logging.File != "" {
-		logFileHandle, err := os.OpenFile(cfg.Logging.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "open log file: %v\n", err)
-			os.Exit(1)
-		}
-		defer func() { _ = logFileHandle.Close() }()
-		logHandler = slog.NewJSONHandler(logFileHandle, logOpts)
-	} else {
-		logHandler = slog.NewTextHandler(os.Stderr, logOpts)
+	logHandler, logFile, err := newLogHandler(cfg.Logging, &slog.HandlerOptions{Level: logLevel})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if logFile != nil {
+		defer func() { _ = logFile.Close() }()
 	}
 	logger := slog.New(logHandler)
 
 	logger.Info("magabot starting", "version", version.Short())
+	for _, change := range cfg.Migrations() {
+		logger.Info("config migrated in memory, written on next save", "change", change)
+	}
 
 	// Load secrets from backend and overlay onto config
 	secretsMgr := loadSecrets(cfg, logger)
@@ -98,6 +105,20 @@ func runDaemon() {
 		defer secretsMgr.Stop()
 	}
 
+	// Start tracing (no-op unless observability.otlp_endpoint is set)
+	shutdownTracing, err := telemetry.Setup(context.Background(), telemetry.Config{
+		Endpoint:       cfg.Observability.OTLPEndpoint,
+		Headers:        cfg.Observability.OTLPHeaders,
+		ServiceVersion: version.Short(),
+	})
+	if err != nil {
+		logger.Error("init tracing failed", "error", err)
+		os.Exit(1)
+	}
+	if telemetry.Enabled() {
+		logger.Info("tracing enabled", "otlp_endpoint", cfg.Observability.OTLPEndpoint)
+	}
+
 	// Ensure all configured directories exist before initializing subsystems
 	if err := cfg.EnsureDirectories(); err != nil {
 		logger.Error("ensure directories failed", "error", err)
@@ -143,11 +164,18 @@ func runDaemon() {
 	llmCfg := &llm.Config{
 		Main:            cfg.LLM.Main,
 		SystemPrompt:    cfg.LLM.SystemPrompt,
-		MaxInput:        cfg.LLM.MaxInputLength,
+		MaxInput:        cfg.LLM.MaxInputTokens,
 		MaxContextChars: cfg.LLM.MaxContextChars,
 		Timeout:         cfg.LLM.Timeout.Duration(),
 		RateLimit:       cfg.LLM.RateLimit,
+		LogPrompts:      cfg.Logging.LogPrompts,
+		RedactMessages:  cfg.Logging.RedactMessages,
 		Logger:          logger.With("component", "llm"),
+
+		MaxConcurrentPerProvider: cfg.LLM.MaxConcurrentPerProvider,
+		ContextWindows:           cfg.LLM.ContextWindows,
+		VisionModels:             cfg.LLM.VisionModels,
+		Tokenizers:               loadTokenizers(cfg.LLM.Tokenizers, logger),
 	}
 	llmRouter := llm.NewRouter(llmCfg)
 
@@ -157,53 +185,29 @@ func runDaemon() {
 	}
 
 	// Register LLM providers using allm-go (with URL validation - A10 SSRF protection)
-	if cfg.LLM.Anthropic.Enabled {
-		if err := registerAnthropicProvider(llmRouter, cfg); err != nil {
-			logger.Error("register anthropic provider failed", "error", err)
-		}
-	}
-
-	if cfg.LLM.OpenAI.Enabled {
-		if err := registerOpenAIProvider(llmRouter, cfg); err != nil {
-			logger.Error("register openai provider failed", "error", err)
-		}
-	}
-
-	if cfg.LLM.GLM.Enabled {
-		if err := registerAnthropicCompatProvider(llmRouter, "glm", cfg.LLM.GLM, cfg); err != nil {
-			logger.Error("register glm provider failed", "error", err)
-		}
-	}
-
-	if cfg.LLM.Local.Enabled {
-		if err := registerCompatProvider(llmRouter, compatProviderConfig{
-			name: "local", model: cfg.LLM.Local.Model,
-			maxTokens: derefInt(cfg.LLM.Local.MaxTokens), temperature: derefFloat64(cfg.LLM.Local.Temperature),
-			baseURL: cfg.LLM.Local.BaseURL, isLocal: true, maxRetries: derefInt(cfg.LLM.Local.MaxRetries),
-		}, cfg); err != nil {
-			logger.Error("register local provider failed", "error", err)
-		}
-	}
-
-	if cfg.LLM.Kimi.Enabled {
-		if err := registerAnthropicCompatProvider(llmRouter, "kimi", cfg.LLM.Kimi, cfg); err != nil {
-			logger.Error("register kimi provider failed", "error", err)
-		}
-	}
-
-	if cfg.LLM.MiniMax.Enabled {
-		if err := registerAnthropicCompatProvider(llmRouter, "minimax", cfg.LLM.MiniMax, cfg); err != nil {
-			logger.Error("register minimax provider failed", "error", err)
-		}
-	}
+	registerLLMProviders(llmRouter, cfg, logger)
 
 	// Restore persisted LLM settings (effort, fallback) from config
 	restoreLLMSettings(llmRouter, cfg, logger)
 
+	// Parse system prompt templates ({{.Date}}, {{.UserID}}, ...) once
+	prompts, err := parseSystemPrompts(cfg)
+	if err != nil {
+		logger.Error("invalid system prompt template", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize bot handlers
 	adminHandler := bot.NewAdminHandler(cfg, configDir)
-	memoryHandler := bot.NewMemoryHandler(cfg.Paths.MemoryDir)
+	memoryHandler := bot.NewMemoryHandler(cfg.Paths.MemoryDir, newMemoryEmbedder(cfg, logger))
+	memoryHandler.SetVectorStore(cfg.Memory.VectorDriver, cfg.Memory.VectorDSN)
+	memoryHandler.SetRetrieval(float32(cfg.Memory.MinSimilarity), cfg.Memory.MinResults)
 	confirmMgr := bot.NewConfirmationManager()
+	moderator, err := newModerator(cfg, store, logger)
+	if err != nil {
+		logger.Error("refusing to start with moderation enabled", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize session manager
 	maxHistory := cfg.Session.MaxHistory
@@ -214,6 +218,10 @@ func runDaemon() {
 	// Initialize message router
 	rtr := router.NewRouter(store, vault, cfg, authorizer, rateLimiter, logger)
 
+	// Plugins add their LLM providers before routing rules are checked
+	pluginMgr := startPlugins(cfg, rtr, llmRouter, logger)
+	setupLLMRouting(llmRouter, cfg, logger)
+
 	// Initialize audit logger
 	auditLogger, err := security.NewAuditLogger(filepath.Dir(cfg.GetSecurityLogPath()))
 	if err != nil {
@@ -227,8 +235,24 @@ func runDaemon() {
 	hooksMgr := hooks.NewManager(mergeHooksConfig(cfg, logger), logger.With("component", "hooks"))
 	rtr.SetHooks(hooksMgr)
 
+	// Onboard users without access instead of denying them silently
+	if cfg.Bot.NewUserMessage != "" {
+		rtr.SetNewUserHandler(func(msg *router.Message) string {
+			notifyNewUser(rtr, cfg, msg, logger)
+			return cfg.Bot.NewUserMessage
+		})
+	}
+
 	// Initialize skills manager
 	skillsMgr := skills.NewManager(cfg.Skills.Dir)
+	skillsMgr.SetLimits(cfg.Skills.Timeout.Duration(), cfg.Skills.MaxConcurrent)
+	skillsMgr.SetLogger(logger.With("component", "skills"))
+	skillsMgr.SetSandbox(skills.SandboxConfig{
+		AllowScripts:    cfg.Skills.AllowScripts,
+		Enabled:         cfg.Skills.Sandbox,
+		AllowedCommands: cfg.Skills.AllowedCommands,
+		AllowedHosts:    cfg.Skills.AllowedHosts,
+	})
 	if err := skillsMgr.LoadAll(); err != nil {
 		logger.Warn("failed to load skills", "error", err)
 	}
@@ -251,12 +275,28 @@ func runDaemon() {
 		SessionTimeout: cfg.Agent.SessionTimeout.Seconds(),
 		Shortcuts:      cfg.Agent.Shortcuts,
 		DiscoverDepth:  cfg.Agent.DiscoverDepth,
+		MaxSessions:    cfg.Agent.MaxSessions,
 		PlanDelegate:   cfg.Agent.PlanDelegate != nil && *cfg.Agent.PlanDelegate,
 		CLIPath:        agentCLIPath,
+		AllowedDirs:    cfg.Agent.AllowedDirs,
+		Policy: agent.Policy{
+			AllowedCommands: cfg.Agent.AllowedCommands,
+			DeniedPaths:     cfg.Agent.DeniedPaths,
+		},
+		OnCommand: func(sess *agent.Session, command string, blocked error) {
+			action := "agent_command"
+			if blocked != nil {
+				action = "agent_command_blocked"
+			}
+			details := fmt.Sprintf("%s in %s: %s", sess.Agent, sess.Dir, util.Truncate(command, 500))
+			_ = store.AuditLog(sess.Platform, security.HashUserID(sess.Platform, sess.UserID), action, details)
+		},
 		OnSessionClose: func(platform, chatID, message string) {
-			_ = rtr.Send(platform, chatID, message)
+			_ = rtr.Notify(platform, chatID, message)
 		},
-		OnUsage: llmRouter.TrackUsage,
+		OnUsage:   llmRouter.TrackUsage,
+		RecordDir: agentRecordDir(cfg),
+		Redact:    agentRedact(cfg),
 		GetCLISettings: func() string {
 			if cli := llmRouter.CLIProvider(); cli != nil {
 				return cli.Effort()
@@ -265,49 +305,85 @@ func runDaemon() {
 		},
 	}, logger.With("component", "agent"))
 
-	// Create session manager with router's send function for background task notifications
+	// Create session manager with router's send function for background task
+	// notifications, which quiet hours hold back
+	rtr.SetQuietHours(newQuietHours(cfg, logger))
 	sessionMgr := session.NewManager(func(platform, chatID, message string) error {
-		return rtr.Send(platform, chatID, message)
+		return rtr.Notify(platform, chatID, message)
 	}, maxHistory, logger)
+	sessionMode, err := session.ParseMode(cfg.Session.Mode)
+	if err != nil {
+		logger.Error("invalid session config", "error", err)
+		os.Exit(1)
+	}
+	sessionMgr.SetMode(sessionMode)
+	if err := cfg.Commands.Validate(); err != nil {
+		logger.Error("invalid commands config", "error", err)
+		os.Exit(1)
+	}
 	sessionHandler := bot.NewSessionHandler(sessionMgr)
 
-	// Preload conversation history from DB into session memory
-	if keys, err := store.ListConversationSessions(); err != nil {
-		logger.Warn("failed to list conversation sessions", "error", err)
-	} else {
-		restored := 0
-		for _, key := range keys {
-			// key format: "platform:chatID"
-			parts := strings.SplitN(key, ":", 2)
-			if len(parts) != 2 {
-				continue
-			}
-			dbHistory, err := store.GetConversationHistory(key, maxHistory)
-			if err != nil || len(dbHistory) == 0 {
-				continue
-			}
-			sess := sessionMgr.GetOrCreate(parts[0], parts[1], "")
-			for _, h := range dbHistory {
-				sessionMgr.AddMessage(sess, h.Role, h.Content)
-			}
-			restored++
-		}
-		if restored > 0 {
-			logger.Info("preloaded conversation sessions", "count", restored)
-		}
+	// Sessions load their history and checkpoints from the DB on first use,
+	// and come back the same way after eviction, with their settings
+	sessionMgr.SetLoader(sessionLoader(store, maxHistory))
+	sessionMgr.SetContextStore(store)
+	sessionMgr.SetMaxSessions(cfg.Session.MaxSessions)
+	if cfg.Session.MaxTurns*2 > maxHistory {
+		logger.Warn("session.max_turns is never reached: history keeps fewer messages than that many turns",
+			"max_turns", cfg.Session.MaxTurns, "max_history", maxHistory)
+	}
+
+	// Hard daily budget per user, on top of the per-minute rate limit
+	if quota := newDailyQuota(cfg, store, logger); quota != nil {
+		rtr.SetDailyQuota(quota, func(msg *router.Message, resetAt time.Time) string {
+			return i18n.T(userLanguage(cfg, sessionMgr, msg), "quota.exceeded", resetAt.Format("15:04 MST"))
+		})
+	}
+
+	// Directories platforms download media into
+	downloadDirs := []string{
+		filepath.Join(cfg.GetPlatformDir("telegram"), "downloads"),
+		filepath.Join(cfg.GetPlatformDir("whatsapp"), "downloads"),
+		cfg.Paths.DownloadsDir,
 	}
 
 	// Set message handler with LLM integration
-	rtr.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
-		logArgs := []any{"platform", msg.Platform, "user", security.HashUserID(msg.Platform, msg.UserID)}
+	debounce := newDebouncer(cfg.Session.Debounce.Duration())
+	toolMgr := newToolManager(cfg, logger)
+	var handle router.MessageHandler
+	handle = func(ctx context.Context, msg *router.Message) (string, error) {
+		if cfg.Paths.DownloadsEphemeral && len(msg.Media) > 0 {
+			defer removeDownloads(append([]string(nil), msg.Media...), downloadDirs, logger)
+		}
+
+		logArgs := []any{"platform", msg.Platform, "user", security.HashUserID(msg.Platform, msg.UserID), "request_id", msg.RequestID}
 		if msg.ReplyTo != nil {
 			logArgs = append(logArgs, "reply_to_user", msg.ReplyTo.Username, "reply_to_text", util.Truncate(msg.ReplyTo.Text, 80))
 		}
 		logger.Info("received message", logArgs...)
 
+		// A button press stands for the command or answer in its data;
+		// typed commands use the platform's prefix and are rewritten to "/"
+		var isCommand bool
+		if data, ok := router.ButtonData(msg.Text); ok {
+			msg.Text = data
+			isCommand = strings.HasPrefix(data, "/")
+		} else {
+			msg.Text, isCommand = normalizeCommand(cfg.CommandPrefix(msg.Platform), msg.Text)
+		}
+
+		// A command or media ends the user's pending batch of messages
+		debounceKey := msg.Platform + ":" + msg.ChatID + ":" + msg.ThreadID + ":" + msg.UserID
+		if debounce != nil && (isCommand || len(msg.Media) > 0) {
+			debounce.flush(debounceKey)
+		}
+
 		// Handle bot commands (skip if matched by a skill command trigger)
-		if strings.HasPrefix(msg.Text, "/") && !skillsMgr.IsSkillCommand(msg.Text) {
-			return handleCommand(msg, llmRouter, store, cfg, adminHandler, memoryHandler, sessionHandler, sessionMgr, confirmMgr, logger)
+		if isCommand && !skillsMgr.IsSkillCommand(msg.Text) {
+			if reply, ok, err := handlePluginCommand(ctx, pluginMgr, msg, cfg, sessionMgr); ok {
+				return reply, err
+			}
+			return handleCommand(msg, rtr, llmRouter, store, cfg, adminHandler, memoryHandler, sessionHandler, sessionMgr, confirmMgr, logger)
 		}
 
 		// Handle pending confirmation (y/n)
@@ -323,16 +399,38 @@ func runDaemon() {
 			}
 		}
 
-		// Handle agent session commands (:new, :quit, :status)
+		// :send <name> <message> talks to a named agent session
+		if name, text, ok := parseAgentSend(msg.Text); ok {
+			lang := userLanguage(cfg, sessionMgr, msg)
+			if cfg.CommandDisabled(":send") {
+				return i18n.T(lang, "command.disabled", ":send"), nil
+			}
+			if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
+				return i18n.T(lang, "agent.admin_required"), nil
+			}
+			if name == "" || text == "" {
+				return i18n.T(lang, "agent.send_usage"), nil
+			}
+			if agentMgr.GetNamedSession(msg.Platform, msg.ChatID, name) == nil {
+				return i18n.T(lang, "agent.send_not_found", name, name), nil
+			}
+			sendMsg := *msg
+			sendMsg.Text = text
+			return routeToAgent(ctx, &sendMsg, name, agentMgr, func(text string) {
+				_ = rtr.Send(msg.Platform, msg.ChatID, text)
+			}, *cfg.Agent.StreamOutput, llmRouter, skillsMgr)
+		}
+
+		// Handle agent session commands (:new, :quit, :status, :save)
 		if strings.HasPrefix(msg.Text, ":") {
-			return handleAgentCommand(msg, agentMgr, cfg)
+			return handleAgentCommand(msg, agentMgr, cfg, userLanguage(cfg, sessionMgr, msg))
 		}
 
-		// Route to active agent session if one exists
+		// Route to the default agent session if one exists
 		if agentMgr.HasSession(msg.Platform, msg.ChatID) {
-			return routeToAgent(ctx, msg, agentMgr, func(text string) {
+			return routeToAgent(ctx, msg, agent.DefaultSession, agentMgr, func(text string) {
 				_ = rtr.Send(msg.Platform, msg.ChatID, text)
-			}, llmRouter, skillsMgr)
+			}, *cfg.Agent.StreamOutput, llmRouter, skillsMgr)
 		}
 
 		// If the message looks like a coding implementation task, short-circuit before
@@ -345,6 +443,25 @@ func runDaemon() {
 			return "⚠️ This request requires agent mode which requires admin access.", nil
 		}
 
+		// Answer messages sent in quick succession as one turn: each is
+		// buffered and gets no reply of its own, and the batch is answered
+		// through the router once the user pauses. Webhook callers each wait
+		// for their own answer, and the router can't send into a thread, so
+		// neither is batched
+		if debounce != nil && !isCommand && len(msg.Media) == 0 && msg.Platform != "webhook" && msg.ThreadID == "" && !isDebounced(ctx) {
+			batch := *msg
+			batch.StreamCallback, batch.OnSent = nil, nil // the platform's dispatch has returned
+			batchCtx := withDebounced(context.WithoutCancel(ctx))
+			if debounce.add(debounceKey, msg.Text, func(text string) {
+				batch.Text = text
+				if err := rtr.Answer(batchCtx, &batch, handle); err != nil {
+					logger.Warn("answer batched messages failed", "platform", batch.Platform, "request_id", batch.RequestID, "error", err)
+				}
+			}) {
+				return "", nil
+			}
+		}
+
 		// Check if first-time user
 		isFirst, err := store.IsFirstMessage(msg.Platform, security.HashUserID(msg.Platform, msg.UserID))
 		if err != nil {
@@ -359,8 +476,18 @@ func runDaemon() {
 			)
 		}
 
-		// Get or create session for this chat
-		sess := sessionMgr.GetOrCreate(msg.Platform, msg.ChatID, msg.UserID)
+		// Get or create session for this chat (or thread)
+		sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+
+		// Start over once the chat reaches session.max_turns, so it doesn't
+		// drift or grow costly
+		resetNotice := ""
+		if maxTurns := cfg.Session.MaxTurns; maxTurns > 0 && sessionMgr.Turns(sess) >= maxTurns {
+			if err := resetConversation(sessionMgr, store, sess); err != nil {
+				logger.Warn("clear conversation history failed", "session", sess.ID, "error", err)
+			}
+			resetNotice = i18n.T(userLanguage(cfg, sessionMgr, msg), "session.auto_reset", maxTurns)
+		}
 
 		// Build message list from session history
 		history := sessionMgr.GetHistory(sess, maxHistory)
@@ -389,12 +516,37 @@ func runDaemon() {
 			content = fmt.Sprintf("[Replying to %s: %s]\n\n%s", sender, msg.ReplyTo.Text, content)
 		}
 
+		var mediaNotes []string // shown to the user ahead of the reply
+		var images []llm.Image  // sent as image blocks; the router picks a model that reads them
 		if len(msg.Media) > 0 {
 			var otherMedia []string
 			var transcripts []string
 			for _, path := range msg.Media {
-				if isAudioFile(path) {
-					transcript, err := transcribeAudioFile(path)
+				if isImageFile(path) {
+					prepared, err := prepareImage(path, cfg.Media)
+					if err != nil {
+						logger.Warn("image skipped", "path", path, "error", err)
+						key := "image.invalid"
+						if errors.Is(err, errImageTooLarge) {
+							key = "image.too_large"
+						}
+						mediaNotes = append(mediaNotes, i18n.T(userLanguage(cfg, sessionMgr, msg), key, filepath.Base(path)))
+						continue
+					}
+					otherMedia = append(otherMedia, prepared)
+					if img, err := loadImage(prepared); err != nil {
+						logger.Warn("read image failed", "path", prepared, "error", err)
+					} else {
+						images = append(images, img)
+					}
+				} else if isAudioFile(path) {
+					var progress func(done, total int)
+					if msg.StreamCallback != nil {
+						progress = func(done, total int) {
+							msg.StreamCallback(fmt.Sprintf("🎤 _Transcribing voice note... %d/%d_", done, total))
+						}
+					}
+					transcript, err := transcribeVoice(ctx, path, cfg.Media, progress)
 					if err != nil {
 						logger.Warn("voice transcription failed", "path", path, "error", err)
 						otherMedia = append(otherMedia, path)
@@ -433,30 +585,34 @@ func runDaemon() {
 		userMsg := llm.Message{
 			Role:    "user",
 			Content: content,
+			Images:  images,
 		}
 		messages = append(messages, userMsg)
 
+		// Screen the message before it reaches the LLM
+		if moderator.blocks(ctx, msg, "input", content) {
+			return moderator.reply(userLanguage(cfg, sessionMgr, msg)), nil
+		}
+
 		// Prepend welcome message for first-time users
 		welcomePrefix := ""
 		if isFirst {
-			welcomePrefix = "👋 *Welcome!* This is our first conversation.\nType /help to see all features.\n\n"
+			welcomePrefix = i18n.T(userLanguage(cfg, sessionMgr, msg), "welcome.first")
+		}
+		welcomePrefix += resetNotice
+		if len(mediaNotes) > 0 {
+			welcomePrefix += strings.Join(mediaNotes, "\n") + "\n\n"
 		}
 
 		// Build system prompt from active persona + platform formatting rules
 		var systemPromptOverride string
-		if len(cfg.Personas.List) > 0 {
-			personaName, _ := sessionMgr.GetContext(sess, "persona").(string)
-			persona := cfg.GetPersona(personaName)
-			if persona == nil {
-				persona = cfg.GetDefaultPersona()
-			}
-			if persona != nil {
-				systemPromptOverride = llm.BuildSystemPrompt(persona.SystemPrompt, msg.Platform)
-			}
+		persona := activePersona(cfg, sessionMgr, sess)
+		if persona != nil {
+			systemPromptOverride = llm.BuildSystemPrompt(prompts.expand(persona, msg.UserID, msg.Platform), msg.Platform)
 		}
 		if systemPromptOverride == "" {
 			// No personas configured — use llm.system_prompt with platform-aware formatting
-			systemPromptOverride = llm.BuildSystemPrompt(cfg.LLM.SystemPrompt, msg.Platform)
+			systemPromptOverride = llm.BuildSystemPrompt(prompts.expand(nil, msg.UserID, msg.Platform), msg.Platform)
 		}
 
 		// Inject skill prompts into system prompt
@@ -467,39 +623,83 @@ func runDaemon() {
 			systemPromptOverride += "\n\n" + matchedPrompts
 		}
 
-		// For voice input: suppress text streaming — we'll send a voice reply at the end
-		if isVoiceMsg {
+		// Add the user's relevant memories; the answer cites them by ID
+		var memorySources []memory.Source
+		if cfg.Memory.Enabled {
+			var memContext string
+			memContext, memorySources = memoryHandler.Recall(ctx, msg.UserID, content, memoryContextLimit(cfg))
+			if memContext != "" {
+				systemPromptOverride += "\n\n" + memContext + memory.CitationInstruction
+			}
+		}
+
+		// For voice input: suppress text streaming — we'll send a voice reply at the end.
+		// Answers to be screened aren't streamed either.
+		if isVoiceMsg || moderator.screensOutput() {
 			msg.StreamCallback = func(string) {}
 		}
 
 		// Send to LLM (streaming)
-		ch, err := llmRouter.StreamChat(ctx, msg.UserID, messages, systemPromptOverride)
+		sessionModel := sessionModelOverride(sessionMgr, sess, llmRouter.MainProvider())
+		req := &llm.Request{
+			UserID:       msg.UserID,
+			Messages:     messages,
+			SystemPrompt: systemPromptOverride,
+			Model:        sessionModel,
+		}
+		resolveParams(cfg, req, messageProfile(skillsMgr, persona, msg.Text), sessionTemperature(sessionMgr, sess))
+		resolveReasoning(cfg, req, llmRouter.MainProvider(), sessionThink(sessionMgr, sess))
+		useTools(toolMgr, req)
+		ch, err := llmRouter.StreamRequest(ctx, req)
 		if err != nil {
-			return llm.FormatError(err), nil
+			return llmErrorReply(cfg, llmRouter, msg.Text, err), nil
 		}
 
 		var respContent string
+		var usage *llm.StreamUsage
+		var truncated bool
 		{
 			var content strings.Builder
 			var thinking bool
-			for chunk := range ch {
-				if chunk.Error != nil {
-					if content.Len() == 0 {
-						return llm.FormatError(chunk.Error), nil
+			for continuations := 0; ; continuations++ {
+				for chunk := range ch {
+					if chunk.Error != nil {
+						if content.Len() == 0 {
+							return llmErrorReply(cfg, llmRouter, msg.Text, chunk.Error), nil
+						}
+						break // keep partial response
 					}
-					break // keep partial response
-				}
-				// Show thinking indicator while AI reasons
-				if chunk.Thinking != "" && content.Len() == 0 {
-					if !thinking {
-						thinking = true
-						msg.StreamCallback(welcomePrefix + "🤔 _Thinking..._")
+					if chunk.Usage != nil {
+						usage = addUsage(usage, chunk.Usage)
 					}
-					continue
+					// Show thinking indicator while AI reasons
+					if chunk.Thinking != "" && content.Len() == 0 {
+						if !thinking {
+							thinking = true
+							msg.StreamCallback(welcomePrefix + "🤔 _Thinking..._")
+						}
+						continue
+					}
+					content.WriteString(chunk.Content)
+					if content.Len() > 0 {
+						msg.StreamCallback(welcomePrefix + content.String())
+					}
+				}
+
+				// An answer cut off at max_tokens is continued up to
+				// llm.max_continuations times, then marked as truncated
+				if req.FinishReason != llm.FinishLength || content.Len() == 0 {
+					break
 				}
-				content.WriteString(chunk.Content)
-				if content.Len() > 0 {
-					msg.StreamCallback(welcomePrefix + content.String())
+				if continuations >= cfg.LLM.MaxContinuations {
+					truncated = true
+					break
+				}
+				req = req.Continuation(content.String())
+				if ch, err = llmRouter.StreamRequest(ctx, req); err != nil {
+					logger.Warn("continue truncated answer failed", "error", err)
+					truncated = true
+					break
 				}
 			}
 			respContent = content.String()
@@ -508,13 +708,33 @@ func runDaemon() {
 		if respContent == "" {
 			return "", nil
 		}
+		if moderator.screensOutput() && moderator.blocks(ctx, msg, "output", respContent) {
+			return moderator.reply(userLanguage(cfg, sessionMgr, msg)), nil
+		}
+		truncationNote := ""
+		if truncated {
+			truncationNote = "\n\n" + i18n.T(userLanguage(cfg, sessionMgr, msg), "llm.truncated")
+		}
+
+		// Keep what the answer cited for /sources
+		cited := memory.Cited(respContent, memorySources)
+		if cfg.Memory.Enabled {
+			sessionMgr.SetContext(sess, "sources", cited)
+		}
 
 		// Record messages in session (use resolved content, which includes transcription if voice)
 		sessionMgr.AddMessage(sess, "user", userMsg.Content)
-		sessionMgr.AddMessage(sess, "assistant", respContent)
+		// The provider and model that answered, after routing and fallback
+		servedProvider, servedModel := llmRouter.Served(req)
+		reply := session.Message{Role: "assistant", Content: respContent, Model: servedModel}
+		if usage != nil {
+			reply.InputTokens, reply.OutputTokens = usage.InputTokens, usage.OutputTokens
+			msg.InputTokens, msg.OutputTokens = usage.InputTokens, usage.OutputTokens
+		}
+		sessionMgr.AppendMessage(sess, reply)
 
 		// Persist conversation to database
-		sessionKey := fmt.Sprintf("%s:%s", msg.Platform, msg.ChatID)
+		sessionKey := sess.ID
 		now := time.Now()
 		if err := store.SaveConversationMessage(sessionKey, "user", userMsg.Content, now); err != nil {
 			logger.Warn("save conversation message failed", "error", err, "role", "user")
@@ -542,8 +762,11 @@ func runDaemon() {
 			}
 		}
 
-		return welcomePrefix + respContent, nil
-	})
+		// Let the router attach feedback reactions to the answer
+		msg.Provider, msg.Model = servedProvider, servedModel
+		return welcomePrefix + withSources(respContent+truncationNote, cited), nil
+	}
+	rtr.SetHandler(handle)
 
 	// Register platforms
 	ctx, cancel := context.WithCancel(context.Background())
@@ -553,6 +776,7 @@ func runDaemon() {
 		tg, err := telegram.New(&telegram.Config{
 			Token:        cfg.Platforms.Telegram.BotToken,
 			DownloadsDir: filepath.Join(cfg.GetPlatformDir("telegram"), "downloads"),
+			MaxLen:       cfg.Platforms.Telegram.MaxMessageLength,
 			Logger:       logger.With("platform", "telegram"),
 		})
 		if err != nil {
@@ -563,11 +787,22 @@ func runDaemon() {
 	}
 
 	if cfg.Platforms.Slack != nil && cfg.Platforms.Slack.Enabled {
-		sl, err := slack.New(&slack.Config{
+		slackCfg := &slack.Config{
 			BotToken: cfg.Platforms.Slack.BotToken,
 			AppToken: cfg.Platforms.Slack.AppToken,
+			MaxLen:   cfg.Platforms.Slack.MaxMessageLength,
 			Logger:   logger.With("platform", "slack"),
-		})
+		}
+		if cfg.Platforms.Slack.UseWebhook {
+			slackCfg.Port = cfg.Platforms.Slack.WebhookPort
+			if slackCfg.Port == 0 {
+				slackCfg.Port = 3000
+			}
+			slackCfg.Bind = cfg.Platforms.Slack.WebhookBind
+			slackCfg.EventsPath = cfg.Platforms.Slack.WebhookPath
+			slackCfg.SigningSecret = cfg.Platforms.Slack.SigningSecret
+		}
+		sl, err := slack.New(slackCfg)
 		if err != nil {
 			logger.Error("init slack failed", "error", err)
 		} else {
@@ -578,7 +813,9 @@ func runDaemon() {
 	if cfg.Platforms.WhatsApp != nil && cfg.Platforms.WhatsApp.Enabled {
 		wa, err := whatsapp.New(&whatsapp.Config{
 			DataDir:      cfg.GetPlatformDir("whatsapp"),
+			DBPath:       cfg.Platforms.WhatsApp.DBPath,
 			DownloadsDir: filepath.Join(cfg.GetPlatformDir("whatsapp"), "downloads"),
+			MaxLen:       cfg.Platforms.WhatsApp.MaxMessageLength,
 			Logger:       logger.With("platform", "whatsapp"),
 			OnPairFailure: func() {
 				cfg.Platforms.WhatsApp.Enabled = false
@@ -588,6 +825,20 @@ func runDaemon() {
 					logger.Warn("whatsapp disabled in config — run 'magabot setup platform' to re-enable")
 				}
 			},
+			Reconnect: whatsapp.ReconnectConfig{
+				InitialDelay: cfg.Platforms.WhatsApp.Reconnect.InitialDelay.Duration(),
+				MaxDelay:     cfg.Platforms.WhatsApp.Reconnect.MaxDelay.Duration(),
+				NotifyAfter:  cfg.Platforms.WhatsApp.Reconnect.NotifyAfter,
+			},
+			OnReconnectFailing: func(attempts int, err error) {
+				notifyAdmins(rtr, cfg, "whatsapp", fmt.Sprintf("⚠️ WhatsApp is disconnected and %d reconnect attempts failed (%v). Still retrying.", attempts, err), logger)
+			},
+			OnReconnected: func(attempts int, downtime time.Duration) {
+				notifyAdmins(rtr, cfg, "whatsapp", fmt.Sprintf("✅ WhatsApp reconnected after %s (%d attempts).", formatDuration(downtime), attempts), logger)
+			},
+			OnLoggedOut: func() {
+				notifyAdmins(rtr, cfg, "whatsapp", "⚠️ WhatsApp logged this device out. Run 'magabot setup platform' to pair it again.", logger)
+			},
 		})
 		if err != nil {
 			logger.Error("init whatsapp failed", "error", err)
@@ -596,6 +847,7 @@ func runDaemon() {
 		}
 	}
 
+	var webhookServer *webhook.Server
 	if cfg.Platforms.Webhook != nil && cfg.Platforms.Webhook.Enabled {
 		wh, err := webhook.New(&webhook.Config{
 			Port:         cfg.Platforms.Webhook.Port,
@@ -604,29 +856,63 @@ func runDaemon() {
 			AuthMethod:   cfg.Platforms.Webhook.AuthMethod,
 			BearerToken:  cfg.Platforms.Webhook.BearerToken,
 			BearerTokens: cfg.Platforms.Webhook.BearerTokens,
+			BasicUser:    cfg.Platforms.Webhook.BasicUser,
+			BasicPass:    cfg.Platforms.Webhook.BasicPass,
 			HMACSecret:   cfg.Platforms.Webhook.HMACSecret,
 			HMACUsers:    cfg.Platforms.Webhook.HMACUsers,
 			AllowedIPs:   cfg.Platforms.Webhook.AllowedIPs,
 			AllowedUsers: cfg.Platforms.Webhook.AllowedUsers,
+			Sources:      webhookSources(cfg.Platforms.Webhook.Sources),
+			Transform:    cfg.Platforms.Webhook.Transform,
+			Routes:       webhookRoutes(cfg.Platforms.Webhook.Routes),
+			CronJobs:     cfg.Platforms.Webhook.CronJobs,
+			Ready:        readinessCheck(rtr, llmRouter),
 			Logger:       logger.With("platform", "webhook"),
+
+			MaxBodySize:        cfg.Platforms.Webhook.MaxBodySize,
+			RequireContentType: cfg.Platforms.Webhook.RequireContentType,
+			SecurityProfile:    cfg.Platforms.Webhook.SecurityProfile,
+			HMACAlgorithms:     cfg.Platforms.Webhook.HMACAlgorithms,
+			Ed25519PublicKey:   cfg.Platforms.Webhook.Ed25519PublicKey,
+
+			DebugRequests:  cfg.Platforms.Webhook.DebugRequests,
+			DebugBodyBytes: cfg.Platforms.Webhook.DebugBodyBytes,
+			DebugAdmins:    cfg.Platforms.Webhook.Admins,
+
+			RateLimitPerIP:   cfg.Platforms.Webhook.RateLimitPerIP,
+			RateLimitPerUser: cfg.Platforms.Webhook.RateLimitPerUser,
+			RateLimitWindow:  cfg.Platforms.Webhook.RateLimitWindow.Duration(),
+			Alerts:           webhookAlerts(cfg, rtr, logger),
 		})
 		if err != nil {
 			logger.Error("init webhook failed", "error", err)
 		} else {
 			rtr.Register(wh)
+			webhookServer = wh
 		}
 	}
 
-	// Start download cleanup goroutine
-	if cfg.Media.RetentionDays > 0 {
-		downloadDirs := []string{
-			filepath.Join(cfg.GetPlatformDir("telegram"), "downloads"),
-			filepath.Join(cfg.GetPlatformDir("whatsapp"), "downloads"),
+	// Run scheduled jobs (cron.enabled); llm_digest jobs go through the LLM
+	if scheduler := startCronScheduler(cfg, rtr, llmRouter, memoryHandler, prompts, logger); scheduler != nil {
+		defer scheduler.Stop()
+		if webhookServer != nil {
+			webhookServer.SetCronRunner(webhookCronRunner(scheduler))
 		}
-		retention := time.Duration(cfg.Media.RetentionDays) * 24 * time.Hour
+	}
+
+	// Periodic checks (heartbeat.enabled), alerting through quiet hours
+	if hb := startHeartbeat(cfg, rtr, logger); hb != nil {
+		defer hb.Stop()
+	}
+
+	// Periodically probe LLM providers so /status reflects revoked keys or down endpoints
+	llmRouter.StartHealthProbe(ctx, cfg.LLM.HealthCheckInterval.Duration())
+
+	// Start download cleanup goroutine (swept daily, or every retention period if shorter)
+	if retention := cfg.DownloadsRetention(); retention > 0 {
 		go func() {
 			cleanOldDownloads(downloadDirs, retention, logger)
-			ticker := time.NewTicker(24 * time.Hour)
+			ticker := time.NewTicker(min(retention, 24*time.Hour))
 			defer ticker.Stop()
 			for {
 				select {
@@ -639,11 +925,40 @@ func runDaemon() {
 		}()
 	}
 
+	// Prune data older than storage retention (history_retention, retention.*)
+	startPruneJob(ctx, cfg, store, logger)
+
+	// Keep per-model latency and error stats across restarts
+	flushModelStats := startModelStatsJob(ctx, llmRouter, store, logger)
+
+	// Drop sessions idle longer than session.cleanup_age from memory
+	if idle := cfg.Session.CleanupAge.Duration(); idle > 0 {
+		go func() {
+			ticker := time.NewTicker(min(idle, time.Hour))
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if n := sessionMgr.EvictIdle(idle); n > 0 {
+						logger.Debug("evicted idle sessions", "count", n)
+					}
+					sessionMgr.Clear(idle)
+				}
+			}
+		}()
+	}
+
 	// Start router
 	if err := rtr.Start(ctx); err != nil {
 		logger.Error("start router failed", "error", err)
 		os.Exit(1)
 	}
+	if webhookServer != nil {
+		// Every platform is up, so /health/ready can let traffic in
+		webhookServer.SetReady(true)
+	}
 
 	logger.Info("magabot started",
 		"version", version.Short(),
@@ -667,7 +982,11 @@ func runDaemon() {
 	for {
 		sig := <-sigCh
 
-		if handleReloadSignal(sig, rtr, logger) {
+		if handleReloadSignal(sig, rtr, logger, logFile) {
+			continue
+		}
+		if isTokenReloadSignal(sig) {
+			reloadWebhookTokens(webhookServer, logger)
 			continue
 		}
 
@@ -680,6 +999,11 @@ func runDaemon() {
 		skillsWatcher.Stop()
 	}
 	agentMgr.Stop()
+	if pluginMgr != nil {
+		if err := pluginMgr.StopAll(); err != nil {
+			logger.Warn("stop plugins failed", "error", err)
+		}
+	}
 
 	// Fire on_stop hooks (synchronous, give hooks a chance to run)
 	hooksMgr.Fire(hooks.OnStop, &hooks.EventData{
@@ -687,16 +1011,22 @@ func runDaemon() {
 		Platforms: rtr.Platforms(),
 	})
 
-	done := make(chan struct{})
+	// Let in-flight messages finish; new ones are rejected meanwhile
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), router.DefaultDrainTimeout)
+	done := make(chan int, 1)
 	go func() {
-		rtr.Stop()
-		close(done)
+		done <- rtr.Shutdown(drainCtx)
 	}()
 	select {
-	case <-done:
-	case <-time.After(10 * time.Second):
-		logger.Warn("shutdown timed out after 10s")
+	case active := <-done:
+		if active > 0 {
+			logger.Warn("shutdown drain timed out", "timeout", router.DefaultDrainTimeout, "active", active)
+		}
+	case <-time.After(router.DefaultDrainTimeout + 2*time.Second):
+		logger.Warn("shutdown timed out stopping platforms", "active", rtr.InFlight())
 	}
+	cancelDrain()
+	flushModelStats()
 
 	if cfg.Storage.Backup.Enabled {
 		if info, err := backupMgr.Create(dataDir, rtr.Platforms()); err == nil {
@@ -704,12 +1034,288 @@ func runDaemon() {
 		}
 	}
 
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(tracingCtx); err != nil {
+		logger.Warn("flush traces failed", "error", err)
+	}
+	cancelTracing()
+
 	logger.Info("magabot stopped")
 }
 
+// newMemoryEmbedder builds the embedding client for semantic memory, or
+// returns nil (keyword search) when none is configured or it is invalid.
+func newMemoryEmbedder(cfg *config.Config, logger *slog.Logger) *embedding.Client {
+	ec := cfg.MemoryEmbedding()
+	if ec == nil {
+		return nil
+	}
+	ecfg := embedding.Config{
+		Provider:          embedding.Provider(ec.Provider),
+		APIKey:            ec.APIKey,
+		Model:             ec.Model,
+		BaseURL:           ec.BaseURL,
+		Dimensions:        ec.Dimensions,
+		Timeout:           ec.Timeout.Duration(),
+		Proxy:             ec.Proxy,
+		MaxBatchSize:      ec.MaxBatchSize,
+		RequestsPerMinute: ec.RequestsPerMinute,
+		MaxRetries:        derefInt(ec.MaxRetries),
+		Logger:            logger.With("component", "embedding"),
+	}
+	if err := embedding.ValidateConfig(ecfg); err != nil {
+		logger.Warn("memory embedding disabled, using keyword search", "provider", ec.Provider, "error", err)
+		return nil
+	}
+	logger.Info("semantic memory enabled", "provider", ec.Provider, "model", ec.Model)
+	return embedding.NewClient(ecfg)
+}
+
+// notifyAdmins sends message to the admins of every connected platform
+// except from, which is usually the one the alert is about. Webhooks have
+// no one to send to.
+func notifyAdmins(rtr *router.Router, cfg *config.Config, from, message string, logger *slog.Logger) {
+	sent := 0
+	for name, ok := range rtr.PlatformStatus() {
+		if name == from || name == "webhook" || !ok {
+			continue
+		}
+		for _, admin := range cfg.PlatformAdmins(name) {
+			if err := rtr.Send(name, admin, message); err != nil {
+				logger.Warn("admin notification failed", "platform", name, "error", err)
+				if !errors.Is(err, router.ErrSendQueued) {
+					continue
+				}
+			}
+			sent++
+		}
+	}
+	if sent == 0 {
+		logger.Warn("no admin on another platform to notify", "about", from)
+	}
+}
+
+// resetConversation clears sess's history in memory and in the database.
+// Per-chat settings such as the persona are kept.
+func resetConversation(sessionMgr *session.Manager, store *storage.Store, sess *session.Session) error {
+	sessionMgr.ClearMessages(sess)
+	return store.ClearConversationHistory(sess.ID)
+}
+
+// notifyNewUser tells msg's platform admins that a user without access
+// wrote, with the command that allows them.
+func notifyNewUser(rtr *router.Router, cfg *config.Config, msg *router.Message, logger *slog.Logger) {
+	who := msg.UserID
+	if msg.Username != "" {
+		who = fmt.Sprintf("%s (%s)", msg.Username, msg.UserID)
+	}
+	text := fmt.Sprintf("👋 New user on %s without access: %s\n\nAllow them with:\n%sallow %s",
+		msg.Platform, who, cfg.CommandPrefix(msg.Platform), msg.UserID)
+	for _, admin := range cfg.PlatformAdmins(msg.Platform) {
+		if err := rtr.Send(msg.Platform, admin, text); err != nil {
+			logger.Warn("new user notification failed", "platform", msg.Platform, "error", err)
+		}
+	}
+}
+
+// readinessCheck reports the daemon as ready once every registered platform
+// is connected and at least one LLM provider is available.
+func readinessCheck(rtr *router.Router, llmRouter *llm.Router) func() error {
+	return func() error {
+		if down := rtr.DisconnectedPlatforms(); len(down) > 0 {
+			return fmt.Errorf("platforms not connected: %s", strings.Join(down, ", "))
+		}
+		if !llmRouter.Available() {
+			return errors.New("no LLM provider available")
+		}
+		return nil
+	}
+}
+
+// reloadWebhookTokens re-reads the config file and applies its webhook
+// bearer tokens and allowlist to the running server.
+func reloadWebhookTokens(wh *webhook.Server, logger *slog.Logger) {
+	if wh == nil {
+		logger.Warn("webhook token reload requested but the webhook is not running")
+		return
+	}
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		logger.Error("webhook token reload failed", "error", err)
+		return
+	}
+	if cfg.Platforms.Webhook == nil {
+		logger.Warn("webhook token reload skipped: webhook config removed")
+		return
+	}
+	wh.SetBearerTokens(cfg.Platforms.Webhook.BearerTokens, cfg.Platforms.Webhook.AllowedUsers)
+}
+
+// webhookSources converts configured webhook payload schemas.
+func webhookSources(cfgs []config.WebhookSourceConfig) []webhook.SourceConfig {
+	sources := make([]webhook.SourceConfig, len(cfgs))
+	for i, c := range cfgs {
+		sources[i] = webhook.SourceConfig{
+			Name:     c.Name,
+			Header:   c.Header,
+			Field:    c.Field,
+			Match:    c.Match,
+			TextPath: c.TextPath,
+			UserPath: c.UserPath,
+
+			MaxBodySize: c.MaxBodySize,
+		}
+	}
+	return sources
+}
+
+// webhookRoutes converts config webhook routes to webhook.Route.
+func webhookRoutes(cfgs []config.WebhookRouteConfig) []webhook.Route {
+	routes := make([]webhook.Route, len(cfgs))
+	for i, c := range cfgs {
+		routes[i] = webhook.Route{
+			Path:         c.Path,
+			AuthMethod:   c.AuthMethod,
+			BearerToken:  c.BearerToken,
+			BearerTokens: c.BearerTokens,
+			BasicUser:    c.BasicUser,
+			BasicPass:    c.BasicPass,
+			HMACSecret:   c.HMACSecret,
+			HMACUsers:    c.HMACUsers,
+			AllowedIPs:   c.AllowedIPs,
+			AllowedUsers: c.AllowedUsers,
+			Sources:      webhookSources(c.Sources),
+			Transform:    c.Transform,
+
+			HMACAlgorithms:   c.HMACAlgorithms,
+			Ed25519PublicKey: c.Ed25519PublicKey,
+		}
+	}
+	return routes
+}
+
+// loadTokenizers loads the llm.tokenizers encoding files. Models whose file
+// fails to load fall back to estimated token counts.
+func loadTokenizers(files map[string]string, logger *slog.Logger) map[string]llm.Tokenizer {
+	if len(files) == 0 {
+		return nil
+	}
+	tokenizers := make(map[string]llm.Tokenizer, len(files))
+	for prefix, path := range files {
+		tok, err := llm.LoadTokenizer(path)
+		if err != nil {
+			logger.Warn("tokenizer not loaded, estimating tokens", "models", prefix, "error", err)
+			continue
+		}
+		tokenizers[prefix] = tok
+	}
+	return tokenizers
+}
+
+// setupLLMRouting routes messages to providers by llm.routing_rules. Rules
+// naming a provider that is not registered, and class rules without a
+// routing_classifier, are skipped with a warning.
+func setupLLMRouting(llmRouter *llm.Router, cfg *config.Config, logger *slog.Logger) {
+	if len(cfg.LLM.RoutingRules) == 0 {
+		return
+	}
+	registered := make(map[string]bool)
+	for _, name := range llmRouter.Providers() {
+		registered[name] = true
+	}
+	classifier := cfg.LLM.RoutingClassifier
+	if classifier != "" && !registered[classifier] {
+		logger.Warn("routing classifier not registered, skipping class rules", "provider", classifier)
+		classifier = ""
+	}
+
+	var rules []llm.RoutingRule
+	var labels []string
+	for i, rc := range cfg.LLM.RoutingRules {
+		name := rc.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if rc.Provider != "" && !registered[rc.Provider] {
+			logger.Warn("routing rule skipped: provider not registered", "rule", name, "provider", rc.Provider)
+			continue
+		}
+		if rc.Class != "" {
+			if classifier == "" {
+				logger.Warn("routing rule skipped: class needs llm.routing_classifier", "rule", name)
+				continue
+			}
+			if !slices.Contains(labels, rc.Class) {
+				labels = append(labels, rc.Class)
+			}
+		}
+		rules = append(rules, llm.RoutingRule{
+			Name: name, Provider: rc.Provider, Model: rc.Model,
+			Keywords: rc.Keywords, MinLength: rc.MinLength, MaxLength: rc.MaxLength,
+			Code: rc.Code, Class: rc.Class,
+		})
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	var classify func(ctx context.Context, text string) string
+	if len(labels) > 0 {
+		classify = func(ctx context.Context, text string) string {
+			label, err := llmRouter.Classify(ctx, classifier, labels, text)
+			if err != nil {
+				logger.Warn("message classification failed", "provider", classifier, "error", err)
+			}
+			return label
+		}
+	}
+	llmRouter.SetRouteFunc(llm.RuleRoute(rules, classify))
+	logger.Info("llm routing rules loaded", "rules", len(rules))
+}
+
+// webhookAlerts returns the webhook's admin alert settings. Alerts go to
+// the configured "platform:chat_id" chats, or else to the admins of the
+// other platforms.
+func webhookAlerts(cfg *config.Config, rtr *router.Router, logger *slog.Logger) webhook.AlertConfig {
+	ac := cfg.Platforms.Webhook.Alerts
+	if ac == nil || !ac.Enabled {
+		return webhook.AlertConfig{}
+	}
+	type target struct{ platform, chatID string }
+	var targets []target
+	for _, chat := range ac.Chats {
+		name, chatID, ok := strings.Cut(chat, ":")
+		if !ok || name == "" || chatID == "" {
+			logger.Warn("ignoring webhook alert chat, want platform:chat_id", "chat", chat)
+			continue
+		}
+		targets = append(targets, target{name, chatID})
+	}
+
+	notify := func(text string) { notifyAdmins(rtr, cfg, "webhook", text, logger) }
+	if len(targets) > 0 {
+		notify = func(text string) {
+			for _, t := range targets {
+				if err := rtr.Send(t.platform, t.chatID, text); err != nil {
+					logger.Warn("webhook alert failed", "platform", t.platform, "error", err)
+				}
+			}
+		}
+	}
+	return webhook.AlertConfig{
+		Notify:       notify,
+		AuthFailures: ac.AuthFailures,
+		RateLimited:  ac.RateLimited,
+		Window:       ac.Window.Duration(),
+		Cooldown:     ac.Cooldown.Duration(),
+	}
+}
+
 // cleanOldDownloads deletes files in dirs that are older than maxAge.
 func cleanOldDownloads(dirs []string, maxAge time.Duration, logger *slog.Logger) {
 	cutoff := time.Now().Add(-maxAge)
+	var files int
+	var reclaimed int64
 	for _, dir := range dirs {
 		entries, err := os.ReadDir(dir)
 		if err != nil {
@@ -726,11 +1332,52 @@ func cleanOldDownloads(dirs []string, maxAge time.Duration, logger *slog.Logger)
 			if info.ModTime().Before(cutoff) {
 				path := filepath.Join(dir, entry.Name())
 				if err := os.Remove(path); err == nil {
+					files++
+					reclaimed += info.Size()
 					logger.Debug("removed old download", "path", path)
 				}
 			}
 		}
 	}
+	if files > 0 {
+		logger.Info("cleaned old downloads", "files", files, "bytes", reclaimed)
+	}
+}
+
+// removeDownloads deletes the media files of a handled message, with any
+// downscaled copies. Paths outside the download dirs are left alone.
+func removeDownloads(paths, dirs []string, logger *slog.Logger) {
+	var files int
+	var reclaimed int64
+	for _, path := range paths {
+		if !inDownloadDir(path, dirs) {
+			continue
+		}
+		for _, p := range []string{path, scaledImagePath(path)} {
+			info, err := os.Stat(p)
+			if err != nil {
+				continue
+			}
+			if err := os.Remove(p); err == nil {
+				files++
+				reclaimed += info.Size()
+			}
+		}
+	}
+	if files > 0 {
+		logger.Debug("removed handled downloads", "files", files, "bytes", reclaimed)
+	}
+}
+
+// inDownloadDir reports whether path is directly inside one of dirs.
+func inDownloadDir(path string, dirs []string) bool {
+	parent := filepath.Dir(filepath.Clean(path))
+	for _, dir := range dirs {
+		if dir != "" && parent == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
 }
 
 // localScriptPath returns the full path to a script in ~/.local/bin/.
@@ -752,9 +1399,10 @@ func speakText(text string) ([]byte, error) {
 	return out, nil
 }
 
-// transcribeAudioFile runs the local whisper script on an audio file.
-func transcribeAudioFile(path string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+// transcribeAudioFile runs the local whisper script on an audio file of
+// the given length (0 if unknown), giving up after transcribeTimeout.
+func transcribeAudioFile(ctx context.Context, path string, length time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, transcribeTimeout(length))
 	defer cancel()
 	out, err := exec.CommandContext(ctx, localScriptPath("transcribe-voice"), path).Output()
 	if err != nil {
@@ -845,18 +1493,114 @@ func formatDuration(d time.Duration) string {
 	hours := int(d.Hours()) % 24
 	minutes := int(d.Minutes()) % 60
 
-	switch {
-	case days > 0:
-		return fmt.Sprintf("%dd %dh", days, hours)
-	case hours > 0:
-		return fmt.Sprintf("%dh %dm", hours, minutes)
-	default:
-		return fmt.Sprintf("%dm", minutes)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// userLanguage picks the response language for msg: the user's /lang
+// choice, then the client's locale, then bot.language.
+func userLanguage(cfg *config.Config, sessionMgr *session.Manager, msg *router.Message) string {
+	if lang, _ := sessionMgr.GetUserContext(msg.Platform, msg.UserID, "lang").(string); lang != "" {
+		return lang
+	}
+	if lang, ok := i18n.Match(msg.Locale); ok {
+		return lang
+	}
+	if lang, ok := i18n.Match(cfg.Bot.Language); ok {
+		return lang
+	}
+	return i18n.Default
+}
+
+// helpItemNumber matches the "12. " that numbers a help line.
+var helpItemNumber = regexp.MustCompile(`^\s*\d+\. `)
+
+// hideDisabledCommands drops the lines of a help text that introduce a
+// command in commands.disabled, renumbering the numbered ones, and section
+// headings left without lines.
+func hideDisabledCommands(text string, cfg *config.Config) string {
+	if len(cfg.Commands.Disabled) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	n := 0
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		disabled := false
+		for i := 0; i < len(fields) && i < 3; i++ {
+			if strings.HasPrefix(fields[i], "/") || strings.HasPrefix(fields[i], ":") {
+				disabled = cfg.CommandDisabled(fields[i])
+				break
+			}
+		}
+		if disabled {
+			continue
+		}
+		if loc := helpItemNumber.FindStringIndex(line); loc != nil {
+			n++
+			line = fmt.Sprintf("%*d. ", loc[1]-2, n) + line[loc[1]:]
+		}
+		kept = append(kept, line)
+	}
+	out := kept[:0]
+	for i, line := range kept {
+		if strings.HasSuffix(line, ":") && (i+1 == len(kept) || strings.TrimSpace(kept[i+1]) == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.TrimRight(strings.Join(out, "\n"), "\n")
+}
+
+// handleLangCommand shows or sets the user's response language.
+func handleLangCommand(args []string, lang string, sessionMgr *session.Manager, msg *router.Message) string {
+	available := strings.Join(i18n.Languages(), ", ")
+	if len(args) == 0 {
+		return i18n.T(lang, "lang.current", lang, available)
+	}
+	code, ok := i18n.Match(args[0])
+	if !ok {
+		return i18n.T(lang, "lang.unsupported", args[0], available)
+	}
+	sessionMgr.SetUserContext(msg.Platform, msg.UserID, "lang", code)
+	return i18n.T(code, "lang.set", code)
+}
+
+// addUsage adds u to the usage of an answer streamed in several parts.
+func addUsage(total, u *llm.StreamUsage) *llm.StreamUsage {
+	if total == nil {
+		return &llm.StreamUsage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens}
+	}
+	total.InputTokens += u.InputTokens
+	total.OutputTokens += u.OutputTokens
+	return total
+}
+
+// normalizeCommand reports whether text starts with the command prefix and
+// rewrites it to the "/" form handleCommand and skill triggers match on.
+func normalizeCommand(prefix, text string) (string, bool) {
+	if prefix == "" || !strings.HasPrefix(text, prefix) {
+		return text, false
 	}
+	return "/" + strings.TrimPrefix(text, prefix), true
 }
 
+// confirmButtons are offered with confirmation prompts; presses arrive as
+// /yes and /no.
+var confirmButtons = [][]router.Button{{
+	{Text: "✅ Yes", Data: "/yes"},
+	{Text: "❌ No", Data: "/no"},
+}}
+
 // handleCommand handles bot commands
-func handleCommand(msg *router.Message, llmRouter *llm.Router, store *storage.Store, cfg *config.Config, adminH *bot.AdminHandler, memoryH *bot.MemoryHandler, sessionH *bot.SessionHandler, sessionMgr *session.Manager, confirmMgr *bot.ConfirmationManager, logger *slog.Logger) (string, error) {
+func handleCommand(msg *router.Message, rtr *router.Router, llmRouter *llm.Router, store *storage.Store, cfg *config.Config, adminH *bot.AdminHandler, memoryH *bot.MemoryHandler, sessionH *bot.SessionHandler, sessionMgr *session.Manager, confirmMgr *bot.ConfirmationManager, logger *slog.Logger) (string, error) {
 	parts := strings.Fields(msg.Text)
 	if len(parts) == 0 {
 		return "", nil
@@ -868,140 +1612,168 @@ func handleCommand(msg *router.Message, llmRouter *llm.Router, store *storage.St
 		cmd = cmd[:i]
 	}
 	args := parts[1:]
+	lang := userLanguage(cfg, sessionMgr, msg)
+
+	if cfg.CommandDisabled(cmd) {
+		return i18n.T(lang, "command.disabled", cmd), nil
+	}
+	if !cfg.CanRunCommand(msg.Platform, msg.UserID, cmd) {
+		return i18n.T(lang, "command.denied", cmd), nil
+	}
 
 	switch cmd {
 	case "/yes", "/confirm":
 		if resp, handled := confirmMgr.Confirm(msg.Platform, msg.ChatID, msg.UserID); handled {
 			return resp, nil
 		}
-		return "No pending action to confirm.", nil
+		return i18n.T(lang, "confirm.none"), nil
 
 	case "/no", "/cancel":
 		if resp, handled := confirmMgr.Cancel(msg.Platform, msg.ChatID, msg.UserID); handled {
 			return resp, nil
 		}
-		return "No pending action to cancel.", nil
+		return i18n.T(lang, "cancel.none"), nil
 
 	case "/start":
-		welcome := `👋 *Hi! I'm Magabot* — your personal AI chatbot.
-
-💬 Send any message and I'll reply using AI.
-
-🎯 What I can do:
-1. 💬 Chat — ask anything, multi-turn conversation
-2. 📷 Image — send a photo, I'll analyze it (vision)
-3. 🎤 Voice — send a voice message, I'll transcribe & reply
-4. 📄 Document — send a PDF/file, I'll read & analyze it
-5. 🎨 Generate — ask me to create an image (DALL-E)
-6. 🔊 TTS — I can reply with voice messages
-7. 💭 Thinking — deep reasoning for complex questions
-
-⚡ /help — full help
-📊 /status — bot & provider status
-🔧 /config — bot configuration
-🧠 /memory — memory management`
-		return welcome, nil
+		return hideDisabledCommands(i18n.T(lang, "start"), cfg), nil
 
 	case "/help":
-		return `📖 *Magabot Help*
-
-Send any message and I'll reply using AI.
-
-💬 Commands:
- 1. /start — Welcome message
- 2. /status — Bot status
- 3. /model — Current model & switch
- 4. /llm — Switch LLM provider
- 5. /effort — Set effort level (low/medium/high/max)
- 6. /prompt — Custom system prompt
- 7. /persona — Switch AI persona
- 8. /fallback — Set fallback model
- 9. /budget — Budget limit per request
-10. /clear — Clear conversation history
-11. /help — This help
-
-🔧 Admin:
-12. /restart — Restart bot
-13. /config — Configuration
-14. /memory — Memory management
-15. /task — Background tasks
-
-🤖 Agent Sessions:
-• :new [agent] <dir> — Start coding agent
-• :quit — Close session
-• :status — Session info`, nil
+		return hideDisabledCommands(i18n.T(lang, "help"), cfg), nil
+
+	case "/lang":
+		return handleLangCommand(args, lang, sessionMgr, msg), nil
 
 	case "/status":
 		stats, err := store.Stats()
 		if err != nil {
-			return fmt.Sprintf("📊 *Status*\n\n⚠️ Error getting stats: %v", err), nil
+			return i18n.T(lang, "status.error", err), nil
 		}
 		llmStats := llmRouter.Stats()
 
 		var sb strings.Builder
-		sb.WriteString("📊 *Magabot Status*\n\n")
-		sb.WriteString("🖥️ System:\n")
+		line := func(key string, args ...any) {
+			sb.WriteString("  • " + i18n.T(lang, key, args...) + "\n")
+		}
+		section := func(key string) {
+			sb.WriteString("\n" + i18n.T(lang, key) + "\n")
+		}
+		sb.WriteString(i18n.T(lang, "status.title") + "\n\n")
+		sb.WriteString(i18n.T(lang, "status.system") + "\n")
 		sb.WriteString(fmt.Sprintf("  • OS: %s/%s\n", runtime.GOOS, runtime.GOARCH))
 		sb.WriteString(fmt.Sprintf("  • Magabot: v%s\n", version.Short()))
 		sb.WriteString(fmt.Sprintf("  • Go: %s\n", runtime.Version()))
 		sb.WriteString(fmt.Sprintf("  • PID: %d (PPID: %d)\n", os.Getpid(), os.Getppid()))
 
 		srv := util.GetServerStats()
-		sb.WriteString("\n💻 Server:\n")
-		sb.WriteString(fmt.Sprintf("  • CPU: load %.2f / %.2f / %.2f (1/5/15m)\n", srv.LoadAvg1, srv.LoadAvg5, srv.LoadAvg15))
+		section("status.server")
+		line("status.cpu", srv.LoadAvg1, srv.LoadAvg5, srv.LoadAvg15)
 		if srv.MemTotal > 0 {
 			memPct := float64(srv.MemUsed) / float64(srv.MemTotal) * 100
-			sb.WriteString(fmt.Sprintf("  • Memory: %s / %s (%.0f%%)\n", util.FormatBytes(srv.MemUsed), util.FormatBytes(srv.MemTotal), memPct))
+			line("status.memory", util.FormatBytes(srv.MemUsed), util.FormatBytes(srv.MemTotal), memPct)
 		}
 		if srv.DiskTotal > 0 {
 			diskPct := float64(srv.DiskUsed) / float64(srv.DiskTotal) * 100
-			sb.WriteString(fmt.Sprintf("  • Disk: %s / %s (%.0f%%)\n", util.FormatBytes(srv.DiskUsed), util.FormatBytes(srv.DiskTotal), diskPct))
+			line("status.disk", util.FormatBytes(srv.DiskUsed), util.FormatBytes(srv.DiskTotal), diskPct)
 		}
 		if srv.HasGPU {
 			gpuMemPct := float64(srv.GPUMemUsed) / float64(srv.GPUMemTotal) * 100
-			sb.WriteString(fmt.Sprintf("  • GPU: %s — %s / %s (%.0f%% mem, %d%% util)\n",
-				srv.GPUName, util.FormatBytes(srv.GPUMemUsed), util.FormatBytes(srv.GPUMemTotal), gpuMemPct, srv.GPUUtil))
+			line("status.gpu", srv.GPUName, util.FormatBytes(srv.GPUMemUsed), util.FormatBytes(srv.GPUMemTotal), gpuMemPct, srv.GPUUtil)
+		}
+
+		section("status.platforms")
+		states := rtr.PlatformStates()
+		for _, name := range sortedKeys(states) {
+			icon := "✅"
+			if states[name] != "connected" {
+				icon = "⚠️"
+			}
+			sb.WriteString(fmt.Sprintf("  • %s: %s %s\n", name, icon, states[name]))
 		}
 
 		sb.WriteString("\n🤖 LLM:\n")
-		sb.WriteString(fmt.Sprintf("  • Provider: %s\n", llmStats["main"]))
+		line("status.provider", llmStats["main"])
+		model := llmRouter.GetModel()
+		sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+		if m := sessionModelOverride(sessionMgr, sess, llmRouter.MainProvider()); m != "" {
+			model = i18n.T(lang, "status.this_chat_model", m)
+		}
+		if model != "" {
+			line("status.model", model)
+		}
+		if since, down := llmRouter.Outage(); down {
+			line("status.unavailable", since.Format("15:04:05"))
+		}
+		if inFlight, _ := llmStats["in_flight"].(map[string]int); inFlight[cfg.LLM.Main] > 0 {
+			if limit := cfg.LLM.MaxConcurrentPerProvider; limit > 0 {
+				line("status.in_flight_of", inFlight[cfg.LLM.Main], limit)
+			} else {
+				line("status.in_flight", inFlight[cfg.LLM.Main])
+			}
+		}
 		activeCfg := cfg.LLM.GetProviderConfig(cfg.LLM.Main)
 		if activeCfg != nil {
 			if activeCfg.Effort != "" {
-				sb.WriteString(fmt.Sprintf("  • Effort: %s\n", activeCfg.Effort))
+				line("status.effort", activeCfg.Effort)
 			}
 		}
 
 		if cli := llmRouter.CLIProvider(); cli != nil {
 			if fb := cli.FallbackModel(); fb != "" {
-				sb.WriteString(fmt.Sprintf("  • Fallback: %s\n", fb))
+				line("status.fallback", fb)
 			}
 			if budget := cli.MaxBudget(); budget > 0 {
-				sb.WriteString(fmt.Sprintf("  • Budget: $%.2f/req\n", budget))
+				line("status.budget", budget)
+			}
+		}
+
+		if health := llmRouter.CachedHealth(); len(health) > 0 {
+			for _, name := range sortedKeys(health) {
+				ph := health[name]
+				if ph.NoProbe && ph.CheckedAt.IsZero() {
+					continue // never probed and not used yet
+				}
+				if ph.OK {
+					line("status.health_ok", name, ph.Latency.Truncate(time.Millisecond), formatDuration(time.Since(ph.CheckedAt)))
+				} else {
+					line("status.health_down", name, formatDuration(time.Since(ph.CheckedAt)))
+				}
 			}
 		}
+		modelStats := llmRouter.ModelStats()
+		for _, key := range sortedKeys(modelStats) {
+			sb.WriteString("  • " + formatModelStat(lang, key, modelStats[key]) + "\n")
+		}
 
 		usage := llmRouter.Usage()
 		now := time.Now()
-		sb.WriteString(fmt.Sprintf("  • Hourly: %d reqs, %s tokens in / %s out (resets in %s)\n",
-			usage.HourlyCount, formatTokenCount(usage.HourlyTokenIn), formatTokenCount(usage.HourlyTokenOut),
-			formatDuration(usage.NextHourReset.Sub(now))))
-		sb.WriteString(fmt.Sprintf("  • Weekly: %d reqs, %s tokens in / %s out (resets in %s)\n",
-			usage.WeeklyCount, formatTokenCount(usage.WeeklyTokenIn), formatTokenCount(usage.WeeklyTokenOut),
-			formatDuration(usage.NextWeekReset.Sub(now))))
+		line("status.hourly", usage.HourlyCount, formatTokenCount(usage.HourlyTokenIn), formatTokenCount(usage.HourlyTokenOut),
+			formatDuration(usage.NextHourReset.Sub(now)))
+		line("status.weekly", usage.WeeklyCount, formatTokenCount(usage.WeeklyTokenIn), formatTokenCount(usage.WeeklyTokenOut),
+			formatDuration(usage.NextWeekReset.Sub(now)))
 		if usage.TotalTokenIn > 0 || usage.TotalTokenOut > 0 {
-			sb.WriteString(fmt.Sprintf("  • Total: %s tokens in / %s out\n",
-				formatTokenCount(usage.TotalTokenIn), formatTokenCount(usage.TotalTokenOut)))
+			line("status.total", formatTokenCount(usage.TotalTokenIn), formatTokenCount(usage.TotalTokenOut))
 		}
 
-		sb.WriteString("\n📡 Platforms:\n")
+		section("status.platforms")
 		userCounts, _ := stats["users"].(map[string]int64)
 		if len(userCounts) > 0 {
 			for platform, users := range userCounts {
-				sb.WriteString(fmt.Sprintf("  • %s — %d users\n", platform, users))
+				line("status.users", platform, users)
 			}
 		} else {
-			sb.WriteString("  • _no activity yet_\n")
+			line("status.no_activity")
+		}
+
+		section("status.sessions")
+		if cfg.Session.MaxSessions > 0 {
+			line("status.in_memory_of", sessionMgr.Count(), cfg.Session.MaxSessions)
+		} else {
+			line("status.in_memory", sessionMgr.Count())
+		}
+		if cfg.Session.MaxTurns > 0 {
+			line("status.chat_turns_of", sessionMgr.Turns(sess), cfg.Session.MaxTurns)
+		} else {
+			line("status.chat_turns", sessionMgr.Turns(sess))
 		}
 
 		return sb.String(), nil
@@ -1009,7 +1781,7 @@ Send any message and I'll reply using AI.
 	case "/model":
 		allModels := llmRouter.ListAllModels(context.Background())
 		if len(allModels) == 0 {
-			return "❌ No models available", nil
+			return i18n.T(lang, "model.none"), nil
 		}
 
 		// Flatten models into numbered list
@@ -1018,26 +1790,32 @@ Send any message and I'll reply using AI.
 			model    llm.ModelInfo
 		}
 		var flat []flatModel
-		for provider, models := range allModels {
-			for _, m := range models {
+		for _, provider := range sortedKeys(allModels) {
+			for _, m := range allModels[provider] {
 				flat = append(flat, flatModel{provider, m})
 			}
 		}
 
+		sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+		sessionModel := sessionModelOverride(sessionMgr, sess, llmRouter.MainProvider())
+
 		// No args: show current model + numbered list
 		if len(args) == 0 {
 			stats := llmRouter.Stats()
 			var sb strings.Builder
-			sb.WriteString(fmt.Sprintf("🤖 *Current:* `%s`", stats["main"]))
+			sb.WriteString(i18n.T(lang, "model.current", stats["main"]))
+			if sessionModel != "" {
+				sb.WriteString(" | " + i18n.T(lang, "model.this_chat", sessionModel))
+			}
 			if cli := llmRouter.CLIProvider(); cli != nil {
 				if e := cli.Effort(); e != "" {
-					sb.WriteString(fmt.Sprintf(" | effort: %s", e))
+					sb.WriteString(" | " + i18n.T(lang, "model.effort", e))
 				}
 				if fb := cli.FallbackModel(); fb != "" {
-					sb.WriteString(fmt.Sprintf(" | fallback: %s", fb))
+					sb.WriteString(" | " + i18n.T(lang, "model.fallback", fb))
 				}
 			}
-			sb.WriteString("\n\n📋 Available models:\n")
+			sb.WriteString("\n\n" + i18n.T(lang, "model.available") + "\n")
 			for i, fm := range flat {
 				sb.WriteString(fmt.Sprintf("`%d.` `%s`", i+1, fm.model.ID))
 				if fm.model.Name != "" && fm.model.Name != fm.model.ID {
@@ -1045,47 +1823,78 @@ Send any message and I'll reply using AI.
 				}
 				sb.WriteString("\n")
 			}
-			sb.WriteString("\n_Switch: /model <number> or /model <name>_")
+			sb.WriteString("\n" + i18n.T(lang, "model.usage"))
 			return sb.String(), nil
 		}
 
-		// With args: switch model by number or name
+		// Picking a model can route to pricier ones, so it needs an admin
+		// unless per-chat overrides are enabled for everyone
+		isAdmin := cfg.IsPlatformAdmin(msg.Platform, msg.UserID)
+		global := strings.EqualFold(args[0], "global")
+		if global {
+			args = args[1:]
+			if len(args) == 0 {
+				return i18n.T(lang, "model.global_usage"), nil
+			}
+		}
+		if !isAdmin && (global || !cfg.LLM.AllowModelOverride) {
+			return i18n.T(lang, "admin.required"), nil
+		}
+
+		if !global && len(args) == 1 && strings.EqualFold(args[0], "default") {
+			sessionMgr.SetContext(sess, "model", "")
+			sessionMgr.SetContext(sess, "model_provider", "")
+			return i18n.T(lang, "model.chat_default", llmRouter.GetModel()), nil
+		}
+
+		// Switch model by number or name
 		selection := strings.Join(args, " ")
 
-		var selectedID string
+		var selected *flatModel
 		// Try as number first
 		var idx int
 		if n, err := fmt.Sscanf(selection, "%d", &idx); n == 1 && err == nil {
 			if idx < 1 || idx > len(flat) {
-				return fmt.Sprintf("❌ Invalid number. Choose 1-%d", len(flat)), nil
+				return i18n.T(lang, "choice.invalid", len(flat)), nil
 			}
-			selectedID = flat[idx-1].model.ID
+			selected = &flat[idx-1]
 		} else {
 			// Try as model name/ID
-			for _, fm := range flat {
+			for i, fm := range flat {
 				if strings.EqualFold(fm.model.ID, selection) || strings.EqualFold(fm.model.Name, selection) {
-					selectedID = fm.model.ID
+					selected = &flat[i]
 					break
 				}
 			}
-			if selectedID == "" {
-				return fmt.Sprintf("❌ Model '%s' not found. Use /model to see available models.", selection), nil
+			if selected == nil {
+				return i18n.T(lang, "model.not_found", selection), nil
 			}
 		}
 
-		llmRouter.SetModel(selectedID)
+		provider := llmRouter.MainProvider()
+		if selected.provider != provider {
+			return i18n.T(lang, "model.other_provider", selected.model.ID, selected.provider, selected.provider), nil
+		}
+
+		if !global {
+			sessionMgr.SetContext(sess, "model", selected.model.ID)
+			sessionMgr.SetContext(sess, "model_provider", provider)
+			return i18n.T(lang, "model.chat_set", selected.model.ID), nil
+		}
+
+		llmRouter.SetModel(selected.model.ID)
 		// Persist model to config YAML
-		if provider := llmRouter.MainProvider(); provider != "" {
-			if err := cfg.PatchYAMLField("llm."+provider+".model", selectedID); err != nil {
+		if provider != "" {
+			if err := cfg.PatchYAMLField(cfg.LLM.ProviderYAMLPath(provider)+".model", selected.model.ID); err != nil {
 				logger.Warn("persist model failed", "error", err)
 			}
 		}
-		return fmt.Sprintf("✅ Model switched to `%s`", selectedID), nil
+		return i18n.T(lang, "model.set", selected.model.ID), nil
 
 	case "/llm":
 		providers := llmRouter.Providers()
 		if len(providers) == 0 {
-			return "❌ No LLM providers registered", nil
+			return i18n.T(lang, "llm.none"), nil
 		}
 
 		currentMain := llmRouter.MainProvider()
@@ -1093,15 +1902,15 @@ Send any message and I'll reply using AI.
 		// No args: show current + list
 		if len(args) == 0 {
 			var sb strings.Builder
-			sb.WriteString(fmt.Sprintf("🤖 *Active:* `%s`\n\n📋 Available providers:\n", currentMain))
+			sb.WriteString(i18n.T(lang, "llm.current", currentMain) + "\n")
 			for i, p := range providers {
 				marker := ""
 				if p == currentMain {
-					marker = " ← active"
+					marker = " " + i18n.T(lang, "llm.active_marker")
 				}
 				sb.WriteString(fmt.Sprintf("`%d.` `%s`%s\n", i+1, p, marker))
 			}
-			sb.WriteString("\n_Switch: /llm <number> or /llm <name>_")
+			sb.WriteString("\n" + i18n.T(lang, "llm.usage"))
 			return sb.String(), nil
 		}
 
@@ -1112,7 +1921,7 @@ Send any message and I'll reply using AI.
 		var idx int
 		if n, err := fmt.Sscanf(selection, "%d", &idx); n == 1 && err == nil {
 			if idx < 1 || idx > len(providers) {
-				return fmt.Sprintf("❌ Invalid number. Choose 1-%d", len(providers)), nil
+				return i18n.T(lang, "choice.invalid", len(providers)), nil
 			}
 			selectedName = providers[idx-1]
 		} else {
@@ -1123,12 +1932,12 @@ Send any message and I'll reply using AI.
 				}
 			}
 			if selectedName == "" {
-				return fmt.Sprintf("❌ Provider '%s' not found. Use /llm to see available providers.", selection), nil
+				return i18n.T(lang, "llm.not_found", selection), nil
 			}
 		}
 
 		if selectedName == currentMain {
-			return fmt.Sprintf("`%s` is already the active provider.", selectedName), nil
+			return i18n.T(lang, "llm.already_active", selectedName), nil
 		}
 
 		if err := llmRouter.SetMain(selectedName); err != nil {
@@ -1140,25 +1949,19 @@ Send any message and I'll reply using AI.
 		switchMainUpdateEnv(cfg, selectedName)
 		saveRestartNotify(msg.Platform, msg.ChatID, "llm-switch")
 		adminH.ScheduleRestart(3, nil)
-		return fmt.Sprintf("✅ Active provider switched to `%s`\n🔄 Restarting in 3 seconds...", selectedName), nil
+		return i18n.T(lang, "llm.switched", selectedName), nil
 
 	case "/effort":
 		cli := llmRouter.CLIProvider()
 		if cli == nil {
-			return "❌ Effort only available for Claude CLI mode", nil
+			return i18n.T(lang, "cli.only", "/effort"), nil
 		}
 		if len(args) == 0 {
 			current := cli.Effort()
 			if current == "" {
 				current = "default"
 			}
-			return fmt.Sprintf("⚡ *Effort:* `%s`\n\n"+
-				"1. *low* — fast, short answers\n"+
-				"2. *medium* — balanced (default)\n"+
-				"3. *high* — detailed, slower\n"+
-				"4. *max* — maximum (Opus only)\n\n"+
-				"_Set: /effort <level> or /effort <number>_\n"+
-				"_Reset: /effort reset_", current), nil
+			return i18n.T(lang, "effort.current", current), nil
 		}
 		// Support number selection
 		switch args[0] {
@@ -1176,110 +1979,131 @@ Send any message and I'll reply using AI.
 		case "low", "medium", "high", "max":
 			cli.SetEffort(level)
 			if provider := llmRouter.MainProvider(); provider != "" {
-				if err := cfg.PatchYAMLField("llm."+provider+".effort", level); err != nil {
+				if err := cfg.PatchYAMLField(cfg.LLM.ProviderYAMLPath(provider)+".effort", level); err != nil {
 					logger.Warn("persist effort failed", "error", err)
 				}
 			}
-			return fmt.Sprintf("✅ Effort set to `%s`", level), nil
+			return i18n.T(lang, "effort.set", level), nil
 		case "default", "off", "reset":
 			cli.SetEffort("")
 			if provider := llmRouter.MainProvider(); provider != "" {
-				if err := cfg.PatchYAMLField("llm."+provider+".effort", ""); err != nil {
+				if err := cfg.PatchYAMLField(cfg.LLM.ProviderYAMLPath(provider)+".effort", ""); err != nil {
 					logger.Warn("persist effort reset failed", "error", err)
 				}
 			}
-			return "✅ Effort reset to default", nil
+			return i18n.T(lang, "effort.reset"), nil
 		default:
-			return "❌ Invalid effort. Options: `low` | `medium` | `high` | `max`", nil
+			return i18n.T(lang, "effort.invalid"), nil
 		}
 
 	case "/prompt":
 		cli := llmRouter.CLIProvider()
 		if cli == nil {
-			return "❌ Prompt customization only available for Claude CLI mode", nil
+			return i18n.T(lang, "cli.only", "/prompt"), nil
 		}
 		if len(args) == 0 {
 			current := cli.AppendPrompt()
 			if current == "" {
-				return "📝 *Custom prompt:* _none_\n\n_Set: /prompt <instructions>_\n_Clear: /prompt reset_", nil
+				return i18n.T(lang, "prompt.none"), nil
 			}
-			return fmt.Sprintf("📝 *Custom prompt:*\n%s\n\n_Clear: /prompt reset_", current), nil
+			return i18n.T(lang, "prompt.current", current), nil
 		}
 		if args[0] == "reset" || args[0] == "off" || args[0] == "clear" {
 			cli.SetAppendPrompt("")
-			return "✅ Custom prompt cleared", nil
+			return i18n.T(lang, "prompt.cleared"), nil
 		}
 		prompt := strings.Join(args, " ")
 		cli.SetAppendPrompt(prompt)
-		return fmt.Sprintf("✅ Custom prompt set:\n_%s_", prompt), nil
+		return i18n.T(lang, "prompt.set", prompt), nil
 
 	case "/fallback":
 		cli := llmRouter.CLIProvider()
 		if cli == nil {
-			return "❌ Fallback only available for Claude CLI mode", nil
+			return i18n.T(lang, "cli.only", "/fallback"), nil
 		}
 		if len(args) == 0 {
 			current := cli.FallbackModel()
 			if current == "" {
-				return "🔄 *Fallback model:* _none_\n\n_Set: /fallback <model>_\n_Example: /fallback claude-sonnet-4-6_", nil
+				return i18n.T(lang, "fallback.none"), nil
 			}
-			return fmt.Sprintf("🔄 *Fallback model:* `%s`\n\n_Clear: /fallback off_", current), nil
+			return i18n.T(lang, "fallback.current", current), nil
 		}
 		if args[0] == "off" || args[0] == "reset" || args[0] == "none" {
 			cli.SetFallbackModel("")
 			if provider := llmRouter.MainProvider(); provider != "" {
-				if err := cfg.PatchYAMLField("llm."+provider+".fallback_model", ""); err != nil {
+				if err := cfg.PatchYAMLField(cfg.LLM.ProviderYAMLPath(provider)+".fallback_model", ""); err != nil {
 					logger.Warn("persist fallback reset failed", "error", err)
 				}
 			}
-			return "✅ Fallback model disabled", nil
+			return i18n.T(lang, "fallback.cleared"), nil
 		}
 		model := args[0]
 		cli.SetFallbackModel(model)
 		if provider := llmRouter.MainProvider(); provider != "" {
-			if err := cfg.PatchYAMLField("llm."+provider+".fallback_model", model); err != nil {
+			if err := cfg.PatchYAMLField(cfg.LLM.ProviderYAMLPath(provider)+".fallback_model", model); err != nil {
 				logger.Warn("persist fallback failed", "error", err)
 			}
 		}
-		return fmt.Sprintf("✅ Fallback model set to `%s`", model), nil
+		return i18n.T(lang, "fallback.set", model), nil
 
 	case "/budget":
 		cli := llmRouter.CLIProvider()
 		if cli == nil {
-			return "❌ Budget only available for Claude CLI mode", nil
+			return i18n.T(lang, "cli.only", "/budget"), nil
 		}
 		if len(args) == 0 {
 			current := cli.MaxBudget()
 			if current <= 0 {
-				return "💰 *Budget:* _unlimited_\n\n_Set: /budget <amount>_ (e.g. /budget 5.00)\n_Clear: /budget off_", nil
+				return i18n.T(lang, "budget.none"), nil
 			}
-			return fmt.Sprintf("💰 *Budget:* $%.2f per request\n\n_Clear: /budget off_", current), nil
+			return i18n.T(lang, "budget.current", current), nil
 		}
 		if args[0] == "off" || args[0] == "reset" || args[0] == "unlimited" {
 			cli.SetMaxBudget(0)
-			return "✅ Budget limit removed", nil
+			return i18n.T(lang, "budget.cleared"), nil
 		}
 		var amount float64
 		if _, err := fmt.Sscanf(args[0], "%f", &amount); err != nil || amount <= 0 {
-			return "❌ Invalid amount. Example: `/budget 5.00`", nil
+			return i18n.T(lang, "budget.invalid"), nil
 		}
 		cli.SetMaxBudget(amount)
-		return fmt.Sprintf("✅ Budget set to $%.2f per request", amount), nil
+		return i18n.T(lang, "budget.set", amount), nil
 
-	case "/clear":
-		sess := sessionMgr.GetOrCreate(msg.Platform, msg.ChatID, msg.UserID)
-		sessionMgr.ClearMessages(sess)
-		sessionKey := fmt.Sprintf("%s:%s", msg.Platform, msg.ChatID)
-		if err := store.ClearConversationHistory(sessionKey); err != nil {
-			return fmt.Sprintf("⚠️ History cleared from memory but DB error: %v", err), nil
+	case "/temp":
+		return handleTempCommand(args, lang, msg, cfg, sessionMgr), nil
+
+	case "/think":
+		if llmRouter.CLIProvider() != nil {
+			return i18n.T(lang, "think.cli"), nil
 		}
-		return "🗑 Conversation history cleared.", nil
+		sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+		model := sessionModelOverride(sessionMgr, sess, llmRouter.MainProvider())
+		if model == "" {
+			model = llmRouter.GetModel()
+		}
+		return handleThinkCommand(args, lang, msg, cfg, sessionMgr, llmRouter.MainProvider(), model), nil
+
+	case "/sources":
+		return handleSourcesCommand(lang, msg, cfg, sessionMgr), nil
+
+	case "/clear", "/reset", "/new":
+		sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+		if err := resetConversation(sessionMgr, store, sess); err != nil {
+			return i18n.T(lang, "session.reset_db_error", err), nil
+		}
+		return i18n.T(lang, "session.reset"), nil
+
+	case "/export":
+		return handleExportCommand(args, lang, msg, rtr, cfg, sessionMgr, confirmMgr, logger), nil
+
+	case "/checkpoint":
+		return handleCheckpointCommand(args, lang, msg, store, sessionMgr, logger), nil
 
 	case "/persona":
 		if len(cfg.Personas.List) == 0 {
-			return "No personas configured. Add a `personas` section to config.yaml.", nil
+			return i18n.T(lang, "persona.none"), nil
 		}
-		sess := sessionMgr.GetOrCreate(msg.Platform, msg.ChatID, msg.UserID)
+		sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
 
 		if len(args) == 0 {
 			// Show current persona and list available
@@ -1290,8 +2114,8 @@ Send any message and I'll reply using AI.
 				}
 			}
 			var sb strings.Builder
-			sb.WriteString(fmt.Sprintf("🎭 Active persona: %s\n\n", currentName))
-			sb.WriteString("Available personas:\n")
+			sb.WriteString(i18n.T(lang, "persona.current", currentName) + "\n\n")
+			sb.WriteString(i18n.T(lang, "persona.available") + "\n")
 			for i, p := range cfg.Personas.List {
 				marker := "  "
 				if p.Name == currentName {
@@ -1299,11 +2123,11 @@ Send any message and I'll reply using AI.
 				}
 				personality := ""
 				if p.Personality != "" {
-					personality = fmt.Sprintf("\n     Personality: %s", p.Personality)
+					personality = "\n     " + i18n.T(lang, "persona.personality", p.Personality)
 				}
 				sb.WriteString(fmt.Sprintf("%s%d. %s — %s%s\n", marker, i+1, p.Name, p.Description, personality))
 			}
-			sb.WriteString("\nSwitch: /persona <name or number>")
+			sb.WriteString("\n" + i18n.T(lang, "persona.usage"))
 			return sb.String(), nil
 		}
 
@@ -1321,34 +2145,55 @@ Send any message and I'll reply using AI.
 			for i, p := range cfg.Personas.List {
 				names = append(names, fmt.Sprintf("%d:%s", i+1, p.Name))
 			}
-			return fmt.Sprintf("Unknown persona %q. Available: %s", args[0], strings.Join(names, ", ")), nil
+			return i18n.T(lang, "persona.unknown", args[0], strings.Join(names, ", ")), nil
 		}
 
 		sessionMgr.SetContext(sess, "persona", persona.Name)
 
 		// Clear conversation history when switching persona
 		sessionMgr.ClearMessages(sess)
-		sessionKey := fmt.Sprintf("%s:%s", msg.Platform, msg.ChatID)
-		_ = store.ClearConversationHistory(sessionKey)
+		_ = store.ClearConversationHistory(sess.ID)
 
 		if persona.FirstMessage != "" {
-			return fmt.Sprintf("🎭 Switched to %s\n\n%s", persona.Name, persona.FirstMessage), nil
+			return i18n.T(lang, "persona.set", persona.Name) + "\n\n" + persona.FirstMessage, nil
 		}
-		return fmt.Sprintf("🎭 Switched to %s", persona.Name), nil
+		return i18n.T(lang, "persona.set", persona.Name), nil
 
 	case "/config":
 		if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
-			return "🔒 Admin access required.", nil
+			return i18n.T(lang, "admin.required"), nil
 		}
 		resp, needRestart, err := adminH.HandleCommand(msg.Platform, msg.UserID, msg.ChatID, args)
 		if err != nil {
-			return fmt.Sprintf("❌ Error: %v", err), nil
+			return i18n.T(lang, "error", err), nil
+		}
+		if needRestart {
+			adminH.ScheduleRestart(3, nil)
+		}
+		return resp, nil
+
+	case "/allow":
+		if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
+			return i18n.T(lang, "admin.required"), nil
+		}
+		if len(args) != 1 {
+			return i18n.T(lang, "allow.usage"), nil
+		}
+		resp, needRestart, err := adminH.HandleCommand(msg.Platform, msg.UserID, msg.ChatID, []string{"allow", "user", args[0]})
+		if err != nil {
+			return i18n.T(lang, "error", err), nil
 		}
 		if needRestart {
 			adminH.ScheduleRestart(3, nil)
 		}
 		return resp, nil
 
+	case "/feedback":
+		if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
+			return i18n.T(lang, "admin.required"), nil
+		}
+		return handleFeedbackCommand(args, lang, store, cfg), nil
+
 	case "/memory":
 		return memoryH.HandleCommand(msg.UserID, msg.Platform, args)
 
@@ -1357,23 +2202,23 @@ Send any message and I'll reply using AI.
 
 	case "/restart":
 		if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
-			return "🔒 Admin access required.", nil
+			return i18n.T(lang, "admin.required"), nil
 		}
 		prompt := confirmMgr.Request(
 			msg.Platform, msg.ChatID, msg.UserID,
-			"🔄 *Restart Magabot?*\nBot will restart and be briefly offline.",
+			i18n.T(lang, "restart.confirm"),
 			2*time.Minute,
 			func() (string, error) {
 				saveRestartNotify(msg.Platform, msg.ChatID, "restart")
 				adminH.ScheduleRestart(3, nil)
-				return "✅ Restarting in 3 seconds...", nil
+				return i18n.T(lang, "restart.scheduled"), nil
 			},
 		)
-		return prompt, nil
+		return msg.Respond(router.Reply{Text: prompt, Buttons: confirmButtons}), nil
 
 	case "/update":
 		if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
-			return "🔒 Admin access required.", nil
+			return i18n.T(lang, "admin.required"), nil
 		}
 
 		u := updater.New(updater.Config{
@@ -1388,17 +2233,15 @@ Send any message and I'll reply using AI.
 
 		release, hasUpdate, err := u.CheckUpdate(ctx)
 		if err != nil {
-			return fmt.Sprintf("❌ Update check failed: %v", err), nil
+			return i18n.T(lang, "update.check_failed", err), nil
 		}
 		if !hasUpdate {
-			return fmt.Sprintf("✅ Already up to date! (v%s)", version.Short()), nil
+			return i18n.T(lang, "update.none", version.Short()), nil
 		}
 
 		prompt := confirmMgr.Request(
 			msg.Platform, msg.ChatID, msg.UserID,
-			fmt.Sprintf("🔄 *Update Available*\n\n📦 %s → %s\n\n📝 %s",
-				version.Short(), release.TagName,
-				truncateNotes(release.Body, 200)),
+			i18n.T(lang, "update.confirm", version.Short(), release.TagName, truncateNotes(release.Body, 200)),
 			5*time.Minute,
 			func() (string, error) {
 				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
@@ -1408,13 +2251,13 @@ Send any message and I'll reply using AI.
 				}
 				saveRestartNotify(msg.Platform, msg.ChatID, "update")
 				adminH.ScheduleRestart(3, nil)
-				return fmt.Sprintf("✅ Updated to %s! Restarting in 3s...", release.TagName), nil
+				return i18n.T(lang, "update.done", release.TagName), nil
 			},
 		)
-		return prompt, nil
+		return msg.Respond(router.Reply{Text: prompt, Buttons: confirmButtons}), nil
 
 	default:
-		return "❓ Unknown command. Try /help", nil
+		return i18n.T(lang, "command.unknown"), nil
 	}
 }
 
@@ -1528,6 +2371,7 @@ func loadSecrets(cfg *config.Config, logger *slog.Logger) *secrets.Manager {
 		{secrets.KeyGLMAPIKey, &cfg.LLM.GLM.APIKey, "glm_api_key"},
 		{secrets.KeyKimiAPIKey, &cfg.LLM.Kimi.APIKey, "kimi_api_key"},
 		{secrets.KeyMiniMaxAPIKey, &cfg.LLM.MiniMax.APIKey, "minimax_api_key"},
+		{secrets.KeyEmbeddingAPIKey, &cfg.Memory.Embedding.APIKey, "embedding_api_key"},
 	}
 
 	// Platform secrets need nil-safe handling
@@ -1538,6 +2382,7 @@ func loadSecrets(cfg *config.Config, logger *slog.Logger) *secrets.Manager {
 		mappings = append(mappings,
 			secretMapping{secrets.KeySlackBotToken, &cfg.Platforms.Slack.BotToken, "slack_bot_token"},
 			secretMapping{secrets.KeySlackAppToken, &cfg.Platforms.Slack.AppToken, "slack_app_token"},
+			secretMapping{secrets.KeySlackSigningSecret, &cfg.Platforms.Slack.SigningSecret, "slack_signing_secret"},
 		)
 	}
 
@@ -1581,6 +2426,7 @@ func derefFloat64(p *float64) float64 {
 
 // buildClientOptions constructs the common allm.Option slice for context window,
 // retry, truncation, and input length settings shared across all provider registrations.
+// Rate limits are not retried by allm but by the router, after the provider's Retry-After.
 func buildClientOptions(model string, maxRetries int, llmCfg *config.LLMConfig) []allm.Option {
 	var opts []allm.Option
 	if model != "" {
@@ -1595,8 +2441,9 @@ func buildClientOptions(model string, maxRetries int, llmCfg *config.LLMConfig)
 	if llmCfg.TruncationStrategy != "" {
 		opts = append(opts, allm.WithTruncationStrategy(llmCfg.TruncationStrategy))
 	}
-	if llmCfg.MaxInputLength > 0 {
-		opts = append(opts, allm.WithMaxInputLen(llmCfg.MaxInputLength))
+	if llmCfg.MaxInputTokens > 0 {
+		// The router enforces the limit in tokens; allm's check is in bytes
+		opts = append(opts, allm.WithMaxInputLen(llm.InputLenLimit(llmCfg.MaxInputTokens)))
 	}
 	return opts
 }
@@ -1610,9 +2457,172 @@ type compatProviderConfig struct {
 	baseURL     string
 	isLocal     bool // local providers allow localhost/private IPs
 	maxRetries  int
+	network     config.LLMProviderConfig // proxy, extra_headers and timeout
 	constructor func(apiKey string, opts ...provider.CompatOption) *provider.OpenAICompatibleProvider
 }
 
+// defaultProviderEndpoints are the base URLs providers use without a
+// base_url, so a per-provider proxy and headers can be routed to them.
+var defaultProviderEndpoints = map[string]string{
+	"anthropic": "https://api.anthropic.com",
+	"openai":    "https://api.openai.com",
+	"glm":       "https://api.z.ai/api/anthropic",
+	"kimi":      "https://api.moonshot.ai/anthropic",
+	"minimax":   "https://api.minimax.io/anthropic",
+	"local":     "http://localhost:11434/v1",
+	"ollama":    llm.DefaultOllamaURL,
+}
+
+// providerNetworkOptions applies a provider's proxy, headers and timeout.
+// A provider with a proxy or headers gets an HTTP client of its own, which
+// is returned for providers that take one. The LLM SDKs always use
+// http.DefaultClient, so it is also registered for the provider's endpoint
+// (see util.RegisterEndpoint). Without a proxy, HTTPS_PROXY/NO_PROXY apply
+// as usual.
+func providerNetworkOptions(name, baseURL string, pc config.LLMProviderConfig) ([]allm.Option, *http.Client, error) {
+	var opts []allm.Option
+	if timeout := pc.Timeout.Duration(); timeout > 0 {
+		opts = append(opts, allm.WithTimeout(timeout))
+	}
+
+	hr, hasRequest, err := providerHostRequest(name, pc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pc.Proxy == "" && !hasRequest {
+		return opts, nil, nil
+	}
+	var proxyURL *url.URL
+	if pc.Proxy != "" {
+		if proxyURL, err = util.ParseProxyURL(pc.Proxy); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	endpoint := baseURL
+	if endpoint == "" {
+		endpoint = defaultProviderEndpoints[name]
+	}
+	if endpoint == "" {
+		return nil, nil, fmt.Errorf("%s: proxy and extra_headers require base_url", name)
+	}
+	client := util.NewEndpointClient(proxyURL, hr)
+	if err := util.RegisterEndpoint(endpoint, client); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return opts, client, nil
+}
+
+// providerHostRequest builds the request changes for a provider's
+// extra_headers, organization, project and azure settings. ok is false when
+// none are set.
+func providerHostRequest(name string, pc config.LLMProviderConfig) (hr util.HostRequest, ok bool, err error) {
+	header, err := util.ParseHeaders(pc.ExtraHeaders)
+	if err != nil {
+		return hr, false, fmt.Errorf("%s extra_headers: %w", name, err)
+	}
+	if pc.Organization != "" {
+		header.Set("OpenAI-Organization", pc.Organization)
+	}
+	if pc.Project != "" {
+		header.Set("OpenAI-Project", pc.Project)
+	}
+	hr.Header = header
+	if pc.Azure != nil && name != "openai" {
+		return hr, false, fmt.Errorf("%s: azure is only supported by the openai provider", name)
+	}
+	if pc.Azure != nil {
+		hr.Query = url.Values{"api-version": {pc.Azure.APIVersion}}
+		hr.APIKeyHeader = "api-key"
+	}
+	return hr, len(header) > 0 || pc.Azure != nil, nil
+}
+
+// azureBaseURL returns the OpenAI base URL of an Azure OpenAI deployment.
+func azureBaseURL(az *config.AzureOpenAIConfig) (string, error) {
+	if az.Endpoint == "" || az.Deployment == "" || az.APIVersion == "" {
+		return "", fmt.Errorf("azure: endpoint, deployment and api_version are required")
+	}
+	u, err := url.Parse(strings.TrimRight(az.Endpoint, "/"))
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("azure: endpoint must be an https URL")
+	}
+	return u.String() + "/openai/deployments/" + url.PathEscape(az.Deployment), nil
+}
+
+// registerLLMProviders registers every enabled provider with llmRouter,
+// logging the ones that fail to register.
+func registerLLMProviders(llmRouter *llm.Router, cfg *config.Config, logger *slog.Logger) {
+	if cfg.LLM.Anthropic.Enabled {
+		if err := registerAnthropicProvider(llmRouter, cfg); err != nil {
+			logger.Error("register anthropic provider failed", "error", err)
+		}
+	}
+
+	if cfg.LLM.OpenAI.Enabled {
+		if err := registerOpenAIProvider(llmRouter, cfg); err != nil {
+			logger.Error("register openai provider failed", "error", err)
+		}
+	}
+
+	if cfg.LLM.GLM.Enabled {
+		if err := registerAnthropicCompatProvider(llmRouter, "glm", cfg.LLM.GLM, cfg); err != nil {
+			logger.Error("register glm provider failed", "error", err)
+		}
+	}
+
+	if cfg.LLM.Local.Enabled {
+		if err := registerCompatProvider(llmRouter, compatProviderConfig{
+			name: "local", model: cfg.LLM.Local.Model,
+			maxTokens: derefInt(cfg.LLM.Local.MaxTokens), temperature: derefFloat64(cfg.LLM.Local.Temperature),
+			baseURL: cfg.LLM.Local.BaseURL, isLocal: true, maxRetries: derefInt(cfg.LLM.Local.MaxRetries),
+			network: cfg.LLM.Local,
+		}, cfg); err != nil {
+			logger.Error("register local provider failed", "error", err)
+		}
+	}
+
+	if cfg.LLM.Ollama.Enabled {
+		if err := registerOllamaProvider(llmRouter, cfg); err != nil {
+			logger.Error("register ollama provider failed", "error", err)
+		}
+	}
+
+	if cfg.LLM.Kimi.Enabled {
+		if err := registerAnthropicCompatProvider(llmRouter, "kimi", cfg.LLM.Kimi, cfg); err != nil {
+			logger.Error("register kimi provider failed", "error", err)
+		}
+	}
+
+	if cfg.LLM.MiniMax.Enabled {
+		if err := registerAnthropicCompatProvider(llmRouter, "minimax", cfg.LLM.MiniMax, cfg); err != nil {
+			logger.Error("register minimax provider failed", "error", err)
+		}
+	}
+
+	for _, name := range sortedKeys(cfg.LLM.Compatible) {
+		pc := cfg.LLM.Compatible[name]
+		if pc == nil || !pc.Enabled {
+			continue
+		}
+		if err := registerOpenAICompatibleProvider(llmRouter, name, *pc, cfg); err != nil {
+			logger.Error("register openai-compatible provider failed", "name", name, "error", err)
+		}
+	}
+
+	for _, cp := range cfg.LLM.Custom {
+		if err := registerCustomProvider(llmRouter, cp, cfg); err != nil {
+			logger.Error("register custom provider failed", "name", cp.Name, "error", err)
+		}
+	}
+
+	// Output token limits let the router spot answers cut off by them
+	for _, name := range llmRouter.Providers() {
+		if pc := cfg.LLM.GetProviderConfig(name); pc != nil {
+			llmRouter.SetMaxTokens(name, derefInt(pc.MaxTokens))
+		}
+	}
+}
+
 // registerCompatProvider registers an OpenAI-compatible provider with shared validation logic.
 func registerCompatProvider(llmRouter *llm.Router, cfg compatProviderConfig, llmCfg *config.Config) error {
 	if cfg.baseURL != "" {
@@ -1648,7 +2658,37 @@ func registerCompatProvider(llmRouter *llm.Router, cfg compatProviderConfig, llm
 	}
 
 	clientOpts := buildClientOptions(cfg.model, cfg.maxRetries, &llmCfg.LLM)
-	llmRouter.Register(cfg.name, allm.New(p, clientOpts...))
+	netOpts, _, err := providerNetworkOptions(cfg.name, cfg.baseURL, cfg.network)
+	if err != nil {
+		return err
+	}
+	clientOpts = append(clientOpts, netOpts...)
+	llmRouter.RegisterProvider(cfg.name, p, clientOpts...)
+	return nil
+}
+
+// registerOllamaProvider registers Ollama through its native API.
+func registerOllamaProvider(llmRouter *llm.Router, cfg *config.Config) error {
+	oc := cfg.LLM.Ollama
+	netOpts, httpClient, err := providerNetworkOptions("ollama", oc.BaseURL, oc)
+	if err != nil {
+		return err
+	}
+	p, err := llm.NewOllama(llm.OllamaConfig{
+		BaseURL:     oc.BaseURL,
+		Model:       oc.Model,
+		MaxTokens:   derefInt(oc.MaxTokens),
+		Temperature: derefFloat64(oc.Temperature),
+		KeepAlive:   oc.KeepAlive,
+		HTTPClient:  httpClient,
+	})
+	if err != nil {
+		return err
+	}
+
+	clientOpts := buildClientOptions(oc.Model, derefInt(oc.MaxRetries), &cfg.LLM)
+	clientOpts = append(clientOpts, netOpts...)
+	llmRouter.RegisterProvider("ollama", p, clientOpts...)
 	return nil
 }
 
@@ -1676,7 +2716,7 @@ func registerAnthropicCompatProvider(llmRouter *llm.Router, name string, ac conf
 			allowedTools = defaultCLITools
 		}
 		cliOpts = append(cliOpts, provider.WithCLIAllowedTools(allowedTools))
-		llmRouter.Register(name, allm.New(provider.ClaudeCLI(cliOpts...), clientOpts...))
+		llmRouter.RegisterProvider(name, provider.ClaudeCLI(cliOpts...), clientOpts...)
 		return nil
 	}
 
@@ -1687,6 +2727,12 @@ func registerAnthropicCompatProvider(llmRouter *llm.Router, name string, ac conf
 		}
 	}
 
+	netOpts, _, err := providerNetworkOptions(name, ac.BaseURL, ac)
+	if err != nil {
+		return err
+	}
+	clientOpts = append(clientOpts, netOpts...)
+
 	opts := []provider.AnthropicOption{
 		provider.WithAnthropicModel(ac.Model),
 		provider.WithAnthropicMaxTokens(derefInt(ac.MaxTokens)),
@@ -1696,24 +2742,32 @@ func registerAnthropicCompatProvider(llmRouter *llm.Router, name string, ac conf
 		opts = append(opts, provider.WithAnthropicBaseURL(ac.BaseURL))
 	}
 
-	var p allm.Provider
+	build := provider.Anthropic
 	switch name {
 	case "glm":
-		p = provider.GLM(ac.APIKey, opts...)
+		build = provider.GLM
 	case "kimi":
-		p = provider.Kimi(ac.APIKey, opts...)
+		build = provider.Kimi
 	case "minimax":
-		p = provider.MiniMax(ac.APIKey, opts...)
-	default:
-		p = provider.Anthropic(ac.APIKey, opts...)
+		build = provider.MiniMax
 	}
-	llmRouter.Register(name, allm.New(p, clientOpts...))
+	p := llm.PooledProvider(ac.Keys(), 0, func(key string) *provider.AnthropicProvider {
+		return build(key, opts...)
+	})
+	llmRouter.RegisterProvider(name, p, clientOpts...)
 	return nil
 }
 
 func registerOpenAIProvider(llmRouter *llm.Router, cfg *config.Config) error {
-	if cfg.LLM.OpenAI.BaseURL != "" {
-		if err := util.ValidateBaseURL(cfg.LLM.OpenAI.BaseURL); err != nil {
+	baseURL := cfg.LLM.OpenAI.BaseURL
+	if cfg.LLM.OpenAI.Azure != nil {
+		var err error
+		if baseURL, err = azureBaseURL(cfg.LLM.OpenAI.Azure); err != nil {
+			return err
+		}
+	}
+	if baseURL != "" {
+		if err := util.ValidateBaseURL(baseURL); err != nil {
 			return fmt.Errorf("invalid base URL: %w", err)
 		}
 	}
@@ -1723,51 +2777,132 @@ func registerOpenAIProvider(llmRouter *llm.Router, cfg *config.Config) error {
 		provider.WithOpenAIMaxTokens(derefInt(cfg.LLM.OpenAI.MaxTokens)),
 		provider.WithOpenAITemperature(derefFloat64(cfg.LLM.OpenAI.Temperature)),
 	}
-	if cfg.LLM.OpenAI.BaseURL != "" {
-		opts = append(opts, provider.WithOpenAIBaseURL(cfg.LLM.OpenAI.BaseURL))
+	if baseURL != "" {
+		opts = append(opts, provider.WithOpenAIBaseURL(baseURL))
 	}
 
 	clientOpts := buildClientOptions(cfg.LLM.OpenAI.Model, derefInt(cfg.LLM.OpenAI.MaxRetries), &cfg.LLM)
-	llmRouter.Register("openai", allm.New(provider.OpenAI(cfg.LLM.OpenAI.APIKey, opts...), clientOpts...))
+	netOpts, _, err := providerNetworkOptions("openai", baseURL, cfg.LLM.OpenAI)
+	if err != nil {
+		return err
+	}
+	clientOpts = append(clientOpts, netOpts...)
+	p := llm.PooledProvider(cfg.LLM.OpenAI.Keys(), 0, func(key string) *provider.OpenAIProvider {
+		return provider.OpenAI(key, opts...)
+	})
+	llmRouter.RegisterProvider("openai", p, clientOpts...)
 	return nil
 }
 
-// handleAgentCommand processes colon-prefixed agent session commands.
-// Only platform admins can use agent sessions (they execute code on the server).
-func handleAgentCommand(msg *router.Message, agentMgr *agent.Manager, cfg *config.Config) (string, error) {
-	// Agent sessions execute code on the server — restrict to admins
-	if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
-		return "Agent sessions require admin access.", nil
+// registerOpenAICompatibleProvider registers a user-named OpenAI-compatible
+// endpoint from llm.compatible (OpenRouter, Together, Groq, ...).
+func registerOpenAICompatibleProvider(llmRouter *llm.Router, name string, pc config.LLMProviderConfig, cfg *config.Config) error {
+	if config.IsBuiltinProvider(name) {
+		return fmt.Errorf("name %q is reserved for the built-in provider", name)
+	}
+	return registerCompatibleProvider(llmRouter, name, llm.FormatOpenAI, pc, cfg)
+}
+
+// registerCustomProvider registers an llm.custom entry in the wire format
+// it names. Its name must not clash with a built-in, llm.compatible or
+// earlier llm.custom provider.
+func registerCustomProvider(llmRouter *llm.Router, cp config.CustomProviderConfig, cfg *config.Config) error {
+	if config.IsBuiltinProvider(cp.Name) {
+		return fmt.Errorf("name %q is reserved for the built-in provider", cp.Name)
+	}
+	if _, ok := cfg.LLM.Compatible[cp.Name]; ok || util.Contains(llmRouter.Providers(), cp.Name) {
+		return fmt.Errorf("name %q is already used by another provider", cp.Name)
+	}
+	return registerCompatibleProvider(llmRouter, cp.Name, cp.Format, cp.LLMProviderConfig, cfg)
+}
+
+// registerCompatibleProvider registers a user-named endpoint speaking the
+// given wire format.
+func registerCompatibleProvider(llmRouter *llm.Router, name, format string, pc config.LLMProviderConfig, cfg *config.Config) error {
+	clientOpts := buildClientOptions(pc.Model, derefInt(pc.MaxRetries), &cfg.LLM)
+	netOpts, _, err := providerNetworkOptions(name, pc.BaseURL, pc)
+	if err != nil {
+		return err
+	}
+	clientOpts = append(clientOpts, netOpts...)
+	client, err := llm.NewCompatible(llm.CompatibleConfig{
+		Name:        name,
+		Format:      format,
+		BaseURL:     pc.BaseURL,
+		APIKeys:     pc.Keys(),
+		Model:       pc.Model,
+		MaxTokens:   derefInt(pc.MaxTokens),
+		Temperature: derefFloat64(pc.Temperature),
+	}, clientOpts...)
+	if err != nil {
+		return err
 	}
+	llmRouter.RegisterProvider(name, client.Provider(), clientOpts...)
+	return nil
+}
+
+// sortedKeys returns the keys of m in sorted order for deterministic iteration.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// activePersona returns the persona chosen for a session with /persona, or
+// the default persona; nil when none are configured.
+func activePersona(cfg *config.Config, sessionMgr *session.Manager, sess *session.Session) *config.Persona {
+	if len(cfg.Personas.List) == 0 {
+		return nil
+	}
+	personaName, _ := sessionMgr.GetContext(sess, "persona").(string)
+	if persona := cfg.GetPersona(personaName); persona != nil {
+		return persona
+	}
+	return cfg.GetDefaultPersona()
+}
+
+// sessionModelOverride returns the model chosen for a session with /model,
+// or "" when there is none or it belongs to a provider that is no longer main.
+func sessionModelOverride(sessionMgr *session.Manager, sess *session.Session, provider string) string {
+	model, _ := sessionMgr.GetContext(sess, "model").(string)
+	if owner, _ := sessionMgr.GetContext(sess, "model_provider").(string); owner != provider {
+		return ""
+	}
+	return model
+}
 
+// handleAgentCommand processes colon-prefixed agent session commands.
+// Only platform admins can use agent sessions (they execute code on the server).
+func handleAgentCommand(msg *router.Message, agentMgr *agent.Manager, cfg *config.Config, lang string) (string, error) {
 	parts := strings.Fields(msg.Text)
 	if len(parts) == 0 {
 		return "", nil
 	}
 
 	cmd := strings.ToLower(parts[0])
+	if cfg.CommandDisabled(cmd) {
+		return i18n.T(lang, "command.disabled", cmd), nil
+	}
+
+	// Agent sessions execute code on the server — restrict to admins
+	if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
+		return i18n.T(lang, "agent.admin_required"), nil
+	}
 
 	switch cmd {
 	case ":new":
-		if agentMgr.HasSession(msg.Platform, msg.ChatID) {
-			return "Agent session already active. Use :quit first.", nil
+		name, agentType, dir, err := parseAgentNew(parts[1:])
+		if err != nil {
+			return i18n.T(lang, "agent.start_failed", err) + "\n" + i18n.T(lang, "agent.new_usage"), nil
 		}
-
-		agentType := ""
-		dir := ""
-
-		switch len(parts) {
-		case 1:
-			dir = "~"
-		case 2:
-			dir = parts[1]
-		default:
-			if agent.ValidAgent(parts[1]) {
-				agentType = parts[1]
-				dir = parts[2]
-			} else {
-				dir = parts[1]
+		if agentMgr.GetNamedSession(msg.Platform, msg.ChatID, name) != nil {
+			if name == agent.DefaultSession {
+				return i18n.T(lang, "agent.active"), nil
 			}
+			return i18n.T(lang, "agent.named_active", name, name), nil
 		}
 
 		resolved, resolveErr := agentMgr.ResolveDir(dir)
@@ -1775,49 +2910,142 @@ func handleAgentCommand(msg *router.Message, agentMgr *agent.Manager, cfg *confi
 			return resolveErr.Error(), nil
 		}
 
-		sess, err := agentMgr.NewSession(msg.Platform, msg.ChatID, msg.UserID, agentType, resolved)
+		sess, err := agentMgr.NewNamedSession(msg.Platform, msg.ChatID, name, msg.UserID, agentType, resolved)
 		if err != nil {
-			return fmt.Sprintf("Failed to start agent session: %v", err), nil
+			return i18n.T(lang, "agent.start_failed", err), nil
 		}
 
-		return fmt.Sprintf("Agent session started: %s in %s\nSend messages to interact. Use :quit to end.", sess.Agent, sess.Dir), nil
+		if name != agent.DefaultSession {
+			return i18n.T(lang, "agent.named_started", name, sess.Agent, sess.Dir, name, name), nil
+		}
+		return i18n.T(lang, "agent.started", sess.Agent, sess.Dir), nil
 
 	case ":quit", ":exit", ":close":
-		if !agentMgr.HasSession(msg.Platform, msg.ChatID) {
-			return "No active agent session.", nil
+		name := agent.DefaultSession
+		if len(parts) > 1 {
+			name = strings.ToLower(parts[1])
+		}
+		if !agentMgr.CloseNamedSession(msg.Platform, msg.ChatID, name) {
+			if name == agent.DefaultSession {
+				return i18n.T(lang, "agent.none"), nil
+			}
+			return i18n.T(lang, "agent.not_found", name), nil
+		}
+		if name != agent.DefaultSession {
+			return i18n.T(lang, "agent.named_closed", name), nil
 		}
-		agentMgr.CloseSession(msg.Platform, msg.ChatID)
-		return "Agent session closed.", nil
+		return i18n.T(lang, "agent.closed"), nil
 
-	case ":status":
-		sess := agentMgr.GetSession(msg.Platform, msg.ChatID)
+	case ":save":
+		name := agent.DefaultSession
+		if len(parts) > 1 {
+			name = strings.ToLower(parts[1])
+		}
+		sess := agentMgr.GetNamedSession(msg.Platform, msg.ChatID, name)
 		if sess == nil {
-			return "No active agent session.", nil
+			if name == agent.DefaultSession {
+				return i18n.T(lang, "agent.none"), nil
+			}
+			return i18n.T(lang, "agent.not_found", name), nil
+		}
+		transcript := sess.Transcript()
+		if len(transcript) == 0 {
+			return i18n.T(lang, "agent.save_empty"), nil
+		}
+		path, err := saveAgentTranscript(cfg.Paths.ExportsDir, sess, transcript)
+		if err != nil {
+			return i18n.T(lang, "agent.save_failed", err), nil
 		}
-		duration := time.Since(sess.GetStartTime()).Truncate(time.Second)
-		idle := time.Since(sess.GetLastActivity()).Truncate(time.Second)
-		timeoutInfo := "disabled"
-		if !cfg.Agent.SessionTimeout.IsZero() {
-			remaining := cfg.Agent.SessionTimeout.Duration() - idle
-			if remaining < 0 {
-				remaining = 0
+		return i18n.T(lang, "agent.saved", len(transcript), path), nil
+
+	case ":status":
+		sessions := agentMgr.ListSessions(msg.Platform, msg.ChatID)
+		if len(sessions) == 0 {
+			return i18n.T(lang, "agent.none"), nil
+		}
+		var sb strings.Builder
+		for i, sess := range sessions {
+			if i > 0 {
+				sb.WriteString("\n\n")
 			}
-			timeoutInfo = fmt.Sprintf("%s (closes in %s)", cfg.Agent.SessionTimeout.Duration(), remaining.Truncate(time.Second))
+			duration := time.Since(sess.GetStartTime()).Truncate(time.Second)
+			idle := time.Since(sess.GetLastActivity()).Truncate(time.Second)
+			timeoutInfo := i18n.T(lang, "agent.timeout_off")
+			if !cfg.Agent.SessionTimeout.IsZero() {
+				remaining := cfg.Agent.SessionTimeout.Duration() - idle
+				if remaining < 0 {
+					remaining = 0
+				}
+				timeoutInfo = i18n.T(lang, "agent.timeout", cfg.Agent.SessionTimeout.Duration(), remaining.Truncate(time.Second))
+			}
+			if len(sessions) > 1 || sess.Name != agent.DefaultSession {
+				sb.WriteString(i18n.T(lang, "agent.session", sess.Name) + "\n")
+			}
+			sb.WriteString(i18n.T(lang, "agent.status", sess.Agent, sess.Dir, sess.GetMsgCount(), duration, idle, timeoutInfo))
 		}
-		return fmt.Sprintf("Agent: %s\nDirectory: %s\nMessages: %d\nDuration: %s\nIdle: %s\nIdle timeout: %s",
-			sess.Agent, sess.Dir, sess.GetMsgCount(), duration, idle, timeoutInfo), nil
+		return sb.String(), nil
+
+	default:
+		return i18n.T(lang, "agent.unknown", cmd), nil
+	}
+}
 
+// parseAgentNew parses the arguments of ":new [name] [agent] <dir>".
+// A single argument is the directory; two are an agent and directory when
+// the first names an agent, otherwise a session name and directory.
+func parseAgentNew(args []string) (name, agentType, dir string, err error) {
+	name = agent.DefaultSession
+	switch len(args) {
+	case 0:
+		dir = "~"
+	case 1:
+		dir = args[0]
+	case 2:
+		if agent.ValidAgent(args[0]) {
+			agentType, dir = args[0], args[1]
+		} else {
+			name, dir = strings.ToLower(args[0]), args[1]
+		}
 	default:
-		return fmt.Sprintf("Unknown agent command: %s\nAvailable: :new, :quit, :status", cmd), nil
+		if !agent.ValidAgent(args[1]) {
+			return "", "", "", fmt.Errorf("unknown agent %q", args[1])
+		}
+		name, agentType, dir = strings.ToLower(args[0]), args[1], args[2]
+	}
+	if !agent.ValidSessionName(name) {
+		return "", "", "", fmt.Errorf("invalid session name %q (use lowercase letters, digits, - and _)", name)
+	}
+	return name, agentType, dir, nil
+}
+
+// parseAgentSend splits ":send <name> <message>". ok is false when text is
+// not a :send command.
+func parseAgentSend(text string) (name, message string, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || strings.ToLower(fields[0]) != ":send" {
+		return "", "", false
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(text, fields[0]))
+	if len(fields) > 1 {
+		name = strings.ToLower(fields[1])
+		message = strings.TrimSpace(strings.TrimPrefix(rest, fields[1]))
 	}
+	return name, message, true
 }
 
-// routeToAgent sends a regular message to the active agent session.
-func routeToAgent(ctx context.Context, msg *router.Message, agentMgr *agent.Manager, notify func(string), llmRouter *llm.Router, skillsMgr *skills.Manager) (string, error) {
-	sess := agentMgr.GetSession(msg.Platform, msg.ChatID)
+// routeToAgent sends a regular message to the chat's agent session called name.
+// With stream set, output is sent through notify as the agent produces it;
+// otherwise the full output is returned once the agent finishes. Output from
+// named sessions is prefixed with the session name.
+func routeToAgent(ctx context.Context, msg *router.Message, name string, agentMgr *agent.Manager, notify func(string), stream bool, llmRouter *llm.Router, skillsMgr *skills.Manager) (string, error) {
+	sess := agentMgr.GetNamedSession(msg.Platform, msg.ChatID, name)
 	if sess == nil {
 		return "", nil
 	}
+	if name != agent.DefaultSession {
+		send := notify
+		notify = func(text string) { send("[" + name + "] " + text) }
+	}
 
 	templates := agent.DefaultTemplates
 
@@ -1891,9 +3119,23 @@ func routeToAgent(ctx context.Context, msg *router.Message, agentMgr *agent.Mana
 		textSt.MarkSent(len(accumulated))
 	}
 
+	if !stream {
+		onText = nil
+	}
+
 	output, err := agentMgr.Execute(ctx, sess, msg.Text, msg.Media, wrappedNotify, onText, keepalive, agentSkillContext)
 	close(statusDone)
 
+	if !stream {
+		if err != nil {
+			if output != "" {
+				return output + "\n\n⚠️ " + err.Error(), nil
+			}
+			return fmt.Sprintf("Agent error: %v", err), nil
+		}
+		return output, nil
+	}
+
 	// Flush any remaining text that wasn't sent during streaming.
 	if remainder, ok := textSt.FinalText(output); ok {
 		remainder = strings.TrimSpace(remainder)
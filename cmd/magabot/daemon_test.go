@@ -0,0 +1,353 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/session"
+	"github.com/kusa/magabot/internal/skills"
+)
+
+func TestNormalizeCommand(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Bot.Prefix = "/"
+	cfg.Platforms.Discord = &config.DiscordConfig{Prefix: "!"}
+
+	tests := []struct {
+		platform, text string
+		want           string
+		isCommand      bool
+	}{
+		{"discord", "!status", "/status", true},
+		{"discord", "/status", "/status", false},
+		{"discord", "hello", "hello", false},
+		{"telegram", "/status", "/status", true},
+		{"telegram", "!status", "!status", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeCommand(cfg.CommandPrefix(tt.platform), tt.text)
+		if got != tt.want || ok != tt.isCommand {
+			t.Errorf("normalizeCommand(%s, %q) = %q, %v; want %q, %v", tt.platform, tt.text, got, ok, tt.want, tt.isCommand)
+		}
+	}
+
+	// A configured Discord prefix of "/" makes slash commands work there too
+	cfg.Platforms.Discord.Prefix = "/"
+	if _, ok := normalizeCommand(cfg.CommandPrefix("discord"), "/status"); !ok {
+		t.Error("/status should be a command when discord.prefix is /")
+	}
+}
+
+func TestUserLanguage(t *testing.T) {
+	cfg := &config.Config{}
+	sessionMgr := session.NewManager(nil, 10, slog.Default())
+	msg := &router.Message{Platform: "telegram", ChatID: "1", UserID: "1"}
+
+	if got := userLanguage(cfg, sessionMgr, msg); got != "en" {
+		t.Errorf("default language = %q, want en", got)
+	}
+	cfg.Bot.Language = "id"
+	if got := userLanguage(cfg, sessionMgr, msg); got != "id" {
+		t.Errorf("bot.language = %q, want id", got)
+	}
+	msg.Locale = "en-GB"
+	if got := userLanguage(cfg, sessionMgr, msg); got != "en" {
+		t.Errorf("client locale = %q, want en", got)
+	}
+
+	// /lang overrides the locale for this user, in every chat
+	if resp := handleLangCommand([]string{"id"}, "en", sessionMgr, msg); !strings.Contains(resp, "Bahasa") {
+		t.Errorf("/lang id reply = %q, want Indonesian", resp)
+	}
+	if got := userLanguage(cfg, sessionMgr, msg); got != "id" {
+		t.Errorf("after /lang id = %q", got)
+	}
+	other := &router.Message{Platform: "telegram", ChatID: "2", UserID: "1", Locale: "en"}
+	if got := userLanguage(cfg, sessionMgr, other); got != "id" {
+		t.Errorf("same user in another chat = %q, want id", got)
+	}
+	other.UserID = "2"
+	if got := userLanguage(cfg, sessionMgr, other); got != "en" {
+		t.Errorf("another user = %q, want en", got)
+	}
+	if resp := handleLangCommand([]string{"xx"}, "id", sessionMgr, msg); !strings.Contains(resp, "tidak didukung") {
+		t.Errorf("/lang xx reply = %q", resp)
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	cfg := &config.Config{}
+	seed := 7
+	cfg.LLM.Stop, cfg.LLM.Seed = []string{"END"}, &seed
+	cfg.LLM.Profiles = map[string]config.LLMProfile{
+		"chat":    {Temperature: 0.8},
+		"precise": {Temperature: 0.2, MaxTokens: 1024, Stop: []string{"---"}},
+	}
+	cfg.Personas.List = []config.Persona{{Name: "extractor", Profile: "precise"}}
+	sessionMgr := session.NewManager(nil, 10, slog.Default())
+	msg := &router.Message{Platform: "telegram", ChatID: "1", UserID: "1"}
+	sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+
+	req := &llm.Request{}
+	resolveParams(cfg, req, chatProfile(nil), 0)
+	if req.Temperature != 0.8 || req.MaxTokens != 0 {
+		t.Errorf("chat profile = %v/%d, want 0.8/0", req.Temperature, req.MaxTokens)
+	}
+	if len(req.Stop) != 1 || req.Stop[0] != "END" || req.Seed == nil || *req.Seed != 7 {
+		t.Errorf("chat profile stop/seed = %q/%v, want the llm defaults", req.Stop, req.Seed)
+	}
+	resolveParams(cfg, req, chatProfile(activePersona(cfg, sessionMgr, sess)), 0)
+	if req.Temperature != 0.2 || req.MaxTokens != 1024 {
+		t.Errorf("persona profile = %v/%d, want 0.2/1024", req.Temperature, req.MaxTokens)
+	}
+	if len(req.Stop) != 1 || req.Stop[0] != "---" || req.Seed == nil || *req.Seed != 7 {
+		t.Errorf("persona profile stop/seed = %q/%v, want its own stop and the default seed", req.Stop, req.Seed)
+	}
+	resolveParams(cfg, req, profileDigest, 0)
+	if req.Temperature != 0 || req.MaxTokens != 0 {
+		t.Errorf("unset profile = %v/%d, want provider defaults", req.Temperature, req.MaxTokens)
+	}
+
+	// structured skill commands get the precise profile, built in when unset
+	skillsMgr := skills.NewManager(t.TempDir())
+	for _, s := range skills.BuiltinSkills() {
+		skillsMgr.AddSkill(s)
+	}
+	if p := messageProfile(skillsMgr, nil, "/summarize the thread"); p != skills.ProfilePrecise {
+		t.Errorf("/summarize profile = %q, want precise", p)
+	}
+	if p := messageProfile(skillsMgr, nil, "summarize the thread"); p != profileChat {
+		t.Errorf("plain text profile = %q, want chat", p)
+	}
+	resolveParams(&config.Config{}, req, messageProfile(skillsMgr, nil, "/math 2+2"), 0)
+	if req.Temperature != 0.2 {
+		t.Errorf("default precise profile temperature = %v, want 0.2", req.Temperature)
+	}
+
+	// /temp overrides the profile's temperature for this chat only
+	if resp := handleTempCommand([]string{"3"}, "en", msg, cfg, sessionMgr); !strings.Contains(resp, "Invalid") {
+		t.Errorf("/temp 3 reply = %q", resp)
+	}
+	handleTempCommand([]string{"1.1"}, "en", msg, cfg, sessionMgr)
+	resolveParams(cfg, req, profileChat, sessionTemperature(sessionMgr, sess))
+	if req.Temperature != 1.1 {
+		t.Errorf("after /temp 1.1 = %v", req.Temperature)
+	}
+	handleTempCommand([]string{"reset"}, "en", msg, cfg, sessionMgr)
+	if resp := handleTempCommand(nil, "en", msg, cfg, sessionMgr); !strings.Contains(resp, "0.2 (precise profile)") {
+		t.Errorf("/temp after reset = %q", resp)
+	}
+}
+
+func TestParseAgentNew(t *testing.T) {
+	tests := []struct {
+		args                 string
+		name, agentType, dir string
+		wantErr              bool
+	}{
+		{"", "default", "", "~", false},
+		{"myproject", "default", "", "myproject", false},
+		{"codex ~/code", "default", "codex", "~/code", false},
+		{"api ~/code/api", "api", "", "~/code/api", false},
+		{"web claude ~/code/web", "web", "claude", "~/code/web", false},
+		{"web gemini ~/code/web", "", "", "", true},
+		{"Bad/Name ~/code", "", "", "", true},
+	}
+	for _, tt := range tests {
+		name, agentType, dir, err := parseAgentNew(strings.Fields(tt.args))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAgentNew(%q) err = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if name != tt.name || agentType != tt.agentType || dir != tt.dir {
+			t.Errorf("parseAgentNew(%q) = (%q, %q, %q), want (%q, %q, %q)", tt.args, name, agentType, dir, tt.name, tt.agentType, tt.dir)
+		}
+	}
+}
+
+func TestParseAgentSend(t *testing.T) {
+	tests := []struct {
+		text          string
+		name, message string
+		ok            bool
+	}{
+		{":send api run the tests", "api", "run the tests", true},
+		{":SEND Web  fix  the  header", "web", "fix  the  header", true},
+		{":send api", "api", "", true},
+		{":send", "", "", true},
+		{":sender x", "", "", false},
+		{"send api hi", "", "", false},
+	}
+	for _, tt := range tests {
+		name, message, ok := parseAgentSend(tt.text)
+		if name != tt.name || message != tt.message || ok != tt.ok {
+			t.Errorf("parseAgentSend(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.text, name, message, ok, tt.name, tt.message, tt.ok)
+		}
+	}
+}
+
+func TestRegisterCustomProvider(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.LLM.Compatible = map[string]*config.LLMProviderConfig{"together": {Enabled: true}}
+	custom := func(name, format, baseURL string) config.CustomProviderConfig {
+		return config.CustomProviderConfig{Name: name, Format: format, LLMProviderConfig: config.LLMProviderConfig{
+			APIKey: "test-key", BaseURL: baseURL, Model: "test-model",
+		}}
+	}
+
+	// No main provider configured: the first available one is selected
+	llmRouter := llm.NewRouter(&llm.Config{Logger: slog.Default()})
+	if err := registerCustomProvider(llmRouter, custom("groq", "", "https://api.groq.com/openai/v1"), cfg); err != nil {
+		t.Fatalf("register groq: %v", err)
+	}
+	if err := registerCustomProvider(llmRouter, custom("proxy", "anthropic", "https://llm.example.com"), cfg); err != nil {
+		t.Fatalf("register proxy: %v", err)
+	}
+	if llmRouter.MainProvider() != "groq" {
+		t.Errorf("main provider = %q, want groq", llmRouter.MainProvider())
+	}
+
+	rejected := []config.CustomProviderConfig{
+		custom("openai", "openai", "https://api.example.com/v1"),   // built-in name
+		custom("together", "openai", "https://api.example.com/v1"), // llm.compatible name
+		custom("groq", "openai", "https://api.example.com/v1"),     // duplicate
+		custom("evil", "openai", "http://169.254.169.254/v1"),      // SSRF
+		custom("odd", "gemini", "https://api.example.com/v1"),      // unknown format
+	}
+	for _, cp := range rejected {
+		if err := registerCustomProvider(llmRouter, cp, cfg); err == nil {
+			t.Errorf("registerCustomProvider(%s, %s) should fail", cp.Name, cp.BaseURL)
+		}
+	}
+
+	providers := llmRouter.Providers()
+	sort.Strings(providers)
+	if got := strings.Join(providers, ","); got != "groq,proxy" {
+		t.Errorf("Providers() = %q, want groq,proxy", got)
+	}
+	if err := llmRouter.SetMain("proxy"); err != nil {
+		t.Errorf("SetMain(proxy): %v", err)
+	}
+}
+
+func TestProviderHostRequest(t *testing.T) {
+	pc := config.LLMProviderConfig{
+		ExtraHeaders: map[string]string{"x-team": "ops"},
+		Organization: "org-1",
+		Project:      "proj-1",
+		Azure:        &config.AzureOpenAIConfig{Endpoint: "https://res.openai.azure.com", Deployment: "gpt", APIVersion: "2024-10-21"},
+	}
+	hr, ok, err := providerHostRequest("openai", pc)
+	if err != nil || !ok {
+		t.Fatalf("providerHostRequest = %v, %v", ok, err)
+	}
+	for name, want := range map[string]string{"X-Team": "ops", "Openai-Organization": "org-1", "Openai-Project": "proj-1"} {
+		if got := hr.Header.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+	if hr.Query.Get("api-version") != "2024-10-21" || hr.APIKeyHeader != "api-key" {
+		t.Errorf("azure request = %+v", hr)
+	}
+
+	if _, ok, err := providerHostRequest("openai", config.LLMProviderConfig{}); ok || err != nil {
+		t.Errorf("empty config = %v, %v; want nothing to apply", ok, err)
+	}
+	for _, bad := range []config.LLMProviderConfig{
+		{ExtraHeaders: map[string]string{"Bad Header": "x"}},
+		{ExtraHeaders: map[string]string{"X-Evil": "a\r\nb"}},
+		{ExtraHeaders: map[string]string{"authorization": "Bearer x"}},
+	} {
+		if _, _, err := providerHostRequest("openai", bad); err == nil {
+			t.Errorf("providerHostRequest(%v) should fail", bad.ExtraHeaders)
+		}
+	}
+	if _, _, err := providerHostRequest("anthropic", pc); err == nil {
+		t.Error("azure on anthropic should fail")
+	}
+}
+
+func TestProviderNetworkOptions(t *testing.T) {
+	opts, client, err := providerNetworkOptions("openai", "", config.LLMProviderConfig{})
+	if err != nil || len(opts) != 0 || client != nil {
+		t.Errorf("no settings = %d opts, client %v, %v; want nothing", len(opts), client, err)
+	}
+
+	// Providers on one host keep clients of their own
+	_, a, err := providerNetworkOptions("a", "https://gw.example.test/a/v1", config.LLMProviderConfig{Proxy: "http://proxy-a:3128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b, err := providerNetworkOptions("b", "https://gw.example.test/b/v1", config.LLMProviderConfig{ExtraHeaders: map[string]string{"X-Team": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == nil || b == nil || a == b {
+		t.Errorf("clients = %p and %p, want one per endpoint", a, b)
+	}
+
+	if _, _, err := providerNetworkOptions("custom", "", config.LLMProviderConfig{Proxy: "http://proxy:3128"}); err == nil {
+		t.Error("a proxy without base_url or a default endpoint should fail")
+	}
+	if _, _, err := providerNetworkOptions("openai", "", config.LLMProviderConfig{Proxy: "ftp://proxy"}); err == nil {
+		t.Error("an invalid proxy should fail")
+	}
+}
+
+func TestAzureBaseURL(t *testing.T) {
+	got, err := azureBaseURL(&config.AzureOpenAIConfig{Endpoint: "https://res.openai.azure.com/", Deployment: "my gpt", APIVersion: "2024-10-21"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://res.openai.azure.com/openai/deployments/my%20gpt"; got != want {
+		t.Errorf("azureBaseURL = %q, want %q", got, want)
+	}
+	for _, az := range []config.AzureOpenAIConfig{
+		{Endpoint: "http://res.openai.azure.com", Deployment: "gpt", APIVersion: "v"},
+		{Endpoint: "https://res.openai.azure.com", APIVersion: "v"},
+		{Endpoint: "https://res.openai.azure.com", Deployment: "gpt"},
+	} {
+		if _, err := azureBaseURL(&az); err == nil {
+			t.Errorf("azureBaseURL(%+v) should fail", az)
+		}
+	}
+}
+
+func TestDisabledCommands(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Commands.Disabled = []string{"task", "/memory", ":agent"}
+	sessionMgr := session.NewManager(nil, 10, slog.Default())
+	msg := func(text string) *router.Message {
+		return &router.Message{Platform: "telegram", ChatID: "1", UserID: "1", Text: text}
+	}
+
+	// Disabled commands are answered before dispatch; a nil handler would panic
+	for _, text := range []string{"/task list", "/MEMORY", "/task@mybot"} {
+		got, err := handleCommand(msg(text), nil, nil, nil, cfg, nil, nil, nil, sessionMgr, nil, slog.Default())
+		if err != nil || !strings.Contains(got, "disabled") {
+			t.Errorf("handleCommand(%q) = %q, %v; want it disabled", text, got, err)
+		}
+	}
+	got, err := handleAgentCommand(msg(":new /tmp"), nil, cfg, "en")
+	if err != nil || !strings.Contains(got, ":new is disabled") {
+		t.Errorf("handleAgentCommand(:new) = %q, %v; want it disabled", got, err)
+	}
+
+	help, _ := handleCommand(msg("/help"), nil, nil, nil, cfg, nil, nil, nil, sessionMgr, nil, slog.Default())
+	for _, hidden := range []string{"/task", "/memory", ":new", ":send", ":quit", ":status", "Agent Sessions"} {
+		if strings.Contains(help, hidden) {
+			t.Errorf("help shows disabled %s", hidden)
+		}
+	}
+	if !strings.Contains(help, "/status") || !strings.Contains(help, "\n20. /feedback stats") || strings.Contains(help, "22.") {
+		t.Errorf("help not renumbered after hiding commands:\n%s", help)
+	}
+	if start, _ := handleCommand(msg("/start"), nil, nil, nil, cfg, nil, nil, nil, sessionMgr, nil, slog.Default()); strings.Contains(start, "/memory") || !strings.Contains(start, "\n1. 💬 Chat") {
+		t.Errorf("start text = %q, want /memory hidden and its list intact", start)
+	}
+}
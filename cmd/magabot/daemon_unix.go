@@ -3,21 +3,31 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 
+	"github.com/kusa/magabot/internal/logrotate"
 	"github.com/kusa/magabot/internal/router"
 )
 
 func registerSignals(sigCh chan<- os.Signal) {
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
 }
 
-func handleReloadSignal(sig os.Signal, rtr *router.Router, logger *slog.Logger) bool {
+// handleReloadSignal restarts the daemon on SIGHUP. The log file is
+// reopened first, so after an external rotation the restart is logged to
+// the new file.
+func handleReloadSignal(sig os.Signal, rtr *router.Router, logger *slog.Logger, logFile *logrotate.Writer) bool {
 	if sig == syscall.SIGHUP {
+		if logFile != nil {
+			if err := logFile.Reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "reopen log file: %v\n", err)
+			}
+		}
 		logger.Info("SIGHUP received, restarting...")
 		rtr.Stop()
 
@@ -37,11 +47,21 @@ func handleReloadSignal(sig os.Signal, rtr *router.Router, logger *slog.Logger)
 		args := []string{canonical, "daemon"}
 		env := os.Environ()
 
+		// Exec skips deferred calls; let rotated logs finish compressing
+		if logFile != nil {
+			_ = logFile.Close()
+		}
 		if err := syscall.Exec(canonical, args, env); err != nil {
-			logger.Error("restart failed", "error", err)
+			fmt.Fprintf(os.Stderr, "restart failed: %v\n", err)
 			os.Exit(1)
 		}
 		return true
 	}
 	return false
 }
+
+// isTokenReloadSignal reports whether sig asks the daemon to reload webhook
+// bearer tokens (SIGUSR1, sent by 'magabot webhook token').
+func isTokenReloadSignal(sig os.Signal) bool {
+	return sig == syscall.SIGUSR1
+}
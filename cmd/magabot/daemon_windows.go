@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 
+	"github.com/kusa/magabot/internal/logrotate"
 	"github.com/kusa/magabot/internal/router"
 )
 
@@ -14,7 +15,12 @@ func registerSignals(sigCh chan<- os.Signal) {
 	signal.Notify(sigCh, os.Interrupt)
 }
 
-func handleReloadSignal(_ os.Signal, _ *router.Router, _ *slog.Logger) bool {
+func handleReloadSignal(_ os.Signal, _ *router.Router, _ *slog.Logger, _ *logrotate.Writer) bool {
 	// Windows does not support SIGHUP; reload is not available
 	return false
 }
+
+func isTokenReloadSignal(_ os.Signal) bool {
+	// Windows has no SIGUSR1; token changes apply on restart
+	return false
+}
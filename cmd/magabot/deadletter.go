@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/security"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+// deadLetterListLimit caps how many dead letters `deadletter list` shows.
+const deadLetterListLimit = 50
+
+func cmdDeadLetter() {
+	subCmd := "list"
+	if len(os.Args) > 2 {
+		subCmd = os.Args[2]
+	}
+
+	switch subCmd {
+	case "list", "ls":
+		cmdDeadLetterList()
+	case "replay":
+		cmdDeadLetterReplay()
+	case "help":
+		cmdDeadLetterHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown deadletter command: %s\n", subCmd)
+		cmdDeadLetterHelp()
+		os.Exit(1)
+	}
+}
+
+// openDeadLetterStore opens the database and, when an encryption key is
+// configured, the vault needed to read message content.
+func openDeadLetterStore() (*storage.Store, *security.Vault) {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := storage.New(cfg.GetDatabasePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var vault *security.Vault
+	if cfg.Security.EncryptionKey != "" {
+		vault, _ = security.NewVault(cfg.Security.EncryptionKey)
+	}
+	return store, vault
+}
+
+func cmdDeadLetterList() {
+	store, vault := openDeadLetterStore()
+	defer func() { _ = store.Close() }()
+
+	dls, err := store.ListDeadLetters(deadLetterListLimit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(dls) == 0 {
+		fmt.Println("No dead letters. Every message was delivered.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tFAILED AT\tTARGET\tTRIES\tREPLAY\tERROR\tMESSAGE")
+	for _, dl := range dls {
+		replay := "-"
+		if dl.Replay {
+			replay = "queued"
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s:%s\t%d\t%s\t%s\t%s\n",
+			dl.ID,
+			dl.CreatedAt.Local().Format("01/02 15:04"),
+			dl.Platform, dl.ChatID,
+			dl.Attempts,
+			replay,
+			truncateStr(dl.Error, 30),
+			deadLetterPreview(dl.Content, vault),
+		)
+	}
+	_ = w.Flush()
+	fmt.Println("\nUse 'magabot deadletter replay <id|all>' to resend.")
+}
+
+// deadLetterPreview returns the start of a dead letter's message on one line.
+func deadLetterPreview(content string, vault *security.Vault) string {
+	if vault != nil {
+		plain, err := vault.Decrypt(content)
+		if err != nil {
+			return "(encrypted)"
+		}
+		content = string(plain)
+	}
+	return truncateStr(strings.Join(strings.Fields(content), " "), 40)
+}
+
+func cmdDeadLetterReplay() {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: magabot deadletter replay <id|all>")
+		os.Exit(1)
+	}
+
+	var ids []int64
+	if os.Args[3] != "all" {
+		for _, arg := range os.Args[3:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid dead letter ID: %s\n", arg)
+				os.Exit(1)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	store, _ := openDeadLetterStore()
+	defer func() { _ = store.Close() }()
+
+	n, err := store.QueueDeadLetterReplay(ids...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if n == 0 {
+		fmt.Println("No matching dead letters.")
+		return
+	}
+	fmt.Printf("🔁 Queued %d message(s); the daemon resends them within a minute.\n", n)
+	if !isRunning() {
+		fmt.Println("   Magabot is not running; they will be sent on the next start.")
+	}
+}
+
+func cmdDeadLetterHelp() {
+	fmt.Println(`Dead Letters
+
+Messages that could not be delivered after platforms.send_retries attempts.
+
+Usage: magabot deadletter <command>
+
+Commands:
+  list, ls            List undelivered messages (newest first)
+  replay <id...|all>  Queue messages for the running daemon to resend
+  help                Show this help`)
+}
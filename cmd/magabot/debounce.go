@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debounceFlushChars is the message length that ends a chat's batch at
+// once: a message this long is a complete request on its own.
+const debounceFlushChars = 500
+
+// debouncer coalesces messages a user sends in quick succession into one
+// LLM turn, so "can you" / "summarize this" / "thanks" get one answer.
+// Messages are buffered without blocking the platform that delivered them:
+// platforms that dispatch messages one at a time would otherwise deliver
+// nothing else until the window ran out.
+type debouncer struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	batches map[string]*debounceBatch
+}
+
+// debounceBatch is the text a chat has sent since its batch began.
+type debounceBatch struct {
+	texts  []string
+	timer  *time.Timer
+	answer func(text string)
+}
+
+// newDebouncer returns a debouncer waiting window for more messages, or nil
+// when window is zero.
+func newDebouncer(window time.Duration) *debouncer {
+	if window <= 0 {
+		return nil
+	}
+	return &debouncer{window: window, batches: make(map[string]*debounceBatch)}
+}
+
+// add adds text to key's batch and returns at once. When no message has
+// arrived for the window (or the batch is flushed), the answer passed with
+// the batch's first message is called in its own goroutine with the
+// batch's text joined by newlines. A message of debounceFlushChars or more
+// flushes the batch; without one pending it is not buffered, and add
+// returns false for the caller to answer it now.
+func (d *debouncer) add(key, text string, answer func(text string)) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if b, pending := d.batches[key]; pending {
+		b.texts = append(b.texts, text)
+		if len(text) >= debounceFlushChars {
+			d.flushLocked(key, b)
+		} else {
+			b.timer.Reset(d.window)
+		}
+		return true
+	}
+	if len(text) >= debounceFlushChars {
+		return false
+	}
+	b := &debounceBatch{texts: []string{text}, answer: answer}
+	b.timer = time.AfterFunc(d.window, func() { d.end(key, b) })
+	d.batches[key] = b
+	return true
+}
+
+// flush ends key's batch, if any, so it is answered now. Commands and
+// messages with media call it so what came before them doesn't wait out
+// the window.
+func (d *debouncer) flush(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if b, ok := d.batches[key]; ok {
+		d.flushLocked(key, b)
+	}
+}
+
+// end ends batch b of key unless it has ended already, when key may have a
+// new batch.
+func (d *debouncer) end(key string, b *debounceBatch) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.batches[key] == b {
+		d.flushLocked(key, b)
+	}
+}
+
+// flushLocked ends batch b of key and answers it. Caller must hold d.mu.
+func (d *debouncer) flushLocked(key string, b *debounceBatch) {
+	b.timer.Stop()
+	delete(d.batches, key)
+	go b.answer(strings.Join(b.texts, "\n"))
+}
+
+// debouncedKey marks the context of a batch being answered, so the
+// handler doesn't buffer it again.
+type debouncedKey struct{}
+
+func withDebounced(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debouncedKey{}, true)
+}
+
+func isDebounced(ctx context.Context) bool {
+	v, _ := ctx.Value(debouncedKey{}).(bool)
+	return v
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collect returns an answer func for debouncer.add and where its text
+// arrives.
+func collect() (func(string), <-chan string) {
+	out := make(chan string, 1)
+	return func(text string) { out <- text }, out
+}
+
+// recv waits for a batch's answer.
+func recv(t *testing.T, answers <-chan string) string {
+	t.Helper()
+	select {
+	case text := <-answers:
+		return text
+	case <-time.After(time.Second):
+		t.Fatal("batch never answered")
+		return ""
+	}
+}
+
+func TestDebouncerCoalesces(t *testing.T) {
+	d := newDebouncer(50 * time.Millisecond)
+	answer, first := collect()
+	if !d.add("telegram:1:1", "can you", answer) {
+		t.Fatal("the first message should be buffered")
+	}
+	for _, text := range []string{"summarize this", "thanks"} {
+		if !d.add("telegram:1:1", text, func(string) { t.Error("only the first message's answer is used") }) {
+			t.Fatalf("add(%q) should join the pending batch", text)
+		}
+	}
+	// Another chat gets its own batch
+	answerOther, other := collect()
+	d.add("telegram:2:2", "hello", answerOther)
+
+	if got := recv(t, first); got != "can you\nsummarize this\nthanks" {
+		t.Errorf("batch = %q", got)
+	}
+	if got := recv(t, other); got != "hello" {
+		t.Errorf("other chat = %q", got)
+	}
+}
+
+func TestDebouncerSynchronousDispatch(t *testing.T) {
+	// Slack and WhatsApp hand messages over one at a time, each after the
+	// previous handler returned; buffering must not hold the dispatcher up
+	window := 100 * time.Millisecond
+	d := newDebouncer(window)
+	answer, answers := collect()
+	handler := func(key, text string) string {
+		if d.add(key, text, answer) {
+			return ""
+		}
+		return text
+	}
+
+	start := time.Now()
+	for _, m := range []struct{ key, text string }{
+		{"slack:c:u", "can you"}, {"slack:c2:u2", "hi"}, {"slack:c:u", "summarize this"}, {"slack:c:u", "thanks"},
+	} {
+		if reply := handler(m.key, m.text); reply != "" {
+			t.Errorf("%q answered at once: %q", m.text, reply)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= window {
+		t.Fatalf("dispatching 4 messages took %s, the handler must not wait out the window", elapsed)
+	}
+
+	got := map[string]bool{recv(t, answers): true, recv(t, answers): true}
+	if !got["can you\nsummarize this\nthanks"] || !got["hi"] {
+		t.Errorf("batches = %v", got)
+	}
+}
+
+func TestDebouncerFlushTriggers(t *testing.T) {
+	d := newDebouncer(time.Hour)
+
+	// A long message joins the batch and flushes it
+	answer, first := collect()
+	d.add("k", "look at this", answer)
+	long := strings.Repeat("x", debounceFlushChars)
+	if !d.add("k", long, answer) {
+		t.Error("a long message should join the pending batch")
+	}
+	if got := recv(t, first); got != "look at this\n"+long {
+		t.Errorf("batch = %q", got)
+	}
+
+	// A long message without a batch is answered by the caller
+	if d.add("k", long, answer) {
+		t.Error("a long message without a batch should not be buffered")
+	}
+
+	// A command flushes the batch
+	d.add("k", "hi", answer)
+	d.flush("k")
+	if got := recv(t, first); got != "hi" {
+		t.Errorf("batch = %q", got)
+	}
+}
+
+func TestDebouncedContext(t *testing.T) {
+	if isDebounced(context.Background()) {
+		t.Error("a plain context is not a batch's")
+	}
+	if !isDebounced(withDebounced(context.Background())) {
+		t.Error("withDebounced should mark the context")
+	}
+}
+
+func TestNewDebouncerDisabled(t *testing.T) {
+	if d := newDebouncer(0); d != nil {
+		t.Error("a zero window should disable debouncing")
+	}
+}
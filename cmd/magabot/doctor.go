@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/llm"
+)
+
+// doctorTimeout bounds `magabot doctor`'s probes of all providers.
+const doctorTimeout = 30 * time.Second
+
+// cmdDoctor probes every configured LLM provider once, the way the daemon
+// does every llm.health_check_interval, and exits 1 if any fails:
+// magabot doctor
+func cmdDoctor() {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	if secretsMgr := loadSecrets(cfg, logger); secretsMgr != nil {
+		defer secretsMgr.Stop()
+	}
+
+	llmRouter := llm.NewRouter(&llm.Config{Main: cfg.LLM.Main, Logger: logger})
+	registerLLMProviders(llmRouter, cfg, logger)
+	if len(llmRouter.Providers()) == 0 {
+		fmt.Fprintln(os.Stderr, "No LLM providers are configured. Run 'magabot setup llm' first.")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	health := llmRouter.ProbeHealth(ctx)
+	report, ok := renderDoctor(llmRouter.Providers(), health, llmRouter.MainProvider())
+	fmt.Print(report)
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// renderDoctor formats the probe results of providers as a table, the main
+// provider marked. ok is false when any failed or wasn't probed in time;
+// providers with no free probe are listed but don't count.
+func renderDoctor(providers []string, health map[string]llm.ProviderHealth, main string) (report string, ok bool) {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PROVIDER\tSTATUS\tLATENCY\tERROR")
+	ok = true
+	for _, name := range providers {
+		label := name
+		if name == main {
+			label += " (main)"
+		}
+		ph, probed := health[name]
+		switch {
+		case !probed:
+			ok = false
+			_, _ = fmt.Fprintf(w, "%s\t⏱  not checked\t-\tprobe timed out\n", label)
+		case ph.NoProbe:
+			_, _ = fmt.Fprintf(w, "%s\t➖ skipped\t-\tno free health check\n", label)
+		case !ph.OK:
+			ok = false
+			errText := "-"
+			if ph.Error != nil {
+				errText = oneLine(ph.Error.Error(), benchPreviewLen)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t❌ failing\t%s\t%s\n", label, ph.Latency.Round(time.Millisecond), errText)
+		default:
+			_, _ = fmt.Fprintf(w, "%s\t✅ ok\t%s\t-\n", label, ph.Latency.Round(time.Millisecond))
+		}
+	}
+	_ = w.Flush()
+	return b.String(), ok
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/llm"
+)
+
+func TestRenderDoctor(t *testing.T) {
+	health := map[string]llm.ProviderHealth{
+		"anthropic": {OK: true, Latency: 120 * time.Millisecond},
+		"openai":    {Error: errors.New("401 unauthorized")},
+	}
+	report, ok := renderDoctor([]string{"anthropic", "openai"}, health, "anthropic")
+	if ok {
+		t.Error("ok with a failing provider")
+	}
+	for _, want := range []string{"anthropic (main)", "✅ ok", "120ms", "❌ failing", "401 unauthorized"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+
+	if report, ok := renderDoctor([]string{"anthropic", "ollama"}, health, ""); ok || !strings.Contains(report, "not checked") {
+		t.Errorf("unprobed provider: ok=%v\n%s", ok, report)
+	}
+	if _, ok := renderDoctor([]string{"anthropic"}, health, ""); !ok {
+		t.Error("not ok with every provider healthy")
+	}
+	health["custom"] = llm.ProviderHealth{OK: true, NoProbe: true}
+	if report, ok := renderDoctor([]string{"anthropic", "custom"}, health, ""); !ok || !strings.Contains(report, "skipped") {
+		t.Errorf("provider without a probe: ok=%v\n%s", ok, report)
+	}
+}
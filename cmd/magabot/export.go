@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/agent"
+	"github.com/kusa/magabot/internal/bot"
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/i18n"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/session"
+	"github.com/kusa/magabot/internal/util"
+)
+
+// conversationExport is the --json transcript format.
+type conversationExport struct {
+	Platform   string            `json:"platform"`
+	ChatID     string            `json:"chat_id"`
+	ThreadID   string            `json:"thread_id,omitempty"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Messages   []session.Message `json:"messages"`
+}
+
+// handleExportCommand handles "/export [--json]": it writes the session
+// transcript to the exports directory and sends it as a file. With message
+// redaction on, only admins may export, after confirming.
+func handleExportCommand(args []string, lang string, msg *router.Message, rtr *router.Router, cfg *config.Config, sessionMgr *session.Manager, confirmMgr *bot.ConfirmationManager, logger *slog.Logger) string {
+	asJSON := false
+	for _, a := range args {
+		switch strings.ToLower(a) {
+		case "--json", "json":
+			asJSON = true
+		default:
+			return i18n.T(lang, "export.usage")
+		}
+	}
+
+	sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+	history := sessionMgr.GetHistory(sess, 0)
+	if len(history) == 0 {
+		return i18n.T(lang, "export.empty")
+	}
+
+	export := func() (string, error) {
+		exp := conversationExport{
+			Platform:   msg.Platform,
+			ChatID:     msg.ChatID,
+			ThreadID:   msg.ThreadID,
+			ExportedAt: time.Now(),
+			Messages:   history,
+		}
+		name, data, err := renderExport(&exp, asJSON)
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(cfg.Paths.ExportsDir, name)
+		if err := os.MkdirAll(cfg.Paths.ExportsDir, 0700); err != nil {
+			return "", fmt.Errorf("create exports dir: %w", err)
+		}
+		if err := writeExportFile(path, data); err != nil {
+			return "", fmt.Errorf("write export: %w", err)
+		}
+		if err := rtr.SendFile(msg.Platform, msg.ChatID, name, data); err != nil {
+			logger.Warn("send export failed", "platform", msg.Platform, "error", err)
+			return i18n.T(lang, "export.send_failed", len(history), path, err), nil
+		}
+		return i18n.T(lang, "export.done", len(history)), nil
+	}
+
+	if !cfg.Logging.RedactMessages {
+		resp, err := export()
+		if err != nil {
+			return i18n.T(lang, "export.failed", err)
+		}
+		return resp
+	}
+
+	if !cfg.IsPlatformAdmin(msg.Platform, msg.UserID) {
+		return i18n.T(lang, "export.admin_only")
+	}
+	prompt := confirmMgr.Request(
+		msg.Platform, msg.ChatID, msg.UserID,
+		i18n.T(lang, "export.confirm", len(history)),
+		2*time.Minute,
+		export,
+	)
+	return msg.Respond(router.Reply{Text: prompt, Buttons: confirmButtons})
+}
+
+// writeExportFile creates path and writes data to it, refusing to
+// overwrite an existing export.
+func writeExportFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportNameUnsafe matches what may not appear in a part of an export
+// file name.
+var exportNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// renderExport returns the export file name and contents. The name holds
+// the platform, chat and thread, so chats exporting in the same second
+// don't collide in the shared exports directory.
+func renderExport(exp *conversationExport, asJSON bool) (string, []byte, error) {
+	parts := []string{"conversation", exp.Platform, exp.ChatID}
+	if exp.ThreadID != "" {
+		parts = append(parts, exp.ThreadID)
+	}
+	for i, p := range parts {
+		parts[i] = strings.Trim(exportNameUnsafe.ReplaceAllString(p, "_"), ".")
+	}
+	base := strings.Join(append(parts, exp.ExportedAt.Format("20060102-150405")), "-")
+	if asJSON {
+		data, err := json.MarshalIndent(exp, "", "  ")
+		if err != nil {
+			return "", nil, fmt.Errorf("encode export: %w", err)
+		}
+		return base + ".json", data, nil
+	}
+	return base + ".md", []byte(renderExportMarkdown(exp)), nil
+}
+
+// renderExportMarkdown renders a transcript with per-turn metadata.
+func renderExportMarkdown(exp *conversationExport) string {
+	var in, out int
+	for _, m := range exp.Messages {
+		in += m.InputTokens
+		out += m.OutputTokens
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Conversation export\n\n")
+	fmt.Fprintf(&sb, "- Platform: %s\n", exp.Platform)
+	fmt.Fprintf(&sb, "- Chat: %s\n", exp.ChatID)
+	if exp.ThreadID != "" {
+		fmt.Fprintf(&sb, "- Thread: %s\n", exp.ThreadID)
+	}
+	fmt.Fprintf(&sb, "- Exported: %s\n", exp.ExportedAt.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "- Messages: %d\n", len(exp.Messages))
+	if in > 0 || out > 0 {
+		fmt.Fprintf(&sb, "- Tokens: %d in / %d out\n", in, out)
+	}
+
+	for _, m := range exp.Messages {
+		who := "👤 User"
+		if m.Role == "assistant" {
+			who = "🤖 Assistant"
+		}
+		meta := []string{m.Timestamp.Format("2006-01-02 15:04:05")}
+		if m.Model != "" {
+			meta = append(meta, m.Model)
+		}
+		if m.InputTokens > 0 || m.OutputTokens > 0 {
+			meta = append(meta, fmt.Sprintf("%d in / %d out tokens", m.InputTokens, m.OutputTokens))
+		}
+		fmt.Fprintf(&sb, "\n---\n\n### %s — %s\n\n%s\n", who, strings.Join(meta, " · "), m.Content)
+	}
+	return sb.String()
+}
+
+// agentRecordDir returns where agent sessions are recorded, or "" when
+// agent.record is off.
+func agentRecordDir(cfg *config.Config) string {
+	if !cfg.Agent.Record {
+		return ""
+	}
+	return filepath.Join(cfg.Paths.ExportsDir, "agent-sessions")
+}
+
+// agentRedact returns how agent transcripts are redacted: API keys and
+// tokens are masked unless agent.redact_transcripts is off.
+func agentRedact(cfg *config.Config) func(string) string {
+	if cfg.Agent.RedactTranscripts != nil && !*cfg.Agent.RedactTranscripts {
+		return nil
+	}
+	return util.SanitizeErrorMessage
+}
+
+// saveAgentTranscript writes an agent session's transcript to the exports
+// directory (":save") and returns the file's path.
+func saveAgentTranscript(dir string, sess *agent.Session, transcript []agent.TranscriptEntry) (string, error) {
+	now := time.Now()
+	var sb strings.Builder
+	sb.WriteString("# Agent session transcript\n\n")
+	fmt.Fprintf(&sb, "- Session: %s\n", sess.Name)
+	fmt.Fprintf(&sb, "- Agent: %s\n", sess.Agent)
+	fmt.Fprintf(&sb, "- Directory: %s\n", sess.Dir)
+	fmt.Fprintf(&sb, "- Started: %s\n", sess.GetStartTime().Format(time.RFC3339))
+	fmt.Fprintf(&sb, "- Exported: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "- Messages: %d\n", len(transcript))
+	for _, e := range transcript {
+		who := "👤 User"
+		if e.Role == agent.RoleAgent {
+			who = "🤖 " + sess.Agent
+		}
+		fmt.Fprintf(&sb, "\n---\n\n### %s — %s\n\n", who, e.Time.Format("2006-01-02 15:04:05"))
+		for _, path := range e.Media {
+			fmt.Fprintf(&sb, "📎 %s\n", path)
+		}
+		if len(e.Media) > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(e.Content + "\n")
+		if e.Error != "" {
+			fmt.Fprintf(&sb, "\n⚠️ %s\n", e.Error)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("create exports dir: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("agent-%s-%s.md", sess.Name, now.Format("20060102-150405")))
+	if err := os.WriteFile(path, []byte(sb.String()), 0600); err != nil {
+		return "", fmt.Errorf("write transcript: %w", err)
+	}
+	return path, nil
+}
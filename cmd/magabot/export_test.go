@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/session"
+)
+
+func TestRenderExport(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	exp := &conversationExport{
+		Platform:   "telegram",
+		ChatID:     "42",
+		ExportedAt: ts,
+		Messages: []session.Message{
+			{Role: "user", Content: "hi", Timestamp: ts},
+			{Role: "assistant", Content: "hello", Timestamp: ts, Model: "claude", InputTokens: 10, OutputTokens: 3},
+		},
+	}
+
+	name, data, err := renderExport(exp, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "conversation-telegram-42-20260102-030405.md" {
+		t.Errorf("name = %q", name)
+	}
+	md := string(data)
+	for _, want := range []string{
+		"- Messages: 2\n",
+		"- Tokens: 10 in / 3 out\n",
+		"### 👤 User — 2026-01-02 03:04:05\n\nhi\n",
+		"### 🤖 Assistant — 2026-01-02 03:04:05 · claude · 10 in / 3 out tokens\n\nhello\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	name, data, err = renderExport(exp, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded conversationExport
+	if err := json.Unmarshal(data, &decoded); err != nil || !strings.HasSuffix(name, ".json") {
+		t.Fatalf("json export %q: %v", name, err)
+	}
+	if len(decoded.Messages) != 2 || decoded.Messages[1].Model != "claude" {
+		t.Errorf("decoded = %+v", decoded)
+	}
+
+	exp.ChatID, exp.ThreadID = "../x@s.whatsapp.net", "1700000000.000100"
+	if name, _, _ := renderExport(exp, false); name != "conversation-telegram-_x_s.whatsapp.net-1700000000.000100-20260102-030405.md" {
+		t.Errorf("name = %q, want the chat and thread sanitised", name)
+	}
+}
+
+func TestWriteExportFileRefusesOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversation.md")
+	if err := writeExportFile(path, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeExportFile(path, []byte("second")); err == nil {
+		t.Error("second write succeeded, want an error")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first" {
+		t.Errorf("file = %q, want the first export kept", data)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/i18n"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+// handleFeedbackCommand handles "/feedback stats".
+func handleFeedbackCommand(args []string, lang string, store *storage.Store, cfg *config.Config) string {
+	if len(args) == 0 || args[0] != "stats" {
+		return i18n.T(lang, "feedback.usage")
+	}
+	stats, err := store.FeedbackStats()
+	if err != nil {
+		return i18n.T(lang, "error", err)
+	}
+	out := formatFeedbackStats(lang, stats)
+	if !cfg.Platforms.Feedback {
+		out += "\n\n" + i18n.T(lang, "feedback.off")
+	}
+	return out
+}
+
+// formatFeedbackStats renders satisfaction per provider/model in lang.
+func formatFeedbackStats(lang string, stats []storage.FeedbackStat) string {
+	if len(stats) == 0 {
+		return i18n.T(lang, "feedback.none")
+	}
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "feedback.title") + "\n")
+	for _, st := range stats {
+		rated := st.Up + st.Down
+		fmt.Fprintf(&sb, "\n• %s/%s — 👍 %d · 👎 %d", st.Provider, st.Model, st.Up, st.Down)
+		if rated > 0 {
+			sb.WriteString(" " + i18n.T(lang, "feedback.satisfied", st.Up*100/rated))
+		}
+		sb.WriteString("\n  " + i18n.T(lang, "feedback.ratings", rated, st.Responses))
+	}
+	return sb.String()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kusa/magabot/internal/storage"
+)
+
+func TestFormatFeedbackStats(t *testing.T) {
+	if got := formatFeedbackStats("en", nil); !strings.Contains(got, "No rated answers") {
+		t.Errorf("empty stats = %q", got)
+	}
+
+	got := formatFeedbackStats("en", []storage.FeedbackStat{
+		{Provider: "anthropic", Model: "claude", Responses: 10, Up: 3, Down: 1},
+		{Provider: "openai", Model: "gpt", Responses: 2},
+	})
+	for _, want := range []string{
+		"anthropic/claude — 👍 3 · 👎 1 (75% satisfied)",
+		"4 rating(s) on 10 answer(s)",
+		"openai/gpt — 👍 0 · 👎 0\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
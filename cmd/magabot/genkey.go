@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kusa/magabot/internal/security"
+	"golang.org/x/term"
+)
+
+const (
+	genKeyUsage     = "Usage: magabot genkey [--format base64|hex|raw] [--length <bytes>] [--from-passphrase [--salt <hex>]]"
+	minGenKeyLength = 16
+	maxGenKeyLength = 1024
+)
+
+// genKeyOptions are the parsed `magabot genkey` flags.
+type genKeyOptions struct {
+	format         string // base64, hex or raw
+	length         int    // key bytes
+	fromPassphrase bool
+	salt           []byte // with fromPassphrase; nil = new random salt
+}
+
+// cmdGenKey generates a random key, or derives one from a passphrase:
+// magabot genkey [--format base64|hex|raw] [--length <bytes>] [--from-passphrase [--salt <hex>]]
+func cmdGenKey() {
+	opts, err := parseGenKeyArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n\n%s\n", err, genKeyUsage)
+		os.Exit(1)
+	}
+
+	var key []byte
+	if opts.fromPassphrase {
+		newSalt := opts.salt == nil
+		if newSalt {
+			opts.salt = make([]byte, security.SaltSize)
+			if _, err := rand.Read(opts.salt); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+				os.Exit(1)
+			}
+		}
+		passphrase, err := readPassphrase(newSalt)
+		if err == nil {
+			key, err = security.DeriveKey(passphrase, opts.salt, opts.length)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		key = make([]byte, opts.length)
+		if _, err := rand.Read(key); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// security.encryption_key takes base64; check the key works as one
+	usable := len(key) == security.KeySize
+	if usable {
+		if _, err := security.NewVault(base64.StdEncoding.EncodeToString(key)); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Generated key rejected: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Raw bytes go to stdout alone, so they can be redirected to a file
+	notes := os.Stdout
+	if opts.format == "raw" {
+		notes = os.Stderr
+		_, _ = os.Stdout.Write(key)
+	} else {
+		fmt.Printf("Generated key: %s\n", encodeKey(key, opts.format))
+	}
+	if opts.fromPassphrase {
+		salt := hex.EncodeToString(opts.salt)
+		fmt.Fprintf(notes, "Salt: %s\n", salt)
+		fmt.Fprintf(notes, "Derive the same key elsewhere with: magabot genkey --from-passphrase --salt %s%s\n", salt, genKeyFlags(opts))
+	}
+	switch {
+	case usable && opts.format == "base64":
+		fmt.Fprintln(notes, "Add this to your config.yaml under security.encryption_key")
+	case usable:
+		fmt.Fprintln(notes, "security.encryption_key takes this key in base64 (--format base64)")
+	default:
+		fmt.Fprintf(notes, "⚠️  security.encryption_key needs a %d-byte key; this one is %d bytes\n", security.KeySize, len(key))
+	}
+}
+
+// parseGenKeyArgs reads --format, --length, --from-passphrase and --salt,
+// in --flag value or --flag=value form.
+func parseGenKeyArgs(args []string) (genKeyOptions, error) {
+	opts := genKeyOptions{format: "base64", length: security.KeySize}
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch name {
+		case "--from-passphrase":
+			opts.fromPassphrase = true
+			continue
+		case "--format", "--length", "--salt":
+		default:
+			return opts, fmt.Errorf("unknown argument %q", args[i])
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "--format":
+			switch value {
+			case "base64", "hex", "raw":
+				opts.format = value
+			default:
+				return opts, fmt.Errorf("unknown format %q (want base64, hex or raw)", value)
+			}
+		case "--length":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < minGenKeyLength || n > maxGenKeyLength {
+				return opts, fmt.Errorf("invalid length %q (want %d-%d bytes)", value, minGenKeyLength, maxGenKeyLength)
+			}
+			opts.length = n
+		case "--salt":
+			salt, err := hex.DecodeString(strings.TrimSpace(value))
+			if err != nil || len(salt) < security.SaltSize {
+				return opts, fmt.Errorf("invalid salt (want at least %d bytes in hex)", security.SaltSize)
+			}
+			opts.salt = salt
+		}
+	}
+	if opts.salt != nil && !opts.fromPassphrase {
+		return opts, errors.New("--salt needs --from-passphrase")
+	}
+	return opts, nil
+}
+
+// encodeKey renders key in format, base64 or hex.
+func encodeKey(key []byte, format string) string {
+	if format == "hex" {
+		return hex.EncodeToString(key)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// genKeyFlags returns the non-default flags of opts other than the salt,
+// for the command that derives the same key again.
+func genKeyFlags(opts genKeyOptions) string {
+	var flags string
+	if opts.format != "base64" {
+		flags += " --format " + opts.format
+	}
+	if opts.length != security.KeySize {
+		flags += " --length " + strconv.Itoa(opts.length)
+	}
+	return flags
+}
+
+// readPassphrase reads a passphrase without echo from the terminal, asking
+// twice when confirm is set, or reads the first line of piped stdin.
+func readPassphrase(confirm bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("no passphrase on stdin")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(passphrase) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return string(passphrase), nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kusa/magabot/internal/security"
+)
+
+func TestParseGenKeyArgs(t *testing.T) {
+	opts, err := parseGenKeyArgs(nil)
+	if err != nil || opts.format != "base64" || opts.length != security.KeySize || opts.fromPassphrase || opts.salt != nil {
+		t.Errorf("defaults = %+v, %v", opts, err)
+	}
+
+	opts, err = parseGenKeyArgs([]string{"--format", "hex", "--length=64", "--from-passphrase", "--salt", "00112233445566778899aabbccddeeff"})
+	if err != nil || opts.format != "hex" || opts.length != 64 || !opts.fromPassphrase || len(opts.salt) != 16 {
+		t.Errorf("parsed = %+v, %v", opts, err)
+	}
+	if flags := genKeyFlags(opts); flags != " --format hex --length 64" {
+		t.Errorf("genKeyFlags = %q", flags)
+	}
+
+	for _, args := range [][]string{
+		{"--format", "pem"},
+		{"--length", "8"},
+		{"--length=many"},
+		{"--length"},
+		{"--from-passphrase", "--salt", "0011"},
+		{"--salt", "00112233445566778899aabbccddeeff"},
+		{"--verbose"},
+	} {
+		if _, err := parseGenKeyArgs(args); err == nil {
+			t.Errorf("parseGenKeyArgs(%q) should fail", args)
+		}
+	}
+}
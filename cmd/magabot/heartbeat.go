@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/kusa/magabot/internal/bot"
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/heartbeat"
+	"github.com/kusa/magabot/internal/router"
+)
+
+// startHeartbeat runs the periodic checks (heartbeat.enabled) and posts
+// their alerts to heartbeat.targets. The alerts are proactive, so they go
+// through rtr.Notify and wait out quiet hours.
+func startHeartbeat(cfg *config.Config, rtr *router.Router, logger *slog.Logger) *heartbeat.Service {
+	hc := cfg.Heartbeat
+	if !hc.Enabled {
+		return nil
+	}
+	if len(hc.Targets) == 0 {
+		logger.Warn("heartbeat enabled without targets, alerts go nowhere")
+	}
+	svc := heartbeat.NewService(hc.Interval.Duration(), rtr.Notify)
+	for _, t := range hc.Targets {
+		svc.AddTarget(t.Platform, t.ChatID)
+	}
+	bot.NewHeartbeatHandler(svc).RegisterDefaultChecks()
+	svc.Start()
+	return svc
+}
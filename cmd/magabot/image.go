@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/llm"
+)
+
+// maxDecodePixels bounds the images decoded for downscaling, so a small
+// file claiming huge dimensions cannot exhaust memory.
+const maxDecodePixels = 50_000_000
+
+// scaledJPEGQuality is the quality of downscaled images.
+const scaledJPEGQuality = 85
+
+var (
+	errNotImage      = errors.New("not a valid image")
+	errImageTooLarge = errors.New("image too large")
+)
+
+// isImageFile reports whether path has an image extension.
+func isImageFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// sniffImage returns the MIME type detected from the file's content, or
+// errNotImage when the content is not an image.
+func sniffImage(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	mime := http.DetectContentType(head[:n])
+	if !strings.HasPrefix(mime, "image/") {
+		return "", errNotImage
+	}
+	return mime, nil
+}
+
+// loadImage reads an image checked by prepareImage for the LLM.
+func loadImage(path string) (llm.Image, error) {
+	mime, err := sniffImage(path)
+	if err != nil {
+		return llm.Image{}, err
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path from prepareImage
+	if err != nil {
+		return llm.Image{}, err
+	}
+	return llm.ImageFromBytes(mime, data), nil
+}
+
+// prepareImage checks an image before it is handed to the LLM and returns
+// the path to use. Images over media.max_image_bytes or
+// media.max_image_dimension are re-encoded as a downscaled JPEG next to the
+// original when downscaling is enabled; otherwise (or when that is still
+// too big) it returns errImageTooLarge.
+func prepareImage(path string, media config.MediaConfig) (string, error) {
+	mime, err := sniffImage(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	overBytes := media.MaxImageBytes > 0 && info.Size() > media.MaxImageBytes
+	overDimension := false
+	var cfg image.Config
+	decodable := mime != "image/webp" // no WebP decoder in the standard library
+	if decodable {
+		if cfg, err = decodeImageConfig(path); err != nil {
+			return "", errNotImage
+		}
+		overDimension = media.MaxImageDimension > 0 && max(cfg.Width, cfg.Height) > media.MaxImageDimension
+	}
+	if !overBytes && !overDimension {
+		return path, nil
+	}
+	if media.MaxImageDimension <= 0 || !decodable || cfg.Width*cfg.Height > maxDecodePixels {
+		return "", errImageTooLarge
+	}
+
+	scaled, err := downscaleImage(path, media.MaxImageDimension)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(scaled); err == nil && media.MaxImageBytes > 0 && info.Size() > media.MaxImageBytes {
+		_ = os.Remove(scaled)
+		return "", errImageTooLarge
+	}
+	return scaled, nil
+}
+
+func decodeImageConfig(path string) (image.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer func() { _ = f.Close() }()
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg, err
+}
+
+// downscaleImage writes path scaled to fit maxDimension as a JPEG beside it
+// and returns the new file's path.
+func downscaleImage(path string, maxDimension int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	src, _, err := image.Decode(f)
+	_ = f.Close()
+	if err != nil {
+		return "", errNotImage
+	}
+
+	out := scaledImagePath(path)
+	w, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	if err := jpeg.Encode(w, resizeToFit(src, maxDimension), &jpeg.Options{Quality: scaledJPEGQuality}); err != nil {
+		_ = w.Close()
+		_ = os.Remove(out)
+		return "", fmt.Errorf("encode %s: %w", out, err)
+	}
+	return out, w.Close()
+}
+
+// scaledImagePath returns where downscaleImage writes path's scaled copy.
+func scaledImagePath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".scaled.jpg"
+}
+
+// resizeToFit scales src so its longest side is at most maxDimension,
+// averaging the source pixels behind each output pixel. Transparent areas
+// are flattened onto white, since JPEG has no alpha.
+func resizeToFit(src image.Image, maxDimension int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	nw, nh := w, h
+	if longest := max(w, h); longest > maxDimension {
+		nw = max(1, w*maxDimension/longest)
+		nh = max(1, h*maxDimension/longest)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		y0, y1 := b.Min.Y+y*h/nh, b.Min.Y+max((y+1)*h/nh, y*h/nh+1)
+		for x := 0; x < nw; x++ {
+			x0, x1 := b.Min.X+x*w/nw, b.Min.X+max((x+1)*w/nw, x*w/nw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			white := 0xffff - a/n // premultiplied: add white where transparent
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + white),
+				G: uint16(g/n + white),
+				B: uint16(bl/n + white),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
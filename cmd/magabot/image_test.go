@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kusa/magabot/internal/config"
+)
+
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+}
+
+func TestPrepareImage(t *testing.T) {
+	dir := t.TempDir()
+	photo := filepath.Join(dir, "photo.png")
+	writePNG(t, photo, 300, 200)
+
+	// Within limits: used as is
+	got, err := prepareImage(photo, config.MediaConfig{MaxImageBytes: 5 << 20})
+	if err != nil || got != photo {
+		t.Fatalf("prepareImage = %q, %v; want original", got, err)
+	}
+
+	// Over the dimension limit: downscaled to a JPEG
+	got, err = prepareImage(photo, config.MediaConfig{MaxImageBytes: 5 << 20, MaxImageDimension: 100})
+	if err != nil {
+		t.Fatalf("prepareImage downscale: %v", err)
+	}
+	if got != filepath.Join(dir, "photo.scaled.jpg") {
+		t.Errorf("scaled path = %q", got)
+	}
+	f, err := os.Open(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, format, err := image.DecodeConfig(f)
+	_ = f.Close()
+	if err != nil || format != "jpeg" || cfg.Width != 100 || cfg.Height != 66 {
+		t.Errorf("scaled image = %s %dx%d, %v; want jpeg 100x66", format, cfg.Width, cfg.Height, err)
+	}
+
+	// Over the byte limit without downscaling: rejected
+	if _, err := prepareImage(photo, config.MediaConfig{MaxImageBytes: 100}); !errors.Is(err, errImageTooLarge) {
+		t.Errorf("oversized image error = %v, want errImageTooLarge", err)
+	}
+
+	// Extension says image, content does not
+	fake := filepath.Join(dir, "fake.jpg")
+	if err := os.WriteFile(fake, []byte("#!/bin/sh\necho hi\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prepareImage(fake, config.MediaConfig{MaxImageBytes: 5 << 20}); !errors.Is(err, errNotImage) {
+		t.Errorf("fake image error = %v, want errNotImage", err)
+	}
+}
+
+func TestResizeToFit_FlattensTransparency(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4)) // fully transparent
+	out := resizeToFit(src, 2)
+	if b := out.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Fatalf("size = %v, want 2x2", b)
+	}
+	if r, g, b, _ := out.At(0, 0).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Errorf("transparent pixel = %x,%x,%x; want white", r, g, b)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/logrotate"
+)
+
+// newLogHandler builds the daemon's log handler in logging.format (default
+// text on stderr, JSON in logging.file). With a log file it also returns
+// the file's writer, which rotates it per logging.rotate; close it on exit.
+func newLogHandler(lc config.LoggingConfig, opts *slog.HandlerOptions) (slog.Handler, *logrotate.Writer, error) {
+	var out io.Writer = os.Stderr
+	format := cmp.Or(lc.Format, "text")
+	var file *logrotate.Writer
+	if lc.File != "" {
+		var err error
+		file, err = logrotate.New(lc.File, logrotate.Config{
+			MaxSize:  int64(lc.Rotate.MaxSizeMB) << 20,
+			MaxAge:   lc.Rotate.MaxAge.Duration(),
+			Keep:     lc.Rotate.Keep,
+			Compress: lc.Rotate.Compress == nil || *lc.Rotate.Compress,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		out = file
+		format = cmp.Or(lc.Format, "json")
+	}
+
+	switch format {
+	case "json":
+		return slog.NewJSONHandler(out, opts), file, nil
+	case "text":
+		return slog.NewTextHandler(out, opts), file, nil
+	default:
+		if file != nil {
+			_ = file.Close()
+		}
+		return nil, nil, fmt.Errorf("unknown logging.format %q (use json or text)", lc.Format)
+	}
+}
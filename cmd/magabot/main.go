@@ -56,6 +56,10 @@ func main() {
 		cmdRestart()
 	case "status":
 		cmdStatus()
+	case "stats":
+		cmdStats()
+	case "prune":
+		cmdPrune()
 	case "log", "logs":
 		cmdLog()
 	case "init":
@@ -76,6 +80,16 @@ func main() {
 		cmdSkill()
 	case "cron":
 		cmdCron()
+	case "memory":
+		cmdMemory()
+	case "deadletter", "deadletters":
+		cmdDeadLetter()
+	case "webhook":
+		cmdWebhook()
+	case "bench":
+		cmdBench()
+	case "doctor":
+		cmdDoctor()
 	case "qr":
 		cmdQR()
 	case "config":
@@ -98,13 +112,17 @@ Usage: magabot <command>
   stop          Stop magabot daemon
   restart       Restart magabot daemon
   status        Show magabot status
+  stats         Message volume per platform and provider
+  bench         Compare configured LLM providers on one prompt
+  doctor        Check that every configured LLM provider answers
+  prune         Delete data older than storage retention
   log           View logs (tail -f)
   qr            Show WhatsApp QR code for pairing
   init          Quick setup (auto-detects env vars, zero prompts)
   setup         Interactive setup wizard (detailed configuration)
   reset         Reset config (keep platform connections)
   uninstall     Completely uninstall magabot
-  genkey        Generate encryption key
+  genkey        Generate encryption key (--format, --length, --from-passphrase)
   version       Show version
   help          Show this help
 
@@ -112,7 +130,7 @@ Usage: magabot <command>
   update apply                         Download and install update
   update rollback                      Restore previous version
 
-  config show                          Show current configuration
+  config show [--full|--reveal]        Show current configuration
   config edit                          Edit config.yaml
   config admin <platform> add <id>     Add platform admin
   config admin <platform> remove <id>  Remove platform admin
@@ -126,6 +144,20 @@ Usage: magabot <command>
   cron run <id>                        Run job immediately
   cron show <id>                       Show job details
 
+  stats [hour|day|week|month] [count]  Message volume per platform with trend
+
+  bench [--prompt <text>] [--json]     Latency, tokens and cost per provider
+
+  memory import <file> --user <id>     Embed memories (JSON export or paragraphs)
+         [--resume]                    Skip memories already embedded
+
+  deadletter list                      List messages that failed to send
+  deadletter replay <id|all>           Resend failed messages
+
+  webhook token add <user>             Generate a webhook bearer token
+  webhook token list                   List token users and fingerprints
+  webhook token revoke <user>          Revoke a user's webhook tokens
+
   skill list                           List installed skills
   skill info <name>                    Show skill details
   skill create <name>                  Create new skill template
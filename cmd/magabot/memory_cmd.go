@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/embedding"
+	"github.com/kusa/magabot/internal/memory"
+)
+
+const memoryImportUsage = "Usage: magabot memory import <file> --user <id> [--resume]"
+
+// memoryImportOptions are the parsed `magabot memory import` arguments.
+type memoryImportOptions struct {
+	file   string
+	user   string
+	resume bool
+}
+
+// cmdMemory handles `magabot memory` subcommands.
+func cmdMemory() {
+	if len(os.Args) < 3 || os.Args[2] != "import" {
+		fmt.Fprintln(os.Stderr, memoryImportUsage)
+		os.Exit(1)
+	}
+	opts, err := parseMemoryImportArgs(os.Args[3:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n\n%s\n", err, memoryImportUsage)
+		os.Exit(1)
+	}
+	cmdMemoryImport(opts)
+}
+
+// parseMemoryImportArgs reads the file and --user and --resume, in
+// --flag value or --flag=value form.
+func parseMemoryImportArgs(args []string) (memoryImportOptions, error) {
+	var opts memoryImportOptions
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch {
+		case name == "--resume":
+			opts.resume = true
+			continue
+		case name == "--user":
+		case !strings.HasPrefix(args[i], "-") && opts.file == "":
+			opts.file = args[i]
+			continue
+		default:
+			return opts, fmt.Errorf("unknown argument %q", args[i])
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		opts.user = strings.TrimSpace(value)
+	}
+	if opts.file == "" {
+		return opts, fmt.Errorf("no file to import")
+	}
+	if opts.user == "" {
+		return opts, fmt.Errorf("--user is required")
+	}
+	return opts, nil
+}
+
+// readMemoryImport reads the memories in path: a JSON array as written by
+// the memory export, or text with one memory per paragraph.
+func readMemoryImport(path string) ([]*memory.SemanticMemory, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path given by the operator
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var memories []*memory.SemanticMemory
+		if err := json.Unmarshal(data, &memories); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		return memories, nil
+	}
+
+	var memories []*memory.SemanticMemory
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	for _, para := range strings.Split(text, "\n\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			memories = append(memories, &memory.SemanticMemory{Content: para})
+		}
+	}
+	return memories, nil
+}
+
+// cmdMemoryImport embeds a file of memories into a user's semantic memory.
+// Each batch is stored once embedded, so after an interruption or a rate
+// limit, --resume continues with the memories not yet stored.
+func cmdMemoryImport(opts memoryImportOptions) {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	if secretsMgr := loadSecrets(cfg, logger); secretsMgr != nil {
+		defer secretsMgr.Stop()
+	}
+	embedder := newMemoryEmbedder(cfg, logger)
+	if embedder == nil {
+		fmt.Fprintln(os.Stderr, "❌ Memory embedding is not configured (memory.embedding).")
+		os.Exit(1)
+	}
+
+	memories, err := readMemoryImport(opts.file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+	if len(memories) == 0 {
+		fmt.Println("Nothing to import.")
+		return
+	}
+
+	store, err := memory.NewSemanticStore(memory.SemanticConfig{
+		DataDir: cfg.Paths.MemoryDir,
+		UserID:  opts.user,
+		Client:  embedder,
+		Driver:  cfg.Memory.VectorDriver,
+		DSN:     cfg.Memory.VectorDSN,
+		Logger:  logger,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = store.Close() }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("📥 Importing %d memories for %s...\n", len(memories), opts.user)
+	progress, err := store.Import(ctx, memories, opts.resume, func(p embedding.ImportProgress) {
+		fmt.Printf("\r   %d/%d done (%d embedded, %d already stored)", p.Done(), p.Total, p.Embedded, p.Skipped)
+	})
+	fmt.Println()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Import stopped after %d of %d: %v\n", progress.Done(), progress.Total, err)
+		fmt.Fprintf(os.Stderr, "   Continue with: magabot memory import %s --user %s --resume\n", opts.file, opts.user)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Imported %d memories (%d embedded, %d already stored)\n", progress.Total, progress.Embedded, progress.Skipped)
+}
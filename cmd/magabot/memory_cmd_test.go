@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMemoryImportArgs(t *testing.T) {
+	opts, err := parseMemoryImportArgs([]string{"notes.txt", "--user", "42", "--resume"})
+	if err != nil || opts.file != "notes.txt" || opts.user != "42" || !opts.resume {
+		t.Errorf("parsed = %+v, %v", opts, err)
+	}
+	opts, err = parseMemoryImportArgs([]string{"--user=42", "export.json"})
+	if err != nil || opts.file != "export.json" || opts.user != "42" || opts.resume {
+		t.Errorf("parsed = %+v, %v", opts, err)
+	}
+
+	for _, args := range [][]string{
+		{"notes.txt"},
+		{"--user", "42"},
+		{"notes.txt", "--user"},
+		{"notes.txt", "other.txt", "--user", "42"},
+		{"notes.txt", "--user", "42", "--force"},
+	} {
+		if _, err := parseMemoryImportArgs(args); err == nil {
+			t.Errorf("parseMemoryImportArgs(%q) should fail", args)
+		}
+	}
+}
+
+func TestReadMemoryImport(t *testing.T) {
+	dir := t.TempDir()
+
+	text := filepath.Join(dir, "notes.txt")
+	_ = os.WriteFile(text, []byte("First note.\r\n\r\nSecond note\nspans two lines.\n\n\n"), 0o600)
+	memories, err := readMemoryImport(text)
+	if err != nil || len(memories) != 2 || memories[1].Content != "Second note\nspans two lines." {
+		t.Errorf("text import = %+v, %v", memories, err)
+	}
+
+	export := filepath.Join(dir, "export.json")
+	_ = os.WriteFile(export, []byte(`[{"id":"abc","content":"Likes tea","type":"preference"}]`), 0o600)
+	memories, err = readMemoryImport(export)
+	if err != nil || len(memories) != 1 || memories[0].ID != "abc" || memories[0].Type != "preference" {
+		t.Errorf("JSON import = %+v, %v", memories, err)
+	}
+
+	_ = os.WriteFile(export, []byte(`{"content":"not an array"}`), 0o600)
+	if _, err := readMemoryImport(export); err == nil {
+		t.Error("Expected an error for JSON that is not an array")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kusa/magabot/internal/i18n"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/storage"
+	"github.com/kusa/magabot/internal/util"
+)
+
+// modelStatsInterval is how often the daemon saves a snapshot of the LLM
+// model stats.
+const modelStatsInterval = 15 * time.Minute
+
+// modelStatsSaver saves snapshots of the models that served requests since
+// the last one.
+type modelStatsSaver struct {
+	llmRouter *llm.Router
+	store     *storage.Store
+	logger    *slog.Logger
+
+	mu    sync.Mutex
+	saved time.Time
+}
+
+// startModelStatsJob restores the stats saved before the last shutdown and
+// saves a snapshot every modelStatsInterval until ctx is done. Call the
+// returned function on shutdown to save the last one.
+func startModelStatsJob(ctx context.Context, llmRouter *llm.Router, store *storage.Store, logger *slog.Logger) func() {
+	s := &modelStatsSaver{llmRouter: llmRouter, store: store, logger: logger, saved: time.Now()}
+	if snaps, err := store.LatestModelStats(); err != nil {
+		logger.Warn("load model stats failed", "error", err)
+	} else {
+		stats := make([]llm.ModelStat, len(snaps))
+		for i, snap := range snaps {
+			stats[i] = llm.ModelStat{
+				Provider: snap.Provider, Model: snap.Model,
+				Requests: snap.Requests, Errors: snap.Errors,
+				P50: snap.P50, P95: snap.P95,
+				LastError: snap.LastError, LastErrorAt: snap.LastErrorAt,
+				UpdatedAt: snap.TakenAt,
+			}
+			if snap.Requests > 0 {
+				stats[i].ErrorRate = float64(snap.Errors) / float64(snap.Requests)
+			}
+		}
+		llmRouter.RestoreModelStats(stats)
+	}
+
+	go func() {
+		ticker := time.NewTicker(modelStatsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.save()
+			}
+		}
+	}()
+	return s.save
+}
+
+// save stores a snapshot of every model updated since the last save.
+func (s *modelStatsSaver) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var snaps []storage.ModelStatSnapshot
+	for _, st := range s.llmRouter.ModelStats() {
+		if st.UpdatedAt.Before(s.saved) {
+			continue
+		}
+		snaps = append(snaps, storage.ModelStatSnapshot{
+			Provider: st.Provider, Model: st.Model,
+			Requests: st.Requests, Errors: st.Errors,
+			P50: st.P50, P95: st.P95,
+			LastError: st.LastError, LastErrorAt: st.LastErrorAt,
+			TakenAt: now,
+		})
+	}
+	if err := s.store.SaveModelStats(snaps); err != nil {
+		s.logger.Warn("save model stats failed", "error", err)
+		return
+	}
+	s.saved = now
+}
+
+// formatModelStat renders one model's stats as a /status line in lang.
+func formatModelStat(lang, key string, st llm.ModelStat) string {
+	line := i18n.T(lang, "status.model_stat",
+		key, st.P50.Round(10*time.Millisecond), st.P95.Round(10*time.Millisecond), st.ErrorRate*100, st.Requests)
+	if st.LastError != "" {
+		line += i18n.T(lang, "status.model_stat_error", util.TruncateRunes(st.LastError, 60), formatDuration(time.Since(st.LastErrorAt)))
+	}
+	return line
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/i18n"
+	"github.com/kusa/magabot/internal/moderation"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/security"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+// moderator screens chat messages, and optionally answers, under the
+// moderation config. A nil moderator lets everything through.
+type moderator struct {
+	client *moderation.Client
+	cfg    config.ModerationConfig
+	store  *storage.Store
+	logger *slog.Logger
+}
+
+// newModerator builds the moderator, or returns nil when moderation is off.
+// An invalid config is an error rather than moderation quietly turned off.
+func newModerator(cfg *config.Config, store *storage.Store, logger *slog.Logger) (*moderator, error) {
+	mc := cfg.Moderation
+	if !mc.Enabled {
+		return nil, nil
+	}
+	logger = logger.With("component", "moderation")
+	apiKey := mc.APIKey
+	if apiKey == "" && (mc.Provider == "" || mc.Provider == string(moderation.ProviderOpenAI)) {
+		apiKey = cfg.LLM.OpenAI.APIKey
+	}
+	mcfg := moderation.Config{
+		Provider:   moderation.Provider(mc.Provider),
+		APIKey:     apiKey,
+		Model:      mc.Model,
+		BaseURL:    mc.BaseURL,
+		Timeout:    mc.Timeout.Duration(),
+		Thresholds: mc.Thresholds,
+		Logger:     logger,
+	}
+	if err := moderation.ValidateConfig(mcfg); err != nil {
+		return nil, fmt.Errorf("invalid moderation config: %w", err)
+	}
+	if mc.Action != "" && mc.Action != "block" && mc.Action != "flag" {
+		return nil, fmt.Errorf("invalid moderation config: unknown action %q (want block or flag)", mc.Action)
+	}
+	logger.Info("moderation enabled", "provider", mcfg.Provider, "action", mc.Action, "output", mc.Output)
+	return &moderator{client: moderation.NewClient(mcfg), cfg: mc, store: store, logger: logger}, nil
+}
+
+// screensOutput reports whether answers are screened too. Their streamed
+// previews are then suppressed, so nothing unscreened reaches the chat.
+func (m *moderator) screensOutput() bool {
+	return m != nil && m.cfg.Output
+}
+
+// blocks screens text going direction ("input" or "output") in msg's chat
+// and reports whether it must be stopped. Flagged text is audit-logged
+// whether or not it is blocked. When the moderation API fails, text goes
+// through unless fail_closed is set.
+func (m *moderator) blocks(ctx context.Context, msg *router.Message, direction, text string) bool {
+	if m == nil {
+		return false
+	}
+	result, err := m.client.Check(ctx, text)
+	if err != nil {
+		m.logger.Warn("moderation check failed", "direction", direction, "fail_closed", m.cfg.FailClosed, "error", err)
+		return m.cfg.FailClosed
+	}
+	if !result.Flagged {
+		return false
+	}
+
+	block := m.cfg.Action != "flag"
+	action := "moderation_blocked"
+	if !block {
+		action = "moderation_flagged"
+	}
+	categories := strings.Join(result.Categories, ",")
+	m.logger.Info("message flagged by moderation", "platform", msg.Platform, "direction", direction, "categories", categories, "blocked", block)
+	if err := m.store.AuditLog(msg.Platform, security.HashUserID(msg.Platform, msg.UserID), action, direction+": "+categories); err != nil {
+		m.logger.Warn("audit log failed", "error", err)
+	}
+	return block
+}
+
+// reply is what the user gets instead of a blocked message's answer.
+func (m *moderator) reply(lang string) string {
+	if m.cfg.Message != "" {
+		return m.cfg.Message
+	}
+	return i18n.T(lang, "moderation.blocked")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/kusa/magabot/internal/config"
+)
+
+func TestNewModerator(t *testing.T) {
+	cfg := &config.Config{}
+	if m, err := newModerator(cfg, nil, slog.Default()); m != nil || err != nil {
+		t.Fatalf("disabled = (%v, %v), want no moderator", m, err)
+	}
+
+	cfg.Moderation.Enabled = true
+	cfg.Moderation.APIKey = "sk-test"
+	if m, err := newModerator(cfg, nil, slog.Default()); m == nil || err != nil {
+		t.Fatalf("valid config = (%v, %v), want a moderator", m, err)
+	}
+
+	// An enabled moderator with a broken config must stop startup rather
+	// than let everything through
+	for name, broken := range map[string]func(*config.ModerationConfig){
+		"no api key":       func(mc *config.ModerationConfig) { mc.APIKey = "" },
+		"unknown provider": func(mc *config.ModerationConfig) { mc.Provider = "acme" },
+		"unknown action":   func(mc *config.ModerationConfig) { mc.Action = "warn" },
+	} {
+		c := &config.Config{}
+		c.Moderation = cfg.Moderation
+		broken(&c.Moderation)
+		if m, err := newModerator(c, nil, slog.Default()); m != nil || err == nil {
+			t.Errorf("%s = (%v, %v), want an error", name, m, err)
+		}
+	}
+}
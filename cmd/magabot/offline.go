@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/llm"
+)
+
+// Intents the offline responder can answer without an LLM.
+var (
+	offlineHelpIntent   = regexp.MustCompile(`(?i)^\W*(help|menu|commands|what can you do)\b`)
+	offlineStatusIntent = regexp.MustCompile(`(?i)^\W*(status|ping|are you (there|up|online|down|working))\b`)
+)
+
+// llmErrorReply returns the user-facing reply for a failed LLM call. During a
+// provider outage it answers from the offline responder (if enabled) or the
+// configured llm.fallback_message; other errors use llm.FormatError.
+func llmErrorReply(cfg *config.Config, llmRouter *llm.Router, text string, err error) string {
+	if !llm.IsUnavailable(err) {
+		return llm.FormatError(err)
+	}
+	since, _ := llmRouter.Outage()
+
+	if cfg.LLM.OfflineResponder {
+		if reply := offlineReply(text, llmRouter.MainProvider(), since); reply != "" {
+			return reply
+		}
+	}
+	if cfg.LLM.FallbackMessage != "" {
+		return cfg.LLM.FallbackMessage
+	}
+	return llm.FormatError(err)
+}
+
+// offlineReply answers a few built-in intents while no provider is
+// reachable. Returns "" when the message matches none of them.
+func offlineReply(text, provider string, since time.Time) string {
+	switch {
+	case offlineHelpIntent.MatchString(text):
+		return "📖 *Offline mode*\n\nThe AI provider is currently unavailable, so I can't chat right now.\n\n" +
+			"Commands still work:\n• /help — full help\n• /status — bot & provider status\n• /llm — switch provider"
+	case offlineStatusIntent.MatchString(text):
+		down := "just now"
+		if !since.IsZero() {
+			down = "since " + since.Format("15:04") + fmt.Sprintf(" (%s)", time.Since(since).Round(time.Minute))
+		}
+		return fmt.Sprintf("⚠️ I'm running, but the AI provider (%s) is unavailable %s.\nI'll answer normally once it recovers.", strings.TrimSpace(provider), down)
+	}
+	return ""
+}
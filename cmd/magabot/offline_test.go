@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/llm"
+)
+
+func TestLLMErrorReply(t *testing.T) {
+	router := llm.NewRouter(&llm.Config{Main: "none"})
+	outage := fmt.Errorf("%w: provider %q not registered", llm.ErrNoProvider, "none")
+
+	cfg := &config.Config{}
+	if got := llmErrorReply(cfg, router, "hi", outage); got != llm.FormatError(outage) {
+		t.Errorf("no fallback configured: got %q", got)
+	}
+
+	cfg.LLM.FallbackMessage = "Back soon."
+	if got := llmErrorReply(cfg, router, "hi", outage); got != "Back soon." {
+		t.Errorf("fallback message not used: %q", got)
+	}
+	if got := llmErrorReply(cfg, router, "hi", llm.ErrInputTooLong); got != llm.FormatError(llm.ErrInputTooLong) {
+		t.Errorf("request errors should not use the fallback: %q", got)
+	}
+
+	cfg.LLM.OfflineResponder = true
+	if got := llmErrorReply(cfg, router, "help", outage); !strings.Contains(got, "Offline mode") {
+		t.Errorf("help intent not answered offline: %q", got)
+	}
+	if got := llmErrorReply(cfg, router, "status?", outage); !strings.Contains(got, "unavailable") {
+		t.Errorf("status intent not answered offline: %q", got)
+	}
+	if got := llmErrorReply(cfg, router, "write me a poem", outage); got != "Back soon." {
+		t.Errorf("unknown intent should use the fallback message: %q", got)
+	}
+}
+
+func TestOfflineReply_Intents(t *testing.T) {
+	since := time.Now().Add(-10 * time.Minute)
+	for _, text := range []string{"help", "Help me", "what can you do?", "/help"} {
+		if offlineReply(text, "openai", since) == "" {
+			t.Errorf("%q should match the help intent", text)
+		}
+	}
+	if got := offlineReply("are you up?", "openai", since); !strings.Contains(got, "openai") || !strings.Contains(got, "10m") {
+		t.Errorf("status reply missing provider or duration: %q", got)
+	}
+	for _, text := range []string{"helpful tips please", "statistics homework", ""} {
+		if got := offlineReply(text, "openai", since); got != "" {
+			t.Errorf("%q should not match an intent, got %q", text, got)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/i18n"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/plugin"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/session"
+)
+
+// Plugins are compiled in: a plugin package calls plugin.RegisterBuiltin
+// from init and is linked in with a blank import here.
+
+// startPlugins registers the compiled-in plugins (plugins.auto_load) and
+// starts them (plugins.auto_start). The LLM providers they register join
+// llmRouter, so they can be made main with /llm or picked by routing rules
+// like the built-in providers; call it before setupLLMRouting. It returns
+// nil when plugins.enabled is off.
+func startPlugins(cfg *config.Config, rtr *router.Router, llmRouter *llm.Router, logger *slog.Logger) *plugin.Manager {
+	pc := cfg.Plugins
+	if !pc.Enabled {
+		return nil
+	}
+	mgr := plugin.NewManager(plugin.Config{
+		PluginDirs:    pc.Dirs,
+		Allowlist:     pc.Allowlist,
+		Denylist:      pc.Denylist,
+		DataDir:       cfg.Paths.DataDir,
+		MessageSender: rtr,
+		LLMRegistry:   llmRouter,
+		Logger:        logger.With("component", "plugins"),
+	})
+	if !pc.AutoLoad {
+		return mgr
+	}
+	mgr.RegisterBuiltins()
+	if err := mgr.LoadConfig(); err != nil {
+		logger.Warn("load plugin config failed", "error", err)
+	}
+	if pc.AutoStart {
+		if err := mgr.StartAll(); err != nil {
+			logger.Warn("start plugins failed", "error", err)
+		}
+	}
+	return mgr
+}
+
+// handlePluginCommand runs msg's command with the plugin that registered
+// it. ok is false when no plugin did, leaving it to handleCommand.
+func handlePluginCommand(ctx context.Context, mgr *plugin.Manager, msg *router.Message, cfg *config.Config, sessionMgr *session.Manager) (reply string, ok bool, err error) {
+	parts := strings.Fields(msg.Text)
+	if mgr == nil || len(parts) == 0 {
+		return "", false, nil
+	}
+	cmd := strings.ToLower(parts[0])
+	if i := strings.Index(cmd, "@"); i > 0 {
+		cmd = cmd[:i]
+	}
+	name := strings.TrimPrefix(cmd, "/")
+	if !mgr.HasCommand(name) {
+		return "", false, nil
+	}
+
+	lang := userLanguage(cfg, sessionMgr, msg)
+	if cfg.CommandDisabled(cmd) {
+		return i18n.T(lang, "command.disabled", cmd), true, nil
+	}
+	if !cfg.CanRunCommand(msg.Platform, msg.UserID, cmd) {
+		return i18n.T(lang, "command.denied", cmd), true, nil
+	}
+
+	rawArgs := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(msg.Text), parts[0]))
+	reply, err = mgr.HandleCommand(ctx, &plugin.Command{
+		Name:     name,
+		Args:     parts[1:],
+		RawArgs:  rawArgs,
+		Platform: msg.Platform,
+		ChatID:   msg.ChatID,
+		UserID:   msg.UserID,
+		IsAdmin:  cfg.IsPlatformAdmin(msg.Platform, msg.UserID),
+		Message:  msg.Text,
+	})
+	return reply, true, err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/plugin"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/session"
+)
+
+// echoPlugin registers /echo, which replies with its arguments.
+type echoPlugin struct{}
+
+func (echoPlugin) Metadata() plugin.Metadata { return plugin.Metadata{ID: "echo"} }
+
+func (echoPlugin) Init(ctx plugin.Context) error {
+	return ctx.RegisterCommand("echo", func(_ context.Context, cmd *plugin.Command) (string, error) {
+		return cmd.UserID + ": " + cmd.RawArgs, nil
+	})
+}
+
+func (echoPlugin) Start(context.Context) error { return nil }
+func (echoPlugin) Stop(context.Context) error  { return nil }
+
+func TestHandlePluginCommand(t *testing.T) {
+	mgr := plugin.NewManager(plugin.Config{DataDir: t.TempDir()})
+	if err := mgr.Register(echoPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	sessionMgr := session.NewManager(nil, 10, slog.Default())
+	msg := func(text string) *router.Message {
+		return &router.Message{Platform: "telegram", ChatID: "1", UserID: "7", Text: text}
+	}
+
+	reply, ok, err := handlePluginCommand(context.Background(), mgr, msg("/echo@mybot  hello  there"), cfg, sessionMgr)
+	if !ok || err != nil || reply != "7: hello  there" {
+		t.Errorf("/echo = %q, %v, %v; want the plugin's reply", reply, ok, err)
+	}
+	if _, ok, _ := handlePluginCommand(context.Background(), mgr, msg("/status"), cfg, sessionMgr); ok {
+		t.Error("/status went to a plugin that didn't register it")
+	}
+
+	cfg.Commands.Disabled = []string{"echo"}
+	if reply, ok, _ := handlePluginCommand(context.Background(), mgr, msg("/echo hi"), cfg, sessionMgr); !ok || !strings.Contains(reply, "disabled") {
+		t.Errorf("disabled /echo = %q, %v; want it refused", reply, ok)
+	}
+}
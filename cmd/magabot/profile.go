@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/i18n"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/session"
+	"github.com/kusa/magabot/internal/skills"
+)
+
+// Intents that select an llm.profiles entry when nothing more specific does.
+const (
+	profileChat   = "chat"   // ordinary conversation
+	profileDigest = "digest" // llm_digest cron jobs
+)
+
+// maxTemperature is the highest temperature /temp accepts.
+const maxTemperature = 2.0
+
+// chatProfile returns the profile for a chat turn: the persona's, or "chat".
+func chatProfile(persona *config.Persona) string {
+	if persona != nil && persona.Profile != "" {
+		return persona.Profile
+	}
+	return profileChat
+}
+
+// messageProfile returns the profile for a chat turn answering text: that
+// of the skill whose command starts it ("precise" for the built-in
+// /summarize, /translate and /math), else chatProfile's.
+func messageProfile(skillsMgr *skills.Manager, persona *config.Persona, text string) string {
+	if p := skillsMgr.CommandProfile(text); p != "" {
+		return p
+	}
+	return chatProfile(persona)
+}
+
+// resolveParams sets req's max tokens, temperature, stop sequences and seed
+// from the named llm.profiles entry, with a session /temp override taking
+// precedence. Settings left unset fall through to the provider config.
+func resolveParams(cfg *config.Config, req *llm.Request, profile string, sessionTemp float64) {
+	p := cfg.LLMProfile(profile)
+	req.MaxTokens = p.MaxTokens
+	req.Temperature = p.Temperature
+	req.Stop = p.Stop
+	req.Seed = p.Seed
+	if sessionTemp > 0 {
+		req.Temperature = sessionTemp
+	}
+}
+
+// sessionTemperature returns the temperature set with /temp, or 0.
+func sessionTemperature(sessionMgr *session.Manager, sess *session.Session) float64 {
+	t, _ := sessionMgr.GetContext(sess, "temperature").(float64)
+	return t
+}
+
+// resolveReasoning sets req's reasoning effort and thinking budget from the
+// provider's config, with a session /think level taking precedence over both.
+// The router sends them only to models that support them.
+func resolveReasoning(cfg *config.Config, req *llm.Request, provider, sessionEffort string) {
+	if sessionEffort != "" {
+		req.ReasoningEffort = sessionEffort
+		return
+	}
+	pc := cfg.LLM.GetProviderConfig(provider)
+	if pc == nil {
+		return
+	}
+	if llm.ValidEffort(pc.ReasoningEffort) {
+		req.ReasoningEffort = pc.ReasoningEffort
+	}
+	req.ThinkingBudget = pc.ThinkingBudget
+}
+
+// sessionThink returns the reasoning effort set with /think, or "".
+func sessionThink(sessionMgr *session.Manager, sess *session.Session) string {
+	level, _ := sessionMgr.GetContext(sess, "think").(string)
+	return level
+}
+
+// handleThinkCommand handles /think: show, set or reset the chat's reasoning
+// effort.
+func handleThinkCommand(args []string, lang string, msg *router.Message, cfg *config.Config, sessionMgr *session.Manager, provider, model string) string {
+	sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+
+	if len(args) == 0 {
+		current := i18n.T(lang, "setting.provider_default")
+		if level := sessionThink(sessionMgr, sess); level != "" {
+			current = i18n.T(lang, "setting.this_chat", level)
+		} else if pc := cfg.LLM.GetProviderConfig(provider); pc != nil && pc.ThinkingBudget > 0 {
+			current = i18n.T(lang, "think.budget_config", pc.ThinkingBudget)
+		} else if pc != nil && llm.ValidEffort(pc.ReasoningEffort) {
+			current = i18n.T(lang, "setting.config", pc.ReasoningEffort)
+		}
+		reply := i18n.T(lang, "think.current", current)
+		if !llm.SupportsReasoningEffort(model) && !llm.SupportsThinking(model) {
+			reply += "\n\n" + i18n.T(lang, "think.unsupported", model)
+		}
+		return reply
+	}
+
+	level := strings.ToLower(args[0])
+	switch level {
+	case "reset", "off", "default":
+		sessionMgr.SetContext(sess, "think", "")
+		return i18n.T(lang, "think.reset")
+	}
+	if !llm.ValidEffort(level) {
+		return i18n.T(lang, "think.invalid")
+	}
+	sessionMgr.SetContext(sess, "think", level)
+	return i18n.T(lang, "think.set", level)
+}
+
+// handleTempCommand handles /temp: show, set or reset the chat's temperature.
+func handleTempCommand(args []string, lang string, msg *router.Message, cfg *config.Config, sessionMgr *session.Manager) string {
+	sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+
+	if len(args) == 0 {
+		current := i18n.T(lang, "setting.provider_default")
+		if t := sessionTemperature(sessionMgr, sess); t > 0 {
+			current = i18n.T(lang, "setting.this_chat", fmt.Sprintf("%g", t))
+		} else if name := chatProfile(activePersona(cfg, sessionMgr, sess)); cfg.LLMProfile(name).Temperature > 0 {
+			current = i18n.T(lang, "temp.profile", cfg.LLMProfile(name).Temperature, name)
+		}
+		return i18n.T(lang, "temp.current", current, maxTemperature)
+	}
+
+	switch args[0] {
+	case "reset", "off", "default":
+		sessionMgr.SetContext(sess, "temperature", 0.0)
+		return i18n.T(lang, "temp.reset")
+	}
+	t, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || t <= 0 || t > maxTemperature {
+		return i18n.T(lang, "temp.invalid", maxTemperature)
+	}
+	sessionMgr.SetContext(sess, "temperature", t)
+	return i18n.T(lang, "temp.set", t)
+}
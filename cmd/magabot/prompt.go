@@ -0,0 +1,54 @@
+package main
+
+import (
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/llm"
+)
+
+// systemPrompts holds llm.system_prompt and persona prompts, parsed once at
+// startup so template errors surface before the daemon serves messages.
+type systemPrompts struct {
+	base     *llm.PromptTemplate
+	personas map[string]*llm.PromptTemplate
+	botName  string
+}
+
+// parseSystemPrompts parses every configured system prompt template.
+func parseSystemPrompts(cfg *config.Config) (*systemPrompts, error) {
+	base, err := llm.ParsePromptTemplate("llm.system_prompt", cfg.LLM.SystemPrompt)
+	if err != nil {
+		return nil, err
+	}
+	sp := &systemPrompts{
+		base:     base,
+		personas: make(map[string]*llm.PromptTemplate, len(cfg.Personas.List)),
+		botName:  cfg.Bot.Name,
+	}
+	for _, p := range cfg.Personas.List {
+		pt, err := llm.ParsePromptTemplate("persona "+p.Name, p.SystemPrompt)
+		if err != nil {
+			return nil, err
+		}
+		sp.personas[p.Name] = pt
+	}
+	return sp, nil
+}
+
+// expand renders the persona's prompt (or llm.system_prompt when persona is
+// nil) for one request.
+func (s *systemPrompts) expand(persona *config.Persona, userID, platform string) string {
+	vars := llm.NewPromptVars(time.Now(), userID, platform, s.botName)
+	if persona == nil {
+		return s.base.Expand(vars)
+	}
+	if pt, ok := s.personas[persona.Name]; ok {
+		return pt.Expand(vars)
+	}
+	// Persona added after startup: render it if it parses, else use it verbatim
+	if pt, err := llm.ParsePromptTemplate("persona "+persona.Name, persona.SystemPrompt); err == nil {
+		return pt.Expand(vars)
+	}
+	return persona.SystemPrompt
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/storage"
+	"github.com/kusa/magabot/internal/subagent"
+)
+
+// defaultPruneInterval is how often the daemon prunes when
+// storage.retention.interval is unset.
+const defaultPruneInterval = 24 * time.Hour
+
+// pruneCount is how many entries one retention category removed.
+type pruneCount struct {
+	Category string
+	Days     int
+	Removed  int64
+}
+
+// pruneData deletes data older than each category's retention. Categories
+// with retention 0 are kept forever and skipped. On error it returns the
+// counts gathered so far.
+func pruneData(cfg *config.Config, store *storage.Store, logger *slog.Logger) ([]pruneCount, error) {
+	sc := cfg.Storage
+	steps := []struct {
+		category string
+		days     int
+		prune    func(days int) (int64, error)
+	}{
+		{"messages", sc.RetentionDays(sc.Retention.Messages), store.PurgeOldMessages},
+		{"conversations", sc.RetentionDays(sc.Retention.Conversations), store.PurgeOldConversations},
+		{"audit_log", sc.RetentionDays(sc.Retention.AuditLog), store.PurgeOldAuditLogs},
+		{"dead_letters", sc.RetentionDays(sc.Retention.DeadLetters), store.PurgeOldDeadLetters},
+		{"model_stats", sc.RetentionDays(sc.Retention.ModelStats), store.PurgeOldModelStats},
+		{"sessions", sc.RetentionDays(sc.Retention.Sessions), store.PurgeOldSessionContexts},
+		{"subagents", sc.RetentionDays(sc.Retention.SubAgents), func(days int) (int64, error) {
+			return pruneSubAgents(cfg.Paths.DataDir, days, logger)
+		}},
+	}
+
+	var counts []pruneCount
+	for _, step := range steps {
+		if step.days <= 0 {
+			continue
+		}
+		n, err := step.prune(step.days)
+		if err != nil {
+			return counts, fmt.Errorf("prune %s: %w", step.category, err)
+		}
+		counts = append(counts, pruneCount{Category: step.category, Days: step.days, Removed: n})
+	}
+	return counts, nil
+}
+
+// pruneSubAgents removes finished sub-agents persisted in dataDir.
+func pruneSubAgents(dataDir string, days int, logger *slog.Logger) (int64, error) {
+	reg, err := subagent.NewRegistry(subagent.Config{DataDir: dataDir, Logger: logger})
+	if err != nil {
+		return 0, err
+	}
+	return int64(reg.Cleanup(time.Duration(days) * 24 * time.Hour)), nil
+}
+
+// startPruneJob prunes old data now and then every
+// storage.retention.interval until ctx is done.
+func startPruneJob(ctx context.Context, cfg *config.Config, store *storage.Store, logger *slog.Logger) {
+	interval := cfg.Storage.Retention.Interval.Duration()
+	if interval <= 0 {
+		interval = defaultPruneInterval
+	}
+	run := func() {
+		counts, err := pruneData(cfg, store, logger)
+		if err != nil {
+			logger.Warn("prune old data failed", "error", err)
+		}
+		for _, c := range counts {
+			if c.Removed > 0 {
+				logger.Info("pruned old data", "category", c.Category, "older_than_days", c.Days, "removed", c.Removed)
+			}
+		}
+	}
+	go func() {
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+}
+
+// cmdPrune runs the retention job once and compacts the database.
+func cmdPrune() {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := storage.New(cfg.GetDatabasePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = store.Close() }()
+
+	counts, err := pruneData(cfg, store, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	for _, c := range counts {
+		fmt.Printf("🧹 %-14s %6d removed (older than %d days)\n", c.Category, c.Removed, c.Days)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+	if len(counts) == 0 {
+		fmt.Println("Retention is 0 (keep forever) for every category; nothing to prune.")
+		fmt.Println("Set storage.history_retention or storage.retention.<category> in config.yaml.")
+		return
+	}
+	if err := store.Vacuum(); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Vacuum failed: %v\n", err)
+		return
+	}
+	fmt.Println("✅ Database compacted")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+func TestPruneData(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+
+	for _, age := range []int{40, 10} {
+		if err := store.SaveMessage(&storage.Message{Platform: "telegram", ChatID: "c", UserID: "u", Content: "x", Timestamp: time.Now().AddDate(0, 0, -age), Direction: "in"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keep, short := 0, 5
+	cfg := &config.Config{}
+	cfg.Paths.DataDir = dir
+	cfg.Storage.HistoryRetention = 30
+	cfg.Storage.Retention.AuditLog = &keep
+	cfg.Storage.Retention.Conversations = &short
+
+	counts, err := pruneData(cfg, store, slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err != nil {
+		t.Fatalf("pruneData: %v", err)
+	}
+	want := []pruneCount{
+		{Category: "messages", Days: 30, Removed: 1},
+		{Category: "conversations", Days: 5},
+		{Category: "dead_letters", Days: 30},
+		{Category: "model_stats", Days: 30},
+		{Category: "sessions", Days: 30},
+		{Category: "subagents", Days: 30},
+	}
+	if len(counts) != len(want) {
+		t.Fatalf("counts = %+v, want %+v", counts, want)
+	}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Errorf("counts[%d] = %+v, want %+v", i, counts[i], want[i])
+		}
+	}
+
+	cfg.Storage.HistoryRetention = 0
+	cfg.Storage.Retention = config.RetentionConfig{}
+	if counts, _ := pruneData(cfg, store, slog.Default()); len(counts) != 0 {
+		t.Errorf("retention 0 pruned %+v", counts)
+	}
+}
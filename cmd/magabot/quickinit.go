@@ -211,7 +211,7 @@ func buildInitConfig(cfg *envConfig) string {
 	b.WriteString("llm:\n")
 	fmt.Fprintf(&b, "  default: \"%s\"\n", cfg.defaultProvider())
 	b.WriteString("  system_prompt: \"You are a helpful AI assistant. Be concise and friendly.\"\n")
-	b.WriteString("  max_input_length: 10000\n")
+	b.WriteString("  max_input_tokens: 10000\n")
 	b.WriteString("  timeout: 2m\n")
 	b.WriteString("  max_context_chars: 250000\n")
 	b.WriteString("  rate_limit: 10\n\n")
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/cron"
+)
+
+// newQuietHours builds the bot.quiet_hours window, or returns nil when it
+// is unset or invalid.
+func newQuietHours(cfg *config.Config, logger *slog.Logger) *cron.QuietHours {
+	qc := cfg.Bot.QuietHours
+	if qc == nil || (qc.Start == "" && qc.End == "") {
+		return nil
+	}
+	q, err := cron.ParseQuietHours(qc.Start, qc.End, qc.Timezone, qc.Platforms)
+	if err != nil {
+		logger.Warn("quiet hours disabled, invalid config", "error", err)
+		return nil
+	}
+	return q
+}
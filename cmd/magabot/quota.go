@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/cron"
+	"github.com/kusa/magabot/internal/security"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+// newDailyQuota builds the per-user daily quota, or returns nil when
+// security.daily_quota sets no limit. An unknown timezone falls back to
+// local time.
+func newDailyQuota(cfg *config.Config, store *storage.Store, logger *slog.Logger) *security.DailyQuota {
+	qc := cfg.Security.DailyQuota
+	if qc.Messages <= 0 && qc.Tokens <= 0 {
+		return nil
+	}
+	loc := time.Local
+	if qc.Timezone != "" {
+		l, err := time.LoadLocation(cron.ResolveTimezone(qc.Timezone))
+		if err != nil {
+			logger.Warn("invalid daily quota timezone, using local time", "timezone", qc.Timezone, "error", err)
+		} else {
+			loc = l
+		}
+	}
+	return security.NewDailyQuota(int64(qc.Messages), int64(qc.Tokens), loc, store)
+}
@@ -30,6 +30,8 @@ func cmdSetup() {
 	subCmd := strings.ToLower(os.Args[2])
 
 	switch subCmd {
+	case "--non-interactive", "--from-env":
+		runNonInteractiveSetup(os.Args[2:])
 	case "llm":
 		if len(os.Args) > 3 && strings.ToLower(os.Args[3]) == "main" {
 			setupLLMMain()
@@ -52,6 +54,7 @@ func printSetupUsage() {
 	fmt.Println(`Magabot Setup
 
 Usage: magabot setup [target]
+       magabot setup --non-interactive [--answers file.yaml] [--force]
 
 Targets:
   (none)      Run full interactive wizard
@@ -67,7 +70,15 @@ Examples:
   magabot setup llm main   # Switch active LLM provider
   magabot setup platform   # Setup chat platform
   magabot setup webhook    # Setup webhook endpoint
-  magabot setup voice      # Install voice support`)
+  magabot setup voice      # Install voice support
+
+Non-interactive mode (Docker/CI):
+  Reads MAGABOT_<KEY> environment variables, falling back to a flat YAML
+  answers file (e.g. telegram_token, llm_default, openai_api_key). Platforms
+  are enabled by supplying their token; missing required values are listed.
+
+  MAGABOT_TELEGRAM_TOKEN=... MAGABOT_OPENAI_API_KEY=... \
+    magabot setup --non-interactive`)
 }
 
 // setupPlatform asks which platform to configure
@@ -560,6 +571,7 @@ func setupLLMMain() {
 		{"kimi", cfg.LLM.Kimi},
 		{"minimax", cfg.LLM.MiniMax},
 		{"local", cfg.LLM.Local},
+		{"ollama", cfg.LLM.Ollama},
 	}
 	for _, p := range all {
 		if p.cfg.Enabled {
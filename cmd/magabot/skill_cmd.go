@@ -7,6 +7,7 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	"github.com/kusa/magabot/internal/config"
 	"github.com/kusa/magabot/internal/skills"
 )
 
@@ -51,6 +52,12 @@ func cmdSkill() {
 			return
 		}
 		cmdSkillDisable(os.Args[3])
+	case "test":
+		if len(os.Args) < 4 {
+			fmt.Println("Usage: magabot skill test <name> [--user ID] [--platform NAME] [--timeout D] [--json] <input...>")
+			return
+		}
+		os.Exit(cmdSkillTest(os.Args[3], os.Args[4:]))
 	case "reload":
 		cmdSkillReload()
 	case "builtin":
@@ -72,6 +79,9 @@ Commands:
   create <name>     Create a new skill template
   enable <name>     Enable a skill
   disable <name>    Disable a skill
+  test <name> <input...>
+                    Run a skill locally with a synthetic message
+                    (--user ID, --platform NAME, --timeout 10s, --json)
   reload            Reload all skills
   builtin           List built-in skills
 
@@ -80,6 +90,7 @@ Skills directory: ` + getSkillsDir() + `
 Example:
   magabot skill create my-skill
   magabot skill list
+  magabot skill test weather --json "weather in Jakarta"
   magabot skill enable translator
 `)
 }
@@ -132,19 +143,42 @@ func cmdSkillList() {
 	fmt.Printf("\nTotal: %d skills\n", len(skillList))
 }
 
-// cmdSkillInfo shows details about a skill
-func cmdSkillInfo(name string) {
+// loadSkillManager loads installed skills with the execution limits and
+// sandbox settings from the config, when one exists.
+func loadSkillManager() *skills.Manager {
 	manager := skills.NewManager(getSkillsDir())
 	_ = manager.LoadAll()
+	if cfg, err := config.Load(configFile); err == nil {
+		manager.SetLimits(cfg.Skills.Timeout.Duration(), cfg.Skills.MaxConcurrent)
+		manager.SetSandbox(skills.SandboxConfig{
+			AllowScripts:    cfg.Skills.AllowScripts,
+			Enabled:         cfg.Skills.Sandbox,
+			AllowedCommands: cfg.Skills.AllowedCommands,
+			AllowedHosts:    cfg.Skills.AllowedHosts,
+		})
+	}
+	return manager
+}
 
-	skill, ok := manager.Get(name)
-	if !ok {
-		// Try built-in
-		skill = skills.GetBuiltinSkill(name)
-		if skill == nil {
-			fmt.Printf("Skill not found: %s\n", name)
-			return
-		}
+// findSkill returns an installed skill, falling back to a built-in one.
+func findSkill(manager *skills.Manager, name string) *skills.Skill {
+	if skill, ok := manager.Get(name); ok {
+		return skill
+	}
+	if skill := skills.GetBuiltinSkill(name); skill != nil {
+		manager.AddSkill(skill)
+		return skill
+	}
+	return nil
+}
+
+// cmdSkillInfo shows details about a skill
+func cmdSkillInfo(name string) {
+	manager := loadSkillManager()
+	skill := findSkill(manager, name)
+	if skill == nil {
+		fmt.Printf("Skill not found: %s\n", name)
+		return
 	}
 
 	fmt.Printf("📦 %s\n", skill.Name)
@@ -172,6 +206,14 @@ func cmdSkillInfo(name string) {
 	fmt.Println()
 
 	fmt.Printf("Action Type: %s\n", skill.Actions.Type)
+	timeoutSrc := "skills.timeout"
+	if !skill.Timeout.IsZero() {
+		timeoutSrc = "skill.yaml"
+	}
+	fmt.Printf("Timeout:     %s (%s)\n", manager.TimeoutFor(skill), timeoutSrc)
+	if manager.SandboxEnabled() {
+		fmt.Println("Sandbox:     on")
+	}
 	if skill.Path != "" {
 		fmt.Printf("Location:    %s\n", skill.Path)
 	}
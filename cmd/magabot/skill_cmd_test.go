@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/skills"
+	"github.com/kusa/magabot/internal/util"
+)
+
+func TestParseSkillTestFlags(t *testing.T) {
+	f, err := parseSkillTestFlags([]string{"--user", "42", "hello", "--json", "--platform", "slack", "--timeout", "2s", "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.userID != "42" || f.platform != "slack" || !f.jsonOut || f.timeout != 2*time.Second || f.input != "hello world" {
+		t.Errorf("unexpected flags: %+v", f)
+	}
+
+	if f, _ := parseSkillTestFlags([]string{"--", "--json", "literal"}); f.jsonOut || f.input != "--json literal" {
+		t.Errorf("-- should end flag parsing: %+v", f)
+	}
+	for _, args := range [][]string{{"--user"}, {"--timeout", "soon"}, {"--timeout", "-1s"}} {
+		if _, err := parseSkillTestFlags(args); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestRunSkillTest(t *testing.T) {
+	dir := t.TempDir()
+	manager := skills.NewManager(dir)
+	manager.SetSandbox(skills.SandboxConfig{AllowScripts: true})
+	skill := &skills.Skill{
+		Name:     "echo",
+		Path:     dir,
+		Triggers: skills.Triggers{Commands: []string{"/echo"}},
+		Actions:  skills.Actions{Type: "script", Script: `printf '%s@%s:' "$MAGABOT_USER_ID" "$MAGABOT_PLATFORM"; cat`},
+	}
+	manager.AddSkill(skill)
+
+	res := runSkillTest(manager, skill, skillTestFlags{userID: "u1", platform: "discord", input: "/echo hi"})
+	if res.Error != "" || res.Output != "u1@discord:/echo hi" || !res.Matched {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	var buf bytes.Buffer
+	printSkillTestResult(&buf, res, true)
+	var decoded skillTestResult
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Output != res.Output {
+		t.Errorf("--json output not decodable: %v\n%s", err, buf.String())
+	}
+}
+
+func TestRunSkillTest_TimeoutOverride(t *testing.T) {
+	dir := t.TempDir()
+	manager := skills.NewManager(dir)
+	manager.SetSandbox(skills.SandboxConfig{AllowScripts: true})
+	skill := &skills.Skill{
+		Name:    "slow",
+		Path:    dir,
+		Timeout: util.NewDuration(time.Minute),
+		Actions: skills.Actions{Type: "script", Script: "sleep 5"},
+	}
+
+	res := runSkillTest(manager, skill, skillTestFlags{timeout: 50 * time.Millisecond, input: "x"})
+	if !res.TimedOut || !strings.Contains(res.Error, "timed out") {
+		t.Errorf("expected timeout, got %+v", res)
+	}
+	if skill.Timeout.Duration() != time.Minute {
+		t.Error("--timeout must not modify the loaded skill")
+	}
+}
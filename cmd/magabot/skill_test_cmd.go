@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/skills"
+	"github.com/kusa/magabot/internal/util"
+)
+
+// skillTestResult is the outcome of `magabot skill test`, printed with --json.
+type skillTestResult struct {
+	Skill      string `json:"skill"`
+	Input      string `json:"input"`
+	UserID     string `json:"user_id"`
+	Platform   string `json:"platform"`
+	Matched    bool   `json:"matched"`
+	ActionType string `json:"action_type"`
+	Output     string `json:"output"`
+	Error      string `json:"error,omitempty"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// skillTestFlags are the options accepted by `magabot skill test`.
+type skillTestFlags struct {
+	userID   string
+	platform string
+	timeout  time.Duration
+	jsonOut  bool
+	input    string
+}
+
+// parseSkillTestFlags parses flags; remaining arguments form the input message.
+func parseSkillTestFlags(args []string) (skillTestFlags, error) {
+	f := skillTestFlags{userID: "cli-test", platform: "cli"}
+	var words []string
+	value := func(i *int, flag string) (string, error) {
+		if *i+1 >= len(args) {
+			return "", fmt.Errorf("%s requires a value", flag)
+		}
+		*i++
+		return args[*i], nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		var err error
+		switch a := args[i]; a {
+		case "--json":
+			f.jsonOut = true
+		case "--user":
+			f.userID, err = value(&i, a)
+		case "--platform":
+			f.platform, err = value(&i, a)
+		case "--timeout":
+			var v string
+			if v, err = value(&i, a); err == nil {
+				if f.timeout, err = time.ParseDuration(v); err == nil && f.timeout <= 0 {
+					err = errors.New("--timeout must be positive")
+				}
+			}
+		case "--":
+			words = append(words, args[i+1:]...)
+			i = len(args)
+		default:
+			words = append(words, a)
+		}
+		if err != nil {
+			return f, err
+		}
+	}
+	f.input = strings.Join(words, " ")
+	return f, nil
+}
+
+// runSkillTest executes a skill with a synthetic message. A --timeout
+// overrides the configured skill timeout.
+func runSkillTest(manager *skills.Manager, skill *skills.Skill, f skillTestFlags) skillTestResult {
+	if f.timeout > 0 {
+		manager.SetLimits(f.timeout, 0)
+		override := *skill
+		override.Timeout = util.Duration{}
+		skill = &override
+	}
+
+	res := skillTestResult{
+		Skill:      skill.Name,
+		Input:      f.input,
+		UserID:     f.userID,
+		Platform:   f.platform,
+		Matched:    manager.Matches(skill, f.input),
+		ActionType: skill.Actions.Type,
+	}
+	start := time.Now()
+	out, err := manager.ExecuteInput(context.Background(), skill, skills.Input{
+		Message:  f.input,
+		UserID:   f.userID,
+		Platform: f.platform,
+	})
+	res.DurationMS = time.Since(start).Milliseconds()
+	res.Output = out
+	if err != nil {
+		res.Error = err.Error()
+		res.TimedOut = errors.Is(err, skills.ErrTimeout)
+	}
+	return res
+}
+
+// printSkillTestResult writes the result as JSON or human-readable text.
+func printSkillTestResult(w io.Writer, res skillTestResult, jsonOut bool) {
+	if jsonOut {
+		data, _ := json.MarshalIndent(res, "", "  ")
+		_, _ = fmt.Fprintln(w, string(data))
+		return
+	}
+
+	matched := "no (executed anyway)"
+	if res.Matched {
+		matched = "yes"
+	}
+	_, _ = fmt.Fprintf(w, "🧪 %s (%s) — %dms\n", res.Skill, res.ActionType, res.DurationMS)
+	_, _ = fmt.Fprintf(w, "Input:    %q (user %s on %s)\n", res.Input, res.UserID, res.Platform)
+	_, _ = fmt.Fprintf(w, "Triggers: %s\n", matched)
+	if res.Error != "" {
+		_, _ = fmt.Fprintf(w, "❌ Error:  %s\n", res.Error)
+	}
+	if res.Output != "" {
+		_, _ = fmt.Fprintf(w, "\n%s\n", res.Output)
+	}
+}
+
+// cmdSkillTest handles `magabot skill test <name> <input...>` and returns
+// the process exit code.
+func cmdSkillTest(name string, args []string) int {
+	f, err := parseSkillTestFlags(args)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+
+	manager := loadSkillManager()
+	skill := findSkill(manager, name)
+	if skill == nil {
+		fmt.Printf("Skill not found: %s\n", name)
+		return 1
+	}
+
+	res := runSkillTest(manager, skill, f)
+	printSkillTestResult(os.Stdout, res, f.jsonOut)
+	if res.Error != "" {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/i18n"
+	"github.com/kusa/magabot/internal/memory"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/session"
+)
+
+// defaultMemoryContextLimit bounds the memories added to a prompt when
+// memory.context_limit is unset.
+const defaultMemoryContextLimit = 2000
+
+func memoryContextLimit(cfg *config.Config) int {
+	if cfg.Memory.ContextLimit > 0 {
+		return cfg.Memory.ContextLimit
+	}
+	return defaultMemoryContextLimit
+}
+
+// withSources appends the list of memories answer cites, if any.
+func withSources(answer string, cited []memory.Source) string {
+	if len(cited) == 0 {
+		return answer
+	}
+	return answer + "\n\n" + memory.FormatSources(cited)
+}
+
+// sessionSources returns the memories the chat's last answer cited.
+func sessionSources(sessionMgr *session.Manager, sess *session.Session) []memory.Source {
+	sources, _ := sessionMgr.GetContext(sess, "sources").([]memory.Source)
+	return sources
+}
+
+// handleSourcesCommand handles /sources: list the memories the last answer
+// in this chat was based on.
+func handleSourcesCommand(lang string, msg *router.Message, cfg *config.Config, sessionMgr *session.Manager) string {
+	if !cfg.Memory.Enabled {
+		return i18n.T(lang, "sources.disabled")
+	}
+	sess := sessionMgr.GetOrCreateThread(msg.Platform, msg.ChatID, msg.ThreadID, msg.UserID)
+	sources := sessionSources(sessionMgr, sess)
+	if len(sources) == 0 {
+		return i18n.T(lang, "sources.none")
+	}
+	return memory.FormatSources(sources)
+}
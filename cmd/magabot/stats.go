@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+// defaultStatBuckets is how many buckets `magabot stats` shows per grouping.
+var defaultStatBuckets = map[string]int{
+	"hour":  24,
+	"day":   14,
+	"week":  12,
+	"month": 12,
+}
+
+// sparkTicks are the bar heights used to draw trends.
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// cmdStats prints message volume per platform and provider:
+// magabot stats [hour|day|week|month] [count]
+func cmdStats() {
+	groupBy, count, err := parseStatsArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n\nUsage: magabot stats [hour|day|week|month] [count]\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := storage.New(cfg.GetDatabasePath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = store.Close() }()
+
+	starts := statBucketStarts(time.Now(), groupBy, count)
+	to := advanceBucket(starts[len(starts)-1], groupBy)
+	buckets, err := store.MessageStats(starts[0], to, groupBy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(renderMessageStats(buckets, starts, groupBy))
+}
+
+// parseStatsArgs reads the optional grouping and bucket count.
+func parseStatsArgs(args []string) (string, int, error) {
+	groupBy := "day"
+	if len(args) > 0 {
+		groupBy = strings.ToLower(args[0])
+		if _, ok := defaultStatBuckets[groupBy]; !ok {
+			return "", 0, fmt.Errorf("unknown grouping %q (use hour, day, week or month)", args[0])
+		}
+	}
+	count := defaultStatBuckets[groupBy]
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > 366 {
+			return "", 0, fmt.Errorf("invalid count %q (1-366)", args[1])
+		}
+		count = n
+	}
+	return groupBy, count, nil
+}
+
+// bucketStart returns the start of the bucket containing t, matching
+// storage.MessageStats: local time, weeks starting on Monday.
+func bucketStart(t time.Time, groupBy string) time.Time {
+	t = t.Local()
+	switch groupBy {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		return day.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	}
+}
+
+// advanceBucket returns the start of the bucket after start.
+func advanceBucket(start time.Time, groupBy string) time.Time {
+	switch groupBy {
+	case "hour":
+		return start.Add(time.Hour)
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// statBucketStarts returns the starts of the count buckets ending with the
+// one containing now, oldest first.
+func statBucketStarts(now time.Time, groupBy string, count int) []time.Time {
+	starts := make([]time.Time, count)
+	starts[count-1] = bucketStart(now, groupBy)
+	for i := count - 2; i >= 0; i-- {
+		// Step back from inside the previous bucket so DST shifts don't skip one
+		starts[i] = bucketStart(starts[i+1].Add(-time.Minute), groupBy)
+	}
+	return starts
+}
+
+// sparkline draws values as bars scaled to the largest.
+func sparkline(values []int64) string {
+	var peak int64
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		switch {
+		case v == 0:
+			sb.WriteRune(' ')
+		case peak == 0:
+			sb.WriteRune(sparkTicks[0])
+		default:
+			sb.WriteRune(sparkTicks[int(v*int64(len(sparkTicks)-1)/peak)])
+		}
+	}
+	return sb.String()
+}
+
+// renderMessageStats prints per-platform totals with a trend over starts,
+// followed by answers per LLM provider.
+func renderMessageStats(buckets []storage.StatBucket, starts []time.Time, groupBy string) string {
+	index := make(map[time.Time]int, len(starts))
+	for i, s := range starts {
+		index[s] = i
+	}
+
+	type row struct {
+		in, out int64
+		trend   []int64
+	}
+	platforms := make(map[string]*row)
+	providers := make(map[string]int64)
+	total := &row{trend: make([]int64, len(starts))}
+	for _, b := range buckets {
+		p, ok := platforms[b.Platform]
+		if !ok {
+			p = &row{trend: make([]int64, len(starts))}
+			platforms[b.Platform] = p
+		}
+		p.in += b.In
+		p.out += b.Out
+		total.in += b.In
+		total.out += b.Out
+		if i, ok := index[b.Start]; ok {
+			p.trend[i] += b.In + b.Out
+			total.trend[i] += b.In + b.Out
+		}
+		if b.Provider != "" {
+			providers[b.Provider] += b.Out
+		}
+	}
+
+	layout := "Jan 2"
+	if groupBy == "hour" {
+		layout = "Jan 2 15:04"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📊 Messages by %s, %s – %s\n\n", groupBy,
+		starts[0].Format(layout), starts[len(starts)-1].Format(layout))
+	if len(platforms) == 0 {
+		sb.WriteString("No messages in this period.\n")
+		return sb.String()
+	}
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PLATFORM\tIN\tOUT\tTREND")
+	for _, name := range sortedKeys(platforms) {
+		p := platforms[name]
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", name, p.in, p.out, sparkline(p.trend))
+	}
+	if len(platforms) > 1 {
+		_, _ = fmt.Fprintf(w, "total\t%d\t%d\t%s\n", total.in, total.out, sparkline(total.trend))
+	}
+	_ = w.Flush()
+
+	if len(providers) > 0 {
+		names := sortedKeys(providers)
+		sort.SliceStable(names, func(i, j int) bool { return providers[names[i]] > providers[names[j]] })
+		sb.WriteString("\nLLM answers:\n")
+		w = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		for _, name := range names {
+			_, _ = fmt.Fprintf(w, "  %s\t%d\n", name, providers[name])
+		}
+		_ = w.Flush()
+	}
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/storage"
+)
+
+func TestParseStatsArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		groupBy string
+		count   int
+		wantErr bool
+	}{
+		{nil, "day", 14, false},
+		{[]string{"hour"}, "hour", 24, false},
+		{[]string{"Week", "8"}, "week", 8, false},
+		{[]string{"year"}, "", 0, true},
+		{[]string{"day", "0"}, "", 0, true},
+		{[]string{"day", "x"}, "", 0, true},
+	}
+	for _, tt := range tests {
+		groupBy, count, err := parseStatsArgs(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStatsArgs(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if groupBy != tt.groupBy || count != tt.count {
+			t.Errorf("parseStatsArgs(%v) = %s, %d, want %s, %d", tt.args, groupBy, count, tt.groupBy, tt.count)
+		}
+	}
+}
+
+func TestStatBucketStarts(t *testing.T) {
+	now := time.Date(2026, 3, 4, 15, 30, 0, 0, time.Local) // Wednesday
+
+	days := statBucketStarts(now, "day", 3)
+	if !days[0].Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)) || !days[2].Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("day starts = %v", days)
+	}
+	weeks := statBucketStarts(now, "week", 2)
+	if !weeks[1].Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)) || !weeks[0].Equal(time.Date(2026, 2, 23, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("week starts = %v", weeks)
+	}
+	months := statBucketStarts(now, "month", 3)
+	if !months[0].Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("month starts = %v", months)
+	}
+}
+
+func TestRenderMessageStats(t *testing.T) {
+	starts := statBucketStarts(time.Date(2026, 3, 4, 12, 0, 0, 0, time.Local), "day", 3)
+	if got := renderMessageStats(nil, starts, "day"); !strings.Contains(got, "No messages") {
+		t.Errorf("empty stats = %q", got)
+	}
+
+	got := renderMessageStats([]storage.StatBucket{
+		{Start: starts[0], Platform: "telegram", In: 4},
+		{Start: starts[0], Platform: "telegram", Provider: "anthropic", Out: 4},
+		{Start: starts[2], Platform: "telegram", Provider: "openai", Out: 1},
+		{Start: starts[2], Platform: "slack", In: 2},
+	}, starts, "day")
+	for _, want := range []string{"telegram  4   5    █ ▁", "slack     2   0      █", "total     6   5", "anthropic  4", "openai     1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]int64{0, 1, 4, 8}); got != " ▁▄█" {
+		t.Errorf("sparkline = %q", got)
+	}
+}
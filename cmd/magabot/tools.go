@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusa/magabot/internal/tools"
+)
+
+// newToolManager registers the tools enabled under tools: for the LLM to
+// call, or returns nil when none is.
+func newToolManager(cfg *config.Config, logger *slog.Logger) *tools.Manager {
+	tc := cfg.Tools
+	if !tc.Search.Enabled && !tc.Weather.Enabled {
+		return nil
+	}
+	mgr := tools.NewManager(logger.With("component", "tools"))
+	if tc.Search.Enabled {
+		mgr.Register(tools.NewSearch(&tools.SearchConfig{APIKey: tc.Search.APIKey}))
+	}
+	if tc.Weather.Enabled {
+		mgr.Register(tools.NewWeather())
+	}
+	return mgr
+}
+
+// useTools lets the model call the manager's tools for req. A nil manager
+// leaves req alone.
+func useTools(mgr *tools.Manager, req *llm.Request) {
+	if mgr == nil {
+		return
+	}
+	req.Tools = mgr.Definitions()
+	req.RunTool = mgr.Call
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kusa/magabot/internal/config"
 	"github.com/kusa/magabot/internal/updater"
 	"github.com/kusa/magabot/internal/version"
 )
@@ -21,7 +23,7 @@ const (
 
 func cmdUpdate() {
 	if len(os.Args) < 3 {
-		cmdUpdateCheck()
+		cmdUpdateCheck(updateFlags{})
 		return
 	}
 
@@ -29,10 +31,9 @@ func cmdUpdate() {
 
 	switch subCmd {
 	case "check":
-		cmdUpdateCheck()
+		cmdUpdateCheck(parseUpdateFlags(os.Args[3:]))
 	case "apply", "install":
-		autoConfirm := len(os.Args) > 3 && (os.Args[3] == "--yes" || os.Args[3] == "-y")
-		cmdUpdateApply(autoConfirm)
+		cmdUpdateApply(parseUpdateFlags(os.Args[3:]))
 	case "rollback":
 		cmdUpdateRollback()
 	case "help":
@@ -44,16 +45,83 @@ func cmdUpdate() {
 	}
 }
 
-func cmdUpdateCheck() {
+// updateFlags holds options for 'update check' and 'update apply'.
+type updateFlags struct {
+	autoConfirm     bool
+	channel         string // empty = use config
+	allowUnverified bool
+}
+
+func parseUpdateFlags(args []string) updateFlags {
+	var f updateFlags
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--yes", "-y":
+			f.autoConfirm = true
+		case "--allow-unverified":
+			f.allowUnverified = true
+		case "--channel":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "❌ --channel requires stable or beta")
+				os.Exit(1)
+			}
+			i++
+			f.channel = strings.ToLower(args[i])
+			if !updater.ValidChannel(f.channel) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown channel %q (use stable or beta)\n", args[i])
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown flag: %s\n", args[i])
+			cmdUpdateHelp()
+			os.Exit(1)
+		}
+	}
+	return f
+}
+
+// resolveUpdateChannel returns the release channel to use. A --channel flag
+// wins and is persisted to config so later checks stay on it.
+func resolveUpdateChannel(flagChannel string) string {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		if flagChannel != "" {
+			return flagChannel
+		}
+		return updater.ChannelStable
+	}
+
+	if flagChannel == "" {
+		if updater.ValidChannel(cfg.Update.Channel) {
+			return cfg.Update.Channel
+		}
+		return updater.ChannelStable
+	}
+
+	if flagChannel != cfg.Update.Channel {
+		if err := cfg.PatchYAMLField("update.channel", flagChannel); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Could not save channel to config: %v\n", err)
+		}
+	}
+	return flagChannel
+}
+
+func newUpdater(flags updateFlags) *updater.Updater {
+	return updater.New(updater.Config{
+		RepoOwner:       repoOwner,
+		RepoName:        repoName,
+		CurrentVersion:  version.Short(),
+		BinaryName:      "magabot",
+		Channel:         resolveUpdateChannel(flags.channel),
+		AllowUnverified: flags.allowUnverified,
+	})
+}
+
+func cmdUpdateCheck(flags updateFlags) {
 	fmt.Printf("🔍 Checking for updates...\n\n")
 	fmt.Printf("Current version: %s\n", version.Short())
 
-	u := updater.New(updater.Config{
-		RepoOwner:      repoOwner,
-		RepoName:       repoName,
-		CurrentVersion: version.Short(),
-		BinaryName:     "magabot",
-	})
+	u := newUpdater(flags)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -71,15 +139,10 @@ func cmdUpdateCheck() {
 	}
 }
 
-func cmdUpdateApply(autoConfirm bool) {
+func cmdUpdateApply(flags updateFlags) {
 	fmt.Printf("🔄 Checking for updates...\n")
 
-	u := updater.New(updater.Config{
-		RepoOwner:      repoOwner,
-		RepoName:       repoName,
-		CurrentVersion: version.Short(),
-		BinaryName:     "magabot",
-	})
+	u := newUpdater(flags)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
@@ -99,7 +162,7 @@ func cmdUpdateApply(autoConfirm bool) {
 	fmt.Printf("\n📝 Release Notes:\n%s\n", truncateNotes(release.Body, 300))
 
 	// Confirm
-	if !autoConfirm {
+	if !flags.autoConfirm {
 		fmt.Print("\nDo you want to update? [y/N]: ")
 		reader := bufio.NewReader(os.Stdin)
 		confirm, _ := reader.ReadString('\n')
@@ -135,7 +198,15 @@ func cmdUpdateApply(autoConfirm bool) {
 	fmt.Println("⬇️  Downloading update...")
 	if err := u.Update(ctx, release); err != nil {
 		fmt.Fprintf(os.Stderr, "❌ Update failed: %v\n", err)
-		fmt.Println("💡 Run 'magabot update rollback' to restore previous version")
+		switch {
+		case errors.Is(err, updater.ErrChecksumMismatch):
+			fmt.Println("⚠️  The download did not match its published checksum and was NOT installed.")
+		case errors.Is(err, updater.ErrNoChecksum):
+			fmt.Println("⚠️  This release publishes no checksum, so it was NOT installed.")
+			fmt.Println("💡 Re-run with --allow-unverified to install it anyway")
+		default:
+			fmt.Println("💡 Run 'magabot update rollback' to restore previous version")
+		}
 		os.Exit(1)
 	}
 
@@ -179,6 +250,10 @@ Commands:
   rollback    Restore previous version
   help        Show this help
 
+Options (check/apply):
+  --channel stable|beta  Release channel; saved to config (update.channel)
+  --allow-unverified     Install releases that publish no SHA-256 checksum
+
 Update Process:
   1. 'magabot update check' - Check if update available
   2. 'magabot update apply' - Download and install
@@ -188,6 +263,8 @@ If issues occur:
   'magabot update rollback' - Restore previous version
 
 Notes:
+  - Downloads are verified against the release's SHA-256 checksums
+    and never installed on mismatch
   - Bot will be stopped during update
   - Previous version is kept as backup
   - Rollback available until next update`)
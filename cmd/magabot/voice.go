@@ -0,0 +1,130 @@
+// Long voice notes: split into chunks and transcribed in order
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/config"
+)
+
+// Transcribing gets a fixed allowance for loading the model plus a
+// multiple of the audio's length, and never less than minTranscribeTimeout.
+const (
+	minTranscribeTimeout  = 2 * time.Minute
+	transcribeStartup     = time.Minute
+	transcribeSpeedFactor = 2 // seconds allowed per second of audio
+)
+
+// transcribeTimeout returns how long transcribing length of audio may
+// take. Unknown lengths (0) get minTranscribeTimeout.
+func transcribeTimeout(length time.Duration) time.Duration {
+	return max(transcribeStartup+transcribeSpeedFactor*length, minTranscribeTimeout)
+}
+
+// missingChunk stands in for a chunk whose transcription failed, so the
+// reader knows part of the note is absent.
+const missingChunk = "[…]"
+
+// audioDuration returns the length of an audio file, read with ffprobe.
+func audioDuration(ctx context.Context, path string) (time.Duration, error) {
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=duration", "-of", "csv=p=0", "--", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: %w", err)
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: unexpected duration %q", strings.TrimSpace(string(out)))
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// splitAudio cuts the first limit of path into chunk-long 16 kHz mono WAV
+// files in dir, the format Whisper works in, and returns them in order.
+func splitAudio(ctx context.Context, path, dir string, chunk, limit time.Duration) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-loglevel", "error",
+		"-i", path,
+		"-t", strconv.FormatFloat(limit.Seconds(), 'f', 0, 64),
+		"-ac", "1", "-ar", "16000",
+		"-f", "segment", "-segment_time", strconv.FormatFloat(chunk.Seconds(), 'f', 0, 64),
+		filepath.Join(dir, "chunk-%03d.wav"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	chunks, err := filepath.Glob(filepath.Join(dir, "chunk-*.wav"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(chunks)
+	return chunks, nil
+}
+
+// transcribeChunks transcribes chunks in order and returns one segment per
+// chunk. A failed chunk becomes missingChunk and the rest carry on; failed
+// counts them. progress, if set, is called after each chunk.
+func transcribeChunks(ctx context.Context, chunks []string, transcribe func(context.Context, string) (string, error), progress func(done, total int)) (segments []string, failed int) {
+	segments = make([]string, len(chunks))
+	for i, chunk := range chunks {
+		text, err := transcribe(ctx, chunk)
+		if err != nil || text == "" {
+			segments[i] = missingChunk
+			failed++
+		} else {
+			segments[i] = text
+		}
+		if progress != nil {
+			progress(i+1, len(chunks))
+		}
+	}
+	return segments, failed
+}
+
+// transcribeVoice transcribes an audio file. Notes up to
+// media.audio_chunk_length go to the transcriber whole; longer ones are
+// split, transcribed chunk by chunk with progress reports, and stitched
+// back together. Audio past media.max_audio_duration is left out with a
+// note. Without ffmpeg the file is transcribed whole.
+func transcribeVoice(ctx context.Context, path string, media config.MediaConfig, progress func(done, total int)) (string, error) {
+	chunkLen := media.AudioChunkLength.Duration()
+	duration, err := audioDuration(ctx, path)
+	if err != nil || chunkLen <= 0 || duration <= chunkLen {
+		return transcribeAudioFile(ctx, path, duration)
+	}
+
+	limit := duration
+	if maxLen := media.MaxAudioDuration.Duration(); maxLen > 0 && duration > maxLen {
+		limit = maxLen
+	}
+	dir, err := os.MkdirTemp("", "magabot-audio-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	chunks, err := splitAudio(ctx, path, dir, chunkLen, limit)
+	if err != nil {
+		return "", err
+	}
+	transcribe := func(ctx context.Context, chunk string) (string, error) {
+		return transcribeAudioFile(ctx, chunk, chunkLen)
+	}
+	segments, failed := transcribeChunks(ctx, chunks, transcribe, progress)
+	if failed == len(segments) {
+		return "", fmt.Errorf("all %d chunks failed to transcribe", failed)
+	}
+
+	transcript := strings.Join(segments, " ")
+	if limit < duration {
+		transcript += fmt.Sprintf("\n\n[Transcript covers the first %s of %s]",
+			formatDuration(limit), formatDuration(duration))
+	}
+	return transcript, nil
+}
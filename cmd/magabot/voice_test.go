@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -67,6 +71,31 @@ func TestCleanOldDownloads_NonExistentDir(t *testing.T) {
 	cleanOldDownloads([]string{"/nonexistent/path"}, 24*time.Hour, logger)
 }
 
+func TestRemoveDownloads(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	photo := filepath.Join(dir, "photo.png")
+	outside := filepath.Join(other, "notes.txt")
+	for _, p := range []string{photo, scaledImagePath(photo), outside} {
+		if err := os.WriteFile(p, []byte("data"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeDownloads([]string{photo, outside, filepath.Join(dir, "missing.ogg")}, []string{dir}, logger)
+
+	for _, p := range []string{photo, scaledImagePath(photo)} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be deleted", filepath.Base(p))
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Error("files outside the download dirs must be kept")
+	}
+}
+
 func TestLocalScriptPath(t *testing.T) {
 	home, _ := os.UserHomeDir()
 	got := localScriptPath("tts-speak")
@@ -75,3 +104,75 @@ func TestLocalScriptPath(t *testing.T) {
 		t.Errorf("localScriptPath = %q, want %q", got, want)
 	}
 }
+
+func TestTranscribeChunks(t *testing.T) {
+	chunks := []string{"a.wav", "b.wav", "c.wav"}
+	transcribe := func(_ context.Context, path string) (string, error) {
+		if path == "b.wav" {
+			return "", errors.New("whisper crashed")
+		}
+		return "text of " + path, nil
+	}
+	var reports []int
+	segments, failed := transcribeChunks(context.Background(), chunks, transcribe, func(done, total int) {
+		if total != 3 {
+			t.Errorf("progress total = %d, want 3", total)
+		}
+		reports = append(reports, done)
+	})
+
+	want := []string{"text of a.wav", missingChunk, "text of c.wav"}
+	if !reflect.DeepEqual(segments, want) {
+		t.Errorf("segments = %q, want %q", segments, want)
+	}
+	if failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+	if !reflect.DeepEqual(reports, []int{1, 2, 3}) {
+		t.Errorf("progress reports = %v, want 1, 2, 3", reports)
+	}
+}
+
+func TestSplitAudio(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "note.wav")
+	if out, err := exec.Command("ffmpeg", "-loglevel", "error", "-f", "lavfi", "-i", "sine=duration=25", src).CombinedOutput(); err != nil {
+		t.Fatalf("make test audio: %v: %s", err, out)
+	}
+
+	chunks, err := splitAudio(ctx, src, dir, 10*time.Second, 25*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Errorf("chunks = %v, want 3 (10s + 10s + 5s)", chunks)
+	}
+
+	chunks, err = splitAudio(ctx, src, t.TempDir(), 10*time.Second, 15*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 {
+		t.Errorf("chunks within a 15s limit = %v, want 2", chunks)
+	}
+}
+
+func TestTranscribeTimeout(t *testing.T) {
+	cases := []struct {
+		length, want time.Duration
+	}{
+		{0, 2 * time.Minute},
+		{20 * time.Second, 2 * time.Minute},
+		{5 * time.Minute, 11 * time.Minute},
+		{30 * time.Minute, 61 * time.Minute},
+	}
+	for _, c := range cases {
+		if got := transcribeTimeout(c.length); got != c.want {
+			t.Errorf("transcribeTimeout(%s) = %s, want %s", c.length, got, c.want)
+		}
+	}
+}
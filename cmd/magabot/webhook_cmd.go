@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/kusa/magabot/internal/config"
+	"github.com/kusa/magabot/internal/security"
+	"github.com/kusa/magabot/internal/util"
+)
+
+func cmdWebhook() {
+	if len(os.Args) < 3 || os.Args[2] != "token" {
+		if len(os.Args) > 2 && os.Args[2] != "help" {
+			fmt.Fprintf(os.Stderr, "Unknown webhook command: %s\n", os.Args[2])
+			cmdWebhookHelp()
+			os.Exit(1)
+		}
+		cmdWebhookHelp()
+		return
+	}
+
+	subCmd := "list"
+	if len(os.Args) > 3 {
+		subCmd = os.Args[3]
+	}
+
+	switch subCmd {
+	case "add":
+		cmdWebhookTokenAdd()
+	case "list", "ls":
+		cmdWebhookTokenList()
+	case "revoke", "rm":
+		cmdWebhookTokenRevoke()
+	case "help":
+		cmdWebhookHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown webhook token command: %s\n", subCmd)
+		cmdWebhookHelp()
+		os.Exit(1)
+	}
+}
+
+// loadWebhookConfig loads the config and exits unless a webhook is configured.
+func loadWebhookConfig() *config.Config {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Platforms.Webhook == nil {
+		fmt.Fprintln(os.Stderr, "Webhook is not configured. Run 'magabot setup webhook' first.")
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// tokenFingerprint identifies a token in listings without revealing it.
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// saveWebhookTokens saves the config and tells a running daemon to pick up
+// the new tokens.
+func saveWebhookTokens(cfg *config.Config) {
+	if err := cfg.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to save config: %v\n", err)
+		os.Exit(1)
+	}
+	pid := getPID()
+	if pid == 0 || !processExists(pid) {
+		fmt.Println("   Magabot is not running; the change applies on the next start.")
+		return
+	}
+	if err := signalTokenReload(pid); err != nil {
+		fmt.Printf("   ⚠️  Could not reload the running daemon: %v\n", err)
+		return
+	}
+	fmt.Println("   Running daemon reloaded its webhook tokens.")
+}
+
+func cmdWebhookTokenAdd() {
+	if len(os.Args) < 5 {
+		fmt.Println("Usage: magabot webhook token add <user>")
+		os.Exit(1)
+	}
+	user := os.Args[4]
+
+	cfg := loadWebhookConfig()
+	wh := cfg.Platforms.Webhook
+	switch wh.AuthMethod {
+	case "", "none":
+		wh.AuthMethod = "bearer"
+	case "bearer":
+	default:
+		fmt.Fprintf(os.Stderr, "Webhook uses auth_method %q; bearer tokens are only checked with auth_method bearer.\n", wh.AuthMethod)
+		os.Exit(1)
+	}
+
+	token := security.GenerateKey()[:32]
+	if wh.BearerTokens == nil {
+		wh.BearerTokens = make(map[string]string)
+	}
+	wh.BearerTokens[token] = user
+	wh.AllowedUsers = util.AddUnique(wh.AllowedUsers, user)
+
+	fmt.Printf("🔑 Token for %s: %s\n", user, token)
+	fmt.Println("   Store it now; it is not shown again.")
+	saveWebhookTokens(cfg)
+}
+
+func cmdWebhookTokenList() {
+	cfg := loadWebhookConfig()
+	tokens := cfg.Platforms.Webhook.BearerTokens
+	if len(tokens) == 0 {
+		fmt.Println("No webhook tokens. Add one with 'magabot webhook token add <user>'.")
+		return
+	}
+
+	type entry struct{ user, fingerprint string }
+	entries := make([]entry, 0, len(tokens))
+	for token, user := range tokens {
+		entries = append(entries, entry{user, tokenFingerprint(token)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].user != entries[j].user {
+			return entries[i].user < entries[j].user
+		}
+		return entries[i].fingerprint < entries[j].fingerprint
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "USER\tFINGERPRINT")
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", e.user, e.fingerprint)
+	}
+	_ = w.Flush()
+}
+
+func cmdWebhookTokenRevoke() {
+	if len(os.Args) < 5 {
+		fmt.Println("Usage: magabot webhook token revoke <user>")
+		os.Exit(1)
+	}
+	user := os.Args[4]
+
+	cfg := loadWebhookConfig()
+	wh := cfg.Platforms.Webhook
+	n := revokeTokens(wh.BearerTokens, user)
+	if n == 0 {
+		fmt.Printf("No webhook tokens for %s.\n", user)
+		return
+	}
+	fmt.Printf("🗑️  Revoked %d token(s) for %s\n", n, user)
+	if disallowUser(wh, user) {
+		fmt.Printf("   Removed %s from allowed_users\n", user)
+	}
+	saveWebhookTokens(cfg)
+}
+
+// disallowUser removes user from wh.AllowedUsers, as token add put it there,
+// unless user still signs in with an HMAC secret or basic auth. Patterns
+// such as "ci-*" are left alone. It reports whether user was removed.
+func disallowUser(wh *config.WebhookConfig, user string) bool {
+	if wh.BasicUser == user || !util.Contains(wh.AllowedUsers, user) {
+		return false
+	}
+	for _, u := range wh.HMACUsers {
+		if u == user {
+			return false
+		}
+	}
+	wh.AllowedUsers = util.Remove(wh.AllowedUsers, user)
+	return true
+}
+
+// revokeTokens deletes every token of user from tokens and returns how many
+// were removed.
+func revokeTokens(tokens map[string]string, user string) int {
+	n := 0
+	for token, u := range tokens {
+		if u == user {
+			delete(tokens, token)
+			n++
+		}
+	}
+	return n
+}
+
+func cmdWebhookHelp() {
+	fmt.Println(`Webhook Tokens
+
+Bearer tokens identify webhook callers: each token maps to one user ID.
+Changes are saved to the config and applied to a running daemon without a
+restart (Unix; on Windows run 'magabot restart').
+
+Usage: magabot webhook token <command>
+
+Commands:
+  add <user>      Generate a token for user and print it once
+  list, ls        Show users and token fingerprints
+  revoke <user>   Delete every token of user and drop user from allowed_users
+  help            Show this help`)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kusa/magabot/internal/config"
+)
+
+func TestTokenFingerprint(t *testing.T) {
+	fp := tokenFingerprint("secret-token")
+	if !strings.HasPrefix(fp, "sha256:") || len(fp) != len("sha256:")+12 {
+		t.Errorf("fingerprint = %q, want sha256: and 12 hex digits", fp)
+	}
+	if strings.Contains(fp, "secret") {
+		t.Errorf("fingerprint %q reveals the token", fp)
+	}
+	if fp != tokenFingerprint("secret-token") {
+		t.Error("fingerprint is not stable")
+	}
+	if fp == tokenFingerprint("other-token") {
+		t.Error("different tokens share a fingerprint")
+	}
+}
+
+func TestRevokeTokens(t *testing.T) {
+	tokens := map[string]string{
+		"a1": "alice",
+		"a2": "alice",
+		"b1": "bob",
+	}
+	if n := revokeTokens(tokens, "alice"); n != 2 {
+		t.Errorf("revoked %d tokens, want 2", n)
+	}
+	if len(tokens) != 1 || tokens["b1"] != "bob" {
+		t.Errorf("tokens after revoke = %v, want only bob's", tokens)
+	}
+	if n := revokeTokens(tokens, "carol"); n != 0 {
+		t.Errorf("revoked %d tokens for unknown user, want 0", n)
+	}
+}
+
+func TestDisallowUser(t *testing.T) {
+	wh := &config.WebhookConfig{
+		AllowedUsers: []string{"alice", "bob", "carol", "ci-*"},
+		HMACUsers:    map[string]string{"secret": "bob"},
+		BasicUser:    "carol",
+	}
+	if !disallowUser(wh, "alice") {
+		t.Error("alice should be removed")
+	}
+	for _, user := range []string{"bob", "carol", "ci-1", "dave"} {
+		if disallowUser(wh, user) {
+			t.Errorf("%s should be kept", user)
+		}
+	}
+	if got := strings.Join(wh.AllowedUsers, ","); got != "bob,carol,ci-*" {
+		t.Errorf("allowed_users = %s, want bob,carol,ci-*", got)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/kusa/magabot/internal/config"
 	"github.com/kusa/magabot/internal/llm"
 	"github.com/kusa/magabot/internal/secrets"
 	"github.com/kusa/magabot/internal/security"
@@ -37,6 +39,7 @@ type WizardState struct {
 
 	DiscordEnabled bool
 	DiscordToken   string
+	DiscordPrefix  string
 	DiscordUserID  string
 
 	SlackEnabled       bool
@@ -48,6 +51,7 @@ type WizardState struct {
 	SlackSigningSecret string
 
 	WhatsAppEnabled bool
+	WhatsAppDBPath  string
 
 	// LLM
 	LLMDefault       string
@@ -297,7 +301,7 @@ func step2Platforms(reader *bufio.Reader, state *WizardState) {
 			fmt.Println("  2. Send /newbot and follow the instructions")
 			fmt.Println("  3. Copy the token (format: 123456:ABC-DEF...)")
 			fmt.Println()
-			state.TelegramToken = askPassword(reader, "Bot token")
+			state.TelegramToken = askVerifiedToken(reader, "Bot token", checkTelegramToken)
 
 			// Webhook configuration
 			if state.TelegramMode == "webhook" || state.TelegramMode == "both" {
@@ -329,6 +333,10 @@ func step2Platforms(reader *bufio.Reader, state *WizardState) {
 			fmt.Println("  3. Enable MESSAGE CONTENT INTENT")
 			fmt.Println()
 			state.DiscordToken = askPassword(reader, "Bot token")
+			state.DiscordPrefix = askString(reader, "Command prefix", "!")
+			fmt.Println()
+			fmt.Println("  To find your Discord user ID:")
+			fmt.Println("  Enable Developer Mode, then right-click your name → Copy User ID")
 			fmt.Println()
 			state.DiscordUserID = askString(reader, "Your Discord user ID (optional)", "")
 
@@ -359,7 +367,7 @@ func step2Platforms(reader *bufio.Reader, state *WizardState) {
 			fmt.Println("  2. Add Bot Token Scopes and install to workspace")
 			fmt.Println("  3. Copy Bot Token (xoxb-...)")
 			fmt.Println()
-			state.SlackBotToken = askPassword(reader, "Bot token (xoxb-...)")
+			state.SlackBotToken = askVerifiedToken(reader, "Bot token (xoxb-...)", checkSlackToken)
 
 			// Socket Mode (bot mode)
 			if state.SlackMode == "bot" || state.SlackMode == "both" {
@@ -387,6 +395,8 @@ func step2Platforms(reader *bufio.Reader, state *WizardState) {
 			fmt.Println("💬 WhatsApp Configuration")
 			fmt.Println("─────────────────────────")
 			fmt.Println("  ℹ️  WhatsApp will require QR code scan after starting the bot")
+			fmt.Println()
+			state.WhatsAppDBPath = askString(reader, "Session database path", filepath.Join(dataDir, "platform", "whatsapp", "whatsapp.db"))
 
 		}
 	}
@@ -502,7 +512,7 @@ func step3LLM(reader *bufio.Reader, state *WizardState) {
 		fmt.Println("───────────────────────")
 		fmt.Println("  Get API key: https://platform.openai.com/api-keys")
 		fmt.Println()
-		state.OpenAIKey = askPassword(reader, "API key (sk-...)")
+		state.OpenAIKey = askVerifiedToken(reader, "API key (sk-...)", checkOpenAIKey)
 
 	case "glm":
 		state.GLMEnabled = true
@@ -710,11 +720,18 @@ func askInt(reader *bufio.Reader, prompt string, defaultVal int) int {
 	return val
 }
 
+// isNumericID reports whether id looks like a Telegram/Discord numeric user ID.
+func isNumericID(id string) bool {
+	matched, _ := regexp.MatchString(`^\d+$`, id)
+	return matched
+}
+
 func generateWizardConfig(state *WizardState) string {
 	var b strings.Builder
 
 	b.WriteString("# Magabot Configuration\n")
 	b.WriteString("# Generated by setup wizard\n\n")
+	fmt.Fprintf(&b, "version: \"%d\"\n\n", config.CurrentVersion)
 
 	// Secrets backend
 	b.WriteString("secrets:\n")
@@ -730,11 +747,9 @@ func generateWizardConfig(state *WizardState) string {
 	// Security
 	b.WriteString("security:\n")
 	fmt.Fprintf(&b, "  encryption_key: \"%s\"\n", state.EncryptionKey)
-	if state.TelegramUserID != "" {
-		if matched, _ := regexp.MatchString(`^\d+$`, state.TelegramUserID); matched {
-			b.WriteString("  allowed_users:\n")
-			fmt.Fprintf(&b, "    telegram: [\"%s\"]\n", state.TelegramUserID)
-		}
+	if isNumericID(state.TelegramUserID) {
+		b.WriteString("  allowed_users:\n")
+		fmt.Fprintf(&b, "    telegram: [\"%s\"]\n", state.TelegramUserID)
 	}
 	b.WriteString("  rate_limit:\n")
 	b.WriteString("    messages_per_minute: 30\n")
@@ -747,6 +762,9 @@ func generateWizardConfig(state *WizardState) string {
 		b.WriteString("  telegram:\n")
 		b.WriteString("    enabled: true\n")
 		fmt.Fprintf(&b, "    bot_token: \"%s\"\n", state.TelegramToken)
+		if isNumericID(state.TelegramUserID) {
+			fmt.Fprintf(&b, "    admins: [\"%s\"]\n", state.TelegramUserID)
+		}
 		if state.TelegramMode == "webhook" || state.TelegramMode == "both" {
 			b.WriteString("    use_webhook: true\n")
 			fmt.Fprintf(&b, "    webhook_url: \"%s\"\n", state.TelegramWebhookURL)
@@ -761,6 +779,12 @@ func generateWizardConfig(state *WizardState) string {
 		b.WriteString("  discord:\n")
 		b.WriteString("    enabled: true\n")
 		fmt.Fprintf(&b, "    token: \"%s\"\n", state.DiscordToken)
+		if state.DiscordPrefix != "" {
+			fmt.Fprintf(&b, "    prefix: \"%s\"\n", state.DiscordPrefix)
+		}
+		if isNumericID(state.DiscordUserID) {
+			fmt.Fprintf(&b, "    admins: [\"%s\"]\n", state.DiscordUserID)
+		}
 		b.WriteString("\n")
 	}
 
@@ -782,7 +806,11 @@ func generateWizardConfig(state *WizardState) string {
 
 	if state.WhatsAppEnabled {
 		b.WriteString("  whatsapp:\n")
-		b.WriteString("    enabled: true\n\n")
+		b.WriteString("    enabled: true\n")
+		if state.WhatsAppDBPath != "" {
+			fmt.Fprintf(&b, "    db_path: \"%s\"\n", state.WhatsAppDBPath)
+		}
+		b.WriteString("\n")
 	}
 
 	b.WriteString("\n")
@@ -815,7 +843,7 @@ func generateWizardConfig(state *WizardState) string {
 	fmt.Fprintf(&b, "  default: \"%s\"\n", state.LLMDefault)
 	b.WriteString("  system_prompt: |\n")
 	b.WriteString("    You are a helpful AI assistant. Be concise and friendly.\n")
-	b.WriteString("  max_input_length: 10000\n")
+	b.WriteString("  max_input_tokens: 10000\n")
 	b.WriteString("  timeout: 2m\n")
 	b.WriteString("  max_context_chars: 250000\n")
 	b.WriteString("  rate_limit: 10\n\n")
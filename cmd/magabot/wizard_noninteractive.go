@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kusa/magabot/internal/security"
+	"gopkg.in/yaml.v3"
+)
+
+// answerEnvPrefix is prepended (upper-cased) to answer keys when reading the
+// environment, e.g. telegram_token → MAGABOT_TELEGRAM_TOKEN.
+const answerEnvPrefix = "MAGABOT_"
+
+// answerKeys lists every answer understood by non-interactive setup.
+var answerKeys = []string{
+	"secrets_backend", "vault_address", "vault_token",
+	"encryption_key",
+	"telegram_token", "telegram_user_id", "telegram_mode",
+	"telegram_webhook_url", "telegram_webhook_port", "telegram_webhook_path", "telegram_webhook_secret",
+	"discord_token", "discord_prefix", "discord_user_id",
+	"slack_bot_token", "slack_app_token", "slack_mode",
+	"slack_signing_secret", "slack_webhook_port", "slack_webhook_path",
+	"whatsapp_enabled", "whatsapp_db_path",
+	"llm_default", "effort",
+	"anthropic_api_key", "anthropic_mode", "anthropic_model",
+	"openai_api_key",
+	"glm_api_key", "glm_base_url", "glm_model",
+	"kimi_api_key", "kimi_base_url", "kimi_model",
+	"minimax_api_key", "minimax_base_url", "minimax_model",
+	"local_base_url", "local_model",
+}
+
+// setupAnswers resolves answers from the environment first, then from an
+// optional YAML answers file.
+type setupAnswers struct {
+	file map[string]string
+}
+
+// loadSetupAnswers reads a flat YAML answers file (keys as in answerKeys).
+// An empty path yields an env-only answer set.
+func loadSetupAnswers(path string) (*setupAnswers, error) {
+	a := &setupAnswers{file: map[string]string{}}
+	if path == "" {
+		return a, nil
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("read answers file: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse answers file: %w", err)
+	}
+
+	known := make(map[string]bool, len(answerKeys))
+	for _, k := range answerKeys {
+		known[k] = true
+	}
+	for k, v := range raw {
+		k = strings.ToLower(k)
+		if !known[k] {
+			return nil, fmt.Errorf("answers file: unknown key %q", k)
+		}
+		if v != nil {
+			a.file[k] = fmt.Sprint(v)
+		}
+	}
+	return a, nil
+}
+
+// get returns the answer for key, preferring MAGABOT_<KEY> from the environment.
+func (a *setupAnswers) get(key string) string {
+	if v, ok := os.LookupEnv(answerEnvPrefix + strings.ToUpper(key)); ok {
+		return strings.TrimSpace(v)
+	}
+	return strings.TrimSpace(a.file[key])
+}
+
+// getOr returns the answer for key, or def when unset.
+func (a *setupAnswers) getOr(key, def string) string {
+	if v := a.get(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// stateFromAnswers builds a WizardState equivalent to what the interactive
+// wizard would produce. All missing required values are reported together.
+func stateFromAnswers(a *setupAnswers) (*WizardState, error) {
+	var missing, invalid []string
+	require := func(key string) string {
+		v := a.get(key)
+		if v == "" {
+			missing = append(missing, answerEnvPrefix+strings.ToUpper(key))
+		}
+		return v
+	}
+	intAnswer := func(key string, def int) int {
+		v := a.get(key)
+		if v == "" {
+			return def
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 65535 {
+			invalid = append(invalid, fmt.Sprintf("%s%s=%q (want a port number)", answerEnvPrefix, strings.ToUpper(key), v))
+			return def
+		}
+		return n
+	}
+	mode := func(key string) string {
+		switch v := strings.ToLower(a.getOr(key, "bot")); v {
+		case "bot", "webhook", "both":
+			return v
+		default:
+			invalid = append(invalid, fmt.Sprintf("%s%s=%q (want bot, webhook or both)", answerEnvPrefix, strings.ToUpper(key), v))
+			return "bot"
+		}
+	}
+
+	state := &WizardState{
+		SecretsBackend: strings.ToLower(a.getOr("secrets_backend", "local")),
+		EncryptionKey:  a.get("encryption_key"),
+	}
+
+	// Secrets backend
+	switch state.SecretsBackend {
+	case "local":
+	case "vault":
+		state.VaultAddress = require("vault_address")
+		state.VaultToken = require("vault_token")
+	default:
+		invalid = append(invalid, fmt.Sprintf("%sSECRETS_BACKEND=%q (want local or vault)", answerEnvPrefix, state.SecretsBackend))
+	}
+	if state.EncryptionKey == "" {
+		state.EncryptionKey = security.GenerateKey()
+	}
+
+	// Platforms — enabled by supplying their token
+	if state.TelegramToken = a.get("telegram_token"); state.TelegramToken != "" {
+		state.TelegramEnabled = true
+		state.TelegramUserID = a.get("telegram_user_id")
+		state.TelegramMode = mode("telegram_mode")
+		if state.TelegramMode != "bot" {
+			state.TelegramWebhookURL = require("telegram_webhook_url")
+			state.TelegramWebhookPort = intAnswer("telegram_webhook_port", 8443)
+			state.TelegramWebhookPath = a.getOr("telegram_webhook_path", "/telegram")
+			state.TelegramWebhookSecret = a.getOr("telegram_webhook_secret", security.GenerateKey()[:32])
+		}
+	}
+
+	if state.DiscordToken = a.get("discord_token"); state.DiscordToken != "" {
+		state.DiscordEnabled = true
+		state.DiscordPrefix = a.getOr("discord_prefix", "!")
+		state.DiscordUserID = a.get("discord_user_id")
+	}
+
+	if state.SlackBotToken = a.get("slack_bot_token"); state.SlackBotToken != "" {
+		state.SlackEnabled = true
+		state.SlackMode = mode("slack_mode")
+		if state.SlackMode != "webhook" {
+			state.SlackAppToken = require("slack_app_token")
+		}
+		if state.SlackMode != "bot" {
+			state.SlackSigningSecret = require("slack_signing_secret")
+			state.SlackWebhookPort = intAnswer("slack_webhook_port", 3000)
+			state.SlackWebhookPath = a.getOr("slack_webhook_path", "/slack/events")
+		}
+	}
+
+	if v := a.get("whatsapp_enabled"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%sWHATSAPP_ENABLED=%q (want true or false)", answerEnvPrefix, v))
+		}
+		state.WhatsAppEnabled = enabled
+		if enabled {
+			state.WhatsAppDBPath = a.get("whatsapp_db_path")
+		}
+	}
+
+	// LLM — default provider, inferred from the first supplied credential
+	state.LLMDefault = strings.ToLower(a.get("llm_default"))
+	if state.LLMDefault == "" {
+		for _, name := range []string{"anthropic", "openai", "glm", "kimi", "minimax"} {
+			if a.get(name+"_api_key") != "" {
+				state.LLMDefault = name
+				break
+			}
+		}
+		if state.LLMDefault == "" && a.get("local_base_url") != "" {
+			state.LLMDefault = "local"
+		}
+	}
+	state.Effort = a.get("effort")
+
+	switch state.LLMDefault {
+	case "anthropic":
+		state.AnthropicEnabled = true
+		state.AnthropicMode = strings.ToLower(a.getOr("anthropic_mode", "api"))
+		state.AnthropicModel = a.get("anthropic_model")
+		switch state.AnthropicMode {
+		case "api":
+			state.AnthropicKey = require("anthropic_api_key")
+		case "cli":
+		default:
+			invalid = append(invalid, fmt.Sprintf("%sANTHROPIC_MODE=%q (want api or cli)", answerEnvPrefix, state.AnthropicMode))
+		}
+	case "openai":
+		state.OpenAIEnabled = true
+		state.OpenAIKey = require("openai_api_key")
+	case "glm":
+		state.GLMEnabled = true
+		state.GLMKey = require("glm_api_key")
+		state.GLMBaseURL = a.getOr("glm_base_url", "https://api.z.ai/api/anthropic")
+		state.GLMModel = a.get("glm_model")
+	case "kimi":
+		state.KimiEnabled = true
+		state.KimiKey = require("kimi_api_key")
+		state.KimiBaseURL = a.getOr("kimi_base_url", "https://api.moonshot.ai/anthropic")
+		state.KimiModel = a.get("kimi_model")
+	case "minimax":
+		state.MiniMaxEnabled = true
+		state.MiniMaxKey = require("minimax_api_key")
+		state.MiniMaxBaseURL = a.getOr("minimax_base_url", "https://api.minimax.io/anthropic")
+		state.MiniMaxModel = a.get("minimax_model")
+	case "local":
+		state.LocalEnabled = true
+		state.LocalBaseURL = a.getOr("local_base_url", "http://localhost:11434/v1")
+		state.LocalModel = a.getOr("local_model", "llama3")
+	case "":
+		missing = append(missing, answerEnvPrefix+"LLM_DEFAULT (or a provider API key)")
+	default:
+		invalid = append(invalid, fmt.Sprintf("%sLLM_DEFAULT=%q (want anthropic, openai, glm, kimi, minimax or local)", answerEnvPrefix, state.LLMDefault))
+	}
+
+	if len(missing) > 0 || len(invalid) > 0 {
+		var b strings.Builder
+		b.WriteString("non-interactive setup: incomplete answers")
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			b.WriteString("\n  missing: " + strings.Join(missing, ", "))
+		}
+		for _, inv := range invalid {
+			b.WriteString("\n  invalid: " + inv)
+		}
+		return nil, fmt.Errorf("%s", b.String())
+	}
+	return state, nil
+}
+
+// runNonInteractiveSetup handles `magabot setup --non-interactive`.
+// Flags: --answers <file> (YAML answers), --force (overwrite existing config).
+func runNonInteractiveSetup(args []string) {
+	var answersFile string
+	force := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--non-interactive", "--from-env":
+		case "--force", "-f":
+			force = true
+		case "--answers":
+			if i+1 >= len(args) {
+				fmt.Println("❌ --answers requires a file path")
+				os.Exit(1)
+			}
+			i++
+			answersFile = args[i]
+		default:
+			fmt.Printf("❌ Unknown flag: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	if _, err := os.Stat(configFile); err == nil && !force {
+		fmt.Println("❌ Config already exists:", configFile)
+		fmt.Println("   Pass --force to overwrite it.")
+		os.Exit(1)
+	}
+
+	answers, err := loadSetupAnswers(answersFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	state, err := stateFromAnswers(answers)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	ensureDirs()
+	if err := os.WriteFile(configFile, []byte(generateWizardConfig(state)), 0600); err != nil {
+		fmt.Printf("❌ Failed to write config: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✅ Configuration saved to:", configFile)
+
+	if state.SecretsBackend == "vault" {
+		storeSecretsInVault(state)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kusa/magabot/internal/config"
+)
+
+func TestCheckTelegramToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bot123:good/getMe" {
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"username":"magabot"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"ok":false,"description":"Unauthorized"}`))
+	}))
+	defer srv.Close()
+	orig := telegramAPIBase
+	telegramAPIBase = srv.URL
+	defer func() { telegramAPIBase = orig }()
+
+	who, err := checkTelegramToken(context.Background(), "123:good")
+	if err != nil || who != "@magabot" {
+		t.Errorf("good token: got (%q, %v)", who, err)
+	}
+	if _, err := checkTelegramToken(context.Background(), "123:bad"); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("bad token: expected Unauthorized error, got %v", err)
+	}
+}
+
+func TestCheckSlackToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer xoxb-good" {
+			_, _ = w.Write([]byte(`{"ok":true,"user":"magabot","team":"acme"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+	}))
+	defer srv.Close()
+	orig := slackAPIBase
+	slackAPIBase = srv.URL
+	defer func() { slackAPIBase = orig }()
+
+	who, err := checkSlackToken(context.Background(), "xoxb-good")
+	if err != nil || who != "magabot (acme)" {
+		t.Errorf("good token: got (%q, %v)", who, err)
+	}
+	if _, err := checkSlackToken(context.Background(), "xoxb-bad"); err == nil || !strings.Contains(err.Error(), "invalid_auth") {
+		t.Errorf("bad token: expected invalid_auth error, got %v", err)
+	}
+}
+
+func TestCheckOpenAIKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" && r.Header.Get("Authorization") == "Bearer sk-good" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"message":"Incorrect API key provided"}}`))
+	}))
+	defer srv.Close()
+	orig := openAIAPIBase
+	openAIAPIBase = srv.URL
+	defer func() { openAIAPIBase = orig }()
+
+	who, err := checkOpenAIKey(context.Background(), "sk-good")
+	if err != nil || who != "OpenAI (2 models available)" {
+		t.Errorf("good key: got (%q, %v)", who, err)
+	}
+	if _, err := checkOpenAIKey(context.Background(), "sk-bad"); err == nil || !strings.Contains(err.Error(), "Incorrect API key") {
+		t.Errorf("bad key: expected the API's error, got %v", err)
+	}
+
+	// The wizard's timeout reaches the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := checkOpenAIKey(ctx, "sk-good"); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled check: got %v, want context.Canceled", err)
+	}
+}
+
+func TestAskVerifiedToken(t *testing.T) {
+	check := func(_ context.Context, token string) (string, error) {
+		if token == "good" {
+			return "ok", nil
+		}
+		return "", errors.New("rejected")
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"skip test", "bad\nn\n", "bad"},
+		{"passes", "good\n\n", "good"},
+		{"re-enter after failure", "bad\ny\ny\ngood\ny\n", "good"},
+		{"keep after failure", "bad\ny\nn\n", "bad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input))
+			if got := askVerifiedToken(reader, "Token", check); got != tt.want {
+				t.Errorf("askVerifiedToken = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateWizardConfig_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	state := &WizardState{
+		SecretsBackend:        "local",
+		EncryptionKey:         "test-key",
+		TelegramEnabled:       true,
+		TelegramToken:         "123:abc",
+		TelegramUserID:        "111",
+		TelegramMode:          "webhook",
+		TelegramWebhookURL:    "https://example.com",
+		TelegramWebhookPort:   8443,
+		TelegramWebhookPath:   "/telegram",
+		TelegramWebhookSecret: "tg-secret",
+		DiscordEnabled:        true,
+		DiscordToken:          "discord-token",
+		DiscordPrefix:         "?",
+		DiscordUserID:         "222",
+		SlackEnabled:          true,
+		SlackBotToken:         "xoxb-1",
+		SlackAppToken:         "xapp-1",
+		SlackMode:             "both",
+		SlackWebhookPort:      3000,
+		SlackWebhookPath:      "/slack/events",
+		SlackSigningSecret:    "slack-secret",
+		WhatsAppEnabled:       true,
+		WhatsAppDBPath:        filepath.Join(dir, "wa", "session.db"),
+		LLMDefault:            "openai",
+		OpenAIEnabled:         true,
+		OpenAIKey:             "sk-test",
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(generateWizardConfig(state)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load generated config: %v", err)
+	}
+
+	tg := cfg.Platforms.Telegram
+	if tg == nil || !tg.Enabled || tg.BotToken != "123:abc" || !tg.UseWebhook ||
+		tg.WebhookURL != "https://example.com" || tg.WebhookPort != 8443 || tg.WebhookSecret != "tg-secret" {
+		t.Errorf("telegram not round-tripped: %+v", tg)
+	} else if !reflect.DeepEqual(tg.Admins, []string{"111"}) {
+		t.Errorf("telegram admins = %v, want [111]", tg.Admins)
+	}
+
+	dc := cfg.Platforms.Discord
+	if dc == nil || !dc.Enabled || dc.Token != "discord-token" || dc.Prefix != "?" {
+		t.Errorf("discord not round-tripped: %+v", dc)
+	} else if !reflect.DeepEqual(dc.Admins, []string{"222"}) {
+		t.Errorf("discord admins = %v, want [222]", dc.Admins)
+	}
+
+	sl := cfg.Platforms.Slack
+	if sl == nil || !sl.Enabled || sl.BotToken != "xoxb-1" || sl.AppToken != "xapp-1" ||
+		!sl.UseWebhook || sl.WebhookPort != 3000 || sl.SigningSecret != "slack-secret" {
+		t.Errorf("slack not round-tripped: %+v", sl)
+	}
+
+	wa := cfg.Platforms.WhatsApp
+	if wa == nil || !wa.Enabled || wa.DBPath != state.WhatsAppDBPath {
+		t.Errorf("whatsapp not round-tripped: %+v", wa)
+	}
+}
+
+func TestGenerateWizardConfig_OmitsDisabledPlatforms(t *testing.T) {
+	dir := t.TempDir()
+	state := &WizardState{SecretsBackend: "local", DiscordEnabled: true, DiscordToken: "t", DiscordUserID: "not-a-number"}
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(generateWizardConfig(state)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load generated config: %v", err)
+	}
+	if cfg.Platforms.Telegram != nil || cfg.Platforms.Slack != nil || cfg.Platforms.WhatsApp != nil {
+		t.Error("disabled platforms should not be written")
+	}
+	if dc := cfg.Platforms.Discord; dc == nil || dc.Prefix != "!" || len(dc.Admins) != 0 {
+		t.Errorf("discord = %+v, want default prefix and no admins", dc)
+	}
+}
+
+func TestStateFromAnswers_EnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "answers.yaml")
+	answersYAML := "telegram_token: \"123:file\"\nopenai_api_key: sk-file\nslack_bot_token: xoxb-1\nslack_mode: webhook\nslack_signing_secret: s3cret\nslack_webhook_port: 4000\n"
+	if err := os.WriteFile(path, []byte(answersYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MAGABOT_TELEGRAM_TOKEN", "123:env")
+
+	answers, err := loadSetupAnswers(path)
+	if err != nil {
+		t.Fatalf("loadSetupAnswers: %v", err)
+	}
+	state, err := stateFromAnswers(answers)
+	if err != nil {
+		t.Fatalf("stateFromAnswers: %v", err)
+	}
+
+	if !state.TelegramEnabled || state.TelegramToken != "123:env" || state.TelegramMode != "bot" {
+		t.Errorf("telegram = %v %q %q, want env token in bot mode", state.TelegramEnabled, state.TelegramToken, state.TelegramMode)
+	}
+	if state.LLMDefault != "openai" || state.OpenAIKey != "sk-file" {
+		t.Errorf("llm = %q key %q, want inferred openai", state.LLMDefault, state.OpenAIKey)
+	}
+	if !state.SlackEnabled || state.SlackWebhookPort != 4000 || state.SlackAppToken != "" {
+		t.Errorf("slack webhook not populated: %+v", state)
+	}
+	if state.EncryptionKey == "" {
+		t.Error("encryption key should be generated")
+	}
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte(generateWizardConfig(state)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load generated config: %v", err)
+	}
+	if cfg.Platforms.Telegram == nil || cfg.Platforms.Telegram.BotToken != "123:env" {
+		t.Errorf("telegram token not written: %+v", cfg.Platforms.Telegram)
+	}
+}
+
+func TestStateFromAnswers_ReportsAllMissing(t *testing.T) {
+	t.Setenv("MAGABOT_LLM_DEFAULT", "anthropic")
+	t.Setenv("MAGABOT_SECRETS_BACKEND", "vault")
+	t.Setenv("MAGABOT_TELEGRAM_TOKEN", "123:abc")
+	t.Setenv("MAGABOT_TELEGRAM_MODE", "webhook")
+
+	answers, _ := loadSetupAnswers("")
+	_, err := stateFromAnswers(answers)
+	if err == nil {
+		t.Fatal("expected error for missing values")
+	}
+	for _, want := range []string{
+		"MAGABOT_ANTHROPIC_API_KEY",
+		"MAGABOT_VAULT_ADDRESS",
+		"MAGABOT_VAULT_TOKEN",
+		"MAGABOT_TELEGRAM_WEBHOOK_URL",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadSetupAnswers_UnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "answers.yaml")
+	if err := os.WriteFile(path, []byte("telegram_tokn: x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSetupAnswers(path); err == nil || !strings.Contains(err.Error(), "telegram_tokn") {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
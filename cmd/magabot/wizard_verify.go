@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// API endpoints used by the wizard's connection tests (overridable for tests).
+var (
+	telegramAPIBase = "https://api.telegram.org"
+	slackAPIBase    = "https://slack.com/api"
+	openAIAPIBase   = "https://api.openai.com/v1"
+)
+
+const tokenCheckTimeout = 10 * time.Second
+
+// tokenChecker verifies a credential against the live API and returns a short
+// description of the account it belongs to.
+type tokenChecker func(ctx context.Context, token string) (string, error)
+
+// askVerifiedToken prompts for a secret and, unless the user skips it (e.g.
+// offline setup), tests it against the live API. On failure the user may
+// re-enter the token or keep it as-is.
+func askVerifiedToken(reader *bufio.Reader, prompt string, check tokenChecker) string {
+	for {
+		token := askPassword(reader, prompt)
+		if token == "" || !askYesNo(reader, "Test connection now?", true) {
+			return token
+		}
+
+		fmt.Print("  🔄 Testing connection... ")
+		ctx, cancel := context.WithTimeout(context.Background(), tokenCheckTimeout)
+		who, err := check(ctx, token)
+		cancel()
+		if err == nil {
+			fmt.Printf("✅ Connected as %s\n", who)
+			return token
+		}
+
+		fmt.Printf("❌ %v\n", err)
+		if !askYesNo(reader, "Re-enter token?", true) {
+			return token
+		}
+	}
+}
+
+// checkTelegramToken calls Telegram's getMe.
+func checkTelegramToken(ctx context.Context, token string) (string, error) {
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      struct {
+			Username string `json:"username"`
+		} `json:"result"`
+	}
+	endpoint := telegramAPIBase + "/bot" + url.PathEscape(token) + "/getMe"
+	if err := getJSON(ctx, endpoint, "", &result); err != nil {
+		return "", err
+	}
+	if !result.OK {
+		return "", fmt.Errorf("telegram rejected token: %s", result.Description)
+	}
+	return "@" + result.Result.Username, nil
+}
+
+// checkSlackToken calls Slack's auth.test.
+func checkSlackToken(ctx context.Context, token string) (string, error) {
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		User  string `json:"user"`
+		Team  string `json:"team"`
+	}
+	if err := getJSON(ctx, slackAPIBase+"/auth.test", token, &result); err != nil {
+		return "", err
+	}
+	if !result.OK {
+		return "", fmt.Errorf("slack rejected token: %s", result.Error)
+	}
+	return fmt.Sprintf("%s (%s)", result.User, result.Team), nil
+}
+
+// checkOpenAIKey lists models with the key.
+func checkOpenAIKey(ctx context.Context, key string) (string, error) {
+	var result struct {
+		Data  []json.RawMessage `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := getJSON(ctx, openAIAPIBase+"/models", key, &result); err != nil {
+		return "", err
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("openai rejected key: %s", result.Error.Message)
+	}
+	return fmt.Sprintf("OpenAI (%d models available)", len(result.Data)), nil
+}
+
+// getJSON performs a GET request and decodes the JSON body. Transport errors
+// are unwrapped so the request URL (which may embed a token) is never shown.
+func getJSON(ctx context.Context, endpoint, bearer string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return errors.New("invalid token format")
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("unexpected response (HTTP %d): %s", resp.StatusCode, strings.TrimSpace(string(body[:min(len(body), 100)])))
+	}
+	return nil
+}
@@ -3,6 +3,7 @@
 package agent
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +27,13 @@ const (
 	AgentCodex  = "codex"
 )
 
+// DefaultSession is the name of the session started without one; plain
+// chat messages go to it.
+const DefaultSession = "default"
+
+// sessionNameRe matches valid session names.
+var sessionNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
 // CLISettings returns the current effort for Claude CLI.
 // This is called on each execution to pick up runtime changes from /effort.
 type CLISettings func() (effort string)
@@ -46,11 +55,26 @@ type Config struct {
 	OnSessionClose NotifyFunc        // optional: called when a session is auto-closed
 	OnUsage        func(int, int)    // optional: called with (inputTokens, outputTokens) after each request
 	CLIPath        string            // path to claude binary (default: "claude")
+	MaxSessions    int               // max concurrent sessions per chat (default 3)
+
+	// Policy restricts agent commands and paths; see Policy.
+	Policy Policy
+	// OnCommand, if set, is called for every command an agent runs, with
+	// the PolicyError when it was blocked (for the audit log).
+	OnCommand func(sess *Session, command string, blocked error)
+
+	// RecordDir, if set, is where each session's messages are recorded,
+	// one JSON line per message; see LoadTranscript.
+	RecordDir string
+	// Redact, if set, is applied to messages before they are kept in a
+	// session's transcript or recorded.
+	Redact func(string) string
 }
 
 // Session represents an active agent session tied to a chat.
 type Session struct {
 	mu           sync.Mutex
+	Name         string // session name within the chat (DefaultSession if unnamed)
 	Agent        string // agent type: claude, codex
 	Dir          string // working directory (resolved absolute path)
 	Platform     string
@@ -60,12 +84,14 @@ type Session struct {
 	StartTime    time.Time                   // when the session was created
 	LastActivity time.Time                   // last Execute() call (for idle timeout)
 	cli          *provider.ClaudeCLIProvider // Claude CLI provider (nil for non-Claude agents)
+	transcript   []TranscriptEntry           // messages so far, see Transcript
+	recordPath   string                      // recording file, once the first message is recorded
 }
 
 // Manager manages agent sessions across chats.
 type Manager struct {
 	mu       sync.RWMutex
-	sessions map[string]*Session // key: "platform:chatID"
+	sessions map[string]*Session // key: "platform:chatID", plus "/name" for named sessions
 	config   Config
 	logger   *slog.Logger
 	done     chan struct{} // signals idle cleanup goroutine to stop
@@ -98,6 +124,9 @@ func NewManager(cfg Config, logger *slog.Logger) *Manager {
 	if cfg.SessionTimeout < 0 {
 		cfg.SessionTimeout = 0
 	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = 3
+	}
 	m := &Manager{
 		sessions: make(map[string]*Session),
 		config:   cfg,
@@ -120,15 +149,48 @@ func sessionKey(platform, chatID string) string {
 	return platform + ":" + chatID
 }
 
+// namedSessionKey returns the map key for a named session in a chat.
+// The default session uses the plain chat key.
+func namedSessionKey(platform, chatID, name string) string {
+	if name == "" || name == DefaultSession {
+		return sessionKey(platform, chatID)
+	}
+	return sessionKey(platform, chatID) + "/" + name
+}
+
+// ValidSessionName reports whether name can be used for a named session.
+func ValidSessionName(name string) bool {
+	return sessionNameRe.MatchString(name)
+}
+
 // ValidAgent returns true if the agent type is recognized.
 func ValidAgent(agent string) bool {
 	_, ok := agentInfo[agent]
 	return ok
 }
 
-// NewSession creates and registers a new agent session.
+// NewSession creates and registers the default agent session for a chat.
 // Returns an error if the directory is not allowed, doesn't exist, or the agent binary is not in PATH.
 func (m *Manager) NewSession(platform, chatID, userID, agent, dir string) (*Session, error) {
+	return m.NewNamedSession(platform, chatID, DefaultSession, userID, agent, dir)
+}
+
+// NewNamedSession creates and registers an agent session under name, so
+// several agents can run side by side in one chat. It fails if the name is
+// taken or the chat already has MaxSessions sessions.
+func (m *Manager) NewNamedSession(platform, chatID, name, userID, agent, dir string) (*Session, error) {
+	if name == "" {
+		name = DefaultSession
+	}
+	if !ValidSessionName(name) {
+		return nil, fmt.Errorf("invalid session name %q (use lowercase letters, digits, - and _)", name)
+	}
+	m.mu.RLock()
+	err := m.checkCapacity(platform, chatID, name)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
 	if agent == "" {
 		agent = m.config.Main
 	}
@@ -136,6 +198,10 @@ func (m *Manager) NewSession(platform, chatID, userID, agent, dir string) (*Sess
 	if !ok {
 		return nil, fmt.Errorf("unknown agent %q (supported: claude, codex)", agent)
 	}
+	if agent == AgentCodex && m.config.Policy.active() {
+		// Codex does not report its commands, so the policy could not be enforced
+		return nil, &PolicyError{Action: "codex session", Reason: "agent.allowed_commands and agent.denied_paths only work with claude"}
+	}
 
 	// Resolve to absolute path to prevent traversal
 	absDir, err := filepath.Abs(dir)
@@ -143,10 +209,18 @@ func (m *Manager) NewSession(platform, chatID, userID, agent, dir string) (*Sess
 		return nil, fmt.Errorf("resolve path %q: %w", dir, err)
 	}
 
+	// Resolve symlinks so a link cannot point the session outside the jail
+	if resolved, err := filepath.EvalSymlinks(absDir); err == nil {
+		absDir = resolved
+	}
+
 	// Validate directory is under an allowed parent
 	if err := m.validateDir(absDir); err != nil {
 		return nil, err
 	}
+	if err := m.config.Policy.checkPath(absDir); err != nil {
+		return nil, fmt.Errorf("directory %q is denied", absDir)
+	}
 
 	// Validate directory exists
 	info, err := os.Stat(absDir)
@@ -162,8 +236,9 @@ func (m *Manager) NewSession(platform, chatID, userID, agent, dir string) (*Sess
 		return nil, fmt.Errorf("%q not found in PATH", bin)
 	}
 
-	key := sessionKey(platform, chatID)
+	key := namedSessionKey(platform, chatID, name)
 	sess := &Session{
+		Name:         name,
 		Agent:        agent,
 		Dir:          absDir,
 		Platform:     platform,
@@ -179,19 +254,32 @@ func (m *Manager) NewSession(platform, chatID, userID, agent, dir string) (*Sess
 		if cliPath == "" {
 			cliPath = "claude"
 		}
-		sess.cli = provider.ClaudeCLI(
+		opts := []provider.CLIOption{
 			provider.WithCLIPath(cliPath),
 			provider.WithCLIWorkDir(absDir),
 			provider.WithCLISessionPersist(true),
-		)
+		}
+		if m.config.Policy.active() {
+			// Let the CLI enforce the policy itself instead of skipping its
+			// permission checks; the stream check stays as a backstop
+			opts = append(opts,
+				provider.WithCLISkipPermissions(false),
+				provider.WithCLIAllowedTools(m.config.Policy.cliTools()),
+			)
+		}
+		sess.cli = provider.ClaudeCLI(opts...)
 	}
 
 	m.mu.Lock()
+	if err := m.checkCapacity(platform, chatID, name); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
 	m.sessions[key] = sess
 	m.mu.Unlock()
 
 	m.logger.Info("agent session created",
-		"agent", agent, "dir", absDir,
+		"name", name, "agent", agent, "dir", absDir,
 		"platform", platform, "chat_id", chatID,
 	)
 
@@ -211,11 +299,14 @@ func (m *Manager) validateDir(absDir string) error {
 	}
 
 	for _, parent := range allowed {
-		absParent, err := filepath.Abs(parent)
+		absParent, err := filepath.Abs(expandHome(parent))
 		if err != nil {
 			continue
 		}
-		if absDir == absParent || strings.HasPrefix(absDir, absParent+string(filepath.Separator)) {
+		if resolved, err := filepath.EvalSymlinks(absParent); err == nil {
+			absParent = resolved
+		}
+		if withinDir(absDir, absParent) {
 			return nil
 		}
 	}
@@ -298,26 +389,76 @@ func (m *Manager) ResolveDir(name string) (string, error) {
 	return name, nil
 }
 
-// GetSession returns the active session for a chat, or nil if none.
+// GetSession returns the chat's default session, or nil if none.
 func (m *Manager) GetSession(platform, chatID string) *Session {
+	return m.GetNamedSession(platform, chatID, DefaultSession)
+}
+
+// GetNamedSession returns the chat's session called name, or nil if none.
+func (m *Manager) GetNamedSession(platform, chatID, name string) *Session {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.sessions[sessionKey(platform, chatID)]
+	return m.sessions[namedSessionKey(platform, chatID, name)]
 }
 
-// HasSession returns true if there is an active session for the chat.
+// HasSession returns true if the chat has a default session.
 func (m *Manager) HasSession(platform, chatID string) bool {
 	return m.GetSession(platform, chatID) != nil
 }
 
-// CloseSession removes an active agent session.
+// ListSessions returns the chat's active sessions ordered by start time.
+func (m *Manager) ListSessions(platform, chatID string) []*Session {
+	m.mu.RLock()
+	var list []*Session
+	for _, sess := range m.sessions {
+		if sess.Platform == platform && sess.ChatID == chatID {
+			list = append(list, sess)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].GetStartTime().Before(list[j].GetStartTime())
+	})
+	return list
+}
+
+// checkCapacity returns an error when name is taken in the chat or the
+// chat is at MaxSessions. Caller must hold m.mu.
+func (m *Manager) checkCapacity(platform, chatID, name string) error {
+	if _, exists := m.sessions[namedSessionKey(platform, chatID, name)]; exists {
+		return fmt.Errorf("session %q is already active", name)
+	}
+	n := 0
+	for _, sess := range m.sessions {
+		if sess.Platform == platform && sess.ChatID == chatID {
+			n++
+		}
+	}
+	if n >= m.config.MaxSessions {
+		return fmt.Errorf("this chat already has %d agent sessions (max %d)", n, m.config.MaxSessions)
+	}
+	return nil
+}
+
+// CloseSession removes the chat's default session.
 func (m *Manager) CloseSession(platform, chatID string) {
-	key := sessionKey(platform, chatID)
+	m.CloseNamedSession(platform, chatID, DefaultSession)
+}
+
+// CloseNamedSession removes the chat's session called name. It reports
+// whether such a session existed.
+func (m *Manager) CloseNamedSession(platform, chatID, name string) bool {
+	key := namedSessionKey(platform, chatID, name)
 	m.mu.Lock()
+	_, ok := m.sessions[key]
 	delete(m.sessions, key)
 	m.mu.Unlock()
 
-	m.logger.Info("agent session closed", "platform", platform, "chat_id", chatID)
+	if ok {
+		m.logger.Info("agent session closed", "platform", platform, "chat_id", chatID, "name", name)
+	}
+	return ok
 }
 
 // Execute runs a message through the agent and returns the output.
@@ -326,22 +467,31 @@ func (m *Manager) CloseSession(platform, chatID string) {
 // keepalive, if non-nil, resets the idle timer whenever a value is received —
 // use this to extend the timeout when the caller sends progress messages to the user.
 // onText, if non-nil, is called with accumulated text content during streaming
-// so the caller can deliver partial results incrementally.
+// (each content chunk for Claude, each stdout line for Codex) so the caller can
+// deliver partial results incrementally. The returned output is always the full text.
 // skillContext, if non-empty, is appended to the CLI system prompt for this request.
+// The message and the output are added to the session's transcript.
 func (m *Manager) Execute(ctx context.Context, sess *Session, message string, media []string, onProgress func(string), onText func(string), keepalive <-chan struct{}, skillContext string) (string, error) {
 	sess.Touch()
 	timeout := time.Duration(m.config.Timeout) * time.Second
 	maxRetries := m.config.MaxRetries
+	m.record(sess, RoleUser, message, media, nil)
 
+	var output string
+	var err error
 	if sess.Agent == AgentClaude && sess.cli != nil {
-		return m.executeClaude(ctx, sess, message, media, onProgress, onText, keepalive, skillContext, timeout, maxRetries)
+		output, err = m.executeClaude(ctx, sess, message, media, onProgress, onText, keepalive, skillContext, timeout, maxRetries)
+	} else {
+		output, err = m.executeCodex(ctx, sess, message, onText, timeout)
 	}
-	return m.executeCodex(ctx, sess, message, timeout)
+	m.record(sess, RoleAgent, output, nil, err)
+	return output, err
 }
 
 // executeClaude runs a message through Claude CLI via allm-go provider.
 func (m *Manager) executeClaude(ctx context.Context, sess *Session, message string, media []string, onProgress func(string), onText func(string), keepalive <-chan struct{}, skillContext string, timeout time.Duration, maxRetries int) (string, error) {
-	streaming := onProgress != nil
+	// Tool calls are only visible when streaming, so a policy forces it
+	streaming := onProgress != nil || m.config.Policy.active()
 
 	var allOutput []string
 
@@ -503,6 +653,8 @@ func (m *Manager) buildRequest(sess *Session, message string, media []string, co
 // onText, if non-nil, is called with accumulated text on each content chunk
 // so the caller can deliver partial results to the user incrementally.
 func (m *Manager) streamClaude(ctx context.Context, sess *Session, req *allm.Request, onProgress func(string), onText func(string), keepalive <-chan struct{}, idle *time.Timer, timeout time.Duration) (string, error) {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
 	ch := sess.cli.Stream(ctx, req)
 
 	// When the caller sends a progress message to the user (keepalive signal),
@@ -547,6 +699,14 @@ func (m *Manager) streamClaude(ctx context.Context, sess *Session, req *allm.Req
 		}
 
 		if chunk.ToolUse != nil {
+			if err := m.checkToolUse(sess, chunk.ToolUse.Name, chunk.ToolUse.Input); err != nil {
+				// Kill the CLI and drain its output so the stream goroutine exits
+				stop()
+				for range ch {
+				}
+				return textContent.String(), err
+			}
+
 			// Track unique tool actions for the fallback summary
 			entry := summarizeToolUse(chunk.ToolUse.Name, chunk.ToolUse.Input)
 			if entry != "" && !toolSeen[entry] {
@@ -582,8 +742,28 @@ func (m *Manager) streamClaude(ctx context.Context, sess *Session, req *allm.Req
 	return result, nil
 }
 
+// checkToolUse checks a tool call against the session policy and reports
+// it to OnCommand.
+func (m *Manager) checkToolUse(sess *Session, name string, input json.RawMessage) error {
+	command, err := m.config.Policy.checkToolUse(sess, name, input)
+	if m.config.OnCommand != nil {
+		m.config.OnCommand(sess, command, err)
+	}
+	if err != nil {
+		m.logger.Warn("agent command blocked", "agent", sess.Agent, "dir", sess.Dir, "error", err)
+	}
+	return err
+}
+
+// codexFlushInterval is how often the Codex output is offered to onText
+// again while no new line arrives, so text the caller held back to throttle
+// its sends still goes out when Codex pauses.
+var codexFlushInterval = time.Second
+
 // executeCodex runs a message through Codex via direct exec.
-func (m *Manager) executeCodex(ctx context.Context, sess *Session, message string, timeout time.Duration) (string, error) {
+// onText, if non-nil, receives the output accumulated so far after each line
+// and every codexFlushInterval.
+func (m *Manager) executeCodex(ctx context.Context, sess *Session, message string, onText func(string), timeout time.Duration) (string, error) {
 	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -592,12 +772,65 @@ func (m *Manager) executeCodex(ctx context.Context, sess *Session, message strin
 
 	cmd := exec.CommandContext(attemptCtx, bin, args...)
 	cmd.Dir = sess.Dir
+	if m.config.OnCommand != nil {
+		m.config.OnCommand(sess, bin+" exec "+message, nil)
+	}
+
+	if onText == nil {
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("agent %s: %w", sess.Agent, err)
+		}
+		return strings.TrimSpace(StripANSI(string(out))), nil
+	}
 
-	out, err := cmd.Output()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return "", fmt.Errorf("agent %s: %w", sess.Agent, err)
 	}
-	return strings.TrimSpace(StripANSI(string(out))), nil
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("agent %s: %w", sess.Agent, err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(stdout)
+		for {
+			line, readErr := reader.ReadString('\n')
+			if line != "" {
+				lines <- line
+			}
+			if readErr != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(codexFlushInterval)
+	defer ticker.Stop()
+	var output strings.Builder
+	for lines != nil {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				continue
+			}
+			output.WriteString(StripANSI(line))
+			onText(output.String())
+		case <-ticker.C:
+			if output.Len() > 0 {
+				onText(output.String())
+			}
+		}
+	}
+
+	result := strings.TrimSpace(output.String())
+	if err := cmd.Wait(); err != nil {
+		return result, fmt.Errorf("agent %s: %w", sess.Agent, err)
+	}
+	return result, nil
 }
 
 // GetMsgCount returns the session message count (thread-safe).
@@ -670,9 +903,13 @@ func (m *Manager) sweepIdleSessions() {
 			"idle", now.Sub(sess.LastActivity).Truncate(time.Second).String(),
 		)
 		if m.config.OnSessionClose != nil {
+			label := sess.Agent + " in " + sess.Dir
+			if sess.Name != "" && sess.Name != DefaultSession {
+				label = sess.Name + ": " + label
+			}
 			m.config.OnSessionClose(sess.Platform, sess.ChatID,
-				fmt.Sprintf("⏱ Agent session (%s in %s) closed — idle for more than %s.",
-					sess.Agent, sess.Dir, timeout.Truncate(time.Second)))
+				fmt.Sprintf("⏱ Agent session (%s) closed — idle for more than %s.",
+					label, timeout.Truncate(time.Second)))
 		}
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -233,6 +234,69 @@ func TestExecuteCanceledContext(t *testing.T) {
 	}
 }
 
+func TestExecuteCodexStreamsLines(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the codex binary")
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho 'first line'\necho 'second line'\n"
+	if err := os.WriteFile(filepath.Join(bin, "codex"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	m := NewManager(Config{Timeout: 10}, nil)
+	sess := &Session{Agent: AgentCodex, Dir: t.TempDir()}
+
+	var updates []string
+	output, err := m.Execute(context.Background(), sess, "test", nil, nil, func(text string) {
+		updates = append(updates, text)
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if output != "first line\nsecond line" {
+		t.Errorf("output = %q", output)
+	}
+	want := []string{"first line\n", "first line\nsecond line\n"}
+	if len(updates) != len(want) || updates[0] != want[0] || updates[1] != want[1] {
+		t.Errorf("updates = %q, want %q", updates, want)
+	}
+}
+
+func TestExecuteCodexFlushesOnInterval(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the codex binary")
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho 'first line'\nsleep 0.5\necho 'second line'\n"
+	if err := os.WriteFile(filepath.Join(bin, "codex"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	orig := codexFlushInterval
+	codexFlushInterval = 50 * time.Millisecond
+	defer func() { codexFlushInterval = orig }()
+
+	m := NewManager(Config{Timeout: 10}, nil)
+	sess := &Session{Agent: AgentCodex, Dir: t.TempDir()}
+
+	// Offered again while Codex sleeps, without waiting for the next line
+	flushed := 0
+	_, err := m.Execute(context.Background(), sess, "test", nil, nil, func(text string) {
+		if text == "first line\n" {
+			flushed++
+		}
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if flushed < 2 {
+		t.Errorf("first line offered %d times, want it flushed again during the pause", flushed)
+	}
+}
+
 func TestSessionKey(t *testing.T) {
 	tests := []struct {
 		platform, chatID, want string
@@ -336,3 +400,47 @@ func TestManagerStopIdempotent(t *testing.T) {
 	m.Stop()
 	m.Stop() // should not panic
 }
+
+func TestNamedSessions(t *testing.T) {
+	m := NewManager(Config{MaxSessions: 2}, nil)
+	defer m.Stop()
+
+	// Insert sessions directly; NewNamedSession needs the agent binary
+	m.mu.Lock()
+	m.sessions[namedSessionKey("telegram", "1", DefaultSession)] = &Session{Name: DefaultSession, Platform: "telegram", ChatID: "1", StartTime: time.Now()}
+	m.sessions[namedSessionKey("telegram", "1", "api")] = &Session{Name: "api", Platform: "telegram", ChatID: "1", StartTime: time.Now().Add(time.Second)}
+	m.sessions[namedSessionKey("telegram", "2", "api")] = &Session{Name: "api", Platform: "telegram", ChatID: "2", StartTime: time.Now()}
+	m.mu.Unlock()
+
+	if got := m.GetNamedSession("telegram", "1", "api"); got == nil || got.Name != "api" {
+		t.Errorf("GetNamedSession(api) = %v", got)
+	}
+	if got := m.GetSession("telegram", "1"); got == nil || got.Name != DefaultSession {
+		t.Errorf("GetSession = %v, want default session", got)
+	}
+	if m.HasSession("telegram", "2") {
+		t.Error("chat 2 has only a named session, HasSession should be false")
+	}
+
+	list := m.ListSessions("telegram", "1")
+	if len(list) != 2 || list[0].Name != DefaultSession || list[1].Name != "api" {
+		t.Errorf("ListSessions = %v", list)
+	}
+
+	if _, err := m.NewNamedSession("telegram", "1", "web", "u", AgentClaude, t.TempDir()); err == nil || !strings.Contains(err.Error(), "max 2") {
+		t.Errorf("expected max sessions error, got %v", err)
+	}
+	if _, err := m.NewNamedSession("telegram", "1", "Bad Name", "u", AgentClaude, t.TempDir()); err == nil {
+		t.Error("expected invalid name error")
+	}
+
+	if !m.CloseNamedSession("telegram", "1", "api") {
+		t.Error("CloseNamedSession(api) = false")
+	}
+	if m.CloseNamedSession("telegram", "1", "api") {
+		t.Error("closing a closed session should report false")
+	}
+	if !m.HasSession("telegram", "1") {
+		t.Error("closing a named session should keep the default one")
+	}
+}
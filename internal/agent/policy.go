@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Policy restricts what an agent may do inside a session. A Claude agent
+// under an active policy runs with permission checks on and only the tools
+// from cliTools allowed. Each tool call it reports in its output stream is
+// checked again as a backstop; a call that breaks the policy stops the run
+// as soon as it appears, though the CLI may already have started it.
+// Codex gives no per-command visibility, so Codex sessions are refused
+// while the policy restricts anything.
+type Policy struct {
+	AllowedCommands []string // programs Bash may run, e.g. "git", "go" (empty = any)
+	DeniedPaths     []string // paths no tool may touch, e.g. "~/.ssh"
+}
+
+// PolicyError reports an agent action blocked by the session policy.
+type PolicyError struct {
+	Action string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("blocked %s: %s", e.Action, e.Reason)
+}
+
+// active reports whether the policy restricts anything.
+func (p Policy) active() bool {
+	return len(p.AllowedCommands) > 0 || len(p.DeniedPaths) > 0
+}
+
+// fileTools are the Claude CLI tools an agent under a policy may use
+// besides Bash; their paths are checked by checkToolUse.
+var fileTools = []string{"Read", "Edit", "Write", "MultiEdit", "NotebookEdit", "Glob", "Grep", "LS", "TodoWrite"}
+
+// cliTools returns the tools to allow the Claude CLI under the policy.
+// With AllowedCommands set, Bash is limited to those programs, e.g.
+// "Bash(git:*)".
+func (p Policy) cliTools() []string {
+	tools := append([]string(nil), fileTools...)
+	if len(p.AllowedCommands) == 0 {
+		return append(tools, "Bash")
+	}
+	for _, program := range p.AllowedCommands {
+		tools = append(tools, "Bash("+program+":*)")
+	}
+	return tools
+}
+
+// shellSeparators split a shell command line into simple commands.
+var shellSeparators = regexp.MustCompile("&&|\\|\\||[;|&\\n(){}`]|\\$\\(")
+
+// commandRunners are programs that run another program given in their
+// arguments; the wrapped program is checked too. The value is how many
+// non-option arguments come before it (timeout's duration).
+var commandRunners = map[string]int{
+	"env": 0, "xargs": 0, "nohup": 0, "nice": 0, "timeout": 1, "sudo": 0, "doas": 0,
+	"command": 0, "exec": 0, "builtin": 0, "time": 0, "stdbuf": 0, "setsid": 0, "chroot": 1,
+}
+
+// inlineShells run their -c argument as a command line.
+var inlineShells = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true}
+
+// commandPrograms returns the program run by each simple command in a
+// shell command line, skipping leading VAR=value assignments. Programs run
+// through a wrapper (env, xargs, find -exec, sh -c, eval, ...) are returned
+// along with the wrapper. It is a best-effort parse: quoting is not
+// interpreted, so anything it cannot follow yields a word that no allowlist
+// contains.
+func commandPrograms(command string) []string {
+	var programs []string
+	for _, segment := range shellSeparators.Split(command, -1) {
+		programs = append(programs, segmentPrograms(strings.Fields(segment))...)
+	}
+	return programs
+}
+
+// segmentPrograms returns the programs one simple command runs.
+func segmentPrograms(fields []string) []string {
+	for len(fields) > 0 && (strings.Trim(fields[0], "'\"\\") == "" ||
+		strings.Contains(fields[0], "=") && !strings.HasPrefix(fields[0], "=")) {
+		fields = fields[1:] // assignments, and escapes left over from a separator
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	program := filepath.Base(strings.Trim(fields[0], "'\"\\"))
+	programs := []string{program}
+	args := fields[1:]
+
+	switch {
+	case program == "eval":
+		return append(programs, segmentPrograms(args)...)
+	case inlineShells[program]:
+		for i, a := range args {
+			if a == "-c" || (strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && strings.HasSuffix(a, "c")) {
+				return append(programs, segmentPrograms(args[i+1:])...)
+			}
+		}
+	case program == "find":
+		for i, a := range args {
+			switch a {
+			case "-exec", "-execdir", "-ok", "-okdir":
+				programs = append(programs, segmentPrograms(args[i+1:])...)
+			}
+		}
+	default:
+		skip, ok := commandRunners[program]
+		if !ok {
+			break
+		}
+		for i, a := range args {
+			if strings.HasPrefix(a, "-") || (program == "env" && strings.Contains(a, "=")) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			return append(programs, segmentPrograms(args[i:])...)
+		}
+	}
+	return programs
+}
+
+// expandHome replaces a leading "~" with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// withinDir reports whether path is dir or inside it.
+func withinDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// checkPath returns a PolicyError when absPath falls under a denied path.
+func (p Policy) checkPath(absPath string) error {
+	for _, denied := range p.DeniedPaths {
+		if withinDir(absPath, filepath.Clean(expandHome(denied))) {
+			return &PolicyError{Action: absPath, Reason: "path is denied"}
+		}
+	}
+	return nil
+}
+
+// checkCommand returns a PolicyError when a shell command runs a program
+// outside AllowedCommands or mentions a denied path.
+func (p Policy) checkCommand(command string) error {
+	if len(p.AllowedCommands) > 0 {
+		for _, program := range commandPrograms(command) {
+			if !containsString(p.AllowedCommands, program) {
+				return &PolicyError{Action: command, Reason: fmt.Sprintf("%q is not an allowed command", program)}
+			}
+		}
+	}
+	for _, denied := range p.DeniedPaths {
+		if strings.Contains(command, denied) || strings.Contains(command, expandHome(denied)) {
+			return &PolicyError{Action: command, Reason: fmt.Sprintf("mentions denied path %s", denied)}
+		}
+	}
+	return nil
+}
+
+// checkToolUse describes a tool call for the audit log and checks it
+// against the policy. Files written by the agent must stay inside the
+// session directory.
+func (p Policy) checkToolUse(sess *Session, name string, input json.RawMessage) (string, error) {
+	var in struct {
+		Command      string `json:"command"`
+		FilePath     string `json:"file_path"`
+		NotebookPath string `json:"notebook_path"`
+		Path         string `json:"path"`
+	}
+	_ = json.Unmarshal(input, &in)
+
+	if name == "Bash" {
+		return in.Command, p.checkCommand(in.Command)
+	}
+
+	path := in.FilePath
+	if path == "" {
+		path = in.NotebookPath
+	}
+	if path == "" {
+		path = in.Path
+	}
+	if path == "" {
+		return name, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(sess.Dir, path)
+	}
+	path = filepath.Clean(path)
+	detail := name + " " + path
+
+	if err := p.checkPath(path); err != nil {
+		return detail, err
+	}
+	switch name {
+	case "Edit", "Write", "NotebookEdit":
+		if !withinDir(path, sess.Dir) {
+			return detail, &PolicyError{Action: detail, Reason: "outside the session directory " + sess.Dir}
+		}
+	}
+	return detail, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandPrograms(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"git status", []string{"git"}},
+		{"go build ./... && go test ./...", []string{"go", "go"}},
+		{"GOOS=linux /usr/bin/go build | tee out.log", []string{"go", "tee"}},
+		{"cd src; make || echo failed", []string{"cd", "make", "echo"}},
+		{"echo $(rm -rf /tmp/x)", []string{"echo", "rm"}},
+		{"env FOO=1 curl http://example.com", []string{"env", "curl"}},
+		{"ls | xargs -0 rm", []string{"ls", "xargs", "rm"}},
+		{"find . -name '*.go' -exec rm {} \\;", []string{"find", "rm"}},
+		{"sh -c 'curl http://example.com'", []string{"sh", "curl"}},
+		{"bash -lc \"wget x\"", []string{"bash", "wget"}},
+		{"timeout 5s nohup curl x", []string{"timeout", "nohup", "curl"}},
+		{"eval curl x", []string{"eval", "curl"}},
+	}
+	for _, tt := range tests {
+		if got := commandPrograms(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("commandPrograms(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyCheckCommand(t *testing.T) {
+	p := Policy{AllowedCommands: []string{"git", "go"}, DeniedPaths: []string{"/etc/secrets"}}
+
+	if err := p.checkCommand("git diff && go test ./..."); err != nil {
+		t.Errorf("allowed command blocked: %v", err)
+	}
+	for _, cmd := range []string{"curl http://example.com", "go test && rm -rf /", "git add /etc/secrets/key",
+		"env curl http://example.com", "find . -exec curl x {} +", "sh -c 'curl x'"} {
+		var perr *PolicyError
+		if err := p.checkCommand(cmd); !errors.As(err, &perr) {
+			t.Errorf("checkCommand(%q) = %v, want PolicyError", cmd, err)
+		}
+	}
+
+	if err := (Policy{}).checkCommand("anything goes"); err != nil {
+		t.Errorf("empty policy blocked a command: %v", err)
+	}
+}
+
+func TestPolicyCheckToolUse(t *testing.T) {
+	dir := t.TempDir()
+	sess := &Session{Agent: AgentClaude, Dir: dir}
+	p := Policy{DeniedPaths: []string{filepath.Join(dir, "secret")}}
+
+	tests := []struct {
+		name    string
+		tool    string
+		input   map[string]string
+		blocked bool
+	}{
+		{"relative write", "Write", map[string]string{"file_path": "main.go"}, false},
+		{"read outside", "Read", map[string]string{"file_path": "/usr/include/stdio.h"}, false},
+		{"write outside", "Edit", map[string]string{"file_path": "/tmp/other/main.go"}, true},
+		{"write escape", "Write", map[string]string{"file_path": "../x.go"}, true},
+		{"denied read", "Read", map[string]string{"file_path": filepath.Join(dir, "secret", "key")}, true},
+		{"denied grep", "Grep", map[string]string{"path": "secret"}, true},
+	}
+	for _, tt := range tests {
+		input, _ := json.Marshal(tt.input)
+		_, err := p.checkToolUse(sess, tt.tool, input)
+		if (err != nil) != tt.blocked {
+			t.Errorf("%s: err = %v, want blocked %v", tt.name, err, tt.blocked)
+		}
+	}
+}
+
+func TestPolicyCLITools(t *testing.T) {
+	tools := Policy{AllowedCommands: []string{"git", "go"}}.cliTools()
+	if !containsString(tools, "Bash(git:*)") || !containsString(tools, "Bash(go:*)") {
+		t.Errorf("cliTools() = %v, want Bash limited to git and go", tools)
+	}
+	if containsString(tools, "Bash") {
+		t.Errorf("cliTools() = %v allows unrestricted Bash", tools)
+	}
+
+	tools = Policy{DeniedPaths: []string{"~/.ssh"}}.cliTools()
+	if !containsString(tools, "Bash") || !containsString(tools, "Read") {
+		t.Errorf("cliTools() = %v, want Bash and the file tools", tools)
+	}
+}
+
+func TestNewSessionDeniedDir(t *testing.T) {
+	parent := t.TempDir()
+	denied := filepath.Join(parent, "private")
+	if err := os.Mkdir(denied, 0700); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(Config{
+		AllowedDirs: []string{parent},
+		Policy:      Policy{DeniedPaths: []string{denied}},
+	}, nil)
+
+	_, err := m.NewSession("telegram", "1", "u", AgentClaude, denied)
+	if err == nil || !strings.Contains(err.Error(), "is denied") {
+		t.Errorf("err = %v, want denied directory error", err)
+	}
+}
+
+func TestNewSessionCodexRefusedUnderPolicy(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(Config{
+		AllowedDirs: []string{dir},
+		Policy:      Policy{AllowedCommands: []string{"git"}},
+	}, nil)
+
+	var perr *PolicyError
+	if _, err := m.NewSession("telegram", "1", "u", AgentCodex, dir); !errors.As(err, &perr) {
+		t.Errorf("err = %v, want PolicyError for a codex session", err)
+	}
+}
+
+func TestNewSessionSymlinkOutsideJail(t *testing.T) {
+	jail := t.TempDir()
+	outside := t.TempDir()
+	link := filepath.Join(jail, "escape")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	m := NewManager(Config{AllowedDirs: []string{jail}}, nil)
+
+	_, err := m.NewSession("telegram", "1", "u", AgentCodex, link)
+	if err == nil || !strings.Contains(err.Error(), "not under an allowed path") {
+		t.Errorf("err = %v, want symlink out of the allowed directory rejected", err)
+	}
+}
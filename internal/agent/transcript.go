@@ -0,0 +1,143 @@
+// Recording agent sessions' messages
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Transcript roles.
+const (
+	RoleUser  = "user"
+	RoleAgent = "agent"
+)
+
+// maxTranscriptEntries bounds the transcript a session keeps in memory; the
+// recording file keeps everything.
+const maxTranscriptEntries = 1000
+
+// unsafeFileChars matches characters kept out of recording file names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// TranscriptEntry is one message to or from an agent session.
+type TranscriptEntry struct {
+	Time    time.Time `json:"time"`
+	Role    string    `json:"role"` // RoleUser or RoleAgent
+	Content string    `json:"content"`
+	Media   []string  `json:"media,omitempty"` // files sent with a user message
+	Error   string    `json:"error,omitempty"` // why the agent's run failed
+}
+
+// Transcript returns the session's messages so far, oldest first (at most
+// the last maxTranscriptEntries).
+func (s *Session) Transcript() []TranscriptEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]TranscriptEntry(nil), s.transcript...)
+}
+
+// RecordPath returns the file the session is recorded to, or "" when it
+// isn't recorded (yet).
+func (s *Session) RecordPath() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recordPath
+}
+
+// record adds a message to the session's transcript and, with
+// Config.RecordDir set, appends it to the session's recording. Content is
+// passed through Config.Redact first.
+func (m *Manager) record(sess *Session, role, content string, media []string, runErr error) {
+	entry := TranscriptEntry{Time: time.Now(), Role: role, Content: content, Media: media}
+	if runErr != nil {
+		entry.Error = runErr.Error()
+	}
+	if m.config.Redact != nil {
+		entry.Content = m.config.Redact(entry.Content)
+		entry.Error = m.config.Redact(entry.Error)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.transcript = append(sess.transcript, entry)
+	if n := len(sess.transcript) - maxTranscriptEntries; n > 0 {
+		sess.transcript = append(sess.transcript[:0:0], sess.transcript[n:]...)
+	}
+
+	if m.config.RecordDir == "" {
+		return
+	}
+	if sess.recordPath == "" {
+		sess.recordPath = filepath.Join(m.config.RecordDir, recordFileName(sess))
+	}
+	if err := appendRecord(sess.recordPath, entry); err != nil {
+		m.logger.Warn("record agent session failed", "path", sess.recordPath, "error", err)
+	}
+}
+
+// recordFileName names a session's recording after its start time, chat
+// and name.
+func recordFileName(sess *Session) string {
+	start := sess.StartTime
+	if start.IsZero() {
+		start = time.Now()
+	}
+	name := sess.Name
+	if name == "" {
+		name = DefaultSession
+	}
+	chat := unsafeFileChars.ReplaceAllString(sess.Platform+"-"+sess.ChatID, "_")
+	return fmt.Sprintf("agent-%s-%s-%s.jsonl", start.Format("20060102-150405"), chat, name)
+}
+
+// appendRecord appends entry to the recording at path as a JSON line.
+func appendRecord(path string, entry TranscriptEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create recording dir: %w", err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode entry: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304 -- path built from RecordDir
+	if err != nil {
+		return fmt.Errorf("open recording: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write recording: %w", err)
+	}
+	return f.Close()
+}
+
+// LoadTranscript reads a session recording written under Config.RecordDir.
+func LoadTranscript(path string) ([]TranscriptEntry, error) {
+	f, err := os.Open(path) // #nosec G304 -- caller-chosen recording file
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []TranscriptEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry TranscriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordedTranscriptRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the codex binary")
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho \"did: $3 with sk-abcdefghijklmnopqrstuvwx\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "codex"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	recordDir := t.TempDir()
+	m := NewManager(Config{
+		Timeout:   10,
+		RecordDir: recordDir,
+		Redact:    func(s string) string { return strings.ReplaceAll(s, "sk-abcdefghijklmnopqrstuvwx", "[REDACTED]") },
+	}, nil)
+	sess := &Session{Name: "api", Agent: AgentCodex, Dir: t.TempDir(), Platform: "telegram", ChatID: "-100/42", StartTime: time.Now()}
+
+	for _, message := range []string{"fix the tests", "now lint"} {
+		if _, err := m.Execute(context.Background(), sess, message, nil, nil, nil, nil, ""); err != nil {
+			t.Fatalf("Execute(%q): %v", message, err)
+		}
+	}
+
+	transcript := sess.Transcript()
+	if len(transcript) != 4 {
+		t.Fatalf("transcript has %d entries, want 4: %+v", len(transcript), transcript)
+	}
+	wantRoles := []string{RoleUser, RoleAgent, RoleUser, RoleAgent}
+	for i, e := range transcript {
+		if e.Role != wantRoles[i] {
+			t.Errorf("entry %d role = %q, want %q", i, e.Role, wantRoles[i])
+		}
+		if e.Time.IsZero() {
+			t.Errorf("entry %d has no timestamp", i)
+		}
+	}
+	if transcript[1].Content != "did: fix the tests with [REDACTED]" {
+		t.Errorf("agent output = %q, want it redacted", transcript[1].Content)
+	}
+
+	path := sess.RecordPath()
+	if filepath.Dir(path) != recordDir || strings.ContainsAny(filepath.Base(path), "/") || !strings.HasSuffix(path, "-telegram--100_42-api.jsonl") {
+		t.Errorf("RecordPath() = %q", path)
+	}
+	loaded, err := LoadTranscript(path)
+	if err != nil {
+		t.Fatalf("LoadTranscript: %v", err)
+	}
+	for i := range loaded {
+		if !loaded[i].Time.Equal(transcript[i].Time) {
+			t.Errorf("entry %d time = %v, want %v", i, loaded[i].Time, transcript[i].Time)
+		}
+		loaded[i].Time = transcript[i].Time
+	}
+	if !reflect.DeepEqual(loaded, transcript) {
+		t.Errorf("loaded transcript = %+v\nwant %+v", loaded, transcript)
+	}
+}
+
+func TestTranscriptWithoutRecording(t *testing.T) {
+	m := NewManager(Config{}, nil)
+	sess := &Session{Agent: AgentCodex}
+	m.record(sess, RoleUser, "hello", []string{"/tmp/a.png"}, nil)
+	if got := sess.Transcript(); len(got) != 1 || got[0].Content != "hello" || got[0].Media[0] != "/tmp/a.png" {
+		t.Errorf("Transcript() = %+v", got)
+	}
+	if sess.RecordPath() != "" {
+		t.Errorf("RecordPath() = %q, want none without RecordDir", sess.RecordPath())
+	}
+}
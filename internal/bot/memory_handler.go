@@ -1,29 +1,66 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
 
+	"github.com/kusa/magabot/internal/embedding"
 	"github.com/kusa/magabot/internal/memory"
+	"github.com/kusa/magabot/internal/telemetry"
 	"github.com/kusa/magabot/internal/util"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // MemoryHandler handles memory-related commands
 type MemoryHandler struct {
-	mu      sync.RWMutex
-	stores  map[string]*memory.Store // userID -> store
-	dataDir string
+	mu       sync.RWMutex
+	stores   map[string]*memory.Store         // userID -> store
+	semantic map[string]*memory.SemanticStore // userID -> store, when embedder is set
+	dataDir  string
+	embedder *embedding.Client
+
+	vectorDriver string // semantic store backend; empty = SQLite
+	vectorDSN    string
+
+	minSimilarity float32 // threshold for memories added to prompts
+	minResults    int
 }
 
-// NewMemoryHandler creates a new memory handler
-func NewMemoryHandler(dataDir string) *MemoryHandler {
+// NewMemoryHandler creates a new memory handler. With an embedding client,
+// memories are kept in a vector store and searched semantically; with nil,
+// search falls back to keywords.
+func NewMemoryHandler(dataDir string, embedder *embedding.Client) *MemoryHandler {
 	return &MemoryHandler{
-		stores:  make(map[string]*memory.Store),
-		dataDir: dataDir,
+		stores:   make(map[string]*memory.Store),
+		semantic: make(map[string]*memory.SemanticStore),
+		dataDir:  dataDir,
+		embedder: embedder,
 	}
 }
 
+// SetVectorStore selects where semantic memories are stored: an
+// embedding.Driver* name and, for pgvector, its Postgres DSN. Call before
+// the handler is used.
+func (h *MemoryHandler) SetVectorStore(driver, dsn string) {
+	h.vectorDriver = driver
+	h.vectorDSN = dsn
+}
+
+// SetRetrieval sets the similarity a memory needs to be added to prompts,
+// and how many of the best memories are added regardless. Call before the
+// handler is used.
+func (h *MemoryHandler) SetRetrieval(minSimilarity float32, minResults int) {
+	h.minSimilarity = minSimilarity
+	h.minResults = minResults
+}
+
+// Semantic reports whether memories are searched by embeddings.
+func (h *MemoryHandler) Semantic() bool {
+	return h.embedder != nil
+}
+
 // GetStore gets or creates a memory store for a user
 func (h *MemoryHandler) GetStore(userID string) (*memory.Store, error) {
 	h.mu.RLock()
@@ -52,6 +89,10 @@ func (h *MemoryHandler) GetStore(userID string) (*memory.Store, error) {
 
 // HandleCommand processes memory commands
 func (h *MemoryHandler) HandleCommand(userID, platform string, args []string) (string, error) {
+	if h.embedder != nil {
+		return h.handleSemanticCommand(userID, platform, args)
+	}
+
 	store, err := h.GetStore(userID)
 	if err != nil {
 		return "", err
@@ -122,7 +163,7 @@ func (h *MemoryHandler) searchMemory(store *memory.Store, args []string) (string
 	memories := store.Search(query, 5)
 
 	if len(memories) == 0 {
-		return fmt.Sprintf("🔍 No memories found for: %s", query), nil
+		return fmt.Sprintf("🔍 No memories found for: %s\n\n%s", query, keywordSearchNote), nil
 	}
 
 	var sb strings.Builder
@@ -133,10 +174,14 @@ func (h *MemoryHandler) searchMemory(store *memory.Store, args []string) (string
 		sb.WriteString(fmt.Sprintf("   📅 %s | 🔑 %s\n\n",
 			mem.CreatedAt.Format("Jan 2"), mem.ID[:8]))
 	}
+	sb.WriteString(keywordSearchNote)
 
 	return sb.String(), nil
 }
 
+// keywordSearchNote tells users why search only matches exact words.
+const keywordSearchNote = "ℹ️ Keyword search only — set memory.embedding in config for semantic search."
+
 // listMemory lists all memories
 func (h *MemoryHandler) listMemory(store *memory.Store, args []string) (string, error) {
 	memType := ""
@@ -246,11 +291,32 @@ func (h *MemoryHandler) showHelp() string {
 }
 
 // GetContext retrieves relevant memories for LLM context
-func (h *MemoryHandler) GetContext(userID, query string, maxTokens int) string {
+func (h *MemoryHandler) GetContext(ctx context.Context, userID, query string, maxTokens int) string {
+	text, _ := h.Recall(ctx, userID, query, maxTokens)
+	return text
+}
+
+// Recall is GetContext that also returns the memories in the context, so an
+// answer's [id] citations can be traced back with memory.Cited.
+func (h *MemoryHandler) Recall(ctx context.Context, userID, query string, maxTokens int) (string, []memory.Source) {
+	backend := "keyword"
+	if h.embedder != nil {
+		backend = "semantic"
+	}
+	ctx, span := telemetry.Start(ctx, "memory.search", attribute.String("memory.backend", backend))
+	defer span.End()
+
+	if h.embedder != nil {
+		sources, err := h.semanticSources(ctx, userID, query)
+		telemetry.RecordError(span, err)
+		return memory.FormatContext("Relevant memories about the user:", sources, maxTokens)
+	}
+
 	store, err := h.GetStore(userID)
 	if err != nil {
-		return ""
+		telemetry.RecordError(span, err)
+		return "", nil
 	}
 
-	return store.GetContext(query, maxTokens)
+	return memory.FormatContext("Relevant memories:", store.Sources(query, 10), maxTokens)
 }
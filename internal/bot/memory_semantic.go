@@ -0,0 +1,243 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/memory"
+	"github.com/kusa/magabot/internal/util"
+)
+
+// semanticTimeout bounds embedding API calls made by a memory command.
+const semanticTimeout = 30 * time.Second
+
+// GetSemanticStore gets or creates the vector-backed memory store for a
+// user. Keyword memories saved before embeddings were configured are
+// imported the first time the store is opened empty.
+func (h *MemoryHandler) GetSemanticStore(ctx context.Context, userID string) (*memory.SemanticStore, error) {
+	h.mu.RLock()
+	if store, ok := h.semantic[userID]; ok {
+		h.mu.RUnlock()
+		return store, nil
+	}
+	h.mu.RUnlock()
+
+	legacy, err := h.GetStore(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if store, ok := h.semantic[userID]; ok {
+		return store, nil
+	}
+
+	store, err := memory.NewSemanticStore(memory.SemanticConfig{
+		DataDir: h.dataDir,
+		UserID:  userID,
+		Client:  h.embedder,
+		Driver:  h.vectorDriver,
+		DSN:     h.vectorDSN,
+
+		MinSimilarity: h.minSimilarity,
+		MinResults:    h.minResults,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if n, err := store.Count(); err == nil && n == 0 && len(legacy.List("")) > 0 {
+		_ = store.MigrateFromLegacy(ctx, legacy)
+	}
+
+	h.semantic[userID] = store
+	return store, nil
+}
+
+// handleSemanticCommand processes memory commands against the vector store.
+func (h *MemoryHandler) handleSemanticCommand(userID, platform string, args []string) (string, error) {
+	if len(args) == 0 {
+		return h.showHelp(), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), semanticTimeout)
+	defer cancel()
+
+	store, err := h.GetSemanticStore(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := strings.ToLower(args[0])
+	subArgs := args[1:]
+
+	switch cmd {
+	case "add", "remember":
+		if len(subArgs) == 0 {
+			return "Usage: /memory add <content to remember>", nil
+		}
+		mem, err := store.Remember(ctx, strings.Join(subArgs, " "), platform, "manual")
+		if err != nil {
+			return "", fmt.Errorf("remember: %w", err)
+		}
+		return fmt.Sprintf("🧠 Remembered!\n\n📝 %s\n🏷️ Type: %s\n🔑 ID: %s",
+			mem.Content, mem.Type, shortID(mem.ID)), nil
+	case "search", "find", "recall":
+		return h.searchSemantic(ctx, store, subArgs)
+	case "list", "ls":
+		return h.listSemantic(store, subArgs)
+	case "delete", "rm", "forget":
+		return h.deleteSemantic(store, subArgs)
+	case "clear":
+		count, err := store.Count()
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return "📋 No memories to clear.", nil
+		}
+		if err := store.Clear(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("🗑️ Cleared %d memories.", count), nil
+	case "stats":
+		stats, err := store.Stats()
+		if err != nil {
+			return "", err
+		}
+		var sb strings.Builder
+		sb.WriteString("📊 *Memory Stats*\n\n")
+		sb.WriteString(fmt.Sprintf("📦 Total: %d\n", stats["total"]))
+		for k, v := range stats {
+			if k != "total" && v > 0 {
+				sb.WriteString(fmt.Sprintf("  • %s: %d\n", k, v))
+			}
+		}
+		sb.WriteString("🔎 Search: semantic")
+		return sb.String(), nil
+	case "help":
+		return h.showHelp(), nil
+	default:
+		mem, err := store.Remember(ctx, strings.Join(args, " "), platform, "manual")
+		if err != nil {
+			return "", fmt.Errorf("remember: %w", err)
+		}
+		return fmt.Sprintf("🧠 Noted: %s", util.Truncate(mem.Content, 50)), nil
+	}
+}
+
+// searchSemantic lists the memories closest in meaning to the query.
+func (h *MemoryHandler) searchSemantic(ctx context.Context, store *memory.SemanticStore, args []string) (string, error) {
+	if len(args) == 0 {
+		return "Usage: /memory search <query>", nil
+	}
+
+	query := strings.Join(args, " ")
+	results, err := store.SearchWithScore(ctx, query, 5)
+	if err != nil {
+		return "", fmt.Errorf("search memories: %w", err)
+	}
+
+	if len(results) == 0 {
+		return fmt.Sprintf("🔍 No memories found for: %s", query), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔍 *Memories for: %s*\n\n", query))
+
+	for i, r := range results {
+		mem := r.Memory
+		sb.WriteString(fmt.Sprintf("%d. [%s] %s\n", i+1, mem.Type, util.Truncate(mem.Content, 80)))
+		sb.WriteString(fmt.Sprintf("   📅 %s | 🔑 %s | 🎯 %.0f%%\n\n",
+			mem.CreatedAt.Format("Jan 2"), shortID(mem.ID), r.Similarity*100))
+	}
+
+	return sb.String(), nil
+}
+
+// listSemantic lists stored memories, optionally of one type.
+func (h *MemoryHandler) listSemantic(store *memory.SemanticStore, args []string) (string, error) {
+	memories, err := semanticMemories(store)
+	if err != nil {
+		return "", err
+	}
+	if len(args) > 0 {
+		filtered := memories[:0]
+		for _, mem := range memories {
+			if mem.Type == args[0] {
+				filtered = append(filtered, mem)
+			}
+		}
+		memories = filtered
+	}
+
+	if len(memories) == 0 {
+		return "📋 No memories stored yet.", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📋 *Memories* (%d total)\n\n", len(memories)))
+
+	limit := min(10, len(memories))
+	for _, mem := range memories[:limit] {
+		sb.WriteString(fmt.Sprintf("• [%s] %s\n", mem.Type, util.Truncate(mem.Content, 60)))
+	}
+
+	util.WriteTruncatedFooter(&sb, len(memories), limit, "memories")
+
+	return sb.String(), nil
+}
+
+// deleteSemantic deletes a memory by ID prefix.
+func (h *MemoryHandler) deleteSemantic(store *memory.SemanticStore, args []string) (string, error) {
+	if len(args) == 0 {
+		return "Usage: /memory delete <id>", nil
+	}
+
+	memories, err := semanticMemories(store)
+	if err != nil {
+		return "", err
+	}
+	for _, mem := range memories {
+		if strings.HasPrefix(mem.ID, args[0]) {
+			if err := store.Delete(mem.ID); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("🗑️ Deleted: %s", util.Truncate(mem.Content, 50)), nil
+		}
+	}
+
+	return fmt.Sprintf("❌ Memory not found: %s", args[0]), nil
+}
+
+// semanticSources retrieves relevant memories for LLM context.
+func (h *MemoryHandler) semanticSources(ctx context.Context, userID, query string) ([]memory.Source, error) {
+	ctx, cancel := context.WithTimeout(ctx, semanticTimeout)
+	defer cancel()
+
+	store, err := h.GetSemanticStore(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return store.Sources(ctx, query, 10)
+}
+
+// semanticMemories returns every memory in the store.
+func semanticMemories(store *memory.SemanticStore) ([]*memory.SemanticMemory, error) {
+	count, err := store.Count()
+	if err != nil {
+		return nil, err
+	}
+	return store.List(0, count)
+}
+
+// shortID returns the first 8 characters of a memory ID.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -20,6 +21,8 @@ type Config struct {
 	mu       sync.RWMutex `yaml:"-"`
 	filePath string       `yaml:"-"`
 
+	migrations []string // what Load's migrations changed, see Migrations
+
 	// Bot identity
 	Bot BotConfig `yaml:"bot"`
 
@@ -32,6 +35,9 @@ type Config struct {
 	// Access control
 	Access AccessConfig `yaml:"access"`
 
+	// Who may run which chat commands
+	Commands CommandsConfig `yaml:"commands,omitempty"`
+
 	// Cron jobs
 	Cron CronConfig `yaml:"cron"`
 
@@ -47,9 +53,15 @@ type Config struct {
 	// Logging settings
 	Logging LoggingConfig `yaml:"logging"`
 
+	// Tracing settings
+	Observability ObservabilityConfig `yaml:"observability,omitempty"`
+
 	// Security settings
 	Security SecurityConfig `yaml:"security"`
 
+	// Content moderation of messages and answers
+	Moderation ModerationConfig `yaml:"moderation,omitempty"`
+
 	// Storage settings
 	Storage StorageConfig `yaml:"storage"`
 
@@ -71,6 +83,9 @@ type Config struct {
 	// Hooks (event-driven shell commands)
 	Hooks []HookConfig `yaml:"hooks,omitempty"`
 
+	// Tools the LLM may call while answering
+	Tools ToolsConfig `yaml:"tools,omitempty"`
+
 	// Agent sessions (coding agents via chat)
 	Agent AgentConfig `yaml:"agent"`
 
@@ -86,17 +101,43 @@ type Config struct {
 	// Personas
 	Personas PersonasConfig `yaml:"personas"`
 
+	// Self-update settings
+	Update UpdateConfig `yaml:"update"`
+
 	// Metadata
 	Version     string    `yaml:"version"`
 	LastUpdated time.Time `yaml:"last_updated"`
 	UpdatedBy   string    `yaml:"updated_by"`
 }
 
+// UpdateConfig holds self-update settings
+type UpdateConfig struct {
+	Channel string `yaml:"channel"` // stable (default) or beta (includes pre-releases)
+}
+
 // BotConfig holds bot identity settings
 type BotConfig struct {
 	Name        string `yaml:"name"`
 	Description string `yaml:"description"`
-	Prefix      string `yaml:"prefix"` // Command prefix (default: /)
+	Prefix      string `yaml:"prefix"`   // Command prefix (default: /)
+	Language    string `yaml:"language"` // Default language for built-in replies: en, id (default: en)
+
+	// Reply to a user without access on their first message, and tell the
+	// platform's admins how to allow them; empty = deny silently
+	NewUserMessage string `yaml:"new_user_message,omitempty"`
+
+	// Hold proactive messages (cron posts, background task notices) back
+	// during a nightly window and send them when it ends
+	QuietHours *QuietHoursConfig `yaml:"quiet_hours,omitempty"`
+}
+
+// QuietHoursConfig is a daily window without proactive messages. Replies
+// to users are never held.
+type QuietHoursConfig struct {
+	Start     string   `yaml:"start"`               // "HH:MM", e.g. "22:00"
+	End       string   `yaml:"end"`                 // "HH:MM"; before start spans midnight, e.g. "07:00"
+	Timezone  string   `yaml:"timezone,omitempty"`  // IANA zone or alias such as WIB (default: local time)
+	Platforms []string `yaml:"platforms,omitempty"` // platforms it applies to (default: all)
 }
 
 // PlatformsConfig holds all platform configurations
@@ -106,13 +147,36 @@ type PlatformsConfig struct {
 	Slack    *SlackConfig    `yaml:"slack,omitempty"`
 	WhatsApp *WhatsAppConfig `yaml:"whatsapp,omitempty"`
 	Webhook  *WebhookConfig  `yaml:"webhook,omitempty"`
+
+	// How long message IDs are remembered to drop redeliveries (default: 2m, "0s" disables)
+	DedupWindow *util.Duration `yaml:"dedup_window,omitempty"`
+
+	// Retries for a failed outbound send before it is dead-lettered (default: 3, 0 disables)
+	SendRetries *int `yaml:"send_retries,omitempty"`
+
+	// Wait before the first send retry, doubled after each attempt (default: 2s)
+	SendBackoff *util.Duration `yaml:"send_backoff,omitempty"`
+
+	// Outbound send rate per platform; sends wait to stay under it. An entry
+	// replaces the built-in default for its platform (router.DefaultSendLimits)
+	SendLimits map[string]SendLimitConfig `yaml:"send_limits,omitempty"`
+
+	// Seed 👍/👎 reactions on LLM answers and record user ratings (Telegram, Slack)
+	Feedback bool `yaml:"feedback,omitempty"`
+}
+
+// SendLimitConfig caps the messages sent on one platform, in messages per
+// second; 0 means no limit.
+type SendLimitConfig struct {
+	PerSecond float64 `yaml:"per_second"` // across all chats
+	PerChat   float64 `yaml:"per_chat"`   // to one chat
 }
 
 // TelegramConfig for Telegram platform
 type TelegramConfig struct {
 	Enabled  bool   `yaml:"enabled"`
-	Token    string `yaml:"token"`
-	BotToken string `yaml:"bot_token"` // Alias for token
+	Token    string `yaml:"token,omitempty"` // Before v2; migrated to bot_token
+	BotToken string `yaml:"bot_token"`
 	// Access control for this platform
 	Admins       []string `yaml:"admins"`        // Platform admins (can change config)
 	AllowedUsers []string `yaml:"allowed_users"` // Allowed user IDs
@@ -125,6 +189,8 @@ type TelegramConfig struct {
 	WebhookPort   int    `yaml:"webhook_port"`   // Local port to listen on
 	WebhookPath   string `yaml:"webhook_path"`   // Path (e.g., /telegram)
 	WebhookSecret string `yaml:"webhook_secret"` // Secret token for verification
+
+	MaxMessageLength int `yaml:"max_message_length,omitempty"` // Split outgoing messages above this length (default: 4096)
 }
 
 // DiscordConfig for Discord platform
@@ -149,21 +215,38 @@ type SlackConfig struct {
 	AllowedChats []string `yaml:"allowed_chats"`
 	AllowGroups  bool     `yaml:"allow_groups"`
 	AllowDMs     bool     `yaml:"allow_dms"`
-	// Webhook mode (Events API instead of Socket Mode)
+	// Webhook mode: Events API on webhook_path, slash commands on
+	// /slack/commands and interactivity on /slack/interactive over HTTP,
+	// signed with signing_secret. Without app_token it replaces Socket Mode.
 	UseWebhook    bool   `yaml:"use_webhook"`
-	WebhookPort   int    `yaml:"webhook_port"` // Local port to listen on
-	WebhookPath   string `yaml:"webhook_path"` // Path (e.g., /slack/events)
+	WebhookPort   int    `yaml:"webhook_port"`           // Local port to listen on (default: 3000)
+	WebhookBind   string `yaml:"webhook_bind,omitempty"` // Address to listen on (default: 127.0.0.1)
+	WebhookPath   string `yaml:"webhook_path"`           // Events API path (default: /slack/events)
 	SigningSecret string `yaml:"signing_secret"`
+
+	MaxMessageLength int `yaml:"max_message_length,omitempty"` // Split outgoing messages above this length (default: 4096)
 }
 
 // WhatsAppConfig for WhatsApp platform
 type WhatsAppConfig struct {
 	Enabled      bool     `yaml:"enabled"`
+	DBPath       string   `yaml:"db_path,omitempty"` // Session database (default: <platform dir>/whatsapp.db)
 	Admins       []string `yaml:"admins"`
 	AllowedUsers []string `yaml:"allowed_users"`
 	AllowedChats []string `yaml:"allowed_chats"`
 	AllowGroups  bool     `yaml:"allow_groups"`
 	AllowDMs     bool     `yaml:"allow_dms"`
+
+	MaxMessageLength int `yaml:"max_message_length,omitempty"` // Split outgoing messages above this length (default: 4096)
+
+	Reconnect WhatsAppReconnectConfig `yaml:"reconnect,omitempty"`
+}
+
+// WhatsAppReconnectConfig tunes reconnection after the WhatsApp connection drops
+type WhatsAppReconnectConfig struct {
+	InitialDelay util.Duration `yaml:"initial_delay,omitempty"` // First retry delay, doubled per failure (default 2s)
+	MaxDelay     util.Duration `yaml:"max_delay,omitempty"`     // Retry delay cap (default 5m)
+	NotifyAfter  int           `yaml:"notify_after,omitempty"`  // Failed attempts before admins on other platforms are alerted (default 5)
 }
 
 // WebhookConfig for generic webhook
@@ -173,37 +256,178 @@ type WebhookConfig struct {
 	Path         string            `yaml:"path"`
 	Bind         string            `yaml:"bind"`
 	Secret       string            `yaml:"secret,omitempty"`
-	AuthMethod   string            `yaml:"auth_method"`             // bearer, hmac, none
+	AuthMethod   string            `yaml:"auth_method"`             // bearer, basic, hmac, none
 	BearerToken  string            `yaml:"bearer_token,omitempty"`  // Legacy single token
 	BearerTokens map[string]string `yaml:"bearer_tokens,omitempty"` // token -> user_id (secure)
+	BasicUser    string            `yaml:"basic_user,omitempty"`    // basic auth: the user_id
+	BasicPass    string            `yaml:"basic_pass,omitempty"`    // basic auth password
 	HMACSecret   string            `yaml:"hmac_secret,omitempty"`   // Legacy single secret
 	HMACUsers    map[string]string `yaml:"hmac_users,omitempty"`    // secret -> user_id (secure)
 	Admins       []string          `yaml:"admins"`
 	AllowedIPs   []string          `yaml:"allowed_ips"`
 	AllowedUsers []string          `yaml:"allowed_users"` // Required: allowed user IDs
+
+	Sources            []WebhookSourceConfig `yaml:"sources,omitempty"`              // payload schemas, tried before built-in parsing
+	Transform          string                `yaml:"transform,omitempty"`            // jq expression for payloads no source matches
+	MaxBodySize        int64                 `yaml:"max_body_size,omitempty"`        // bytes (default: 1MB)
+	RequireContentType []string              `yaml:"require_content_type,omitempty"` // e.g. ["application/json"]; others get 415
+	SecurityProfile    string                `yaml:"security_profile,omitempty"`     // "strict": hmac signature also covers X-Timestamp and X-Nonce
+
+	HMACAlgorithms   []string `yaml:"hmac_algorithms,omitempty"`    // signatures hmac auth accepts: sha256 (default), sha1, ed25519
+	Ed25519PublicKey string   `yaml:"ed25519_public_key,omitempty"` // hex or base64 key for ed25519 signatures
+
+	Routes []WebhookRouteConfig `yaml:"routes,omitempty"` // extra endpoints with their own auth and allowlists
+
+	CronJobs []string `yaml:"cron_jobs,omitempty"` // cron job IDs authenticated users may run with POST <path>/cron/<id>
+
+	RateLimitPerIP   int           `yaml:"rate_limit_per_ip,omitempty"`   // requests per window per IP (0 = disabled)
+	RateLimitPerUser int           `yaml:"rate_limit_per_user,omitempty"` // requests per window per user (0 = disabled)
+	RateLimitWindow  util.Duration `yaml:"rate_limit_window,omitempty"`   // default: 1m
+
+	Alerts *WebhookAlertsConfig `yaml:"alerts,omitempty"` // admin alerts on repeated auth failures and rate limiting
+
+	// Opt-in request log for debugging integrations, served to admins on GET /debug/requests
+	DebugRequests  int `yaml:"debug_requests,omitempty"`   // requests kept in memory (0 = disabled, max 1000)
+	DebugBodyBytes int `yaml:"debug_body_bytes,omitempty"` // body bytes kept per request (0 = hash only, max 4096)
+}
+
+// WebhookAlertsConfig tells admins when the webhook looks under attack.
+// Alerts of one kind are sent at most once per cooldown.
+type WebhookAlertsConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Chats        []string      `yaml:"chats,omitempty"`         // "platform:chat_id" targets (default: admins of the other platforms)
+	AuthFailures int           `yaml:"auth_failures,omitempty"` // failed auths from one IP before alerting (default: at lockout, 5)
+	RateLimited  int           `yaml:"rate_limited,omitempty"`  // rate-limited requests from one IP or user within window (default: 20)
+	Window       util.Duration `yaml:"window,omitempty"`        // default: 10m
+	Cooldown     util.Duration `yaml:"cooldown,omitempty"`      // default: 15m
+}
+
+// WebhookRouteConfig is an extra webhook endpoint, e.g. /webhook/github.
+// Its auth, allowlists and sources are independent of the top-level ones.
+type WebhookRouteConfig struct {
+	Path         string                `yaml:"path"`
+	AuthMethod   string                `yaml:"auth_method"` // bearer, basic, hmac, none
+	BearerToken  string                `yaml:"bearer_token,omitempty"`
+	BearerTokens map[string]string     `yaml:"bearer_tokens,omitempty"` // token -> user_id
+	BasicUser    string                `yaml:"basic_user,omitempty"`    // basic auth: the user_id
+	BasicPass    string                `yaml:"basic_pass,omitempty"`    // basic auth password
+	HMACSecret   string                `yaml:"hmac_secret,omitempty"`
+	HMACUsers    map[string]string     `yaml:"hmac_users,omitempty"` // secret -> user_id
+	AllowedIPs   []string              `yaml:"allowed_ips,omitempty"`
+	AllowedUsers []string              `yaml:"allowed_users"`
+	Sources      []WebhookSourceConfig `yaml:"sources,omitempty"`
+	Transform    string                `yaml:"transform,omitempty"`
+
+	HMACAlgorithms   []string `yaml:"hmac_algorithms,omitempty"`
+	Ed25519PublicKey string   `yaml:"ed25519_public_key,omitempty"`
+}
+
+// WebhookSourceConfig maps one webhook source's JSON payload to a message
+type WebhookSourceConfig struct {
+	Name     string `yaml:"name"`
+	Header   string `yaml:"header,omitempty"`    // match on this header, e.g. X-Event-Source
+	Field    string `yaml:"field,omitempty"`     // or on this JSONPath, e.g. $.source
+	Match    string `yaml:"match,omitempty"`     // expected value (empty = any)
+	TextPath string `yaml:"text_path"`           // JSONPath to the message text
+	UserPath string `yaml:"user_path,omitempty"` // JSONPath to the user ID
+
+	MaxBodySize int64 `yaml:"max_body_size,omitempty"` // bytes; overrides the webhook default
 }
 
 // LLMConfig holds LLM provider settings
 type LLMConfig struct {
-	Main               string          `yaml:"main"`                // Main/primary provider
-	Providers          ProvidersConfig `yaml:"providers,omitempty"` // Alternative structure
-	SystemPrompt       string          `yaml:"system_prompt"`
-	MaxInputLength     int             `yaml:"max_input_length"`
-	Timeout            util.Duration   `yaml:"timeout"`           // idle timeout per chunk during streaming, e.g. "60s"
-	MaxContextChars    int             `yaml:"max_context_chars"` // max total chars sent to LLM (trims oldest messages)
-	RateLimit          int             `yaml:"rate_limit"`
-	MaxContextTokens   int             `yaml:"max_context_tokens"`
-	TruncationStrategy string          `yaml:"truncation_strategy"`
-	PromptCaching      bool            `yaml:"prompt_caching"`
+	Main                string          `yaml:"main"`                // Main/primary provider
+	Providers           ProvidersConfig `yaml:"providers,omitempty"` // Before v2; migrated to llm.<name>
+	SystemPrompt        string          `yaml:"system_prompt"`
+	MaxInputLength      int             `yaml:"max_input_length,omitempty"` // Before v3, in characters; migrated to max_input_tokens
+	MaxInputTokens      int             `yaml:"max_input_tokens"`           // max prompt tokens, history included (older history is trimmed)
+	Timeout             util.Duration   `yaml:"timeout"`                    // idle timeout per chunk during streaming, e.g. "60s"
+	MaxContextChars     int             `yaml:"max_context_chars"`          // max total chars sent to LLM (trims oldest messages)
+	RateLimit           int             `yaml:"rate_limit"`
+	MaxContextTokens    int             `yaml:"max_context_tokens"`
+	TruncationStrategy  string          `yaml:"truncation_strategy"`
+	PromptCaching       bool            `yaml:"prompt_caching"`
+	HealthCheckInterval util.Duration   `yaml:"health_check_interval"`       // provider probe interval, e.g. "5m" (0 = disabled)
+	FallbackMessage     string          `yaml:"fallback_message,omitempty"`  // reply sent when no provider is reachable (default: error text)
+	OfflineResponder    bool            `yaml:"offline_responder"`           // answer help/status intents without an LLM during outages
+	AllowModelOverride  bool            `yaml:"allow_model_override"`        // let non-admins pick a per-chat model with /model
+	MaxContinuations    int             `yaml:"max_continuations,omitempty"` // follow-up requests for answers cut off at max_tokens (0 = note the cut instead)
+
+	// Stop sequences and sampling seed for every request, unless its
+	// profile sets its own; the seed makes answers reproducible where the
+	// provider supports it (not Anthropic)
+	Stop []string `yaml:"stop,omitempty"`
+	Seed *int     `yaml:"seed,omitempty"`
+
+	// Requests in flight to one provider at once; more wait for a free slot (0 = unlimited)
+	MaxConcurrentPerProvider int `yaml:"max_concurrent_per_provider"`
+
+	// Context window in tokens per model name, for models magabot doesn't
+	// know; history is trimmed to fit before each request
+	ContextWindows map[string]int `yaml:"context_windows,omitempty"`
+
+	// Whether a model accepts images, per model name, for models magabot
+	// doesn't know or gets wrong; photos go to a model that does
+	VisionModels map[string]bool `yaml:"vision_models,omitempty"`
+
+	// tiktoken encoding files (e.g. o200k_base.tiktoken) per model name
+	// prefix, for exact token counts; other models are estimated
+	Tokenizers map[string]string `yaml:"tokenizers,omitempty"`
 
 	// Direct provider configs (preferred structure)
 	// omitempty: disabled providers are pruned on save so only active ones appear in YAML
 	Anthropic LLMProviderConfig `yaml:"anthropic,omitempty"`
 	OpenAI    LLMProviderConfig `yaml:"openai,omitempty"`
 	GLM       LLMProviderConfig `yaml:"glm,omitempty"`
-	Local     LLMProviderConfig `yaml:"local,omitempty"` // Self-hosted (Ollama, vLLM, llama.cpp, etc.)
+	Local     LLMProviderConfig `yaml:"local,omitempty"`  // Self-hosted OpenAI-compatible (vLLM, llama.cpp, etc.)
+	Ollama    LLMProviderConfig `yaml:"ollama,omitempty"` // Ollama through its native API
 	Kimi      LLMProviderConfig `yaml:"kimi,omitempty"`
 	MiniMax   LLMProviderConfig `yaml:"minimax,omitempty"`
+
+	// Generic OpenAI-compatible endpoints (OpenRouter, Together, Groq, ...),
+	// keyed by the user-chosen provider name
+	Compatible map[string]*LLMProviderConfig `yaml:"compatible,omitempty"`
+
+	// Extra providers added purely through config, each speaking the
+	// OpenAI or Anthropic wire format. Listed entries are always enabled.
+	Custom []CustomProviderConfig `yaml:"custom,omitempty"`
+
+	// Sampling settings per intent: "chat" for conversation, "digest" for
+	// llm_digest cron jobs, "precise" for structured skill commands, or any
+	// name a persona or skill selects with profile
+	Profiles map[string]LLMProfile `yaml:"profiles,omitempty"`
+
+	// Provider and model per message, by the first matching rule; messages
+	// no rule matches go to main
+	RoutingRules []RoutingRuleConfig `yaml:"routing_rules,omitempty"`
+
+	// Provider, ideally a small fast model, that labels messages for
+	// routing rules with a class
+	RoutingClassifier string `yaml:"routing_classifier,omitempty"`
+}
+
+// LLMProfile overrides the provider's sampling settings for one intent.
+// Zero values keep the provider's max_tokens and temperature, and llm.stop
+// and llm.seed.
+type LLMProfile struct {
+	MaxTokens   int      `yaml:"max_tokens,omitempty"`
+	Temperature float64  `yaml:"temperature,omitempty"`
+	Stop        []string `yaml:"stop,omitempty"`
+	Seed        *int     `yaml:"seed,omitempty"`
+}
+
+// RoutingRuleConfig sends messages that meet all of its conditions to a
+// provider and model. A rule without conditions matches every message.
+type RoutingRuleConfig struct {
+	Name     string `yaml:"name,omitempty"`
+	Provider string `yaml:"provider,omitempty"` // empty keeps the main provider
+	Model    string `yaml:"model,omitempty"`    // empty keeps the provider's model
+
+	Keywords  []string `yaml:"keywords,omitempty"`   // any of these words or phrases, ignoring case
+	MinLength int      `yaml:"min_length,omitempty"` // message characters
+	MaxLength int      `yaml:"max_length,omitempty"`
+	Code      bool     `yaml:"code,omitempty"`  // message looks like code
+	Class     string   `yaml:"class,omitempty"` // label from routing_classifier
 }
 
 // KimiDefaultBaseURL is the default Anthropic-compatible endpoint for Kimi.
@@ -221,13 +445,54 @@ func (l *LLMConfig) GetProviderConfig(name string) *LLMProviderConfig {
 		return &l.GLM
 	case "local":
 		return &l.Local
+	case "ollama":
+		return &l.Ollama
 	case "kimi":
 		return &l.Kimi
 	case "minimax":
 		return &l.MiniMax
-	default:
-		return nil
 	}
+	if p := l.Compatible[name]; p != nil {
+		return p
+	}
+	for i := range l.Custom {
+		if l.Custom[i].Name == name {
+			return &l.Custom[i].LLMProviderConfig
+		}
+	}
+	return nil
+}
+
+// ProviderYAMLPath returns the dot-separated YAML path of the named provider's
+// config block (e.g. "llm.openai", "llm.compatible.groq" or "llm.custom.0"),
+// for use with PatchYAMLField.
+func (l *LLMConfig) ProviderYAMLPath(name string) string {
+	if _, ok := l.Compatible[name]; ok {
+		return "llm.compatible." + name
+	}
+	for i := range l.Custom {
+		if l.Custom[i].Name == name {
+			return fmt.Sprintf("llm.custom.%d", i)
+		}
+	}
+	return "llm." + name
+}
+
+// IsBuiltinProvider reports whether name is one of the built-in LLM providers.
+func IsBuiltinProvider(name string) bool {
+	switch name {
+	case "anthropic", "openai", "glm", "local", "ollama", "kimi", "minimax":
+		return true
+	}
+	return false
+}
+
+// CustomProviderConfig is an llm.custom entry: a named provider whose
+// format selects the wire protocol.
+type CustomProviderConfig struct {
+	Name              string `yaml:"name"`
+	Format            string `yaml:"format,omitempty"` // "openai" (default) or "anthropic"
+	LLMProviderConfig `yaml:",inline"`
 }
 
 // LLMProviderConfig holds config for a single LLM provider
@@ -235,6 +500,7 @@ type LLMProviderConfig struct {
 	Enabled       bool     `yaml:"enabled"`
 	Mode          string   `yaml:"mode,omitempty"`       // "api" (default) or "cli" (Claude CLI)
 	APIKey        string   `yaml:"api_key"`              // #nosec G117 -- config field
+	APIKeys       []string `yaml:"api_keys,omitempty"`   // #nosec G117 -- extra keys, rotated when one is rate limited
 	AuthToken     string   `yaml:"auth_token,omitempty"` // OAuth token (Claude Pro/Max)
 	Model         string   `yaml:"model"`
 	MaxTokens     *int     `yaml:"max_tokens,omitempty"`
@@ -245,6 +511,47 @@ type LLMProviderConfig struct {
 	MaxRetries    *int     `yaml:"max_retries,omitempty"`
 	Effort        string   `yaml:"effort,omitempty"`         // CLI effort level: low, medium, high, max
 	FallbackModel string   `yaml:"fallback_model,omitempty"` // CLI fallback model
+
+	// Reasoning for API models that support it: OpenAI o-series/gpt-5 and
+	// Claude 3.7+. Chats override the effort with /think.
+	ReasoningEffort string `yaml:"reasoning_effort,omitempty"` // low, medium or high
+	ThinkingBudget  int    `yaml:"thinking_budget,omitempty"`  // Claude thinking tokens (min 1024); overrides reasoning_effort
+
+	// Network: proxy defaults to HTTPS_PROXY/NO_PROXY, timeout to 60s per request
+	Proxy   string        `yaml:"proxy,omitempty"`   // e.g. "http://proxy.corp:3128"
+	Timeout util.Duration `yaml:"timeout,omitempty"` // e.g. "120s"
+
+	// Sent with every API request
+	ExtraHeaders map[string]string `yaml:"extra_headers,omitempty"` // e.g. {"X-Team": "ops"}
+	Organization string            `yaml:"organization,omitempty"`  // OpenAI-Organization header
+	Project      string            `yaml:"project,omitempty"`       // OpenAI-Project header
+
+	// How long Ollama keeps the model loaded after a request, e.g. "10m",
+	// or "-1" for forever (ollama provider only; default: server's 5m)
+	KeepAlive string `yaml:"keep_alive,omitempty"`
+
+	// Azure OpenAI deployment (openai provider only); replaces base_url
+	Azure *AzureOpenAIConfig `yaml:"azure,omitempty"`
+}
+
+// AzureOpenAIConfig points the openai provider at an Azure OpenAI deployment.
+// Requests go to {endpoint}/openai/deployments/{deployment} with the
+// api-version query parameter, and the API key is sent as "api-key".
+type AzureOpenAIConfig struct {
+	Endpoint   string `yaml:"endpoint"`    // e.g. https://myresource.openai.azure.com
+	Deployment string `yaml:"deployment"`  // deployment name, used instead of the model
+	APIVersion string `yaml:"api_version"` // e.g. 2024-10-21
+}
+
+// Keys returns api_key followed by api_keys, without blanks or duplicates.
+func (p *LLMProviderConfig) Keys() []string {
+	var keys []string
+	for _, k := range append([]string{p.APIKey}, p.APIKeys...) {
+		if k != "" && !util.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
 }
 
 // IntPtr returns a pointer to the given int value.
@@ -253,7 +560,8 @@ func IntPtr(v int) *int { return &v }
 // Float64Ptr returns a pointer to the given float64 value.
 func Float64Ptr(v float64) *float64 { return &v }
 
-// ProvidersConfig holds individual LLM provider configs (alternative structure)
+// ProvidersConfig is the llm.providers block of v1 configs, which the v2
+// migration moves to the provider fields of LLMConfig.
 type ProvidersConfig struct {
 	Anthropic *LLMProviderConfig `yaml:"anthropic,omitempty"`
 	OpenAI    *LLMProviderConfig `yaml:"openai,omitempty"`
@@ -280,18 +588,56 @@ type HeartbeatConfig struct {
 	Targets  []CronTarget  `yaml:"targets"`  // Where to send alerts
 }
 
+// ToolsConfig enables the tools the LLM may call (function calling) to
+// ground its answers
+type ToolsConfig struct {
+	Search  SearchToolConfig `yaml:"search,omitempty"`
+	Weather ToolConfig       `yaml:"weather,omitempty"`
+}
+
+// ToolConfig switches a tool without settings on or off
+type ToolConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// SearchToolConfig configures the web search tool
+type SearchToolConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	APIKey  string `yaml:"api_key,omitempty"` // Brave Search API key; DuckDuckGo is used without one
+}
+
 // MemoryConfig holds memory/RAG settings
 type MemoryConfig struct {
-	Enabled      bool `yaml:"enabled"`
+	Enabled      bool `yaml:"enabled"`       // Add relevant memories to chat prompts, with cited sources
 	MaxEntries   int  `yaml:"max_entries"`   // Max memories per user
 	ContextLimit int  `yaml:"context_limit"` // Max tokens for context
+
+	// Embedding provider for semantic /memory search; keyword search when disabled
+	Embedding EmbeddingConfig `yaml:"embedding"`
+
+	// Where semantic memory vectors live: "sqlite" (default, a file per user)
+	// or "pgvector" (Postgres at vector_dsn, a table per user)
+	VectorDriver string `yaml:"vector_driver,omitempty"`
+	VectorDSN    string `yaml:"vector_dsn,omitempty"` // #nosec G117
+
+	// Memories added to prompts need at least min_similarity (0-1, 0 = any);
+	// the best min_results are added regardless
+	MinSimilarity float64 `yaml:"min_similarity,omitempty"`
+	MinResults    int     `yaml:"min_results,omitempty"`
 }
 
 // SessionConfig holds session settings
 type SessionConfig struct {
 	MaxHistory  int           `yaml:"max_history"`  // Max messages per session
 	TaskTimeout util.Duration `yaml:"task_timeout"` // Timeout for background tasks, e.g. "10m"
-	CleanupAge  util.Duration `yaml:"cleanup_age"`  // When to cleanup old sessions, e.g. "24h"
+	CleanupAge  util.Duration `yaml:"cleanup_age"`  // Evict sessions idle this long from memory (default: 24h)
+	MaxSessions int           `yaml:"max_sessions"` // Sessions kept in memory, least recently used evicted (0 = unlimited)
+	Mode        string        `yaml:"mode"`         // chat (default), user or user_per_chat
+	MaxTurns    int           `yaml:"max_turns"`    // User messages after which a chat's history resets (0 = never)
+
+	// Wait this long for more messages from a user before answering, and
+	// answer them together as one turn, e.g. "2s" (0 = answer each at once)
+	Debounce util.Duration `yaml:"debounce,omitempty"`
 }
 
 // CronJob defines a scheduled job
@@ -324,6 +670,28 @@ type LoggingConfig struct {
 	Level  string `yaml:"level"`  // debug, info, warn, error
 	File   string `yaml:"file"`   // Log file path (empty = stderr)
 	Format string `yaml:"format"` // json or text
+	// LLM request logging (debug level). Content is only logged with
+	// log_prompts; redact_messages then logs its length instead.
+	LogPrompts     bool `yaml:"log_prompts,omitempty"`
+	RedactMessages bool `yaml:"redact_messages"`
+	// Rotation of the log file
+	Rotate LogRotateConfig `yaml:"rotate,omitempty"`
+}
+
+// LogRotateConfig holds log file rotation settings. The file is rotated
+// when it reaches max_size_mb or gets older than max_age, whichever comes
+// first; with neither set it grows without bound.
+type LogRotateConfig struct {
+	MaxSizeMB int           `yaml:"max_size_mb,omitempty"` // Rotate at this size (0 = no size limit)
+	MaxAge    util.Duration `yaml:"max_age,omitempty"`     // Rotate after this long, e.g. "24h" (0 = no age limit)
+	Keep      int           `yaml:"keep,omitempty"`        // Rotated files to keep (default: 5)
+	Compress  *bool         `yaml:"compress,omitempty"`    // Gzip rotated files (default: true)
+}
+
+// ObservabilityConfig holds OpenTelemetry tracing settings
+type ObservabilityConfig struct {
+	OTLPEndpoint string            `yaml:"otlp_endpoint"`          // OTLP/HTTP collector URL, e.g. http://localhost:4318 (empty = tracing off)
+	OTLPHeaders  map[string]string `yaml:"otlp_headers,omitempty"` // Sent with every export, e.g. a backend API key
 }
 
 // SecurityConfig holds security settings
@@ -331,6 +699,7 @@ type SecurityConfig struct {
 	EncryptionKey string              `yaml:"encryption_key"`
 	AllowedUsers  map[string][]string `yaml:"allowed_users"` // platform -> user IDs
 	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	DailyQuota    DailyQuotaConfig    `yaml:"daily_quota,omitempty"`
 }
 
 // RateLimitConfig holds rate limiting settings
@@ -339,15 +708,54 @@ type RateLimitConfig struct {
 	CommandsPerMinute int `yaml:"commands_per_minute"`
 }
 
+// DailyQuotaConfig caps what each user may use per day, on top of the
+// per-minute rate limit. Admins and commands are exempt.
+type DailyQuotaConfig struct {
+	Messages int    `yaml:"messages,omitempty"` // messages per user per day (0 = unlimited)
+	Tokens   int    `yaml:"tokens,omitempty"`   // LLM input+output tokens per user per day (0 = unlimited)
+	Timezone string `yaml:"timezone,omitempty"` // IANA zone whose midnight resets the quota (default: local time)
+}
+
 // StorageConfig holds storage settings
 type StorageConfig struct {
-	Database string       `yaml:"database"` // SQLite database path
-	Backup   BackupConfig `yaml:"backup"`
+	Database         string          `yaml:"database"`          // SQLite database path
+	HistoryRetention int             `yaml:"history_retention"` // days to keep stored data; 0 = keep forever
+	Retention        RetentionConfig `yaml:"retention"`
+	Backup           BackupConfig    `yaml:"backup"`
+}
+
+// RetentionConfig overrides storage.history_retention per category, in
+// days (0 = keep forever). Unset categories use history_retention.
+type RetentionConfig struct {
+	Messages      *int          `yaml:"messages"`      // message log
+	Conversations *int          `yaml:"conversations"` // LLM conversation history
+	AuditLog      *int          `yaml:"audit_log"`
+	DeadLetters   *int          `yaml:"dead_letters"`
+	SubAgents     *int          `yaml:"subagents"`   // finished sub-agents
+	Sessions      *int          `yaml:"sessions"`    // settings of sessions evicted from memory
+	ModelStats    *int          `yaml:"model_stats"` // LLM latency and error snapshots
+	Interval      util.Duration `yaml:"interval"`    // how often the daemon prunes (default 24h)
+}
+
+// RetentionDays returns the retention for a category: override when set,
+// else storage.history_retention.
+func (s StorageConfig) RetentionDays(override *int) int {
+	if override != nil {
+		return *override
+	}
+	return s.HistoryRetention
 }
 
 // MediaConfig holds settings for downloaded media files.
 type MediaConfig struct {
-	RetentionDays int `yaml:"retention_days"` // days to keep downloaded files; 0 = keep forever
+	RetentionDays     int   `yaml:"retention_days"`      // days to keep downloaded files; 0 = keep forever
+	MaxImageBytes     int64 `yaml:"max_image_bytes"`     // largest image passed to the LLM (default 5 MB)
+	MaxImageDimension int   `yaml:"max_image_dimension"` // downscale larger or oversized images to this longest side, as JPEG; 0 = never downscale
+
+	// Voice notes longer than AudioChunkLength are split and transcribed
+	// chunk by chunk; audio past MaxAudioDuration is not transcribed.
+	MaxAudioDuration util.Duration `yaml:"max_audio_duration,omitempty"` // default 30m
+	AudioChunkLength util.Duration `yaml:"audio_chunk_length,omitempty"` // default 5m
 }
 
 // PathsConfig holds directory paths
@@ -358,12 +766,27 @@ type PathsConfig struct {
 	CacheDir     string `yaml:"cache_dir"`     // Cache directory (default: data_dir/cache)
 	ExportsDir   string `yaml:"exports_dir"`   // Exports directory (default: data_dir/exports)
 	DownloadsDir string `yaml:"downloads_dir"` // Downloads directory (default: data_dir/downloads)
+
+	// Downloaded media older than this is deleted, e.g. "72h" (default: media.retention_days)
+	DownloadsRetention util.Duration `yaml:"downloads_retention,omitempty"`
+	// Delete downloaded media as soon as the reply to its message is sent
+	DownloadsEphemeral bool `yaml:"downloads_ephemeral,omitempty"`
 }
 
 // SkillsConfig holds skills settings
 type SkillsConfig struct {
-	Dir        string `yaml:"dir"`         // Skills directory (default: ~/code/magabot-skills)
-	AutoReload bool   `yaml:"auto_reload"` // Watch for changes and reload (default: true)
+	Dir           string        `yaml:"dir"`            // Skills directory (default: ~/code/magabot-skills)
+	AutoReload    bool          `yaml:"auto_reload"`    // Watch for changes and reload (default: true)
+	Timeout       util.Duration `yaml:"timeout"`        // Per-skill execution timeout (default: 30s)
+	MaxConcurrent int           `yaml:"max_concurrent"` // Skills executing at once (default: 4)
+
+	// AllowScripts lets script skills run shell commands (default: false)
+	AllowScripts bool `yaml:"allow_scripts"`
+
+	// Sandbox restricts script/API skills (see skills.SandboxConfig)
+	Sandbox         bool     `yaml:"sandbox"`                    // Enable sandbox mode (default: false)
+	AllowedCommands []string `yaml:"allowed_commands,omitempty"` // Commands sandboxed scripts may run
+	AllowedHosts    []string `yaml:"allowed_hosts,omitempty"`    // Hosts sandboxed skills may reach
 }
 
 // BackupConfig holds backup settings
@@ -390,7 +813,18 @@ type AgentConfig struct {
 	SessionTimeout util.Duration     `yaml:"session_timeout"` // idle session timeout, e.g. "6h" (0 = disabled)
 	Shortcuts      map[string]string `yaml:"shortcuts"`       // directory shortcuts, e.g. "myproject": "~/code/myproject"
 	DiscoverDepth  int               `yaml:"discover_depth"`  // auto-discover search depth (default 3)
+	MaxSessions    int               `yaml:"max_sessions"`    // concurrent named sessions per chat (default 3)
 	PlanDelegate   *bool             `yaml:"plan_delegate"`   // plan first, then delegate to subagents (default: true; uses single model)
+	StreamOutput   *bool             `yaml:"stream_output"`   // send agent output to chat as it arrives (default: true)
+
+	// Session jail and command policy
+	AllowedDirs     []string `yaml:"allowed_dirs"`     // directories sessions may start in (empty = home only)
+	AllowedCommands []string `yaml:"allowed_commands"` // programs the agent may run in Bash (empty = any)
+	DeniedPaths     []string `yaml:"denied_paths"`     // paths the agent may not touch, e.g. "~/.ssh"
+
+	// Session recording
+	Record            bool  `yaml:"record"`             // record each session's messages under exports_dir/agent-sessions
+	RedactTranscripts *bool `yaml:"redact_transcripts"` // mask API keys and tokens in transcripts (default: true)
 }
 
 // HooksFile is the top-level structure for config-hooks.yml
@@ -447,11 +881,32 @@ type EmbeddingConfig struct {
 	Dimensions   int           `yaml:"dimensions,omitempty"` // Output dimensions
 	MaxBatchSize int           `yaml:"max_batch_size"`       // Max texts per batch (default: 100)
 	Timeout      util.Duration `yaml:"timeout"`              // API timeout, e.g. "30s"
+	Proxy        string        `yaml:"proxy,omitempty"`      // HTTP proxy URL (default: HTTPS_PROXY/NO_PROXY)
+	// Rate limiting and retry
+	RequestsPerMinute int  `yaml:"requests_per_minute,omitempty"` // Client-side limit (0 = unlimited)
+	MaxRetries        *int `yaml:"max_retries,omitempty"`         // Retries on 429/5xx (default: 3, 0 = none)
 	// Memory integration
 	AutoEmbed   bool `yaml:"auto_embed"`   // Auto-generate embeddings for memories
 	SearchLimit int  `yaml:"search_limit"` // Default search result limit (default: 10)
 }
 
+// ModerationConfig holds content moderation settings. Messages are screened
+// before they reach the LLM and, with Output, answers before they reach the
+// user.
+type ModerationConfig struct {
+	Enabled    bool               `yaml:"enabled"`
+	Provider   string             `yaml:"provider,omitempty"`    // openai (default), local
+	APIKey     string             `yaml:"api_key,omitempty"`     // Defaults to llm.openai.api_key // #nosec G117
+	Model      string             `yaml:"model,omitempty"`       // Default: omni-moderation-latest
+	BaseURL    string             `yaml:"base_url,omitempty"`    // Custom API base URL
+	Timeout    util.Duration      `yaml:"timeout,omitempty"`     // API timeout (default: 10s)
+	Thresholds map[string]float64 `yaml:"thresholds,omitempty"`  // Category -> score that trips it, overriding the provider's verdict
+	Action     string             `yaml:"action,omitempty"`      // block (default) or flag (audit-log only)
+	Output     bool               `yaml:"output,omitempty"`      // Also screen answers (disables streamed previews)
+	Message    string             `yaml:"message,omitempty"`     // Reply to blocked messages (default: localized notice)
+	FailClosed bool               `yaml:"fail_closed,omitempty"` // Block when the moderation API fails (default: let through)
+}
+
 // PersonasConfig holds persona settings
 type PersonasConfig struct {
 	Default string    `yaml:"default"`
@@ -465,6 +920,7 @@ type Persona struct {
 	Personality  string `yaml:"personality"`
 	SystemPrompt string `yaml:"system_prompt"`
 	FirstMessage string `yaml:"first_message"`
+	Profile      string `yaml:"profile,omitempty"` // llm.profiles entry used instead of "chat"
 }
 
 // LocalSecretsConfig holds local file-based secrets settings
@@ -472,6 +928,12 @@ type LocalSecretsConfig struct {
 	Path string `yaml:"path"`
 }
 
+// Migrations returns what Load changed to bring the file up to
+// CurrentVersion, one line per change, for logging at startup.
+func (c *Config) Migrations() []string {
+	return c.migrations
+}
+
 // Load reads config from file
 func Load(filePath string) (*Config, error) {
 	cfg := &Config{
@@ -482,6 +944,7 @@ func Load(filePath string) (*Config, error) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Return default config
+			cfg.Version = strconv.Itoa(CurrentVersion)
 			cfg.setDefaults()
 			return cfg, nil
 		}
@@ -496,6 +959,9 @@ func Load(filePath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	if cfg.migrations, err = cfg.migrate(); err != nil {
+		return nil, err
+	}
 	cfg.setDefaults()
 	return cfg, nil
 }
@@ -533,7 +999,7 @@ func (c *Config) setDefaults() {
 		c.Access.Mode = "allowlist"
 	}
 	if c.Version == "" {
-		c.Version = "1"
+		c.Version = strconv.Itoa(CurrentVersion)
 	}
 
 	// Paths defaults
@@ -572,12 +1038,27 @@ func (c *Config) setDefaults() {
 	if c.Media.RetentionDays == 0 {
 		c.Media.RetentionDays = 60
 	}
+	if c.Media.MaxImageBytes <= 0 {
+		c.Media.MaxImageBytes = 5 << 20
+	}
+	if c.Media.MaxAudioDuration.IsZero() {
+		c.Media.MaxAudioDuration = util.NewDuration(30 * time.Minute)
+	}
+	if c.Media.AudioChunkLength.IsZero() {
+		c.Media.AudioChunkLength = util.NewDuration(5 * time.Minute)
+	}
 
 	// Skills defaults
 	if c.Skills.Dir == "" {
 		c.Skills.Dir = filepath.Join(home, "code", "magabot-skills")
 	}
 	c.Skills.Dir = expandPath(c.Skills.Dir)
+	if c.Skills.Timeout.IsZero() {
+		c.Skills.Timeout = util.NewDuration(30 * time.Second)
+	}
+	if c.Skills.MaxConcurrent <= 0 {
+		c.Skills.MaxConcurrent = 4
+	}
 	// Auto reload enabled by default
 	// (already false by default, set explicitly if needed)
 
@@ -592,10 +1073,21 @@ func (c *Config) setDefaults() {
 		c.Agent.SessionTimeout = util.NewDuration(6 * time.Hour)
 	}
 	// Temperature, MaxTokens, MaxRetries defaults for all providers (only if key missing from YAML)
-	for _, p := range []*LLMProviderConfig{
+	providerCfgs := []*LLMProviderConfig{
 		&c.LLM.Anthropic, &c.LLM.OpenAI, &c.LLM.GLM,
-		&c.LLM.Local, &c.LLM.Kimi, &c.LLM.MiniMax,
-	} {
+		&c.LLM.Local, &c.LLM.Ollama, &c.LLM.Kimi, &c.LLM.MiniMax,
+	}
+	for _, p := range c.LLM.Compatible {
+		providerCfgs = append(providerCfgs, p)
+	}
+	for i := range c.LLM.Custom {
+		c.LLM.Custom[i].Enabled = true
+		providerCfgs = append(providerCfgs, &c.LLM.Custom[i].LLMProviderConfig)
+	}
+	for _, p := range providerCfgs {
+		if p == nil {
+			continue
+		}
 		if p.Enabled && p.Temperature == nil {
 			p.Temperature = Float64Ptr(0.5)
 		}
@@ -616,19 +1108,34 @@ func (c *Config) setDefaults() {
 		t := true
 		c.Agent.PlanDelegate = &t
 	}
+	if c.Agent.StreamOutput == nil {
+		t := true
+		c.Agent.StreamOutput = &t
+	}
+	if c.Agent.RedactTranscripts == nil {
+		t := true
+		c.Agent.RedactTranscripts = &t
+	}
 
 	// Platform defaults
-	if c.Platforms.Telegram != nil {
-		// Coalesce Token / BotToken so either YAML key works
-		if c.Platforms.Telegram.BotToken == "" && c.Platforms.Telegram.Token != "" {
-			c.Platforms.Telegram.BotToken = c.Platforms.Telegram.Token
-		}
-	}
 	if c.Platforms.Discord != nil {
 		if c.Platforms.Discord.Prefix == "" {
 			c.Platforms.Discord.Prefix = "!"
 		}
 	}
+	if c.Platforms.WhatsApp != nil && c.Platforms.WhatsApp.DBPath != "" {
+		c.Platforms.WhatsApp.DBPath = expandPath(c.Platforms.WhatsApp.DBPath)
+	}
+
+	// Update defaults
+	if c.Update.Channel == "" {
+		c.Update.Channel = "stable"
+	}
+
+	// Session defaults
+	if c.Session.CleanupAge.IsZero() {
+		c.Session.CleanupAge = util.NewDuration(24 * time.Hour)
+	}
 
 	// SubAgent defaults
 	if c.SubAgents.MaxAgents <= 0 {
@@ -652,23 +1159,57 @@ func (c *Config) setDefaults() {
 	for i, dir := range c.Plugins.Dirs {
 		c.Plugins.Dirs[i] = expandPath(dir)
 	}
+	for prefix, path := range c.LLM.Tokenizers {
+		c.LLM.Tokenizers[prefix] = expandPath(path)
+	}
 
 	// Embedding defaults
-	if c.Embedding.MaxBatchSize <= 0 {
-		c.Embedding.MaxBatchSize = 100
+	setEmbeddingDefaults(&c.Embedding)
+	setEmbeddingDefaults(&c.Memory.Embedding)
+}
+
+// setEmbeddingDefaults fills unset embedding settings.
+func setEmbeddingDefaults(e *EmbeddingConfig) {
+	if e.MaxBatchSize <= 0 {
+		e.MaxBatchSize = 100
+	}
+	if e.Timeout.IsZero() {
+		e.Timeout = util.NewDuration(30 * time.Second)
 	}
-	if c.Embedding.Timeout.IsZero() {
-		c.Embedding.Timeout = util.NewDuration(30 * time.Second)
+	if e.SearchLimit <= 0 {
+		e.SearchLimit = 10
 	}
-	if c.Embedding.SearchLimit <= 0 {
-		c.Embedding.SearchLimit = 10
+	if e.MaxRetries == nil {
+		e.MaxRetries = IntPtr(3)
 	}
-	if c.Embedding.Provider == "" {
-		c.Embedding.Provider = "openai"
+	if e.Provider == "" {
+		e.Provider = "openai"
 	}
-	if c.Embedding.Model == "" {
-		c.Embedding.Model = "text-embedding-3-small"
+	if e.Model == "" {
+		e.Model = "text-embedding-3-small"
+	}
+}
+
+// DownloadsRetention returns how long downloaded media is kept:
+// paths.downloads_retention when set, else media.retention_days (0 = forever).
+func (c *Config) DownloadsRetention() time.Duration {
+	if d := c.Paths.DownloadsRetention.Duration(); d > 0 {
+		return d
 	}
+	return time.Duration(c.Media.RetentionDays) * 24 * time.Hour
+}
+
+// MemoryEmbedding returns the embedding settings for semantic memory:
+// memory.embedding when enabled, else the top-level embedding section when
+// enabled, else nil.
+func (c *Config) MemoryEmbedding() *EmbeddingConfig {
+	if c.Memory.Embedding.Enabled {
+		return &c.Memory.Embedding
+	}
+	if c.Embedding.Enabled {
+		return &c.Embedding
+	}
+	return nil
 }
 
 // expandPath expands ~ to home directory
@@ -744,6 +1285,9 @@ func (c *Config) pruneDisabledForSave() {
 	if !c.LLM.Local.Enabled {
 		c.LLM.Local = z
 	}
+	if !c.LLM.Ollama.Enabled {
+		c.LLM.Ollama = z
+	}
 	if !c.LLM.Kimi.Enabled {
 		c.LLM.Kimi = z
 	}
@@ -751,6 +1295,17 @@ func (c *Config) pruneDisabledForSave() {
 		c.LLM.MiniMax = z
 	}
 
+	// Build a fresh map so deleting entries doesn't touch the live config
+	if len(c.LLM.Compatible) > 0 {
+		compat := make(map[string]*LLMProviderConfig, len(c.LLM.Compatible))
+		for name, p := range c.LLM.Compatible {
+			if p != nil && p.Enabled {
+				compat[name] = p
+			}
+		}
+		c.LLM.Compatible = compat
+	}
+
 	// Also prune the alternative Providers structure
 	if c.LLM.Providers.Anthropic != nil && !c.LLM.Providers.Anthropic.Enabled {
 		c.LLM.Providers.Anthropic = nil
@@ -799,6 +1354,17 @@ func (c *Config) IsPlatformAdmin(platform, userID string) bool {
 	return c.isPlatformAdmin(platform, userID)
 }
 
+// PlatformAdmins returns a copy of the platform's admin IDs
+func (c *Config) PlatformAdmins(platform string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pa := c.getPlatformAccess(platform)
+	if pa == nil {
+		return nil
+	}
+	return append([]string(nil), pa.Admins...)
+}
+
 // isPlatformAdmin is the lock-free internal version (caller must hold mu)
 func (c *Config) isPlatformAdmin(platform, userID string) bool {
 	if platform == "whatsapp" {
@@ -846,6 +1412,47 @@ type PlatformAccess struct {
 	AllowDMs     bool
 }
 
+// CommandPrefix returns the prefix that marks a command on platform:
+// discord.prefix for Discord, bot.prefix (default "/") elsewhere.
+func (c *Config) CommandPrefix(platform string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if platform == "discord" {
+		if c.Platforms.Discord != nil && c.Platforms.Discord.Prefix != "" {
+			return c.Platforms.Discord.Prefix
+		}
+		return "!"
+	}
+	if c.Bot.Prefix != "" {
+		return c.Bot.Prefix
+	}
+	return "/"
+}
+
+// defaultLLMProfiles are used for intents llm.profiles doesn't list.
+var defaultLLMProfiles = map[string]LLMProfile{
+	"precise": {Temperature: 0.2}, // structured commands such as /summarize
+}
+
+// LLMProfile returns the llm.profiles entry for name; the default profile
+// or the zero profile when there is none. Its stop and seed default to
+// llm.stop and llm.seed.
+func (c *Config) LLMProfile(name string) LLMProfile {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p, ok := c.LLM.Profiles[name]
+	if !ok {
+		p = defaultLLMProfiles[name]
+	}
+	if len(p.Stop) == 0 {
+		p.Stop = c.LLM.Stop
+	}
+	if p.Seed == nil {
+		p.Seed = c.LLM.Seed
+	}
+	return p
+}
+
 // GetPlatformAccess returns a read-only snapshot of access fields for a platform (thread-safe).
 func (c *Config) GetPlatformAccess(platform string) *PlatformAccess {
 	c.mu.RLock()
@@ -917,7 +1524,7 @@ func (c *Config) isAllowedPlatform(pa *PlatformAccess, userID, chatID string, is
 	if util.Contains(pa.Admins, userID) {
 		return true
 	}
-	userOK := util.Contains(pa.AllowedUsers, userID)
+	userOK := util.ContainsMatch(pa.AllowedUsers, userID)
 	if len(pa.AllowedUsers) == 0 {
 		userOK = c.Access.Mode != "allowlist"
 	}
@@ -1053,7 +1660,15 @@ func (c *Config) PatchYAMLField(path, value string) error {
 }
 
 // patchYAMLNode navigates the yaml.Node tree and sets or removes a value.
+// A numeric path segment selects an existing sequence item.
 func patchYAMLNode(node *yaml.Node, path []string, value string) error {
+	if len(path) > 1 && node.Kind == yaml.SequenceNode {
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(node.Content) {
+			return fmt.Errorf("invalid sequence index %q", path[0])
+		}
+		return patchYAMLNode(node.Content[i], path[1:], value)
+	}
 	if len(path) == 0 || node.Kind != yaml.MappingNode {
 		return fmt.Errorf("invalid path or node type")
 	}
@@ -3,7 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/util"
 )
 
 func TestConfigLoad(t *testing.T) {
@@ -127,6 +131,39 @@ func TestIsAllowed(t *testing.T) {
 	}
 }
 
+func TestIsAllowedPatterns(t *testing.T) {
+	cfg := &Config{
+		Access: AccessConfig{
+			Mode: "allowlist",
+		},
+		Platforms: PlatformsConfig{
+			Slack: &SlackConfig{
+				Enabled:      true,
+				Admins:       []string{"U_ADMIN"},
+				AllowedUsers: []string{"U_TEAM_*", "*_BOT"},
+				AllowDMs:     true,
+			},
+		},
+	}
+
+	// Prefix and suffix patterns
+	if !cfg.IsAllowed("slack", "U_TEAM_42", "D1", false) {
+		t.Error("U_TEAM_42 should match U_TEAM_*")
+	}
+	if !cfg.IsAllowed("slack", "U_CI_BOT", "D1", false) {
+		t.Error("U_CI_BOT should match *_BOT")
+	}
+	if cfg.IsAllowed("slack", "U_OTHER", "D1", false) {
+		t.Error("U_OTHER should not match any pattern")
+	}
+
+	// Bare * allows everyone
+	cfg.Platforms.Slack.AllowedUsers = []string{"*"}
+	if !cfg.IsAllowed("slack", "U_ANYONE", "D1", false) {
+		t.Error("Bare * should allow everyone")
+	}
+}
+
 func TestIsAllowedOpenMode(t *testing.T) {
 	cfg := &Config{
 		Access: AccessConfig{
@@ -147,4 +184,449 @@ func TestIsAllowedOpenMode(t *testing.T) {
 	}
 }
 
+func TestMemoryEmbedding(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	if cfg.MemoryEmbedding() != nil {
+		t.Error("No embedding should be configured by default")
+	}
+	if cfg.Memory.Embedding.Model != "text-embedding-3-small" {
+		t.Errorf("Expected default model, got %q", cfg.Memory.Embedding.Model)
+	}
+
+	cfg.Embedding.Enabled = true
+	if cfg.MemoryEmbedding() != &cfg.Embedding {
+		t.Error("Top-level embedding should be used when memory.embedding is off")
+	}
+
+	cfg.Memory.Embedding.Enabled = true
+	if cfg.MemoryEmbedding() != &cfg.Memory.Embedding {
+		t.Error("memory.embedding should take precedence")
+	}
+}
+
+func TestEmbeddingMaxRetries(t *testing.T) {
+	cfg := &Config{}
+	cfg.Memory.Embedding.MaxRetries = IntPtr(0)
+	cfg.setDefaults()
+	if cfg.Embedding.MaxRetries == nil || *cfg.Embedding.MaxRetries != 3 {
+		t.Errorf("unset max_retries = %v, want the default 3", cfg.Embedding.MaxRetries)
+	}
+	if *cfg.Memory.Embedding.MaxRetries != 0 {
+		t.Errorf("max_retries: 0 became %d, want retries off", *cfg.Memory.Embedding.MaxRetries)
+	}
+}
+
 // Tests for Contains/Remove/AddUnique live in internal/util/util_test.go
+
+func TestCompatibleProviders(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	data := `llm:
+  main: groq
+  compatible:
+    groq:
+      enabled: true
+      api_key: gsk-test
+      base_url: https://api.groq.com/openai/v1
+      model: llama-3.3-70b-versatile
+    together:
+      enabled: false
+      base_url: https://api.together.xyz/v1
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	groq := cfg.LLM.GetProviderConfig("groq")
+	if groq == nil || groq.Model != "llama-3.3-70b-versatile" {
+		t.Fatalf("GetProviderConfig(groq) = %+v", groq)
+	}
+	if groq.MaxRetries == nil || *groq.MaxRetries != 2 {
+		t.Error("defaults should apply to enabled compatible providers")
+	}
+	if got := cfg.LLM.ProviderYAMLPath("groq"); got != "llm.compatible.groq" {
+		t.Errorf("ProviderYAMLPath(groq) = %q", got)
+	}
+	if got := cfg.LLM.ProviderYAMLPath("openai"); got != "llm.openai" {
+		t.Errorf("ProviderYAMLPath(openai) = %q", got)
+	}
+
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, ok := cfg.LLM.Compatible["together"]; !ok {
+		t.Error("Save must not prune the live config")
+	}
+
+	cfg2, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, ok := cfg2.LLM.Compatible["together"]; ok {
+		t.Error("disabled compatible provider should be pruned on save")
+	}
+	if cfg2.LLM.GetProviderConfig("groq") == nil {
+		t.Error("enabled compatible provider should survive save")
+	}
+}
+
+func TestCustomProviders(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	data := `llm:
+  main: groq
+  custom:
+    - name: groq
+      base_url: https://api.groq.com/openai/v1
+      api_key: gsk-test
+      model: llama-3.3-70b-versatile
+    - name: proxy
+      format: anthropic
+      base_url: https://llm.example.com
+      api_key: sk-test
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.LLM.Custom) != 2 || cfg.LLM.Custom[1].Format != "anthropic" {
+		t.Fatalf("Custom = %+v", cfg.LLM.Custom)
+	}
+	proxy := cfg.LLM.GetProviderConfig("proxy")
+	if proxy == nil || !proxy.Enabled || proxy.MaxRetries == nil {
+		t.Fatalf("GetProviderConfig(proxy) = %+v, want enabled with defaults", proxy)
+	}
+	if got := cfg.LLM.ProviderYAMLPath("proxy"); got != "llm.custom.1" {
+		t.Errorf("ProviderYAMLPath(proxy) = %q", got)
+	}
+
+	if err := cfg.PatchYAMLField(cfg.LLM.ProviderYAMLPath("proxy")+".model", "claude-sonnet-4-6"); err != nil {
+		t.Fatalf("PatchYAMLField failed: %v", err)
+	}
+	cfg2, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := cfg2.LLM.GetProviderConfig("proxy").Model; got != "claude-sonnet-4-6" {
+		t.Errorf("patched model = %q", got)
+	}
+	if err := cfg.PatchYAMLField("llm.custom.5.model", "x"); err == nil {
+		t.Error("out-of-range index should fail")
+	}
+}
+
+func TestDownloadsRetention(t *testing.T) {
+	cfg := &Config{}
+	cfg.Media.RetentionDays = 2
+	if got := cfg.DownloadsRetention(); got != 48*time.Hour {
+		t.Errorf("DownloadsRetention() = %v, want media.retention_days", got)
+	}
+	cfg.Paths.DownloadsRetention = util.NewDuration(6 * time.Hour)
+	if got := cfg.DownloadsRetention(); got != 6*time.Hour {
+		t.Errorf("DownloadsRetention() = %v, want paths.downloads_retention", got)
+	}
+}
+
+func TestIsSecretKey(t *testing.T) {
+	secret := []string{"api_key", "api_keys", "auth_token", "bot_token", "app_token", "token", "bearer_tokens",
+		"signing_secret", "webhook_secret", "hmac_secret", "hmac_users", "otlp_headers", "extra_headers", "encryption_key", "password", "basic_pass"}
+	for _, k := range secret {
+		if !IsSecretKey(k) {
+			t.Errorf("IsSecretKey(%q) = false, want true", k)
+		}
+	}
+	plain := []string{"max_tokens", "max_context_tokens", "secret_path", "secrets", "auth_method", "model", "base_url", "basic_user", "bypass"}
+	for _, k := range plain {
+		if IsSecretKey(k) {
+			t.Errorf("IsSecretKey(%q) = true, want false", k)
+		}
+	}
+}
+
+func TestProviderKeys(t *testing.T) {
+	p := LLMProviderConfig{APIKey: "a", APIKeys: []string{"b", "", "a", "c"}}
+	if got := strings.Join(p.Keys(), ","); got != "a,b,c" {
+		t.Errorf("Keys() = %q, want a,b,c", got)
+	}
+	if got := (&LLMProviderConfig{}).Keys(); len(got) != 0 {
+		t.Errorf("Keys() with no keys = %q", got)
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	if got := MaskSecret("sk-ant-api03-abcdef1234"); got != "****…1234" {
+		t.Errorf("MaskSecret(long) = %q", got)
+	}
+	if got := MaskSecret("short"); got != "****" {
+		t.Errorf("MaskSecret(short) = %q", got)
+	}
+	if got := MaskSecret(""); got != "" {
+		t.Errorf("MaskSecret(empty) = %q", got)
+	}
+}
+
+func TestRedactedYAML(t *testing.T) {
+	cfg := &Config{}
+	cfg.Security.EncryptionKey = "encryption-key-9999"
+	cfg.LLM.Anthropic = LLMProviderConfig{Enabled: true, APIKey: "sk-ant-secret-1111", APIKeys: []string{"sk-ant-secret-5555"}, Model: "claude", MaxTokens: IntPtr(4096)}
+	cfg.LLM.Compatible = map[string]*LLMProviderConfig{
+		"openrouter": {Enabled: true, APIKey: "sk-or-secret-2222", BaseURL: "https://openrouter.ai/api/v1"},
+	}
+	cfg.Platforms.Telegram = &TelegramConfig{Enabled: true, BotToken: "123456:telegram-3333"}
+	cfg.Platforms.Webhook = &WebhookConfig{BearerTokens: map[string]string{"bearer-token-4444": "user1"}}
+
+	data, err := cfg.RedactedYAML()
+	if err != nil {
+		t.Fatalf("RedactedYAML: %v", err)
+	}
+	out := string(data)
+
+	for _, leaked := range []string{"encryption-key", "sk-ant-secret", "sk-or-secret", "telegram-3333", "bearer-token"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("output leaks %q:\n%s", leaked, out)
+		}
+	}
+	for _, want := range []string{"****…9999", "****…1111", "****…2222", "****…3333", "****…4444", "****…5555", "claude", "max_tokens: 4096", "openrouter"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Live config must be untouched
+	if cfg.LLM.Anthropic.APIKey != "sk-ant-secret-1111" || cfg.LLM.Compatible["openrouter"].APIKey != "sk-or-secret-2222" {
+		t.Error("RedactedYAML modified the live config")
+	}
+}
+
+func TestCommandPrefix(t *testing.T) {
+	cfg := &Config{}
+	cfg.setDefaults()
+	if got := cfg.CommandPrefix("telegram"); got != "/" {
+		t.Errorf("telegram prefix = %q, want /", got)
+	}
+	if got := cfg.CommandPrefix("discord"); got != "!" {
+		t.Errorf("unconfigured discord prefix = %q, want !", got)
+	}
+
+	cfg.Bot.Prefix = "."
+	cfg.Platforms.Discord = &DiscordConfig{Prefix: "?"}
+	if got := cfg.CommandPrefix("slack"); got != "." {
+		t.Errorf("slack prefix = %q, want bot.prefix", got)
+	}
+	if got := cfg.CommandPrefix("discord"); got != "?" {
+		t.Errorf("discord prefix = %q, want ?", got)
+	}
+}
+
+func TestLoadMigratesV0(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	v0 := `platforms:
+  telegram:
+    enabled: true
+    token: "123:abc"
+llm:
+  main: openai
+  providers:
+    openai:
+      enabled: true
+      model: gpt-4o
+`
+	if err := os.WriteFile(path, []byte(v0), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Version != "3" {
+		t.Errorf("version = %q, want 3", cfg.Version)
+	}
+	if tg := cfg.Platforms.Telegram; tg.BotToken != "123:abc" || tg.Token != "" {
+		t.Errorf("telegram token/bot_token = %q/%q, want moved to bot_token", tg.Token, tg.BotToken)
+	}
+	if !cfg.LLM.OpenAI.Enabled || cfg.LLM.OpenAI.Model != "gpt-4o" || cfg.LLM.Providers.OpenAI != nil {
+		t.Errorf("llm.providers.openai not moved to llm.openai: %+v", cfg.LLM.OpenAI)
+	}
+	want := []string{
+		"v1→v2: platforms.telegram.token renamed to bot_token",
+		"v1→v2: llm.providers.openai moved to llm.openai",
+	}
+	if got := cfg.Migrations(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("migrations = %q, want %q", got, want)
+	}
+
+	// The upgrade is written back on save and not repeated
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "version: \"3\"") || strings.Contains(string(data), "providers:") {
+		t.Errorf("saved config not upgraded:\n%s", data)
+	}
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(cfg.Migrations()) != 0 || cfg.Platforms.Telegram.BotToken != "123:abc" || !cfg.LLM.OpenAI.Enabled {
+		t.Errorf("reloaded config: migrations %q, bot_token %q, openai %v", cfg.Migrations(), cfg.Platforms.Telegram.BotToken, cfg.LLM.OpenAI.Enabled)
+	}
+}
+
+func TestMigrateKeepsExistingValues(t *testing.T) {
+	cfg := &Config{Version: "1"}
+	cfg.Platforms.Telegram = &TelegramConfig{Token: "old", BotToken: "new"}
+	cfg.LLM.Anthropic.Model = "claude-sonnet-4-6"
+	cfg.LLM.Providers.Anthropic = &LLMProviderConfig{Model: "claude-2"}
+
+	changes, err := cfg.migrate()
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("changes = %q, want 2 drops", changes)
+	}
+	if cfg.Platforms.Telegram.BotToken != "new" || cfg.LLM.Anthropic.Model != "claude-sonnet-4-6" {
+		t.Errorf("existing values overwritten: bot_token %q, model %q", cfg.Platforms.Telegram.BotToken, cfg.LLM.Anthropic.Model)
+	}
+}
+
+func TestMigrateMaxInputLength(t *testing.T) {
+	// v2 counted max_input_length in characters
+	cfg := &Config{Version: "2"}
+	cfg.LLM.MaxInputLength = 10000
+	changes, err := cfg.migrate()
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if cfg.LLM.MaxInputTokens != 2500 || cfg.LLM.MaxInputLength != 0 {
+		t.Errorf("max_input_tokens/length = %d/%d, want 2500/0", cfg.LLM.MaxInputTokens, cfg.LLM.MaxInputLength)
+	}
+	if len(changes) != 1 || !strings.Contains(changes[0], "replaced by max_input_tokens: 2500") {
+		t.Errorf("changes = %q", changes)
+	}
+
+	cfg = &Config{Version: "2"}
+	cfg.LLM.MaxInputLength, cfg.LLM.MaxInputTokens = 10000, 8000
+	if _, err := cfg.migrate(); err != nil || cfg.LLM.MaxInputTokens != 8000 {
+		t.Errorf("existing max_input_tokens = %d, %v; want 8000 kept", cfg.LLM.MaxInputTokens, err)
+	}
+}
+
+func TestMigrateVersions(t *testing.T) {
+	cfg := &Config{Version: "3"}
+	if changes, err := cfg.migrate(); err != nil || len(changes) != 0 {
+		t.Errorf("current version: changes %q, err %v; want none", changes, err)
+	}
+
+	cfg = &Config{Version: "99"}
+	changes, err := cfg.migrate()
+	if err != nil || len(changes) != 1 || cfg.Version != "99" {
+		t.Errorf("newer version: changes %q, err %v, version %q; want a note and no change", changes, err, cfg.Version)
+	}
+
+	cfg = &Config{Version: "two"}
+	if _, err := cfg.migrate(); err == nil {
+		t.Error("invalid version should fail")
+	}
+}
+
+func TestCommandPermission(t *testing.T) {
+	cfg := &Config{Commands: CommandsConfig{Permissions: map[string]string{
+		"model":  PermissionAdmin,
+		"memory": PermissionAllowlist,
+		"help":   PermissionAll,
+		"image":  "admins", // typo
+	}}}
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"/model", PermissionAdmin},
+		{"MODEL", PermissionAdmin},
+		{"memory", PermissionAllowlist},
+		{"/help", PermissionAll},
+		{"/status", PermissionAll}, // unlisted
+		{"/image", PermissionAdmin},
+	}
+	for _, tt := range tests {
+		if got := cfg.CommandPermission(tt.command); got != tt.want {
+			t.Errorf("CommandPermission(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	if err := cfg.Commands.Validate(); err == nil || !strings.Contains(err.Error(), `image: "admins"`) {
+		t.Errorf("Validate() = %v, want an error naming image", err)
+	}
+	delete(cfg.Commands.Permissions, "image")
+	if err := cfg.Commands.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestCanRunCommand(t *testing.T) {
+	cfg := &Config{
+		Access: AccessConfig{Mode: "open"},
+		Platforms: PlatformsConfig{
+			Telegram: &TelegramConfig{
+				Enabled:      true,
+				Admins:       []string{"tg_admin"},
+				AllowedUsers: []string{"tg_user"},
+			},
+		},
+		Commands: CommandsConfig{Permissions: map[string]string{
+			"model":  PermissionAdmin,
+			"memory": PermissionAllowlist,
+		}},
+	}
+
+	tests := []struct {
+		userID  string
+		command string
+		want    bool
+	}{
+		{"tg_admin", "/model", true},
+		{"tg_user", "/model", false},
+		{"tg_admin", "/memory", true},
+		{"tg_user", "/memory", true},
+		{"stranger", "/memory", false}, // open mode lets them in, not past allowlist
+		{"stranger", "/help", true},
+	}
+	for _, tt := range tests {
+		if got := cfg.CanRunCommand("telegram", tt.userID, tt.command); got != tt.want {
+			t.Errorf("CanRunCommand(%q, %q) = %v, want %v", tt.userID, tt.command, got, tt.want)
+		}
+	}
+	if cfg.CanRunCommand("slack", "tg_admin", "/model") {
+		t.Error("telegram admin should not pass an admin command on slack")
+	}
+}
+
+func TestCommandDisabled(t *testing.T) {
+	cfg := &Config{Commands: CommandsConfig{Disabled: []string{"Task", "/memory", ":agent"}}}
+	for command, want := range map[string]bool{
+		"/task": true, "task": true, "/memory": true, "/help": false,
+		":new": true, ":send": true, ":quit": true, ":status": true, "/status": false,
+	} {
+		if got := cfg.CommandDisabled(command); got != want {
+			t.Errorf("CommandDisabled(%q) = %v, want %v", command, got, want)
+		}
+	}
+	cfg.Commands.Disabled = []string{":new"}
+	if !cfg.CommandDisabled(":new") || cfg.CommandDisabled(":quit") {
+		t.Error("a single agent command should disable only itself")
+	}
+}
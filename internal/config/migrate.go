@@ -0,0 +1,115 @@
+// Config schema migrations, applied on load
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// CurrentVersion is the schema version this build writes. Bump it together
+// with a new entry in migrations whenever a key is renamed or moved.
+const CurrentVersion = 3
+
+// migration upgrades a config from version from to from+1. apply returns
+// one line per change it made, for the startup log.
+type migration struct {
+	from  int
+	apply func(c *Config) []string
+}
+
+// migrations run in order, starting at the config's version.
+var migrations = []migration{
+	// v0 configs predate the version key; nothing else changed
+	{from: 0, apply: func(*Config) []string { return nil }},
+	{from: 1, apply: migrateV1},
+	{from: 2, apply: migrateV2},
+}
+
+// migrate upgrades c in memory to CurrentVersion and returns what changed.
+// The new version is written on the next Save. A config newer than this
+// build is left alone.
+func (c *Config) migrate() ([]string, error) {
+	version := 0
+	if c.Version != "" {
+		v, err := strconv.Atoi(c.Version)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid config version %q", c.Version)
+		}
+		version = v
+	}
+	if version > CurrentVersion {
+		return []string{fmt.Sprintf("config version %d is newer than this build supports (%d); loaded as is", version, CurrentVersion)}, nil
+	}
+
+	var changes []string
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		for _, change := range m.apply(c) {
+			changes = append(changes, fmt.Sprintf("v%d→v%d: %s", m.from, m.from+1, change))
+		}
+	}
+	c.Version = strconv.Itoa(CurrentVersion)
+	return changes, nil
+}
+
+// migrateV1 renames platforms.telegram.token to bot_token and moves the
+// unused llm.providers.<name> blocks to llm.<name>.
+func migrateV1(c *Config) []string {
+	var changes []string
+	if tg := c.Platforms.Telegram; tg != nil && tg.Token != "" {
+		if tg.BotToken == "" {
+			tg.BotToken = tg.Token
+			changes = append(changes, "platforms.telegram.token renamed to bot_token")
+		} else {
+			changes = append(changes, "platforms.telegram.token dropped in favor of bot_token")
+		}
+		tg.Token = ""
+	}
+
+	moves := []struct {
+		name string
+		from *LLMProviderConfig
+		to   *LLMProviderConfig
+	}{
+		{"anthropic", c.LLM.Providers.Anthropic, &c.LLM.Anthropic},
+		{"openai", c.LLM.Providers.OpenAI, &c.LLM.OpenAI},
+		{"glm", c.LLM.Providers.GLM, &c.LLM.GLM},
+		{"kimi", c.LLM.Providers.Kimi, &c.LLM.Kimi},
+		{"minimax", c.LLM.Providers.MiniMax, &c.LLM.MiniMax},
+	}
+	for _, mv := range moves {
+		if mv.from == nil {
+			continue
+		}
+		if reflect.ValueOf(*mv.to).IsZero() {
+			*mv.to = *mv.from
+			changes = append(changes, fmt.Sprintf("llm.providers.%s moved to llm.%s", mv.name, mv.name))
+		} else {
+			changes = append(changes, fmt.Sprintf("llm.providers.%s dropped: llm.%s is already set", mv.name, mv.name))
+		}
+	}
+	c.LLM.Providers = ProvidersConfig{}
+	return changes
+}
+
+// charsPerToken converts v2's llm.max_input_length, counted in characters,
+// to tokens: the usual ratio for English text.
+const charsPerToken = 4
+
+// migrateV2 replaces llm.max_input_length, a character count, with
+// max_input_tokens, so an old limit keeps about the same size.
+func migrateV2(c *Config) []string {
+	chars := c.LLM.MaxInputLength
+	if chars <= 0 {
+		return nil
+	}
+	c.LLM.MaxInputLength = 0
+	if c.LLM.MaxInputTokens > 0 {
+		return []string{"llm.max_input_length dropped: max_input_tokens is already set"}
+	}
+	c.LLM.MaxInputTokens = (chars + charsPerToken - 1) / charsPerToken
+	return []string{fmt.Sprintf("llm.max_input_length (%d characters) replaced by max_input_tokens: %d", chars, c.LLM.MaxInputTokens)}
+}
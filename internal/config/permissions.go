@@ -0,0 +1,107 @@
+// Per-command permission levels
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kusa/magabot/internal/util"
+)
+
+// Permission levels for commands.permissions.
+const (
+	PermissionAll       = "all"       // everyone the bot answers
+	PermissionAllowlist = "allowlist" // platform admins and allowed_users, even in open mode
+	PermissionAdmin     = "admin"     // platform admins
+)
+
+// CommandsConfig holds chat command settings.
+type CommandsConfig struct {
+	// Permissions maps command names, without the prefix, to a permission
+	// level. Unlisted commands are open to all; commands that always need an
+	// admin, such as /config, keep checking for one.
+	Permissions map[string]string `yaml:"permissions,omitempty"`
+
+	// Disabled lists built-in commands no one can run, hidden from /help:
+	// chat commands without the prefix ("task", "memory"), agent session
+	// commands with theirs (":new"), or ":agent" for all of those.
+	Disabled []string `yaml:"disabled,omitempty"`
+}
+
+// Validate reports permissions with an unknown level.
+func (c *CommandsConfig) Validate() error {
+	var bad []string
+	for name, level := range c.Permissions {
+		switch level {
+		case PermissionAll, PermissionAllowlist, PermissionAdmin:
+		default:
+			bad = append(bad, fmt.Sprintf("%s: %q", name, level))
+		}
+	}
+	if len(bad) > 0 {
+		sort.Strings(bad)
+		return fmt.Errorf("unknown command permission (want all, allowlist or admin): %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
+
+// CommandPermission returns the permission level of command, given with or
+// without its "/" prefix. Unlisted commands are PermissionAll; an unknown
+// level counts as PermissionAdmin, so a typo never opens a command up.
+func (c *Config) CommandPermission(command string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.commandPermission(command)
+}
+
+func (c *Config) commandPermission(command string) string {
+	level, ok := c.Commands.Permissions[strings.ToLower(strings.TrimPrefix(command, "/"))]
+	switch {
+	case !ok || level == "":
+		return PermissionAll
+	case level == PermissionAll || level == PermissionAllowlist:
+		return level
+	}
+	return PermissionAdmin
+}
+
+// agentCommands are the agent session commands ":agent" disables.
+var agentCommands = []string{":new", ":send", ":quit", ":exit", ":close", ":status", ":save"}
+
+// CommandDisabled reports whether command, a chat command with or without
+// its "/" prefix or an agent command with its ":", is in commands.disabled.
+func (c *Config) CommandDisabled(command string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	command = strings.ToLower(strings.TrimPrefix(command, "/"))
+	for _, name := range c.Commands.Disabled {
+		name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "/"))
+		if name == command || (name == ":agent" && slices.Contains(agentCommands, command)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRunCommand reports whether userID on platform may run command.
+func (c *Config) CanRunCommand(platform, userID, command string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	switch c.commandPermission(command) {
+	case PermissionAll:
+		return true
+	case PermissionAllowlist:
+		if c.isPlatformAdmin(platform, userID) {
+			return true
+		}
+		if platform == "whatsapp" {
+			userID = util.NormalizeWhatsAppJID(userID)
+		}
+		pa := c.getPlatformAccess(platform)
+		return pa != nil && util.ContainsMatch(pa.AllowedUsers, userID)
+	}
+	return c.isPlatformAdmin(platform, userID)
+}
@@ -0,0 +1,139 @@
+// Secret masking for displaying configuration
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretKeyPattern matches yaml keys that hold credentials
+// (api_key, api_keys, bot_token, bearer_tokens, signing_secret, basic_pass, ...).
+var secretKeyPattern = regexp.MustCompile(`(^|_)(key|keys|token|tokens|secret|pass|password|passphrase)$`)
+
+// IsSecretKey reports whether a yaml key holds a secret value.
+// Numeric limits such as max_tokens are excluded.
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasPrefix(key, "max_") {
+		return false
+	}
+	return key == "hmac_users" || key == "otlp_headers" || key == "extra_headers" || secretKeyPattern.MatchString(key)
+}
+
+// MaskSecret hides all but the last 4 characters of a secret.
+// Short values are fully masked so little of them leaks.
+func MaskSecret(v string) string {
+	if v == "" {
+		return ""
+	}
+	if len(v) <= 8 {
+		return "****"
+	}
+	return "****…" + v[len(v)-4:]
+}
+
+// RedactSecrets walks v (a pointer to a struct) and masks every string,
+// string slice and string map stored under a secret yaml key. Nested structs,
+// pointers, slices and maps are followed, so new secret fields are covered
+// without changes here.
+func RedactSecrets(v interface{}) {
+	redactValue(reflect.ValueOf(v), false)
+}
+
+func redactValue(v reflect.Value, secret bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			redactValue(v.Elem(), secret)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			redactValue(v.Field(i), IsSecretKey(name))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			redactValue(v.Index(i), secret)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		redactMap(v, secret)
+	case reflect.String:
+		if secret && v.CanSet() {
+			v.SetString(MaskSecret(v.String()))
+		}
+	}
+}
+
+// redactMap handles map entries, which are not addressable. Under a secret
+// key both keys and values are masked (e.g. bearer_tokens maps token → user).
+func redactMap(m reflect.Value, secret bool) {
+	type entry struct{ k, v reflect.Value }
+	var deletes []reflect.Value
+	var sets []entry
+
+	iter := m.MapRange()
+	for iter.Next() {
+		k, val := iter.Key(), iter.Value()
+
+		// Copy the value so it can be modified, then write it back
+		cp := reflect.New(val.Type()).Elem()
+		cp.Set(val)
+		redactValue(cp, secret)
+
+		nk := k
+		if secret && k.Kind() == reflect.String {
+			nk = reflect.New(k.Type()).Elem()
+			nk.SetString(MaskSecret(k.String()))
+			deletes = append(deletes, k)
+		}
+		sets = append(sets, entry{k: nk, v: cp})
+	}
+
+	for _, k := range deletes {
+		m.SetMapIndex(k, reflect.Value{})
+	}
+	for _, e := range sets {
+		m.SetMapIndex(e.k, e.v)
+	}
+}
+
+// RedactedYAML returns the config serialized as YAML with all secrets masked.
+// The live config is not modified.
+func (c *Config) RedactedYAML() ([]byte, error) {
+	c.mu.RLock()
+	data, err := yaml.Marshal(c)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	var cp Config
+	if err := yaml.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	RedactSecrets(&cp)
+
+	out, err := yaml.Marshal(&cp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return out, nil
+}
@@ -3,6 +3,7 @@ package cron
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -19,14 +20,22 @@ type NotifyChannel struct {
 	Name   string `json:"name"`   // friendly name for display
 }
 
+// Job kinds
+const (
+	KindMessage   = "message"    // send Message as is (default)
+	KindLLMDigest = "llm_digest" // send Message to the LLM as a prompt and post the answer
+)
+
 // Job represents a scheduled cron job
 type Job struct {
 	ID          string          `json:"id"`
 	Name        string          `json:"name"`
 	Description string          `json:"description,omitempty"`
-	Schedule    string          `json:"schedule"` // cron expression (e.g., "0 9 * * 1-5")
-	Message     string          `json:"message"`  // message to send
-	Channels    []NotifyChannel `json:"channels"` // where to send
+	Schedule    string          `json:"schedule"`              // cron expression (e.g., "0 9 * * 1-5")
+	Kind        string          `json:"kind,omitempty"`        // KindMessage or KindLLMDigest; empty means KindMessage
+	Message     string          `json:"message"`               // message to send, or the prompt for llm_digest
+	MemoryUser  string          `json:"memory_user,omitempty"` // llm_digest: user whose memories are added as context
+	Channels    []NotifyChannel `json:"channels"`              // where to send
 	Enabled     bool            `json:"enabled"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
@@ -35,6 +44,23 @@ type Job struct {
 	RunCount    int64           `json:"run_count"`
 }
 
+// IsDigest reports whether the job posts an LLM-generated digest.
+func (j *Job) IsDigest() bool {
+	return j.Kind == KindLLMDigest
+}
+
+// validKind reports whether kind is a known job kind.
+func validKind(kind string) bool {
+	switch kind {
+	case "", KindMessage, KindLLMDigest:
+		return true
+	}
+	return false
+}
+
+// ErrJobNotFound is returned for a job ID the store doesn't have.
+var ErrJobNotFound = errors.New("job not found")
+
 // JobStore manages persistent storage of cron jobs
 type JobStore struct {
 	mu       sync.RWMutex
@@ -117,6 +143,9 @@ func (s *JobStore) save() error {
 
 // Create adds a new job
 func (s *JobStore) Create(job *Job) error {
+	if !validKind(job.Kind) {
+		return fmt.Errorf("unknown job kind: %s", job.Kind)
+	}
 	if job.ID == "" {
 		job.ID = uuid.New().String()[:8] // short ID for usability
 	}
@@ -132,6 +161,9 @@ func (s *JobStore) Create(job *Job) error {
 
 // Update modifies an existing job
 func (s *JobStore) Update(job *Job) error {
+	if !validKind(job.Kind) {
+		return fmt.Errorf("unknown job kind: %s", job.Kind)
+	}
 	s.mu.Lock()
 	if _, exists := s.jobs[job.ID]; !exists {
 		s.mu.Unlock()
@@ -164,7 +196,7 @@ func (s *JobStore) Get(id string) (*Job, error) {
 
 	job, exists := s.jobs[id]
 	if !exists {
-		return nil, fmt.Errorf("job not found: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
 	}
 
 	// Return a copy
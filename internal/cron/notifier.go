@@ -26,6 +26,8 @@ type NotifierConfig struct {
 type Notifier struct {
 	config     NotifierConfig
 	httpClient *http.Client
+	throttle   func(ctx context.Context, platform, target string) error
+	hold       func(platform, target, message string) bool
 }
 
 // NewNotifier creates a new notifier
@@ -36,16 +38,67 @@ func NewNotifier(config NotifierConfig) *Notifier {
 	}
 }
 
+// SetThrottle sets a wait before each send, such as the daemon router's
+// per-platform send limit, so job fan-outs stay under it. platform is the
+// channel type's full name (telegram, whatsapp, slack or discord).
+func (n *Notifier) SetThrottle(wait func(ctx context.Context, platform, target string) error) {
+	n.throttle = wait
+}
+
+// wait applies the throttle, if any.
+func (n *Notifier) wait(ctx context.Context, platform, target string) error {
+	if n.throttle == nil {
+		return nil
+	}
+	return n.throttle(ctx, platform, target)
+}
+
+// SetHold sets a check run before each send that may take the message
+// over, such as the daemon router holding it for quiet hours; the message
+// isn't sent when hold returns true. platform is as for SetThrottle.
+func (n *Notifier) SetHold(hold func(platform, target, message string) bool) {
+	n.hold = hold
+}
+
+// held reports whether the hold, if any, took the message over.
+func (n *Notifier) held(platform, target, message string) bool {
+	return n.hold != nil && n.hold(platform, target, message)
+}
+
 // Send dispatches a message to the specified channel
 func (n *Notifier) Send(ctx context.Context, ch NotifyChannel, message string) error {
 	switch strings.ToLower(ch.Type) {
 	case "telegram", "tg":
+		if n.held("telegram", ch.Target, message) {
+			return nil
+		}
+		if err := n.wait(ctx, "telegram", ch.Target); err != nil {
+			return err
+		}
 		return n.sendTelegram(ctx, ch.Target, message)
 	case "whatsapp", "wa":
+		if n.held("whatsapp", ch.Target, message) {
+			return nil
+		}
+		if err := n.wait(ctx, "whatsapp", ch.Target); err != nil {
+			return err
+		}
 		return n.sendWhatsApp(ctx, ch.Target, message)
 	case "slack":
+		if n.held("slack", ch.Target, message) {
+			return nil
+		}
+		if err := n.wait(ctx, "slack", ch.Target); err != nil {
+			return err
+		}
 		return n.sendSlack(ctx, ch.Target, message)
 	case "discord":
+		if n.held("discord", ch.Target, message) {
+			return nil
+		}
+		if err := n.wait(ctx, "discord", ch.Target); err != nil {
+			return err
+		}
 		return n.sendDiscord(ctx, ch.Target, message)
 	case "webhook":
 		return n.sendWebhook(ctx, ch.Target, message)
@@ -0,0 +1,91 @@
+package cron
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuietHours is a daily window, such as 22:00-07:00, during which
+// proactive messages are held back. A window whose end is before its start
+// spans midnight.
+type QuietHours struct {
+	start, end time.Duration // since midnight
+	loc        *time.Location
+	platforms  []string // empty = all
+}
+
+// ParseQuietHours parses a window from "HH:MM" start and end times in
+// timezone (empty = local time; aliases such as WIB are accepted). It
+// applies to platforms, or to all of them when none are given.
+func ParseQuietHours(start, end, timezone string, platforms []string) (*QuietHours, error) {
+	from, err := parseClock(start)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours start: %w", err)
+	}
+	to, err := parseClock(end)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours end: %w", err)
+	}
+	if from == to {
+		return nil, fmt.Errorf("quiet hours start and end are both %s", start)
+	}
+	loc := time.Local
+	if timezone != "" {
+		if loc, err = time.LoadLocation(ResolveTimezone(timezone)); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+	return &QuietHours{start: from, end: to, loc: loc, platforms: platforms}, nil
+}
+
+// parseClock parses "HH:MM" into the time since midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Applies reports whether the window covers platform.
+func (q *QuietHours) Applies(platform string) bool {
+	if len(q.platforms) == 0 {
+		return true
+	}
+	for _, p := range q.platforms {
+		if strings.EqualFold(p, platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether t falls inside the window.
+func (q *QuietHours) Active(t time.Time) bool {
+	since := sinceMidnight(t.In(q.loc))
+	if q.start < q.end {
+		return since >= q.start && since < q.end
+	}
+	return since >= q.start || since < q.end
+}
+
+// Ends returns when the window t falls in ends, or t itself when the
+// window isn't active at t.
+func (q *QuietHours) Ends(t time.Time) time.Time {
+	if !q.Active(t) {
+		return t
+	}
+	local := t.In(q.loc)
+	y, m, d := local.Date()
+	if q.start > q.end && sinceMidnight(local) >= q.start {
+		d++ // before midnight; the window ends tomorrow
+	}
+	return time.Date(y, m, d, int(q.end/time.Hour), int(q.end%time.Hour/time.Minute), 0, 0, q.loc)
+}
+
+// sinceMidnight returns the wall-clock time of day of t.
+func sinceMidnight(t time.Time) time.Duration {
+	h, m, s := t.Clock()
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+}
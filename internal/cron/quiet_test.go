@@ -0,0 +1,107 @@
+package cron
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursAcrossMidnight(t *testing.T) {
+	q, err := ParseQuietHours("22:00", "07:00", "Asia/Jakarta", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+
+	tests := []struct {
+		at     time.Time
+		active bool
+		ends   time.Time
+	}{
+		{time.Date(2026, 3, 1, 21, 59, 0, 0, jakarta), false, time.Time{}},
+		{time.Date(2026, 3, 1, 22, 0, 0, 0, jakarta), true, time.Date(2026, 3, 2, 7, 0, 0, 0, jakarta)},
+		{time.Date(2026, 3, 1, 23, 59, 0, 0, jakarta), true, time.Date(2026, 3, 2, 7, 0, 0, 0, jakarta)},
+		{time.Date(2026, 3, 2, 0, 0, 0, 0, jakarta), true, time.Date(2026, 3, 2, 7, 0, 0, 0, jakarta)},
+		{time.Date(2026, 3, 2, 3, 0, 0, 0, jakarta), true, time.Date(2026, 3, 2, 7, 0, 0, 0, jakarta)},
+		{time.Date(2026, 3, 2, 7, 0, 0, 0, jakarta), false, time.Time{}},
+		{time.Date(2026, 3, 2, 12, 0, 0, 0, jakarta), false, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := q.Active(tt.at); got != tt.active {
+			t.Errorf("Active(%s) = %v, want %v", tt.at.Format("15:04"), got, tt.active)
+		}
+		want := tt.ends
+		if !tt.active {
+			want = tt.at
+		}
+		if got := q.Ends(tt.at); !got.Equal(want) {
+			t.Errorf("Ends(%s) = %s, want %s", tt.at, got, want)
+		}
+	}
+}
+
+func TestQuietHoursTimezone(t *testing.T) {
+	// 22:00-07:00 in Jakarta (UTC+7) is 15:00-00:00 UTC
+	q, err := ParseQuietHours("22:00", "07:00", "WIB", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Active(time.Date(2026, 3, 1, 16, 0, 0, 0, time.UTC)) {
+		t.Error("16:00 UTC is 23:00 in Jakarta and should be quiet")
+	}
+	if q.Active(time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Error("03:00 UTC is 10:00 in Jakarta and should not be quiet")
+	}
+	if got := q.Ends(time.Date(2026, 3, 1, 16, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Ends = %s, want midnight UTC", got.UTC())
+	}
+}
+
+func TestQuietHoursSameDay(t *testing.T) {
+	q, err := ParseQuietHours("12:30", "14:00", "UTC", []string{"Telegram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC)
+	if !q.Active(at) || q.Active(at.Add(-time.Hour)) || q.Active(at.Add(time.Hour)) {
+		t.Error("window 12:30-14:00 misplaced")
+	}
+	if got := q.Ends(at); !got.Equal(time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("Ends = %s, want 14:00 the same day", got)
+	}
+	if !q.Applies("telegram") || q.Applies("slack") {
+		t.Error("window should only apply to telegram")
+	}
+}
+
+func TestParseQuietHoursInvalid(t *testing.T) {
+	for _, tt := range [][3]string{
+		{"25:00", "07:00", ""},
+		{"22:00", "7am", ""},
+		{"22:00", "22:00", ""},
+		{"22:00", "07:00", "Mars/Olympus"},
+	} {
+		if _, err := ParseQuietHours(tt[0], tt[1], tt[2], nil); err == nil {
+			t.Errorf("ParseQuietHours(%q, %q, %q) should fail", tt[0], tt[1], tt[2])
+		}
+	}
+}
+
+func TestNotifierHold(t *testing.T) {
+	n := NewNotifier(NotifierConfig{})
+	var held []string
+	n.SetHold(func(platform, target, message string) bool {
+		held = append(held, platform+":"+target+":"+message)
+		return platform == "telegram"
+	})
+
+	if err := n.Send(context.Background(), NotifyChannel{Type: "tg", Target: "42"}, "good night"); err != nil {
+		t.Errorf("held send = %v, want nil", err)
+	}
+	if err := n.Send(context.Background(), NotifyChannel{Type: "slack", Target: "C1"}, "hi"); err == nil {
+		t.Error("a send the hold passes on should go out (and fail without a token)")
+	}
+	if len(held) != 2 || held[0] != "telegram:42:good night" {
+		t.Errorf("hold saw %v", held)
+	}
+}
@@ -9,14 +9,18 @@ import (
 	"github.com/robfig/cron/v3"
 )
 
+// Generator produces the text an llm_digest job posts from its prompt.
+type Generator func(ctx context.Context, job *Job) (string, error)
+
 // Scheduler manages cron job execution
 type Scheduler struct {
-	mu       sync.RWMutex
-	cron     *cron.Cron
-	store    *JobStore
-	notifier *Notifier
-	entryIDs map[string]cron.EntryID // job ID -> cron entry ID
-	running  bool
+	mu        sync.RWMutex
+	cron      *cron.Cron
+	store     *JobStore
+	notifier  *Notifier
+	generator Generator               // nil: llm_digest jobs fail
+	entryIDs  map[string]cron.EntryID // job ID -> cron entry ID
+	running   bool
 }
 
 // NewScheduler creates a new scheduler
@@ -29,6 +33,13 @@ func NewScheduler(store *JobStore, notifier *Notifier) *Scheduler {
 	}
 }
 
+// SetGenerator sets how llm_digest jobs produce their message.
+func (s *Scheduler) SetGenerator(g Generator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generator = g
+}
+
 // Start begins the scheduler
 func (s *Scheduler) Start() error {
 	s.mu.Lock()
@@ -109,19 +120,54 @@ func (s *Scheduler) createJobFunc(jobID string) func() {
 		}
 
 		log.Printf("[CRON] Running job %s (%s)", job.ID, job.Name)
+		_, _ = s.run(context.Background(), job)
+	}
+}
 
-		// Send notifications to all channels
-		var lastErr error
-		for _, ch := range job.Channels {
-			if err := s.notifier.Send(context.Background(), ch, job.Message); err != nil {
-				log.Printf("[CRON] Failed to send to %s/%s: %v", ch.Type, ch.Target, err)
-				lastErr = err
-			}
+// run sends the job's message to all its channels, records the run and
+// returns the message.
+func (s *Scheduler) run(ctx context.Context, job *Job) (string, error) {
+	message, err := s.message(ctx, job)
+	if err != nil {
+		log.Printf("[CRON] Job %s produced no message: %v", job.ID, err)
+		_ = s.store.RecordRun(job.ID, err)
+		return "", err
+	}
+
+	// Send notifications to all channels
+	var lastErr error
+	for _, ch := range job.Channels {
+		if err := s.notifier.Send(ctx, ch, message); err != nil {
+			log.Printf("[CRON] Failed to send to %s/%s: %v", ch.Type, ch.Target, err)
+			lastErr = err
 		}
+	}
+
+	// Record the run
+	_ = s.store.RecordRun(job.ID, lastErr)
+	return message, lastErr
+}
 
-		// Record the run
-		_ = s.store.RecordRun(jobID, lastErr)
+// message returns the text to post for job: the literal message, or for
+// llm_digest jobs the generator's answer to it.
+func (s *Scheduler) message(ctx context.Context, job *Job) (string, error) {
+	if !job.IsDigest() {
+		return job.Message, nil
 	}
+	s.mu.RLock()
+	generate := s.generator
+	s.mu.RUnlock()
+	if generate == nil {
+		return "", fmt.Errorf("llm_digest needs the daemon's LLM")
+	}
+	text, err := generate(ctx, job)
+	if err != nil {
+		return "", fmt.Errorf("generate digest: %w", err)
+	}
+	if text == "" {
+		return "", fmt.Errorf("generate digest: empty response")
+	}
+	return text, nil
 }
 
 // AddJob creates and schedules a new job
@@ -228,26 +274,20 @@ func (s *Scheduler) DisableJob(id string) error {
 
 // RunNow executes a job immediately
 func (s *Scheduler) RunNow(id string) error {
+	_, err := s.RunJob(context.Background(), id)
+	return err
+}
+
+// RunJob executes a job immediately, enabled or not, and returns the
+// message it sent. A missing job is an ErrJobNotFound.
+func (s *Scheduler) RunJob(ctx context.Context, id string) (string, error) {
 	job, err := s.store.Get(id)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	log.Printf("[CRON] Manual run job %s (%s)", job.ID, job.Name)
-
-	// Send notifications
-	var lastErr error
-	for _, ch := range job.Channels {
-		if err := s.notifier.Send(context.Background(), ch, job.Message); err != nil {
-			log.Printf("[CRON] Failed to send to %s/%s: %v", ch.Type, ch.Target, err)
-			lastErr = err
-		}
-	}
-
-	// Record the run
-	_ = s.store.RecordRun(id, lastErr)
-
-	return lastErr
+	return s.run(ctx, job)
 }
 
 // GetJob retrieves a job
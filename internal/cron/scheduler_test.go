@@ -0,0 +1,64 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSchedulerDigestMessage(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	s := NewScheduler(store, NewNotifier(NotifierConfig{}))
+	ctx := context.Background()
+
+	literal := &Job{Message: "Good morning!"}
+	if msg, err := s.message(ctx, literal); err != nil || msg != "Good morning!" {
+		t.Errorf("literal job message = %q, %v", msg, err)
+	}
+
+	digest := &Job{Name: "digest", Schedule: "0 9 * * *", Kind: KindLLMDigest, Message: "Summarize today"}
+	if err := store.Create(digest); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Without a generator the run fails and is recorded
+	if err := s.RunNow(digest.ID); err == nil {
+		t.Error("Expected error running llm_digest without a generator")
+	}
+	if job, _ := store.Get(digest.ID); job.LastError == "" {
+		t.Error("Expected the failed run to be recorded")
+	}
+
+	var gotPrompt string
+	s.SetGenerator(func(_ context.Context, job *Job) (string, error) {
+		gotPrompt = job.Message
+		return "Here is your digest", nil
+	})
+	if msg, err := s.message(ctx, digest); err != nil || msg != "Here is your digest" {
+		t.Errorf("digest message = %q, %v", msg, err)
+	}
+	if gotPrompt != "Summarize today" {
+		t.Errorf("generator got prompt %q", gotPrompt)
+	}
+	if msg, _ := s.message(ctx, literal); msg != "Good morning!" {
+		t.Errorf("literal job should ignore the generator, got %q", msg)
+	}
+
+	s.SetGenerator(func(context.Context, *Job) (string, error) { return "", errors.New("provider down") })
+	if _, err := s.message(ctx, digest); err == nil {
+		t.Error("Expected generator error")
+	}
+}
+
+func TestJobStoreRejectsUnknownKind(t *testing.T) {
+	store, err := NewJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if err := store.Create(&Job{Name: "x", Schedule: "@daily", Kind: "bogus"}); err == nil {
+		t.Error("Expected error for unknown kind")
+	}
+}
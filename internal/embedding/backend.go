@@ -0,0 +1,32 @@
+package embedding
+
+import "context"
+
+// Vector store drivers for VectorStoreConfig.Driver.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPgvector = "pgvector"
+)
+
+// VectorBackend stores entries for a VectorStore and ranks them. The SQLite
+// backend scans embeddings in Go; pgvector ranks inside Postgres.
+type VectorBackend interface {
+	// Add inserts or replaces an entry. embedding may be nil when no
+	// embedding client is configured.
+	Add(id, content string, embedding []float32, metadata map[string]interface{}) error
+	// Get returns the entry with id, or nil, nil when there is none.
+	Get(id string) (*Entry, error)
+	Delete(id string) error
+	// SearchByVector returns up to limit entries by cosine similarity to
+	// query, most similar first. It stops early with ctx.Err() once ctx is
+	// done.
+	SearchByVector(ctx context.Context, query []float32, limit int) ([]SearchResult, error)
+	// KeywordSearch returns the IDs of up to limit entries matching any of
+	// the lowercase letter/digit terms, best match first.
+	KeywordSearch(terms []string, limit int) ([]string, error)
+	Count() (int, error)
+	// List returns entries newest first.
+	List(offset, limit int) ([]*Entry, error)
+	Clear() error
+	Close() error
+}
@@ -5,25 +5,18 @@ package embedding
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/kusandriadi/allm-go"
 
-	_ "github.com/mattn/go-sqlite3"
-
 	"github.com/kusa/magabot/internal/util"
 )
 
@@ -73,15 +66,25 @@ type Config struct {
 	BaseURL      string // Custom base URL for API
 	Dimensions   int    // Output dimensions (for models that support it)
 	Timeout      time.Duration
-	MaxBatchSize int // Max texts per batch request
+	Proxy        string // HTTP proxy URL; empty uses HTTPS_PROXY/NO_PROXY
+	MaxBatchSize int    // Max texts per batch request
 	Logger       *slog.Logger
+
+	// ContinueOnBatchError keeps embedding remaining batches when one fails.
+	// Embed then returns the partial results together with a *BatchError.
+	ContinueOnBatchError bool
+
+	RequestsPerMinute int // Client-side request rate limit (0 = unlimited)
+	MaxRetries        int // Retries on 429/5xx with exponential backoff (0 = no retry)
 }
 
 // Client generates embeddings using an API provider.
 type Client struct {
-	config Config
-	client *http.Client
-	logger *slog.Logger
+	config         Config
+	client         *http.Client
+	limiter        *tokenBucket
+	retryBaseDelay time.Duration
+	logger         *slog.Logger
 }
 
 // NewClient creates a new embedding client.
@@ -117,81 +120,31 @@ func NewClient(cfg Config) *Client {
 		}
 	}
 
+	client, err := util.NewProxyHTTPClient(cfg.Timeout, cfg.Proxy)
+	if err != nil {
+		// Fail closed: never bypass a proxy the operator asked for
+		cfg.Logger.Error("embedding proxy rejected; requests will fail", "error", err)
+		client = util.NewHTTPClient(cfg.Timeout)
+		client.Transport = &http.Transport{Proxy: func(*http.Request) (*url.URL, error) { return nil, err }}
+	}
+
 	return &Client{
-		config: cfg,
-		client: util.NewHTTPClient(cfg.Timeout),
-		logger: cfg.Logger,
+		config:         cfg,
+		client:         client,
+		limiter:        newTokenBucket(cfg.RequestsPerMinute),
+		retryBaseDelay: defaultRetryBaseDelay,
+		logger:         cfg.Logger,
 	}
 }
 
 // isAllowedURL validates that a URL is safe to connect to (SSRF prevention).
-// For local provider, allows localhost connections.
-// For cloud providers, blocks private/internal IP ranges.
+// The local provider may reach localhost and private networks; cloud
+// providers may not.
 func isAllowedURL(baseURL string, provider Provider) error {
-	parsed, err := url.Parse(baseURL)
-	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
-	}
-
-	// Must be HTTP or HTTPS
-	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return fmt.Errorf("unsupported URL scheme: %s", parsed.Scheme)
-	}
-
-	// For local provider, allow localhost
 	if provider == ProviderLocal {
-		return nil
-	}
-
-	// For cloud providers, block private networks
-	host := parsed.Hostname()
-
-	// Check for localhost variants
-	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
-		return fmt.Errorf("cloud provider URL cannot point to localhost")
-	}
-
-	// Resolve hostname and check for private IPs
-	ips, err := net.LookupIP(host)
-	if err == nil {
-		for _, ip := range ips {
-			if isPrivateIP(ip) {
-				return fmt.Errorf("cloud provider URL resolves to private IP: %s", ip)
-			}
-		}
-	}
-
-	return nil
-}
-
-// isPrivateIP checks if an IP address is in a private/reserved range.
-func isPrivateIP(ip net.IP) bool {
-	// Check for loopback
-	if ip.IsLoopback() {
-		return true
-	}
-
-	// Check for private ranges
-	privateRanges := []string{
-		"10.0.0.0/8",
-		"172.16.0.0/12",
-		"192.168.0.0/16",
-		"169.254.0.0/16", // Link-local
-		"fc00::/7",       // IPv6 private
-		"fe80::/10",      // IPv6 link-local
+		return util.ValidateLocalBaseURL(baseURL)
 	}
-
-	for _, cidr := range privateRanges {
-		_, network, err := net.ParseCIDR(cidr)
-		if err != nil {
-			continue
-		}
-		if network.Contains(ip) {
-			return true
-		}
-	}
-
-	return false
+	return util.ValidateBaseURL(baseURL)
 }
 
 // ValidateConfig validates the embedding client configuration.
@@ -203,13 +156,22 @@ func ValidateConfig(cfg Config) error {
 	}
 
 	// Validate API key is not empty for cloud providers
-	if cfg.Provider != ProviderLocal && strings.TrimSpace(cfg.APIKey) == "" {
+	if cfg.Provider != ProviderLocal && registeredEmbedder(cfg.Provider) == nil && strings.TrimSpace(cfg.APIKey) == "" {
 		return fmt.Errorf("API key required for provider: %s", cfg.Provider)
 	}
 
 	return nil
 }
 
+// inputType distinguishes stored documents from search queries for providers
+// that embed them differently (Voyage, Cohere).
+type inputType int
+
+const (
+	inputDocument inputType = iota
+	inputQuery
+)
+
 // Embedding represents a single embedding result.
 type Embedding struct {
 	Text       string    `json:"text"`
@@ -218,14 +180,41 @@ type Embedding struct {
 	TokenCount int       `json:"token_count,omitempty"`
 }
 
+// BatchError reports the batches that failed during Embed when
+// Config.ContinueOnBatchError is set. Failed holds the input indices whose
+// embeddings are missing so callers can retry just those texts.
+type BatchError struct {
+	Failed []int   // input indices that were not embedded
+	Errs   []error // one error per failed batch
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of the inputs failed to embed in %d batch(es): %v", len(e.Failed), len(e.Errs), errors.Join(e.Errs...))
+}
+
+// Unwrap exposes the per-batch errors to errors.Is / errors.As.
+func (e *BatchError) Unwrap() []error {
+	return e.Errs
+}
+
 // Embed generates embeddings for a list of texts.
+//
+// By default the first failing batch aborts the call. With
+// Config.ContinueOnBatchError, the returned slice is index-aligned with texts
+// (failed entries have a nil Vector) and the error is a *BatchError.
 func (c *Client) Embed(ctx context.Context, texts []string) ([]Embedding, error) {
+	return c.embed(ctx, texts, inputDocument)
+}
+
+// embed batches texts and embeds them with the given input type.
+func (c *Client) embed(ctx context.Context, texts []string, kind inputType) ([]Embedding, error) {
 	if len(texts) == 0 {
 		return nil, nil
 	}
 
 	// Batch if needed
 	results := make([]Embedding, 0, len(texts))
+	var batchErr *BatchError
 
 	for i := 0; i < len(texts); i += c.config.MaxBatchSize {
 		end := i + c.config.MaxBatchSize
@@ -234,20 +223,50 @@ func (c *Client) Embed(ctx context.Context, texts []string) ([]Embedding, error)
 		}
 		batch := texts[i:end]
 
-		embeddings, err := c.embedBatch(ctx, batch)
+		embeddings, err := c.embedBatch(ctx, batch, kind)
+		if err == nil && c.config.ContinueOnBatchError && len(embeddings) != len(batch) {
+			err = fmt.Errorf("got %d embeddings for %d inputs", len(embeddings), len(batch))
+		}
 		if err != nil {
-			return nil, fmt.Errorf("batch %d: %w", i/c.config.MaxBatchSize, err)
+			err = fmt.Errorf("batch %d: %w", i/c.config.MaxBatchSize, err)
+			if !c.config.ContinueOnBatchError {
+				return nil, err
+			}
+			if batchErr == nil {
+				batchErr = &BatchError{}
+			}
+			batchErr.Errs = append(batchErr.Errs, err)
+			for j := i; j < end; j++ {
+				batchErr.Failed = append(batchErr.Failed, j)
+				results = append(results, Embedding{Text: texts[j]})
+			}
+			c.logger.Warn("embedding batch failed, continuing", "batch", i/c.config.MaxBatchSize, "size", len(batch), "error", err)
+			continue
 		}
 
 		results = append(results, embeddings...)
 	}
 
+	if batchErr != nil {
+		return results, batchErr
+	}
 	return results, nil
 }
 
-// EmbedOne generates an embedding for a single text.
+// EmbedOne generates a document embedding for a single text.
 func (c *Client) EmbedOne(ctx context.Context, text string) (*Embedding, error) {
-	results, err := c.Embed(ctx, []string{text})
+	return c.embedOne(ctx, text, inputDocument)
+}
+
+// EmbedQuery generates an embedding for a search query. Voyage and Cohere
+// embed queries differently from documents; other providers ignore the
+// distinction.
+func (c *Client) EmbedQuery(ctx context.Context, query string) (*Embedding, error) {
+	return c.embedOne(ctx, query, inputQuery)
+}
+
+func (c *Client) embedOne(ctx context.Context, text string, kind inputType) (*Embedding, error) {
+	results, err := c.embed(ctx, []string{text}, kind)
 	if err != nil {
 		return nil, err
 	}
@@ -257,18 +276,55 @@ func (c *Client) EmbedOne(ctx context.Context, text string) (*Embedding, error)
 	return &results[0], nil
 }
 
-// embedBatch performs the actual API call for a batch of texts.
-func (c *Client) embedBatch(ctx context.Context, texts []string) ([]Embedding, error) {
+// embedBatch performs the API call for a batch of texts, applying the
+// client's rate limit and retrying 429/5xx responses with backoff.
+func (c *Client) embedBatch(ctx context.Context, texts []string, kind inputType) ([]Embedding, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		embeddings, err := c.callProvider(ctx, texts, kind)
+		if err == nil {
+			return embeddings, nil
+		}
+
+		var statusErr *apiStatusError
+		if !errors.As(err, &statusErr) {
+			return nil, err
+		}
+		if attempt >= c.config.MaxRetries {
+			if statusErr.StatusCode == http.StatusTooManyRequests {
+				return nil, fmt.Errorf("%w: %v", ErrRateLimited, err)
+			}
+			return nil, err
+		}
+
+		delay := c.retryDelay(attempt, statusErr.RetryAfter)
+		c.logger.Warn("embedding request failed, retrying",
+			"provider", c.config.Provider, "status", statusErr.StatusCode,
+			"attempt", attempt+1, "delay", delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// callProvider dispatches a single request to the configured provider.
+func (c *Client) callProvider(ctx context.Context, texts []string, kind inputType) ([]Embedding, error) {
 	switch c.config.Provider {
 	case ProviderOpenAI:
 		return c.embedOpenAI(ctx, texts)
 	case ProviderVoyage:
-		return c.embedVoyage(ctx, texts)
+		return c.embedVoyage(ctx, texts, kind)
 	case ProviderCohere:
-		return c.embedCohere(ctx, texts)
+		return c.embedCohere(ctx, texts, kind)
 	case ProviderLocal:
 		return c.embedLocal(ctx, texts)
 	default:
+		if e := registeredEmbedder(c.config.Provider); e != nil {
+			return c.embedRegistered(ctx, e, texts, kind)
+		}
 		return nil, fmt.Errorf("unsupported provider: %s", c.config.Provider)
 	}
 }
@@ -302,7 +358,8 @@ type openAIEmbedding struct {
 
 // doEmbedRequest marshals reqBody, POSTs to baseURL+path, reads the response
 // with a size limit, and unmarshals into result. Auth header is set when APIKey
-// is configured.
+// is configured. 429 and 5xx responses are returned as *apiStatusError so
+// embedBatch can retry them.
 func (c *Client) doEmbedRequest(ctx context.Context, path string, reqBody interface{}, result interface{}) error {
 	data, err := json.Marshal(reqBody)
 	if err != nil {
@@ -330,6 +387,14 @@ func (c *Client) doEmbedRequest(ctx context.Context, path string, reqBody interf
 		return fmt.Errorf("read response: %w", err)
 	}
 
+	if isRetryableStatus(resp.StatusCode) {
+		return &apiStatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Body:       util.SanitizeErrorMessage(util.Truncate(string(body), 200)),
+		}
+	}
+
 	if err := json.Unmarshal(body, result); err != nil {
 		return fmt.Errorf("parse response: %w", err)
 	}
@@ -399,12 +464,15 @@ type voyageEmbedding struct {
 	Embedding []float32 `json:"embedding"`
 }
 
-func (c *Client) embedVoyage(ctx context.Context, texts []string) ([]Embedding, error) {
+func (c *Client) embedVoyage(ctx context.Context, texts []string, kind inputType) ([]Embedding, error) {
 	reqBody := voyageRequest{
 		Input:     texts,
 		Model:     c.config.Model,
 		InputType: "document",
 	}
+	if kind == inputQuery {
+		reqBody.InputType = "query"
+	}
 
 	var result voyageResponse
 	if err := c.doEmbedRequest(ctx, "/embeddings", reqBody, &result); err != nil {
@@ -446,12 +514,15 @@ type cohereResponse struct {
 	} `json:"error,omitempty"`
 }
 
-func (c *Client) embedCohere(ctx context.Context, texts []string) ([]Embedding, error) {
+func (c *Client) embedCohere(ctx context.Context, texts []string, kind inputType) ([]Embedding, error) {
 	reqBody := cohereRequest{
 		Texts:     texts,
 		Model:     c.config.Model,
 		InputType: "search_document",
 	}
+	if kind == inputQuery {
+		reqBody.InputType = "search_query"
+	}
 
 	var result cohereResponse
 	if err := c.doEmbedRequest(ctx, "/embed", reqBody, &result); err != nil {
@@ -535,24 +606,35 @@ func DotProduct(a, b []float32) float32 {
 
 // VectorStore provides persistent storage and retrieval of embeddings.
 type VectorStore struct {
-	mu         sync.RWMutex
-	db         *sql.DB
-	client     *Client
-	tableName  string
-	dimensions int
-	logger     *slog.Logger
+	backend VectorBackend
+	client  *Client
+	logger  *slog.Logger
+
+	keywordWeight float64 // HybridSearch keyword vs vector weight
 }
 
 // VectorStoreConfig holds vector store configuration.
 type VectorStoreConfig struct {
-	DBPath     string
+	// Driver selects the backend: DriverSQLite (default) stores entries in
+	// the DBPath file, DriverPgvector in the Postgres database at DSN.
+	Driver string
+	DBPath string
+	DSN    string
+
 	TableName  string
 	Client     *Client
-	Dimensions int
+	Dimensions int // vector size for pgvector columns (default: the client's model dimensions)
 	Logger     *slog.Logger
+
+	// KeywordWeight is HybridSearch's weight of keyword over vector ranks,
+	// in [0, 1] (default: DefaultKeywordWeight).
+	KeywordWeight *float64
+
+	// Backend, when set, is used instead of opening one from Driver.
+	Backend VectorBackend
 }
 
-// NewVectorStore creates a new vector store backed by SQLite.
+// NewVectorStore creates a new vector store on the configured backend.
 func NewVectorStore(cfg VectorStoreConfig) (*VectorStore, error) {
 	if cfg.TableName == "" {
 		cfg.TableName = "embeddings"
@@ -562,55 +644,42 @@ func NewVectorStore(cfg VectorStoreConfig) (*VectorStore, error) {
 	}
 	if cfg.Dimensions <= 0 {
 		cfg.Dimensions = 1536
+		if cfg.Client != nil {
+			cfg.Dimensions = cfg.Client.config.Dimensions
+		}
 	}
 	if cfg.Logger == nil {
 		cfg.Logger = slog.Default()
 	}
 
-	// Ensure directory exists
-	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0700); err != nil {
-		return nil, fmt.Errorf("create db dir: %w", err)
-	}
-
-	db, err := sql.Open("sqlite3", cfg.DBPath+"?_journal_mode=WAL&_busy_timeout=5000")
-	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+	backend := cfg.Backend
+	if backend == nil {
+		var err error
+		switch cfg.Driver {
+		case "", DriverSQLite:
+			backend, err = newSQLiteBackend(cfg.DBPath, cfg.TableName, cfg.Logger)
+		case DriverPgvector:
+			backend, err = newPgvectorBackend(cfg.DSN, cfg.TableName, cfg.Dimensions)
+		default:
+			err = fmt.Errorf("unknown vector store driver %q (use %s or %s)", cfg.Driver, DriverSQLite, DriverPgvector)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	store := &VectorStore{
-		db:         db,
-		client:     cfg.Client,
-		tableName:  cfg.TableName,
-		dimensions: cfg.Dimensions,
-		logger:     cfg.Logger,
+		backend:       backend,
+		client:        cfg.Client,
+		logger:        cfg.Logger,
+		keywordWeight: DefaultKeywordWeight,
 	}
-
-	if err := store.initSchema(); err != nil {
-		_ = db.Close()
-		return nil, fmt.Errorf("init schema: %w", err)
+	if cfg.KeywordWeight != nil {
+		store.keywordWeight = *cfg.KeywordWeight
 	}
-
 	return store, nil
 }
 
-// initSchema creates the database tables if they don't exist.
-func (s *VectorStore) initSchema() error {
-	query := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id TEXT PRIMARY KEY,
-			content TEXT NOT NULL,
-			embedding BLOB NOT NULL,
-			metadata TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-		CREATE INDEX IF NOT EXISTS idx_%s_created ON %s(created_at);
-	`, s.tableName, s.tableName, s.tableName)
-
-	_, err := s.db.Exec(query)
-	return err
-}
-
 // Entry represents a stored embedding entry.
 type Entry struct {
 	ID        string                 `json:"id"`
@@ -623,9 +692,6 @@ type Entry struct {
 
 // Add stores a new entry, generating the embedding if client is available.
 func (s *VectorStore) Add(ctx context.Context, id, content string, metadata map[string]interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	// Generate embedding
 	var embedding []float32
 	if s.client != nil {
@@ -636,95 +702,45 @@ func (s *VectorStore) Add(ctx context.Context, id, content string, metadata map[
 		embedding = emb.Vector
 	}
 
-	return s.addWithEmbedding(id, content, embedding, metadata)
+	return s.backend.Add(id, content, embedding, metadata)
 }
 
 // AddWithEmbedding stores an entry with a pre-computed embedding.
 func (s *VectorStore) AddWithEmbedding(id, content string, embedding []float32, metadata map[string]interface{}) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.addWithEmbedding(id, content, embedding, metadata)
-}
-
-func (s *VectorStore) addWithEmbedding(id, content string, embedding []float32, metadata map[string]interface{}) error {
-	embData, err := json.Marshal(embedding)
-	if err != nil {
-		return fmt.Errorf("marshal embedding: %w", err)
-	}
-
-	metaData, err := json.Marshal(metadata)
-	if err != nil {
-		return fmt.Errorf("marshal metadata: %w", err)
-	}
-
-	query := fmt.Sprintf(`
-		INSERT OR REPLACE INTO %s (id, content, embedding, metadata, updated_at)
-		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`, s.tableName)
-
-	_, err = s.db.Exec(query, id, content, embData, string(metaData))
-	return err
+	return s.backend.Add(id, content, embedding, metadata)
 }
 
 // decodeEntry unmarshals raw embedding and metadata JSON into an Entry.
 // Metadata errors are logged but not fatal.
-func (s *VectorStore) decodeEntry(entry *Entry, embData []byte, metaData string) error {
-	if err := json.Unmarshal(embData, &entry.Embedding); err != nil {
-		return fmt.Errorf("unmarshal embedding: %w", err)
+func decodeEntry(entry *Entry, embData []byte, metaData string, logger *slog.Logger) error {
+	if len(embData) > 0 {
+		if err := json.Unmarshal(embData, &entry.Embedding); err != nil {
+			return fmt.Errorf("unmarshal embedding: %w", err)
+		}
 	}
 	if metaData != "" {
 		if err := json.Unmarshal([]byte(metaData), &entry.Metadata); err != nil {
-			s.logger.Warn("failed to unmarshal metadata", "id", entry.ID, "error", err)
+			logger.Warn("failed to unmarshal metadata", "id", entry.ID, "error", err)
 		}
 	}
 	return nil
 }
 
-// Get retrieves an entry by ID.
+// Get retrieves an entry by ID. It returns nil, nil when there is none.
 func (s *VectorStore) Get(id string) (*Entry, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	query := fmt.Sprintf(`
-		SELECT id, content, embedding, metadata, created_at, updated_at
-		FROM %s WHERE id = ?
-	`, s.tableName)
-
-	var entry Entry
-	var embData []byte
-	var metaData string
-	err := s.db.QueryRow(query, id).Scan(
-		&entry.ID, &entry.Content, &embData, &metaData,
-		&entry.CreatedAt, &entry.UpdatedAt,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	if err := s.decodeEntry(&entry, embData, metaData); err != nil {
-		return nil, err
-	}
-
-	return &entry, nil
+	return s.backend.Get(id)
 }
 
 // Delete removes an entry by ID.
 func (s *VectorStore) Delete(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	query := fmt.Sprintf("DELETE FROM %s WHERE id = ?", s.tableName)
-	_, err := s.db.Exec(query, id)
-	return err
+	return s.backend.Delete(id)
 }
 
 // SearchResult represents a search result with similarity score.
 type SearchResult struct {
 	Entry      *Entry  `json:"entry"`
 	Similarity float32 `json:"similarity"`
+	Score      float32 `json:"score,omitempty"` // fused rank score (HybridSearch only)
 }
 
 // Search finds similar entries to the query text.
@@ -734,143 +750,92 @@ func (s *VectorStore) Search(ctx context.Context, query string, limit int) ([]Se
 	}
 
 	// Generate query embedding
-	emb, err := s.client.EmbedOne(ctx, query)
+	emb, err := s.client.EmbedQuery(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("generate query embedding: %w", err)
 	}
 
-	return s.SearchByVector(emb.Vector, limit)
+	return s.SearchByVector(ctx, emb.Vector, limit)
 }
 
-// MaxSearchEntries is the maximum number of entries to scan during search.
-// This provides a safeguard against OOM for large datasets.
-const MaxSearchEntries = 10000
-
-// SearchByVector finds similar entries to a query vector.
-func (s *VectorStore) SearchByVector(queryVector []float32, limit int) ([]SearchResult, error) {
+// SearchByVector finds similar entries to a query vector, most similar first.
+func (s *VectorStore) SearchByVector(ctx context.Context, queryVector []float32, limit int) ([]SearchResult, error) {
 	if limit <= 0 {
 		limit = 10
 	}
+	return s.backend.SearchByVector(ctx, queryVector, limit)
+}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// SearchOptions tunes SearchWithOptions.
+type SearchOptions struct {
+	Limit int // max results (default 10)
 
-	// Load entries with limit to prevent OOM
-	// Note: For large datasets, consider using a specialized vector database
-	query := fmt.Sprintf(`
-		SELECT id, content, embedding, metadata, created_at, updated_at
-		FROM %s ORDER BY created_at DESC LIMIT %d
-	`, s.tableName, MaxSearchEntries)
+	// MinSimilarity drops results whose cosine similarity is below it;
+	// 0 keeps every result.
+	MinSimilarity float32
 
-	rows, err := s.db.Query(query)
+	// MinResults keeps at least this many of the best results even when
+	// they fall below MinSimilarity.
+	MinResults int
+}
+
+// SearchWithOptions is Search with a similarity threshold. It also returns
+// how many results the threshold dropped.
+func (s *VectorStore) SearchWithOptions(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, int, error) {
+	if s.client == nil {
+		return nil, 0, fmt.Errorf("no embedding client configured")
+	}
+	emb, err := s.client.EmbedQuery(ctx, query)
 	if err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("generate query embedding: %w", err)
 	}
-	defer func() { _ = rows.Close() }()
-
-	var results []SearchResult
-
-	for rows.Next() {
-		var entry Entry
-		var embData []byte
-		var metaData string
-
-		err := rows.Scan(&entry.ID, &entry.Content, &embData, &metaData,
-			&entry.CreatedAt, &entry.UpdatedAt)
-		if err != nil {
-			continue
-		}
-
-		if err := s.decodeEntry(&entry, embData, metaData); err != nil {
-			continue
-		}
-
-		similarity := float32(allm.CosineSimilarity(queryVector, entry.Embedding))
+	return s.SearchByVectorWithOptions(ctx, emb.Vector, opts)
+}
 
-		results = append(results, SearchResult{
-			Entry:      &entry,
-			Similarity: similarity,
-		})
+// SearchByVectorWithOptions is SearchByVector with a similarity threshold.
+// It also returns how many results the threshold dropped.
+func (s *VectorStore) SearchByVectorWithOptions(ctx context.Context, queryVector []float32, opts SearchOptions) ([]SearchResult, int, error) {
+	results, err := s.SearchByVector(ctx, queryVector, opts.Limit)
+	if err != nil {
+		return nil, 0, err
 	}
+	kept, dropped := applyThreshold(results, opts)
+	return kept, dropped, nil
+}
 
-	// Sort by similarity (descending)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Similarity > results[j].Similarity
-	})
-
-	// Limit results
-	if len(results) > limit {
-		results = results[:limit]
+// applyThreshold cuts results, sorted most similar first, at the first one
+// below opts.MinSimilarity while keeping at least opts.MinResults.
+func applyThreshold(results []SearchResult, opts SearchOptions) ([]SearchResult, int) {
+	keep := len(results)
+	for i, r := range results {
+		if r.Similarity < opts.MinSimilarity {
+			keep = i
+			break
+		}
 	}
-
-	return results, nil
+	keep = max(keep, min(opts.MinResults, len(results)))
+	return results[:keep], len(results) - keep
 }
 
 // Count returns the number of stored entries.
 func (s *VectorStore) Count() (int, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", s.tableName)
-	var count int
-	err := s.db.QueryRow(query).Scan(&count)
-	return count, err
+	return s.backend.Count()
 }
 
 // Clear removes all entries.
 func (s *VectorStore) Clear() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	query := fmt.Sprintf("DELETE FROM %s", s.tableName)
-	_, err := s.db.Exec(query)
-	return err
+	return s.backend.Clear()
 }
 
 // Close closes the database connection.
 func (s *VectorStore) Close() error {
-	return s.db.Close()
+	return s.backend.Close()
 }
 
-// List returns all entries (paginated).
+// List returns all entries (paginated), newest first.
 func (s *VectorStore) List(offset, limit int) ([]*Entry, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
 	if limit <= 0 {
 		limit = 100
 	}
-
-	query := fmt.Sprintf(`
-		SELECT id, content, embedding, metadata, created_at, updated_at
-		FROM %s ORDER BY created_at DESC LIMIT ? OFFSET ?
-	`, s.tableName)
-
-	rows, err := s.db.Query(query, limit, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = rows.Close() }()
-
-	var entries []*Entry
-
-	for rows.Next() {
-		var entry Entry
-		var embData []byte
-		var metaData string
-
-		err := rows.Scan(&entry.ID, &entry.Content, &embData, &metaData,
-			&entry.CreatedAt, &entry.UpdatedAt)
-		if err != nil {
-			continue
-		}
-
-		if err := s.decodeEntry(&entry, embData, metaData); err != nil {
-			continue
-		}
-
-		entries = append(entries, &entry)
-	}
-
-	return entries, nil
+	return s.backend.List(offset, limit)
 }
@@ -2,9 +2,20 @@ package embedding
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCosineSimilarity(t *testing.T) {
@@ -178,7 +189,7 @@ func TestVectorStore(t *testing.T) {
 
 	// Test SearchByVector
 	queryVector := []float32{1, 0, 0}
-	results, err := store.SearchByVector(queryVector, 2)
+	results, err := store.SearchByVector(context.Background(), queryVector, 2)
 	if err != nil {
 		t.Fatalf("failed to search: %v", err)
 	}
@@ -447,7 +458,7 @@ func TestSearchByVector_EmptyStore(t *testing.T) {
 	}
 	defer func() { _ = store.Close() }()
 
-	results, err := store.SearchByVector([]float32{1, 0, 0}, 10)
+	results, err := store.SearchByVector(context.Background(), []float32{1, 0, 0}, 10)
 	if err != nil {
 		t.Fatalf("expected no error on empty store, got: %v", err)
 	}
@@ -456,6 +467,70 @@ func TestSearchByVector_EmptyStore(t *testing.T) {
 	}
 }
 
+func TestSearchByVector_Cancelled(t *testing.T) {
+	store, err := NewVectorStore(VectorStoreConfig{DBPath: filepath.Join(t.TempDir(), "cancel.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+	for i := 0; i < 2*searchCancelCheck; i++ {
+		if err := store.backend.Add(fmt.Sprintf("e%d", i), "content", []float32{1, 0, 0}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.SearchByVector(ctx, []float32{1, 0, 0}, 10); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SearchByVector with cancelled context: err = %v, want context.Canceled", err)
+	}
+
+	results, err := store.SearchByVector(context.Background(), []float32{1, 0, 0}, 10)
+	if err != nil || len(results) != 10 {
+		t.Fatalf("SearchByVector = %d results, %v; want 10", len(results), err)
+	}
+}
+
+func TestSearchByVectorWithOptions(t *testing.T) {
+	store, err := NewVectorStore(VectorStoreConfig{DBPath: filepath.Join(t.TempDir(), "threshold.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+
+	_ = store.AddWithEmbedding("close", "close", []float32{1, 0, 0}, nil)
+	_ = store.AddWithEmbedding("near", "near", []float32{0.8, 0.6, 0}, nil)
+	_ = store.AddWithEmbedding("far", "far", []float32{0, 0, 1}, nil)
+	query := []float32{1, 0, 0}
+
+	tests := []struct {
+		name        string
+		opts        SearchOptions
+		wantIDs     []string
+		wantDropped int
+	}{
+		{"no threshold", SearchOptions{}, []string{"close", "near", "far"}, 0},
+		{"threshold", SearchOptions{MinSimilarity: 0.5}, []string{"close", "near"}, 1},
+		{"strict threshold", SearchOptions{MinSimilarity: 0.99}, []string{"close"}, 2},
+		{"min results", SearchOptions{MinSimilarity: 0.99, MinResults: 2}, []string{"close", "near"}, 1},
+		{"min results over total", SearchOptions{MinSimilarity: 2, MinResults: 5}, []string{"close", "near", "far"}, 0},
+		{"limit", SearchOptions{Limit: 1, MinSimilarity: 0.5}, []string{"close"}, 0},
+	}
+	for _, tt := range tests {
+		results, dropped, err := store.SearchByVectorWithOptions(context.Background(), query, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.Entry.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.wantIDs) || dropped != tt.wantDropped {
+			t.Errorf("%s: got %v (dropped %d), want %v (dropped %d)", tt.name, ids, dropped, tt.wantIDs, tt.wantDropped)
+		}
+	}
+}
+
 func TestAddAndSearch_SortOrder(t *testing.T) {
 	tmpDir, _ := os.MkdirTemp("", "embedding-test-*")
 	defer func() { _ = os.RemoveAll(tmpDir) }()
@@ -484,7 +559,7 @@ func TestAddAndSearch_SortOrder(t *testing.T) {
 		}
 	}
 
-	results, err := store.SearchByVector([]float32{1, 0, 0}, 10)
+	results, err := store.SearchByVector(context.Background(), []float32{1, 0, 0}, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -530,3 +605,519 @@ func TestCosineSimilarity_MismatchedDimensions(t *testing.T) {
 		t.Errorf("expected 0 for mismatched dimensions, got %f", result)
 	}
 }
+
+// newLocalEmbedServer returns a local-provider embedding server that fails any
+// batch containing the text "bad".
+func newLocalEmbedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req localRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		resp := localResponse{}
+		for _, text := range req.Texts {
+			if text == "bad" {
+				resp = localResponse{Error: "cannot embed"}
+				break
+			}
+			resp.Embeddings = append(resp.Embeddings, []float32{float32(len(text)), 0, 0})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEmbed_FailFastByDefault(t *testing.T) {
+	srv := newLocalEmbedServer(t)
+	client := NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL, MaxBatchSize: 2})
+
+	results, err := client.Embed(context.Background(), []string{"a", "bb", "bad", "cccc"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if results != nil {
+		t.Errorf("fail-fast should discard results, got %d", len(results))
+	}
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		t.Error("fail-fast should not return a BatchError")
+	}
+}
+
+func TestEmbed_ContinueOnBatchError(t *testing.T) {
+	srv := newLocalEmbedServer(t)
+	client := NewClient(Config{
+		Provider:             ProviderLocal,
+		BaseURL:              srv.URL,
+		MaxBatchSize:         2,
+		ContinueOnBatchError: true,
+	})
+
+	texts := []string{"a", "bb", "bad", "cccc", "ddddd"}
+	results, err := client.Embed(context.Background(), texts)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected *BatchError, got %v", err)
+	}
+	if len(batchErr.Errs) != 1 {
+		t.Errorf("expected 1 failed batch, got %d", len(batchErr.Errs))
+	}
+	if want := []int{2, 3}; !reflect.DeepEqual(batchErr.Failed, want) {
+		t.Errorf("Failed = %v, want %v", batchErr.Failed, want)
+	}
+
+	if len(results) != len(texts) {
+		t.Fatalf("results should be index-aligned with inputs: got %d, want %d", len(results), len(texts))
+	}
+	for i, r := range results {
+		if r.Text != texts[i] {
+			t.Errorf("results[%d].Text = %q, want %q", i, r.Text, texts[i])
+		}
+		failed := i == 2 || i == 3
+		if failed && r.Vector != nil {
+			t.Errorf("results[%d] should have no vector", i)
+		}
+		if !failed && r.Vector == nil {
+			t.Errorf("results[%d] should have a vector", i)
+		}
+	}
+}
+
+func TestEmbed_RetriesOn429(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(localResponse{Embeddings: [][]float32{{1, 2, 3}}})
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL, MaxRetries: 3})
+	client.retryBaseDelay = time.Millisecond
+
+	emb, err := client.EmbedOne(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("EmbedOne failed after retries: %v", err)
+	}
+	if len(emb.Vector) != 3 {
+		t.Errorf("unexpected vector: %v", emb.Vector)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestEmbed_PersistentRateLimit(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL, MaxRetries: 2})
+	client.retryBaseDelay = time.Millisecond
+
+	_, err := client.EmbedOne(context.Background(), "hello")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 1 call + 2 retries, got %d", calls)
+	}
+}
+
+func TestEmbed_NoRetryOnClientError(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"bad input"}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL, MaxRetries: 3})
+	client.retryBaseDelay = time.Millisecond
+
+	if _, err := client.EmbedOne(context.Background(), "hello"); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("4xx should not be retried, got %d calls", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"garbage", 0},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{now.Add(-10 * time.Second).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	if newTokenBucket(0) != nil {
+		t.Error("zero rate should disable limiting")
+	}
+
+	b := newTokenBucket(600) // 10/s, burst 10
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("11th request should wait for a refill, elapsed %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := newTokenBucket(1)
+	_ = slow.wait(context.Background()) // consume the burst
+	if err := slow.wait(ctx); err == nil {
+		t.Error("wait should return ctx error when cancelled")
+	}
+}
+
+func TestVectorStore_InputTypePerPath(t *testing.T) {
+	tests := []struct {
+		provider  Provider
+		wantAdd   string
+		wantQuery string
+	}{
+		{ProviderVoyage, "document", "query"},
+		{ProviderCohere, "search_document", "search_query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.provider), func(t *testing.T) {
+			var got []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					InputType string `json:"input_type"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Errorf("decode request: %v", err)
+				}
+				got = append(got, req.InputType)
+				vec := []float32{1, 0, 0}
+				if tt.provider == ProviderVoyage {
+					_ = json.NewEncoder(w).Encode(voyageResponse{Data: []voyageEmbedding{{Embedding: vec}}})
+				} else {
+					_ = json.NewEncoder(w).Encode(cohereResponse{Embeddings: [][]float32{vec}})
+				}
+			}))
+			defer srv.Close()
+
+			client := NewClient(Config{Provider: tt.provider, APIKey: "test", BaseURL: srv.URL})
+			store, err := NewVectorStore(VectorStoreConfig{
+				DBPath:     t.TempDir() + "/vectors.db",
+				Client:     client,
+				Dimensions: 3,
+			})
+			if err != nil {
+				t.Fatalf("NewVectorStore: %v", err)
+			}
+			defer func() { _ = store.Close() }()
+
+			ctx := context.Background()
+			if err := store.Add(ctx, "doc1", "hello world", nil); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			if _, err := store.Search(ctx, "hello", 5); err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+
+			want := []string{tt.wantAdd, tt.wantQuery}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("input_type sent = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// newHybridStore returns a store whose query embeddings point along the
+// first axis; "ord-12345" is the only entry far from it.
+func newHybridStore(t *testing.T) *VectorStore {
+	t.Helper()
+	srv := newLocalEmbedServer(t)
+	store, err := NewVectorStore(VectorStoreConfig{
+		DBPath:    filepath.Join(t.TempDir(), "hybrid.db"),
+		TableName: "hybrid",
+		Client:    NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL}),
+	})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	entries := []struct {
+		id, content string
+		vec         []float32
+	}{
+		{"a", "Weekly status meeting notes", []float32{1, 0, 0}},
+		{"b", "Lunch plans for Friday", []float32{0.9, 0.1, 0}},
+		{"c", "Order ORD-12345 shipped to Jakarta", []float32{0, 0, 1}},
+		{"d", "Reminder to renew the passport", []float32{0.8, 0.2, 0}},
+	}
+	for _, e := range entries {
+		if err := store.AddWithEmbedding(e.id, e.content, e.vec, nil); err != nil {
+			t.Fatalf("add %s: %v", e.id, err)
+		}
+	}
+	return store
+}
+
+func TestHybridSearch_KeywordMatchSurfaces(t *testing.T) {
+	for _, fts := range []bool{false, true} {
+		store := newHybridStore(t)
+		backend := store.backend.(*sqliteBackend)
+		if fts && !backend.fts {
+			continue // sqlite built without fts5
+		}
+		backend.fts = fts
+
+		// Pure vector search ranks the exact match last
+		vector, err := store.Search(context.Background(), "ORD-12345", 4)
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		if vector[len(vector)-1].Entry.ID != "c" {
+			t.Fatalf("expected c last by vector, got %s", vector[len(vector)-1].Entry.ID)
+		}
+
+		results, err := store.HybridSearch(context.Background(), "ORD-12345", 2)
+		if err != nil {
+			t.Fatalf("hybrid search (fts=%v): %v", fts, err)
+		}
+		if len(results) == 0 || results[0].Entry.ID != "c" {
+			t.Errorf("fts=%v: expected keyword match first, got %+v", fts, results)
+		}
+		if results[0].Similarity > 0.1 {
+			t.Errorf("expected the poor vector score to be reported, got %f", results[0].Similarity)
+		}
+
+		// Weight 0 is pure vector ranking
+		results, err = store.HybridSearchWeighted(context.Background(), "ORD-12345", 4, 0)
+		if err != nil {
+			t.Fatalf("hybrid search: %v", err)
+		}
+		if results[0].Entry.ID != "a" || results[3].Entry.ID != "c" {
+			t.Errorf("fts=%v: weight 0 should follow vector order, got %s..%s", fts, results[0].Entry.ID, results[3].Entry.ID)
+		}
+	}
+}
+
+func TestHybridSearch_KeywordOnlyWithoutClient(t *testing.T) {
+	store, err := NewVectorStore(VectorStoreConfig{DBPath: filepath.Join(t.TempDir(), "kw.db")})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	_ = store.AddWithEmbedding("1", "passport renewal appointment", nil, nil)
+	_ = store.AddWithEmbedding("2", "passport passport photo requirements", nil, nil)
+	_ = store.AddWithEmbedding("3", "grocery list", nil, nil)
+
+	results, err := store.HybridSearch(context.Background(), "passport", 10)
+	if err != nil {
+		t.Fatalf("hybrid search: %v", err)
+	}
+	if len(results) != 2 || results[0].Entry.ID != "2" {
+		t.Errorf("expected 2 keyword hits with the higher term frequency first, got %+v", results)
+	}
+
+	// Deleted entries leave the keyword index too
+	if err := store.Delete("2"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	results, _ = store.HybridSearch(context.Background(), "passport", 10)
+	if len(results) != 1 || results[0].Entry.ID != "1" {
+		t.Errorf("expected only entry 1 after delete, got %+v", results)
+	}
+}
+
+func TestNewVectorStore_Driver(t *testing.T) {
+	if _, err := NewVectorStore(VectorStoreConfig{Driver: "mongo"}); err == nil {
+		t.Error("expected error for unknown driver")
+	}
+	if _, err := NewVectorStore(VectorStoreConfig{Driver: DriverPgvector}); err == nil {
+		t.Error("expected error for pgvector without a DSN")
+	}
+
+	// A custom backend is used as is
+	backend, err := newSQLiteBackend(filepath.Join(t.TempDir(), "custom.db"), "custom", slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewVectorStore(VectorStoreConfig{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewVectorStore with backend: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	_ = store.AddWithEmbedding("1", "hello", []float32{1, 0}, nil)
+	if n, _ := backend.Count(); n != 1 {
+		t.Errorf("backend count = %d, want 1", n)
+	}
+}
+
+func TestValidateConfig_BaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"cloud public", Config{Provider: ProviderOpenAI, APIKey: "k", BaseURL: "https://api.openai.com/v1"}, false},
+		{"cloud localhost", Config{Provider: ProviderOpenAI, APIKey: "k", BaseURL: "http://localhost:8080/v1"}, true},
+		{"cloud private ip", Config{Provider: ProviderVoyage, APIKey: "k", BaseURL: "http://192.168.1.5/v1"}, true},
+		{"cloud metadata", Config{Provider: ProviderCohere, APIKey: "k", BaseURL: "http://169.254.169.254/v1"}, true},
+		{"local localhost", Config{Provider: ProviderLocal, BaseURL: "http://localhost:11434/v1"}, false},
+		{"local private ip", Config{Provider: ProviderLocal, BaseURL: "http://10.0.0.8:8080/v1"}, false},
+		{"local metadata", Config{Provider: ProviderLocal, BaseURL: "http://169.254.169.254/v1"}, true},
+		{"bad scheme", Config{Provider: ProviderLocal, BaseURL: "file:///etc/passwd"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateConfig(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddDocuments_Resume(t *testing.T) {
+	var failBad atomic.Bool
+	failBad.Store(true)
+	var embedded []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req localRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		resp := localResponse{}
+		for _, text := range req.Texts {
+			if text == "bad" && failBad.Load() {
+				resp = localResponse{Error: "rate limited"}
+				break
+			}
+			resp.Embeddings = append(resp.Embeddings, []float32{float32(len(text)), 1, 0})
+		}
+		if resp.Error == "" {
+			mu.Lock()
+			embedded = append(embedded, req.Texts...)
+			mu.Unlock()
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	store, err := NewVectorStore(VectorStoreConfig{
+		DBPath: filepath.Join(t.TempDir(), "test.db"),
+		Client: NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL}),
+	})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	docs := []Document{
+		{ID: "1", Content: "a"},
+		{ID: "2", Content: "bb"},
+		{Content: "bad", Metadata: map[string]interface{}{"source": "import"}},
+		{ID: "4", Content: "cccc"},
+		{ID: "5", Content: "ddddd"},
+	}
+	var reports []ImportProgress
+	opts := ImportOptions{Resume: true, BatchSize: 2, Progress: func(p ImportProgress) { reports = append(reports, p) }}
+
+	// The second batch fails: the first stays stored
+	progress, err := store.AddDocuments(context.Background(), docs, opts)
+	if err == nil {
+		t.Fatal("expected the failing batch to stop the import")
+	}
+	if progress.Embedded != 2 || progress.Total != 5 {
+		t.Errorf("progress = %+v, want 2 of 5 embedded", progress)
+	}
+	if n, _ := store.Count(); n != 2 {
+		t.Errorf("expected 2 stored documents, got %d", n)
+	}
+
+	// Resuming skips the stored documents and embeds the rest
+	failBad.Store(false)
+	embedded, reports = nil, nil
+	progress, err = store.AddDocuments(context.Background(), docs, opts)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if progress.Skipped != 2 || progress.Embedded != 3 || progress.Done() != 5 {
+		t.Errorf("progress = %+v, want 2 skipped and 3 embedded", progress)
+	}
+	if want := []string{"bad", "cccc", "ddddd"}; !reflect.DeepEqual(embedded, want) {
+		t.Errorf("embedded %q, want %q", embedded, want)
+	}
+	if len(reports) == 0 || reports[len(reports)-1] != progress {
+		t.Errorf("last progress report = %+v, want %+v", reports, progress)
+	}
+	entry, err := store.Get(ContentHash("bad")[:16])
+	if err != nil || entry == nil || entry.Metadata["source"] != "import" || entry.Metadata[ContentHashKey] != ContentHash("bad") {
+		t.Errorf("document without an ID: got %+v, %v", entry, err)
+	}
+
+	// Changed content is embedded again; without Resume everything is
+	docs[0].Content = "changed"
+	embedded = nil
+	if progress, _ = store.AddDocuments(context.Background(), docs, opts); progress.Embedded != 1 {
+		t.Errorf("expected only the changed document to be embedded, got %+v", progress)
+	}
+	if progress, _ = store.AddDocuments(context.Background(), docs, ImportOptions{}); progress.Embedded != 5 {
+		t.Errorf("expected every document embedded without Resume, got %+v", progress)
+	}
+}
+
+func TestPgPoolShared(t *testing.T) {
+	// sql.Open doesn't connect, so no Postgres is needed
+	const dsn = "postgres://localhost/magabot_pool_test?sslmode=disable"
+	db1, err := openPgPool(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db2, err := openPgPool(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db1 != db2 {
+		t.Error("backends on one DSN should share a pool")
+	}
+
+	if err := closePgPool(dsn); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pgPools.pools[dsn]; !ok {
+		t.Fatal("pool closed while still in use")
+	}
+	if err := closePgPool(dsn); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pgPools.pools[dsn]; ok {
+		t.Error("pool kept after the last backend closed")
+	}
+}
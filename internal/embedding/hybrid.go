@@ -0,0 +1,195 @@
+// Hybrid keyword (BM25) and vector search over a VectorStore
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+const (
+	// DefaultKeywordWeight balances keyword and vector ranks equally.
+	DefaultKeywordWeight = 0.5
+
+	// rrfK dampens the influence of top ranks in reciprocal-rank fusion.
+	rrfK = 60
+
+	// hybridMinDepth is the minimum number of candidates taken from each
+	// ranking before fusion.
+	hybridMinDepth = 50
+
+	// BM25 parameters, as used by SQLite FTS5.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// HybridSearch ranks entries by both BM25 keyword relevance and embedding
+// similarity, fused by reciprocal rank with the store's keyword weight.
+// Exact terms such as names and IDs surface even when their vectors are far
+// from the query. Without an embedding client it ranks by keywords alone.
+func (s *VectorStore) HybridSearch(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	return s.HybridSearchWeighted(ctx, query, limit, s.keywordWeight)
+}
+
+// HybridSearchWeighted is HybridSearch with an explicit keyword weight in
+// [0, 1]: 0 ranks by vectors only, 1 by keywords only.
+func (s *VectorStore) HybridSearchWeighted(ctx context.Context, query string, limit int, keywordWeight float64) ([]SearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	keywordWeight = min(max(keywordWeight, 0), 1)
+	if s.client == nil {
+		keywordWeight = 1
+	}
+	depth := max(limit*4, hybridMinDepth)
+
+	var queryVector []float32
+	var vectorHits []SearchResult
+	if keywordWeight < 1 {
+		emb, err := s.client.EmbedQuery(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("generate query embedding: %w", err)
+		}
+		queryVector = emb.Vector
+		if vectorHits, err = s.SearchByVector(ctx, queryVector, depth); err != nil {
+			return nil, err
+		}
+	}
+
+	var keywordHits []string
+	if keywordWeight > 0 {
+		var err error
+		if keywordHits, err = s.keywordSearch(query, depth); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reciprocal-rank fusion
+	scores := make(map[string]float64)
+	entries := make(map[string]*Entry)
+	for rank, r := range vectorHits {
+		scores[r.Entry.ID] += (1 - keywordWeight) / float64(rrfK+rank+1)
+		entries[r.Entry.ID] = r.Entry
+	}
+	for rank, id := range keywordHits {
+		scores[id] += keywordWeight / float64(rrfK+rank+1)
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		entry := entries[id]
+		if entry == nil {
+			var err error
+			if entry, err = s.Get(id); err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				continue // deleted since ranking
+			}
+		}
+		var similarity float32
+		if queryVector != nil {
+			similarity = float32(allm.CosineSimilarity(queryVector, entry.Embedding))
+		}
+		results = append(results, SearchResult{Entry: entry, Similarity: similarity, Score: float32(score)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Similarity > results[j].Similarity
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// keywordSearch returns the IDs of entries matching any query term, best
+// keyword score first.
+func (s *VectorStore) keywordSearch(query string, limit int) ([]string, error) {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	return s.backend.KeywordSearch(terms, limit)
+}
+
+// bm25Doc is a document ranked by rankBM25.
+type bm25Doc struct {
+	id      string
+	content string
+}
+
+// rankBM25 returns the IDs of docs matching any term, best BM25 score
+// first, for backends without a full-text index.
+func rankBM25(docs []bm25Doc, terms []string, limit int) []string {
+	if len(docs) == 0 {
+		return nil
+	}
+	type counted struct {
+		id   string
+		tf   map[string]int
+		size int
+	}
+	counts := make([]counted, 0, len(docs))
+	df := make(map[string]int)
+	totalLen := 0
+	for _, doc := range docs {
+		tokens := tokenize(doc.content)
+		d := counted{id: doc.id, tf: make(map[string]int), size: len(tokens)}
+		for _, t := range tokens {
+			d.tf[t]++
+		}
+		for _, t := range terms {
+			if d.tf[t] > 0 {
+				df[t]++
+			}
+		}
+		counts = append(counts, d)
+		totalLen += len(tokens)
+	}
+
+	n := float64(len(counts))
+	avgLen := float64(totalLen) / n
+	type scored struct {
+		id    string
+		score float64
+	}
+	var hits []scored
+	for _, d := range counts {
+		var score float64
+		for _, t := range terms {
+			tf := float64(d.tf[t])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(d.size)/avgLen))
+		}
+		if score > 0 {
+			hits = append(hits, scored{d.id, score})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+
+	ids := make([]string, 0, min(limit, len(hits)))
+	for _, h := range hits[:min(limit, len(hits))] {
+		ids = append(ids, h.id)
+	}
+	return ids
+}
+
+// tokenize splits text into lowercase letter/digit runs, matching FTS5's
+// default unicode61 tokenizer closely enough for ranking.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
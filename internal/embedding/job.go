@@ -0,0 +1,143 @@
+// Resumable bulk embedding of documents
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// ContentHashKey is the metadata key AddDocuments stores each document's
+// content hash under, so a later run can tell it is already embedded.
+const ContentHashKey = "content_hash"
+
+// defaultImportBatch is the documents AddDocuments embeds per request.
+const defaultImportBatch = 32
+
+// Document is one entry of a bulk import.
+type Document struct {
+	ID       string // empty: derived from the content hash
+	Content  string
+	Metadata map[string]interface{}
+}
+
+// ImportProgress counts the documents of an AddDocuments run so far.
+type ImportProgress struct {
+	Total    int
+	Embedded int // embedded and stored by this run
+	Skipped  int // already stored with the same content (ImportOptions.Resume)
+}
+
+// Done returns the documents handled so far.
+func (p ImportProgress) Done() int {
+	return p.Embedded + p.Skipped
+}
+
+// ImportOptions configure AddDocuments.
+type ImportOptions struct {
+	// Resume skips documents already stored under their ID with the same
+	// content hash, so an interrupted import picks up where it stopped.
+	// Without it every document is embedded again.
+	Resume bool
+
+	BatchSize int                  // documents per embedding request (default 32)
+	Progress  func(ImportProgress) // called after each stored batch
+}
+
+// ContentHash returns the hex SHA-256 of content, as stored under
+// ContentHashKey.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddDocuments embeds docs in batches and stores each batch as soon as it
+// is embedded, so a failure loses at most the batch in flight. It stops at
+// the first error, returning the progress made; running it again with
+// ImportOptions.Resume skips what was stored.
+func (s *VectorStore) AddDocuments(ctx context.Context, docs []Document, opts ImportOptions) (ImportProgress, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultImportBatch
+	}
+	progress := ImportProgress{Total: len(docs)}
+	reported := -1
+	report := func() {
+		if opts.Progress != nil && progress.Done() != reported {
+			reported = progress.Done()
+			opts.Progress(progress)
+		}
+	}
+
+	batch := make([]Document, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		var vectors [][]float32
+		if s.client != nil {
+			texts := make([]string, len(batch))
+			for i, doc := range batch {
+				texts[i] = doc.Content
+			}
+			embeddings, err := s.client.Embed(ctx, texts)
+			if err != nil {
+				return fmt.Errorf("embed documents %d-%d: %w", progress.Done()+1, progress.Done()+len(batch), err)
+			}
+			if len(embeddings) != len(batch) {
+				return fmt.Errorf("embed documents: got %d embeddings for %d documents", len(embeddings), len(batch))
+			}
+			for _, e := range embeddings {
+				vectors = append(vectors, e.Vector)
+			}
+		}
+		for i, doc := range batch {
+			var vector []float32
+			if vectors != nil {
+				vector = vectors[i]
+			}
+			if err := s.backend.Add(doc.ID, doc.Content, vector, doc.Metadata); err != nil {
+				return fmt.Errorf("store document %s: %w", doc.ID, err)
+			}
+			progress.Embedded++
+		}
+		batch = batch[:0]
+		report()
+		return nil
+	}
+
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		hash := ContentHash(doc.Content)
+		if doc.ID == "" {
+			doc.ID = hash[:16]
+		}
+		if opts.Resume {
+			if stored, err := s.backend.Get(doc.ID); err == nil && stored != nil && stored.Metadata[ContentHashKey] == hash {
+				progress.Skipped++
+				continue
+			}
+		}
+
+		metadata := make(map[string]interface{}, len(doc.Metadata)+1)
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		metadata[ContentHashKey] = hash
+		doc.Metadata = metadata
+
+		batch = append(batch, doc)
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return progress, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return progress, err
+	}
+	report()
+	return progress, nil
+}
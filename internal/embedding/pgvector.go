@@ -0,0 +1,279 @@
+// Postgres vector backend using the pgvector extension
+package embedding
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	_ "github.com/lib/pq"
+)
+
+// pgvectorMaxIndexDimensions is the largest vector pgvector's HNSW index
+// accepts; bigger embeddings are searched without an index.
+const pgvectorMaxIndexDimensions = 2000
+
+// pgvectorBackend keeps entries in a Postgres table with a vector column.
+// Similarity and keyword ranking run in SQL, so search cost does not grow
+// with what Go has to load.
+type pgvectorBackend struct {
+	db        *sql.DB
+	dsn       string
+	tableName string
+}
+
+// pgPools shares one connection pool per DSN between backends, since each
+// user's store is a backend of its own.
+var pgPools = struct {
+	sync.Mutex
+	pools map[string]*pgPool
+}{pools: make(map[string]*pgPool)}
+
+// pgPool is a shared connection pool and the number of backends using it.
+type pgPool struct {
+	db   *sql.DB
+	refs int
+}
+
+// openPgPool returns the pool for dsn, opening it for the first backend.
+// Each call must be paired with closePgPool.
+func openPgPool(dsn string) (*sql.DB, error) {
+	pgPools.Lock()
+	defer pgPools.Unlock()
+	if p, ok := pgPools.pools[dsn]; ok {
+		p.refs++
+		return p.db, nil
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	pgPools.pools[dsn] = &pgPool{db: db, refs: 1}
+	return db, nil
+}
+
+// closePgPool releases a backend's use of the pool for dsn, closing it
+// after the last one.
+func closePgPool(dsn string) error {
+	pgPools.Lock()
+	defer pgPools.Unlock()
+	p, ok := pgPools.pools[dsn]
+	if !ok {
+		return nil
+	}
+	if p.refs--; p.refs > 0 {
+		return nil
+	}
+	delete(pgPools.pools, dsn)
+	return p.db.Close()
+}
+
+func newPgvectorBackend(dsn, tableName string, dimensions int) (*pgvectorBackend, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("pgvector: dsn is required")
+	}
+	db, err := openPgPool(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	b := &pgvectorBackend{db: db, dsn: dsn, tableName: tableName}
+	if err := b.initSchema(dimensions); err != nil {
+		_ = closePgPool(dsn)
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
+	return b, nil
+}
+
+// initSchema creates the extension, table and indexes if they don't exist.
+func (b *pgvectorBackend) initSchema(dimensions int) error {
+	query := fmt.Sprintf(`
+		CREATE EXTENSION IF NOT EXISTS vector;
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			id TEXT PRIMARY KEY,
+			content TEXT NOT NULL,
+			embedding vector(%[2]d),
+			metadata JSONB,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_%[1]s_created ON %[1]s(created_at);
+		CREATE INDEX IF NOT EXISTS idx_%[1]s_content ON %[1]s USING gin (to_tsvector('simple', content));
+	`, b.tableName, dimensions)
+	if _, err := b.db.Exec(query); err != nil {
+		return err
+	}
+	if dimensions > pgvectorMaxIndexDimensions {
+		return nil
+	}
+	_, err := b.db.Exec(fmt.Sprintf(
+		`CREATE INDEX IF NOT EXISTS idx_%[1]s_embedding ON %[1]s USING hnsw (embedding vector_cosine_ops)`,
+		b.tableName))
+	return err
+}
+
+// vectorParam formats an embedding as a pgvector literal, or NULL when empty.
+func vectorParam(embedding []float32) (any, error) {
+	if len(embedding) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(embedding) // "[1,2,3]" is also pgvector's text form
+	if err != nil {
+		return nil, fmt.Errorf("marshal embedding: %w", err)
+	}
+	return string(data), nil
+}
+
+func (b *pgvectorBackend) Add(id, content string, embedding []float32, metadata map[string]interface{}) error {
+	vec, err := vectorParam(embedding)
+	if err != nil {
+		return err
+	}
+	metaData, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("marshal metadata: %w", err)
+	}
+
+	_, err = b.db.Exec(fmt.Sprintf(`
+		INSERT INTO %s (id, content, embedding, metadata)
+		VALUES ($1, $2, $3::vector, $4::jsonb)
+		ON CONFLICT (id) DO UPDATE SET
+			content = EXCLUDED.content,
+			embedding = EXCLUDED.embedding,
+			metadata = EXCLUDED.metadata,
+			updated_at = now()
+	`, b.tableName), id, content, vec, string(metaData))
+	return err
+}
+
+// selectColumns are the entry columns in scanEntry order.
+const selectColumns = `id, content, embedding::text, metadata::text, created_at, updated_at`
+
+// scanEntry reads an entry selected with selectColumns plus any extra
+// destinations.
+func scanEntry(row interface{ Scan(...any) error }, extra ...any) (*Entry, error) {
+	var entry Entry
+	var embData, metaData sql.NullString
+	dest := append([]any{&entry.ID, &entry.Content, &embData, &metaData, &entry.CreatedAt, &entry.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if embData.Valid {
+		if err := json.Unmarshal([]byte(embData.String), &entry.Embedding); err != nil {
+			return nil, fmt.Errorf("unmarshal embedding: %w", err)
+		}
+	}
+	if metaData.Valid && metaData.String != "null" {
+		if err := json.Unmarshal([]byte(metaData.String), &entry.Metadata); err != nil {
+			return nil, fmt.Errorf("unmarshal metadata: %w", err)
+		}
+	}
+	return &entry, nil
+}
+
+func (b *pgvectorBackend) Get(id string) (*Entry, error) {
+	row := b.db.QueryRow(fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1`, selectColumns, b.tableName), id)
+	entry, err := scanEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return entry, err
+}
+
+func (b *pgvectorBackend) Delete(id string) error {
+	_, err := b.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, b.tableName), id)
+	return err
+}
+
+func (b *pgvectorBackend) SearchByVector(ctx context.Context, queryVector []float32, limit int) ([]SearchResult, error) {
+	vec, err := vectorParam(queryVector)
+	if err != nil || vec == nil {
+		return nil, err
+	}
+	// <=> is cosine distance: 1 - distance is the cosine similarity
+	rows, err := b.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s, 1 - (embedding <=> $1::vector)
+		FROM %s WHERE embedding IS NOT NULL
+		ORDER BY embedding <=> $1::vector LIMIT $2
+	`, selectColumns, b.tableName), vec, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var results []SearchResult
+	for rows.Next() {
+		var similarity float64
+		entry, err := scanEntry(rows, &similarity)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{Entry: entry, Similarity: float32(similarity)})
+	}
+	return results, rows.Err()
+}
+
+func (b *pgvectorBackend) KeywordSearch(terms []string, limit int) ([]string, error) {
+	// Terms are letters and digits only, so they are safe tsquery lexemes
+	rows, err := b.db.Query(fmt.Sprintf(`
+		SELECT id FROM %s, to_tsquery('simple', $1) query
+		WHERE to_tsvector('simple', content) @@ query
+		ORDER BY ts_rank(to_tsvector('simple', content), query) DESC LIMIT $2
+	`, b.tableName), strings.Join(terms, " | "), limit)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (b *pgvectorBackend) Count() (int, error) {
+	var count int
+	err := b.db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s`, b.tableName)).Scan(&count)
+	return count, err
+}
+
+func (b *pgvectorBackend) List(offset, limit int) ([]*Entry, error) {
+	rows, err := b.db.Query(fmt.Sprintf(`
+		SELECT %s FROM %s ORDER BY created_at DESC LIMIT $1 OFFSET $2
+	`, selectColumns, b.tableName), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []*Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (b *pgvectorBackend) Clear() error {
+	_, err := b.db.Exec(fmt.Sprintf(`DELETE FROM %s`, b.tableName))
+	return err
+}
+
+func (b *pgvectorBackend) Close() error {
+	if b.db == nil {
+		return nil
+	}
+	b.db = nil
+	return closePgPool(b.dsn)
+}
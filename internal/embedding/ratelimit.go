@@ -0,0 +1,138 @@
+// Client-side rate limiting and retry for embedding API calls
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when the provider keeps answering 429 after all
+// retries are exhausted.
+var ErrRateLimited = errors.New("embedding provider rate limit exceeded")
+
+const (
+	// defaultRetryBaseDelay is the first backoff delay; it doubles per attempt.
+	defaultRetryBaseDelay = 1 * time.Second
+	// maxRetryDelay caps both exponential backoff and provider Retry-After hints.
+	maxRetryDelay = 60 * time.Second
+)
+
+// apiStatusError is a retryable HTTP failure (429 or 5xx) from an embedding API.
+type apiStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // parsed Retry-After header; 0 if absent
+	Body       string
+}
+
+func (e *apiStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// isRetryableStatus reports whether an HTTP status should be retried.
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter parses a Retry-After header given as delay-seconds or an
+// HTTP date. Returns 0 when the header is absent or malformed.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// tokenBucket is a simple token-bucket limiter shared by all embedding
+// requests from one Client, regardless of provider.
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+// newTokenBucket creates a limiter allowing perMinute requests per minute,
+// with a burst of roughly one second's worth (at least 1).
+// Returns nil when perMinute <= 0 (unlimited).
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	rate := float64(perMinute) / 60
+	capacity := rate
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &tokenBucket{
+		tokens:   capacity,
+		capacity: capacity,
+		rate:     rate,
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until a token is available or ctx is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext sleeps for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryDelay returns the backoff before retry number attempt (0-based),
+// preferring the provider's Retry-After hint when present.
+func (c *Client) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	d := retryAfter
+	if d <= 0 {
+		d = c.retryBaseDelay << attempt
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
@@ -0,0 +1,66 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Embedder computes embeddings for a provider not built into Client, such
+// as one a plugin registers. Embed returns one vector per text, in order;
+// query is true when the texts are search queries rather than documents.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string, query bool) ([][]float32, error)
+}
+
+var (
+	embeddersMu sync.RWMutex
+	embedders   = map[Provider]Embedder{}
+)
+
+// RegisterEmbedder makes e the backend of Clients whose Config.Provider is
+// name. Built-in providers can't be replaced.
+func RegisterEmbedder(name Provider, e Embedder) error {
+	switch name {
+	case "", ProviderOpenAI, ProviderVoyage, ProviderCohere, ProviderLocal:
+		return fmt.Errorf("embedding provider name %q is reserved", name)
+	}
+	embeddersMu.Lock()
+	defer embeddersMu.Unlock()
+	if _, ok := embedders[name]; ok {
+		return fmt.Errorf("embedding provider already registered: %s", name)
+	}
+	embedders[name] = e
+	return nil
+}
+
+// UnregisterEmbedder removes a backend added with RegisterEmbedder. Clients
+// using it fail from then on.
+func UnregisterEmbedder(name Provider) {
+	embeddersMu.Lock()
+	defer embeddersMu.Unlock()
+	delete(embedders, name)
+}
+
+// registeredEmbedder returns the backend registered as name, or nil.
+func registeredEmbedder(name Provider) Embedder {
+	embeddersMu.RLock()
+	defer embeddersMu.RUnlock()
+	return embedders[name]
+}
+
+// embedRegistered embeds texts with a registered backend.
+func (c *Client) embedRegistered(ctx context.Context, e Embedder, texts []string, kind inputType) ([]Embedding, error) {
+	vectors, err := e.Embed(ctx, texts, kind == inputQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.config.Provider, err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("%s: got %d embeddings for %d inputs", c.config.Provider, len(vectors), len(texts))
+	}
+	out := make([]Embedding, len(texts))
+	for i, v := range vectors {
+		out[i] = Embedding{Text: texts[i], Vector: v, Model: c.config.Model}
+	}
+	return out, nil
+}
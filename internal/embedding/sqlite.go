@@ -0,0 +1,377 @@
+// SQLite vector backend: embeddings stored as JSON, ranked in Go
+package embedding
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kusandriadi/allm-go"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// MaxSearchEntries is the maximum number of entries to scan during search.
+// This provides a safeguard against OOM for large datasets.
+const MaxSearchEntries = 10000
+
+// searchCancelCheck is how many rows SearchByVector scans between checks
+// for a cancelled context.
+const searchCancelCheck = 256
+
+// sqliteBackend keeps entries in a SQLite table and scans the newest
+// MaxSearchEntries of them for vector and keyword search.
+type sqliteBackend struct {
+	mu        sync.RWMutex
+	db        *sql.DB
+	tableName string
+	logger    *slog.Logger
+	fts       bool // full-text index available (SQLite FTS5)
+}
+
+func newSQLiteBackend(dbPath, tableName string, logger *slog.Logger) (*sqliteBackend, error) {
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
+		return nil, fmt.Errorf("create db dir: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	b := &sqliteBackend{db: db, tableName: tableName, logger: logger}
+	if err := b.initSchema(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
+	return b, nil
+}
+
+// initSchema creates the database tables if they don't exist.
+func (b *sqliteBackend) initSchema() error {
+	query := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content TEXT NOT NULL,
+			embedding BLOB NOT NULL,
+			metadata TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_%s_created ON %s(created_at);
+	`, b.tableName, b.tableName, b.tableName)
+
+	if _, err := b.db.Exec(query); err != nil {
+		return err
+	}
+	return b.initFTS()
+}
+
+// initFTS creates the full-text index over entry content. SQLite builds
+// without FTS5 (the go-sqlite3 default without the sqlite_fts5 tag) fall
+// back to ranking BM25 in Go.
+func (b *sqliteBackend) initFTS() error {
+	_, err := b.db.Exec(fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s_fts USING fts5(id UNINDEXED, content)`, b.tableName))
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			b.logger.Debug("sqlite built without fts5; keyword search ranks in memory", "table", b.tableName)
+			return nil
+		}
+		return fmt.Errorf("create fts index: %w", err)
+	}
+	b.fts = true
+
+	// Index entries stored before the index existed
+	_, err = b.db.Exec(fmt.Sprintf(`
+		INSERT INTO %[1]s_fts (id, content)
+		SELECT id, content FROM %[1]s WHERE id NOT IN (SELECT id FROM %[1]s_fts)
+	`, b.tableName))
+	if err != nil {
+		return fmt.Errorf("backfill fts index: %w", err)
+	}
+	return nil
+}
+
+func (b *sqliteBackend) Add(id, content string, embedding []float32, metadata map[string]interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	embData, err := json.Marshal(embedding)
+	if err != nil {
+		return fmt.Errorf("marshal embedding: %w", err)
+	}
+
+	metaData, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("marshal metadata: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT OR REPLACE INTO %s (id, content, embedding, metadata, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, b.tableName)
+
+	if !b.fts {
+		_, err = b.db.Exec(query, id, content, embData, string(metaData))
+		return err
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(query, id, content, embData, string(metaData)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s_fts WHERE id = ?", b.tableName), id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s_fts (id, content) VALUES (?, ?)", b.tableName), id, content); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) Get(id string) (*Entry, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	query := fmt.Sprintf(`
+		SELECT id, content, embedding, metadata, created_at, updated_at
+		FROM %s WHERE id = ?
+	`, b.tableName)
+
+	var entry Entry
+	var embData []byte
+	var metaData string
+	err := b.db.QueryRow(query, id).Scan(
+		&entry.ID, &entry.Content, &embData, &metaData,
+		&entry.CreatedAt, &entry.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := decodeEntry(&entry, embData, metaData, b.logger); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (b *sqliteBackend) Delete(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = ?", b.tableName)
+	if _, err := b.db.Exec(query, id); err != nil {
+		return err
+	}
+	if b.fts {
+		_, err := b.db.Exec(fmt.Sprintf("DELETE FROM %s_fts WHERE id = ?", b.tableName), id)
+		return err
+	}
+	return nil
+}
+
+func (b *sqliteBackend) SearchByVector(ctx context.Context, queryVector []float32, limit int) ([]SearchResult, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	// Load entries with limit to prevent OOM
+	query := fmt.Sprintf(`
+		SELECT id, content, embedding, metadata, created_at, updated_at
+		FROM %s ORDER BY created_at DESC LIMIT %d
+	`, b.tableName, MaxSearchEntries)
+
+	rows, err := b.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var results []SearchResult
+
+	for n := 1; rows.Next(); n++ {
+		if n%searchCancelCheck == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		var entry Entry
+		var embData []byte
+		var metaData string
+
+		err := rows.Scan(&entry.ID, &entry.Content, &embData, &metaData,
+			&entry.CreatedAt, &entry.UpdatedAt)
+		if err != nil {
+			continue
+		}
+
+		if err := decodeEntry(&entry, embData, metaData, b.logger); err != nil {
+			continue
+		}
+
+		similarity := float32(allm.CosineSimilarity(queryVector, entry.Embedding))
+
+		results = append(results, SearchResult{
+			Entry:      &entry,
+			Similarity: similarity,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// A query cancelled mid-scan can end like a finished one; don't sort
+	// a partial result
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Sort by similarity (descending)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Similarity > results[j].Similarity
+	})
+
+	// Limit results
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func (b *sqliteBackend) KeywordSearch(terms []string, limit int) ([]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.fts {
+		return b.bm25Scan(terms, limit)
+	}
+
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + t + `"` // tokens are letters and digits only
+	}
+	rows, err := b.db.Query(fmt.Sprintf(`
+		SELECT id FROM %[1]s_fts WHERE %[1]s_fts MATCH ? ORDER BY bm25(%[1]s_fts) LIMIT ?
+	`, b.tableName), strings.Join(quoted, " OR "), limit)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// bm25Scan ranks entries by BM25 in memory. Caller must hold b.mu.
+func (b *sqliteBackend) bm25Scan(terms []string, limit int) ([]string, error) {
+	rows, err := b.db.Query(fmt.Sprintf(`
+		SELECT id, content FROM %s ORDER BY created_at DESC LIMIT %d
+	`, b.tableName, MaxSearchEntries))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var docs []bm25Doc
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			continue
+		}
+		docs = append(docs, bm25Doc{id: id, content: content})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rankBM25(docs, terms, limit), nil
+}
+
+func (b *sqliteBackend) Count() (int, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", b.tableName)
+	var count int
+	err := b.db.QueryRow(query).Scan(&count)
+	return count, err
+}
+
+func (b *sqliteBackend) List(offset, limit int) ([]*Entry, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	query := fmt.Sprintf(`
+		SELECT id, content, embedding, metadata, created_at, updated_at
+		FROM %s ORDER BY created_at DESC LIMIT ? OFFSET ?
+	`, b.tableName)
+
+	rows, err := b.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []*Entry
+
+	for rows.Next() {
+		var entry Entry
+		var embData []byte
+		var metaData string
+
+		err := rows.Scan(&entry.ID, &entry.Content, &embData, &metaData,
+			&entry.CreatedAt, &entry.UpdatedAt)
+		if err != nil {
+			continue
+		}
+
+		if err := decodeEntry(&entry, embData, metaData, b.logger); err != nil {
+			continue
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+func (b *sqliteBackend) Clear() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	query := fmt.Sprintf("DELETE FROM %s", b.tableName)
+	if _, err := b.db.Exec(query); err != nil {
+		return err
+	}
+	if b.fts {
+		_, err := b.db.Exec(fmt.Sprintf("DELETE FROM %s_fts", b.tableName))
+		return err
+	}
+	return nil
+}
+
+func (b *sqliteBackend) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,239 @@
+// Package format converts the bot's chat Markdown into each platform's syntax
+package format
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type nodeKind int
+
+const (
+	nodeText nodeKind = iota
+	nodeBold
+	nodeItalic
+	nodeStrike
+	nodeCode
+	nodePre
+	nodeLink
+)
+
+type node struct {
+	kind     nodeKind
+	text     string // text, code and pre content
+	lang     string // pre language
+	url      string // link target
+	children []node // bold, italic, strike and link content
+}
+
+// emphasis maps a marker character to the node it produces.
+var emphasis = map[byte]nodeKind{'*': nodeBold, '_': nodeItalic, '~': nodeStrike}
+
+// parse splits s into formatting nodes. The supported subset is *bold* or
+// **bold**, _italic_, ~strike~ or ~~strike~~, `code`, fenced ```lang blocks
+// and [text](url) links. Emphasis markers only open at the start of a word and
+// close at its end, so snake_case names and 2*3*4 stay literal text.
+func parse(s string) []node {
+	var nodes []node
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, node{kind: nodeText, text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if n, width, ok := parseAt(s, i); ok {
+			flush()
+			nodes = append(nodes, n)
+			i += width
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		text.WriteString(s[i : i+size])
+		i += size
+	}
+	flush()
+	return nodes
+}
+
+// parseAt tries to parse a formatting construct starting at s[i].
+func parseAt(s string, i int) (node, int, bool) {
+	rest := s[i:]
+	switch c := rest[0]; {
+	case strings.HasPrefix(rest, "```"):
+		end := strings.Index(rest[3:], "```")
+		if end < 0 {
+			return node{}, 0, false
+		}
+		body := rest[3 : 3+end]
+		var lang string
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			if first := body[:nl]; first != "" && !strings.ContainsAny(first, " \t`") {
+				lang = first
+			}
+			if lang != "" || strings.TrimSpace(body[:nl]) == "" {
+				body = body[nl+1:]
+			}
+		}
+		body = strings.TrimSuffix(body, "\n")
+		return node{kind: nodePre, text: body, lang: lang}, 3 + end + 3, true
+
+	case c == '`':
+		// A double-backtick span may contain single backticks
+		fence := "`"
+		if strings.HasPrefix(rest, "``") {
+			fence = "``"
+		}
+		end := strings.Index(rest[len(fence):], fence)
+		if end <= 0 || strings.Contains(rest[len(fence):len(fence)+end], "\n") {
+			return node{}, 0, false
+		}
+		code := rest[len(fence) : len(fence)+end]
+		if len(fence) == 2 {
+			code = strings.TrimPrefix(strings.TrimSuffix(code, " "), " ")
+		}
+		return node{kind: nodeCode, text: code}, end + 2*len(fence), true
+
+	case c == '[':
+		closeText := strings.Index(rest, "](")
+		if closeText < 0 || strings.ContainsAny(rest[1:closeText], "\n[]") {
+			return node{}, 0, false
+		}
+		closeURL := closingParen(rest[closeText+2:])
+		if closeURL <= 0 {
+			return node{}, 0, false
+		}
+		url := rest[closeText+2 : closeText+2+closeURL]
+		if strings.ContainsAny(url, " \n") {
+			return node{}, 0, false
+		}
+		return node{kind: nodeLink, url: url, children: parse(rest[1:closeText])}, closeText + 2 + closeURL + 1, true
+
+	default:
+		kind, ok := emphasis[c]
+		if !ok || wordCharBefore(s, i) {
+			return node{}, 0, false
+		}
+		delim := rest[:1]
+		if len(rest) > 1 && rest[1] == c {
+			delim = rest[:2]
+		}
+		inner := rest[len(delim):]
+		if inner == "" || unicode.IsSpace(firstRune(inner)) {
+			return node{}, 0, false
+		}
+		for off := 1; off < len(inner); {
+			j := strings.Index(inner[off:], delim)
+			if j < 0 {
+				break
+			}
+			j += off
+			content := inner[:j]
+			after := i + len(delim) + j + len(delim)
+			if strings.Contains(content, "\n") {
+				break
+			}
+			if !unicode.IsSpace(lastRune(content)) && !wordCharAt(s, after) && (after >= len(s) || s[after] != c) {
+				return node{kind: kind, children: parse(content)}, len(delim)*2 + j, true
+			}
+			off = j + 1
+		}
+		return node{}, 0, false
+	}
+}
+
+// closingParen returns the index of the ')' that ends a link target,
+// allowing balanced parentheses inside it as in Wikipedia URLs. Returns -1
+// when there is none.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// wordCharBefore reports whether the rune before s[i] is a letter or digit.
+func wordCharBefore(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordCharAt reports whether the rune at s[i] is a letter or digit.
+func wordCharAt(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// renderer emits one platform's syntax for each node kind.
+type renderer struct {
+	text   func(string) string
+	bold   [2]string
+	italic [2]string
+	strike [2]string
+	code   func(string) string
+	pre    func(code, lang string) string
+	link   func(text, url string) string
+}
+
+func (r *renderer) render(nodes []node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeText:
+			b.WriteString(r.text(n.text))
+		case nodeBold:
+			b.WriteString(r.bold[0] + r.render(n.children) + r.bold[1])
+		case nodeItalic:
+			b.WriteString(r.italic[0] + r.render(n.children) + r.italic[1])
+		case nodeStrike:
+			b.WriteString(r.strike[0] + r.render(n.children) + r.strike[1])
+		case nodeCode:
+			b.WriteString(r.code(n.text))
+		case nodePre:
+			b.WriteString(r.pre(n.text, n.lang))
+		case nodeLink:
+			b.WriteString(r.link(r.render(n.children), n.url))
+		}
+	}
+	return b.String()
+}
+
+// escapeWith prefixes every rune in chars with a backslash.
+func escapeWith(s, chars string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
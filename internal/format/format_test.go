@@ -0,0 +1,79 @@
+package format
+
+import "testing"
+
+func TestToTelegram(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "Hello world", "Hello world"},
+		{"reserved chars", "1+1=2. Done! (really) #tag a-b {x} |y| >z", `1\+1\=2\. Done\! \(really\) \#tag a\-b \{x\} \|y\| \>z`},
+		{"bold", "**bold** and *also*", "*bold* and *also*"},
+		{"italic", "_italic_ text", "_italic_ text"},
+		{"nested", "**bold _italic_**", "*bold _italic_*"},
+		{"strike", "~~gone~~", "~gone~"},
+		{"snake case", "use user_id_here", `use user\_id\_here`},
+		{"arithmetic", "2*3*4", `2\*3\*4`},
+		{"unclosed", "*not bold", `\*not bold`},
+		{"inline code", "run `a_b(1).c`", "run `a_b(1).c`"},
+		{"code escapes", "`a\\b`", "`a\\\\b`"},
+		{"code block", "```go\nx := f(1)\n```", "```go\nx := f(1)\n```"},
+		{"link", "see [the docs](https://example.com/a_(b))", `see [the docs](https://example.com/a_(b\))`},
+		{"link text escaped", "[v1.2](https://x.io)", `[v1\.2](https://x.io)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToTelegram(tt.in); got != tt.want {
+				t.Errorf("ToTelegram(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToSlack(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "Hello world", "Hello world"},
+		{"reserved chars", "a < b && c > d", "a &lt; b &amp;&amp; c &gt; d"},
+		{"bold", "**bold** and *also*", "*bold* and *also*"},
+		{"italic", "_italic_", "_italic_"},
+		{"strike", "~~gone~~", "~gone~"},
+		{"inline code", "`x<y`", "`x&lt;y`"},
+		{"code block drops lang", "```python\nprint(1)\n```", "```\nprint(1)\n```"},
+		{"link", "[**docs**](https://example.com?a=1&b=2)", "<https://example.com?a=1&b=2|*docs*>"},
+		{"link with pipe", "[x](https://e.com/a|b)", "<https://e.com/a%7Cb|x>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToSlack(tt.in); got != tt.want {
+				t.Errorf("ToSlack(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToDiscord(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "Hello world.", "Hello world."},
+		{"reserved chars", `a|b > c \ d`, `a\|b \> c \\ d`},
+		{"bold", "*bold* and **also**", "**bold** and **also**"},
+		{"italic", "_italic_", "_italic_"},
+		{"strike", "~gone~", "~~gone~~"},
+		{"snake case", "user_id", `user\_id`},
+		{"header at line start", "# not a header\n- not a list", "\\# not a header\n\\- not a list"},
+		{"inline code", "`a*b`", "`a*b`"},
+		{"code with backtick", "`` a`b ``", "`` a`b ``"},
+		{"code block", "```js\nlet x\n```", "```js\nlet x\n```"},
+		{"link", "[docs](https://example.com)", "[docs](https://example.com)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToDiscord(tt.in); got != tt.want {
+				t.Errorf("ToDiscord(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Per-platform renderers
+package format
+
+import "strings"
+
+// telegramReserved are the characters MarkdownV2 requires escaping in text.
+const telegramReserved = "_*[]()~`>#+-=|{}.!\\"
+
+var telegram = &renderer{
+	text:   func(s string) string { return escapeWith(s, telegramReserved) },
+	bold:   [2]string{"*", "*"},
+	italic: [2]string{"_", "_"},
+	strike: [2]string{"~", "~"},
+	code:   func(s string) string { return "`" + escapeWith(s, "`\\") + "`" },
+	pre: func(code, lang string) string {
+		return "```" + lang + "\n" + escapeWith(code, "`\\") + "\n```"
+	},
+	link: func(text, url string) string {
+		return "[" + text + "](" + escapeWith(url, ")\\") + ")"
+	},
+}
+
+// ToTelegram converts Markdown to Telegram MarkdownV2 (parse_mode "MarkdownV2").
+func ToTelegram(s string) string {
+	return telegram.render(parse(s))
+}
+
+// slackEscaper escapes the characters Slack treats as control sequences.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var slack = &renderer{
+	text:   slackEscaper.Replace,
+	bold:   [2]string{"*", "*"},
+	italic: [2]string{"_", "_"},
+	strike: [2]string{"~", "~"},
+	code:   func(s string) string { return "`" + slackEscaper.Replace(s) + "`" },
+	pre: func(code, _ string) string {
+		// Slack ignores language hints and would show them as code
+		return "```\n" + slackEscaper.Replace(code) + "\n```"
+	},
+	link: func(text, url string) string {
+		url = strings.NewReplacer("|", "%7C", ">", "%3E", "<", "%3C").Replace(url)
+		return "<" + url + "|" + text + ">"
+	},
+}
+
+// ToSlack converts Markdown to Slack mrkdwn.
+func ToSlack(s string) string {
+	return slack.render(parse(s))
+}
+
+// discordReserved are the characters Discord treats as formatting.
+const discordReserved = "\\*_~`|>[]"
+
+var discord = &renderer{
+	text:   escapeDiscord,
+	bold:   [2]string{"**", "**"},
+	italic: [2]string{"_", "_"},
+	strike: [2]string{"~~", "~~"},
+	code: func(s string) string {
+		// Code spans cannot escape backticks; a double fence can hold them
+		if strings.Contains(s, "`") {
+			return "`` " + s + " ``"
+		}
+		return "`" + s + "`"
+	},
+	pre: func(code, lang string) string {
+		// A zero-width space keeps a nested fence from closing the block
+		return "```" + lang + "\n" + strings.ReplaceAll(code, "```", "`\u200b``") + "\n```"
+	},
+	link: func(text, url string) string {
+		return "[" + text + "](" + strings.ReplaceAll(url, ")", "%29") + ")"
+	},
+}
+
+// escapeDiscord escapes formatting characters plus headers and list markers
+// at the start of a line. Escaping a marker that would not have rendered is
+// harmless; Discord drops the backslash before any punctuation.
+func escapeDiscord(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = escapeWith(line, discordReserved)
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "- ") {
+			line = "\\" + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ToDiscord converts Markdown to Discord's Markdown dialect.
+func ToDiscord(s string) string {
+	return discord.render(parse(s))
+}
@@ -39,6 +39,7 @@ const (
 // EventData is the JSON payload passed to hooks on stdin.
 type EventData struct {
 	Event     string   `json:"event"`
+	RequestID string   `json:"request_id,omitempty"` // correlates the hook with the message's logs and LLM calls
 	Platform  string   `json:"platform,omitempty"`
 	UserID    string   `json:"user_id,omitempty"`
 	ChatID    string   `json:"chat_id,omitempty"`
@@ -107,7 +108,7 @@ func (m *Manager) Fire(event Event, data *EventData) *Result {
 		out, err := m.executeHook(h, data)
 		if err != nil {
 			result.Blocked = true
-			m.logger.Warn("hook blocked or failed",
+			m.loggerFor(data).Warn("hook blocked or failed",
 				"hook", h.Name, "event", event, "error", err)
 		}
 		if out != "" {
@@ -139,6 +140,15 @@ func (m *Manager) FireAsync(event Event, data *EventData) {
 	}
 }
 
+// loggerFor returns the logger for a hook run, tagged with the event's
+// request ID when it has one.
+func (m *Manager) loggerFor(data *EventData) *slog.Logger {
+	if data.RequestID == "" {
+		return m.logger
+	}
+	return m.logger.With("request_id", data.RequestID)
+}
+
 // matchesPlatform checks if a hook should run for the given platform.
 func matchesPlatform(platforms []string, platform string) bool {
 	if len(platforms) == 0 {
@@ -171,9 +181,10 @@ func (m *Manager) executeHook(h config.HookConfig, data *EventData) (string, err
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	logger := m.loggerFor(data)
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		m.logger.Error("hook marshal failed", "hook", h.Name, "error", err)
+		logger.Error("hook marshal failed", "hook", h.Name, "error", err)
 		return "", fmt.Errorf("marshal: %w", err)
 	}
 
@@ -185,10 +196,10 @@ func (m *Manager) executeHook(h config.HookConfig, data *EventData) (string, err
 	cmd.Stdout = &limitedWriter{w: &stdout, n: maxOutputBytes}
 	cmd.Stderr = &limitedWriter{w: &stderr, n: maxOutputBytes}
 
-	m.logger.Debug("firing hook", "hook", h.Name, "event", data.Event, "platform", data.Platform)
+	logger.Debug("firing hook", "hook", h.Name, "event", data.Event, "platform", data.Platform)
 
 	if err := cmd.Run(); err != nil {
-		m.logger.Warn("hook execution failed",
+		logger.Warn("hook execution failed",
 			"hook", h.Name,
 			"event", data.Event,
 			"error", err,
@@ -199,7 +210,7 @@ func (m *Manager) executeHook(h config.HookConfig, data *EventData) (string, err
 
 	output := strings.TrimSpace(stdout.String())
 	if output != "" {
-		m.logger.Debug("hook produced output", "hook", h.Name, "output_len", len(output))
+		logger.Debug("hook produced output", "hook", h.Name, "output_len", len(output))
 	}
 
 	return output, nil
@@ -131,6 +131,22 @@ func TestFire_Echo(t *testing.T) {
 	}
 }
 
+func TestFire_RequestIDOnStdin(t *testing.T) {
+	skipIfNoShell(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat")
+	}
+	hooksConfig := []config.HookConfig{
+		{Name: "cat-hook", Event: "pre_message", Command: "cat"},
+	}
+	m := hooks.NewManager(hooksConfig, newLogger())
+
+	result := m.Fire(hooks.PreMessage, &hooks.EventData{RequestID: "0123456789abcdef", Text: "hi"})
+	if !strings.Contains(result.Output, `"request_id":"0123456789abcdef"`) {
+		t.Errorf("hook stdin = %q, want the request ID", result.Output)
+	}
+}
+
 func TestFire_PlatformFilter(t *testing.T) {
 	hooksConfig := []config.HookConfig{
 		{
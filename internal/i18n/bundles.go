@@ -0,0 +1,507 @@
+package i18n
+
+// en is the English bundle and the fallback for every other language.
+var en = Bundle{
+	"admin.required":   "🔒 Admin access required.",
+	"command.unknown":  "❓ Unknown command. Try /help",
+	"command.denied":   "🔒 You don't have permission to use %s.",
+	"command.disabled": "🚫 %s is disabled on this bot.",
+	"confirm.none":     "No pending action to confirm.",
+	"cancel.none":      "No pending action to cancel.",
+	"welcome.first":    "👋 *Welcome!* This is our first conversation.\nType /help to see all features.\n\n",
+
+	"lang.current":     "🌐 Language: %s\n\nAvailable: %s\n\nSwitch: /lang <code>",
+	"lang.set":         "✅ Language set to %s",
+	"lang.unsupported": "Unsupported language %q. Available: %s",
+
+	"image.too_large": "⚠️ %s is too large to include, so I skipped it.",
+	"image.invalid":   "⚠️ %s is not a valid image, so I skipped it.",
+
+	"session.reset":          "🗑 Conversation history cleared.",
+	"session.reset_db_error": "⚠️ History cleared from memory but DB error: %v",
+	"session.auto_reset":     "🔄 _This conversation reached %d turns, so I started a fresh one. Send /reset to do this anytime._\n\n",
+
+	"llm.truncated": "✂️ _(response truncated: it reached the output token limit)_",
+
+	"moderation.blocked": "🚫 I can't help with that message.",
+
+	"quota.exceeded": "⏳ You've reached your daily limit. It resets at %s.",
+
+	"error":          "❌ Error: %v",
+	"choice.invalid": "❌ Invalid number. Choose 1-%d",
+	"cli.only":       "❌ %s is only available in Claude CLI mode",
+
+	"status.error":            "📊 *Status*\n\n⚠️ Error getting stats: %v",
+	"status.title":            "📊 *Magabot Status*",
+	"status.system":           "🖥️ System:",
+	"status.server":           "💻 Server:",
+	"status.platforms":        "📡 Platforms:",
+	"status.sessions":         "💬 Sessions:",
+	"status.cpu":              "CPU: load %.2f / %.2f / %.2f (1/5/15m)",
+	"status.memory":           "Memory: %s / %s (%.0f%%)",
+	"status.disk":             "Disk: %s / %s (%.0f%%)",
+	"status.gpu":              "GPU: %s — %s / %s (%.0f%% mem, %d%% util)",
+	"status.provider":         "Provider: %s",
+	"status.model":            "Model: %s",
+	"status.this_chat_model":  "%s (this chat)",
+	"status.unavailable":      "⚠️ Unavailable since %s",
+	"status.in_flight":        "In flight: %d",
+	"status.in_flight_of":     "In flight: %d/%d",
+	"status.effort":           "Effort: %s",
+	"status.fallback":         "Fallback: %s",
+	"status.budget":           "Budget: $%.2f/req",
+	"status.health_ok":        "%s: ✅ %s (checked %s ago)",
+	"status.health_down":      "%s: ❌ unreachable (checked %s ago)",
+	"status.model_stat":       "%s: p50 %s, p95 %s, %.0f%% errors of %d",
+	"status.model_stat_error": " (last: %s, %s ago)",
+	"status.hourly":           "Hourly: %d reqs, %s tokens in / %s out (resets in %s)",
+	"status.weekly":           "Weekly: %d reqs, %s tokens in / %s out (resets in %s)",
+	"status.total":            "Total: %s tokens in / %s out",
+	"status.users":            "%s — %d users",
+	"status.no_activity":      "_no activity yet_",
+	"status.in_memory":        "In memory: %d",
+	"status.in_memory_of":     "In memory: %d/%d",
+	"status.chat_turns":       "This chat: %d turns",
+	"status.chat_turns_of":    "This chat: %d/%d turns",
+
+	"model.none":           "❌ No models available",
+	"model.current":        "🤖 *Current:* `%s`",
+	"model.this_chat":      "this chat: `%s`",
+	"model.effort":         "effort: %s",
+	"model.fallback":       "fallback: %s",
+	"model.available":      "📋 Available models:",
+	"model.usage":          "_This chat: /model <number|name>, /model default_\n_Everyone: /model global <number|name>_",
+	"model.global_usage":   "Usage: /model global <number|name>",
+	"model.not_found":      "❌ Model '%s' not found. Use /model to see available models.",
+	"model.other_provider": "❌ `%s` is a %s model; switch provider with /llm %s first.",
+	"model.chat_default":   "✅ This chat now uses the default model `%s`",
+	"model.chat_set":       "✅ This chat now uses `%s`. Revert with /model default",
+	"model.set":            "✅ Model switched to `%s`",
+
+	"llm.none":           "❌ No LLM providers registered",
+	"llm.current":        "🤖 *Active:* `%s`\n\n📋 Available providers:",
+	"llm.active_marker":  "← active",
+	"llm.usage":          "_Switch: /llm <number> or /llm <name>_",
+	"llm.not_found":      "❌ Provider '%s' not found. Use /llm to see available providers.",
+	"llm.already_active": "`%s` is already the active provider.",
+	"llm.switched":       "✅ Active provider switched to `%s`\n🔄 Restarting in 3 seconds...",
+
+	"effort.current": "⚡ *Effort:* `%s`\n\n1. *low* — fast, short answers\n2. *medium* — balanced (default)\n3. *high* — detailed, slower\n4. *max* — maximum (Opus only)\n\n_Set: /effort <level> or /effort <number>_\n_Reset: /effort reset_",
+	"effort.set":     "✅ Effort set to `%s`",
+	"effort.reset":   "✅ Effort reset to default",
+	"effort.invalid": "❌ Invalid effort. Options: `low` | `medium` | `high` | `max`",
+
+	"prompt.none":    "📝 *Custom prompt:* _none_\n\n_Set: /prompt <instructions>_\n_Clear: /prompt reset_",
+	"prompt.current": "📝 *Custom prompt:*\n%s\n\n_Clear: /prompt reset_",
+	"prompt.cleared": "✅ Custom prompt cleared",
+	"prompt.set":     "✅ Custom prompt set:\n_%s_",
+
+	"fallback.none":    "🔄 *Fallback model:* _none_\n\n_Set: /fallback <model>_\n_Example: /fallback claude-sonnet-4-6_",
+	"fallback.current": "🔄 *Fallback model:* `%s`\n\n_Clear: /fallback off_",
+	"fallback.cleared": "✅ Fallback model disabled",
+	"fallback.set":     "✅ Fallback model set to `%s`",
+
+	"budget.none":    "💰 *Budget:* _unlimited_\n\n_Set: /budget <amount>_ (e.g. /budget 5.00)\n_Clear: /budget off_",
+	"budget.current": "💰 *Budget:* $%.2f per request\n\n_Clear: /budget off_",
+	"budget.cleared": "✅ Budget limit removed",
+	"budget.invalid": "❌ Invalid amount. Example: `/budget 5.00`",
+	"budget.set":     "✅ Budget set to $%.2f per request",
+
+	"think.cli": "❌ Claude CLI mode: use /effort instead",
+
+	"persona.none":        "No personas configured. Add a `personas` section to config.yaml.",
+	"persona.current":     "🎭 Active persona: %s",
+	"persona.available":   "Available personas:",
+	"persona.personality": "Personality: %s",
+	"persona.usage":       "Switch: /persona <name or number>",
+	"persona.unknown":     "Unknown persona %q. Available: %s",
+	"persona.set":         "🎭 Switched to %s",
+
+	"allow.usage": "Usage: /allow <user_id>",
+
+	"restart.confirm":   "🔄 *Restart Magabot?*\nBot will restart and be briefly offline.",
+	"restart.scheduled": "✅ Restarting in 3 seconds...",
+
+	"update.check_failed": "❌ Update check failed: %v",
+	"update.none":         "✅ Already up to date! (v%s)",
+	"update.confirm":      "🔄 *Update Available*\n\n📦 %s → %s\n\n📝 %s",
+	"update.done":         "✅ Updated to %s! Restarting in 3s...",
+
+	"setting.provider_default": "provider default",
+	"setting.this_chat":        "%s (this chat)",
+	"setting.config":           "%s (config)",
+
+	"think.current":       "🧠 *Thinking:* `%s`\n\nHigher levels reason longer before answering.\n\n_Set: /think high|medium|low_\n_Reset: /think reset_",
+	"think.budget_config": "%d thinking tokens (config)",
+	"think.unsupported":   "⚠️ `%s` does not support it; the setting is ignored.",
+	"think.reset":         "✅ Thinking reset to default",
+	"think.invalid":       "❌ Invalid level. Options: `low` | `medium` | `high`",
+	"think.set":           "✅ Thinking set to `%s` for this chat",
+
+	"temp.current": "🌡 *Temperature:* `%s`\n\nLower is more focused, higher more creative.\n\n_Set: /temp <0.1-%g>_\n_Reset: /temp reset_",
+	"temp.profile": "%g (%s profile)",
+	"temp.reset":   "✅ Temperature reset to default",
+	"temp.invalid": "❌ Invalid temperature. Use a number above 0 and up to %g, e.g. `/temp 0.2`",
+	"temp.set":     "✅ Temperature set to `%g` for this chat",
+
+	"sources.disabled": "ℹ️ Answers don't use memories. Set memory.enabled in config to add them, with sources.",
+	"sources.none":     "📚 The last answer didn't cite any memories.",
+
+	"checkpoint.usage":     "Usage: /checkpoint save|load|delete <name>, /checkpoint list",
+	"checkpoint.limit":     "❌ This chat already has %d checkpoints. Delete one with /checkpoint delete <name>.",
+	"checkpoint.bad_name":  "❌ Checkpoint names must be 1-32 characters.",
+	"checkpoint.saved":     "📌 Saved checkpoint `%s` (%d messages)",
+	"checkpoint.restored":  "⏪ Restored checkpoint `%s` (%d messages)",
+	"checkpoint.not_found": "❌ No checkpoint named `%s`. See /checkpoint list.",
+	"checkpoint.deleted":   "🗑 Deleted checkpoint `%s`",
+	"checkpoint.none":      "📌 No checkpoints yet. Save one with /checkpoint save <name>.",
+	"checkpoint.list":      "📌 *Checkpoints* (%d/%d)",
+	"checkpoint.item":      "`%s` — %d messages, %s",
+
+	"feedback.usage":     "Usage: /feedback stats",
+	"feedback.off":       "_Feedback collection is off; set platforms.feedback: true to enable it._",
+	"feedback.none":      "📊 *Feedback*\n\nNo rated answers yet.",
+	"feedback.title":     "📊 *Feedback by model*",
+	"feedback.satisfied": "(%d%% satisfied)",
+	"feedback.ratings":   "%d rating(s) on %d answer(s)",
+
+	"export.usage":       "Usage: /export [--json]",
+	"export.empty":       "Nothing to export yet.",
+	"export.done":        "📤 Exported %d messages",
+	"export.send_failed": "⚠️ Saved %d messages to `%s` but could not send the file: %v",
+	"export.failed":      "❌ Export failed: %v",
+	"export.admin_only":  "🔒 Message redaction is on; only an admin can export conversations.",
+	"export.confirm":     "📤 *Export %d messages?*\nMessage redaction is on, so this writes the full transcript to disk and sends it here.",
+
+	"agent.admin_required": "Agent sessions require admin access.",
+	"agent.start_failed":   "Failed to start agent session: %v",
+	"agent.new_usage":      "Usage: :new [name] [agent] <dir>",
+	"agent.active":         "Agent session already active. Use :quit first.",
+	"agent.named_active":   "Agent session %q already active. Use :quit %s first.",
+	"agent.started":        "Agent session started: %s in %s\nSend messages to interact. Use :quit to end.",
+	"agent.named_started":  "Agent session %q started: %s in %s\nUse :send %s <message> to interact and :quit %s to end.",
+	"agent.send_usage":     "Usage: :send <name> <message>",
+	"agent.send_not_found": "No agent session named %q. Start one with :new %s <dir>.",
+	"agent.none":           "No active agent session.",
+	"agent.not_found":      "No agent session named %q.",
+	"agent.closed":         "Agent session closed.",
+	"agent.named_closed":   "Agent session %q closed.",
+	"agent.save_empty":     "Nothing to save yet.",
+	"agent.save_failed":    "❌ Save failed: %v",
+	"agent.saved":          "📤 Saved %d messages to `%s`",
+	"agent.session":        "Session: %s",
+	"agent.status":         "Agent: %s\nDirectory: %s\nMessages: %d\nDuration: %s\nIdle: %s\nIdle timeout: %s",
+	"agent.timeout":        "%s (closes in %s)",
+	"agent.timeout_off":    "disabled",
+	"agent.unknown":        "Unknown agent command: %s\nAvailable: :new, :send, :quit, :status, :save",
+
+	"start": `👋 *Hi! I'm Magabot* — your personal AI chatbot.
+
+💬 Send any message and I'll reply using AI.
+
+🎯 What I can do:
+1. 💬 Chat — ask anything, multi-turn conversation
+2. 📷 Image — send a photo, I'll analyze it (vision)
+3. 🎤 Voice — send a voice message, I'll transcribe & reply
+4. 📄 Document — send a PDF/file, I'll read & analyze it
+5. 🎨 Generate — ask me to create an image (DALL-E)
+6. 🔊 TTS — I can reply with voice messages
+7. 💭 Thinking — deep reasoning for complex questions
+
+⚡ /help — full help
+📊 /status — bot & provider status
+🔧 /config — bot configuration
+🧠 /memory — memory management`,
+
+	"help": `📖 *Magabot Help*
+
+Send any message and I'll reply using AI.
+
+💬 Commands:
+ 1. /start — Welcome message
+ 2. /status — Bot status
+ 3. /model — Current model & switch
+ 4. /llm — Switch LLM provider
+ 5. /effort — Set effort level (low/medium/high/max)
+ 6. /prompt — Custom system prompt
+ 7. /persona — Switch AI persona
+ 8. /fallback — Set fallback model
+ 9. /budget — Budget limit per request
+10. /temp — Response temperature for this chat
+11. /think — Reasoning depth for this chat (high/medium/low)
+12. /reset — Start a fresh conversation (also /clear, /new)
+13. /checkpoint — Save/load conversation branches
+14. /export — Download this conversation
+15. /lang — Response language
+16. /sources — Memories behind the last answer
+17. /help — This help
+
+🔧 Admin:
+18. /restart — Restart bot
+19. /config — Configuration
+20. /memory — Memory management
+21. /task — Background tasks
+22. /feedback stats — Answer ratings by model
+23. /allow — Allow a new user
+
+🤖 Agent Sessions:
+• :new [agent] <dir> — Start coding agent
+• :new <name> [agent] <dir> — Start another, named agent
+• :send <name> <msg> — Message a named agent
+• :quit [name] — Close session
+• :status — Session info
+• :save [name] — Save session transcript`,
+}
+
+// id is the Indonesian bundle.
+var id = Bundle{
+	"admin.required":   "🔒 Perlu akses admin.",
+	"command.unknown":  "❓ Perintah tidak dikenal. Coba /help",
+	"command.denied":   "🔒 Kamu tidak punya izin untuk memakai %s.",
+	"command.disabled": "🚫 %s dinonaktifkan di bot ini.",
+	"confirm.none":     "Tidak ada aksi yang menunggu konfirmasi.",
+	"cancel.none":      "Tidak ada aksi yang bisa dibatalkan.",
+	"welcome.first":    "👋 *Selamat datang!* Ini percakapan pertama kita.\nKetik /help untuk melihat semua fitur.\n\n",
+
+	"lang.current":     "🌐 Bahasa: %s\n\nTersedia: %s\n\nGanti: /lang <kode>",
+	"lang.set":         "✅ Bahasa diganti ke %s",
+	"lang.unsupported": "Bahasa %q tidak didukung. Tersedia: %s",
+
+	"image.too_large": "⚠️ %s terlalu besar untuk disertakan, jadi saya lewati.",
+	"image.invalid":   "⚠️ %s bukan gambar yang valid, jadi saya lewati.",
+
+	"session.reset":          "🗑 Riwayat percakapan dihapus.",
+	"session.reset_db_error": "⚠️ Riwayat dihapus dari memori, tapi database gagal: %v",
+	"session.auto_reset":     "🔄 _Percakapan ini sudah %d giliran, jadi saya mulai yang baru. Kirim /reset untuk melakukannya kapan saja._\n\n",
+
+	"llm.truncated": "✂️ _(respons terpotong: mencapai batas token keluaran)_",
+
+	"moderation.blocked": "🚫 Saya tidak bisa membantu dengan pesan itu.",
+
+	"quota.exceeded": "⏳ Kamu sudah mencapai batas harian. Batas direset pukul %s.",
+
+	"error":          "❌ Galat: %v",
+	"choice.invalid": "❌ Nomor tidak valid. Pilih 1-%d",
+	"cli.only":       "❌ %s hanya tersedia di mode Claude CLI",
+
+	"status.error":            "📊 *Status*\n\n⚠️ Gagal mengambil statistik: %v",
+	"status.title":            "📊 *Status Magabot*",
+	"status.system":           "🖥️ Sistem:",
+	"status.server":           "💻 Server:",
+	"status.platforms":        "📡 Platform:",
+	"status.sessions":         "💬 Sesi:",
+	"status.cpu":              "CPU: beban %.2f / %.2f / %.2f (1/5/15m)",
+	"status.memory":           "Memori: %s / %s (%.0f%%)",
+	"status.disk":             "Disk: %s / %s (%.0f%%)",
+	"status.gpu":              "GPU: %s — %s / %s (memori %.0f%%, pemakaian %d%%)",
+	"status.provider":         "Penyedia: %s",
+	"status.model":            "Model: %s",
+	"status.this_chat_model":  "%s (chat ini)",
+	"status.unavailable":      "⚠️ Tidak tersedia sejak %s",
+	"status.in_flight":        "Sedang diproses: %d",
+	"status.in_flight_of":     "Sedang diproses: %d/%d",
+	"status.effort":           "Effort: %s",
+	"status.fallback":         "Cadangan: %s",
+	"status.budget":           "Anggaran: $%.2f/permintaan",
+	"status.health_ok":        "%s: ✅ %s (dicek %s lalu)",
+	"status.health_down":      "%s: ❌ tidak terjangkau (dicek %s lalu)",
+	"status.model_stat":       "%s: p50 %s, p95 %s, %.0f%% galat dari %d",
+	"status.model_stat_error": " (terakhir: %s, %s lalu)",
+	"status.hourly":           "Per jam: %d permintaan, %s token masuk / %s keluar (reset dalam %s)",
+	"status.weekly":           "Per minggu: %d permintaan, %s token masuk / %s keluar (reset dalam %s)",
+	"status.total":            "Total: %s token masuk / %s keluar",
+	"status.users":            "%s — %d pengguna",
+	"status.no_activity":      "_belum ada aktivitas_",
+	"status.in_memory":        "Di memori: %d",
+	"status.in_memory_of":     "Di memori: %d/%d",
+	"status.chat_turns":       "Chat ini: %d giliran",
+	"status.chat_turns_of":    "Chat ini: %d/%d giliran",
+
+	"model.none":           "❌ Tidak ada model yang tersedia",
+	"model.current":        "🤖 *Saat ini:* `%s`",
+	"model.this_chat":      "chat ini: `%s`",
+	"model.effort":         "effort: %s",
+	"model.fallback":       "cadangan: %s",
+	"model.available":      "📋 Model yang tersedia:",
+	"model.usage":          "_Chat ini: /model <nomor|nama>, /model default_\n_Semua orang: /model global <nomor|nama>_",
+	"model.global_usage":   "Cara pakai: /model global <nomor|nama>",
+	"model.not_found":      "❌ Model '%s' tidak ditemukan. Pakai /model untuk melihat model yang tersedia.",
+	"model.other_provider": "❌ `%s` adalah model %s; ganti penyedia dengan /llm %s dulu.",
+	"model.chat_default":   "✅ Chat ini sekarang memakai model bawaan `%s`",
+	"model.chat_set":       "✅ Chat ini sekarang memakai `%s`. Kembalikan dengan /model default",
+	"model.set":            "✅ Model diganti ke `%s`",
+
+	"llm.none":           "❌ Tidak ada penyedia LLM yang terdaftar",
+	"llm.current":        "🤖 *Aktif:* `%s`\n\n📋 Penyedia yang tersedia:",
+	"llm.active_marker":  "← aktif",
+	"llm.usage":          "_Ganti: /llm <nomor> atau /llm <nama>_",
+	"llm.not_found":      "❌ Penyedia '%s' tidak ditemukan. Pakai /llm untuk melihat penyedia yang tersedia.",
+	"llm.already_active": "`%s` sudah menjadi penyedia aktif.",
+	"llm.switched":       "✅ Penyedia aktif diganti ke `%s`\n🔄 Memulai ulang dalam 3 detik...",
+
+	"effort.current": "⚡ *Effort:* `%s`\n\n1. *low* — cepat, jawaban singkat\n2. *medium* — seimbang (bawaan)\n3. *high* — rinci, lebih lambat\n4. *max* — maksimum (hanya Opus)\n\n_Atur: /effort <level> atau /effort <nomor>_\n_Reset: /effort reset_",
+	"effort.set":     "✅ Effort diatur ke `%s`",
+	"effort.reset":   "✅ Effort dikembalikan ke bawaan",
+	"effort.invalid": "❌ Effort tidak valid. Pilihan: `low` | `medium` | `high` | `max`",
+
+	"prompt.none":    "📝 *Prompt kustom:* _tidak ada_\n\n_Atur: /prompt <instruksi>_\n_Hapus: /prompt reset_",
+	"prompt.current": "📝 *Prompt kustom:*\n%s\n\n_Hapus: /prompt reset_",
+	"prompt.cleared": "✅ Prompt kustom dihapus",
+	"prompt.set":     "✅ Prompt kustom diatur:\n_%s_",
+
+	"fallback.none":    "🔄 *Model cadangan:* _tidak ada_\n\n_Atur: /fallback <model>_\n_Contoh: /fallback claude-sonnet-4-6_",
+	"fallback.current": "🔄 *Model cadangan:* `%s`\n\n_Hapus: /fallback off_",
+	"fallback.cleared": "✅ Model cadangan dinonaktifkan",
+	"fallback.set":     "✅ Model cadangan diatur ke `%s`",
+
+	"budget.none":    "💰 *Anggaran:* _tanpa batas_\n\n_Atur: /budget <jumlah>_ (mis. /budget 5.00)\n_Hapus: /budget off_",
+	"budget.current": "💰 *Anggaran:* $%.2f per permintaan\n\n_Hapus: /budget off_",
+	"budget.cleared": "✅ Batas anggaran dihapus",
+	"budget.invalid": "❌ Jumlah tidak valid. Contoh: `/budget 5.00`",
+	"budget.set":     "✅ Anggaran diatur ke $%.2f per permintaan",
+
+	"think.cli": "❌ Mode Claude CLI: pakai /effort",
+
+	"persona.none":        "Belum ada persona. Tambahkan bagian `personas` di config.yaml.",
+	"persona.current":     "🎭 Persona aktif: %s",
+	"persona.available":   "Persona yang tersedia:",
+	"persona.personality": "Kepribadian: %s",
+	"persona.usage":       "Ganti: /persona <nama atau nomor>",
+	"persona.unknown":     "Persona %q tidak dikenal. Tersedia: %s",
+	"persona.set":         "🎭 Beralih ke %s",
+
+	"allow.usage": "Cara pakai: /allow <user_id>",
+
+	"restart.confirm":   "🔄 *Mulai ulang Magabot?*\nBot akan dimulai ulang dan offline sebentar.",
+	"restart.scheduled": "✅ Memulai ulang dalam 3 detik...",
+
+	"update.check_failed": "❌ Gagal memeriksa pembaruan: %v",
+	"update.none":         "✅ Sudah versi terbaru! (v%s)",
+	"update.confirm":      "🔄 *Pembaruan Tersedia*\n\n📦 %s → %s\n\n📝 %s",
+	"update.done":         "✅ Diperbarui ke %s! Memulai ulang dalam 3 detik...",
+
+	"setting.provider_default": "bawaan penyedia",
+	"setting.this_chat":        "%s (chat ini)",
+	"setting.config":           "%s (konfigurasi)",
+
+	"think.current":       "🧠 *Berpikir:* `%s`\n\nLevel lebih tinggi bernalar lebih lama sebelum menjawab.\n\n_Atur: /think high|medium|low_\n_Reset: /think reset_",
+	"think.budget_config": "%d token berpikir (konfigurasi)",
+	"think.unsupported":   "⚠️ `%s` tidak mendukungnya; pengaturan ini diabaikan.",
+	"think.reset":         "✅ Berpikir dikembalikan ke bawaan",
+	"think.invalid":       "❌ Level tidak valid. Pilihan: `low` | `medium` | `high`",
+	"think.set":           "✅ Berpikir diatur ke `%s` untuk chat ini",
+
+	"temp.current": "🌡 *Temperatur:* `%s`\n\nLebih rendah lebih fokus, lebih tinggi lebih kreatif.\n\n_Atur: /temp <0.1-%g>_\n_Reset: /temp reset_",
+	"temp.profile": "%g (profil %s)",
+	"temp.reset":   "✅ Temperatur dikembalikan ke bawaan",
+	"temp.invalid": "❌ Temperatur tidak valid. Pakai angka di atas 0 sampai %g, mis. `/temp 0.2`",
+	"temp.set":     "✅ Temperatur diatur ke `%g` untuk chat ini",
+
+	"sources.disabled": "ℹ️ Jawaban tidak memakai memori. Atur memory.enabled di konfigurasi untuk menambahkannya, beserta sumbernya.",
+	"sources.none":     "📚 Jawaban terakhir tidak mengutip memori apa pun.",
+
+	"checkpoint.usage":     "Cara pakai: /checkpoint save|load|delete <nama>, /checkpoint list",
+	"checkpoint.limit":     "❌ Chat ini sudah punya %d checkpoint. Hapus satu dengan /checkpoint delete <nama>.",
+	"checkpoint.bad_name":  "❌ Nama checkpoint harus 1-32 karakter.",
+	"checkpoint.saved":     "📌 Checkpoint `%s` disimpan (%d pesan)",
+	"checkpoint.restored":  "⏪ Checkpoint `%s` dipulihkan (%d pesan)",
+	"checkpoint.not_found": "❌ Tidak ada checkpoint bernama `%s`. Lihat /checkpoint list.",
+	"checkpoint.deleted":   "🗑 Checkpoint `%s` dihapus",
+	"checkpoint.none":      "📌 Belum ada checkpoint. Simpan dengan /checkpoint save <nama>.",
+	"checkpoint.list":      "📌 *Checkpoint* (%d/%d)",
+	"checkpoint.item":      "`%s` — %d pesan, %s",
+
+	"feedback.usage":     "Cara pakai: /feedback stats",
+	"feedback.off":       "_Pengumpulan umpan balik mati; atur platforms.feedback: true untuk menyalakannya._",
+	"feedback.none":      "📊 *Umpan balik*\n\nBelum ada jawaban yang dinilai.",
+	"feedback.title":     "📊 *Umpan balik per model*",
+	"feedback.satisfied": "(%d%% puas)",
+	"feedback.ratings":   "%d penilaian untuk %d jawaban",
+
+	"export.usage":       "Cara pakai: /export [--json]",
+	"export.empty":       "Belum ada yang bisa diekspor.",
+	"export.done":        "📤 %d pesan diekspor",
+	"export.send_failed": "⚠️ %d pesan disimpan ke `%s`, tapi file gagal dikirim: %v",
+	"export.failed":      "❌ Ekspor gagal: %v",
+	"export.admin_only":  "🔒 Redaksi pesan aktif; hanya admin yang bisa mengekspor percakapan.",
+	"export.confirm":     "📤 *Ekspor %d pesan?*\nRedaksi pesan aktif, jadi ini menulis transkrip lengkap ke disk dan mengirimnya ke sini.",
+
+	"agent.admin_required": "Sesi agen perlu akses admin.",
+	"agent.start_failed":   "Gagal memulai sesi agen: %v",
+	"agent.new_usage":      "Cara pakai: :new [nama] [agen] <dir>",
+	"agent.active":         "Sesi agen sudah aktif. Pakai :quit dulu.",
+	"agent.named_active":   "Sesi agen %q sudah aktif. Pakai :quit %s dulu.",
+	"agent.started":        "Sesi agen dimulai: %s di %s\nKirim pesan untuk berinteraksi. Pakai :quit untuk mengakhiri.",
+	"agent.named_started":  "Sesi agen %q dimulai: %s di %s\nPakai :send %s <pesan> untuk berinteraksi dan :quit %s untuk mengakhiri.",
+	"agent.send_usage":     "Cara pakai: :send <nama> <pesan>",
+	"agent.send_not_found": "Tidak ada sesi agen bernama %q. Mulai dengan :new %s <dir>.",
+	"agent.none":           "Tidak ada sesi agen yang aktif.",
+	"agent.not_found":      "Tidak ada sesi agen bernama %q.",
+	"agent.closed":         "Sesi agen ditutup.",
+	"agent.named_closed":   "Sesi agen %q ditutup.",
+	"agent.save_empty":     "Belum ada yang bisa disimpan.",
+	"agent.save_failed":    "❌ Gagal menyimpan: %v",
+	"agent.saved":          "📤 %d pesan disimpan ke `%s`",
+	"agent.session":        "Sesi: %s",
+	"agent.status":         "Agen: %s\nDirektori: %s\nPesan: %d\nDurasi: %s\nDiam: %s\nBatas diam: %s",
+	"agent.timeout":        "%s (ditutup dalam %s)",
+	"agent.timeout_off":    "nonaktif",
+	"agent.unknown":        "Perintah agen tidak dikenal: %s\nTersedia: :new, :send, :quit, :status, :save",
+
+	"start": `👋 *Halo! Saya Magabot* — chatbot AI pribadimu.
+
+💬 Kirim pesan apa saja dan saya akan membalas dengan AI.
+
+🎯 Yang bisa saya lakukan:
+1. 💬 Chat — tanya apa saja, percakapan berlanjut
+2. 📷 Gambar — kirim foto, saya analisis (vision)
+3. 🎤 Suara — kirim pesan suara, saya transkrip & balas
+4. 📄 Dokumen — kirim PDF/file, saya baca & analisis
+5. 🎨 Buat gambar — minta saya membuat gambar (DALL-E)
+6. 🔊 TTS — saya bisa membalas dengan pesan suara
+7. 💭 Berpikir — penalaran mendalam untuk pertanyaan rumit
+
+⚡ /help — bantuan lengkap
+📊 /status — status bot & provider
+🔧 /config — konfigurasi bot
+🧠 /memory — kelola memori`,
+
+	"help": `📖 *Bantuan Magabot*
+
+Kirim pesan apa saja dan saya akan membalas dengan AI.
+
+💬 Perintah:
+ 1. /start — Pesan sambutan
+ 2. /status — Status bot
+ 3. /model — Model aktif & ganti model
+ 4. /llm — Ganti provider LLM
+ 5. /effort — Atur tingkat effort (low/medium/high/max)
+ 6. /prompt — System prompt kustom
+ 7. /persona — Ganti persona AI
+ 8. /fallback — Atur model cadangan
+ 9. /budget — Batas biaya per permintaan
+10. /temp — Temperature balasan untuk chat ini
+11. /think — Kedalaman penalaran untuk chat ini (high/medium/low)
+12. /reset — Mulai percakapan baru (juga /clear, /new)
+13. /checkpoint — Simpan/muat cabang percakapan
+14. /export — Unduh percakapan ini
+15. /lang — Bahasa balasan
+16. /sources — Memori sumber jawaban terakhir
+17. /help — Bantuan ini
+
+🔧 Admin:
+18. /restart — Restart bot
+19. /config — Konfigurasi
+20. /memory — Kelola memori
+21. /task — Tugas latar belakang
+22. /feedback stats — Rating jawaban per model
+23. /allow — Izinkan pengguna baru
+
+🤖 Sesi Agent:
+• :new [agent] <dir> — Mulai agent coding
+• :new <nama> [agent] <dir> — Mulai agent lain dengan nama
+• :send <nama> <pesan> — Kirim pesan ke agent bernama
+• :quit [nama] — Tutup sesi
+• :status — Info sesi
+• :save [nama] — Simpan transkrip sesi`,
+}
@@ -0,0 +1,59 @@
+// Package i18n holds the bot's built-in response strings per language
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Default is the language used when neither the user nor bot.language picks
+// a supported one, and for keys missing from another bundle.
+const Default = "en"
+
+// Bundle maps message keys to text. Text may contain fmt verbs filled from
+// the arguments passed to T.
+type Bundle map[string]string
+
+var bundles = map[string]Bundle{
+	"en": en,
+	"id": id,
+}
+
+// Languages returns the supported language codes, sorted.
+func Languages() []string {
+	codes := make([]string, 0, len(bundles))
+	for code := range bundles {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Match maps a language tag such as "id", "id-ID" or "en_US" to a supported
+// code.
+func Match(tag string) (string, bool) {
+	code := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	if _, ok := bundles[code]; ok {
+		return code, true
+	}
+	return "", false
+}
+
+// T returns the text for key in lang, falling back to the default language
+// and then to the key itself.
+func T(lang, key string, args ...any) string {
+	text, ok := bundles[lang][key]
+	if !ok {
+		if text, ok = bundles[Default][key]; !ok {
+			text = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
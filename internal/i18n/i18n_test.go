@@ -0,0 +1,73 @@
+package i18n
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{"en", "en", true},
+		{"id-ID", "id", true},
+		{"EN_us", "en", true},
+		{"fr", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Match(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Match(%q) = %q, %v; want %q, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T("id", "admin.required"); !strings.Contains(got, "admin") || got == T("en", "admin.required") {
+		t.Errorf("id admin.required = %q", got)
+	}
+	if got := T("en", "lang.set", "id"); got != "✅ Language set to id" {
+		t.Errorf("formatted text = %q", got)
+	}
+	if got := T("fr", "command.unknown"); got != T(Default, "command.unknown") {
+		t.Errorf("unknown language should fall back to default, got %q", got)
+	}
+	if got := T("en", "no.such.key"); got != "no.such.key" {
+		t.Errorf("missing key = %q, want the key", got)
+	}
+}
+
+func TestBundlesComplete(t *testing.T) {
+	for _, lang := range Languages() {
+		for key := range bundles[Default] {
+			if _, ok := bundles[lang][key]; !ok {
+				t.Errorf("%s bundle missing %q", lang, key)
+			}
+		}
+		for key := range bundles[lang] {
+			if _, ok := bundles[Default][key]; !ok {
+				t.Errorf("%s bundle has %q, which %s lacks", lang, key, Default)
+			}
+		}
+	}
+}
+
+// verb matches a fmt verb, so translations can be checked to take the same
+// arguments as the default text.
+var verb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestBundlesSameVerbs(t *testing.T) {
+	for _, lang := range Languages() {
+		for key, text := range bundles[lang] {
+			got := strings.Join(verb.FindAllString(text, -1), " ")
+			want := strings.Join(verb.FindAllString(bundles[Default][key], -1), " ")
+			if got != want {
+				t.Errorf("%s %q has verbs %q, %s has %q", lang, key, got, Default, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Side-by-side provider benchmarking with estimated costs
+package llm
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// modelPrices maps model name prefixes to list prices in USD per million
+// input and output tokens. More specific prefixes come first; prices change,
+// so costs computed from them are estimates.
+var modelPrices = []struct {
+	prefix        string
+	input, output float64
+}{
+	{"claude-opus-4-5", 5, 25},
+	{"claude-opus-4-6", 5, 25},
+	{"claude-opus-4", 15, 75},
+	{"claude-sonnet-4", 3, 15},
+	{"claude-3-7-sonnet", 3, 15},
+	{"claude-3-5-sonnet", 3, 15},
+	{"claude-haiku-4", 1, 5},
+	{"claude-3-5-haiku", 0.8, 4},
+	{"gpt-5-nano", 0.05, 0.4},
+	{"gpt-5-mini", 0.25, 2},
+	{"gpt-5", 1.25, 10},
+	{"gpt-4.1-nano", 0.1, 0.4},
+	{"gpt-4.1-mini", 0.4, 1.6},
+	{"gpt-4.1", 2, 8},
+	{"gpt-4o-mini", 0.15, 0.6},
+	{"gpt-4o", 2.5, 10},
+	{"o1-mini", 1.1, 4.4},
+	{"o1", 15, 60},
+	{"o3-mini", 1.1, 4.4},
+	{"o3", 2, 8},
+	{"o4-mini", 1.1, 4.4},
+	{"deepseek-chat", 0.27, 1.1},
+	{"deepseek-reasoner", 0.55, 2.19},
+}
+
+// EstimateCost returns the list-price cost in USD of a call to model, and
+// false when the model's price is unknown. Vendor prefixes are ignored.
+func EstimateCost(model string, inputTokens, outputTokens int) (float64, bool) {
+	model = bareModel(model)
+	for _, p := range modelPrices {
+		if strings.HasPrefix(model, p.prefix) {
+			return (float64(inputTokens)*p.input + float64(outputTokens)*p.output) / 1e6, true
+		}
+	}
+	return 0, false
+}
+
+// BenchResult is one provider's answer to a Bench prompt.
+type BenchResult struct {
+	Provider     string
+	Model        string
+	Latency      time.Duration
+	InputTokens  int
+	OutputTokens int
+	Cost         float64 // estimated USD; valid when CostKnown
+	CostKnown    bool
+	Response     string
+	Err          error
+}
+
+// Bench sends prompt to every available provider at once, each bounded by
+// timeout, and returns their results: answers fastest first, then failures
+// by provider name. It bypasses the rate limiter, the system prompt and
+// usage tracking, so it measures the providers alone.
+func (r *Router) Bench(ctx context.Context, prompt string, timeout time.Duration) []BenchResult {
+	r.mu.RLock()
+	clients := make(map[string]*allm.Client, len(r.clients))
+	for k, v := range r.clients {
+		clients[k] = v
+	}
+	r.mu.RUnlock()
+
+	var (
+		mu      sync.Mutex
+		results []BenchResult
+		wg      sync.WaitGroup
+	)
+	for name, client := range clients {
+		if !client.Provider().Available() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := benchClient(ctx, client, prompt, timeout)
+			res.Provider = name
+			mu.Lock()
+			results = append(results, res)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err == nil
+		}
+		if a.Err == nil && a.Latency != b.Latency {
+			return a.Latency < b.Latency
+		}
+		return a.Provider < b.Provider
+	})
+	return results
+}
+
+// benchClient runs one Bench call against client.
+func benchClient(ctx context.Context, client *allm.Client, prompt string, timeout time.Duration) BenchResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	res := BenchResult{Model: client.Model()}
+	start := time.Now()
+	resp, err := client.Complete(ctx, prompt)
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if resp.Model != "" {
+		res.Model = resp.Model
+	}
+	res.InputTokens, res.OutputTokens = resp.InputTokens, resp.OutputTokens
+	res.Response = resp.Content
+	res.Cost, res.CostKnown = EstimateCost(res.Model, resp.InputTokens, resp.OutputTokens)
+	return res
+}
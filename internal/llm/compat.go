@@ -0,0 +1,120 @@
+// Generic compatible providers (OpenRouter, Together, Groq, Fireworks, ...)
+package llm
+
+import (
+	"fmt"
+
+	"github.com/kusa/magabot/internal/util"
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/provider"
+)
+
+// Wire formats a compatible endpoint can speak.
+const (
+	FormatOpenAI    = "openai"    // OpenAI chat completions
+	FormatAnthropic = "anthropic" // Anthropic messages
+)
+
+// CompatibleConfig configures a hosted endpoint that speaks the OpenAI or
+// Anthropic wire format.
+type CompatibleConfig struct {
+	Name        string   // user-chosen provider name, used for registration and display
+	Format      string   // FormatOpenAI (default) or FormatAnthropic
+	BaseURL     string   // e.g. https://openrouter.ai/api/v1
+	APIKey      string   // #nosec G117 -- config field
+	APIKeys     []string // #nosec G117 -- extra keys, rotated when one is rate limited
+	Model       string
+	MaxTokens   int
+	Temperature float64
+}
+
+// keys returns APIKey followed by APIKeys.
+func (cfg CompatibleConfig) keys() []string {
+	keys := cfg.APIKeys
+	if cfg.APIKey != "" || len(keys) == 0 {
+		keys = append([]string{cfg.APIKey}, keys...)
+	}
+	return keys
+}
+
+// validate checks the name and base URL. The base URL is validated as a
+// cloud URL (localhost and private IPs are blocked); self-hosted servers
+// should use the Local provider instead.
+func (cfg CompatibleConfig) validate(kind string) error {
+	if cfg.Name == "" {
+		return fmt.Errorf("%s provider: name is required", kind)
+	}
+	if cfg.BaseURL == "" {
+		return fmt.Errorf("%s provider %s: base_url is required", kind, cfg.Name)
+	}
+	if err := util.ValidateBaseURL(cfg.BaseURL); err != nil {
+		return fmt.Errorf("invalid base URL for %s: %w", cfg.Name, err)
+	}
+	return nil
+}
+
+// NewCompatible creates a client for cfg.BaseURL in the wire format named
+// by cfg.Format.
+func NewCompatible(cfg CompatibleConfig, opts ...allm.Option) (*allm.Client, error) {
+	switch cfg.Format {
+	case "", FormatOpenAI:
+		return NewOpenAICompatible(cfg, opts...)
+	case FormatAnthropic:
+		return NewAnthropicCompatible(cfg, opts...)
+	default:
+		return nil, fmt.Errorf("provider %s: unknown format %q (want %s or %s)", cfg.Name, cfg.Format, FormatOpenAI, FormatAnthropic)
+	}
+}
+
+// NewOpenAICompatible creates a client for an OpenAI-compatible endpoint.
+func NewOpenAICompatible(cfg CompatibleConfig, opts ...allm.Option) (*allm.Client, error) {
+	if err := cfg.validate("openai-compatible"); err != nil {
+		return nil, err
+	}
+
+	compatOpts := []provider.CompatOption{
+		provider.WithBaseURL(cfg.BaseURL),
+		provider.WithDefaultModel(cfg.Model),
+	}
+	if cfg.MaxTokens > 0 {
+		compatOpts = append(compatOpts, provider.WithMaxTokens(cfg.MaxTokens))
+	}
+	if cfg.Temperature > 0 {
+		compatOpts = append(compatOpts, provider.WithTemperature(cfg.Temperature))
+	}
+
+	p := PooledProvider(cfg.keys(), 0, func(key string) *provider.OpenAICompatibleProvider {
+		return provider.OpenAICompatible(allm.ProviderName(cfg.Name), key, compatOpts...)
+	})
+	return allm.New(p, opts...), nil
+}
+
+// NewAnthropicCompatible creates a client for an Anthropic-compatible
+// endpoint. An API key is required, so the Anthropic key from the
+// environment is never sent to a third-party endpoint.
+func NewAnthropicCompatible(cfg CompatibleConfig, opts ...allm.Option) (*allm.Client, error) {
+	if err := cfg.validate("anthropic-compatible"); err != nil {
+		return nil, err
+	}
+	if cfg.APIKey == "" && len(cfg.APIKeys) == 0 {
+		return nil, fmt.Errorf("anthropic-compatible provider %s: api_key is required", cfg.Name)
+	}
+
+	anthropicOpts := []provider.AnthropicOption{
+		provider.WithAnthropicBaseURL(cfg.BaseURL),
+	}
+	if cfg.Model != "" {
+		anthropicOpts = append(anthropicOpts, provider.WithAnthropicModel(cfg.Model))
+	}
+	if cfg.MaxTokens > 0 {
+		anthropicOpts = append(anthropicOpts, provider.WithAnthropicMaxTokens(cfg.MaxTokens))
+	}
+	if cfg.Temperature > 0 {
+		anthropicOpts = append(anthropicOpts, provider.WithAnthropicTemperature(cfg.Temperature))
+	}
+
+	p := PooledProvider(cfg.keys(), 0, func(key string) *provider.AnthropicProvider {
+		return provider.Anthropic(key, anthropicOpts...)
+	})
+	return allm.New(p, opts...), nil
+}
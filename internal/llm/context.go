@@ -0,0 +1,151 @@
+// Model context windows and token budgeting before a request is sent
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// contextWindows maps model name prefixes to context windows in tokens.
+// More specific prefixes come first; models not listed are not checked.
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude-", 200_000},
+	{"opus", 200_000}, // Claude CLI aliases
+	{"sonnet", 200_000},
+	{"haiku", 200_000},
+	{"gpt-5", 400_000},
+	{"gpt-4.1", 1_047_576},
+	{"gpt-4o", 128_000},
+	{"o1", 200_000},
+	{"o3", 200_000},
+	{"o4-mini", 200_000},
+	{"glm-4.6", 200_000},
+	{"glm-4.7", 200_000},
+	{"glm-4", 128_000},
+	{"kimi-k2", 131_072},
+	{"moonshot-v1-8k", 8_192},
+	{"moonshot-v1-32k", 32_768},
+	{"moonshot-v1", 131_072},
+	{"minimax-m2", 204_800},
+	{"llama-3.1", 128_000},
+	{"llama-3.3", 128_000},
+}
+
+// ContextWindow returns the context window of model in tokens, or 0 when
+// unknown. Vendor prefixes such as "openai/" (OpenRouter) are ignored.
+func ContextWindow(model string) int {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, w := range contextWindows {
+		if strings.HasPrefix(model, w.prefix) {
+			return w.tokens
+		}
+	}
+	return 0
+}
+
+// contextWindow returns the configured context window for model, falling
+// back to ContextWindow.
+func (r *Router) contextWindow(model string) int {
+	if n := r.contextWindows[model]; n > 0 {
+		return n
+	}
+	return ContextWindow(model)
+}
+
+const (
+	// messageOverheadTokens covers role markers and separators per message.
+	messageOverheadTokens = 4
+	// imageTokens is a rough cost of one image, which providers bill by size.
+	imageTokens = 1_600
+)
+
+// estimateTokens counts the prompt tokens of messages with tok. Role
+// markers and images are estimated; the reserve in fitContext absorbs the
+// error.
+func estimateTokens(tok Tokenizer, messages []allm.Message) int {
+	total := 0
+	for _, m := range messages {
+		total += messageOverheadTokens + tok.CountTokens(m.Content)
+		total += len(m.Images) * imageTokens
+	}
+	return total
+}
+
+// ContextError reports a prompt that does not fit the model's context
+// window, or max_input_tokens, even after trimming history. It matches
+// ErrInputTooLong.
+type ContextError struct {
+	Model    string
+	Tokens   int  // estimated prompt tokens
+	Window   int  // the limit exceeded: the model's context window, or max_input_tokens
+	MaxInput bool // Window is max_input_tokens
+}
+
+func (e *ContextError) Error() string {
+	if e.MaxInput {
+		return fmt.Sprintf("%v: about %d tokens, over the %d-token input limit",
+			ErrInputTooLong, e.Tokens, e.Window)
+	}
+	return fmt.Sprintf("%v: about %d tokens, over the %d-token context window of %s",
+		ErrInputTooLong, e.Tokens, e.Window, e.Model)
+}
+
+func (e *ContextError) Unwrap() error { return ErrInputTooLong }
+
+// fitContext drops the oldest history until messages fit model's context
+// window, keeping a tenth of it free for the reply and estimation error, and
+// the input limit (max_input_tokens), whichever is smaller. Tokens are
+// counted with the model's tokenizer. The system prompt and the last message
+// are always kept; if they alone are too big it returns a *ContextError.
+func (r *Router) fitContext(model string, messages []allm.Message) ([]allm.Message, error) {
+	window := r.contextWindow(model)
+	budget := window - window/10
+	limitedByInput := r.maxInput > 0 && (window == 0 || r.maxInput < budget)
+	if limitedByInput {
+		budget = r.maxInput
+	}
+	if budget <= 0 {
+		return messages, nil
+	}
+
+	tok := r.tokenizer(model)
+	tokens := estimateTokens(tok, messages)
+	if tokens <= budget {
+		return messages, nil
+	}
+
+	var system []allm.Message
+	history := messages
+	if len(history) > 0 && history[0].Role == "system" {
+		system, history = history[:1], history[1:]
+	}
+	dropped := 0
+	for len(history) > 1 && tokens > budget {
+		tokens -= estimateTokens(tok, history[:1])
+		history = history[1:]
+		dropped++
+	}
+	if tokens > budget {
+		if limitedByInput {
+			return nil, &ContextError{Model: model, Tokens: tokens, Window: r.maxInput, MaxInput: true}
+		}
+		return nil, &ContextError{Model: model, Tokens: tokens, Window: window}
+	}
+
+	r.logger.Info("trimmed conversation history to fit context window",
+		"model", model,
+		"context_window", window,
+		"max_input", r.maxInput,
+		"estimated_tokens", tokens,
+		"dropped", dropped,
+	)
+	return append(append(make([]allm.Message, 0, len(system)+len(history)), system...), history...), nil
+}
@@ -0,0 +1,80 @@
+// Detecting and continuing answers cut off at the output token limit
+package llm
+
+// Finish reasons StreamRequest sets on Request.FinishReason.
+const (
+	FinishStop   = "stop"   // the model ended its answer
+	FinishLength = "length" // the answer hit the output token limit
+)
+
+// ContinuePrompt asks the model for the rest of an answer that was cut off.
+const ContinuePrompt = "Your previous answer was cut off. Continue exactly where it stopped, without repeating anything or adding an introduction."
+
+// Truncated reports whether a provider's finish reason, as in
+// Response.FinishReason, means the output token limit cut the answer off:
+// "length" from OpenAI-compatible APIs, "max_tokens" from Anthropic.
+func Truncated(reason string) bool {
+	return reason == FinishLength || reason == "max_tokens"
+}
+
+// finishReason maps a provider's finish reason to FinishLength or
+// FinishStop, or "" when the provider reported none.
+func finishReason(reason string) string {
+	switch {
+	case reason == "":
+		return ""
+	case Truncated(reason):
+		return FinishLength
+	default:
+		return FinishStop
+	}
+}
+
+// streamFinishReason infers why a stream ended, for answers whose
+// provider's finish reason is unknown: streamed chunks don't carry it. An
+// answer that used its whole output token limit counts as cut off. limit
+// is 0 when unknown.
+func streamFinishReason(usage *StreamUsage, limit int) string {
+	if usage != nil && limit > 0 && usage.OutputTokens >= limit {
+		return FinishLength
+	}
+	return FinishStop
+}
+
+// Continuation returns a copy of req that asks for the rest of partial, the
+// answer req got before it was cut off.
+func (req *Request) Continuation(partial string) *Request {
+	next := *req
+	next.FinishReason = ""
+	next.Messages = make([]Message, 0, len(req.Messages)+2)
+	next.Messages = append(next.Messages, req.Messages...)
+	next.Messages = append(next.Messages,
+		Message{Role: "assistant", Content: partial},
+		Message{Role: "user", Content: ContinuePrompt},
+	)
+	return &next
+}
+
+// SetMaxTokens records the output token limit a provider is configured
+// with, which StreamRequest needs to tell a cut-off answer from a finished
+// one when the request sets none. 0 forgets it.
+func (r *Router) SetMaxTokens(provider string, tokens int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if tokens > 0 {
+		r.maxTokens[provider] = tokens
+	} else {
+		delete(r.maxTokens, provider)
+	}
+}
+
+// outputLimit returns the output token limit req runs with on provider, or
+// 0 when unknown.
+func (r *Router) outputLimit(provider string, req *Request) int {
+	if req.MaxTokens > 0 {
+		return req.MaxTokens
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.maxTokens[provider]
+}
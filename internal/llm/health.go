@@ -0,0 +1,199 @@
+// Periodic provider health probing with cached results
+package llm
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// HealthChecker is an optional interface providers can implement to supply a
+// cheaper or more accurate probe than the default models-list ping.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// probeTimeout bounds one provider's health probe.
+const probeTimeout = 10 * time.Second
+
+// errNoProbe reports a provider that can't be checked without a billable
+// completion.
+var errNoProbe = errors.New("no free health probe")
+
+// ProviderHealth is the cached result of the last probe for a provider.
+type ProviderHealth struct {
+	OK        bool
+	Latency   time.Duration
+	Error     error
+	CheckedAt time.Time
+	// NoProbe marks a provider with neither a HealthCheck nor model
+	// listing. It is never probed and counts as healthy; CheckedAt and
+	// Latency are from its last successful request, if any.
+	NoProbe bool
+}
+
+// healthCache stores the most recent probe result per provider.
+type healthCache struct {
+	mu      sync.RWMutex
+	results map[string]ProviderHealth
+}
+
+func newHealthCache() *healthCache {
+	return &healthCache{results: make(map[string]ProviderHealth)}
+}
+
+func (h *healthCache) set(name string, ph ProviderHealth) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.results[name] = ph
+}
+
+func (h *healthCache) get(name string) (ProviderHealth, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ph, ok := h.results[name]
+	return ph, ok
+}
+
+func (h *healthCache) snapshot() map[string]ProviderHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make(map[string]ProviderHealth, len(h.results))
+	for k, v := range h.results {
+		out[k] = v
+	}
+	return out
+}
+
+// served records a successful request to a provider that is never probed,
+// so its status shows when it last worked.
+func (h *healthCache) served(name string, latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.results[name].NoProbe {
+		h.results[name] = ProviderHealth{OK: true, Latency: latency, CheckedAt: time.Now(), NoProbe: true}
+	}
+}
+
+// probeProvider checks p with its HealthCheck or by listing its models.
+// Unlike allm's Ping it never falls back to a billable completion: a
+// provider offering neither returns errNoProbe.
+func probeProvider(ctx context.Context, p allm.Provider) error {
+	if hc, ok := p.(HealthChecker); ok {
+		return hc.HealthCheck(ctx)
+	}
+	lister, ok := p.(allm.ModelLister)
+	if !ok {
+		return errNoProbe
+	}
+	_, err := lister.Models(ctx)
+	if errors.Is(err, allm.ErrNotSupported) {
+		return errNoProbe
+	}
+	return err
+}
+
+// probeClient runs a single health probe against a client. ok is false for
+// a provider that offers no free probe.
+func probeClient(ctx context.Context, client *allm.Client) (ph ProviderHealth, ok bool) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	start := time.Now()
+	err := probeProvider(ctx, client.Provider())
+	if errors.Is(err, errNoProbe) {
+		return ProviderHealth{}, false
+	}
+	return ProviderHealth{OK: err == nil, Latency: time.Since(start), Error: err, CheckedAt: time.Now()}, true
+}
+
+// ProbeHealth probes every registered provider once and updates the cache.
+// Providers without a free probe are marked NoProbe and assumed healthy.
+func (r *Router) ProbeHealth(ctx context.Context) map[string]ProviderHealth {
+	r.mu.RLock()
+	clients := make(map[string]*allm.Client, len(r.clients))
+	for k, v := range r.clients {
+		clients[k] = v
+	}
+	r.mu.RUnlock()
+
+	for name, client := range clients {
+		if ctx.Err() != nil {
+			break
+		}
+		ph, ok := probeClient(ctx, client)
+		if !ok {
+			if cached, _ := r.health.get(name); !cached.NoProbe {
+				r.health.set(name, ProviderHealth{OK: true, NoProbe: true})
+			}
+			continue
+		}
+		if !ph.OK {
+			r.logger.Warn("llm provider health check failed", "provider", name, "error", ph.Error)
+		}
+		r.health.set(name, ph)
+	}
+	return r.health.snapshot()
+}
+
+// StartHealthProbe probes all providers immediately and then every interval
+// until ctx is cancelled. Results are cached so callers never hit the
+// provider APIs on the message path. A non-positive interval disables probing.
+func (r *Router) StartHealthProbe(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		r.ProbeHealth(ctx)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.ProbeHealth(ctx)
+			}
+		}
+	}()
+}
+
+// CachedHealth returns the last probe result for every provider that has
+// been checked. Providers never probed are absent from the map.
+func (r *Router) CachedHealth() map[string]ProviderHealth {
+	return r.health.snapshot()
+}
+
+// IsHealthy reports whether the named provider passed its last probe.
+// Providers that have not been probed yet are assumed healthy.
+func (r *Router) IsHealthy(name string) bool {
+	ph, ok := r.health.get(name)
+	return !ok || ph.OK
+}
+
+// healthFallback returns the provider to use instead of name when name
+// failed its last health probe: the first other provider by name that is
+// available, healthy and not cooling down. ok is false when name is
+// healthy or no other provider qualifies.
+func (r *Router) healthFallback(name string) (string, *allm.Client, bool) {
+	if r.IsHealthy(name) {
+		return "", nil, false
+	}
+	r.mu.RLock()
+	clients := maps.Clone(r.clients)
+	r.mu.RUnlock()
+
+	for _, other := range slices.Sorted(maps.Keys(clients)) {
+		client := clients[other]
+		if other == name || !r.IsHealthy(other) || r.cooldown(other) > 0 || !client.Provider().Available() {
+			continue
+		}
+		r.logger.Warn("llm provider failed its health check, using another", "provider", name, "fallback", other)
+		return other, client, true
+	}
+	return "", nil, false
+}
@@ -0,0 +1,264 @@
+// API key rotation for providers configured with several keys
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// DefaultKeyCooldown is how long a rate-limited key is skipped when the
+// provider doesn't say (see RetryAfter).
+const DefaultKeyCooldown = time.Minute
+
+// KeyPool is a provider that spreads requests round-robin over the same
+// provider configured with different API keys. A key that is rate limited or
+// out of quota is put on cooldown and the request is retried with the next
+// one, so callers only see the error when every key is exhausted.
+//
+// Optional allm interfaces (model listing, embeddings, speech, ...) are
+// forwarded; they report allm.ErrNotSupported when the provider lacks them.
+type KeyPool struct {
+	providers []allm.Provider
+	cooldown  time.Duration
+	now       func() time.Time // for tests
+
+	mu    sync.Mutex
+	next  int
+	until []time.Time // per key: skip until this time
+}
+
+// NewKeyPool creates a pool over one provider instance per API key. A
+// cooldown <= 0 uses DefaultKeyCooldown.
+func NewKeyPool(providers []allm.Provider, cooldown time.Duration) *KeyPool {
+	if cooldown <= 0 {
+		cooldown = DefaultKeyCooldown
+	}
+	return &KeyPool{
+		providers: providers,
+		cooldown:  cooldown,
+		now:       time.Now,
+		until:     make([]time.Time, len(providers)),
+	}
+}
+
+// PooledProvider returns a KeyPool over build(key) for each key, or the
+// plain provider when there is only one key.
+func PooledProvider[P allm.Provider](keys []string, cooldown time.Duration, build func(apiKey string) P) allm.Provider {
+	if len(keys) <= 1 {
+		var key string
+		if len(keys) == 1 {
+			key = keys[0]
+		}
+		return build(key)
+	}
+	providers := make([]allm.Provider, len(keys))
+	for i, k := range keys {
+		providers[i] = build(k)
+	}
+	return NewKeyPool(providers, cooldown)
+}
+
+// order returns key indexes to try: keys off cooldown in round-robin order,
+// then cooling keys by soonest expiry as a last resort.
+func (p *KeyPool) order() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	n := len(p.providers)
+	ready := make([]int, 0, n)
+	var cooling []int
+	for off := range n {
+		i := (p.next + off) % n
+		if now.Before(p.until[i]) {
+			cooling = append(cooling, i)
+		} else {
+			ready = append(ready, i)
+		}
+	}
+	sort.SliceStable(cooling, func(a, b int) bool { return p.until[cooling[a]].Before(p.until[cooling[b]]) })
+	p.next = (p.next + 1) % n
+	return append(ready, cooling...)
+}
+
+// demote puts key i on cooldown for as long as err's Retry-After asks, or
+// the pool's cooldown.
+func (p *KeyPool) demote(i int, err error) {
+	cooldown := p.cooldown
+	if d, ok := RetryAfter(err); ok {
+		cooldown = d
+	}
+	p.mu.Lock()
+	p.until[i] = p.now().Add(cooldown)
+	p.mu.Unlock()
+}
+
+// exhausted returns err, the last key's, as a *RetryAfterError lasting
+// until the first key comes off cooldown, now that every key is resting.
+func (p *KeyPool) exhausted(err error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	first := p.until[0]
+	for _, until := range p.until[1:] {
+		if until.Before(first) {
+			first = until
+		}
+	}
+	if d := first.Sub(p.now()); d > 0 {
+		return &RetryAfterError{Err: err, After: d}
+	}
+	return err
+}
+
+// keyExhausted reports whether err means this key, rather than the request,
+// should be given a rest.
+func keyExhausted(err error) bool {
+	if errors.Is(err, allm.ErrRateLimited) || httpStatus(err) == 429 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "quota") || strings.Contains(msg, "credit balance")
+}
+
+// poolCall runs fn against each key in turn until one is not exhausted.
+func poolCall[T any](p *KeyPool, fn func(allm.Provider) (T, error)) (T, error) {
+	var result T
+	var err error
+	for _, i := range p.order() {
+		result, err = fn(p.providers[i])
+		if err == nil || !keyExhausted(err) {
+			return result, err
+		}
+		p.demote(i, err)
+	}
+	return result, p.exhausted(err)
+}
+
+// Name returns the underlying provider name.
+func (p *KeyPool) Name() string { return p.providers[0].Name() }
+
+// Available reports whether any key's provider is configured.
+func (p *KeyPool) Available() bool {
+	for _, pr := range p.providers {
+		if pr.Available() {
+			return true
+		}
+	}
+	return false
+}
+
+// Complete sends the request, failing over to the next key on rate limits.
+func (p *KeyPool) Complete(ctx context.Context, req *allm.Request) (*allm.Response, error) {
+	return poolCall(p, func(pr allm.Provider) (*allm.Response, error) {
+		return pr.Complete(ctx, req)
+	})
+}
+
+// Stream streams the response. A rate-limit error arriving before any
+// content moves the request to the next key.
+func (p *KeyPool) Stream(ctx context.Context, req *allm.Request) <-chan allm.StreamChunk {
+	out := make(chan allm.StreamChunk)
+	go func() {
+		defer close(out)
+		order := p.order()
+		for n, i := range order {
+			ch := p.providers[i].Stream(ctx, req)
+			chunk, ok := <-ch
+			if !ok {
+				return
+			}
+			if chunk.Error != nil && keyExhausted(chunk.Error) {
+				p.demote(i, chunk.Error)
+				if n < len(order)-1 {
+					for range ch {
+					}
+					continue
+				}
+				chunk.Error = p.exhausted(chunk.Error)
+			}
+			for ok {
+				select {
+				case out <- chunk:
+				case <-ctx.Done():
+					return
+				}
+				chunk, ok = <-ch
+			}
+			return
+		}
+	}()
+	return out
+}
+
+// Models lists models using the first available key.
+func (p *KeyPool) Models(ctx context.Context) ([]allm.Model, error) {
+	return poolCall(p, func(pr allm.Provider) ([]allm.Model, error) {
+		lister, ok := pr.(allm.ModelLister)
+		if !ok {
+			return nil, fmt.Errorf("%w: model listing", allm.ErrNotSupported)
+		}
+		return lister.Models(ctx)
+	})
+}
+
+// CountTokens counts tokens using the first available key.
+func (p *KeyPool) CountTokens(ctx context.Context, req *allm.Request) (*allm.TokenCount, error) {
+	return poolCall(p, func(pr allm.Provider) (*allm.TokenCount, error) {
+		counter, ok := pr.(allm.TokenCounter)
+		if !ok {
+			return nil, fmt.Errorf("%w: token counting", allm.ErrNotSupported)
+		}
+		return counter.CountTokens(ctx, req)
+	})
+}
+
+// Embed generates embeddings using the first available key.
+func (p *KeyPool) Embed(ctx context.Context, req *allm.EmbedRequest) (*allm.EmbedResponse, error) {
+	return poolCall(p, func(pr allm.Provider) (*allm.EmbedResponse, error) {
+		embedder, ok := pr.(allm.Embedder)
+		if !ok {
+			return nil, fmt.Errorf("%w: embeddings", allm.ErrNotSupported)
+		}
+		return embedder.Embed(ctx, req)
+	})
+}
+
+// Speak converts text to speech using the first available key.
+func (p *KeyPool) Speak(ctx context.Context, req *allm.SpeechRequest) (*allm.SpeechResponse, error) {
+	return poolCall(p, func(pr allm.Provider) (*allm.SpeechResponse, error) {
+		speaker, ok := pr.(allm.Speaker)
+		if !ok {
+			return nil, fmt.Errorf("%w: text-to-speech", allm.ErrNotSupported)
+		}
+		return speaker.Speak(ctx, req)
+	})
+}
+
+// Transcribe converts speech to text using the first available key.
+func (p *KeyPool) Transcribe(ctx context.Context, req *allm.TranscribeRequest) (*allm.TranscribeResponse, error) {
+	return poolCall(p, func(pr allm.Provider) (*allm.TranscribeResponse, error) {
+		transcriber, ok := pr.(allm.Transcriber)
+		if !ok {
+			return nil, fmt.Errorf("%w: speech-to-text", allm.ErrNotSupported)
+		}
+		return transcriber.Transcribe(ctx, req)
+	})
+}
+
+// GenerateImage creates images using the first available key.
+func (p *KeyPool) GenerateImage(ctx context.Context, req *allm.ImageRequest) (*allm.ImageResponse, error) {
+	return poolCall(p, func(pr allm.Provider) (*allm.ImageResponse, error) {
+		generator, ok := pr.(allm.ImageGenerator)
+		if !ok {
+			return nil, fmt.Errorf("%w: image generation", allm.ErrNotSupported)
+		}
+		return generator.GenerateImage(ctx, req)
+	})
+}
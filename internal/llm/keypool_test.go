@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/allmtest"
+	"github.com/kusandriadi/allm-go/provider"
+)
+
+const okCompletion = `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`
+
+func TestKeyPool_FailsOverOnRateLimit(t *testing.T) {
+	var limited, served atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer key-1" {
+			limited.Add(1)
+			w.Header().Set("x-should-retry", "false")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"rate limit reached","type":"requests"}}`))
+			return
+		}
+		served.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(okCompletion))
+	}))
+	defer srv.Close()
+
+	p := PooledProvider([]string{"key-1", "key-2"}, time.Hour, func(key string) *provider.OpenAICompatibleProvider {
+		return provider.OpenAICompatible(allm.Local, key, provider.WithBaseURL(srv.URL), provider.WithDefaultModel("m"))
+	})
+	client := allm.New(p)
+
+	for i := range 3 {
+		resp, err := client.Chat(context.Background(), []allm.Message{{Role: allm.RoleUser, Content: "hello"}})
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if resp.Content != "hi" {
+			t.Errorf("request %d content = %q", i, resp.Content)
+		}
+	}
+	// key-1 is demoted after its first 429 and skipped while cooling down
+	if limited.Load() != 1 || served.Load() != 3 {
+		t.Errorf("key-1 hits = %d, key-2 hits = %d; want 1 and 3", limited.Load(), served.Load())
+	}
+}
+
+func TestKeyPool_AllKeysLimited(t *testing.T) {
+	limitedErr := allmtest.WithError(allm.ErrRateLimited)
+	a := allmtest.NewMockProvider("test", limitedErr)
+	b := allmtest.NewMockProvider("test", limitedErr)
+	pool := NewKeyPool([]allm.Provider{a, b}, time.Minute)
+
+	_, err := pool.Complete(context.Background(), &allm.Request{})
+	if !errors.Is(err, allm.ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if a.CallCount() != 1 || b.CallCount() != 1 {
+		t.Errorf("calls = %d, %d; want each key tried once", a.CallCount(), b.CallCount())
+	}
+}
+
+func TestKeyPool_StreamFailover(t *testing.T) {
+	limited := allmtest.NewMockProvider("test", allmtest.WithStreamChunks([]allm.StreamChunk{{Error: allm.ErrRateLimited, Done: true}}))
+	ok := allmtest.NewMockProvider("test", allmtest.WithStreamChunks([]allm.StreamChunk{{Content: "hi"}, {Done: true}}))
+	pool := NewKeyPool([]allm.Provider{limited, ok}, time.Minute)
+
+	var got string
+	for chunk := range pool.Stream(context.Background(), &allm.Request{}) {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+		got += chunk.Content
+	}
+	if got != "hi" {
+		t.Errorf("streamed %q, want hi", got)
+	}
+}
+
+func TestPooledProvider_SingleKey(t *testing.T) {
+	p := PooledProvider([]string{"only"}, 0, func(key string) *allmtest.MockProvider {
+		return allmtest.NewMockProvider(key)
+	})
+	if _, isPool := p.(*KeyPool); isPool {
+		t.Error("single key should not be pooled")
+	}
+}
+
+func TestKeyPool_RetryAfter(t *testing.T) {
+	limited := &RetryAfterError{Err: allm.ErrRateLimited, After: 10 * time.Second}
+	a := allmtest.NewMockProvider("test", allmtest.WithError(limited))
+	b := allmtest.NewMockProvider("test", allmtest.WithError(allm.ErrRateLimited))
+	pool := NewKeyPool([]allm.Provider{a, b}, time.Hour)
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	_, err := pool.Complete(context.Background(), &allm.Request{})
+	if pool.until[0] != now.Add(10*time.Second) || pool.until[1] != now.Add(time.Hour) {
+		t.Errorf("cooldowns = %v, %v; want the Retry-After, then the pool default", pool.until[0].Sub(now), pool.until[1].Sub(now))
+	}
+	// Every key is resting: the pool says when the first is back
+	if d, ok := RetryAfter(err); !ok || d != 10*time.Second || !errors.Is(err, allm.ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited retrying after 10s", err)
+	}
+}
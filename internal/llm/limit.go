@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// providerLimiter caps the requests in flight to each provider, so a burst
+// of messages queues here instead of tripping the provider's own
+// concurrency limits. It is separate from the per-user rateLimiter.
+type providerLimiter struct {
+	max      int // 0 = unlimited
+	mu       sync.Mutex
+	slots    map[string]chan struct{}
+	inFlight map[string]int
+}
+
+func newProviderLimiter(max int) *providerLimiter {
+	return &providerLimiter{
+		max:      max,
+		slots:    make(map[string]chan struct{}),
+		inFlight: make(map[string]int),
+	}
+}
+
+// acquire waits for a slot on provider and returns the func that frees it.
+// It returns ctx's error if ctx ends while queued.
+func (l *providerLimiter) acquire(ctx context.Context, provider string) (release func(), err error) {
+	l.mu.Lock()
+	slots, ok := l.slots[provider]
+	if !ok && l.max > 0 {
+		slots = make(chan struct{}, l.max)
+		l.slots[provider] = slots
+	}
+	l.mu.Unlock()
+
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	l.mu.Lock()
+	l.inFlight[provider]++
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight[provider]--
+			l.mu.Unlock()
+			if slots != nil {
+				<-slots
+			}
+		})
+	}, nil
+}
+
+// full reports whether provider has no free slot, i.e. a new request would queue.
+func (l *providerLimiter) full(provider string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.max > 0 && l.inFlight[provider] >= l.max
+}
+
+// snapshot returns the requests in flight per provider.
+func (l *providerLimiter) snapshot() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make(map[string]int, len(l.inFlight))
+	for name, n := range l.inFlight {
+		out[name] = n
+	}
+	return out
+}
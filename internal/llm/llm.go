@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/kusa/magabot/internal/telemetry"
 	"github.com/kusa/magabot/internal/util"
 	"github.com/kusandriadi/allm-go"
 	"github.com/kusandriadi/allm-go/provider"
@@ -16,6 +19,7 @@ import (
 
 // Re-export allm types for convenience
 type (
+	Provider           = allm.Provider
 	Message            = allm.Message
 	Response           = allm.Response
 	Image              = allm.Image
@@ -37,6 +41,9 @@ type (
 	TokenLogProb       = allm.TokenLogProb
 	SearchResult       = allm.SearchResult
 	HealthStatus       = allm.HealthStatus
+	Tool               = allm.Tool
+	ToolCall           = allm.ToolCall
+	ToolResult         = allm.ToolResult
 )
 
 var (
@@ -223,15 +230,30 @@ func (u *usageTracker) stats() UsageStats {
 // Router manages LLM clients
 type Router struct {
 	clients         map[string]*allm.Client
+	options         map[string][]allm.Option // client options per provider, see RegisterProvider
 	mainName        string
 	systemPrompt    string
 	maxInput        int
 	maxContextChars int
+	contextWindows  map[string]int  // per-model overrides of ContextWindow
+	visionModels    map[string]bool // per-model overrides of SupportsVision
+	maxTokens       map[string]int  // output token limit per provider, see SetMaxTokens
+	tokenizers      map[string]Tokenizer
+	route           RouteFunc // picks a provider per request, see SetRouteFunc
+	tokenizerCache  sync.Map  // model -> Tokenizer
 	timeout         time.Duration
 	rateLimiter     *rateLimiter
+	limiter         *providerLimiter
 	usage           *usageTracker
+	health          *healthCache
+	stats           *modelStats
 	logger          *slog.Logger
+	logPrompts      bool // include message content in debug logs
+	redactPrompts   bool // replace logged content with its length
+	outage          outageState
+	cooldowns       map[string]time.Time // provider -> until when it asked us to wait, see noteRetryAfter
 	mu              sync.RWMutex
+	thinking        *allm.ThinkingConfig // main provider's thinking, see SetThinking
 	promptCaching   bool
 }
 
@@ -239,11 +261,30 @@ type Router struct {
 type Config struct {
 	Main            string
 	SystemPrompt    string
-	MaxInput        int
+	MaxInput        int // max prompt tokens, history included; longer history is trimmed (default 10000)
 	MaxContextChars int // max total chars sent to LLM; 0 = default 250000
 	Timeout         time.Duration
 	RateLimit       int // requests per minute per user
 	Logger          *slog.Logger
+	LogPrompts      bool // log message content at debug level (off by default)
+	RedactMessages  bool // when LogPrompts is on, log only content length
+
+	// MaxConcurrentPerProvider caps requests in flight to one provider;
+	// more wait for a free slot. 0 = unlimited.
+	MaxConcurrentPerProvider int
+
+	// ContextWindows sets the context window in tokens of models that
+	// ContextWindow does not know or gets wrong, keyed by model name.
+	ContextWindows map[string]int
+
+	// VisionModels sets whether models that SupportsVision does not know
+	// or gets wrong accept images, keyed by model name.
+	VisionModels map[string]bool
+
+	// Tokenizers counts tokens for models whose name starts with the key,
+	// e.g. a LoadTokenizer encoding for "gpt-4o". Others use
+	// HeuristicTokenizer.
+	Tokenizers map[string]Tokenizer
 }
 
 // NewRouter creates a new LLM router
@@ -268,14 +309,25 @@ func NewRouter(cfg *Config) *Router {
 
 	return &Router{
 		clients:         make(map[string]*allm.Client),
+		options:         make(map[string][]allm.Option),
+		cooldowns:       make(map[string]time.Time),
 		mainName:        cfg.Main,
 		systemPrompt:    cfg.SystemPrompt,
 		maxInput:        cfg.MaxInput,
 		maxContextChars: cfg.MaxContextChars,
+		contextWindows:  cfg.ContextWindows,
+		visionModels:    cfg.VisionModels,
+		maxTokens:       make(map[string]int),
+		tokenizers:      cfg.Tokenizers,
 		timeout:         cfg.Timeout,
 		rateLimiter:     newRateLimiter(cfg.RateLimit),
+		limiter:         newProviderLimiter(cfg.MaxConcurrentPerProvider),
 		usage:           newUsageTracker(),
+		health:          newHealthCache(),
+		stats:           newModelStats(),
 		logger:          logger,
+		logPrompts:      cfg.LogPrompts,
+		redactPrompts:   cfg.RedactMessages,
 	}
 }
 
@@ -285,20 +337,78 @@ func DetectProvider(model string) string {
 	return string(allm.DetectProvider(model))
 }
 
-// Register registers a provider with a name
+// Register registers a provider with a name. Requests get clients of their
+// own on client's provider and model, but none of its other options; use
+// RegisterProvider to keep them.
 func (r *Router) Register(name string, client *allm.Client) {
+	r.register(name, client, nil)
+}
+
+// RegisterProvider registers p with a name and the client options it runs
+// with (timeout, retries, context limits), which every request's client
+// starts from (see requestClient).
+func (r *Router) RegisterProvider(name string, p allm.Provider, opts ...allm.Option) {
+	r.register(name, allm.New(p, opts...), opts)
+}
+
+func (r *Router) register(name string, client *allm.Client, opts []allm.Option) {
+	// Available may probe the provider, so it runs outside the lock
+	available := client.Provider().Available()
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.clients[name] = client
+	r.options[name] = opts
 	r.logger.Info("registered LLM provider", "name", name)
 
 	// Auto-detect main provider if not explicitly set
-	if r.mainName == "" && client.Provider().Available() {
+	if r.mainName == "" && available {
 		r.mainName = name
 		r.logger.Info("auto-selected main provider", "name", name)
 	}
 }
 
+// Unregister removes a provider. When it was the main provider, the first
+// available provider by name takes over.
+func (r *Router) Unregister(name string) {
+	r.mu.Lock()
+	if _, ok := r.clients[name]; !ok {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.clients, name)
+	delete(r.options, name)
+	delete(r.maxTokens, name)
+	r.logger.Info("unregistered LLM provider", "name", name)
+	if r.mainName != name {
+		r.mu.Unlock()
+		return
+	}
+	r.mainName = ""
+	candidates := make(map[string]*allm.Client, len(r.clients))
+	maps.Copy(candidates, r.clients)
+	r.mu.Unlock()
+
+	// Available may probe the provider, so the successor is picked outside
+	// the lock; it only takes over if nothing else became main meanwhile
+	for _, other := range slices.Sorted(maps.Keys(candidates)) {
+		if !candidates[other].Provider().Available() {
+			continue
+		}
+		r.mu.Lock()
+		_, registered := r.clients[other]
+		done := r.mainName != "" || registered
+		if r.mainName == "" && registered {
+			r.mainName = other
+			r.logger.Info("auto-selected main provider", "name", other)
+		}
+		r.mu.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
 // EnablePromptCaching enables prompt caching on system prompts
 func (r *Router) EnablePromptCaching() {
 	r.mu.Lock()
@@ -325,30 +435,68 @@ func (r *Router) QuickChat(ctx context.Context, prompt string) (string, error) {
 	return resp.Content, nil
 }
 
-// chat is the internal method that calls the main client
+// chat is the internal method that calls the main client, or another
+// provider when the main one failed its last health probe
 func (r *Router) chat(ctx context.Context, messages []allm.Message) (*Response, error) {
 	r.mu.RLock()
-	client, ok := r.clients[r.mainName]
+	name := r.mainName
+	client, ok := r.clients[name]
 	r.mu.RUnlock()
 
 	if !ok {
-		return nil, fmt.Errorf("%w: provider %q not registered", ErrNoProvider, r.mainName)
+		err := fmt.Errorf("%w: provider %q not registered", ErrNoProvider, name)
+		r.recordResult(err)
+		return nil, err
+	}
+	if fbName, fb, ok := r.healthFallback(name); ok {
+		name, client = fbName, fb
 	}
 
 	if !client.Provider().Available() {
-		return nil, fmt.Errorf("%w: provider %q not available", ErrNoProvider, r.mainName)
+		err := fmt.Errorf("%w: provider %q not available", ErrNoProvider, name)
+		r.recordResult(err)
+		return nil, err
+	}
+	if left := r.cooldown(name); left > 0 {
+		err := errCoolingDown(name, left)
+		r.recordResult(err)
+		return nil, err
 	}
 
-	resp, err := client.Chat(ctx, messages)
+	model := client.Model()
+	ctx, span := telemetry.Start(ctx, "llm.chat", spanAttributes(name, model)...)
+	defer span.End()
+
+	messages, err := r.fitContext(model, messages)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %s: %w", ErrProviderFailed, r.mainName, err)
+		telemetry.RecordError(span, err)
+		return nil, err
 	}
+	release, err := r.limiter.acquire(ctx, name)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	defer release()
+	r.logRequest(ctx, name, model, messages)
+	start := time.Now()
 
-	r.usage.trackTokens(resp.InputTokens, resp.OutputTokens)
-
-	if resp.RequestID != "" {
-		r.logger.Debug("llm response", "provider", r.mainName, "request_id", resp.RequestID, "tokens_in", resp.InputTokens, "tokens_out", resp.OutputTokens)
+	resp, err := r.chatRetry(ctx, name, r.requestClient(name, client, &Request{}), messages)
+	if err != nil {
+		r.logFailure(ctx, name, model, err, time.Since(start))
+		r.stats.record(name, model, time.Since(start), err)
+		err = r.noteRetryAfter(name, fmt.Errorf("%w: %s: %w", ErrProviderFailed, name, err))
+		r.recordResult(err)
+		telemetry.RecordError(span, err)
+		return nil, err
 	}
+	r.recordResult(nil)
+
+	span.SetAttributes(tokenAttributes(resp.InputTokens, resp.OutputTokens)...)
+	r.usage.trackTokens(resp.InputTokens, resp.OutputTokens)
+	r.logResponse(ctx, name, model, resp.InputTokens, resp.OutputTokens, resp.RequestID, time.Since(start))
+	r.stats.record(name, model, time.Since(start), nil)
+	r.health.served(name, time.Since(start))
 
 	return resp, nil
 }
@@ -445,50 +593,142 @@ func trimHistory(messages []Message, systemPromptLen, maxChars int) []Message {
 	return messages
 }
 
+// Request is a single chat turn for StreamRequest.
+type Request struct {
+	UserID       string    // Rate-limit key
+	Messages     []Message // Conversation, oldest first
+	SystemPrompt string    // Replaces the default system prompt when non-empty
+	Provider     string    // Registered provider for this request only; empty uses the main provider (or the RouteFunc's pick)
+	Model        string    // Model for this request only; empty uses the provider's model
+	MaxTokens    int       // Output token limit for this request only; 0 uses the provider's max_tokens
+	Temperature  float64   // Sampling temperature for this request only; 0 uses the provider's temperature
+
+	// Reasoning for models that support it; others ignore these (see reasoning)
+	ReasoningEffort string // low, medium or high: OpenAI reasoning_effort, or a Claude thinking budget
+	ThinkingBudget  int    // Claude thinking.budget_tokens; overrides the budget ReasoningEffort picks
+
+	// Tools the model may call before answering, run by RunTool; see
+	// completeStream. Both must be set.
+	Tools   []Tool
+	RunTool ToolRunner
+
+	// Stop sequences and sampling seed; providers that lack them (seed on
+	// Anthropic) ignore them. A seeded answer arrives in one chunk, so its
+	// SystemFingerprint can be reported.
+	Stop []string
+	Seed *int
+
+	// SystemFingerprint is set by StreamRequest when the provider reports
+	// the backend configuration that produced the answer (OpenAI and
+	// compatible providers, for answers that arrive in one chunk: seeded
+	// requests and those with tools)
+	SystemFingerprint string
+
+	// FinishReason is set by StreamRequest before the final chunk is sent:
+	// FinishLength when the answer hit the output token limit (see
+	// Continuation), else FinishStop. It is the provider's finish reason
+	// for answers that arrive in one chunk, and inferred from the output
+	// tokens for streamed ones.
+	FinishReason string
+
+	// RequestID is set by StreamRequest to the ID of the message the
+	// request answers (see telemetry.RequestID), which its logs carry too.
+	// ProviderRequestID is the ID the provider gave the call, for answers
+	// that arrive in one chunk.
+	RequestID         string
+	ProviderRequestID string
+}
+
 // StreamChat streams a chat response with idle timeout.
 // The timeout resets on each received chunk, so long-running responses
 // (e.g. extended thinking) won't be killed as long as data keeps flowing.
 // An optional systemPromptOverride can be provided; when non-empty it replaces
 // the router's default system prompt for this request only.
 func (r *Router) StreamChat(ctx context.Context, userID string, messages []Message, systemPromptOverride ...string) (<-chan StreamChunk, error) {
+	req := &Request{UserID: userID, Messages: messages}
+	if len(systemPromptOverride) > 0 {
+		req.SystemPrompt = systemPromptOverride[0]
+	}
+	return r.StreamRequest(ctx, req)
+}
+
+// StreamRequest streams a chat response like StreamChat, honoring the
+// request's system prompt, model and sampling overrides.
+func (r *Router) StreamRequest(ctx context.Context, req *Request) (<-chan StreamChunk, error) {
 	// Rate limit check
-	if !r.rateLimiter.allow(userID) {
-		r.logger.Warn("rate limit exceeded", "user", util.MaskSecret(userID))
+	if !r.rateLimiter.allow(req.UserID) {
+		r.logger.Warn("rate limit exceeded", "user", util.MaskSecret(req.UserID))
 		return nil, ErrRateLimited
 	}
 
 	r.usage.track()
+	req.FinishReason = ""
+	req.RequestID, req.ProviderRequestID = telemetry.RequestID(ctx), ""
 
 	// Copy messages to avoid mutating caller's slice during sanitization
-	sanitized := make([]Message, len(messages))
-	copy(sanitized, messages)
+	sanitized := make([]Message, len(req.Messages))
+	copy(sanitized, req.Messages)
 	for i := range sanitized {
 		sanitized[i].Content = allm.SanitizeInput(sanitized[i].Content)
 	}
 
-	// Get main client
-	r.mu.RLock()
-	client, ok := r.clients[r.mainName]
-	r.mu.RUnlock()
+	// The main provider, or the one the request names or is routed to
+	providerName, client, err := r.clientFor(ctx, req)
+	if err != nil {
+		r.recordResult(err)
+		return nil, err
+	}
+	if left := r.cooldown(providerName); left > 0 {
+		err := errCoolingDown(providerName, left)
+		r.recordResult(err)
+		return nil, err
+	}
 
-	if !ok {
-		return nil, fmt.Errorf("%w: provider %q not registered", ErrNoProvider, r.mainName)
+	model := client.Model()
+	if req.Model != "" {
+		model = req.Model
 	}
 
-	// Build allm messages
-	var override string
-	if len(systemPromptOverride) > 0 {
-		override = systemPromptOverride[0]
+	// The span ends when the stream does, so it covers queuing and streaming
+	ctx, span := telemetry.Start(ctx, "llm.stream", spanAttributes(providerName, model)...)
+
+	// Build allm messages, trimmed to the model's context window
+	allmMessages, err := r.fitContext(model, r.buildMessages(sanitized, req.SystemPrompt))
+	if err != nil {
+		telemetry.RecordError(span, err)
+		span.End()
+		return nil, err
 	}
-	allmMessages := r.buildMessages(sanitized, override)
+
+	// Wait for a free slot on the provider (max_concurrent_per_provider)
+	if r.limiter.full(providerName) {
+		r.logger.Debug("provider at concurrency limit, queuing request", "provider", providerName)
+	}
+	release, err := r.limiter.acquire(ctx, providerName)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		span.End()
+		return nil, err
+	}
+
+	r.logRequest(ctx, providerName, model, allmMessages)
+	start := time.Now()
+	limit := r.outputLimit(providerName, req)
 
 	// Get raw stream from provider (no hard deadline on context)
-	rawCh := client.Stream(ctx, allmMessages)
+	var rawCh <-chan StreamChunk
+	if (len(req.Tools) > 0 && req.RunTool != nil) || req.Seed != nil {
+		rawCh = r.completeStream(ctx, providerName, client, allmMessages, req)
+	} else {
+		rawCh = r.startStream(ctx, providerName, client, allmMessages, req)
+	}
 
 	// Wrap with idle timeout: cancel only if no chunk arrives within r.timeout
 	out := make(chan StreamChunk)
 	go func() {
 		defer close(out)
+		defer release()
+		defer span.End()
 		idle := time.NewTimer(r.timeout)
 		defer idle.Stop()
 
@@ -509,21 +749,46 @@ func (r *Router) StreamChat(ctx context.Context, userID string, messages []Messa
 
 				// Track token usage from the final stream chunk
 				if chunk.Done && chunk.Usage != nil {
+					span.SetAttributes(tokenAttributes(chunk.Usage.InputTokens, chunk.Usage.OutputTokens)...)
 					r.usage.trackTokens(chunk.Usage.InputTokens, chunk.Usage.OutputTokens)
+					r.logResponse(ctx, providerName, model, chunk.Usage.InputTokens, chunk.Usage.OutputTokens, req.ProviderRequestID, time.Since(start))
+				}
+				if chunk.Error != nil {
+					chunk.Error = r.noteRetryAfter(providerName, chunk.Error)
+					r.logFailure(ctx, providerName, model, chunk.Error, time.Since(start))
+					r.stats.record(providerName, model, time.Since(start), chunk.Error)
+					r.recordResult(chunk.Error)
+					telemetry.RecordError(span, chunk.Error)
+				} else if chunk.Done {
+					r.stats.record(providerName, model, time.Since(start), nil)
+					r.health.served(providerName, time.Since(start))
+					r.recordResult(nil)
+					if req.FinishReason == "" {
+						req.FinishReason = streamFinishReason(chunk.Usage, limit)
+					}
+					if req.FinishReason == FinishLength {
+						r.logger.Warn("LLM answer hit the output token limit", "provider", providerName, "model", model, "max_tokens", limit)
+					}
 				}
 
 				select {
 				case out <- chunk:
 				case <-ctx.Done():
+					telemetry.RecordError(span, ctx.Err())
 					return
 				}
 				if chunk.Done || chunk.Error != nil {
 					return
 				}
 			case <-idle.C:
+				r.logFailure(ctx, providerName, model, ErrTimeout, time.Since(start))
+				r.stats.record(providerName, model, time.Since(start), ErrTimeout)
+				r.recordResult(ErrTimeout)
+				telemetry.RecordError(span, ErrTimeout)
 				out <- StreamChunk{Error: ErrTimeout, Done: true}
 				return
 			case <-ctx.Done():
+				telemetry.RecordError(span, ctx.Err())
 				return
 			}
 		}
@@ -532,6 +797,147 @@ func (r *Router) StreamChat(ctx context.Context, userID string, messages []Messa
 	return out, nil
 }
 
+// startStream starts a stream on provider name with the request's
+// settings, on a client of its own (see requestClient). A stream that is
+// rate limited before its first chunk starts over after the delay the
+// provider asked for, as chatRetry does.
+func (r *Router) startStream(ctx context.Context, name string, client *allm.Client, messages []allm.Message, req *Request) <-chan StreamChunk {
+	c := r.requestClient(name, client, req)
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		for attempt := 0; ; attempt++ {
+			ch := c.Stream(ctx, messages)
+			first, ok := <-ch
+			if !ok {
+				return
+			}
+			if first.Error != nil {
+				if wait, retry := rateLimitWait(ctx, first.Error, attempt); retry {
+					for range ch {
+					}
+					r.logger.Warn("llm provider rate limited, retrying", "provider", name, "wait", wait)
+					if sleepCtx(ctx, wait) {
+						continue
+					}
+				}
+			}
+			for chunk := first; ok; chunk, ok = <-ch {
+				select {
+				case out <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+			return
+		}
+	}()
+	return out
+}
+
+// requestClient returns a client for one request on provider name, built
+// from the options the provider was registered with, its current model and
+// req's model, sampling and reasoning settings, then extra. allm clients
+// have no per-call settings, and changing the shared client would reach
+// every request in flight on it.
+func (r *Router) requestClient(name string, client *allm.Client, req *Request, extra ...allm.Option) *allm.Client {
+	r.mu.RLock()
+	opts := slices.Clone(r.options[name])
+	defaultThinking := r.thinking
+	if name != r.mainName {
+		defaultThinking = nil
+	}
+	r.mu.RUnlock()
+
+	model := client.Model()
+	if req.Model != "" {
+		model = req.Model
+	}
+	opts = append(opts, allm.WithModel(model))
+	if req.MaxTokens > 0 {
+		opts = append(opts, allm.WithMaxTokens(req.MaxTokens))
+	}
+	if req.Temperature > 0 {
+		opts = append(opts, allm.WithTemperature(req.Temperature))
+	}
+	effort, thinking := req.reasoning(model, r.outputLimit(name, req))
+	if thinking == nil {
+		thinking = defaultThinking
+	}
+	if effort != "" {
+		opts = append(opts, allm.WithEffort(effort))
+	}
+	if thinking != nil {
+		opts = append(opts, func(c *allm.Client) { c.SetThinking(thinking) })
+	}
+	if req.Seed != nil {
+		opts = append(opts, allm.WithSeed(int64(*req.Seed)))
+	}
+
+	p := &requestProvider{Provider: client.Provider(), stop: req.Stop}
+	return allm.New(p, append(opts, extra...)...)
+}
+
+// requestProvider is the provider of a request's client. It adds the
+// request's stop sequences to each call, as allm.Client has no setting for
+// them, and hides rate limits from allm's retry, which would ignore
+// Retry-After (see chatRetry). It passes on the optional interfaces allm
+// uses, so token counting for max_context_tokens keeps working behind it.
+type requestProvider struct {
+	allm.Provider
+	stop []string
+}
+
+func (p *requestProvider) withStop(req *allm.Request) *allm.Request {
+	if len(p.stop) == 0 {
+		return req
+	}
+	r := *req
+	r.Stop = p.stop
+	return &r
+}
+
+// Complete sends req with the stop sequences.
+func (p *requestProvider) Complete(ctx context.Context, req *allm.Request) (*allm.Response, error) {
+	resp, err := p.Provider.Complete(ctx, p.withStop(req))
+	if errors.Is(err, allm.ErrRateLimited) {
+		return nil, &rateLimitedError{err: err}
+	}
+	return resp, err
+}
+
+// Stream streams req with the stop sequences.
+func (p *requestProvider) Stream(ctx context.Context, req *allm.Request) <-chan allm.StreamChunk {
+	return p.Provider.Stream(ctx, p.withStop(req))
+}
+
+// CountTokens counts tokens with the wrapped provider.
+func (p *requestProvider) CountTokens(ctx context.Context, req *allm.Request) (*allm.TokenCount, error) {
+	counter, ok := p.Provider.(allm.TokenCounter)
+	if !ok {
+		return nil, fmt.Errorf("%w: token counting", allm.ErrNotSupported)
+	}
+	return counter.CountTokens(ctx, req)
+}
+
+// Models lists models with the wrapped provider.
+func (p *requestProvider) Models(ctx context.Context) ([]allm.Model, error) {
+	lister, ok := p.Provider.(allm.ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: model listing", allm.ErrNotSupported)
+	}
+	return lister.Models(ctx)
+}
+
+// Embed generates embeddings with the wrapped provider.
+func (p *requestProvider) Embed(ctx context.Context, req *allm.EmbedRequest) (*allm.EmbedResponse, error) {
+	embedder, ok := p.Provider.(allm.Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%w: embeddings", allm.ErrNotSupported)
+	}
+	return embedder.Embed(ctx, req)
+}
+
 // CountTokens counts tokens in a set of messages
 func (r *Router) CountTokens(ctx context.Context, messages []Message) (*TokenCount, error) {
 	r.mu.RLock()
@@ -558,11 +964,7 @@ func (r *Router) Providers() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	names := make([]string, 0, len(r.clients))
-	for name := range r.clients {
-		names = append(names, name)
-	}
-	return names
+	return slices.Sorted(maps.Keys(r.clients))
 }
 
 // Stats returns usage statistics
@@ -582,10 +984,23 @@ func (r *Router) Stats() map[string]interface{} {
 		}
 	}
 	stats["available"] = available
+	stats["in_flight"] = r.limiter.snapshot()
 
 	return stats
 }
 
+// Available reports whether at least one registered provider is available.
+func (r *Router) Available() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.clients {
+		if c.Provider().Available() {
+			return true
+		}
+	}
+	return false
+}
+
 // Usage returns current hourly and weekly LLM request counts with next reset windows.
 func (r *Router) Usage() UsageStats {
 	return r.usage.stats()
@@ -701,8 +1116,22 @@ func (r *rateLimiter) Stats() map[string]interface{} {
 }
 
 // FormatError formats error for user display with sanitization.
-// Delegates to allm.FormatError.
+// Context window errors name the model, models Ollama hasn't pulled and
+// images no model accepts get a note; others delegate to allm.FormatError.
 func FormatError(err error) string {
+	var ce *ContextError
+	if errors.As(err, &ce) {
+		return fmt.Sprintf("Message too long for %s: about %d tokens, but it accepts %d. Please shorten it.",
+			ce.Model, ce.Tokens, ce.Window)
+	}
+	var mnp *ModelNotPulledError
+	if errors.As(err, &mnp) {
+		return fmt.Sprintf("The model %s isn't downloaded on the Ollama server. Run `ollama pull %s` there, then try again.",
+			mnp.Model, mnp.Model)
+	}
+	if errors.Is(err, ErrNoVision) {
+		return "None of the available models can read images. Please describe the image in text, or enable a provider with a vision model."
+	}
 	return allm.FormatError(err)
 }
 
@@ -787,14 +1216,12 @@ func (r *Router) CLIProvider() *provider.ClaudeCLIProvider {
 	return cli
 }
 
-// SetThinking sets the thinking configuration on the main client
+// SetThinking sets the thinking configuration of requests on the main
+// provider that set no reasoning of their own
 func (r *Router) SetThinking(thinking *allm.ThinkingConfig) {
-	r.mu.RLock()
-	client, ok := r.clients[r.mainName]
-	r.mu.RUnlock()
-	if ok {
-		client.SetThinking(thinking)
-	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.thinking = thinking
 }
 
 // Speak converts text to speech using the first registered provider that supports TTS.
@@ -1,14 +1,30 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/kusa/magabot/internal/telemetry"
 	"github.com/kusandriadi/allm-go"
 	"github.com/kusandriadi/allm-go/allmtest"
+	"github.com/kusandriadi/allm-go/provider"
 )
 
 func TestDetectProvider(t *testing.T) {
@@ -83,22 +99,37 @@ func TestRouter_InputTooLong(t *testing.T) {
 		Main:     "test",
 		MaxInput: 10,
 	})
-	router.Register("test", allm.New(mock, allm.WithMaxInputLen(10)))
+	router.Register("test", allm.New(mock, allm.WithMaxInputLen(InputLenLimit(10))))
 
+	// MaxInput counts tokens, so the router rejects the prompt itself
 	ctx := context.Background()
-	ch, err := router.StreamChat(ctx, "user1", []Message{{Role: "user", Content: "This is a very long message that exceeds the limit"}})
-	if err != nil {
-		t.Fatalf("StreamChat returned unexpected setup error: %v", err)
+	_, err := router.StreamChat(ctx, "user1", []Message{{Role: "user", Content: "This is a very long message that exceeds the limit"}})
+	var ce *ContextError
+	if !errors.As(err, &ce) || !ce.MaxInput || ce.Window != 10 {
+		t.Fatalf("StreamChat error = %v, want a max input *ContextError", err)
 	}
-	// Drain channel and check for error
-	var streamErr error
-	for chunk := range ch {
-		if chunk.Error != nil {
-			streamErr = chunk.Error
-		}
+	if !errors.Is(err, ErrInputTooLong) {
+		t.Error("Expected ErrInputTooLong")
 	}
-	if streamErr == nil {
-		t.Error("Expected error for input too long")
+	if mock.LastRequest() != nil {
+		t.Error("provider should not be called when the prompt is over the input limit")
+	}
+}
+
+func TestRouter_MaxInputTrimsHistory(t *testing.T) {
+	router := NewRouter(&Config{MaxInput: 80})
+	long := strings.Repeat("x", 300) // 79 tokens with overhead
+	messages := []allm.Message{
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: "ok"},
+		{Role: "user", Content: "latest"},
+	}
+	got, err := router.fitContext("unknown-model", messages)
+	if err != nil {
+		t.Fatalf("fitContext error: %v", err)
+	}
+	if len(got) != 2 || got[1].Content != "latest" {
+		t.Errorf("fitContext kept %+v, want the last two messages", got)
 	}
 }
 
@@ -698,6 +729,32 @@ func TestBuildSystemPrompt_Empty(t *testing.T) {
 	}
 }
 
+func TestPromptTemplate(t *testing.T) {
+	pt, err := ParsePromptTemplate("test", "You are {{.BotName}} on {{.Platform}}. Today is {{.Date}}, user {{.UserID}}.")
+	if err != nil {
+		t.Fatalf("ParsePromptTemplate: %v", err)
+	}
+	vars := NewPromptVars(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC), "u1", "telegram", "Maga")
+	want := "You are Maga on telegram. Today is 2026-03-01, user u1."
+	if got := pt.Expand(vars); got != want {
+		t.Errorf("Expand = %q, want %q", got, want)
+	}
+
+	plain, err := ParsePromptTemplate("plain", "No variables here.")
+	if err != nil {
+		t.Fatalf("ParsePromptTemplate plain: %v", err)
+	}
+	if got := plain.Expand(vars); got != "No variables here." {
+		t.Errorf("plain Expand = %q", got)
+	}
+
+	for _, bad := range []string{"Hi {{.Date", "Hi {{.Unknown}}"} {
+		if _, err := ParsePromptTemplate("bad", bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
 func TestUsageTracker_Track(t *testing.T) {
 	u := newUsageTracker()
 
@@ -881,3 +938,1708 @@ func TestRouter_Usage(t *testing.T) {
 		t.Errorf("WeeklyCount after 1 StreamChat = %d, want 1", u.WeeklyCount)
 	}
 }
+
+func TestNewOpenAICompatible(t *testing.T) {
+	client, err := NewOpenAICompatible(CompatibleConfig{
+		Name:    "groq",
+		BaseURL: "https://api.groq.com/openai/v1",
+		APIKey:  "test-key",
+		Model:   "llama-3.3-70b-versatile",
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAICompatible failed: %v", err)
+	}
+	if name := client.Provider().Name(); name != "groq" {
+		t.Errorf("provider name = %q, want %q", name, "groq")
+	}
+	if !client.Provider().Available() {
+		t.Error("provider with API key should be available")
+	}
+
+	router := NewRouter(&Config{})
+	router.Register("groq", client)
+	if router.MainProvider() != "groq" {
+		t.Errorf("main provider = %q, want groq", router.MainProvider())
+	}
+}
+
+func TestNewOpenAICompatible_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  CompatibleConfig
+	}{
+		{"missing name", CompatibleConfig{BaseURL: "https://api.together.xyz/v1"}},
+		{"missing base url", CompatibleConfig{Name: "together"}},
+		{"localhost blocked", CompatibleConfig{Name: "evil", BaseURL: "http://localhost:8080/v1"}},
+		{"private ip blocked", CompatibleConfig{Name: "evil", BaseURL: "http://10.0.0.5/v1"}},
+		{"metadata blocked", CompatibleConfig{Name: "evil", BaseURL: "http://169.254.169.254/v1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewOpenAICompatible(tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestNewCompatible(t *testing.T) {
+	client, err := NewCompatible(CompatibleConfig{
+		Name:    "proxy",
+		Format:  FormatAnthropic,
+		BaseURL: "https://llm.example.com",
+		APIKey:  "test-key",
+		Model:   "claude-sonnet-4-6",
+	})
+	if err != nil {
+		t.Fatalf("NewCompatible(anthropic) failed: %v", err)
+	}
+	if !client.Provider().Available() {
+		t.Error("provider with API key should be available")
+	}
+
+	tests := []struct {
+		name string
+		cfg  CompatibleConfig
+	}{
+		{"unknown format", CompatibleConfig{Name: "x", Format: "gemini", BaseURL: "https://api.example.com", APIKey: "k"}},
+		{"anthropic without key", CompatibleConfig{Name: "x", Format: FormatAnthropic, BaseURL: "https://api.example.com"}},
+		{"anthropic private ip", CompatibleConfig{Name: "x", Format: FormatAnthropic, BaseURL: "http://192.168.1.10", APIKey: "k"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCompatible(tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// healthCheckProvider wraps a mock provider with a custom HealthCheck probe.
+type healthCheckProvider struct {
+	*allmtest.MockProvider
+	err error
+}
+
+func (p *healthCheckProvider) HealthCheck(context.Context) error { return p.err }
+
+func TestRouter_ProbeHealth(t *testing.T) {
+	router := NewRouter(&Config{Main: "good"})
+	router.Register("good", allm.New(&healthCheckProvider{MockProvider: allmtest.NewMockProvider("good")}))
+	router.Register("bad", allm.New(&healthCheckProvider{
+		MockProvider: allmtest.NewMockProvider("bad"),
+		err:          errors.New("401 unauthorized"),
+	}))
+
+	if !router.IsHealthy("bad") {
+		t.Error("unprobed provider should be assumed healthy")
+	}
+	if len(router.CachedHealth()) != 0 {
+		t.Error("cache should be empty before first probe")
+	}
+
+	results := router.ProbeHealth(context.Background())
+	if len(results) != 2 {
+		t.Fatalf("ProbeHealth returned %d results, want 2", len(results))
+	}
+	if !router.IsHealthy("good") {
+		t.Error("good provider should be healthy")
+	}
+	if router.IsHealthy("bad") {
+		t.Error("bad provider should be unhealthy")
+	}
+	if ph := router.CachedHealth()["bad"]; ph.Error == nil || ph.CheckedAt.IsZero() {
+		t.Errorf("cached result missing error or timestamp: %+v", ph)
+	}
+}
+
+// noListProvider hides the mock's model listing.
+type noListProvider struct {
+	allm.Provider
+}
+
+func TestRouter_ProbeHealthNoProbe(t *testing.T) {
+	mock := allmtest.NewMockProvider("custom", allmtest.WithResponse(&allm.Response{Content: "hi"}))
+	router := NewRouter(&Config{Main: "custom"})
+	router.Register("custom", allm.New(noListProvider{mock}))
+	router.Register("pooled", allm.New(NewKeyPool([]allm.Provider{noListProvider{allmtest.NewMockProvider("pooled")}}, 0)))
+
+	router.ProbeHealth(context.Background())
+	if mock.CallCount() != 0 {
+		t.Errorf("probe sent %d completions, want none", mock.CallCount())
+	}
+	for _, name := range []string{"custom", "pooled"} {
+		if ph := router.CachedHealth()[name]; !ph.NoProbe || !ph.OK || !ph.CheckedAt.IsZero() {
+			t.Errorf("%s health = %+v, want healthy and not probed", name, ph)
+		}
+	}
+
+	if _, err := router.QuickChat(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	router.ProbeHealth(context.Background())
+	if ph := router.CachedHealth()["custom"]; !ph.NoProbe || !ph.OK || ph.CheckedAt.IsZero() {
+		t.Errorf("health after a request = %+v, want the request recorded", ph)
+	}
+}
+
+func TestRouter_HealthFallback(t *testing.T) {
+	router := NewRouter(&Config{Main: "main"})
+	router.Register("main", allm.New(&healthCheckProvider{
+		MockProvider: allmtest.NewMockProvider("main", allmtest.WithResponse(&allm.Response{Content: "from main"})),
+		err:          errors.New("401 unauthorized"),
+	}))
+	router.Register("backup", allm.New(&healthCheckProvider{
+		MockProvider: allmtest.NewMockProvider("backup", allmtest.WithResponse(&allm.Response{Content: "from backup"})),
+	}))
+	router.Register("routed", allm.New(&healthCheckProvider{
+		MockProvider: allmtest.NewMockProvider("routed"),
+		err:          errors.New("connection refused"),
+	}))
+
+	// Before any probe every provider counts as healthy
+	if resp, err := router.QuickChat(context.Background(), "hi"); err != nil || resp != "from main" {
+		t.Fatalf("QuickChat before probing = %q, %v", resp, err)
+	}
+
+	router.ProbeHealth(context.Background())
+	if resp, err := router.QuickChat(context.Background(), "hi"); err != nil || resp != "from backup" {
+		t.Errorf("QuickChat with main unhealthy = %q, %v; want the healthy provider", resp, err)
+	}
+
+	// An unhealthy routed provider falls back to main, and main to backup
+	req := &Request{Provider: "routed", Messages: []Message{{Role: "user", Content: "hi"}}}
+	name, _, err := router.clientFor(context.Background(), req)
+	if err != nil || name != "backup" || req.Provider != "backup" {
+		t.Errorf("clientFor = %q, %v (req.Provider %q); want backup", name, err, req.Provider)
+	}
+
+	// With no healthy provider left, main is used as before
+	router.Unregister("backup")
+	if name, _, err := router.clientFor(context.Background(), &Request{}); err != nil || name != "main" {
+		t.Errorf("clientFor without a healthy provider = %q, %v; want main", name, err)
+	}
+}
+
+func TestRouter_StartHealthProbe_Disabled(t *testing.T) {
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", allm.New(allmtest.NewMockProvider("test")))
+
+	router.StartHealthProbe(context.Background(), 0)
+	time.Sleep(20 * time.Millisecond)
+	if len(router.CachedHealth()) != 0 {
+		t.Error("zero interval should disable probing")
+	}
+}
+
+// statusErr mimics provider SDK errors that expose an HTTP status field.
+type statusErr struct{ StatusCode int }
+
+func (e *statusErr) Error() string { return fmt.Sprintf("HTTP %d", e.StatusCode) }
+
+func TestRouter_RequestLogging(t *testing.T) {
+	tests := []struct {
+		name       string
+		logPrompts bool
+		redact     bool
+		want       string
+		notWant    string
+	}{
+		{"content off by default", false, false, "roles=user", "top-secret-question"},
+		{"log prompts", true, false, "prompt=top-secret-question", ""},
+		{"log prompts redacted", true, true, "[redacted 19 chars]", "top-secret-question"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			mock := allmtest.NewMockProvider("test", allmtest.WithResponse(&allm.Response{Content: "OK", InputTokens: 5, OutputTokens: 2}))
+			router := NewRouter(&Config{Main: "test", Logger: logger, LogPrompts: tt.logPrompts, RedactMessages: tt.redact})
+			router.Register("test", allm.New(mock))
+
+			if _, err := router.QuickChat(context.Background(), "top-secret-question"); err != nil {
+				t.Fatalf("QuickChat: %v", err)
+			}
+			out := buf.String()
+			for _, want := range []string{"llm request", "provider=test", tt.want, "llm response", "tokens_in=5", "latency_ms="} {
+				if !strings.Contains(out, want) {
+					t.Errorf("log missing %q:\n%s", want, out)
+				}
+			}
+			if tt.notWant != "" && strings.Contains(out, tt.notWant) {
+				t.Errorf("log should not contain %q:\n%s", tt.notWant, out)
+			}
+		})
+	}
+}
+
+func TestRouter_RequestLogging_ErrorStatus(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	mock := allmtest.NewMockProvider("test", allmtest.WithError(fmt.Errorf("auth: %w", &statusErr{StatusCode: 401})))
+	router := NewRouter(&Config{Main: "test", Logger: logger})
+	router.Register("test", allm.New(mock))
+
+	if _, err := router.QuickChat(context.Background(), "hi"); err == nil {
+		t.Fatal("expected error")
+	}
+	out := buf.String()
+	if !strings.Contains(out, "llm request failed") || !strings.Contains(out, "status=401") || !strings.Contains(out, "provider=test") {
+		t.Errorf("failure log missing provider/status:\n%s", out)
+	}
+}
+
+func TestRouter_RequestLogging_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	mock := allmtest.NewMockProvider("test", allmtest.WithResponse(&allm.Response{Content: "OK", RequestID: "msg_1"}))
+	router := NewRouter(&Config{Main: "test", Logger: logger})
+	router.Register("test", allm.New(mock))
+
+	ctx := telemetry.WithRequestID(context.Background(), "0123456789abcdef")
+	if _, err := router.QuickChat(ctx, "hi"); err != nil {
+		t.Fatalf("QuickChat: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.Contains(line, "msg=\"llm ") && !strings.Contains(line, "request_id=0123456789abcdef") {
+			t.Errorf("log line missing the request ID: %s", line)
+		}
+	}
+	if !strings.Contains(buf.String(), "provider_request_id=msg_1") {
+		t.Errorf("log missing the provider's request ID:\n%s", buf.String())
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	if got := httpStatus(errors.Join(errors.New("x"), &statusErr{StatusCode: 500})); got != 500 {
+		t.Errorf("joined error status = %d, want 500", got)
+	}
+	if got := httpStatus(fmt.Errorf("wrap: %w", allm.ErrRateLimited)); got != 429 {
+		t.Errorf("rate limited status = %d, want 429", got)
+	}
+	if got := httpStatus(errors.New("plain")); got != 0 {
+		t.Errorf("plain error status = %d, want 0", got)
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"per-user rate limit", ErrRateLimited, false},
+		{"input too long", fmt.Errorf("wrap: %w", ErrInputTooLong), false},
+		{"canceled", context.Canceled, false},
+		{"no provider", fmt.Errorf("%w: provider %q not registered", ErrNoProvider, "x"), true},
+		{"provider failed", fmt.Errorf("%w: x: %w", ErrProviderFailed, errors.New("boom")), true},
+		{"provider rate limit", fmt.Errorf("%w: x: %w", ErrProviderFailed, allm.ErrRateLimited), true},
+		{"overloaded", allm.ErrOverloaded, true},
+		{"idle timeout", ErrTimeout, true},
+	}
+	for _, tt := range tests {
+		if got := IsUnavailable(tt.err); got != tt.want {
+			t.Errorf("%s: IsUnavailable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_OutageTracking(t *testing.T) {
+	router := NewRouter(&Config{Main: "down"})
+	router.Register("down", allm.New(allmtest.NewMockProvider("down", allmtest.WithError(allm.ErrServerError))))
+	router.Register("up", allm.New(allmtest.NewMockProvider("up", allmtest.WithResponse(&allm.Response{Content: "OK"}))))
+
+	stream := func() {
+		ch, err := router.StreamChat(context.Background(), "user1", []Message{{Role: "user", Content: "Hello"}})
+		if err != nil {
+			t.Fatalf("StreamChat: %v", err)
+		}
+		for range ch {
+		}
+	}
+
+	if _, down := router.Outage(); down {
+		t.Fatal("new router should not report an outage")
+	}
+	stream()
+	since, down := router.Outage()
+	if !down || since.IsZero() {
+		t.Fatal("failed request should start an outage")
+	}
+	stream()
+	if again, _ := router.Outage(); !again.Equal(since) {
+		t.Error("outage start should not move on repeated failures")
+	}
+
+	if err := router.SetMain("up"); err != nil {
+		t.Fatal(err)
+	}
+	stream()
+	if _, down := router.Outage(); down {
+		t.Error("successful request should clear the outage")
+	}
+}
+
+func TestRouter_StreamRequest_Model(t *testing.T) {
+	mock := allmtest.NewMockProvider("test",
+		allmtest.WithResponse(&allm.Response{Content: "Hello!"}),
+	)
+
+	client := allm.New(mock, allm.WithModel("base-model"))
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", client)
+
+	ch, err := router.StreamRequest(context.Background(), &Request{
+		UserID:   "user1",
+		Messages: []Message{{Role: "user", Content: "Hi"}},
+		Model:    "big-model",
+	})
+	if err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	for range ch {
+	}
+
+	if req := mock.LastRequest(); req == nil || req.Model != "big-model" {
+		t.Errorf("provider request model = %v, want big-model", req)
+	}
+	if got := client.Model(); got != "base-model" {
+		t.Errorf("client model after request = %q, want base-model", got)
+	}
+}
+
+func TestRouter_StreamRequest_Params(t *testing.T) {
+	mock := allmtest.NewMockProvider("test",
+		allmtest.WithResponse(&allm.Response{Content: "Hello!"}),
+	)
+	client := allm.New(mock)
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", client)
+
+	stream := func(req *Request) {
+		t.Helper()
+		ch, err := router.StreamRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("StreamRequest error: %v", err)
+		}
+		for range ch {
+		}
+	}
+
+	msgs := []Message{{Role: "user", Content: "Hi"}}
+	stream(&Request{UserID: "user1", Messages: msgs, MaxTokens: 512, Temperature: 0.2})
+	if req := mock.LastRequest(); req.MaxTokens != 512 || req.Temperature != 0.2 {
+		t.Errorf("provider request max_tokens/temperature = %d/%v, want 512/0.2", req.MaxTokens, req.Temperature)
+	}
+
+	seed := 42
+	stream(&Request{UserID: "user1", Messages: msgs, Stop: []string{"\n\n"}, Seed: &seed})
+	if req := mock.LastRequest(); len(req.Stop) != 1 || req.Stop[0] != "\n\n" || req.Seed == nil || *req.Seed != 42 {
+		t.Errorf("provider request stop/seed = %q/%v, want a blank-line stop and seed 42", req.Stop, req.Seed)
+	}
+	if client.Provider() != allm.Provider(mock) {
+		t.Error("client provider not restored after the request")
+	}
+
+	// Overrides apply to one request only
+	stream(&Request{UserID: "user1", Messages: msgs})
+	if req := mock.LastRequest(); req.MaxTokens != 0 || req.Temperature != 0 || req.Stop != nil || req.Seed != nil {
+		t.Errorf("next request max_tokens/temperature/stop/seed = %d/%v/%q/%v, want provider defaults", req.MaxTokens, req.Temperature, req.Stop, req.Seed)
+	}
+}
+
+// countingProvider counts every request as tokens input tokens.
+type countingProvider struct {
+	*allmtest.MockProvider
+	tokens int
+}
+
+func (p *countingProvider) CountTokens(context.Context, *allm.Request) (*allm.TokenCount, error) {
+	return &allm.TokenCount{InputTokens: p.tokens}, nil
+}
+
+func TestRouter_StreamRequest_StopKeepsTokenCounting(t *testing.T) {
+	p := &countingProvider{MockProvider: allmtest.NewMockProvider("test"), tokens: 500}
+	router := NewRouter(&Config{Main: "test"})
+	router.RegisterProvider("test", p, allm.WithMaxContextTokens(100), allm.WithTruncationStrategy(allm.TruncateNone))
+
+	ch, err := router.StreamRequest(context.Background(), &Request{
+		UserID:   "user1",
+		Messages: []Message{{Role: "user", Content: "Hi"}},
+		Stop:     []string{"END"},
+	})
+	if err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	var streamErr error
+	for chunk := range ch {
+		if chunk.Error != nil {
+			streamErr = chunk.Error
+		}
+	}
+	if streamErr == nil || !strings.Contains(streamErr.Error(), "context truncation") {
+		t.Errorf("stream error = %v, want max_context_tokens enforced behind the stop sequences", streamErr)
+	}
+}
+
+func TestRouter_StreamRequest_RequestID(t *testing.T) {
+	mock := allmtest.NewMockProvider("test",
+		allmtest.WithResponse(&allm.Response{Content: "Hello!", RequestID: "req_provider"}),
+	)
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", allm.New(mock))
+	ctx := telemetry.WithRequestID(context.Background(), "0123456789abcdef")
+
+	stream := func(req *Request) {
+		t.Helper()
+		ch, err := router.StreamRequest(ctx, req)
+		if err != nil {
+			t.Fatalf("StreamRequest error: %v", err)
+		}
+		for range ch {
+		}
+	}
+
+	seed := 1
+	req := &Request{UserID: "user1", Messages: []Message{{Role: "user", Content: "Hi"}}, Seed: &seed}
+	stream(req)
+	if req.RequestID != "0123456789abcdef" || req.ProviderRequestID != "req_provider" {
+		t.Errorf("request IDs = %q/%q, want the message's and the provider's", req.RequestID, req.ProviderRequestID)
+	}
+
+	req = &Request{UserID: "user1", Messages: []Message{{Role: "user", Content: "Hi"}}}
+	stream(req)
+	if req.RequestID != "0123456789abcdef" || req.ProviderRequestID != "" {
+		t.Errorf("streamed request IDs = %q/%q, want only the message's", req.RequestID, req.ProviderRequestID)
+	}
+}
+
+func TestRouter_StreamRequest_SeedFingerprint(t *testing.T) {
+	mock := allmtest.NewMockProvider("test",
+		allmtest.WithResponse(&allm.Response{Content: "Hello!", SystemFingerprint: "fp_123"}),
+	)
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", allm.New(mock))
+
+	seed := 7
+	req := &Request{UserID: "user1", Messages: []Message{{Role: "user", Content: "Hi"}}, Seed: &seed}
+	ch, err := router.StreamRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	var got string
+	for chunk := range ch {
+		got += chunk.Content
+	}
+	if got != "Hello!" {
+		t.Errorf("answer = %q, want Hello!", got)
+	}
+	if req.SystemFingerprint != "fp_123" {
+		t.Errorf("SystemFingerprint = %q, want fp_123", req.SystemFingerprint)
+	}
+	if last := mock.LastRequest(); last.Seed == nil || *last.Seed != 7 {
+		t.Errorf("provider request seed = %v, want 7", last.Seed)
+	}
+}
+
+func TestRouter_StreamRequest_FinishReason(t *testing.T) {
+	mock := allmtest.NewMockProvider("test", allmtest.WithStreamChunks([]allm.StreamChunk{
+		{Content: "The answer is"},
+		{Done: true, Usage: &allm.StreamUsage{InputTokens: 10, OutputTokens: 100}},
+	}))
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", allm.New(mock))
+
+	finish := func(req *Request) string {
+		t.Helper()
+		ch, err := router.StreamRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("StreamRequest error: %v", err)
+		}
+		for range ch {
+		}
+		return req.FinishReason
+	}
+
+	msgs := []Message{{Role: "user", Content: "Hi"}}
+	if got := finish(&Request{UserID: "u1", Messages: msgs}); got != FinishStop {
+		t.Errorf("unknown limit: finish reason = %q, want %q", got, FinishStop)
+	}
+	if got := finish(&Request{UserID: "u1", Messages: msgs, MaxTokens: 100}); got != FinishLength {
+		t.Errorf("request limit reached: finish reason = %q, want %q", got, FinishLength)
+	}
+	if got := finish(&Request{UserID: "u1", Messages: msgs, MaxTokens: 4096}); got != FinishStop {
+		t.Errorf("request limit not reached: finish reason = %q, want %q", got, FinishStop)
+	}
+	router.SetMaxTokens("test", 100)
+	if got := finish(&Request{UserID: "u1", Messages: msgs}); got != FinishLength {
+		t.Errorf("provider limit reached: finish reason = %q, want %q", got, FinishLength)
+	}
+
+	// Complete answers carry the provider's own finish reason
+	seed := 1
+	mock.SetResponse(&allm.Response{Content: "The answer", FinishReason: "max_tokens", OutputTokens: 10})
+	if got := finish(&Request{UserID: "u1", Messages: msgs, Seed: &seed}); got != FinishLength {
+		t.Errorf("provider said max_tokens: finish reason = %q, want %q", got, FinishLength)
+	}
+	mock.SetResponse(&allm.Response{Content: "The answer", FinishReason: "stop", OutputTokens: 100})
+	if got := finish(&Request{UserID: "u1", Messages: msgs, Seed: &seed}); got != FinishStop {
+		t.Errorf("provider said stop at the limit: finish reason = %q, want %q", got, FinishStop)
+	}
+}
+
+func TestRequestContinuation(t *testing.T) {
+	req := &Request{UserID: "u1", Messages: []Message{{Role: "user", Content: "Tell me a story"}}, MaxTokens: 50, FinishReason: FinishLength}
+	next := req.Continuation("Once upon a")
+
+	if len(req.Messages) != 1 || req.FinishReason != FinishLength {
+		t.Error("Continuation modified the original request")
+	}
+	if next.FinishReason != "" || next.MaxTokens != 50 || next.UserID != "u1" {
+		t.Errorf("Continuation did not copy the request settings: %+v", next)
+	}
+	if len(next.Messages) != 3 || next.Messages[1].Role != "assistant" || next.Messages[1].Content != "Once upon a" ||
+		next.Messages[2].Role != "user" || next.Messages[2].Content != ContinuePrompt {
+		t.Errorf("Continuation messages = %+v", next.Messages)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	for reason, want := range map[string]bool{"length": true, "max_tokens": true, "stop": false, "end_turn": false, "": false} {
+		if got := Truncated(reason); got != want {
+			t.Errorf("Truncated(%q) = %v, want %v", reason, got, want)
+		}
+	}
+}
+
+func TestRouter_StreamRequest_Reasoning(t *testing.T) {
+	mock := allmtest.NewMockProvider("test",
+		allmtest.WithResponse(&allm.Response{Content: "Hello!"}),
+	)
+	router := NewRouter(&Config{Main: "test", RateLimit: 100})
+	router.Register("test", allm.New(mock, allm.WithModel("gpt-4o")))
+
+	stream := func(req *Request) *allm.Request {
+		t.Helper()
+		req.UserID = "user1"
+		req.Messages = []Message{{Role: "user", Content: "Hi"}}
+		ch, err := router.StreamRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("StreamRequest error: %v", err)
+		}
+		for range ch {
+		}
+		return mock.LastRequest()
+	}
+
+	tests := []struct {
+		name       string
+		req        Request
+		wantEffort string
+		wantBudget int
+	}{
+		{"openai reasoning model", Request{Model: "o3-mini", ReasoningEffort: "high"}, "high", 0},
+		{"gpt-5 via vendor prefix", Request{Model: "openai/gpt-5", ReasoningEffort: "low"}, "low", 0},
+		{"o1-mini rejects effort", Request{Model: "o1-mini", ReasoningEffort: "high"}, "", 0},
+		{"non-reasoning openai model", Request{Model: "gpt-4o", ReasoningEffort: "high"}, "", 0},
+		{"claude effort picks budget", Request{Model: "claude-sonnet-4-6", ReasoningEffort: "medium"}, "", 8192},
+		{"claude explicit budget", Request{Model: "claude-opus-4-1", ReasoningEffort: "low", ThinkingBudget: 4000}, "", 4000},
+		{"claude budget below max tokens", Request{Model: "claude-3-7-sonnet", ReasoningEffort: "high", MaxTokens: 4096}, "", 4095},
+		{"claude budget under minimum", Request{Model: "claude-sonnet-4-6", ThinkingBudget: 100}, "", 0},
+		{"claude without thinking", Request{Model: "claude-3-5-haiku", ReasoningEffort: "high"}, "", 0},
+		{"other provider model", Request{Model: "llama3", ReasoningEffort: "high", ThinkingBudget: 4000}, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			got := stream(&req)
+			if got.Effort != tt.wantEffort {
+				t.Errorf("effort = %q, want %q", got.Effort, tt.wantEffort)
+			}
+			budget := 0
+			if got.Thinking != nil {
+				budget = got.Thinking.BudgetTokens
+			}
+			if budget != tt.wantBudget {
+				t.Errorf("thinking budget = %d, want %d", budget, tt.wantBudget)
+			}
+		})
+	}
+
+	// Settings apply to one request only
+	got := stream(&Request{Model: "o3"})
+	if got.Effort != "" || got.Thinking != nil {
+		t.Errorf("next request effort/thinking = %q/%v, want unset", got.Effort, got.Thinking)
+	}
+
+	// The budget stays below the provider's max_tokens when the request
+	// sets none, as with the example config's 4096
+	router.SetMaxTokens("test", 4096)
+	for _, effort := range []string{EffortMedium, EffortHigh} {
+		got := stream(&Request{Model: "claude-sonnet-4-6", ReasoningEffort: effort})
+		if got.Thinking == nil || got.Thinking.BudgetTokens != 4095 {
+			t.Errorf("%s effort with max_tokens 4096: thinking = %+v, want a 4095 token budget", effort, got.Thinking)
+		}
+	}
+}
+
+// gatedProvider streams only once release is closed and records how many
+// streams run at once.
+type gatedProvider struct {
+	*allmtest.MockProvider
+	release chan struct{}
+	mu      sync.Mutex
+	active  int
+	peak    int
+}
+
+func (p *gatedProvider) Stream(ctx context.Context, _ *allm.Request) <-chan allm.StreamChunk {
+	p.mu.Lock()
+	p.active++
+	p.peak = max(p.peak, p.active)
+	p.mu.Unlock()
+
+	ch := make(chan allm.StreamChunk, 1)
+	go func() {
+		defer close(ch)
+		defer func() {
+			p.mu.Lock()
+			p.active--
+			p.mu.Unlock()
+		}()
+		select {
+		case <-p.release:
+			ch <- allm.StreamChunk{Content: "OK", Done: true}
+		case <-ctx.Done():
+		}
+	}()
+	return ch
+}
+
+// Complete records the request and answers only once release is closed.
+func (p *gatedProvider) Complete(ctx context.Context, req *allm.Request) (*allm.Response, error) {
+	_, _ = p.MockProvider.Complete(ctx, req)
+	select {
+	case <-p.release:
+		return &allm.Response{Content: "OK"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestRouter_RequestDoesNotWaitForChat(t *testing.T) {
+	slow := &gatedProvider{MockProvider: allmtest.NewMockProvider("slow"), release: make(chan struct{})}
+	defer close(slow.release)
+	fast := allmtest.NewMockProvider("fast", allmtest.WithResponse(&allm.Response{Content: "Hello!"}))
+	router := NewRouter(&Config{Main: "slow"})
+	router.Register("slow", allm.New(slow))
+	router.Register("fast", allm.New(fast))
+
+	go router.QuickChat(context.Background(), "hi")
+	time.Sleep(20 * time.Millisecond)
+
+	// A request with its own model and sampling doesn't queue behind it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ch, err := router.StreamRequest(context.Background(), &Request{
+			UserID:      "user1",
+			Messages:    []Message{{Role: "user", Content: "Hi"}},
+			Provider:    "fast",
+			Model:       "other-model",
+			Temperature: 0.3,
+		})
+		if err != nil {
+			t.Errorf("StreamRequest error: %v", err)
+			return
+		}
+		for range ch {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("request waited for a chat in flight on another provider")
+	}
+}
+
+func TestRouter_RegisterProvider_KeepsOptions(t *testing.T) {
+	mock := allmtest.NewMockProvider("test", allmtest.WithResponse(&allm.Response{Content: "Hello!"}))
+	router := NewRouter(&Config{Main: "test"})
+	router.RegisterProvider("test", mock, allm.WithModel("base-model"), allm.WithMaxInputLen(10))
+
+	ch, err := router.StreamRequest(context.Background(), &Request{
+		UserID:   "user1",
+		Messages: []Message{{Role: "user", Content: "far longer than ten bytes"}},
+		Model:    "big-model",
+	})
+	if err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	var streamErr error
+	for chunk := range ch {
+		if chunk.Error != nil {
+			streamErr = chunk.Error
+		}
+	}
+	if !errors.Is(streamErr, allm.ErrInputTooLong) {
+		t.Errorf("stream error = %v, want the registered input limit", streamErr)
+	}
+}
+
+func TestRouter_MaxConcurrentPerProvider(t *testing.T) {
+	provider := &gatedProvider{MockProvider: allmtest.NewMockProvider("test"), release: make(chan struct{})}
+	router := NewRouter(&Config{Main: "test", RateLimit: 100, MaxConcurrentPerProvider: 2})
+	router.Register("test", allm.New(provider))
+
+	const requests = 6
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ch, err := router.StreamChat(context.Background(), fmt.Sprintf("user%d", i), []Message{{Role: "user", Content: "hi"}})
+			if err != nil {
+				t.Errorf("StreamChat: %v", err)
+				return
+			}
+			for range ch {
+			}
+		}(i)
+	}
+
+	// Two requests start, the rest queue
+	deadline := time.Now().Add(2 * time.Second)
+	for router.Stats()["in_flight"].(map[string]int)["test"] < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := router.Stats()["in_flight"].(map[string]int)["test"]; got != 2 {
+		t.Errorf("in flight = %d, want 2", got)
+	}
+
+	close(provider.release)
+	wg.Wait()
+	if provider.peak != 2 {
+		t.Errorf("peak concurrent streams = %d, want 2", provider.peak)
+	}
+	if got := router.Stats()["in_flight"].(map[string]int)["test"]; got != 0 {
+		t.Errorf("in flight after completion = %d, want 0", got)
+	}
+}
+
+func TestRouter_MaxConcurrentPerProvider_Cancel(t *testing.T) {
+	provider := &gatedProvider{MockProvider: allmtest.NewMockProvider("test"), release: make(chan struct{})}
+	router := NewRouter(&Config{Main: "test", MaxConcurrentPerProvider: 1})
+	router.Register("test", allm.New(provider))
+
+	// The first request holds the only slot until it is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	first, err := router.StreamChat(ctx, "user1", []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+
+	// A queued request gives up when its context ends
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer waitCancel()
+	if _, err := router.StreamChat(waitCtx, "user2", []Message{{Role: "user", Content: "hi"}}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("queued request error = %v, want deadline exceeded", err)
+	}
+
+	// So do chats and classifications, without reaching the provider
+	chatCtx, chatCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer chatCancel()
+	if _, err := router.QuickChat(chatCtx, "hi"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("queued chat error = %v, want deadline exceeded", err)
+	}
+	classifyCtx, classifyCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer classifyCancel()
+	if _, err := router.Classify(classifyCtx, "test", []string{"code"}, "hi"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("queued classification error = %v, want deadline exceeded", err)
+	}
+	if n := provider.CallCount(); n != 0 {
+		t.Errorf("provider got %d completions while its only slot was taken, want 0", n)
+	}
+
+	cancel()
+	for range first {
+	}
+
+	// Cancelling released the slot
+	ch, err := router.StreamChat(context.Background(), "user3", []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("StreamChat after cancel: %v", err)
+	}
+	close(provider.release)
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Errorf("unexpected error: %v", chunk.Error)
+		}
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"claude-sonnet-4-6", 200_000},
+		{"sonnet", 200_000},
+		{"gpt-4o-mini", 128_000},
+		{"openai/gpt-4.1", 1_047_576},
+		{"moonshot-v1-8k", 8_192},
+		{"GLM-4.7", 200_000},
+		{"my-finetune", 0},
+	}
+	for _, tt := range tests {
+		if got := ContextWindow(tt.model); got != tt.want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_FitContext(t *testing.T) {
+	router := NewRouter(&Config{ContextWindows: map[string]int{"small-model": 100}})
+	long := strings.Repeat("x", 300) // 79 tokens with overhead
+	messages := []allm.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "latest"},
+	}
+
+	got, err := router.fitContext("small-model", messages)
+	if err != nil {
+		t.Fatalf("fitContext error: %v", err)
+	}
+	if len(got) != 2 || got[0].Role != "system" || got[1].Content != "latest" {
+		t.Errorf("fitContext kept %+v, want system prompt and last message", got)
+	}
+
+	if got, _ := router.fitContext("unknown-model", messages); len(got) != len(messages) {
+		t.Error("unknown models should not be trimmed")
+	}
+
+	_, err = router.fitContext("small-model", []allm.Message{{Role: "user", Content: strings.Repeat("x", 1000)}})
+	var ce *ContextError
+	if !errors.As(err, &ce) || ce.Model != "small-model" || ce.Window != 100 {
+		t.Fatalf("fitContext error = %v, want *ContextError", err)
+	}
+	if !errors.Is(err, ErrInputTooLong) {
+		t.Error("ContextError should match ErrInputTooLong")
+	}
+	if msg := FormatError(err); !strings.Contains(msg, "small-model") || !strings.Contains(msg, "100") {
+		t.Errorf("FormatError = %q, want model and window", msg)
+	}
+}
+
+func TestRouter_StreamRequest_ContextWindow(t *testing.T) {
+	mock := allmtest.NewMockProvider("test",
+		allmtest.WithResponse(&allm.Response{Content: "Hello!"}),
+	)
+	router := NewRouter(&Config{Main: "test", ContextWindows: map[string]int{"small-model": 100}})
+	router.Register("test", allm.New(mock, allm.WithModel("small-model")))
+
+	_, err := router.StreamRequest(context.Background(), &Request{
+		UserID:   "user1",
+		Messages: []Message{{Role: "user", Content: strings.Repeat("x", 1000)}},
+	})
+	if !errors.Is(err, ErrInputTooLong) {
+		t.Fatalf("StreamRequest error = %v, want ErrInputTooLong", err)
+	}
+	if mock.LastRequest() != nil {
+		t.Error("provider should not be called when the prompt cannot fit")
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		model   string
+		in, out int
+		want    float64
+		known   bool
+	}{
+		{"gpt-4o", 1_000_000, 1_000_000, 12.5, true},
+		{"gpt-4o-mini", 1_000_000, 0, 0.15, true},
+		{"openai/gpt-4o-mini", 0, 1_000_000, 0.6, true},
+		{"claude-sonnet-4-6", 2_000, 1_000, 0.021, true},
+		{"llama3", 1_000, 1_000, 0, false},
+	}
+	for _, tt := range tests {
+		got, known := EstimateCost(tt.model, tt.in, tt.out)
+		if known != tt.known || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("EstimateCost(%q, %d, %d) = %v, %v; want %v, %v", tt.model, tt.in, tt.out, got, known, tt.want, tt.known)
+		}
+	}
+}
+
+func TestRouter_Bench(t *testing.T) {
+	fast := allmtest.NewMockProvider("fast",
+		allmtest.WithResponse(&allm.Response{Content: "fast answer", Model: "gpt-4o-mini", InputTokens: 10, OutputTokens: 20}),
+	)
+	broken := allmtest.NewMockProvider("broken", allmtest.WithError(errors.New("boom")))
+	local := allmtest.NewMockProvider("local",
+		allmtest.WithResponse(&allm.Response{Content: "local answer", InputTokens: 10, OutputTokens: 20}),
+	)
+
+	router := NewRouter(&Config{Main: "fast", RateLimit: 1})
+	router.Register("fast", allm.New(fast))
+	router.Register("broken", allm.New(broken))
+	router.Register("local", allm.New(local, allm.WithModel("llama3")))
+
+	results := router.Bench(context.Background(), "Hi", time.Second)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if last := results[2]; last.Provider != "broken" || last.Err == nil {
+		t.Errorf("failures should sort last, got %+v", last)
+	}
+	byName := map[string]BenchResult{}
+	for _, r := range results {
+		byName[r.Provider] = r
+	}
+	if r := byName["fast"]; r.Response != "fast answer" || r.Model != "gpt-4o-mini" || !r.CostKnown || r.InputTokens != 10 || r.OutputTokens != 20 {
+		t.Errorf("fast result = %+v", r)
+	}
+	if r := byName["local"]; r.Model != "llama3" || r.CostKnown {
+		t.Errorf("local result = %+v, want model llama3 with unknown cost", r)
+	}
+	for _, m := range []*allmtest.MockProvider{fast, broken, local} {
+		if req := m.LastRequest(); req == nil || req.Messages[len(req.Messages)-1].Content != "Hi" {
+			t.Errorf("%s: prompt not sent", m.Name())
+		}
+	}
+}
+
+// writeVocab writes a tiktoken encoding file with every single byte and,
+// after them, every prefix of each of tokens, so that byte-pair merging
+// builds each token up one byte at a time.
+func writeVocab(t *testing.T, tokens ...string) string {
+	t.Helper()
+	var b strings.Builder
+	seen := make(map[string]bool)
+	rank := 0
+	add := func(tok string) {
+		if seen[tok] {
+			return
+		}
+		seen[tok] = true
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), rank)
+		rank++
+	}
+	for i := 0; i < 256; i++ {
+		add(string([]byte{byte(i)}))
+	}
+	for _, tok := range tokens {
+		for i := 2; i <= len(tok); i++ {
+			add(tok[:i])
+		}
+	}
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTokenizer(t *testing.T) {
+	path := writeVocab(t, "hello", " hello", "  ")
+	tok, err := LoadTokenizer(path)
+	if err != nil {
+		t.Fatalf("LoadTokenizer failed: %v", err)
+	}
+	if again, _ := LoadTokenizer(path); again != tok {
+		t.Error("LoadTokenizer should reuse the loaded tokenizer")
+	}
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 1},
+		{"hello hello", 2},
+		{"xyz", 3},
+		{"héllo", 6}, // no merges: h, é (2 bytes), l, l, o
+		// A run of spaces leaves its last one to the next word: "a", "  ", " b"
+		{"a   b", 4},
+		{"a   ", 3}, // at the end the run stays whole: "a", "  " + " "
+	}
+	for _, tt := range tests {
+		if got := tok.CountTokens(tt.text); got != tt.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.tiktoken")
+	_ = os.WriteFile(bad, []byte("not-base64! 1\n"), 0o600)
+	if _, err := LoadTokenizer(bad); err == nil {
+		t.Error("Expected an error for a malformed encoding file")
+	}
+	if _, err := LoadTokenizer(filepath.Join(t.TempDir(), "missing.tiktoken")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestHeuristicTokenizerVsBPE(t *testing.T) {
+	// A vocabulary where, as in real encodings, each English word and each
+	// CJK character is one token and code punctuation is not merged
+	english := "the quick brown fox jumps over the lazy dog and runs far away"
+	cjk := "今天天气很好我们去公园散步吧"
+	code := "if (err != nil) { return fmt.Errorf(\"x: %w\", err); }"
+	var tokens []string
+	for _, w := range strings.Fields(english + " if return fmt Errorf err nil x w") {
+		tokens = append(tokens, w, " "+w)
+	}
+	for _, r := range cjk {
+		tokens = append(tokens, string(r))
+	}
+	tok, err := LoadTokenizer(writeVocab(t, tokens...))
+	if err != nil {
+		t.Fatalf("LoadTokenizer failed: %v", err)
+	}
+
+	for _, text := range []string{english, cjk, code} {
+		exact := tok.CountTokens(text)
+		heuristic := HeuristicTokenizer.CountTokens(text)
+		bytesOver4 := (len(text) + 3) / 4
+		if math.Abs(float64(heuristic-exact)) > 0.5*float64(exact) {
+			t.Errorf("%q: heuristic %d tokens, tokenizer %d: off by more than half", text, heuristic, exact)
+		}
+		if math.Abs(float64(heuristic-exact)) > math.Abs(float64(bytesOver4-exact)) {
+			t.Errorf("%q: heuristic %d is further from the tokenizer's %d than bytes/4 (%d)", text, heuristic, exact, bytesOver4)
+		}
+	}
+}
+
+func TestRouter_Tokenizer(t *testing.T) {
+	bpe, err := LoadTokenizer(writeVocab(t, "hello"))
+	if err != nil {
+		t.Fatalf("LoadTokenizer failed: %v", err)
+	}
+	router := NewRouter(&Config{Tokenizers: map[string]Tokenizer{"gpt-4": HeuristicTokenizer, "gpt-4o": bpe}})
+
+	if router.tokenizer("gpt-4o-mini") != bpe || router.tokenizer("openai/gpt-4o") != bpe {
+		t.Error("Expected the longest matching prefix to win")
+	}
+	if router.tokenizer("gpt-4-turbo") != HeuristicTokenizer || router.tokenizer("llama3") != HeuristicTokenizer {
+		t.Error("Expected the heuristic for other models")
+	}
+}
+
+func TestRuleRoute(t *testing.T) {
+	classified := 0
+	classify := func(_ context.Context, text string) string {
+		classified++
+		if strings.Contains(text, "poem") {
+			return "creative"
+		}
+		return "other"
+	}
+	route := RuleRoute([]RoutingRule{
+		{Name: "code", Provider: "coder", Code: true},
+		{Name: "translate", Provider: "cheap", Model: "small", Keywords: []string{"translate", "in english"}},
+		{Name: "short", Provider: "cheap", MaxLength: 10},
+		{Name: "creative", Provider: "writer", Class: "creative"},
+		{Name: "long", Provider: "big", MinLength: 200},
+	}, classify)
+
+	tests := []struct {
+		text            string
+		provider, model string
+	}{
+		{"```go\nfmt.Println(1)\n```", "coder", ""},
+		{"func main() {\n\treturn\n}", "coder", ""},
+		{"Please Translate this sentence", "cheap", "small"},
+		{"say it in English, please", "cheap", "small"},
+		{"translated words are not keywords", "", ""},
+		{"hi there", "cheap", ""},
+		{"write me a poem about the sea", "writer", ""},
+		{strings.Repeat("tell me more ", 20), "big", ""},
+		{"what is the weather like today?", "", ""}, // no rule: the default
+	}
+	for _, tt := range tests {
+		req := &Request{Messages: []Message{
+			{Role: "user", Content: "translate an old message"},
+			{Role: "assistant", Content: "ok"},
+			{Role: "user", Content: tt.text},
+		}}
+		provider, model := route(context.Background(), req)
+		if provider != tt.provider || model != tt.model {
+			t.Errorf("route(%q) = %q, %q; want %q, %q", tt.text, provider, model, tt.provider, tt.model)
+		}
+	}
+	// Only messages reaching the class rule are classified
+	if classified != 4 {
+		t.Errorf("classifier called %d times, want 4", classified)
+	}
+}
+
+func TestRouter_StreamRequest_Routed(t *testing.T) {
+	mainMock := allmtest.NewMockProvider("main", allmtest.WithResponse(&allm.Response{Content: "main"}))
+	cheapMock := allmtest.NewMockProvider("cheap", allmtest.WithResponse(&allm.Response{Content: "cheap"}))
+	router := NewRouter(&Config{Main: "main"})
+	router.Register("main", allm.New(mainMock, allm.WithModel("main-model")))
+	router.Register("cheap", allm.New(cheapMock, allm.WithModel("cheap-model")))
+	router.SetRouteFunc(RuleRoute([]RoutingRule{
+		{Provider: "cheap", Model: "tiny", Keywords: []string{"quick"}},
+		{Provider: "missing", Keywords: []string{"gone"}},
+	}, nil))
+
+	send := func(text string) *Request {
+		req := &Request{UserID: "user1", Messages: []Message{{Role: "user", Content: text}}}
+		ch, err := router.StreamRequest(context.Background(), req)
+		if err != nil {
+			t.Fatalf("StreamRequest(%q) error: %v", text, err)
+		}
+		for range ch {
+		}
+		return req
+	}
+
+	req := send("a quick question")
+	if cheapMock.CallCount() != 1 || mainMock.CallCount() != 0 {
+		t.Fatalf("calls main=%d cheap=%d, want the cheap provider", mainMock.CallCount(), cheapMock.CallCount())
+	}
+	if last := cheapMock.LastRequest(); last.Model != "tiny" {
+		t.Errorf("routed model = %q, want tiny", last.Model)
+	}
+	if req.Provider != "cheap" || req.Model != "tiny" {
+		t.Errorf("request records %q/%q, want cheap/tiny", req.Provider, req.Model)
+	}
+
+	req = send("a long question")
+	if p, m := router.Served(req); p != "main" || m != "main-model" {
+		t.Errorf("Served() = %q/%q, want main/main-model", p, m)
+	}
+	send("provider gone")
+	if mainMock.CallCount() != 2 || cheapMock.CallCount() != 1 {
+		t.Errorf("calls main=%d cheap=%d, want unmatched and unregistered routes on main", mainMock.CallCount(), cheapMock.CallCount())
+	}
+
+	// An explicit model skips routing
+	mainMock.Reset()
+	ch, err := router.StreamRequest(context.Background(), &Request{
+		UserID: "user1", Model: "pinned", Messages: []Message{{Role: "user", Content: "quick"}},
+	})
+	if err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	for range ch {
+	}
+	if last := mainMock.LastRequest(); last == nil || last.Model != "pinned" {
+		t.Errorf("pinned request = %+v, want the main provider with model pinned", last)
+	}
+}
+
+func TestRouter_Classify(t *testing.T) {
+	mock := allmtest.NewMockProvider("small", allmtest.WithResponse(&allm.Response{Content: "Label: Code."}))
+	router := NewRouter(&Config{Main: "small"})
+	router.Register("small", allm.New(mock))
+
+	label, err := router.Classify(context.Background(), "small", []string{"chat", "code"}, "fix my loop")
+	if err != nil {
+		t.Fatalf("Classify error: %v", err)
+	}
+	if label != "code" {
+		t.Errorf("Classify = %q, want code", label)
+	}
+
+	mock.SetResponse(&allm.Response{Content: "no idea"})
+	if label, _ := router.Classify(context.Background(), "small", []string{"chat", "code"}, "hmm"); label != "" {
+		t.Errorf("Classify = %q, want no label", label)
+	}
+	if _, err := router.Classify(context.Background(), "missing", []string{"chat"}, "hi"); !errors.Is(err, ErrNoProvider) {
+		t.Errorf("Classify with unknown provider error = %v, want ErrNoProvider", err)
+	}
+}
+
+func TestRouter_ModelStats(t *testing.T) {
+	mock := allmtest.NewMockProvider("test", allmtest.WithResponse(&allm.Response{Content: "OK"}))
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", allm.New(mock, allm.WithModel("m1")))
+	router.RestoreModelStats([]ModelStat{
+		{Provider: "test", Model: "m1", Requests: 50, P50: time.Second},
+		{Provider: "test", Model: "old", Requests: 7, LastError: "boom"},
+	})
+
+	send := func() {
+		ch, err := router.StreamRequest(context.Background(), &Request{UserID: "u", Messages: []Message{{Role: "user", Content: "hi"}}})
+		if err != nil {
+			t.Fatalf("StreamRequest error: %v", err)
+		}
+		for range ch {
+		}
+	}
+	send()
+	send()
+	mock.SetError(errors.New("overloaded"))
+	send()
+
+	stats := router.ModelStats()
+	st := stats["test/m1"]
+	if st.Requests != 3 || st.Errors != 1 || st.LastError == "" || st.LastErrorAt.IsZero() {
+		t.Errorf("test/m1 = %+v, want 3 requests, 1 error, and the last error", st)
+	}
+	if st.ErrorRate < 0.33 || st.ErrorRate > 0.34 {
+		t.Errorf("error rate = %v, want 1/3", st.ErrorRate)
+	}
+	if st.P50 <= 0 || st.P95 < st.P50 || st.P50 >= time.Second {
+		t.Errorf("latencies p50=%v p95=%v, want fresh measurements", st.P50, st.P95)
+	}
+	if old := stats["test/old"]; old.Requests != 7 || old.LastError != "boom" {
+		t.Errorf("restored test/old = %+v, want it as saved", old)
+	}
+}
+
+func TestModelStatsWindow(t *testing.T) {
+	s := newModelStats()
+	for i := 1; i <= modelStatWindow+100; i++ {
+		s.record("p", "m", time.Duration(i)*time.Millisecond, nil)
+	}
+	s.record("p", "m", time.Second, context.Canceled) // not the provider's failure
+
+	st := s.windows["p/m"].stat()
+	if st.Requests != modelStatWindow || st.Errors != 0 {
+		t.Fatalf("stat = %+v, want a full window without errors", st)
+	}
+	// The window holds 101ms..300ms
+	if st.P50 != 200*time.Millisecond || st.P95 != 290*time.Millisecond {
+		t.Errorf("p50=%v p95=%v, want 200ms and 290ms", st.P50, st.P95)
+	}
+}
+
+func TestSupportsVision(t *testing.T) {
+	for model, want := range map[string]bool{
+		"claude-sonnet-4-6":             true,
+		"gpt-4o-mini":                   true,
+		"openai/gpt-4.1":                true,
+		"o3-mini":                       false,
+		"o3":                            true,
+		"glm-4.6":                       false,
+		"glm-4.5v":                      true,
+		"kimi-k2-0905-preview":          false,
+		"moonshot-v1-8k-vision-preview": true,
+		"qwen2.5-vl-72b-instruct":       true,
+		"meta-llama/llama-3.3-70b":      false,
+		"":                              false,
+	} {
+		if got := SupportsVision(model); got != want {
+			t.Errorf("SupportsVision(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
+
+func TestRouter_StreamRequest_Vision(t *testing.T) {
+	textMock := allmtest.NewMockProvider("text", allmtest.WithResponse(&allm.Response{Content: "text"}))
+	eyesMock := allmtest.NewMockProvider("eyes", allmtest.WithResponse(&allm.Response{Content: "eyes"}))
+	router := NewRouter(&Config{Main: "text"})
+	router.Register("text", allm.New(textMock, allm.WithModel("glm-4.6")))
+	router.Register("eyes", allm.New(eyesMock, allm.WithModel("gpt-4o")))
+
+	photo := Message{Role: "user", Content: "what is this?", Images: []Image{ImageFromBytes("image/png", []byte("png"))}}
+	send := func(r *Router, req *Request) error {
+		ch, err := r.StreamRequest(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		for range ch {
+		}
+		return nil
+	}
+
+	req := &Request{UserID: "u1", Messages: []Message{photo}}
+	if err := send(router, req); err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	if eyesMock.CallCount() != 1 || textMock.CallCount() != 0 {
+		t.Fatalf("calls text=%d eyes=%d, want the image on the vision provider", textMock.CallCount(), eyesMock.CallCount())
+	}
+	if last := eyesMock.LastRequest(); len(last.Messages) == 0 || len(last.Messages[len(last.Messages)-1].Images) != 1 {
+		t.Error("image was not sent to the vision provider")
+	}
+	if req.Provider != "eyes" || req.Model != "" {
+		t.Errorf("request records %q/%q, want eyes with its own model", req.Provider, req.Model)
+	}
+
+	// Text stays on the main provider
+	if err := send(router, &Request{UserID: "u1", Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	if textMock.CallCount() != 1 {
+		t.Errorf("text calls = %d, want 1", textMock.CallCount())
+	}
+
+	// A per-request model that can't see moves too, even on a main provider that can
+	eyesMock.Reset()
+	mainEyes := NewRouter(&Config{Main: "eyes"})
+	mainEyes.Register("eyes", allm.New(eyesMock, allm.WithModel("gpt-4o")))
+	req = &Request{UserID: "u1", Model: "o3-mini", Messages: []Message{photo}}
+	if err := send(mainEyes, req); err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	if last := eyesMock.LastRequest(); last == nil || last.Model != "gpt-4o" {
+		t.Errorf("request = %+v, want the provider's own vision model", last)
+	}
+
+	// vision_models marks models magabot doesn't know
+	textMock.Reset()
+	marked := NewRouter(&Config{Main: "text", VisionModels: map[string]bool{"glm-4.6": true}})
+	marked.Register("text", allm.New(textMock, allm.WithModel("glm-4.6")))
+	marked.Register("eyes", allm.New(eyesMock, allm.WithModel("gpt-4o")))
+	if err := send(marked, &Request{UserID: "u1", Messages: []Message{photo}}); err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	if textMock.CallCount() != 1 {
+		t.Errorf("text calls = %d, want the marked model to keep the image", textMock.CallCount())
+	}
+
+	// No provider can see
+	blind := NewRouter(&Config{Main: "text"})
+	blind.Register("text", allm.New(textMock, allm.WithModel("glm-4.6")))
+	err := send(blind, &Request{UserID: "u1", Messages: []Message{photo}})
+	if !errors.Is(err, ErrNoVision) {
+		t.Fatalf("err = %v, want ErrNoVision", err)
+	}
+	if msg := FormatError(err); !strings.Contains(msg, "images") {
+		t.Errorf("FormatError = %q, want a note about images", msg)
+	}
+}
+
+// toolProvider asks for a search until it has seen a tool result, then
+// answers with it.
+type toolProvider struct {
+	*allmtest.MockProvider
+}
+
+func (p *toolProvider) Complete(ctx context.Context, req *allm.Request) (*allm.Response, error) {
+	p.MockProvider.Complete(ctx, req) // records the request
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role == allm.RoleTool {
+		return &allm.Response{Content: "It is " + last.ToolResults[0].Content, InputTokens: 20, OutputTokens: 5}, nil
+	}
+	return &allm.Response{
+		ToolCalls:   []allm.ToolCall{{ID: "call_1", Name: "search", Arguments: json.RawMessage(`{"q":"weather jakarta"}`)}},
+		InputTokens: 10, OutputTokens: 3,
+	}, nil
+}
+
+func TestRouter_StreamRequest_Tools(t *testing.T) {
+	p := &toolProvider{allmtest.NewMockProvider("test")}
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", allm.New(p))
+
+	var calls []ToolCall
+	req := &Request{
+		UserID:   "user1",
+		Messages: []Message{{Role: "user", Content: "Weather in Jakarta?"}},
+		Tools:    []Tool{{Name: "search", Description: "Search the web"}},
+		RunTool: func(_ context.Context, call ToolCall) ToolResult {
+			calls = append(calls, call)
+			return ToolResult{Content: "sunny"}
+		},
+	}
+	ch, err := router.StreamRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	var answer string
+	var usage *StreamUsage
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+		answer += chunk.Content
+		if chunk.Done {
+			usage = chunk.Usage
+		}
+	}
+
+	if answer != "It is sunny" {
+		t.Errorf("answer = %q", answer)
+	}
+	if len(calls) != 1 || string(calls[0].Arguments) != `{"q":"weather jakarta"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if usage == nil || usage.InputTokens != 30 || usage.OutputTokens != 8 {
+		t.Errorf("usage = %+v, want both rounds counted", usage)
+	}
+	reqs := p.Requests()
+	if len(reqs) != 2 || len(reqs[0].Tools) != 1 {
+		t.Fatalf("provider saw %d requests, want 2 offering the tool", len(reqs))
+	}
+	if got := reqs[1].Messages[len(reqs[1].Messages)-1].ToolResults; len(got) != 1 || got[0].ToolCallID != "call_1" {
+		t.Errorf("tool results sent back = %+v", got)
+	}
+}
+
+func TestRouter_StreamRequest_ToolRoundsCapped(t *testing.T) {
+	mock := allmtest.NewMockProvider("test", allmtest.WithResponse(&allm.Response{
+		ToolCalls: []allm.ToolCall{{ID: "call", Name: "search", Arguments: json.RawMessage(`{}`)}},
+	}))
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", allm.New(mock))
+
+	runs := 0
+	ch, err := router.StreamRequest(context.Background(), &Request{
+		UserID:   "user1",
+		Messages: []Message{{Role: "user", Content: "Loop forever"}},
+		Tools:    []Tool{{Name: "search"}},
+		RunTool: func(context.Context, ToolCall) ToolResult {
+			runs++
+			return ToolResult{Content: "nothing"}
+		},
+	})
+	if err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	var streamErr error
+	for chunk := range ch {
+		if chunk.Error != nil {
+			streamErr = chunk.Error
+		}
+	}
+
+	if runs != MaxToolRounds {
+		t.Errorf("tool ran %d times, want %d", runs, MaxToolRounds)
+	}
+	reqs := mock.Requests()
+	last := reqs[len(reqs)-1]
+	if len(last.Tools) != 1 {
+		t.Error("the last round should still offer the tools its history refers to")
+	}
+	if note := last.Messages[len(last.Messages)-1]; note.Role != allm.RoleUser || note.Content != answerNowPrompt {
+		t.Errorf("last round ends with %+v, want the answer-now note", note)
+	}
+	if !errors.Is(streamErr, errNoToolAnswer) {
+		t.Errorf("stream error = %v, want errNoToolAnswer instead of a blank answer", streamErr)
+	}
+}
+
+func TestRouter_StreamRequest_ToolsKeepReasoning(t *testing.T) {
+	p := &toolProvider{MockProvider: allmtest.NewMockProvider("test")}
+	router := NewRouter(&Config{Main: "test"})
+	router.Register("test", allm.New(p, allm.WithModel("claude-sonnet-4-5")))
+
+	ch, err := router.StreamRequest(context.Background(), &Request{
+		UserID:          "user1",
+		Messages:        []Message{{Role: "user", Content: "Weather in Jakarta?"}},
+		ReasoningEffort: EffortLow,
+		Tools:           []Tool{{Name: "search"}},
+		RunTool: func(context.Context, ToolCall) ToolResult {
+			return ToolResult{Content: "sunny"}
+		},
+	})
+	if err != nil {
+		t.Fatalf("StreamRequest error: %v", err)
+	}
+	for range ch {
+	}
+
+	for i, req := range p.Requests() {
+		if req.Thinking == nil || req.Thinking.BudgetTokens != thinkingBudgets[EffortLow] {
+			t.Errorf("round %d thinking = %+v, want the low effort budget", i+1, req.Thinking)
+		}
+	}
+}
+
+func TestRouter_RetryAfter_Retries(t *testing.T) {
+	var mu sync.Mutex
+	var hits []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		hits = append(hits, time.Now())
+		first := len(hits)%2 == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set("x-should-retry", "false")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"rate limit reached","type":"requests"}}`))
+			return
+		}
+		if bytes.Contains(body, []byte(`"stream":true`)) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, `data: {"id":"x","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"OK"},"finish_reason":"stop"}]}`+"\n\n")
+			_, _ = io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"x","object":"chat.completion","created":1,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"OK"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}))
+	defer srv.Close()
+
+	// allm's own retry would try again after a millisecond
+	limited := provider.OpenAICompatible(allm.Local, "key", provider.WithBaseURL(srv.URL), provider.WithDefaultModel("m"))
+	router := NewRouter(&Config{Main: "limited", RateLimit: 100})
+	router.RegisterProvider("limited", limited, allm.WithMaxRetries(2), allm.WithRetryBaseDelay(time.Millisecond))
+
+	checkRetried := func(what string) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		if len(hits) != 2 {
+			t.Fatalf("%s: provider called %d times, want twice", what, len(hits))
+		}
+		if gap := hits[1].Sub(hits[0]); gap < 900*time.Millisecond {
+			t.Errorf("%s: retried after %v, want the 1s Retry-After", what, gap)
+		}
+		hits = nil
+	}
+
+	answer, err := router.QuickChat(context.Background(), "Hi")
+	if err != nil || answer != "OK" {
+		t.Fatalf("QuickChat = %q, %v; want OK after a retry", answer, err)
+	}
+	checkRetried("chat")
+
+	ch, err := router.StreamChat(context.Background(), "user1", []Message{{Role: "user", Content: "Hi"}})
+	if err != nil {
+		t.Fatalf("StreamChat error: %v", err)
+	}
+	var content strings.Builder
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+		content.WriteString(chunk.Content)
+	}
+	if content.String() != "OK" {
+		t.Errorf("streamed answer = %q, want OK after a retry", content.String())
+	}
+	checkRetried("stream")
+
+	if left := router.cooldown("limited"); left != 0 {
+		t.Errorf("cooldown after a successful retry = %v, want none", left)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"30", 30 * time.Second, true},
+		{" 0 ", 0, true},
+		{"86400", MaxRetryAfter, true},
+		{"Sun, 01 Mar 2026 12:01:30 GMT", 90 * time.Second, true},
+		{"Sun, 01 Mar 2026 11:00:00 GMT", 0, true},
+		{"", 0, false},
+		{"-5", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRouter_RetryAfter(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "30")
+		w.Header().Set("x-should-retry", "false")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"message":"rate limit reached","type":"requests"}}`))
+	}))
+	defer srv.Close()
+
+	limited := provider.OpenAICompatible(allm.Local, "key", provider.WithBaseURL(srv.URL), provider.WithDefaultModel("m"))
+	router := NewRouter(&Config{Main: "limited"})
+	router.Register("limited", allm.New(limited))
+	router.Register("spare", allm.New(allmtest.NewMockProvider("spare", allmtest.WithResponse(&allm.Response{Content: "OK"}))))
+	request := func(providerName string) error {
+		ch, err := router.StreamRequest(context.Background(), &Request{
+			UserID:   "user1",
+			Messages: []Message{{Role: "user", Content: "Hi"}},
+			Provider: providerName,
+		})
+		if err != nil {
+			return err
+		}
+		for chunk := range ch {
+			if chunk.Error != nil {
+				return chunk.Error
+			}
+		}
+		return nil
+	}
+
+	err := request("")
+	var ra *RetryAfterError
+	if !errors.As(err, &ra) || ra.After != 30*time.Second || httpStatus(err) != http.StatusTooManyRequests {
+		t.Fatalf("err = %v, want a 429 retrying after 30s", err)
+	}
+
+	// Until then the provider isn't called again
+	err = request("")
+	if d, ok := RetryAfter(err); !ok || d <= 0 || d > 30*time.Second {
+		t.Errorf("err while cooling down = %v, want the time left", err)
+	}
+	if !IsUnavailable(err) {
+		t.Error("a provider cooling down should count as unavailable")
+	}
+	if hits.Load() != 1 {
+		t.Errorf("provider called %d times, want once", hits.Load())
+	}
+
+	// A request routed to the cooling provider goes to the main one instead
+	if err := router.SetMain("spare"); err != nil {
+		t.Fatal(err)
+	}
+	if err := request("limited"); err != nil {
+		t.Errorf("routed request = %v, want the main provider's answer", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("provider called %d times, want once", hits.Load())
+	}
+}
+
+// probingProvider's Available calls probe, as a provider that checks its
+// server would take its time.
+type probingProvider struct {
+	*allmtest.MockProvider
+	probe func()
+}
+
+func (p *probingProvider) Available() bool {
+	p.probe()
+	return true
+}
+
+func TestRouter_RegisterProbesOutsideLock(t *testing.T) {
+	router := NewRouter(&Config{})
+	// Providers takes the router's lock, so a probe under it would deadlock
+	probe := func() { router.Providers() }
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.Register("a", allm.New(&probingProvider{allmtest.NewMockProvider("a"), probe}))
+		router.Register("b", allm.New(&probingProvider{allmtest.NewMockProvider("b"), probe}))
+		router.Unregister("a")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Register/Unregister probed a provider while holding the router lock")
+	}
+
+	if got := router.MainProvider(); got != "b" {
+		t.Errorf("MainProvider() = %q, want b to take over", got)
+	}
+	if got := router.Providers(); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("Providers() = %v, want [b]", got)
+	}
+}
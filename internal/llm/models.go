@@ -4,6 +4,7 @@ package llm
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"time"
 
@@ -19,9 +20,26 @@ type ModelInfo struct {
 	Provider      string   `json:"provider"`
 	Description   string   `json:"description,omitempty"`
 	MaxTokens     int      `json:"max_tokens,omitempty"`
-	ContextWindow int      `json:"context_window,omitempty"` // from allm.Model
+	ContextWindow int      `json:"context_window,omitempty"` // tokens, from allm.Model or ContextWindow
 	MaxOutput     int      `json:"max_output,omitempty"`     // from allm.Model
 	Capabilities  []string `json:"capabilities,omitempty"`   // from allm.Model
+
+	SupportsVision bool `json:"supports_vision,omitempty"` // accepts images, see modelSupportsVision
+}
+
+// modelContextWindow returns the context window the provider reports for m,
+// or the known window for its ID when the provider leaves it out.
+func modelContextWindow(m allm.Model) int {
+	if m.ContextWindow > 0 {
+		return m.ContextWindow
+	}
+	return ContextWindow(m.ID)
+}
+
+// modelSupportsVision reports whether m accepts images: the provider lists
+// a "vision" capability, or SupportsVision knows the model.
+func modelSupportsVision(m allm.Model) bool {
+	return slices.Contains(m.Capabilities, "vision") || SupportsVision(m.ID)
 }
 
 // ListModels lists available models from a provider via API
@@ -50,9 +68,14 @@ func (r *Router) ListModels(ctx context.Context, providerName string) ([]ModelIn
 			ID:            m.ID,
 			Name:          m.Name,
 			Provider:      m.Provider,
-			ContextWindow: m.ContextWindow,
+			ContextWindow: modelContextWindow(m),
 			MaxOutput:     m.MaxOutput,
 			Capabilities:  m.Capabilities,
+
+			SupportsVision: modelSupportsVision(m),
+		}
+		if v, ok := r.visionModels[m.ID]; ok {
+			result[i].SupportsVision = v
 		}
 	}
 
@@ -139,6 +162,13 @@ func FetchModels(providerName, apiKey, baseURL string) ([]ModelInfo, error) {
 		}
 		p = provider.Local(baseURL)
 
+	case "ollama":
+		op, err := NewOllama(OllamaConfig{BaseURL: baseURL})
+		if err != nil {
+			return nil, err
+		}
+		p = op
+
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", providerName)
 	}
@@ -161,9 +191,11 @@ func FetchModels(providerName, apiKey, baseURL string) ([]ModelInfo, error) {
 			ID:            m.ID,
 			Name:          m.Name,
 			Provider:      m.Provider,
-			ContextWindow: m.ContextWindow,
+			ContextWindow: modelContextWindow(m),
 			MaxOutput:     m.MaxOutput,
 			Capabilities:  m.Capabilities,
+
+			SupportsVision: modelSupportsVision(m),
 		}
 	}
 
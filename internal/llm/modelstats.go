@@ -0,0 +1,150 @@
+// Rolling latency and error stats per provider and model
+package llm
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// modelStatWindow is the requests per model that ModelStats summarizes.
+const modelStatWindow = 200
+
+// ModelStat summarizes the recent requests to one provider and model.
+type ModelStat struct {
+	Provider string
+	Model    string
+
+	Requests  int           // requests in the window
+	Errors    int           // of those, failed for a provider-side reason (see IsUnavailable)
+	ErrorRate float64       // Errors / Requests
+	P50       time.Duration // latency of successful requests
+	P95       time.Duration
+
+	LastError   string // last provider-side error, kept after the window moves on
+	LastErrorAt time.Time
+	UpdatedAt   time.Time // last request; for restored stats, when they were saved
+}
+
+// modelSample is one finished request.
+type modelSample struct {
+	latency time.Duration
+	failed  bool
+}
+
+// modelWindow holds the last modelStatWindow samples of a model in a ring.
+type modelWindow struct {
+	provider, model string
+	samples         []modelSample
+	next            int
+	lastErr         string
+	lastErrAt       time.Time
+	updatedAt       time.Time
+	restored        *ModelStat // shown until the first request after a restart
+}
+
+// modelStats tracks a modelWindow per "provider/model".
+type modelStats struct {
+	mu      sync.Mutex
+	windows map[string]*modelWindow
+}
+
+func newModelStats() *modelStats {
+	return &modelStats{windows: make(map[string]*modelWindow)}
+}
+
+// record adds a finished request. Failures that are not the provider's
+// doing, such as cancellations or oversized input, are left out.
+func (s *modelStats) record(provider, model string, latency time.Duration, err error) {
+	if err != nil && !IsUnavailable(err) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := provider + "/" + model
+	w := s.windows[key]
+	if w == nil {
+		w = &modelWindow{provider: provider, model: model}
+		s.windows[key] = w
+	}
+	if w.restored != nil {
+		w.lastErr, w.lastErrAt = w.restored.LastError, w.restored.LastErrorAt
+		w.restored = nil
+	}
+
+	sample := modelSample{latency: latency, failed: err != nil}
+	if len(w.samples) < modelStatWindow {
+		w.samples = append(w.samples, sample)
+	} else {
+		w.samples[w.next] = sample
+		w.next = (w.next + 1) % modelStatWindow
+	}
+	w.updatedAt = time.Now()
+	if err != nil {
+		w.lastErr, w.lastErrAt = err.Error(), w.updatedAt
+	}
+}
+
+// stat summarizes w.
+func (w *modelWindow) stat() ModelStat {
+	if w.restored != nil {
+		return *w.restored
+	}
+	st := ModelStat{
+		Provider: w.provider, Model: w.model,
+		Requests:  len(w.samples),
+		LastError: w.lastErr, LastErrorAt: w.lastErrAt,
+		UpdatedAt: w.updatedAt,
+	}
+	latencies := make([]time.Duration, 0, len(w.samples))
+	for _, s := range w.samples {
+		if s.failed {
+			st.Errors++
+		} else {
+			latencies = append(latencies, s.latency)
+		}
+	}
+	if st.Requests > 0 {
+		st.ErrorRate = float64(st.Errors) / float64(st.Requests)
+	}
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		st.P50 = percentile(latencies, 50)
+		st.P95 = percentile(latencies, 95)
+	}
+	return st
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// ModelStats returns the recent latency and error stats of every provider
+// and model that served a request, keyed by "provider/model". Stats
+// restored with RestoreModelStats are returned as saved until the model
+// serves again.
+func (r *Router) ModelStats() map[string]ModelStat {
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	out := make(map[string]ModelStat, len(r.stats.windows))
+	for key, w := range r.stats.windows {
+		out[key] = w.stat()
+	}
+	return out
+}
+
+// RestoreModelStats seeds the stats of models that served no request yet,
+// as saved before a restart.
+func (r *Router) RestoreModelStats(stats []ModelStat) {
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	for i := range stats {
+		st := stats[i]
+		key := st.Provider + "/" + st.Model
+		if _, ok := r.stats.windows[key]; !ok {
+			r.stats.windows[key] = &modelWindow{provider: st.Provider, model: st.Model, restored: &st}
+		}
+	}
+}
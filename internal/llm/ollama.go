@@ -0,0 +1,428 @@
+// Ollama through its native API (/api/chat, /api/tags)
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kusa/magabot/internal/util"
+	"github.com/kusandriadi/allm-go"
+)
+
+// DefaultOllamaURL is where Ollama listens by default.
+const DefaultOllamaURL = "http://localhost:11434"
+
+const (
+	// ollamaProbeTimeout bounds the /api/tags request behind Available.
+	ollamaProbeTimeout = 3 * time.Second
+	// ollamaProbeTTL is how long an Available answer is reused.
+	ollamaProbeTTL = 30 * time.Second
+	// maxOllamaLine caps one line of a streamed /api/chat response.
+	maxOllamaLine = 4 << 20
+)
+
+// OllamaConfig configures the native Ollama provider.
+type OllamaConfig struct {
+	BaseURL     string // default DefaultOllamaURL; an OpenAI-style /v1 suffix is dropped
+	Model       string
+	MaxTokens   int     // num_predict (0 = model default)
+	Temperature float64 // 0 = model default
+	// KeepAlive is how long Ollama keeps the model loaded after a request,
+	// e.g. "10m", "1h", or "-1" for forever (empty = server default).
+	KeepAlive string
+	// HTTPClient sends the requests (nil = http.DefaultClient).
+	HTTPClient *http.Client
+}
+
+// ModelNotPulledError is returned when the Ollama server doesn't have the
+// requested model.
+type ModelNotPulledError struct {
+	Model string
+}
+
+func (e *ModelNotPulledError) Error() string {
+	return fmt.Sprintf("ollama: model %q is not pulled; run `ollama pull %s`", e.Model, e.Model)
+}
+
+// OllamaProvider talks to an Ollama server through its native API, which
+// unlike the OpenAI-compatible one supports keep_alive and reports models
+// that aren't pulled. Use the Local provider for other self-hosted servers.
+type OllamaProvider struct {
+	cfg OllamaConfig
+
+	mu         sync.Mutex
+	available  bool
+	probedAt   time.Time
+	probeClock func() time.Time
+}
+
+// NewOllama creates an Ollama provider. The base URL may point at
+// localhost or a private network.
+func NewOllama(cfg OllamaConfig) (*OllamaProvider, error) {
+	cfg.BaseURL = strings.TrimSuffix(strings.TrimSuffix(cfg.BaseURL, "/"), "/v1")
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultOllamaURL
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if err := util.ValidateLocalBaseURL(cfg.BaseURL); err != nil {
+		return nil, fmt.Errorf("invalid base URL for ollama: %w", err)
+	}
+	if cfg.KeepAlive != "" && cfg.KeepAlive != "-1" && cfg.KeepAlive != "0" {
+		if _, err := time.ParseDuration(cfg.KeepAlive); err != nil {
+			return nil, fmt.Errorf("ollama keep_alive %q: want a duration such as 10m, or -1", cfg.KeepAlive)
+		}
+	}
+	return &OllamaProvider{cfg: cfg, probeClock: time.Now}, nil
+}
+
+// Name returns the provider name.
+func (p *OllamaProvider) Name() string { return "ollama" }
+
+// Available reports whether the server answers /api/tags. The answer is
+// reused for ollamaProbeTTL, since it is asked before routing requests.
+func (p *OllamaProvider) Available() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.probeClock()
+	if !p.probedAt.IsZero() && now.Sub(p.probedAt) < ollamaProbeTTL {
+		return p.available
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaProbeTimeout)
+	defer cancel()
+	_, err := p.tags(ctx)
+	p.available, p.probedAt = err == nil, now
+	return p.available
+}
+
+type ollamaTags struct {
+	Models []struct {
+		Name    string `json:"name"`
+		Details struct {
+			Family   string   `json:"family"`
+			Families []string `json:"families"`
+		} `json:"details"`
+	} `json:"models"`
+}
+
+// tags fetches the models the server has pulled.
+func (p *OllamaProvider) tags(ctx context.Context) (*ollamaTags, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.statusError(resp, "")
+	}
+	var tags ollamaTags
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("ollama: parse tags: %w", err)
+	}
+	return &tags, nil
+}
+
+// Models lists the models the server has pulled.
+func (p *OllamaProvider) Models(ctx context.Context) ([]allm.Model, error) {
+	tags, err := p.tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]allm.Model, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		caps := []string{"streaming"}
+		for _, family := range append([]string{m.Details.Family}, m.Details.Families...) {
+			if family == "clip" || family == "mllama" {
+				caps = append(caps, "vision")
+				break
+			}
+		}
+		models = append(models, allm.Model{ID: m.Name, Name: m.Name, Provider: p.Name(), Capabilities: caps})
+	}
+	return models, nil
+}
+
+type ollamaMessage struct {
+	Role     string   `json:"role"`
+	Content  string   `json:"content"`
+	Thinking string   `json:"thinking,omitempty"`
+	Images   []string `json:"images,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	Think     bool            `json:"think,omitempty"`
+	KeepAlive any             `json:"keep_alive,omitempty"`
+	Format    any             `json:"format,omitempty"`
+	Options   map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// chatRequest converts req to an /api/chat request.
+func (p *OllamaProvider) chatRequest(req *allm.Request, stream bool) (*ollamaChatRequest, error) {
+	if len(req.Tools) > 0 {
+		return nil, fmt.Errorf("%w: tools with the ollama provider", allm.ErrNotSupported)
+	}
+	body := &ollamaChatRequest{
+		Model:  req.Model,
+		Stream: stream,
+		Think:  req.Thinking != nil || req.Effort != "",
+	}
+	if body.Model == "" {
+		body.Model = p.cfg.Model
+	}
+	if body.Model == "" {
+		return nil, fmt.Errorf("%w: ollama: no model configured", allm.ErrProvider)
+	}
+	switch p.cfg.KeepAlive {
+	case "":
+	case "-1", "0":
+		body.KeepAlive = json.Number(p.cfg.KeepAlive)
+	default:
+		body.KeepAlive = p.cfg.KeepAlive
+	}
+	if req.ResponseFormat != nil {
+		body.Format = "json"
+	}
+
+	for _, m := range req.Messages {
+		om := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, img := range m.Images {
+			om.Images = append(om.Images, base64.StdEncoding.EncodeToString(img.Data))
+		}
+		body.Messages = append(body.Messages, om)
+	}
+
+	options := map[string]any{}
+	maxTokens := cmp.Or(req.MaxTokens, p.cfg.MaxTokens)
+	if maxTokens > 0 {
+		options["num_predict"] = maxTokens
+	}
+	if temp := cmp.Or(req.Temperature, p.cfg.Temperature); temp > 0 {
+		options["temperature"] = temp
+	}
+	if req.TopP > 0 {
+		options["top_p"] = req.TopP
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+	if req.Seed != nil {
+		options["seed"] = *req.Seed
+	}
+	if len(options) > 0 {
+		body.Options = options
+	}
+	return body, nil
+}
+
+// post sends an /api/chat request.
+func (p *OllamaProvider) post(ctx context.Context, body *ollamaChatRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("ollama: marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.BaseURL+"/api/chat", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("ollama: create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := p.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ollamaContextError(ctx)
+		}
+		return nil, fmt.Errorf("%w: ollama: %w", allm.ErrProvider, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, p.statusError(resp, body.Model)
+	}
+	return resp, nil
+}
+
+// statusError turns an error response into an error, spotting models that
+// aren't pulled and keeping any Retry-After.
+func (p *OllamaProvider) statusError(resp *http.Response, model string) error {
+	data, _ := util.ReadHTTPBody(resp, 64*1024)
+	var body struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		msg = body.Error
+	}
+	if err := ollamaError(msg, model); err != nil {
+		return err
+	}
+	err := fmt.Errorf("ollama: HTTP %d: %s", resp.StatusCode, util.SanitizeErrorMessage(util.Truncate(msg, 300)))
+	if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		err = &RetryAfterError{Err: err, After: d}
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %w", allm.ErrRateLimited, err)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%w: %w", allm.ErrServerError, err)
+	default:
+		return fmt.Errorf("%w: %w", allm.ErrProvider, err)
+	}
+}
+
+// ollamaError returns a *ModelNotPulledError when msg says model isn't
+// pulled, e.g. `model "llama3" not found, try pulling it first`.
+func ollamaError(msg, model string) error {
+	lower := strings.ToLower(msg)
+	if !strings.Contains(lower, "not found") || !strings.Contains(lower, "model") {
+		return nil
+	}
+	if start := strings.Index(msg, `"`); start >= 0 {
+		if end := strings.Index(msg[start+1:], `"`); end > 0 {
+			model = msg[start+1 : start+1+end]
+		}
+	}
+	return &ModelNotPulledError{Model: model}
+}
+
+// ollamaContextError maps a done context to allm's timeout or cancel error.
+func ollamaContextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: ollama", allm.ErrTimeout)
+	}
+	return fmt.Errorf("%w: ollama", allm.ErrCanceled)
+}
+
+// Complete sends a request and returns the whole answer.
+func (p *OllamaProvider) Complete(ctx context.Context, req *allm.Request) (*allm.Response, error) {
+	body, err := p.chatRequest(req, false)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := p.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var out ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		if ctx.Err() != nil {
+			return nil, ollamaContextError(ctx)
+		}
+		return nil, fmt.Errorf("%w: ollama: parse response: %w", allm.ErrProvider, err)
+	}
+	if out.Error != "" {
+		if err := ollamaError(out.Error, body.Model); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: ollama: %s", allm.ErrProvider, util.SanitizeErrorMessage(out.Error))
+	}
+	return &allm.Response{
+		Content:      out.Message.Content,
+		Thinking:     out.Message.Thinking,
+		Provider:     p.Name(),
+		Model:        cmp.Or(out.Model, body.Model),
+		InputTokens:  out.PromptEvalCount,
+		OutputTokens: out.EvalCount,
+		Latency:      time.Since(start),
+		FinishReason: out.DoneReason, // "stop" or "length", as FinishStop and FinishLength,
+	}, nil
+}
+
+// Stream sends a request and streams the answer from Ollama's
+// newline-delimited JSON.
+func (p *OllamaProvider) Stream(ctx context.Context, req *allm.Request) <-chan allm.StreamChunk {
+	ch := make(chan allm.StreamChunk, 16)
+	go func() {
+		defer close(ch)
+		send := func(chunk allm.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		body, err := p.chatRequest(req, true)
+		if err != nil {
+			send(allm.StreamChunk{Error: err})
+			return
+		}
+		resp, err := p.post(ctx, body)
+		if err != nil {
+			send(allm.StreamChunk{Error: err})
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxOllamaLine)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var out ollamaChatResponse
+			if err := json.Unmarshal(line, &out); err != nil {
+				send(allm.StreamChunk{Error: fmt.Errorf("%w: ollama: parse chunk: %w", allm.ErrProvider, err)})
+				return
+			}
+			if out.Error != "" {
+				err := ollamaError(out.Error, body.Model)
+				if err == nil {
+					err = fmt.Errorf("%w: ollama: %s", allm.ErrProvider, util.SanitizeErrorMessage(out.Error))
+				}
+				send(allm.StreamChunk{Error: err})
+				return
+			}
+			if out.Message.Thinking != "" && !send(allm.StreamChunk{Thinking: out.Message.Thinking}) {
+				return
+			}
+			if out.Message.Content != "" && !send(allm.StreamChunk{Content: out.Message.Content}) {
+				return
+			}
+			if out.Done {
+				send(allm.StreamChunk{Done: true, Usage: &allm.StreamUsage{InputTokens: out.PromptEvalCount, OutputTokens: out.EvalCount}})
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			send(allm.StreamChunk{Error: ollamaContextError(ctx)})
+			return
+		}
+		if err := scanner.Err(); err != nil {
+			send(allm.StreamChunk{Error: fmt.Errorf("%w: ollama: read stream: %w", allm.ErrProvider, err)})
+			return
+		}
+		send(allm.StreamChunk{Error: fmt.Errorf("%w: ollama: stream ended before done", allm.ErrProvider)})
+	}()
+	return ch
+}
@@ -0,0 +1,216 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// mockOllama serves /api/tags and /api/chat like an Ollama server that has
+// pulled only "llama3.2:latest" and "llava:7b".
+func mockOllama(t *testing.T, chats *[]ollamaChatRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[
+				{"name":"llama3.2:latest","details":{"family":"llama","families":["llama"]}},
+				{"name":"llava:7b","details":{"family":"llama","families":["llama","clip"]}}]}`))
+		case "/api/chat":
+			var req ollamaChatRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if chats != nil {
+				*chats = append(*chats, req)
+			}
+			if req.Model != "llama3.2:latest" && req.Model != "llava:7b" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"model \"` + req.Model + `\" not found, try pulling it first"}`))
+				return
+			}
+			if !req.Stream {
+				_, _ = w.Write([]byte(`{"model":"` + req.Model + `","message":{"role":"assistant","content":"Hello there"},
+					"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":3}`))
+				return
+			}
+			for _, line := range []string{
+				`{"message":{"role":"assistant","content":"","thinking":"hmm"},"done":false}`,
+				`{"message":{"role":"assistant","content":"Hel"},"done":false}`,
+				`{"message":{"role":"assistant","content":"lo"},"done":false}`,
+				`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":12,"eval_count":2}`,
+			} {
+				_, _ = w.Write([]byte(line + "\n"))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOllamaComplete(t *testing.T) {
+	var chats []ollamaChatRequest
+	srv := mockOllama(t, &chats)
+	p, err := NewOllama(OllamaConfig{BaseURL: srv.URL + "/v1", Model: "llama3.2:latest", MaxTokens: 256, KeepAlive: "10m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.Complete(context.Background(), &allm.Request{
+		Messages: []allm.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "hi", Images: []allm.Image{{MimeType: "image/png", Data: []byte("png")}}},
+		},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if resp.Content != "Hello there" || resp.Provider != "ollama" || resp.Model != "llama3.2:latest" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.InputTokens != 12 || resp.OutputTokens != 3 || resp.FinishReason != FinishStop {
+		t.Errorf("usage = %d/%d, finish %q", resp.InputTokens, resp.OutputTokens, resp.FinishReason)
+	}
+
+	got := chats[0]
+	if got.Stream || got.KeepAlive != "10m" || len(got.Messages) != 2 || got.Messages[0].Role != "system" {
+		t.Errorf("request = %+v", got)
+	}
+	if imgs := got.Messages[1].Images; len(imgs) != 1 || imgs[0] != "cG5n" {
+		t.Errorf("images = %v, want base64 of the image", imgs)
+	}
+	if got.Options["num_predict"] != float64(256) || got.Options["temperature"] != 0.2 {
+		t.Errorf("options = %v", got.Options)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	srv := mockOllama(t, nil)
+	p, err := NewOllama(OllamaConfig{BaseURL: srv.URL, Model: "llama3.2:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var content, thinking strings.Builder
+	var usage *allm.StreamUsage
+	for chunk := range p.Stream(context.Background(), &allm.Request{Messages: []allm.Message{{Role: "user", Content: "hi"}}}) {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+		content.WriteString(chunk.Content)
+		thinking.WriteString(chunk.Thinking)
+		if chunk.Done {
+			usage = chunk.Usage
+		}
+	}
+	if content.String() != "Hello" || thinking.String() != "hmm" {
+		t.Errorf("content = %q, thinking = %q", content.String(), thinking.String())
+	}
+	if usage == nil || usage.InputTokens != 12 || usage.OutputTokens != 2 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestOllamaModelNotPulled(t *testing.T) {
+	srv := mockOllama(t, nil)
+	p, err := NewOllama(OllamaConfig{BaseURL: srv.URL, Model: "mistral"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &allm.Request{Messages: []allm.Message{{Role: "user", Content: "hi"}}}
+
+	_, err = p.Complete(context.Background(), req)
+	var mnp *ModelNotPulledError
+	if !errors.As(err, &mnp) || mnp.Model != "mistral" {
+		t.Fatalf("Complete error = %v, want ModelNotPulledError for mistral", err)
+	}
+	if msg := FormatError(err); !strings.Contains(msg, "ollama pull mistral") {
+		t.Errorf("FormatError = %q, want the pull command", msg)
+	}
+
+	var streamErr error
+	for chunk := range p.Stream(context.Background(), req) {
+		streamErr = chunk.Error
+	}
+	if !errors.As(streamErr, &mnp) {
+		t.Errorf("Stream error = %v, want ModelNotPulledError", streamErr)
+	}
+}
+
+func TestOllamaRetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "12")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":"server busy"}`))
+	}))
+	defer srv.Close()
+	p, err := NewOllama(OllamaConfig{BaseURL: srv.URL, Model: "llama3.2:latest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Complete(context.Background(), &allm.Request{Messages: []allm.Message{{Role: "user", Content: "hi"}}})
+	if d, ok := RetryAfter(err); !ok || d != 12*time.Second || !errors.Is(err, allm.ErrRateLimited) {
+		t.Errorf("Complete error = %v, want a rate limit retrying after 12s", err)
+	}
+}
+
+func TestOllamaModelsAndAvailable(t *testing.T) {
+	srv := mockOllama(t, nil)
+	p, err := NewOllama(OllamaConfig{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := p.Models(context.Background())
+	if err != nil {
+		t.Fatalf("Models: %v", err)
+	}
+	if len(models) != 2 || models[0].ID != "llama3.2:latest" || models[1].ID != "llava:7b" {
+		t.Fatalf("models = %+v", models)
+	}
+	if !modelSupportsVision(models[1]) {
+		t.Errorf("llava should support vision: %+v", models[1])
+	}
+
+	now := time.Now()
+	p.probeClock = func() time.Time { return now }
+	if !p.Available() {
+		t.Fatal("Available() = false with the server up")
+	}
+	srv.Close()
+	if !p.Available() {
+		t.Error("Available() should reuse a fresh answer")
+	}
+	now = now.Add(ollamaProbeTTL)
+	if p.Available() {
+		t.Error("Available() = true with the server down")
+	}
+}
+
+func TestNewOllamaValidation(t *testing.T) {
+	p, err := NewOllama(OllamaConfig{})
+	if err != nil || p.cfg.BaseURL != DefaultOllamaURL {
+		t.Errorf("NewOllama({}) = %+v, %v; want the default URL", p, err)
+	}
+	if _, err := NewOllama(OllamaConfig{KeepAlive: "soon"}); err == nil {
+		t.Error("NewOllama accepted keep_alive \"soon\"")
+	}
+	if _, err := NewOllama(OllamaConfig{BaseURL: "ftp://localhost:11434"}); err == nil {
+		t.Error("NewOllama accepted an ftp base URL")
+	}
+	if _, err := p.Complete(context.Background(), &allm.Request{Tools: []allm.Tool{{Name: "t"}}}); !errors.Is(err, allm.ErrNotSupported) {
+		t.Errorf("Complete with tools = %v, want ErrNotSupported", err)
+	}
+}
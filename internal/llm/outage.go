@@ -0,0 +1,63 @@
+// Provider outage detection for graceful degradation
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// IsUnavailable reports whether err means the LLM could not answer because
+// of the provider (none configured or reachable, server errors, overload,
+// provider-side rate limits, timeouts) rather than a problem with the
+// request itself or the per-user rate limit.
+func IsUnavailable(err error) bool {
+	switch {
+	case err == nil,
+		err == ErrRateLimited, // per-user limiter, returned unwrapped
+		errors.Is(err, ErrInputTooLong),
+		errors.Is(err, allm.ErrEmptyInput),
+		errors.Is(err, allm.ErrNotSupported),
+		errors.Is(err, allm.ErrCanceled),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+// outageState tracks when the main provider started failing. Guarded by Router.mu.
+type outageState struct {
+	since   time.Time
+	lastErr error
+}
+
+// recordResult updates the outage state after a request to the main provider.
+func (r *Router) recordResult(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		if !r.outage.since.IsZero() {
+			r.logger.Info("llm provider recovered", "provider", r.mainName, "down_for", time.Since(r.outage.since).Round(time.Second))
+		}
+		r.outage = outageState{}
+		return
+	}
+	if !IsUnavailable(err) {
+		return
+	}
+	if r.outage.since.IsZero() {
+		r.outage.since = time.Now()
+	}
+	r.outage.lastErr = err
+}
+
+// Outage reports whether the last request to the main provider failed for a
+// provider-side reason and, if so, since when. Callers use it to answer from
+// a fallback path instead of surfacing raw errors.
+func (r *Router) Outage() (since time.Time, down bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.outage.since, !r.outage.since.IsZero()
+}
@@ -0,0 +1,69 @@
+// System prompt template variables
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PromptVars are the values available to system prompt templates, e.g.
+// "Today is {{.Date}}. You are {{.BotName}}, chatting on {{.Platform}}."
+type PromptVars struct {
+	Date     string // current date, YYYY-MM-DD
+	UserID   string
+	Platform string
+	BotName  string
+}
+
+// NewPromptVars fills PromptVars for a request at time now.
+func NewPromptVars(now time.Time, userID, platform, botName string) PromptVars {
+	return PromptVars{
+		Date:     now.Format("2006-01-02"),
+		UserID:   userID,
+		Platform: platform,
+		BotName:  botName,
+	}
+}
+
+// PromptTemplate is a system prompt parsed once and expanded per request.
+type PromptTemplate struct {
+	raw  string
+	tmpl *template.Template // nil when raw has no template actions
+}
+
+// ParsePromptTemplate parses a system prompt. Unknown variables are
+// rejected here rather than at request time.
+func ParsePromptTemplate(name, text string) (*PromptTemplate, error) {
+	pt := &PromptTemplate{raw: text}
+	if !strings.Contains(text, "{{") {
+		return pt, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse system prompt %s: %w", name, err)
+	}
+	// Dry run catches references to fields PromptVars does not have
+	if err := tmpl.Execute(&strings.Builder{}, PromptVars{}); err != nil {
+		return nil, fmt.Errorf("system prompt %s: %w", name, err)
+	}
+	pt.tmpl = tmpl
+	return pt, nil
+}
+
+// Expand renders the prompt with vars. It falls back to the raw text if
+// execution fails, so a bad value never blanks the system prompt.
+func (p *PromptTemplate) Expand(vars PromptVars) string {
+	if p == nil {
+		return ""
+	}
+	if p.tmpl == nil {
+		return p.raw
+	}
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, vars); err != nil {
+		return p.raw
+	}
+	return b.String()
+}
@@ -0,0 +1,96 @@
+// Reasoning effort and extended thinking for models that support them
+package llm
+
+import (
+	"strings"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// Reasoning effort levels accepted in Request.ReasoningEffort.
+const (
+	EffortLow    = allm.EffortLow
+	EffortMedium = allm.EffortMedium
+	EffortHigh   = allm.EffortHigh
+)
+
+// ValidEffort reports whether level is a reasoning effort level.
+func ValidEffort(level string) bool {
+	return thinkingBudgets[level] > 0
+}
+
+// thinkingBudgets maps effort levels to Anthropic thinking budgets in tokens.
+var thinkingBudgets = map[string]int{
+	EffortLow:    1_024,
+	EffortMedium: 8_192,
+	EffortHigh:   32_768,
+}
+
+// minThinkingBudget is the smallest budget_tokens Anthropic accepts.
+const minThinkingBudget = 1_024
+
+// reasoningEffortModels are prefixes of OpenAI models that take
+// reasoning_effort. o1-mini and o1-preview predate it and reject it.
+var (
+	reasoningEffortModels   = []string{"o1", "o3", "o4", "gpt-5"}
+	noReasoningEffortModels = []string{"o1-mini", "o1-preview"}
+)
+
+// thinkingModels are prefixes of Claude models with extended thinking.
+var thinkingModels = []string{"claude-3-7", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4"}
+
+// SupportsReasoningEffort reports whether model accepts OpenAI's
+// reasoning_effort parameter. Vendor prefixes such as "openai/" are ignored.
+func SupportsReasoningEffort(model string) bool {
+	model = bareModel(model)
+	return hasAnyPrefix(model, reasoningEffortModels) && !hasAnyPrefix(model, noReasoningEffortModels)
+}
+
+// SupportsThinking reports whether model accepts Anthropic's thinking
+// parameter. Vendor prefixes such as "anthropic/" are ignored.
+func SupportsThinking(model string) bool {
+	return hasAnyPrefix(bareModel(model), thinkingModels)
+}
+
+func bareModel(model string) string {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return model
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// reasoning returns the settings req sends to model: a reasoning effort for
+// OpenAI reasoning models, or a thinking budget for Claude models, which
+// ThinkingBudget sets directly or ReasoningEffort picks from
+// thinkingBudgets. Other models get neither. The budget is kept below
+// limit, the output token limit the request runs with (0 when unknown), as
+// Anthropic requires.
+func (req *Request) reasoning(model string, limit int) (effort string, thinking *allm.ThinkingConfig) {
+	switch {
+	case SupportsReasoningEffort(model):
+		return req.ReasoningEffort, nil
+	case SupportsThinking(model):
+		budget := req.ThinkingBudget
+		if budget <= 0 {
+			budget = thinkingBudgets[req.ReasoningEffort]
+		}
+		if limit > 0 && budget >= limit {
+			budget = limit - 1
+		}
+		if budget < minThinkingBudget {
+			return "", nil
+		}
+		return "", &allm.ThinkingConfig{Type: "enabled", BudgetTokens: budget}
+	}
+	return "", nil
+}
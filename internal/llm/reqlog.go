@@ -0,0 +1,160 @@
+// Structured debug logging and tracing of outbound LLM requests
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/telemetry"
+	"github.com/kusa/magabot/internal/util"
+	"github.com/kusandriadi/allm-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxLoggedPromptChars caps message content written to logs when LogPrompts is on.
+const maxLoggedPromptChars = 2000
+
+// requestAttrs returns log attributes describing an outbound request:
+// provider, model, message roles and total size. Message content is only
+// included when prompt logging is enabled, and replaced by its length when
+// redaction is on.
+func (r *Router) requestAttrs(providerName, model string, messages []allm.Message) []any {
+	roles := make([]string, len(messages))
+	chars := 0
+	for i, m := range messages {
+		roles[i] = string(m.Role)
+		chars += len(m.Content)
+	}
+	attrs := []any{
+		"provider", providerName,
+		"model", model,
+		"messages", len(messages),
+		"roles", strings.Join(roles, ","),
+		"chars", chars,
+	}
+	if r.logPrompts && len(messages) > 0 {
+		last := messages[len(messages)-1].Content
+		if r.redactPrompts {
+			attrs = append(attrs, "prompt", fmt.Sprintf("[redacted %d chars]", len(last)))
+		} else {
+			attrs = append(attrs, "prompt", util.SanitizeErrorMessage(util.Truncate(last, maxLoggedPromptChars)))
+		}
+	}
+	return attrs
+}
+
+// loggerFor returns the logger for an LLM call, tagged with the request ID
+// of the message it serves, if any.
+func (r *Router) loggerFor(ctx context.Context) *slog.Logger {
+	if id := telemetry.RequestID(ctx); id != "" {
+		return r.logger.With("request_id", id)
+	}
+	return r.logger
+}
+
+// logRequest logs an outbound request at debug level.
+func (r *Router) logRequest(ctx context.Context, providerName, model string, messages []allm.Message) {
+	if !r.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	r.loggerFor(ctx).Debug("llm request", r.requestAttrs(providerName, model, messages)...)
+}
+
+// logResponse logs a completed request at debug level. providerRequestID
+// is the ID the provider gave the call, when it reports one.
+func (r *Router) logResponse(ctx context.Context, providerName, model string, inputTokens, outputTokens int, providerRequestID string, latency time.Duration) {
+	attrs := []any{
+		"provider", providerName,
+		"model", model,
+		"tokens_in", inputTokens,
+		"tokens_out", outputTokens,
+		"latency_ms", latency.Milliseconds(),
+	}
+	if providerRequestID != "" {
+		attrs = append(attrs, "provider_request_id", providerRequestID)
+	}
+	r.loggerFor(ctx).Debug("llm response", attrs...)
+}
+
+// logFailure logs a failed request with the provider and, when available,
+// the HTTP status so auth, rate-limit and server errors are distinguishable.
+func (r *Router) logFailure(ctx context.Context, providerName, model string, err error, latency time.Duration) {
+	attrs := []any{
+		"provider", providerName,
+		"model", model,
+		"latency_ms", latency.Milliseconds(),
+	}
+	if status := httpStatus(err); status != 0 {
+		attrs = append(attrs, "status", status)
+	}
+	attrs = append(attrs, "error", util.SanitizeErrorMessage(err.Error()))
+	r.loggerFor(ctx).Warn("llm request failed", attrs...)
+}
+
+// spanAttributes describes an LLM call on its trace span.
+func spanAttributes(providerName, model string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("llm.provider", providerName),
+		attribute.String("llm.model", model),
+	}
+}
+
+// tokenAttributes records a finished call's token usage on its span.
+func tokenAttributes(inputTokens, outputTokens int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("llm.input_tokens", inputTokens),
+		attribute.Int("llm.output_tokens", outputTokens),
+	}
+}
+
+// httpStatus extracts an HTTP status code from an error chain. Provider SDK
+// errors (OpenAI, Anthropic) carry it in a StatusCode field; allm sentinels
+// are used as a fallback. Returns 0 when unknown.
+func httpStatus(err error) int {
+	if code := statusCodeField(err); code != 0 {
+		return code
+	}
+	switch {
+	case errors.Is(err, allm.ErrRateLimited):
+		return 429
+	case errors.Is(err, allm.ErrOverloaded):
+		return 529
+	}
+	return 0
+}
+
+// statusCodeField walks the error tree looking for an int StatusCode field.
+func statusCodeField(err error) int {
+	if err == nil {
+		return 0
+	}
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("StatusCode"); f.IsValid() && f.CanInt() && f.Int() != 0 {
+			return int(f.Int())
+		}
+	}
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		return statusCodeField(u.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, e := range u.Unwrap() {
+			if code := statusCodeField(e); code != 0 {
+				return code
+			}
+		}
+	}
+	return 0
+}
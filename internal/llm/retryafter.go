@@ -0,0 +1,221 @@
+// Honoring Retry-After from rate-limited providers
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// MaxRetryAfter caps how long a provider's Retry-After is honored, so a
+// bogus header can't take a provider out for hours.
+const MaxRetryAfter = 10 * time.Minute
+
+// Rate-limited calls are retried by the router rather than by allm, whose
+// fixed backoff ignores Retry-After. A provider that asks for a longer
+// wait than maxRateLimitWait is put on cooldown instead (see noteRetryAfter).
+const (
+	maxRateLimitRetries = 2
+	rateLimitBackoff    = time.Second // wait without a Retry-After, doubled per retry
+	maxRateLimitWait    = 10 * time.Second
+)
+
+// rateLimitedError hides a rate limit from allm's retry, which retries
+// errors that match allm.ErrRateLimited. It deliberately has no Unwrap.
+type rateLimitedError struct{ err error }
+
+func (e *rateLimitedError) Error() string { return e.err.Error() }
+
+// chatRetry sends messages with c on providerName, retrying a rate-limited
+// call after the delay the provider asked for (see rateLimitWait).
+func (r *Router) chatRetry(ctx context.Context, providerName string, c *allm.Client, messages []allm.Message) (*Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.Chat(ctx, messages)
+		var rl *rateLimitedError
+		if !errors.As(err, &rl) {
+			return resp, err
+		}
+		wait, retry := rateLimitWait(ctx, rl.err, attempt)
+		if !retry {
+			return nil, rl.err
+		}
+		r.logger.Warn("llm provider rate limited, retrying", "provider", providerName, "wait", wait)
+		if !sleepCtx(ctx, wait) {
+			return nil, rl.err
+		}
+	}
+}
+
+// rateLimitWait returns how long to wait before retrying a call that
+// failed with err on its attempt'th retry: the provider's Retry-After, or
+// a backoff when it sent none. retry is false when err is no rate limit,
+// the retries are used up, or the wait is over maxRateLimitWait or past
+// ctx's deadline.
+func rateLimitWait(ctx context.Context, err error, attempt int) (wait time.Duration, retry bool) {
+	if httpStatus(err) != http.StatusTooManyRequests || attempt >= maxRateLimitRetries {
+		return 0, false
+	}
+	wait, ok := RetryAfter(err)
+	if !ok {
+		wait = rateLimitBackoff << attempt
+	}
+	if wait > maxRateLimitWait {
+		return 0, false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+		return 0, false
+	}
+	return wait, true
+}
+
+// sleepCtx waits for d, or returns false when ctx ends first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// RetryAfterError is a provider error that said when to try again, from
+// the Retry-After header of its response (usually a 429).
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfter reports how long the provider behind err asked to wait before
+// the next request: a *RetryAfterError in the chain, or the Retry-After
+// header of the response a provider SDK error carries.
+func RetryAfter(err error) (time.Duration, bool) {
+	var ra *RetryAfterError
+	if errors.As(err, &ra) {
+		return ra.After, true
+	}
+	if resp := responseField(err); resp != nil {
+		return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return 0, false
+}
+
+// withRetryAfter returns err as a *RetryAfterError when its provider sent a
+// Retry-After, else err unchanged.
+func withRetryAfter(err error) error {
+	var ra *RetryAfterError
+	if err == nil || errors.As(err, &ra) {
+		return err
+	}
+	if d, ok := RetryAfter(err); ok {
+		return &RetryAfterError{Err: err, After: d}
+	}
+	return err
+}
+
+// parseRetryAfter parses a Retry-After value, delay-seconds or an HTTP date,
+// capped at MaxRetryAfter. A date in the past means retry now.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		if secs > int64(MaxRetryAfter/time.Second) {
+			return MaxRetryAfter, true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return min(max(at.Sub(now), 0), MaxRetryAfter), true
+}
+
+// responseField walks the error tree looking for an *http.Response field
+// named Response, as the OpenAI and Anthropic SDK errors have.
+func responseField(err error) *http.Response {
+	if err == nil {
+		return nil
+	}
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Response"); f.IsValid() && f.CanInterface() {
+			if resp, ok := f.Interface().(*http.Response); ok && resp != nil {
+				return resp
+			}
+		}
+	}
+
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		return responseField(u.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, e := range u.Unwrap() {
+			if resp := responseField(e); resp != nil {
+				return resp
+			}
+		}
+	}
+	return nil
+}
+
+// noteRetryAfter puts providerName on cooldown for as long as err asked,
+// so requests aren't sent to it before then. It returns err, as a
+// *RetryAfterError when it carried a Retry-After.
+func (r *Router) noteRetryAfter(providerName string, err error) error {
+	err = withRetryAfter(err)
+	d, ok := RetryAfter(err)
+	if !ok || d <= 0 {
+		return err
+	}
+	r.mu.Lock()
+	r.cooldowns[providerName] = time.Now().Add(d)
+	r.mu.Unlock()
+	r.logger.Warn("llm provider asked to retry later", "provider", providerName, "retry_after", d)
+	return err
+}
+
+// cooldown returns how long providerName still waits out a Retry-After.
+func (r *Router) cooldown(providerName string) time.Duration {
+	r.mu.RLock()
+	until, ok := r.cooldowns[providerName]
+	r.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+	return max(time.Until(until), 0)
+}
+
+// errCoolingDown is returned instead of calling a provider that is waiting
+// out a Retry-After.
+func errCoolingDown(providerName string, left time.Duration) error {
+	return &RetryAfterError{
+		Err:   fmt.Errorf("%w: %w: %s asked to wait", ErrProviderFailed, allm.ErrRateLimited, providerName),
+		After: left,
+	}
+}
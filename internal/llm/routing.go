@@ -0,0 +1,291 @@
+// Picking a provider and model per message
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kusandriadi/allm-go"
+)
+
+// RouteFunc picks the provider and model for a request that names neither.
+// Empty results keep the main provider and its model.
+type RouteFunc func(ctx context.Context, req *Request) (provider, model string)
+
+// SetRouteFunc sets the hook StreamRequest asks for a provider and model;
+// nil always uses the main provider.
+func (r *Router) SetRouteFunc(fn RouteFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.route = fn
+}
+
+// clientFor returns the provider req runs on and its client: req.Provider
+// or, when req names neither a provider nor a model, the RouteFunc's pick.
+// A pick that is not registered, not available or unhealthy falls back to
+// the main provider, and an unhealthy main provider to another one (see
+// healthFallback). The choice is recorded in req, so a Continuation of it
+// runs on the same model.
+//
+// A request with images whose model doesn't accept them moves to one that
+// does (see visionClient), or fails with ErrNoVision.
+func (r *Router) clientFor(ctx context.Context, req *Request) (string, *allm.Client, error) {
+	name, client, err := r.pickClient(ctx, req)
+	if err != nil || !hasImages(req.Messages) {
+		return name, client, err
+	}
+	model := client.Model()
+	if req.Model != "" {
+		model = req.Model
+	}
+	if r.supportsVision(client, model) {
+		return name, client, nil
+	}
+	vname, vclient, ok := r.visionClient()
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s/%s", ErrNoVision, name, model)
+	}
+	r.logger.Info("message has images, using a vision model", "from", name+"/"+model, "provider", vname, "model", vclient.Model())
+	req.Provider, req.Model = vname, ""
+	return vname, vclient, nil
+}
+
+// Served returns the provider and model a request ran on, filling in the
+// main provider and the provider's default model where req leaves them
+// empty. Call it after StreamRequest, which records its choice in req.
+func (r *Router) Served(req *Request) (provider, model string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	provider, model = req.Provider, req.Model
+	if provider == "" {
+		provider = r.mainName
+	}
+	if model == "" {
+		if client, ok := r.clients[provider]; ok {
+			model = client.Model()
+		}
+	}
+	if model == "" {
+		model = provider
+	}
+	return provider, model
+}
+
+// pickClient is clientFor before the check for images.
+func (r *Router) pickClient(ctx context.Context, req *Request) (string, *allm.Client, error) {
+	r.mu.RLock()
+	route := r.route
+	r.mu.RUnlock()
+	if route != nil && req.Provider == "" && req.Model == "" {
+		req.Provider, req.Model = route(ctx, req)
+		if req.Provider != "" || req.Model != "" {
+			r.logger.Debug("message routed", "provider", req.Provider, "model", req.Model)
+		}
+	}
+
+	r.mu.RLock()
+	main := r.mainName
+	r.mu.RUnlock()
+	if req.Provider != "" && req.Provider != main {
+		r.mu.RLock()
+		client, ok := r.clients[req.Provider]
+		r.mu.RUnlock()
+		if ok && client.Provider().Available() && r.IsHealthy(req.Provider) && r.cooldown(req.Provider) == 0 {
+			return req.Provider, client, nil
+		}
+		r.logger.Warn("routed provider unavailable, using the main provider", "provider", req.Provider)
+		req.Provider, req.Model = "", ""
+	}
+
+	r.mu.RLock()
+	client, ok := r.clients[main]
+	r.mu.RUnlock()
+	if !ok {
+		return "", nil, fmt.Errorf("%w: provider %q not registered", ErrNoProvider, main)
+	}
+	if name, fb, ok := r.healthFallback(main); ok {
+		req.Provider, req.Model = name, ""
+		return name, fb, nil
+	}
+	return main, client, nil
+}
+
+// RoutingRule sends messages that meet all of its set conditions to
+// Provider and Model. A rule without conditions matches every message.
+type RoutingRule struct {
+	Name     string
+	Provider string // registered provider; empty keeps the main provider
+	Model    string // empty keeps the provider's model
+
+	Keywords  []string // any of these words or phrases, ignoring case
+	MinLength int      // at least this many characters
+	MaxLength int      // at most this many characters
+	Code      bool     // looks like code: fenced blocks or lines of code
+	Class     string   // the label the classifier gives the message
+}
+
+// matches reports whether text, labeled class, meets the rule.
+func (rule *RoutingRule) matches(text, class string) bool {
+	n := utf8.RuneCountInString(text)
+	switch {
+	case rule.MinLength > 0 && n < rule.MinLength,
+		rule.MaxLength > 0 && n > rule.MaxLength,
+		rule.Code && !looksLikeCode(text),
+		rule.Class != "" && !strings.EqualFold(rule.Class, class):
+		return false
+	}
+	if len(rule.Keywords) == 0 {
+		return true
+	}
+	lower := strings.ToLower(text)
+	for _, kw := range rule.Keywords {
+		if containsWord(lower, strings.ToLower(strings.TrimSpace(kw))) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleRoute returns a RouteFunc that routes the last user message by the
+// first matching rule. classify labels messages for rules with a Class; it
+// is called at most once per message, and only when such a rule is reached.
+// It may be nil when no rule has a Class.
+func RuleRoute(rules []RoutingRule, classify func(ctx context.Context, text string) string) RouteFunc {
+	return func(ctx context.Context, req *Request) (string, string) {
+		text := lastUserText(req.Messages)
+		if text == "" {
+			return "", ""
+		}
+		class, classified := "", false
+		for i := range rules {
+			rule := &rules[i]
+			if rule.Class != "" && !classified && classify != nil {
+				class, classified = classify(ctx, text), true
+			}
+			if rule.matches(text, class) {
+				return rule.Provider, rule.Model
+			}
+		}
+		return "", ""
+	}
+}
+
+// lastUserText returns the content of the last user message.
+func lastUserText(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return strings.TrimSpace(messages[i].Content)
+		}
+	}
+	return ""
+}
+
+// containsWord reports whether lowercase text contains word as a whole
+// word or phrase.
+func containsWord(text, word string) bool {
+	if word == "" {
+		return false
+	}
+	for from := 0; ; {
+		i := strings.Index(text[from:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := from+i, from+i+len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		from = start + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+// codeLinePrefixes start lines of code in common languages.
+var codeLinePrefixes = []string{
+	"func ", "def ", "class ", "import ", "from ", "package ", "#include", "public ", "private ",
+	"const ", "let ", "var ", "return ", "if (", "for (", "while (", "select ", "<?php", "$ ", "#!/",
+}
+
+// looksLikeCode reports whether text contains a fenced code block or at
+// least two lines that read as code.
+func looksLikeCode(text string) bool {
+	if strings.Contains(text, "```") {
+		return true
+	}
+	lines := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		code := strings.HasSuffix(line, ";") || strings.HasSuffix(line, "{") || line == "}"
+		lower := strings.ToLower(line)
+		for _, p := range codeLinePrefixes {
+			if strings.HasPrefix(lower, p) {
+				code = true
+				break
+			}
+		}
+		if code {
+			if lines++; lines >= 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// classifyTimeout bounds the classifier call made before a message is
+// answered.
+const classifyTimeout = 10 * time.Second
+
+// maxClassifyInput is the message characters the classifier sees.
+const maxClassifyInput = 2000
+
+// Classify asks provider, typically a small fast model, which of labels
+// fits text, and returns that label, or "" when the answer names none.
+func (r *Router) Classify(ctx context.Context, provider string, labels []string, text string) (string, error) {
+	r.mu.RLock()
+	client, ok := r.clients[provider]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: provider %q not registered", ErrNoProvider, provider)
+	}
+	if len(text) > maxClassifyInput {
+		text = strings.ToValidUTF8(text[:maxClassifyInput], "")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, classifyTimeout)
+	defer cancel()
+	messages := []allm.Message{
+		{Role: "system", Content: fmt.Sprintf("Classify the user's message as one of: %s. Answer with the label only.", strings.Join(labels, ", "))},
+		{Role: "user", Content: allm.SanitizeInput(text)},
+	}
+	release, err := r.limiter.acquire(ctx, provider)
+	if err != nil {
+		return "", fmt.Errorf("classify with %s: %w", provider, err)
+	}
+	defer release()
+	resp, err := r.chatRetry(ctx, provider, r.requestClient(provider, client, &Request{}), messages)
+	if err != nil {
+		return "", fmt.Errorf("classify with %s: %w", provider, err)
+	}
+	r.usage.trackTokens(resp.InputTokens, resp.OutputTokens)
+
+	answer := strings.ToLower(resp.Content)
+	for _, label := range labels {
+		if containsWord(answer, strings.ToLower(label)) {
+			return label, nil
+		}
+	}
+	return "", nil
+}
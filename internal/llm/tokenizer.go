@@ -0,0 +1,201 @@
+// Token counting for context budgets and input limits
+package llm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a model sees in a piece of text.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// HeuristicTokenizer estimates tokens from the characters of the text, with
+// no vocabulary. It is the default for every model.
+var HeuristicTokenizer Tokenizer = heuristicTokenizer{}
+
+// heuristicTokenizer weighs characters by how BPE vocabularies usually
+// split them: English words run about four characters per token, code
+// punctuation about two, and CJK scripts about one.
+type heuristicTokenizer struct{}
+
+func (heuristicTokenizer) CountTokens(text string) int {
+	quarters := 0 // quarter tokens
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf && (r == ' ' || r == '\n' || r == '\t' || unicode.IsLetter(r) || unicode.IsDigit(r)):
+			quarters++
+		case r < utf8.RuneSelf:
+			quarters += 2
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			quarters += 4
+		case unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r):
+			quarters += 2
+		default: // emoji and other symbols take several byte-level tokens
+			quarters += 6
+		}
+	}
+	return (quarters + 3) / 4
+}
+
+// maxBytesPerToken bounds the input bytes one token stands for, so that a
+// prompt within a token limit also passes allm's check on raw length.
+const maxBytesPerToken = 32
+
+// InputLenLimit returns the allm.WithMaxInputLen value, in bytes, that
+// admits any prompt within maxTokens.
+func InputLenLimit(maxTokens int) int {
+	return maxTokens * maxBytesPerToken
+}
+
+// bpePattern splits text into the pieces BPE merges within, as tiktoken's
+// cl100k_base does. Go's regexp has no lookahead, so bpeTokenizer handles
+// cl100k's trailing `\s+(?!\S)` itself.
+var bpePattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// maxBPEPiece caps the bytes merged as one piece; longer runs are counted
+// in parts, which keeps merging fast on pathological input.
+const maxBPEPiece = 256
+
+// bpeTokenizer counts tokens exactly with a tiktoken byte-pair encoding.
+type bpeTokenizer struct {
+	ranks map[string]int
+}
+
+var (
+	bpeCache   = make(map[string]*bpeTokenizer)
+	bpeCacheMu sync.Mutex
+)
+
+// LoadTokenizer loads a tiktoken encoding file, such as cl100k_base.tiktoken
+// or o200k_base.tiktoken: one base64 token and its rank per line. Each file
+// is read once; later calls share the loaded tokenizer.
+func LoadTokenizer(path string) (Tokenizer, error) {
+	bpeCacheMu.Lock()
+	defer bpeCacheMu.Unlock()
+	if t, ok := bpeCache[path]; ok {
+		return t, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("load tokenizer: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(text, " ")
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil || !ok {
+			return nil, fmt.Errorf("load tokenizer: %s:%d: want a base64 token and a rank", path, line)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("load tokenizer: %s:%d: invalid rank %q", path, line, rank)
+		}
+		ranks[string(b)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("load tokenizer: %w", err)
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("load tokenizer: %s has no tokens", path)
+	}
+
+	t := &bpeTokenizer{ranks: ranks}
+	bpeCache[path] = t
+	return t, nil
+}
+
+func (t *bpeTokenizer) CountTokens(text string) int {
+	total := 0
+	for pos := 0; pos < len(text); {
+		loc := bpePattern.FindStringIndex(text[pos:])
+		if loc == nil {
+			total += t.countPiece(text[pos:])
+			break
+		}
+		start, end := pos+loc[0], pos+loc[1]
+		if start > pos { // unmatched bytes, e.g. invalid UTF-8
+			total += t.countPiece(text[pos:start])
+		}
+		// `\s+(?!\S)`: a run of spaces before a word leaves its last space
+		// to the word
+		if piece := text[start:end]; end < len(text) && strings.TrimSpace(piece) == "" &&
+			!strings.ContainsAny(piece, "\r\n") && utf8.RuneCountInString(piece) > 1 {
+			_, size := utf8.DecodeLastRuneInString(piece)
+			end -= size
+		}
+		total += t.countPiece(text[start:end])
+		pos = end
+	}
+	return total
+}
+
+// countPiece returns the tokens of one pre-split piece: its bytes, merged
+// pairwise by lowest rank until no pair is in the vocabulary.
+func (t *bpeTokenizer) countPiece(piece string) int {
+	if len(piece) > maxBPEPiece {
+		return t.countPiece(piece[:maxBPEPiece]) + t.countPiece(piece[maxBPEPiece:])
+	}
+	if _, ok := t.ranks[piece]; ok || piece == "" {
+		return min(len(piece), 1)
+	}
+	bounds := make([]int, len(piece)+1) // start of each part, then the end
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := t.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// tokenizer returns the tokenizer configured for model: the one whose
+// Config.Tokenizers key is the longest prefix of its name, or
+// HeuristicTokenizer. Choices are cached per model.
+func (r *Router) tokenizer(model string) Tokenizer {
+	if len(r.tokenizers) == 0 {
+		return HeuristicTokenizer
+	}
+	if t, ok := r.tokenizerCache.Load(model); ok {
+		return t.(Tokenizer)
+	}
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	t, matched := HeuristicTokenizer, 0
+	for prefix, tok := range r.tokenizers {
+		if p := strings.ToLower(prefix); len(p) > matched && (strings.HasPrefix(name, p) || strings.HasPrefix(strings.ToLower(model), p)) {
+			t, matched = tok, len(p)
+		}
+	}
+	r.tokenizerCache.Store(model, t)
+	return t
+}
@@ -0,0 +1,111 @@
+// Function calling: letting the model call tools before it answers
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/provider"
+)
+
+// MaxToolRounds is how many times the model may call tools for one answer;
+// after that it must answer with what it has.
+const MaxToolRounds = 4
+
+// answerNowPrompt ends the last tool round. The tools stay offered, as
+// providers reject tool results without tool definitions, so the model is
+// told instead.
+const answerNowPrompt = "You have used all your tool calls. Answer now with the information you have, without calling any more tools."
+
+// errNoToolAnswer is returned when the model still calls tools after
+// MaxToolRounds instead of answering.
+var errNoToolAnswer = errors.New("model kept calling tools without answering")
+
+// ToolRunner runs a tool call the model made and returns the result sent
+// back to it.
+type ToolRunner func(ctx context.Context, call ToolCall) ToolResult
+
+// completeStream answers messages with a complete response and delivers it
+// as a stream, in one chunk. Tool calls need a complete response: they are
+// allowed when req has tools, and run with req.RunTool until the model
+// answers in text. Seeded requests need one for the system fingerprint,
+// which streamed chunks don't carry. A provider without function calling
+// streams the answer as usual instead.
+func (r *Router) completeStream(ctx context.Context, name string, client *allm.Client, messages []allm.Message, req *Request) <-chan StreamChunk {
+	if _, cli := client.Provider().(*provider.ClaudeCLIProvider); cli {
+		return r.startStream(ctx, name, client, messages, req)
+	}
+
+	out := make(chan StreamChunk, 2) // never blocks, so an abandoned stream doesn't leak
+	go func() {
+		defer close(out)
+		resp, err := r.runTools(ctx, name, client, messages, req)
+		if errors.Is(err, allm.ErrNotSupported) {
+			r.logger.Debug("provider has no function calling, answering without tools", "provider", client.Provider().Name())
+			for chunk := range r.startStream(ctx, name, client, messages, req) {
+				select {
+				case out <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+			return
+		}
+		if err != nil {
+			out <- StreamChunk{Error: err, Done: true}
+			return
+		}
+		req.SystemFingerprint = resp.SystemFingerprint
+		req.FinishReason = finishReason(resp.FinishReason)
+		req.ProviderRequestID = resp.RequestID
+		out <- StreamChunk{Content: resp.Content}
+		out <- StreamChunk{Done: true, Usage: &StreamUsage{InputTokens: resp.InputTokens, OutputTokens: resp.OutputTokens}}
+	}()
+	return out
+}
+
+// runTools sends messages with req.Tools, feeding each round's tool
+// results back until the model stops calling tools or MaxToolRounds is
+// reached, when it is asked to answer now. The response's token counts
+// cover every round.
+func (r *Router) runTools(ctx context.Context, name string, client *allm.Client, messages []allm.Message, req *Request) (*Response, error) {
+	var tools []Tool
+	if req.RunTool != nil {
+		tools = req.Tools
+	}
+	c := r.requestClient(name, client, req, allm.WithTools(tools...))
+
+	messages = append([]allm.Message(nil), messages...)
+	var inputTokens, outputTokens int
+	for round := 0; ; round++ {
+		if round == MaxToolRounds {
+			messages = append(messages, allm.Message{Role: allm.RoleUser, Content: answerNowPrompt})
+		}
+		resp, err := r.chatRetry(ctx, name, c, messages)
+		if err != nil {
+			return nil, err
+		}
+		inputTokens += resp.InputTokens
+		outputTokens += resp.OutputTokens
+		if len(resp.ToolCalls) == 0 || round == MaxToolRounds {
+			if resp.Content == "" && len(resp.ToolCalls) > 0 {
+				return nil, fmt.Errorf("%w: %w after %d rounds", ErrProviderFailed, errNoToolAnswer, MaxToolRounds)
+			}
+			resp.InputTokens, resp.OutputTokens = inputTokens, outputTokens
+			return resp, nil
+		}
+
+		results := make([]ToolResult, len(resp.ToolCalls))
+		for i, call := range resp.ToolCalls {
+			r.loggerFor(ctx).Info("llm tool call", "tool", call.Name, "round", round+1)
+			results[i] = req.RunTool(ctx, call)
+			results[i].ToolCallID = call.ID
+		}
+		messages = append(messages,
+			allm.Message{Role: allm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls},
+			allm.Message{Role: allm.RoleTool, ToolResults: results},
+		)
+	}
+}
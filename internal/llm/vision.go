@@ -0,0 +1,101 @@
+// Which models accept images, and picking one for messages that carry them
+package llm
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/provider"
+)
+
+// ErrNoVision is returned for a request with images when no available
+// provider's model accepts them.
+var ErrNoVision = errors.New("no available model accepts images")
+
+// visionModels maps model name prefixes to whether the models accept
+// images. More specific prefixes come first; models not listed don't,
+// unless their name marks them as vision models (see SupportsVision).
+var visionModels = []struct {
+	prefix string
+	vision bool
+}{
+	{"claude-", true},
+	{"opus", true}, // Claude CLI aliases
+	{"sonnet", true},
+	{"haiku", true},
+	{"gpt-5", true},
+	{"gpt-4.1", true},
+	{"gpt-4o", true},
+	{"gpt-4-turbo", true},
+	{"o1-mini", false},
+	{"o1", true},
+	{"o3-mini", false},
+	{"o3", true},
+	{"o4-mini", true},
+	{"glm-4.5v", true},
+	{"glm-4.6v", true},
+	{"glm-4v", true},
+	{"gemini", true},
+	{"gemma3", true},
+	{"llava", true},
+	{"pixtral", true},
+}
+
+// SupportsVision reports whether model accepts images, as far as magabot
+// knows. Vendor prefixes such as "openai/" (OpenRouter) are ignored.
+func SupportsVision(model string) bool {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, v := range visionModels {
+		if strings.HasPrefix(model, v.prefix) {
+			return v.vision
+		}
+	}
+	return strings.Contains(model, "vision") || strings.Contains(model, "-vl")
+}
+
+// supportsVision reports whether client accepts images with model: the
+// configured llm.vision_models entry, else SupportsVision. The Claude CLI
+// always does, since it reads the image files itself.
+func (r *Router) supportsVision(client *allm.Client, model string) bool {
+	if _, ok := client.Provider().(*provider.ClaudeCLIProvider); ok {
+		return true
+	}
+	if v, ok := r.visionModels[model]; ok {
+		return v
+	}
+	return SupportsVision(model)
+}
+
+// hasImages reports whether any message carries images.
+func hasImages(messages []Message) bool {
+	return slices.ContainsFunc(messages, func(m Message) bool { return len(m.Images) > 0 })
+}
+
+// visionClient returns an available provider whose model accepts images,
+// for a request the provider it was headed to can't answer: the main
+// provider with its own model first, then the others by name. Providers
+// that failed their last health probe are skipped.
+func (r *Router) visionClient() (string, *allm.Client, bool) {
+	r.mu.RLock()
+	clients := maps.Clone(r.clients)
+	main := r.mainName
+	r.mu.RUnlock()
+
+	names := slices.Sorted(maps.Keys(clients))
+	if i := slices.Index(names, main); i > 0 {
+		names = append(append([]string{main}, names[:i]...), names[i+1:]...)
+	}
+	for _, name := range names {
+		client := clients[name]
+		if client.Provider().Available() && r.IsHealthy(name) && r.supportsVision(client, client.Model()) {
+			return name, client, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,235 @@
+// Package logrotate provides a log file writer that rotates the file by
+// size and age, gzips rotated files and keeps a fixed number of them.
+package logrotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeep is how many rotated files are kept when Config.Keep is unset.
+const DefaultKeep = 5
+
+// stampLayout names rotated files: <file>.<stamp>[.gz]. It sorts by time.
+const stampLayout = "20060102-150405.000"
+
+// rotatedSuffix matches the suffix stampLayout adds to rotated files.
+var rotatedSuffix = regexp.MustCompile(`^\.\d{8}-\d{6}\.\d{3}(\.gz)?$`)
+
+// Config holds rotation settings. With neither MaxSize nor MaxAge set, the
+// file is never rotated, but can still be reopened.
+type Config struct {
+	MaxSize  int64         // rotate before the file would grow past this many bytes (0 = no limit)
+	MaxAge   time.Duration // rotate once the file has been written to for this long (0 = no limit)
+	Keep     int           // rotated files to keep (default DefaultKeep)
+	Compress bool          // gzip rotated files
+}
+
+// Writer is an io.WriteCloser appending to a log file and rotating it per
+// Config. It is safe for concurrent use.
+type Writer struct {
+	path string
+	cfg  Config
+	now  func() time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time // when the current file got its first write
+
+	compressing sync.WaitGroup
+	background  sync.Mutex // one compress-and-prune at a time
+}
+
+// New opens (or creates) the log file at path for appending.
+func New(path string, cfg Config) (*Writer, error) {
+	if cfg.Keep <= 0 {
+		cfg.Keep = DefaultKeep
+	}
+	w := &Writer{path: path, cfg: cfg, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the log file. Caller must hold w.mu (or own w).
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600) // #nosec G304 -- operator-configured log path
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	w.file, w.size = f, info.Size()
+	// A file kept from before a restart counts from its last write
+	w.started = w.now()
+	if w.size > 0 {
+		w.started = info.ModTime()
+	}
+	return nil
+}
+
+// Write appends p to the log file, rotating it first when p would take it
+// past MaxSize or it is older than MaxAge.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && w.due(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			// Keep logging to whatever file is open rather than losing lines
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+	if w.size == 0 {
+		w.started = w.now()
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file must be rotated before writing n bytes.
+func (w *Writer) due(n int64) bool {
+	if w.cfg.MaxSize > 0 && w.size+n > w.cfg.MaxSize {
+		return true
+	}
+	return w.cfg.MaxAge > 0 && w.now().Sub(w.started) >= w.cfg.MaxAge
+}
+
+// rotate moves the current file aside, opens a new one and compresses and
+// prunes the rotated files in the background. Caller must hold w.mu.
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	rotated := w.path + "." + w.now().Format(stampLayout)
+	renameErr := os.Rename(w.path, rotated)
+	if err := w.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("rotate log file: %w", renameErr)
+	}
+
+	w.compressing.Add(1)
+	go func() {
+		defer w.compressing.Done()
+		w.background.Lock()
+		defer w.background.Unlock()
+		if w.cfg.Compress {
+			if err := compress(rotated); err != nil {
+				fmt.Fprintf(os.Stderr, "compress rotated log %s: %v\n", rotated, err)
+			}
+		}
+		if err := w.prune(); err != nil {
+			fmt.Fprintf(os.Stderr, "prune rotated logs: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// Reopen closes the log file and opens the file at its path again, for
+// when something else (e.g. logrotate) has moved it.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		_ = w.file.Close()
+	}
+	return w.open()
+}
+
+// Close closes the log file, after any rotated file being compressed is
+// done.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	f := w.file
+	w.file = nil
+	w.mu.Unlock()
+	w.compressing.Wait()
+	if f == nil {
+		return nil
+	}
+	return f.Close()
+}
+
+// Rotated returns the rotated files of the log at path, oldest first.
+func Rotated(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(path)
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, base) && rotatedSuffix.MatchString(name[len(base):]) {
+			files = append(files, filepath.Join(filepath.Dir(path), name))
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// prune removes all but the newest Keep rotated files.
+func (w *Writer) prune() error {
+	files, err := Rotated(w.path)
+	if err != nil {
+		return err
+	}
+	for len(files) > w.cfg.Keep {
+		if err := os.Remove(files[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// compress gzips path to path.gz and removes path.
+func compress(path string) (err error) {
+	src, err := os.Open(path) // #nosec G304 -- rotated log file
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600) // #nosec G304 -- rotated log file
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(path + ".gz")
+		}
+	}()
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,155 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a clock advancing by a millisecond per reading, so
+// rotated file names differ.
+func fakeClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "magabot.log")
+	w, err := New(path, Config{MaxSize: 10, Keep: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = fakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	for _, line := range []string{"line one\n", "line two\n", "line three\n", "line four\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, _ := os.ReadFile(path)
+	if string(current) != "line four\n" {
+		t.Errorf("current file = %q, want the last line", current)
+	}
+	rotated, err := Rotated(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("rotated = %v, want the 2 newest kept", rotated)
+	}
+	for i, want := range []string{"line two\n", "line three\n"} {
+		if !strings.HasSuffix(rotated[i], ".gz") {
+			t.Errorf("%s not compressed", rotated[i])
+			continue
+		}
+		if got := readGzip(t, rotated[i]); got != want {
+			t.Errorf("%s = %q, want %q", rotated[i], got, want)
+		}
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "magabot.log")
+	w, err := New(path, Config{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	w.now = func() time.Time { return now }
+
+	_, _ = w.Write([]byte("old\n"))
+	now = now.Add(30 * time.Minute)
+	_, _ = w.Write([]byte("still fresh\n"))
+	now = now.Add(31 * time.Minute)
+	_, _ = w.Write([]byte("new\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, _ := os.ReadFile(path)
+	if string(current) != "new\n" {
+		t.Errorf("current file = %q", current)
+	}
+	rotated, _ := Rotated(path)
+	if len(rotated) != 1 || strings.HasSuffix(rotated[0], ".gz") {
+		t.Fatalf("rotated = %v, want one uncompressed file", rotated)
+	}
+	if data, _ := os.ReadFile(rotated[0]); string(data) != "old\nstill fresh\n" {
+		t.Errorf("rotated file = %q", data)
+	}
+}
+
+func TestNoLimitsNeverRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "magabot.log")
+	w, err := New(path, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		_, _ = w.Write([]byte(strings.Repeat("x", 100) + "\n"))
+	}
+	_ = w.Close()
+	if rotated, _ := Rotated(path); len(rotated) != 0 {
+		t.Errorf("rotated = %v, want none", rotated)
+	}
+	if _, err := w.Write([]byte("late\n")); err == nil {
+		t.Error("Write after Close should fail")
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "magabot.log")
+	w, err := New(path, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+
+	_, _ = w.Write([]byte("before\n"))
+	moved := filepath.Join(dir, "magabot.log.1")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("still old file\n"))
+	if err := w.Reopen(); err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	_, _ = w.Write([]byte("after\n"))
+
+	if data, _ := os.ReadFile(moved); string(data) != "before\nstill old file\n" {
+		t.Errorf("moved file = %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "after\n" {
+		t.Errorf("reopened file = %q", data)
+	}
+}
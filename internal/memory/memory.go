@@ -186,26 +186,25 @@ func (s *Store) Search(query string, limit int) []*Memory {
 
 // GetContext retrieves relevant context for a conversation
 func (s *Store) GetContext(query string, maxTokens int) string {
-	memories := s.Search(query, 10)
-
-	if len(memories) == 0 {
-		return ""
-	}
-
-	var sb strings.Builder
-	sb.WriteString("Relevant memories:\n")
+	text, _ := FormatContext("Relevant memories:", s.Sources(query, 10), maxTokens)
+	return text
+}
 
-	totalLen := 0
+// Sources returns up to limit memories relevant to query as prompt sources,
+// best match first.
+func (s *Store) Sources(query string, limit int) []Source {
+	memories := s.Search(query, limit)
+	sources := make([]Source, 0, len(memories))
 	for _, mem := range memories {
-		line := fmt.Sprintf("- [%s] %s\n", mem.Type, mem.Content)
-		if totalLen+len(line) > maxTokens {
-			break
-		}
-		sb.WriteString(line)
-		totalLen += len(line)
+		sources = append(sources, Source{
+			ID:        CiteID(mem.ID),
+			Type:      mem.Type,
+			Content:   mem.Content,
+			Platform:  mem.Platform,
+			CreatedAt: mem.CreatedAt,
+		})
 	}
-
-	return sb.String()
+	return sources
 }
 
 // List returns all memories, optionally filtered by type
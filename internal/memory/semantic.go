@@ -3,6 +3,8 @@ package memory
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -24,6 +26,9 @@ type SemanticStore struct {
 	userID  string
 	dataDir string
 	logger  *slog.Logger
+
+	minSimilarity float32 // GetContext threshold
+	minResults    int
 }
 
 // SemanticConfig holds configuration for semantic memory.
@@ -33,6 +38,16 @@ type SemanticConfig struct {
 	Client     *embedding.Client
 	Dimensions int
 	Logger     *slog.Logger
+
+	// Driver and DSN select the vector backend (see embedding.VectorStoreConfig);
+	// empty keeps vectors in a SQLite file under DataDir.
+	Driver string
+	DSN    string
+
+	// MinSimilarity and MinResults filter the memories GetContext adds to
+	// prompts (see embedding.SearchOptions).
+	MinSimilarity float32
+	MinResults    int
 }
 
 // SemanticMemory represents a semantic memory entry.
@@ -55,9 +70,6 @@ func NewSemanticStore(cfg SemanticConfig) (*SemanticStore, error) {
 	if cfg.Logger == nil {
 		cfg.Logger = slog.Default()
 	}
-	if cfg.Dimensions <= 0 {
-		cfg.Dimensions = 1536
-	}
 
 	// Sanitize user ID for file path
 	safeID := sanitizeFilename(cfg.UserID)
@@ -72,9 +84,18 @@ func NewSemanticStore(cfg SemanticConfig) (*SemanticStore, error) {
 	}
 
 	// Create vector store
+	// A shared Postgres database keeps each user's memories in their own table
+	tableName := "memories"
+	if cfg.Driver == embedding.DriverPgvector {
+		sum := sha256.Sum256([]byte(cfg.UserID))
+		tableName = "memories_" + hex.EncodeToString(sum[:8])
+	}
+
 	vectors, err := embedding.NewVectorStore(embedding.VectorStoreConfig{
+		Driver:     cfg.Driver,
 		DBPath:     filepath.Join(memDir, "vectors.db"),
-		TableName:  "memories",
+		DSN:        cfg.DSN,
+		TableName:  tableName,
 		Client:     cfg.Client,
 		Dimensions: cfg.Dimensions,
 		Logger:     cfg.Logger,
@@ -89,6 +110,9 @@ func NewSemanticStore(cfg SemanticConfig) (*SemanticStore, error) {
 		userID:  cfg.UserID,
 		dataDir: memDir,
 		logger:  cfg.Logger,
+
+		minSimilarity: cfg.MinSimilarity,
+		minResults:    cfg.MinResults,
 	}, nil
 }
 
@@ -100,19 +124,32 @@ func (s *SemanticStore) Add(ctx context.Context, mem *SemanticMemory) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Validate content length to prevent resource exhaustion
-	if len(mem.Content) > MaxContentLength {
-		return fmt.Errorf("content too long (max %d bytes)", MaxContentLength)
+	if err := validateContent(mem.Content); err != nil {
+		return err
+	}
+	if mem.ID == "" {
+		mem.ID = uuid.New().String()[:12]
 	}
 
-	// Validate content is not empty
-	if strings.TrimSpace(mem.Content) == "" {
+	// Add to vector store (embedding will be generated automatically)
+	return s.vectors.Add(ctx, mem.ID, mem.Content, s.metadata(mem))
+}
+
+// validateContent rejects empty memories and, to prevent resource
+// exhaustion, overly long ones.
+func validateContent(content string) error {
+	if len(content) > MaxContentLength {
+		return fmt.Errorf("content too long (max %d bytes)", MaxContentLength)
+	}
+	if strings.TrimSpace(content) == "" {
 		return fmt.Errorf("content cannot be empty")
 	}
+	return nil
+}
 
-	if mem.ID == "" {
-		mem.ID = uuid.New().String()[:12]
-	}
+// metadata fills in mem's defaults and returns the metadata it is stored
+// with.
+func (s *SemanticStore) metadata(mem *SemanticMemory) map[string]interface{} {
 	if mem.CreatedAt.IsZero() {
 		mem.CreatedAt = time.Now()
 	}
@@ -140,9 +177,7 @@ func (s *SemanticStore) Add(ctx context.Context, mem *SemanticMemory) error {
 	for k, v := range mem.Metadata {
 		metadata[k] = v
 	}
-
-	// Add to vector store (embedding will be generated automatically)
-	return s.vectors.Add(ctx, mem.ID, mem.Content, metadata)
+	return metadata
 }
 
 // Remember is a convenience method to add a memory from chat.
@@ -281,30 +316,47 @@ func (s *SemanticStore) List(offset, limit int) ([]*SemanticMemory, error) {
 }
 
 // GetContext builds a context string from relevant memories for LLM prompts.
+// Memories below the store's similarity threshold are left out, so a poor
+// match does not steer the answer.
 func (s *SemanticStore) GetContext(ctx context.Context, query string, maxTokens int) (string, error) {
-	memories, err := s.Search(ctx, query, 10)
+	sources, err := s.Sources(ctx, query, 10)
 	if err != nil {
 		return "", err
 	}
+	text, _ := FormatContext("Relevant memories about the user:", sources, maxTokens)
+	return text, nil
+}
 
-	if len(memories) == 0 {
-		return "", nil
+// Sources returns up to limit memories relevant to query as prompt sources,
+// most similar first, applying the store's similarity threshold.
+func (s *SemanticStore) Sources(ctx context.Context, query string, limit int) ([]Source, error) {
+	s.mu.RLock()
+	results, dropped, err := s.vectors.SearchWithOptions(ctx, query, embedding.SearchOptions{
+		Limit:         limit,
+		MinSimilarity: s.minSimilarity,
+		MinResults:    s.minResults,
+	})
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
 	}
-
-	var result string
-	totalLen := 0
-
-	result += "Relevant memories about the user:\n"
-	for _, mem := range memories {
-		line := fmt.Sprintf("- [%s] %s\n", mem.Type, mem.Content)
-		if totalLen+len(line) > maxTokens {
-			break
-		}
-		result += line
-		totalLen += len(line)
+	if dropped > 0 {
+		s.logger.Debug("memories below similarity threshold", "dropped", dropped, "kept", len(results), "min_similarity", s.minSimilarity)
 	}
 
-	return result, nil
+	sources := make([]Source, 0, len(results))
+	for _, r := range results {
+		mem := entryToMemory(r.Entry)
+		sources = append(sources, Source{
+			ID:         CiteID(mem.ID),
+			Type:       mem.Type,
+			Content:    mem.Content,
+			Platform:   mem.Platform,
+			Similarity: r.Similarity,
+			CreatedAt:  mem.CreatedAt,
+		})
+	}
+	return sources, nil
 }
 
 // Close closes the underlying database connection.
@@ -488,6 +540,34 @@ func (s *SemanticStore) BulkImport(ctx context.Context, memories []*SemanticMemo
 	return nil
 }
 
+// Import embeds memories in batches, reporting progress after each one.
+// Memories without an ID get one from their content, so importing the same
+// file again with resume skips every memory already embedded. Invalid
+// memories are logged and skipped. On error it returns the progress made.
+func (s *SemanticStore) Import(ctx context.Context, memories []*SemanticMemory, resume bool, progress func(embedding.ImportProgress)) (embedding.ImportProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	docs := make([]embedding.Document, 0, len(memories))
+	for _, mem := range memories {
+		if err := validateContent(mem.Content); err != nil {
+			s.logger.Warn("skipping memory", "id", mem.ID, "error", err)
+			continue
+		}
+		if mem.Type == "" {
+			mem.Type = detectMemoryType(mem.Content)
+		}
+		if mem.Source == "" {
+			mem.Source = "import"
+		}
+		if mem.Tags == nil {
+			mem.Tags = extractTags(mem.Content)
+		}
+		docs = append(docs, embedding.Document{ID: mem.ID, Content: mem.Content, Metadata: s.metadata(mem)})
+	}
+	return s.vectors.AddDocuments(ctx, docs, embedding.ImportOptions{Resume: resume, Progress: progress})
+}
+
 // Export exports all memories to JSON.
 func (s *SemanticStore) Export() ([]byte, error) {
 	count, err := s.Count()
@@ -0,0 +1,97 @@
+// Memory sources added to prompts, and the citations answers make to them
+package memory
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/util"
+)
+
+// CitationInstruction tells the model how to cite the memories in a
+// FormatContext block.
+const CitationInstruction = "When your answer relies on one of these memories, cite it inline by its ID in square brackets, e.g. [a1b2c3d4]. Do not cite memories you did not use."
+
+// sourceSnippetLen is how much of a memory FormatSources shows.
+const sourceSnippetLen = 80
+
+// Source is a memory added to a prompt, under the ID the model cites it by.
+type Source struct {
+	ID         string    `json:"id"` // CiteID of the memory's ID
+	Type       string    `json:"type"`
+	Content    string    `json:"content"`
+	Platform   string    `json:"platform,omitempty"`
+	Similarity float32   `json:"similarity,omitempty"` // 0 for keyword matches
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CiteID returns the ID a memory is cited by: the first 8 characters of
+// its ID, which /memory delete also accepts.
+func CiteID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// FormatContext lists sources for a prompt under header, one line each with
+// its cite ID, until maxTokens characters are used. It returns the block and
+// the sources that fit, or "" and nil when none do.
+func FormatContext(header string, sources []Source, maxTokens int) (string, []Source) {
+	var sb strings.Builder
+	var used []Source
+	totalLen := 0
+	for _, src := range sources {
+		line := fmt.Sprintf("- [%s] (%s) %s\n", src.ID, src.Type, src.Content)
+		if totalLen+len(line) > maxTokens {
+			break
+		}
+		sb.WriteString(line)
+		totalLen += len(line)
+		used = append(used, src)
+	}
+	if len(used) == 0 {
+		return "", nil
+	}
+	return header + "\n" + sb.String(), used
+}
+
+// citationPattern matches [id] citations, including [id1, id2].
+var citationPattern = regexp.MustCompile(`\[([0-9A-Za-z_-]+(?:\s*,\s*[0-9A-Za-z_-]+)*)\]`)
+
+// Cited returns the sources whose IDs answer cites, in the order first cited.
+func Cited(answer string, sources []Source) []Source {
+	byID := make(map[string]Source, len(sources))
+	for _, src := range sources {
+		byID[src.ID] = src
+	}
+
+	var cited []Source
+	for _, m := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		for _, id := range strings.Split(m[1], ",") {
+			id = strings.TrimSpace(id)
+			if src, ok := byID[id]; ok {
+				cited = append(cited, src)
+				delete(byID, id)
+			}
+		}
+	}
+	return cited
+}
+
+// FormatSources lists sources for the user, each with a short snippet of
+// the memory, its type and when it was saved.
+func FormatSources(sources []Source) string {
+	var sb strings.Builder
+	sb.WriteString("📚 *Sources:*")
+	for _, src := range sources {
+		fmt.Fprintf(&sb, "\n[%s] %s _(%s", src.ID, util.TruncateRunes(strings.Join(strings.Fields(src.Content), " "), sourceSnippetLen), src.Type)
+		if !src.CreatedAt.IsZero() {
+			sb.WriteString(", " + src.CreatedAt.Format("2006-01-02"))
+		}
+		sb.WriteString(")_")
+	}
+	return sb.String()
+}
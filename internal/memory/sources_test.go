@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatContext(t *testing.T) {
+	sources := []Source{
+		{ID: "aaaa1111", Type: "fact", Content: "I work at Google"},
+		{ID: "bbbb2222", Type: "preference", Content: "I prefer dark mode"},
+	}
+
+	text, used := FormatContext("Relevant memories:", sources, 1000)
+	want := "Relevant memories:\n- [aaaa1111] (fact) I work at Google\n- [bbbb2222] (preference) I prefer dark mode\n"
+	if text != want {
+		t.Errorf("FormatContext = %q, want %q", text, want)
+	}
+	if len(used) != 2 {
+		t.Errorf("used %d sources, want 2", len(used))
+	}
+
+	// Only the first line fits
+	if _, used := FormatContext("Relevant memories:", sources, 40); len(used) != 1 || used[0].ID != "aaaa1111" {
+		t.Errorf("used = %+v, want the first source only", used)
+	}
+	if text, used := FormatContext("Relevant memories:", sources, 5); text != "" || used != nil {
+		t.Errorf("FormatContext with no room = %q, %v", text, used)
+	}
+}
+
+func TestCited(t *testing.T) {
+	sources := []Source{{ID: "aaaa1111"}, {ID: "bbbb2222"}, {ID: "cccc3333"}}
+
+	tests := []struct {
+		answer string
+		want   []string
+	}{
+		{"No memories here.", nil},
+		{"You work at Google [bbbb2222].", []string{"bbbb2222"}},
+		{"Both [cccc3333, aaaa1111], again [cccc3333].", []string{"cccc3333", "aaaa1111"}},
+		{"Made up [dddd4444] and [link](http://x).", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, src := range Cited(tt.answer, sources) {
+			got = append(got, src.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Cited(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSources(t *testing.T) {
+	got := FormatSources([]Source{{ID: "aaaa1111", Type: "fact", Content: "I work\nat " + strings.Repeat("x", 200)}})
+	if !strings.HasPrefix(got, "📚 *Sources:*\n[aaaa1111] I work at xxx") || !strings.HasSuffix(got, "... _(fact)_") {
+		t.Errorf("FormatSources = %q", got)
+	}
+}
@@ -0,0 +1,210 @@
+// Package moderation screens text with a moderation API, such as OpenAI's
+// moderations endpoint, before it reaches the LLM or the user.
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/util"
+)
+
+// Provider identifies the moderation API provider.
+type Provider string
+
+const (
+	ProviderOpenAI Provider = "openai"
+	ProviderLocal  Provider = "local" // Self-hosted server speaking the OpenAI moderations API
+
+	// DefaultModel is the OpenAI moderation model used when none is set.
+	DefaultModel = "omni-moderation-latest"
+
+	// maxResponseSize limits moderation API response reads (1 MB).
+	maxResponseSize = 1 << 20
+
+	// maxInputBytes caps the text sent for one check; the rest is not
+	// screened.
+	maxInputBytes = 32 * 1024
+)
+
+// Config holds moderation client configuration.
+type Config struct {
+	Provider Provider
+	APIKey   string // #nosec G117 -- config field, not serialized to untrusted output
+	Model    string
+	BaseURL  string // Custom base URL for API
+	Timeout  time.Duration
+	Proxy    string // HTTP proxy URL; empty uses HTTPS_PROXY/NO_PROXY
+	Logger   *slog.Logger
+
+	// Thresholds maps categories (e.g. "harassment", "self-harm/intent") to
+	// the score at or above which they count as flagged, overriding the
+	// provider's own verdict. A threshold above 1 turns a category off.
+	Thresholds map[string]float64
+}
+
+// Result is the verdict on one text.
+type Result struct {
+	Flagged    bool
+	Categories []string           // categories that tripped, sorted
+	Scores     map[string]float64 // every category's score, as reported
+}
+
+// Client checks text with a moderation API.
+type Client struct {
+	config Config
+	client *http.Client
+}
+
+// NewClient creates a new moderation client.
+func NewClient(cfg Config) *Client {
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOpenAI
+	}
+	if cfg.Model == "" && cfg.Provider == ProviderOpenAI {
+		cfg.Model = DefaultModel
+	}
+	if cfg.BaseURL == "" && cfg.Provider == ProviderOpenAI {
+		cfg.BaseURL = "https://api.openai.com/v1"
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	client, err := util.NewProxyHTTPClient(cfg.Timeout, cfg.Proxy)
+	if err != nil {
+		// Fail closed: never bypass a proxy the operator asked for
+		cfg.Logger.Error("moderation proxy rejected; requests will fail", "error", err)
+		client = util.NewHTTPClient(cfg.Timeout)
+		client.Transport = &http.Transport{Proxy: func(*http.Request) (*url.URL, error) { return nil, err }}
+	}
+	return &Client{config: cfg, client: client}
+}
+
+// ValidateConfig validates the moderation client configuration.
+func ValidateConfig(cfg Config) error {
+	switch cfg.Provider {
+	case "", ProviderOpenAI:
+		if strings.TrimSpace(cfg.APIKey) == "" {
+			return errors.New("API key required for provider: openai")
+		}
+		if cfg.BaseURL != "" {
+			if err := util.ValidateBaseURL(cfg.BaseURL); err != nil {
+				return fmt.Errorf("base URL validation failed: %w", err)
+			}
+		}
+	case ProviderLocal:
+		if cfg.BaseURL == "" {
+			return errors.New("base URL required for provider: local")
+		}
+		// The local provider may reach localhost and private networks
+		if err := util.ValidateLocalBaseURL(cfg.BaseURL); err != nil {
+			return fmt.Errorf("base URL validation failed: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown moderation provider: %s", cfg.Provider)
+	}
+	for category, threshold := range cfg.Thresholds {
+		if threshold < 0 {
+			return fmt.Errorf("threshold for %q must not be negative", category)
+		}
+	}
+	return nil
+}
+
+type moderationRequest struct {
+	Input string `json:"input"`
+	Model string `json:"model,omitempty"`
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		Categories     map[string]bool    `json:"categories"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Check screens text. A category trips when its score reaches its
+// threshold, or, without one, when the provider flags it.
+func (c *Client) Check(ctx context.Context, text string) (*Result, error) {
+	if strings.TrimSpace(text) == "" {
+		return &Result{}, nil
+	}
+	if len(text) > maxInputBytes {
+		text = strings.ToValidUTF8(text[:maxInputBytes], "")
+	}
+
+	data, err := json.Marshal(moderationRequest{Input: text, Model: c.config.Model})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.BaseURL+"/moderations", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := util.ReadHTTPBody(resp, maxResponseSize)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	var result moderationResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, util.SanitizeErrorMessage(util.Truncate(string(body), 200)))
+		}
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, util.SanitizeErrorMessage(result.Error.Message))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d)", resp.StatusCode)
+	}
+	if len(result.Results) == 0 {
+		return nil, errors.New("no moderation result returned")
+	}
+
+	r := result.Results[0]
+	verdict := &Result{Scores: r.CategoryScores}
+	categories := slices.Concat(slices.Collect(maps.Keys(r.Categories)), slices.Collect(maps.Keys(r.CategoryScores)))
+	slices.Sort(categories)
+	for _, category := range slices.Compact(categories) {
+		tripped := r.Categories[category]
+		if threshold, ok := c.config.Thresholds[category]; ok {
+			tripped = r.CategoryScores[category] >= threshold
+		}
+		if tripped {
+			verdict.Categories = append(verdict.Categories, category)
+		}
+	}
+	verdict.Flagged = len(verdict.Categories) > 0
+	return verdict, nil
+}
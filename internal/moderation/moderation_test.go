@@ -0,0 +1,124 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newModerationServer returns a mock moderations endpoint that flags text
+// containing "hate" and scores "violence" by how often "fight" appears.
+func newModerationServer(t *testing.T) (*httptest.Server, *[]moderationRequest) {
+	t.Helper()
+	var requests []moderationRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/moderations" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"bad key"}}`))
+			return
+		}
+		var req moderationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		requests = append(requests, req)
+		hate := strings.Contains(req.Input, "hate")
+		violence := 0.2 * float64(strings.Count(req.Input, "fight"))
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"results": []map[string]any{{
+				"flagged":         hate || violence >= 0.5,
+				"categories":      map[string]bool{"hate": hate, "violence": violence >= 0.5},
+				"category_scores": map[string]float64{"hate": map[bool]float64{true: 0.9, false: 0.01}[hate], "violence": violence},
+			}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestCheck(t *testing.T) {
+	srv, requests := newModerationServer(t)
+
+	tests := []struct {
+		name       string
+		thresholds map[string]float64
+		text       string
+		want       []string
+	}{
+		{"clean", nil, "hello there", nil},
+		{"provider flag", nil, "I hate this", []string{"hate"}},
+		{"below provider flag", nil, "fight", nil},
+		{"threshold lowers bar", map[string]float64{"violence": 0.1}, "fight", []string{"violence"}},
+		{"threshold raises bar", map[string]float64{"violence": 0.9}, "fight fight fight", nil},
+		{"threshold turns category off", map[string]float64{"hate": 2}, "I hate this", nil},
+		{"several", map[string]float64{"violence": 0.3}, "hate fight fight", []string{"hate", "violence"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL, APIKey: "test-key", Thresholds: tt.thresholds})
+			result, err := client.Check(context.Background(), tt.text)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if !slices.Equal(result.Categories, tt.want) {
+				t.Errorf("Categories = %v, want %v", result.Categories, tt.want)
+			}
+			if result.Flagged != (len(tt.want) > 0) {
+				t.Errorf("Flagged = %v, want %v", result.Flagged, len(tt.want) > 0)
+			}
+		})
+	}
+
+	if got := (*requests)[0].Model; got != "" {
+		t.Errorf("local provider sent model %q, want none", got)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	srv, requests := newModerationServer(t)
+
+	client := NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL, APIKey: "wrong"})
+	if _, err := client.Check(context.Background(), "hello"); err == nil || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("expected API error, got %v", err)
+	}
+
+	client = NewClient(Config{Provider: ProviderLocal, BaseURL: srv.URL, APIKey: "test-key"})
+	result, err := client.Check(context.Background(), "   ")
+	if err != nil || result.Flagged {
+		t.Errorf("blank text: got %+v, %v", result, err)
+	}
+	if len(*requests) != 0 {
+		t.Errorf("blank text should not be sent, got %d requests", len(*requests))
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"openai", Config{APIKey: "sk-test"}, false},
+		{"openai without key", Config{Provider: ProviderOpenAI}, true},
+		{"local", Config{Provider: ProviderLocal, BaseURL: "http://localhost:8080"}, false},
+		{"local without URL", Config{Provider: ProviderLocal}, true},
+		{"openai to localhost", Config{APIKey: "sk-test", BaseURL: "http://localhost:8080"}, true},
+		{"unknown provider", Config{Provider: "acme", APIKey: "k"}, true},
+		{"negative threshold", Config{APIKey: "sk-test", Thresholds: map[string]float64{"hate": -1}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateConfig(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 // Embed it in your Bot/Server struct to get thread-safe SetHandler/GetHandler.
 type Base struct {
 	handler   router.MessageHandler
+	reactions router.ReactionHandler
 	handlerMu sync.RWMutex
 }
 
@@ -28,3 +29,18 @@ func (b *Base) GetHandler() router.MessageHandler {
 	b.handlerMu.RUnlock()
 	return h
 }
+
+// SetReactionHandler sets the reaction event handler (thread-safe).
+func (b *Base) SetReactionHandler(h router.ReactionHandler) {
+	b.handlerMu.Lock()
+	b.reactions = h
+	b.handlerMu.Unlock()
+}
+
+// GetReactionHandler returns the current reaction handler (thread-safe).
+func (b *Base) GetReactionHandler() router.ReactionHandler {
+	b.handlerMu.RLock()
+	h := b.reactions
+	b.handlerMu.RUnlock()
+	return h
+}
@@ -0,0 +1,327 @@
+// Events API, slash commands, Block Kit buttons and their HTTP endpoints
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kusa/magabot/internal/format"
+	"github.com/kusa/magabot/internal/platform"
+	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/telemetry"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Paths of the HTTP endpoints served when Config.Port is set. Point the
+// app's Event Subscriptions, slash command and Interactivity Request URLs at
+// them; Config.EventsPath replaces DefaultEventsPath.
+const (
+	DefaultEventsPath = "/slack/events"
+	CommandsPath      = "/slack/commands"
+	InteractivePath   = "/slack/interactive"
+)
+
+// RootCommand is the app's own slash command. "/magabot status" runs
+// /status; any other slash command the app registers runs as itself.
+const RootCommand = "/magabot"
+
+const (
+	sectionMaxLen   = 3000    // Block Kit section text limit
+	maxRequestBody  = 1 << 20 // slash command and interaction payloads
+	responseTimeout = 10 * time.Second
+)
+
+// newServer returns the HTTP server for Events API callbacks on eventsPath,
+// slash commands and interactive components, verified with the app's
+// signing secret.
+func (b *Bot) newServer(bind string, port int, eventsPath string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(eventsPath, b.handleEventsHTTP)
+	mux.HandleFunc(CommandsPath, b.handleCommandsHTTP)
+	mux.HandleFunc(InteractivePath, b.handleInteractiveHTTP)
+	return &http.Server{
+		Addr:              net.JoinHostPort(bind, strconv.Itoa(port)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+}
+
+// verify checks r's Slack signature and timestamp and leaves the body
+// readable again. It replies with an error status and returns false when
+// the request is not from Slack.
+func (b *Bot) verify(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody+1))
+	if err != nil || len(body) > maxRequestBody {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	sv, err := slack.NewSecretsVerifier(r.Header, b.signingSecret)
+	if err == nil {
+		_, _ = sv.Write(body)
+		err = sv.Ensure()
+	}
+	if err != nil {
+		b.logger.Warn("rejected unsigned slack request", "path", r.URL.Path, "remote", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return true
+}
+
+// handleEventsHTTP answers the Events API's URL verification challenge and
+// acknowledges event callbacks at once, handling them in the background.
+func (b *Bot) handleEventsHTTP(w http.ResponseWriter, r *http.Request) {
+	if !b.verify(w, r) {
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	// The signature already proves the request is from Slack
+	event, err := slackevents.ParseEvent(body, slackevents.OptionNoVerifyToken())
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	switch event.Type {
+	case slackevents.URLVerification:
+		var challenge slackevents.ChallengeResponse
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, challenge.Challenge)
+	case slackevents.CallbackEvent:
+		w.WriteHeader(http.StatusOK)
+		b.dispatch(func() { b.handleCallbackEvent(b.ctx, event) })
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleCommandsHTTP acknowledges a slash command at once, within Slack's
+// 3-second window, and answers it in the background.
+func (b *Bot) handleCommandsHTTP(w http.ResponseWriter, r *http.Request) {
+	if !b.verify(w, r) {
+		return
+	}
+	cmd, err := slack.SlashCommandParse(r)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	b.dispatch(func() { b.handleSlashCommand(b.ctx, &cmd) })
+}
+
+// handleInteractiveHTTP acknowledges an interactive payload at once and
+// handles it in the background.
+func (b *Bot) handleInteractiveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !b.verify(w, r) {
+		return
+	}
+	var cb slack.InteractionCallback
+	if err := r.ParseForm(); err != nil || json.Unmarshal([]byte(r.PostForm.Get("payload")), &cb) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	b.dispatch(func() { b.handleInteraction(b.ctx, &cb) })
+}
+
+// dispatch runs fn in the background; Stop waits for it.
+func (b *Bot) dispatch(fn func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn()
+	}()
+}
+
+// slashText converts a slash command to the command the handler runs:
+// "/magabot status" is /status and a bare /magabot is /help.
+func slashText(cmd *slack.SlashCommand) string {
+	text := strings.TrimSpace(cmd.Text)
+	if cmd.Command != RootCommand {
+		return strings.TrimSpace("/" + strings.TrimPrefix(cmd.Command, "/") + " " + text)
+	}
+	if text == "" {
+		return "/help"
+	}
+	return "/" + strings.TrimPrefix(text, "/")
+}
+
+// handleSlashCommand runs a slash command. It arrives like a button press,
+// so it reaches the command handler whatever the command prefix is.
+func (b *Bot) handleSlashCommand(ctx context.Context, cmd *slack.SlashCommand) {
+	b.handleInteractive(ctx, &router.Message{
+		ID:        cmd.TriggerID,
+		Platform:  "slack",
+		ChatID:    cmd.ChannelID,
+		UserID:    cmd.UserID,
+		Username:  cmd.UserName,
+		Text:      router.ButtonPrefix + slashText(cmd),
+		Timestamp: time.Now(),
+		Raw:       cmd,
+	}, cmd.ResponseURL)
+}
+
+// handleInteraction dispatches Block Kit button presses as messages whose
+// text is the button value prefixed with router.ButtonPrefix.
+func (b *Bot) handleInteraction(ctx context.Context, cb *slack.InteractionCallback) {
+	if cb.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+	for _, action := range cb.ActionCallback.BlockActions {
+		if action.Value == "" {
+			continue
+		}
+		b.handleInteractive(ctx, &router.Message{
+			ID:        cb.TriggerID,
+			Platform:  "slack",
+			ChatID:    cb.Channel.ID,
+			ThreadID:  cb.Message.ThreadTimestamp,
+			UserID:    cb.User.ID,
+			Username:  cb.User.Name,
+			Text:      router.ButtonPrefix + action.Value,
+			Timestamp: time.Now(),
+			Raw:       cb,
+		}, cb.ResponseURL)
+	}
+}
+
+// handleInteractive runs a slash command or button press through the
+// handler and posts the answer to responseURL, or to the channel when
+// that fails.
+func (b *Bot) handleInteractive(ctx context.Context, msg *router.Message, responseURL string) {
+	handler := b.GetHandler()
+	if handler == nil {
+		return
+	}
+
+	ctx, span := telemetry.Start(ctx, "message", attribute.String("platform", "slack"))
+	defer span.End()
+
+	response, err := handler(ctx, msg)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		b.logger.Debug("handler error", "error", err)
+		return
+	}
+	if response == "" {
+		return
+	}
+
+	_, sendSpan := telemetry.Start(ctx, "platform.send")
+	defer sendSpan.End()
+
+	reply := router.Reply{Text: platform.SanitizeText("slack", response), Buttons: msg.Buttons}
+	if responseURL != "" {
+		err := b.postResponse(ctx, responseURL, reply)
+		if err == nil {
+			return
+		}
+		b.logger.Debug("response_url failed, posting to channel", "error", err)
+	}
+	if err := b.post(msg.ChatID, msg.ThreadID, reply); err != nil {
+		b.logger.Error("send interactive response failed", "channel", msg.ChatID, "error", err)
+		telemetry.RecordError(sendSpan, err)
+	}
+}
+
+// postResponse posts reply to a slash command's or interaction's
+// response_url, visible to the whole channel.
+func (b *Bot) postResponse(ctx context.Context, responseURL string, reply router.Reply) error {
+	if err := checkResponseURL(responseURL); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, responseTimeout)
+	defer cancel()
+
+	chunks := b.split(reply)
+	for i, chunk := range chunks {
+		wm := &slack.WebhookMessage{Text: chunk.Text, ResponseType: slack.ResponseTypeInChannel}
+		if i == len(chunks)-1 && len(reply.Buttons) > 0 {
+			wm.Blocks = &slack.Blocks{BlockSet: buttonBlocks(chunk.Text, reply.Buttons)}
+		}
+		if err := slack.PostWebhookContext(ctx, responseURL, wm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkResponseURL accepts only Slack's own https response URLs, so a
+// payload cannot make the bot post elsewhere.
+func checkResponseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host != "hooks.slack.com" {
+		return errors.New("response_url is not a Slack URL")
+	}
+	return nil
+}
+
+// post sends reply to a channel, in thread threadTS if set, with its
+// buttons under the last chunk.
+func (b *Bot) post(channelID, threadTS string, reply router.Reply) error {
+	chunks := b.split(reply)
+	for i, chunk := range chunks {
+		opts := []slack.MsgOption{slack.MsgOptionText(chunk.Text, false)}
+		if threadTS != "" {
+			opts = append(opts, slack.MsgOptionTS(threadTS))
+		}
+		if i == len(chunks)-1 && len(reply.Buttons) > 0 {
+			opts = append(opts, slack.MsgOptionBlocks(buttonBlocks(chunk.Text, reply.Buttons)...))
+		}
+		if _, _, err := b.api.PostMessage(channelID, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// split splits reply's text for sending. With buttons, chunks also fit in
+// the Block Kit section the last one is shown in.
+func (b *Bot) split(reply router.Reply) []platform.Chunk {
+	maxLen := b.maxLen
+	if len(reply.Buttons) > 0 {
+		maxLen = min(maxLen, sectionMaxLen)
+	}
+	return platform.SplitFormatted(reply.Text, maxLen, format.ToSlack)
+}
+
+// buttonBlocks renders text as a section followed by one actions block per
+// row of buttons. A press sends the button's Data back as its value.
+func buttonBlocks(text string, rows [][]router.Button) []slack.Block {
+	var blocks []slack.Block
+	if text != "" {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+	}
+	for i, row := range rows {
+		elements := make([]slack.BlockElement, 0, len(row))
+		for j, btn := range row {
+			label := slack.NewTextBlockObject(slack.PlainTextType, btn.Text, true, false)
+			elements = append(elements, slack.NewButtonBlockElement(fmt.Sprintf("button_%d_%d", i, j), btn.Data, label))
+		}
+		blocks = append(blocks, slack.NewActionBlock(fmt.Sprintf("buttons_%d", i), elements...))
+	}
+	return blocks
+}
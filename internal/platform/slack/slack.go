@@ -1,36 +1,65 @@
-// Package slack provides Slack bot integration using Socket Mode
+// Package slack provides Slack bot integration using Socket Mode or the
+// Events API over HTTP
 package slack
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/kusa/magabot/internal/format"
 	"github.com/kusa/magabot/internal/platform"
 	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/telemetry"
 	"github.com/kusa/magabot/internal/util"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Bot represents a Slack bot
 type Bot struct {
 	platform.Base
 	api    *slack.Client
-	socket *socketmode.Client
+	socket *socketmode.Client // nil without an app token: events arrive over HTTP
 	logger *slog.Logger
+	maxLen int
+	userID string // Bot's own user ID, to ignore its reactions
 	done   chan struct{}
 	wg     sync.WaitGroup
+
+	signingSecret string
+	server        *http.Server    // events, slash commands and interactivity over HTTP, or nil
+	ctx           context.Context // from Start, for requests answered in the background
+
+	connected atomic.Bool // Socket Mode connection or HTTP server state
 }
 
 // Config for Slack bot
 type Config struct {
 	BotToken string
 	AppToken string
+	MaxLen   int // Split outgoing messages longer than this (default: 4096, max 40000)
 	Logger   *slog.Logger
+
+	// Port serves Events API callbacks on EventsPath, slash commands on
+	// CommandsPath and interactive components on InteractivePath over HTTP,
+	// for apps that don't use Socket Mode. Requests must be signed with
+	// SigningSecret. 0 disables. Without an AppToken, HTTP is the only way
+	// events arrive.
+	Port          int
+	Bind          string // default: 127.0.0.1
+	EventsPath    string // default: DefaultEventsPath
+	SigningSecret string
 }
 
 // New creates a new Slack bot
@@ -40,14 +69,50 @@ func New(cfg *Config) (*Bot, error) {
 		slack.OptionAppLevelToken(cfg.AppToken),
 	)
 
-	socket := socketmode.New(api)
+	var socket *socketmode.Client
+	if cfg.AppToken != "" {
+		socket = socketmode.New(api)
+	}
+
+	maxLen := cfg.MaxLen
+	if maxLen <= 0 {
+		maxLen = slackMaxLen
+	}
+	maxLen = min(maxLen, slackHardMaxLen)
 
-	return &Bot{
+	b := &Bot{
 		api:    api,
 		socket: socket,
 		logger: cfg.Logger,
+		maxLen: maxLen,
 		done:   make(chan struct{}),
-	}, nil
+
+		signingSecret: cfg.SigningSecret,
+		ctx:           context.Background(),
+	}
+	if cfg.Port > 0 {
+		if cfg.SigningSecret == "" {
+			return nil, errors.New("slack: signing secret is required to serve events over HTTP")
+		}
+		bind := cfg.Bind
+		if bind == "" {
+			bind = "127.0.0.1"
+		}
+		eventsPath := cfg.EventsPath
+		if eventsPath == "" {
+			eventsPath = DefaultEventsPath
+		}
+		if !strings.HasPrefix(eventsPath, "/") {
+			eventsPath = "/" + eventsPath
+		}
+		if eventsPath == CommandsPath || eventsPath == InteractivePath {
+			return nil, fmt.Errorf("slack: webhook path %s is taken by slash commands or interactivity", eventsPath)
+		}
+		b.server = b.newServer(bind, cfg.Port, eventsPath)
+	} else if socket == nil {
+		return nil, errors.New("slack: an app token (Socket Mode) or a webhook port (Events API) is required")
+	}
+	return b, nil
 }
 
 // Name returns the platform name
@@ -55,18 +120,50 @@ func (b *Bot) Name() string {
 	return "slack"
 }
 
-// Start starts the socket mode client
+// Start starts the socket mode client and the HTTP server, whichever are
+// configured
 func (b *Bot) Start(ctx context.Context) error {
-	b.wg.Add(1)
-	go b.processEvents(ctx)
-
-	b.wg.Add(1)
-	go func() {
-		defer b.wg.Done()
-		if err := b.socket.Run(); err != nil {
-			b.logger.Error("socket mode error", "error", err)
+	if auth, err := b.api.AuthTest(); err == nil {
+		b.userID = auth.UserID
+	} else {
+		b.logger.Warn("auth test failed", "error", err)
+	}
+
+	b.ctx = ctx
+
+	if b.server != nil {
+		ln, err := net.Listen("tcp", b.server.Addr)
+		if err != nil {
+			return fmt.Errorf("slack: listen on %s: %w", b.server.Addr, err)
 		}
-	}()
+		if b.socket == nil {
+			b.connected.Store(true)
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			if err := b.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				b.logger.Error("slack http server error", "error", err)
+			}
+			if b.socket == nil {
+				b.connected.Store(false)
+			}
+		}()
+		b.logger.Info("serving slack events, slash commands and interactivity", "addr", b.server.Addr)
+	}
+
+	if b.socket != nil {
+		b.wg.Add(1)
+		go b.processEvents(ctx)
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			if err := b.socket.Run(); err != nil {
+				b.logger.Error("socket mode error", "error", err)
+			}
+		}()
+	}
 
 	b.logger.Info("slack bot started")
 	return nil
@@ -75,19 +172,114 @@ func (b *Bot) Start(ctx context.Context) error {
 // Stop stops the bot
 func (b *Bot) Stop() error {
 	close(b.done)
+	if b.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = b.server.Shutdown(ctx)
+	}
 	b.wg.Wait()
 	return nil
 }
 
 // Send sends a message
 func (b *Bot) Send(chatID, message string) error {
-	_, _, err := b.api.PostMessage(chatID, slack.MsgOptionText(message, false))
-	return err
+	chunks := platform.SplitFormatted(message, b.maxLen, format.ToSlack)
+	for i, chunk := range chunks {
+		if _, _, err := b.api.PostMessage(chatID, slack.MsgOptionText(chunk.Text, false)); err != nil {
+			return platform.SendFailed(err, chunks, i)
+		}
+	}
+	return nil
+}
+
+// SendButtons sends a message with the reply's buttons as Block Kit
+// actions under its last chunk.
+func (b *Bot) SendButtons(chatID string, reply router.Reply) error {
+	return b.post(chatID, "", reply)
 }
 
 // SendVoice is not supported on Slack; it's a no-op.
 func (b *Bot) SendVoice(_ string, _ []byte) error { return nil }
 
+// SendFile uploads data as a file to a channel.
+func (b *Bot) SendFile(chatID, name string, data []byte) error {
+	_, err := b.api.UploadFile(slack.UploadFileParameters{
+		Channel:  chatID,
+		Filename: name,
+		Title:    name,
+		Reader:   bytes.NewReader(data),
+		FileSize: len(data),
+	})
+	return err
+}
+
+// slackMaxFile is Slack's upload size limit in bytes.
+const slackMaxFile = 1 << 30
+
+// SendMedia uploads files to a channel, each with its caption as the
+// message it is shared with. Slack shows images inline itself.
+func (b *Bot) SendMedia(chatID string, files []router.MediaFile, wait func() error) error {
+	for _, f := range files {
+		if err := f.CheckSize("slack", slackMaxFile); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		if err := wait(); err != nil {
+			return err
+		}
+		if _, err := b.api.UploadFile(slack.UploadFileParameters{
+			Channel:        chatID,
+			Filename:       f.Name,
+			Title:          f.Name,
+			InitialComment: format.ToSlack(f.Caption),
+			Reader:         bytes.NewReader(f.Data),
+			FileSize:       len(f.Data),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsConnected reports whether the Socket Mode connection is up, or without
+// Socket Mode, whether the HTTP server is serving.
+func (b *Bot) IsConnected() bool { return b.connected.Load() }
+
+// React adds the bot's reaction to a message; messageID is its timestamp.
+func (b *Bot) React(chatID, messageID, emoji string) error {
+	return b.api.AddReaction(reactionName(emoji), slack.ItemRef{Channel: chatID, Timestamp: messageID})
+}
+
+// MaxReactions returns 0: Slack does not limit the bot's reactions.
+func (b *Bot) MaxReactions() int { return 0 }
+
+// SetReactionHandler is provided by platform.Base.
+
+// reactionName converts a feedback emoji to Slack's reaction name.
+func reactionName(emoji string) string {
+	switch emoji {
+	case router.FeedbackUp:
+		return "+1"
+	case router.FeedbackDown:
+		return "-1"
+	}
+	return strings.Trim(emoji, ":")
+}
+
+// reactionEmoji converts a Slack reaction name to the feedback emoji, or
+// returns it as ":name:" when it is not one.
+func reactionEmoji(name string) string {
+	name, _, _ = strings.Cut(name, "::") // drop skin tone, e.g. "+1::skin-tone-3"
+	switch name {
+	case "+1", "thumbsup":
+		return router.FeedbackUp
+	case "-1", "thumbsdown":
+		return router.FeedbackDown
+	}
+	return ":" + name + ":"
+}
+
 // SetHandler is provided by platform.Base.
 
 // processEvents processes socket mode events
@@ -117,42 +309,73 @@ func (b *Bot) handleEvent(ctx context.Context, evt socketmode.Event) {
 
 		b.socket.Ack(*evt.Request)
 
-		switch eventsAPIEvent.Type {
-		case slackevents.CallbackEvent:
-			innerEvent := eventsAPIEvent.InnerEvent
-			switch ev := innerEvent.Data.(type) {
-			case *slackevents.MessageEvent:
-				if ev.BotID != "" {
-					return
-				}
-				b.handleMessage(ctx, ev)
-			}
+		if eventsAPIEvent.Type == slackevents.CallbackEvent {
+			b.handleCallbackEvent(ctx, eventsAPIEvent)
 		}
 
+	case socketmode.EventTypeConnected:
+		b.connected.Store(true)
+
+	case socketmode.EventTypeConnecting, socketmode.EventTypeConnectionError,
+		socketmode.EventTypeInvalidAuth, socketmode.EventTypeDisconnect:
+		b.connected.Store(false)
+
 	case socketmode.EventTypeSlashCommand:
 		cmd, ok := evt.Data.(slack.SlashCommand)
 		if !ok {
 			return
 		}
 		b.socket.Ack(*evt.Request)
-		b.handleSlashCommand(ctx, &cmd)
+		b.dispatch(func() { b.handleSlashCommand(ctx, &cmd) })
+
+	case socketmode.EventTypeInteractive:
+		cb, ok := evt.Data.(slack.InteractionCallback)
+		if !ok {
+			return
+		}
+		b.socket.Ack(*evt.Request)
+		b.dispatch(func() { b.handleInteraction(ctx, &cb) })
 	}
 }
 
-// handleMessage handles an incoming message
+// handleCallbackEvent handles an Events API callback, from Socket Mode or
+// HTTP.
+func (b *Bot) handleCallbackEvent(ctx context.Context, event slackevents.EventsAPIEvent) {
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.MessageEvent:
+		if ev.BotID != "" {
+			return
+		}
+		b.handleMessage(ctx, ev)
+	case *slackevents.ReactionAddedEvent:
+		b.handleReaction(ctx, ev.User, ev.Reaction, ev.Item, false)
+	case *slackevents.ReactionRemovedEvent:
+		b.handleReaction(ctx, ev.User, ev.Reaction, ev.Item, true)
+	}
+}
 
-// slackMaxLen is the max message length for splitting long responses.
-const slackMaxLen = 4096
+// slackMaxLen is the default length for splitting long responses; Slack
+// truncates message text beyond slackHardMaxLen.
+const (
+	slackMaxLen     = 4096
+	slackHardMaxLen = 40000
+)
 
+// handleMessage handles an incoming message
 func (b *Bot) handleMessage(ctx context.Context, ev *slackevents.MessageEvent) {
 	handler := b.GetHandler()
 	if handler == nil {
 		return
 	}
 
+	ctx, span := telemetry.Start(ctx, "message", attribute.String("platform", "slack"))
+	defer span.End()
+
 	msg := &router.Message{
+		ID:        ev.TimeStamp,
 		Platform:  "slack",
 		ChatID:    ev.Channel,
+		ThreadID:  threadRoot(ev),
 		UserID:    ev.User,
 		Text:      ev.Text,
 		Timestamp: parseSlackTimestamp(ev.TimeStamp),
@@ -204,27 +427,34 @@ func (b *Bot) handleMessage(ctx context.Context, ev *slackevents.MessageEvent) {
 
 	// Set up streaming callback — send new messages progressively (no editing)
 	st := util.NewStreamTracker(2 * time.Second)
+	var lastSent string // Timestamp of the latest message sent for the response
 
 	msg.StreamCallback = func(text string) {
 		newPortion, ok := st.ShouldSend(text)
 		if !ok {
 			return
 		}
+		_, sendSpan := telemetry.Start(ctx, "platform.send", attribute.Bool("streaming", true))
+		defer sendSpan.End()
 
-		for _, chunk := range platform.SplitMessage(platform.SanitizeText("slack", newPortion), slackMaxLen) {
-			if _, _, err := b.api.PostMessage(ev.Channel,
+		for _, chunk := range platform.SplitMessage(platform.SanitizeText("slack", newPortion), b.maxLen) {
+			_, ts, err := b.api.PostMessage(ev.Channel,
 				slack.MsgOptionText(chunk, false),
 				slack.MsgOptionTS(ev.TimeStamp),
-			); err != nil {
+			)
+			if err != nil {
 				b.logger.Debug("stream: send failed", "error", err)
+				telemetry.RecordError(sendSpan, err)
 				return
 			}
+			lastSent = ts
 		}
 		st.MarkSent(len(text))
 	}
 
 	response, err := handler(ctx, msg)
 	if err != nil {
+		telemetry.RecordError(span, err)
 		b.logger.Debug("handler error", "error", err)
 		return
 	}
@@ -234,50 +464,69 @@ func (b *Bot) handleMessage(ctx context.Context, ev *slackevents.MessageEvent) {
 	}
 
 	// Send the remaining text not yet delivered during streaming
-	finalText, shouldSend := st.FinalText(response)
-	if !shouldSend {
-		return
-	}
-	finalText = platform.SanitizeText("slack", finalText)
-
-	for _, chunk := range platform.SplitMessage(finalText, slackMaxLen) {
+	if finalText, shouldSend := st.FinalText(response); shouldSend {
+		finalText = platform.SanitizeText("slack", finalText)
+		_, sendSpan := telemetry.Start(ctx, "platform.send")
+		defer sendSpan.End()
+
+		reply := router.Reply{Text: finalText, Buttons: msg.Buttons}
+		chunks := b.split(reply)
+		for i, chunk := range chunks {
+			opts := []slack.MsgOption{slack.MsgOptionText(chunk.Text, false), slack.MsgOptionTS(ev.TimeStamp)}
+			if i == len(chunks)-1 && len(msg.Buttons) > 0 {
+				opts = append(opts, slack.MsgOptionBlocks(buttonBlocks(chunk.Text, msg.Buttons)...))
+			}
+			_, ts, err := b.api.PostMessage(ev.Channel, opts...)
+			if err != nil {
+				b.logger.Error("send chunk failed", "channel", ev.Channel, "error", err)
+				telemetry.RecordError(sendSpan, err)
+				continue
+			}
+			lastSent = ts
+		}
+	} else if len(msg.Buttons) > 0 {
+		// Everything was streamed already: send the buttons on their own
 		if _, _, err := b.api.PostMessage(ev.Channel,
-			slack.MsgOptionText(chunk, false),
+			slack.MsgOptionBlocks(buttonBlocks("", msg.Buttons)...),
 			slack.MsgOptionTS(ev.TimeStamp),
 		); err != nil {
-			b.logger.Error("send chunk failed", "channel", ev.Channel, "error", err)
+			b.logger.Debug("send buttons failed", "error", err)
 		}
 	}
-}
 
-// handleSlashCommand handles a slash command
-func (b *Bot) handleSlashCommand(ctx context.Context, cmd *slack.SlashCommand) {
-	handler := b.GetHandler()
-	if handler == nil {
-		return
+	if msg.OnSent != nil && lastSent != "" {
+		msg.OnSent(ev.Channel, lastSent)
 	}
+}
 
-	msg := &router.Message{
-		Platform:  "slack",
-		ChatID:    cmd.ChannelID,
-		UserID:    cmd.UserID,
-		Username:  cmd.UserName,
-		Text:      fmt.Sprintf("/%s %s", cmd.Command, cmd.Text),
-		Timestamp: time.Now(),
-		Raw:       cmd,
+// threadRoot returns the thread a message belongs to. Replies are always
+// posted in a thread, so a top-level channel message starts one rooted at
+// itself. Direct messages keep a single conversation unless the user replies
+// inside a thread.
+func threadRoot(ev *slackevents.MessageEvent) string {
+	if ev.ThreadTimeStamp != "" {
+		return ev.ThreadTimeStamp
 	}
-
-	response, err := handler(ctx, msg)
-	if err != nil {
-		b.logger.Debug("handler error", "error", err)
-		return
+	if ev.ChannelType == "im" {
+		return ""
 	}
+	return ev.TimeStamp
+}
 
-	if response != "" {
-		if _, _, err := b.api.PostMessage(cmd.ChannelID, slack.MsgOptionText(platform.SanitizeText("slack", response), false)); err != nil {
-			b.logger.Error("send slash response failed", "channel", cmd.ChannelID, "error", err)
-		}
+// handleReaction forwards a reaction on a message to the reaction handler.
+func (b *Bot) handleReaction(ctx context.Context, user, name string, item slackevents.Item, removed bool) {
+	handler := b.GetReactionHandler()
+	if handler == nil || item.Type != "message" || user == "" || user == b.userID {
+		return
 	}
+	handler(ctx, &router.Reaction{
+		Platform:  "slack",
+		ChatID:    item.Channel,
+		MessageID: item.Timestamp,
+		UserID:    user,
+		Emoji:     reactionEmoji(name),
+		Removed:   removed,
+	})
 }
 
 func parseSlackTimestamp(ts string) time.Time {
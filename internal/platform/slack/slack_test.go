@@ -0,0 +1,210 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/router"
+	"github.com/slack-go/slack"
+)
+
+const testSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+func TestSlashText(t *testing.T) {
+	tests := []struct {
+		command, text, want string
+	}{
+		{"/magabot", "status", "/status"},
+		{"/magabot", "  model  list ", "/model  list"},
+		{"/magabot", "/usage", "/usage"},
+		{"/magabot", "", "/help"},
+		{"/status", "", "/status"},
+		{"/remind", "in 5m stretch", "/remind in 5m stretch"},
+	}
+	for _, tt := range tests {
+		got := slashText(&slack.SlashCommand{Command: tt.command, Text: tt.text})
+		if got != tt.want {
+			t.Errorf("slashText(%q, %q) = %q, want %q", tt.command, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCheckResponseURL(t *testing.T) {
+	if err := checkResponseURL("https://hooks.slack.com/commands/T1/123/abc"); err != nil {
+		t.Errorf("Slack response URL rejected: %v", err)
+	}
+	for _, u := range []string{
+		"http://hooks.slack.com/commands/T1/123/abc",
+		"https://hooks.slack.com.evil.example/x",
+		"https://127.0.0.1/x",
+		"",
+	} {
+		if checkResponseURL(u) == nil {
+			t.Errorf("Expected %q to be rejected", u)
+		}
+	}
+}
+
+func TestButtonBlocks(t *testing.T) {
+	blocks := buttonBlocks("Update?", [][]router.Button{{{Text: "Yes", Data: "/yes"}, {Text: "No", Data: "/no"}}})
+	if len(blocks) != 2 {
+		t.Fatalf("Expected a section and an actions block, got %d blocks", len(blocks))
+	}
+	actions, ok := blocks[1].(*slack.ActionBlock)
+	if !ok || len(actions.Elements.ElementSet) != 2 {
+		t.Fatalf("Expected two buttons, got %#v", blocks[1])
+	}
+	if btn := actions.Elements.ElementSet[1].(*slack.ButtonBlockElement); btn.Value != "/no" {
+		t.Errorf("Expected the button value to carry its data, got %q", btn.Value)
+	}
+
+	if blocks := buttonBlocks("", [][]router.Button{{{Text: "OK", Data: "/yes"}}}); len(blocks) != 1 {
+		t.Errorf("Expected no section without text, got %d blocks", len(blocks))
+	}
+}
+
+func TestNewRequiresSigningSecret(t *testing.T) {
+	if _, err := New(&Config{Port: 3000, Logger: slog.Default()}); err == nil {
+		t.Error("Expected an error without a signing secret")
+	}
+	if _, err := New(&Config{BotToken: "xoxb-test", Logger: slog.Default()}); err == nil {
+		t.Error("Expected an error with neither an app token nor a webhook port")
+	}
+}
+
+// signedRequest returns a form POST signed like Slack signs requests.
+func signedRequest(path string, form url.Values, secret string, ts time.Time) *http.Request {
+	return signedBody(path, form.Encode(), "application/x-www-form-urlencoded", secret, ts)
+}
+
+// signedBody returns a POST of body signed like Slack signs requests.
+func signedBody(path, body, contentType, secret string, ts time.Time) *http.Request {
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = io.WriteString(mac, "v0:"+stamp+":"+body)
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Slack-Request-Timestamp", stamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestHTTPEndpoints(t *testing.T) {
+	b, err := New(&Config{Port: 3000, EventsPath: "/hooks/slack", SigningSecret: testSecret, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	// Web API calls made while handling a message get a stub answer
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"ok":false,"error":"not_authed"}`)
+	}))
+	t.Cleanup(api.Close)
+	b.api = slack.New("xoxb-test", slack.OptionAPIURL(api.URL+"/"))
+	got := make(chan *router.Message, 1)
+	b.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+		got <- msg
+		return "", nil
+	})
+	handler := b.server.Handler
+
+	receive := func(t *testing.T) *router.Message {
+		t.Helper()
+		select {
+		case msg := <-got:
+			return msg
+		case <-time.After(2 * time.Second):
+			t.Fatal("handler not called")
+			return nil
+		}
+	}
+
+	t.Run("URLVerification", func(t *testing.T) {
+		body := `{"type":"url_verification","token":"t","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, signedBody("/hooks/slack", body, "application/json", testSecret, time.Now()))
+		if rec.Code != http.StatusOK || rec.Body.String() != "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P" {
+			t.Errorf("Expected the challenge back, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("MessageEvent", func(t *testing.T) {
+		body := `{"type":"event_callback","team_id":"T1","event":{"type":"message","channel":"C3","channel_type":"channel","user":"U3","text":"hello","ts":"1700000000.000100"}}`
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, signedBody("/hooks/slack", body, "application/json", testSecret, time.Now()))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		msg := receive(t)
+		if msg.Text != "hello" || msg.UserID != "U3" || msg.ChatID != "C3" || msg.ThreadID != "1700000000.000100" {
+			t.Errorf("Unexpected message: %q from %s in %s/%s", msg.Text, msg.UserID, msg.ChatID, msg.ThreadID)
+		}
+	})
+
+	t.Run("DefaultEventsPathUnused", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, signedBody(DefaultEventsPath, `{"type":"url_verification","challenge":"x"}`, "application/json", testSecret, time.Now()))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 off webhook_path, got %d", rec.Code)
+		}
+	})
+
+	t.Run("SlashCommand", func(t *testing.T) {
+		form := url.Values{"command": {"/magabot"}, "text": {"status"}, "user_id": {"U1"}, "channel_id": {"C1"}}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, signedRequest(CommandsPath, form, testSecret, time.Now()))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		msg := receive(t)
+		if msg.Text != router.ButtonPrefix+"/status" || msg.UserID != "U1" || msg.ChatID != "C1" {
+			t.Errorf("Unexpected message: %q from %s in %s", msg.Text, msg.UserID, msg.ChatID)
+		}
+	})
+
+	t.Run("ButtonPress", func(t *testing.T) {
+		payload := `{"type":"block_actions","user":{"id":"U2"},"channel":{"id":"C2"},"actions":[{"block_id":"buttons_0","action_id":"button_0_0","type":"button","value":"/yes"}]}`
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, signedRequest(InteractivePath, url.Values{"payload": {payload}}, testSecret, time.Now()))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		msg := receive(t)
+		if msg.Text != router.ButtonPrefix+"/yes" || msg.UserID != "U2" || msg.ChatID != "C2" {
+			t.Errorf("Unexpected message: %q from %s in %s", msg.Text, msg.UserID, msg.ChatID)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		form := url.Values{"command": {"/magabot"}, "text": {"status"}}
+		for name, req := range map[string]*http.Request{
+			"wrong secret": signedRequest(CommandsPath, form, "other-secret", time.Now()),
+			"stale":        signedRequest(CommandsPath, form, testSecret, time.Now().Add(-10*time.Minute)),
+			"unsigned":     httptest.NewRequest(http.MethodPost, CommandsPath, strings.NewReader(form.Encode())),
+		} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s: expected 401, got %d", name, rec.Code)
+			}
+		}
+		select {
+		case msg := <-got:
+			t.Errorf("Handler called for a rejected request: %q", msg.Text)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	b.wg.Wait()
+}
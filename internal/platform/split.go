@@ -0,0 +1,206 @@
+// Splitting long messages into platform-sized chunks
+package platform
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kusa/magabot/internal/router"
+)
+
+// fenceClose is appended to a chunk that ends inside a code block.
+const fenceClose = "\n```"
+
+// reTableSeparator matches a markdown table header separator (|---|:--:|).
+var reTableSeparator = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+
+// SplitMessage splits text into chunks of at most maxLen bytes so each fits
+// a platform's message limit. Breaks are made at paragraph, then line, then
+// word boundaries, and never inside a UTF-8 sequence. A code block cut by a
+// split is closed at the end of the chunk and reopened (with its language)
+// at the start of the next; a table cut mid-way repeats its header rows.
+func SplitMessage(text string, maxLen int) []string {
+	if maxLen <= 0 || len(text) <= maxLen {
+		return []string{text}
+	}
+
+	var chunks []string
+	for len(text) > maxLen {
+		budget := maxLen
+		if strings.Contains(text, "```") && maxLen > 2*len(fenceClose) {
+			budget -= len(fenceClose)
+		}
+		cut, skip := splitPoint(text, budget)
+		chunk, rest, carry := splitAt(text, cut, skip, maxLen)
+		if len(carry)+len(rest) >= len(text) {
+			// The carried prefix would put back everything the cut removed
+			// (e.g. a fence line longer than the budget); cut hard instead
+			cut, skip = hardCut(text, budget), 0
+			chunk, rest, carry = splitAt(text, cut, skip, maxLen)
+			if len(carry)+len(rest) >= len(text) {
+				carry = ""
+			}
+		}
+
+		chunks = append(chunks, chunk)
+		text = carry + rest
+	}
+	if strings.TrimSpace(text) != "" || len(chunks) == 0 {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// splitPoint returns where to cut text so text[:cut] fits in budget, and how
+// many separator bytes to drop after the cut.
+func splitPoint(text string, budget int) (cut, skip int) {
+	window := text[:budget]
+	// Prefer a boundary in the second half so chunks are not tiny
+	half := budget / 2
+	if i := strings.LastIndex(window, "\n\n"); i > half {
+		return i, 2
+	}
+	if i := strings.LastIndex(window, "\n"); i > half {
+		return i, 1
+	}
+	if i := strings.LastIndexAny(window, " \t"); i > half {
+		return i, 1
+	}
+	if i := strings.LastIndex(window, "\n"); i > 0 {
+		return i, 1
+	}
+	return hardCut(text, budget), 0
+}
+
+// splitAt cuts text at cut, dropping skip separator bytes, and returns the
+// chunk, the rest, and the prefix to carry into the rest so its formatting
+// survives the split.
+func splitAt(text string, cut, skip, maxLen int) (chunk, rest, carry string) {
+	chunk, rest = text[:cut], text[cut+skip:]
+	if opener, open := openFence(chunk); open {
+		chunk += fenceClose
+		carry = opener + "\n"
+		if skip == 2 {
+			rest = text[cut+1:] // keep blank lines inside code
+		}
+	} else if header := tableHeader(chunk, rest); header != "" {
+		carry = header
+	}
+	if len(carry)+1 >= maxLen {
+		carry = "" // cannot make progress with the prefix; drop it
+	}
+	return chunk, rest, carry
+}
+
+// hardCut returns a cut at or before budget on a rune boundary. It always
+// cuts at least one rune, even if that rune is wider than budget.
+func hardCut(text string, budget int) int {
+	cut := budget
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		_, cut = utf8.DecodeRuneInString(text)
+	}
+	return cut
+}
+
+// openFence reports whether text ends inside a ``` code block and, if so,
+// returns the opening fence line (e.g. "```go") to reopen it with.
+func openFence(text string) (string, bool) {
+	var opener string
+	open := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			continue
+		}
+		if open {
+			open = false
+		} else {
+			open, opener = true, trimmed
+		}
+	}
+	return opener, open
+}
+
+// tableHeader returns the header and separator rows (with trailing newline)
+// of a markdown table that continues across the split, or "" if the split is
+// not inside a table.
+func tableHeader(chunk, rest string) string {
+	lines := strings.Split(chunk, "\n")
+	if !isTableRow(lines[len(lines)-1]) || !isTableRow(firstLine(rest)) {
+		return ""
+	}
+
+	start := len(lines) - 1
+	for start > 0 && isTableRow(lines[start-1]) {
+		start--
+	}
+	if start+1 >= len(lines)-1 || !reTableSeparator.MatchString(strings.TrimSpace(lines[start+1])) {
+		return "" // header not in this chunk, or the split is right after it
+	}
+	return lines[start] + "\n" + lines[start+1] + "\n"
+}
+
+func isTableRow(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "|")
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// Chunk is one piece of a formatted message: the converted text to send and
+// the source it came from, for resending as plain text if the platform
+// rejects the markup.
+type Chunk struct {
+	Text   string
+	Source string
+}
+
+// SendFailed returns the error for a message whose chunks[sent] failed to
+// send with err: when earlier chunks were delivered, a
+// *router.PartialSendError with the source of the unsent ones, so a retry
+// doesn't repeat them; else err.
+func SendFailed(err error, chunks []Chunk, sent int) error {
+	if sent == 0 {
+		return err
+	}
+	unsent := make([]string, 0, len(chunks)-sent)
+	for _, c := range chunks[sent:] {
+		unsent = append(unsent, c.Source)
+	}
+	return &router.PartialSendError{Unsent: unsent, Err: err}
+}
+
+// SplitFormatted splits text with SplitMessage and converts each chunk with
+// convert (e.g. format.ToTelegram). Escaping can grow a chunk past maxLen, so
+// oversized chunks are split again with a proportionally smaller budget.
+func SplitFormatted(text string, maxLen int, convert func(string) string) []Chunk {
+	var out []Chunk
+	for _, src := range SplitMessage(text, maxLen) {
+		out = append(out, fitFormatted(src, maxLen, maxLen, convert)...)
+	}
+	return out
+}
+
+func fitFormatted(src string, budget, maxLen int, convert func(string) string) []Chunk {
+	formatted := convert(src)
+	if maxLen <= 0 || len(formatted) <= maxLen || budget <= 1 {
+		return []Chunk{{Text: formatted, Source: src}}
+	}
+	budget = budget * maxLen / len(formatted)
+	if budget >= len(src) {
+		budget = len(src) - 1
+	}
+	var out []Chunk
+	for _, part := range SplitMessage(src, budget) {
+		out = append(out, fitFormatted(part, budget, maxLen, convert)...)
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kusa/magabot/internal/router"
+)
+
+func assertFits(t *testing.T, chunks []string, maxLen int) {
+	t.Helper()
+	for i, c := range chunks {
+		if len(c) > maxLen {
+			t.Errorf("chunk %d is %d bytes, limit %d", i, len(c), maxLen)
+		}
+		if !utf8.ValidString(c) {
+			t.Errorf("chunk %d is not valid UTF-8", i)
+		}
+	}
+}
+
+func TestSplitMessage_Short(t *testing.T) {
+	chunks := SplitMessage("hello", 100)
+	if len(chunks) != 1 || chunks[0] != "hello" {
+		t.Errorf("got %q", chunks)
+	}
+}
+
+func TestSplitMessage_PrefersParagraphs(t *testing.T) {
+	para := strings.Repeat("word ", 15) // 75 bytes
+	text := para + "\n\n" + para + "\n\n" + para
+	chunks := SplitMessage(text, 160)
+	assertFits(t, chunks, 160)
+	if len(chunks) != 2 || chunks[0] != para+"\n\n"+para {
+		t.Errorf("expected a break at the second paragraph, got %q", chunks)
+	}
+}
+
+func TestSplitMessage_WordBoundary(t *testing.T) {
+	text := strings.Repeat("lorem ipsum ", 50)
+	chunks := SplitMessage(text, 64)
+	assertFits(t, chunks, 64)
+	for i, c := range chunks {
+		for _, w := range strings.Fields(c) {
+			if w != "lorem" && w != "ipsum" {
+				t.Errorf("chunk %d broke a word: %q", i, w)
+			}
+		}
+	}
+}
+
+func TestSplitMessage_HardCutKeepsRunes(t *testing.T) {
+	text := strings.Repeat("é日本", 100) // no spaces or newlines
+	chunks := SplitMessage(text, 50)
+	assertFits(t, chunks, 50)
+	if strings.Join(chunks, "") != text {
+		t.Error("hard-cut chunks should reassemble to the original text")
+	}
+}
+
+func TestSplitMessage_OversizedCodeBlock(t *testing.T) {
+	var code []string
+	for i := 0; i < 60; i++ {
+		code = append(code, fmt.Sprintf("fmt.Println(%d) // line", i))
+	}
+	text := "Here is the code:\n\n```go\n" + strings.Join(code, "\n") + "\n```\n\nDone."
+	chunks := SplitMessage(text, 300)
+	assertFits(t, chunks, 300)
+	if len(chunks) < 3 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+
+	var got []string
+	for i, c := range chunks {
+		if strings.Count(c, "```")%2 != 0 {
+			t.Errorf("chunk %d has an unbalanced code fence:\n%s", i, c)
+		}
+		if i > 0 && i < len(chunks)-1 && !strings.HasPrefix(c, "```go\n") {
+			t.Errorf("chunk %d should reopen the go code block:\n%s", i, c)
+		}
+		for _, line := range strings.Split(c, "\n") {
+			if strings.HasPrefix(line, "fmt.") {
+				got = append(got, line)
+			}
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(code, "\n") {
+		t.Error("code lines were lost or broken across chunks")
+	}
+	if !strings.HasSuffix(chunks[len(chunks)-1], "Done.") {
+		t.Errorf("trailing text missing: %q", chunks[len(chunks)-1])
+	}
+}
+
+func TestSplitMessage_FenceLongerThanLimit(t *testing.T) {
+	for _, text := range []string{
+		"```go\n" + strings.Repeat("x", 5000),
+		"```" + strings.Repeat("x", 5000),
+	} {
+		done := make(chan []string, 1)
+		go func() { done <- SplitMessage(text, 4096) }()
+		select {
+		case chunks := <-done:
+			assertFits(t, chunks, 4096)
+			if n := strings.Count(strings.Join(chunks, ""), "x"); n != 5000 {
+				t.Errorf("got %d x's across chunks, want 5000", n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("SplitMessage did not return for %q...", text[:10])
+		}
+	}
+}
+
+func TestSplitMessage_LongFenceLineBeforeBlankLine(t *testing.T) {
+	// The blank line is kept inside the code block, so the cut at it
+	// consumes one byte less than it skips
+	text := "```" + strings.Repeat("a", 2500) + "\n\n" + strings.Repeat("b ", 2000)
+	done := make(chan []string, 1)
+	go func() { done <- SplitMessage(text, 4096) }()
+	select {
+	case chunks := <-done:
+		assertFits(t, chunks, 4096)
+		joined := strings.Join(chunks, "")
+		if strings.Count(joined, "a") < 2500 || strings.Count(joined, "b") != 2000 {
+			t.Errorf("text lost across %d chunks", len(chunks))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SplitMessage did not return")
+	}
+}
+
+func TestSplitMessage_MidTable(t *testing.T) {
+	header := "| Name | Score |"
+	sep := "|------|-------|"
+	rows := []string{header, sep}
+	for i := 0; i < 40; i++ {
+		rows = append(rows, fmt.Sprintf("| user%02d | %5d |", i, i*10))
+	}
+	text := "Results:\n" + strings.Join(rows, "\n")
+	chunks := SplitMessage(text, 200)
+	assertFits(t, chunks, 200)
+	if len(chunks) < 3 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+
+	seen := 0
+	for i, c := range chunks {
+		lines := strings.Split(c, "\n")
+		if i > 0 && (lines[0] != header || lines[1] != sep) {
+			t.Errorf("chunk %d should start with the table header:\n%s", i, c)
+		}
+		for _, line := range lines {
+			if strings.HasPrefix(line, "|") && !strings.HasSuffix(line, "|") {
+				t.Errorf("chunk %d split a table row: %q", i, line)
+			}
+			if strings.HasPrefix(line, "| user") {
+				seen++
+			}
+		}
+	}
+	if seen != 40 {
+		t.Errorf("saw %d data rows, want 40", seen)
+	}
+}
+
+func TestSplitFormatted(t *testing.T) {
+	// Escaping grows each chunk past the limit, so chunks must be re-split to fit
+	double := func(s string) string { return strings.NewReplacer(".", `\.`).Replace(s) }
+	text := strings.Repeat("a.b.c. ", 60)
+
+	chunks := SplitFormatted(text, 100, double)
+	var sources []string
+	for _, c := range chunks {
+		if len(c.Text) > 100 {
+			t.Errorf("formatted chunk is %d bytes, want <= 100", len(c.Text))
+		}
+		if c.Text != double(c.Source) {
+			t.Errorf("chunk text %q does not match its source %q", c.Text, c.Source)
+		}
+		sources = append(sources, c.Source)
+	}
+	if got := strings.Join(sources, " "); strings.ReplaceAll(got, " ", "") != strings.ReplaceAll(text, " ", "") {
+		t.Errorf("sources lost content:\n%s", got)
+	}
+}
+
+func TestSendFailed(t *testing.T) {
+	chunks := []Chunk{{Text: "*a*", Source: "a"}, {Text: "*b*", Source: "b"}, {Text: "*c*", Source: "c"}}
+	sendErr := fmt.Errorf("network unreachable")
+
+	if err := SendFailed(sendErr, chunks, 0); err != sendErr {
+		t.Errorf("nothing sent: got %v, want the send error", err)
+	}
+	var pe *router.PartialSendError
+	if err := SendFailed(sendErr, chunks, 1); !errors.As(err, &pe) || !errors.Is(err, sendErr) {
+		t.Fatalf("partly sent: got %v, want a PartialSendError", err)
+	}
+	if len(pe.Unsent) != 2 || pe.Unsent[0] != "b" || pe.Unsent[1] != "c" {
+		t.Errorf("Unsent = %q, want the sources of the unsent chunks", pe.Unsent)
+	}
+}
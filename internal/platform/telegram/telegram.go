@@ -10,14 +10,18 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/kusa/magabot/internal/format"
 	"github.com/kusa/magabot/internal/platform"
 	"github.com/kusa/magabot/internal/router"
 	"github.com/kusa/magabot/internal/security"
+	"github.com/kusa/magabot/internal/telemetry"
 	"github.com/kusa/magabot/internal/util"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Bot represents a Telegram bot
@@ -27,6 +31,7 @@ type Bot struct {
 	logger       *slog.Logger
 	downloadsDir string
 	username     string
+	maxLen       int
 	done         chan struct{}
 	wg           sync.WaitGroup
 }
@@ -35,6 +40,7 @@ type Bot struct {
 type Config struct {
 	Token        string
 	DownloadsDir string // Directory to save downloaded media
+	MaxLen       int    // Split outgoing messages longer than this (default: 4096)
 	Logger       *slog.Logger
 }
 
@@ -57,10 +63,16 @@ func New(cfg *Config) (*Bot, error) {
 		}
 	}
 
+	maxLen := cfg.MaxLen
+	if maxLen <= 0 || maxLen > telegramMaxLen {
+		maxLen = telegramMaxLen
+	}
+
 	return &Bot{
 		api:          api,
 		logger:       cfg.Logger,
 		downloadsDir: downloadsDir,
+		maxLen:       maxLen,
 		done:         make(chan struct{}),
 	}, nil
 }
@@ -99,15 +111,95 @@ func (b *Bot) Send(chatID, message string) error {
 		return fmt.Errorf("invalid chat ID: %s", chatID)
 	}
 
-	opts := &gotgbot.SendMessageOpts{ParseMode: "Markdown"}
+	opts := &gotgbot.SendMessageOpts{ParseMode: "MarkdownV2"}
+	if threadID != 0 {
+		opts.MessageThreadId = threadID
+	}
+
+	chunks := platform.SplitFormatted(message, b.maxLen, format.ToTelegram)
+	for i, chunk := range chunks {
+		if _, err := b.sendChunk(groupID, chunk, opts); err != nil {
+			return platform.SendFailed(err, chunks, i)
+		}
+	}
+	return nil
+}
+
+// SendButtons sends a message with the reply's buttons as an inline
+// keyboard under its last chunk.
+func (b *Bot) SendButtons(chatID string, reply router.Reply) error {
+	groupID, threadID := parseChatID(chatID)
+	if groupID == 0 {
+		return fmt.Errorf("invalid chat ID: %s", chatID)
+	}
+
+	opts := &gotgbot.SendMessageOpts{ParseMode: "MarkdownV2"}
 	if threadID != 0 {
 		opts.MessageThreadId = threadID
 	}
 
-	_, err := b.api.SendMessage(groupID, message, opts)
+	chunks := platform.SplitFormatted(reply.Text, b.maxLen, format.ToTelegram)
+	for i, chunk := range chunks {
+		chunkOpts := *opts
+		if i == len(chunks)-1 {
+			chunkOpts.ReplyMarkup = inlineKeyboard(reply.Buttons)
+		}
+		if _, err := b.sendChunk(groupID, chunk, &chunkOpts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inlineKeyboard converts button rows to a Telegram inline keyboard.
+func inlineKeyboard(rows [][]router.Button) gotgbot.InlineKeyboardMarkup {
+	kb := make([][]gotgbot.InlineKeyboardButton, 0, len(rows))
+	for _, row := range rows {
+		kbRow := make([]gotgbot.InlineKeyboardButton, 0, len(row))
+		for _, btn := range row {
+			kbRow = append(kbRow, gotgbot.InlineKeyboardButton{Text: btn.Text, CallbackData: btn.Data})
+		}
+		kb = append(kb, kbRow)
+	}
+	return gotgbot.InlineKeyboardMarkup{InlineKeyboard: kb}
+}
+
+// sendChunk sends a MarkdownV2 chunk, falling back to its unformatted source
+// if Telegram rejects the entities. Returns the sent message ID.
+func (b *Bot) sendChunk(chatID int64, chunk platform.Chunk, opts *gotgbot.SendMessageOpts) (int64, error) {
+	chunkOpts := *opts
+	sent, err := b.api.SendMessage(chatID, chunk.Text, &chunkOpts)
+	if err == nil {
+		return sent.MessageId, nil
+	}
+	chunkOpts.ParseMode = ""
+	sent, err2 := b.api.SendMessage(chatID, chunk.Source, &chunkOpts)
+	if err2 != nil {
+		return 0, fmt.Errorf("send failed (even without parse mode): %w", err2)
+	}
+	b.logger.Debug("sent without formatting", "error", err)
+	return sent.MessageId, nil
+}
+
+// React sets the bot's reaction on a message. Telegram bots may only have one
+// reaction per message, so this replaces any earlier one.
+func (b *Bot) React(chatID, messageID, emoji string) error {
+	groupID, _ := parseChatID(chatID)
+	msgID, err := strconv.ParseInt(messageID, 10, 64)
+	if groupID == 0 || err != nil {
+		return fmt.Errorf("invalid message reference: %s/%s", chatID, messageID)
+	}
+	_, err = b.api.SetMessageReaction(groupID, msgID, &gotgbot.SetMessageReactionOpts{
+		Reaction: []gotgbot.ReactionType{gotgbot.ReactionTypeEmoji{Emoji: emoji}},
+	})
 	return err
 }
 
+// MaxReactions returns 1: non-premium bots can set a single reaction.
+func (b *Bot) MaxReactions() int { return 1 }
+
+// SetReactionHandler is provided by platform.Base.
+
 // SendVoice sends an OGG Opus audio as a Telegram voice message.
 func (b *Bot) SendVoice(chatID string, audio []byte) error {
 	groupID, threadID := parseChatID(chatID)
@@ -122,6 +214,84 @@ func (b *Bot) SendVoice(chatID string, audio []byte) error {
 	return err
 }
 
+// SendFile sends data as a Telegram document.
+func (b *Bot) SendFile(chatID, name string, data []byte) error {
+	groupID, threadID := parseChatID(chatID)
+	if groupID == 0 {
+		return fmt.Errorf("invalid chat ID: %s", chatID)
+	}
+	opts := &gotgbot.SendDocumentOpts{}
+	if threadID != 0 {
+		opts.MessageThreadId = threadID
+	}
+	_, err := b.api.SendDocument(groupID, gotgbot.InputFileByReader(name, bytes.NewReader(data)), opts)
+	return err
+}
+
+// Telegram Bot API upload limits
+const (
+	telegramMaxPhoto    = 10 << 20 // bytes
+	telegramMaxDocument = 50 << 20
+	telegramMaxCaption  = 1024 // characters
+)
+
+// SendMedia sends JPEG, PNG and WebP images as photos and other files as
+// documents. A caption too long for Telegram follows its file as a message.
+func (b *Bot) SendMedia(chatID string, files []router.MediaFile, wait func() error) error {
+	groupID, threadID := parseChatID(chatID)
+	if groupID == 0 {
+		return fmt.Errorf("invalid chat ID: %s", chatID)
+	}
+	for _, f := range files {
+		limit := telegramMaxDocument
+		if telegramPhoto(f) {
+			limit = telegramMaxPhoto
+		}
+		if err := f.CheckSize("telegram", limit); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		caption, after := f.Caption, ""
+		if len([]rune(caption)) > telegramMaxCaption {
+			caption, after = "", caption
+		}
+		file := gotgbot.InputFileByReader(f.Name, bytes.NewReader(f.Data))
+		if err := wait(); err != nil {
+			return err
+		}
+		var err error
+		if telegramPhoto(f) {
+			_, err = b.api.SendPhoto(groupID, file, &gotgbot.SendPhotoOpts{Caption: caption, MessageThreadId: threadID})
+		} else {
+			_, err = b.api.SendDocument(groupID, file, &gotgbot.SendDocumentOpts{Caption: caption, MessageThreadId: threadID})
+		}
+		if err != nil {
+			return err
+		}
+		if after != "" {
+			if err := wait(); err != nil {
+				return err
+			}
+			if err := b.Send(chatID, after); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// telegramPhoto reports whether f goes out as a photo rather than a
+// document.
+func telegramPhoto(f router.MediaFile) bool {
+	switch f.Type() {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
 // SetHandler is provided by platform.Base.
 
 // pollUpdates runs the long-polling loop
@@ -139,8 +309,9 @@ func (b *Bot) pollUpdates(ctx context.Context) {
 		}
 
 		updates, err := b.api.GetUpdatesWithContext(ctx, &gotgbot.GetUpdatesOpts{
-			Offset:  offset,
-			Timeout: 60,
+			Offset:         offset,
+			Timeout:        60,
+			AllowedUpdates: []string{"message", "message_reaction", "callback_query"},
 		})
 		if err != nil {
 			if ctx.Err() != nil {
@@ -158,15 +329,48 @@ func (b *Bot) pollUpdates(ctx context.Context) {
 
 		for i := range updates {
 			offset = updates[i].UpdateId + 1
-			if updates[i].Message != nil {
-				go b.handleUpdate(ctx, updates[i].Message)
+			if m := updates[i].Message; m != nil {
+				go b.handleUpdate(ctx, m, strconv.FormatInt(m.MessageId, 10))
+			}
+			if updates[i].CallbackQuery != nil {
+				go b.handleCallback(ctx, updates[i].CallbackQuery)
+			}
+			if updates[i].MessageReaction != nil {
+				go b.handleReaction(ctx, updates[i].MessageReaction)
 			}
 		}
 	}
 }
 
-// handleUpdate handles a single incoming message
-func (b *Bot) handleUpdate(ctx context.Context, msg *gotgbot.Message) {
+// handleCallback dispatches an inline keyboard press as a message whose text
+// is the button data prefixed with router.ButtonPrefix.
+func (b *Bot) handleCallback(ctx context.Context, cq *gotgbot.CallbackQuery) {
+	// Stop the button's loading spinner whatever the outcome
+	if _, err := b.api.AnswerCallbackQuery(cq.Id, nil); err != nil {
+		b.logger.Debug("answer callback failed", "error", err)
+	}
+	origin, ok := cq.Message.(gotgbot.Message)
+	if !ok || cq.Data == "" {
+		return
+	}
+	from := cq.From
+	b.handleUpdate(ctx, &gotgbot.Message{
+		MessageId:       origin.MessageId,
+		MessageThreadId: origin.MessageThreadId,
+		IsTopicMessage:  origin.IsTopicMessage,
+		Chat:            origin.Chat,
+		From:            &from,
+		Date:            time.Now().Unix(),
+		Text:            router.ButtonPrefix + cq.Data,
+	}, "cb:"+cq.Id)
+}
+
+// handleUpdate handles a single incoming message; id identifies it for
+// deduplication.
+func (b *Bot) handleUpdate(ctx context.Context, msg *gotgbot.Message, id string) {
+	ctx, span := telemetry.Start(ctx, "message", attribute.String("platform", "telegram"))
+	defer span.End()
+
 	text := msg.Text
 	var media []string
 
@@ -235,10 +439,12 @@ func (b *Bot) handleUpdate(ctx context.Context, msg *gotgbot.Message) {
 	}
 
 	routerMsg := &router.Message{
+		ID:        id,
 		Platform:  "telegram",
 		ChatID:    chatID,
 		UserID:    fmt.Sprintf("%d", msg.From.Id),
 		Username:  msg.From.Username,
+		Locale:    msg.From.LanguageCode,
 		Text:      text,
 		Media:     media,
 		Timestamp: time.Unix(msg.Date, 0),
@@ -317,14 +523,17 @@ func (b *Bot) handleUpdate(ctx context.Context, msg *gotgbot.Message) {
 
 	// Set up streaming callback — send new messages progressively (no editing)
 	st := util.NewStreamTracker(2 * time.Second)
+	var lastSent int64 // ID of the latest message sent for the response
 
 	routerMsg.StreamCallback = func(text string) {
 		newPortion, ok := st.ShouldSend(text)
 		if !ok {
 			return
 		}
+		_, sendSpan := telemetry.Start(ctx, "platform.send", attribute.Bool("streaming", true))
+		defer sendSpan.End()
 
-		for i, chunk := range platform.SplitMessage(platform.SanitizeText("telegram", newPortion), telegramMaxLen) {
+		for i, chunk := range platform.SplitMessage(platform.SanitizeText("telegram", newPortion), b.maxLen) {
 			opts := &gotgbot.SendMessageOpts{}
 			if st.IsFirstChunk() && i == 0 {
 				opts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: msg.MessageId}
@@ -332,10 +541,13 @@ func (b *Bot) handleUpdate(ctx context.Context, msg *gotgbot.Message) {
 			if threadID != 0 {
 				opts.MessageThreadId = threadID
 			}
-			if _, err := b.api.SendMessage(msg.Chat.Id, chunk, opts); err != nil {
+			sent, err := b.api.SendMessage(msg.Chat.Id, chunk, opts)
+			if err != nil {
 				b.logger.Debug("stream: send failed", "error", err)
+				telemetry.RecordError(sendSpan, err)
 				return
 			}
+			lastSent = sent.MessageId
 		}
 		// Re-send typing since SendMessage clears it
 		typingOpts := &gotgbot.SendChatActionOpts{}
@@ -375,6 +587,7 @@ func (b *Bot) handleUpdate(ctx context.Context, msg *gotgbot.Message) {
 	response, err := handler(ctx, routerMsg)
 	close(typingDone)
 	if err != nil {
+		telemetry.RecordError(span, err)
 		b.logger.Warn("handler error", "error", err)
 		// Notify user for non-auth errors so the bot doesn't go silent
 		if err != security.ErrNotAuthorized && err != security.ErrAccountLocked {
@@ -393,31 +606,90 @@ func (b *Bot) handleUpdate(ctx context.Context, msg *gotgbot.Message) {
 		return
 	}
 
+	_, sendSpan := telemetry.Start(ctx, "platform.send")
+	defer sendSpan.End()
+
 	// Send the remaining text not yet delivered during streaming
-	finalText, shouldSend := st.FinalText(response)
-	if !shouldSend {
+	if finalText, shouldSend := st.FinalText(response); shouldSend {
+		finalText = platform.SanitizeText("telegram", finalText)
+
+		opts := &gotgbot.SendMessageOpts{ParseMode: "MarkdownV2"}
+		if !st.Streamed() {
+			opts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: msg.MessageId}
+		}
+		if threadID != 0 {
+			opts.MessageThreadId = threadID
+		}
+		chunks := platform.SplitFormatted(finalText, b.maxLen, format.ToTelegram)
+		for i, chunk := range chunks {
+			chunkOpts := *opts
+			if i == len(chunks)-1 && len(routerMsg.Buttons) > 0 {
+				chunkOpts.ReplyMarkup = inlineKeyboard(routerMsg.Buttons)
+			}
+			id, err := b.sendChunk(msg.Chat.Id, chunk, &chunkOpts)
+			if err != nil {
+				b.logger.Error("send failed", "error", err)
+				telemetry.RecordError(sendSpan, err)
+				break
+			}
+			lastSent = id
+		}
+	} else if len(routerMsg.Buttons) > 0 && lastSent != 0 {
+		// Everything was streamed already: attach the keyboard to the last message
+		if _, _, err := b.api.EditMessageReplyMarkup(&gotgbot.EditMessageReplyMarkupOpts{
+			ChatId:      msg.Chat.Id,
+			MessageId:   lastSent,
+			ReplyMarkup: inlineKeyboard(routerMsg.Buttons),
+		}); err != nil {
+			b.logger.Debug("attach buttons failed", "error", err)
+		}
+	}
+
+	if routerMsg.OnSent != nil && lastSent != 0 {
+		routerMsg.OnSent(strconv.FormatInt(msg.Chat.Id, 10), strconv.FormatInt(lastSent, 10))
+	}
+}
+
+// handleReaction forwards a user's reaction changes to the reaction handler,
+// one event per emoji added or removed.
+func (b *Bot) handleReaction(ctx context.Context, upd *gotgbot.MessageReactionUpdated) {
+	handler := b.GetReactionHandler()
+	if handler == nil || upd.User == nil || upd.User.Id == b.api.Id {
 		return
 	}
-	finalText = platform.SanitizeText("telegram", finalText)
 
-	opts := &gotgbot.SendMessageOpts{ParseMode: "Markdown"}
-	if !st.Streamed() {
-		opts.ReplyParameters = &gotgbot.ReplyParameters{MessageId: msg.MessageId}
+	before, after := reactionEmojis(upd.OldReaction), reactionEmojis(upd.NewReaction)
+	base := router.Reaction{
+		Platform:  "telegram",
+		ChatID:    strconv.FormatInt(upd.Chat.Id, 10),
+		MessageID: strconv.FormatInt(upd.MessageId, 10),
+		UserID:    strconv.FormatInt(upd.User.Id, 10),
+	}
+	for e := range before {
+		if !after[e] {
+			r := base
+			r.Emoji, r.Removed = e, true
+			handler(ctx, &r)
+		}
 	}
-	if threadID != 0 {
-		opts.MessageThreadId = threadID
+	for e := range after {
+		if !before[e] {
+			r := base
+			r.Emoji = e
+			handler(ctx, &r)
+		}
 	}
-	for _, chunk := range platform.SplitMessage(finalText, telegramMaxLen) {
-		chunkOpts := *opts
-		if _, err := b.api.SendMessage(msg.Chat.Id, chunk, &chunkOpts); err != nil {
-			// Markdown parse may fail on LLM output — retry without parse mode
-			chunkOpts.ParseMode = ""
-			if _, err2 := b.api.SendMessage(msg.Chat.Id, chunk, &chunkOpts); err2 != nil {
-				b.logger.Error("send failed (even without parse mode)", "original_error", err, "retry_error", err2)
-				break
-			}
+}
+
+// reactionEmojis returns the set of plain emoji reactions.
+func reactionEmojis(reactions []gotgbot.ReactionType) map[string]bool {
+	set := make(map[string]bool, len(reactions))
+	for _, r := range reactions {
+		if e, ok := r.(gotgbot.ReactionTypeEmoji); ok {
+			set[e.Emoji] = true
 		}
 	}
+	return set
 }
 
 // telegramMaxLen is Telegram's maximum message length in characters.
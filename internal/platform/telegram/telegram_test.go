@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PaulSonOfLars/gotgbot/v2"
+	"github.com/kusa/magabot/internal/router"
+)
+
+// apiCall is a Bot API request the test server got.
+type apiCall struct {
+	method, caption, text string
+}
+
+// newTestBot returns a Bot whose API calls go to a stub server, and the
+// calls it got.
+func newTestBot(t *testing.T) (*Bot, func() []apiCall) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		calls []apiCall
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			_ = r.ParseMultipartForm(1 << 20)
+		} else {
+			_ = r.ParseForm()
+		}
+		mu.Lock()
+		calls = append(calls, apiCall{path.Base(r.URL.Path), r.FormValue("caption"), r.FormValue("text")})
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := gotgbot.NewBot("123:test", &gotgbot.BotOpts{
+		DisableTokenCheck: true,
+		BotClient: &gotgbot.BaseBotClient{
+			Client:             http.Client{},
+			DefaultRequestOpts: &gotgbot.RequestOpts{APIURL: srv.URL},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot := &Bot{api: api, logger: slog.Default(), maxLen: telegramMaxLen, done: make(chan struct{})}
+	return bot, func() []apiCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(calls)
+	}
+}
+
+func TestSendMedia(t *testing.T) {
+	bot, calls := newTestBot(t)
+	long := strings.Repeat("é", telegramMaxCaption+1)
+	files := []router.MediaFile{
+		{Name: "chart.png", Data: []byte("png"), Caption: "the chart"},
+		{Name: "anim.gif", Data: []byte("gif")},
+		{Name: "report.pdf", Data: []byte("pdf"), Caption: long},
+	}
+	waits := 0
+	if err := bot.SendMedia("42", files, func() error { waits++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	got := calls()
+	want := []string{"sendPhoto", "sendDocument", "sendDocument", "sendMessage"}
+	var methods []string
+	for _, c := range got {
+		methods = append(methods, c.method)
+	}
+	if !slices.Equal(methods, want) {
+		t.Fatalf("API calls = %v, want %v", methods, want)
+	}
+	if got[0].caption != "the chart" {
+		t.Errorf("photo caption = %q", got[0].caption)
+	}
+	// A caption over the limit is sent on its own after the file
+	if got[2].caption != "" || !strings.Contains(got[3].text, "é") {
+		t.Errorf("long caption: document caption %q, then message %q", got[2].caption, got[3].text)
+	}
+	if waits != len(want) {
+		t.Errorf("waited %d times, want once per message (%d)", waits, len(want))
+	}
+}
+
+func TestSendMediaStopsOnWait(t *testing.T) {
+	bot, calls := newTestBot(t)
+	errStop := errors.New("shutting down")
+	n := 0
+	err := bot.SendMedia("42", []router.MediaFile{{Name: "a.pdf"}, {Name: "b.pdf"}}, func() error {
+		if n++; n > 1 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("SendMedia error = %v, want the wait's", err)
+	}
+	if got := calls(); len(got) != 1 {
+		t.Errorf("API calls = %v, want only the first file", got)
+	}
+}
+
+func TestSendMediaTooLarge(t *testing.T) {
+	bot, calls := newTestBot(t)
+	photo := router.MediaFile{Name: "big.jpg", Data: make([]byte, telegramMaxPhoto+1)}
+	err := bot.SendMedia("42", []router.MediaFile{{Name: "ok.pdf"}, photo}, func() error { return nil })
+	if !errors.Is(err, router.ErrMediaTooLarge) {
+		t.Errorf("SendMedia error = %v, want ErrMediaTooLarge", err)
+	}
+	if got := calls(); len(got) != 0 {
+		t.Errorf("API calls = %v, want nothing sent", got)
+	}
+}
@@ -0,0 +1,137 @@
+// Admin alerts for repeated auth failures and sustained rate limiting
+package webhook
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AlertConfig notifies admins when the webhook looks under attack. Alerts
+// of one kind go out at most once per Cooldown; the next one counts those
+// held back meanwhile, so an attack from many IPs sends a few messages,
+// not hundreds.
+type AlertConfig struct {
+	Notify func(text string) // sends an alert; nil disables alerts
+
+	AuthFailures int           // failed auths from one IP that trigger an alert (default and max: MaxAuthFailures, the lockout)
+	RateLimited  int           // requests from one IP or user refused by rate limits within Window (default: 20)
+	Window       time.Duration // default: 10 minutes
+	Cooldown     time.Duration // default: 15 minutes
+}
+
+// Alert kinds, each debounced on its own.
+const (
+	alertAuth = "auth"
+	alertRate = "rate"
+)
+
+// alerter raises AlertConfig alerts. A nil alerter does nothing.
+type alerter struct {
+	cfg     AlertConfig
+	lockout time.Duration
+
+	mu        sync.Mutex
+	refused   map[string]*rateWindow // rate-limited requests per IP or user
+	lastSent  map[string]time.Time   // per alert kind
+	held      map[string]int         // alerts held back per kind since lastSent
+	lastPrune time.Time
+}
+
+// newAlerter returns nil when cfg has no Notify.
+func newAlerter(cfg AlertConfig, maxAuthFailures int, lockout time.Duration) *alerter {
+	if cfg.Notify == nil {
+		return nil
+	}
+	// Locked-out IPs are refused before authenticating, so their count
+	// never passes the lockout threshold
+	if cfg.AuthFailures <= 0 || cfg.AuthFailures > maxAuthFailures {
+		cfg.AuthFailures = maxAuthFailures
+	}
+	if cfg.RateLimited <= 0 {
+		cfg.RateLimited = 20
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Minute
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 15 * time.Minute
+	}
+	return &alerter{
+		cfg:      cfg,
+		lockout:  lockout,
+		refused:  make(map[string]*rateWindow),
+		lastSent: make(map[string]time.Time),
+		held:     make(map[string]int),
+	}
+}
+
+// authFailed is called after each failed auth with the IP's failure count
+// from the failure tracker; locked reports whether the IP is now locked out.
+func (a *alerter) authFailed(ip string, count int, locked bool) {
+	if a == nil || count != a.cfg.AuthFailures {
+		return
+	}
+	text := fmt.Sprintf("🚨 Webhook: %d failed auth attempts in a row from %s.", count, ip)
+	if locked {
+		text += fmt.Sprintf(" The IP is locked out for %s.", a.lockout)
+	}
+	a.send(alertAuth, text)
+}
+
+// rateLimited is called for each request a rate limit refuses; source names
+// the IP or user it was counted against.
+func (a *alerter) rateLimited(source string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	now := time.Now()
+	a.prune(now)
+	w := a.refused[source]
+	if w == nil || now.Sub(w.windowStart) >= a.cfg.Window {
+		w = &rateWindow{windowStart: now}
+		a.refused[source] = w
+	}
+	w.count++
+	hit := w.count == a.cfg.RateLimited
+	a.mu.Unlock()
+
+	if hit {
+		a.send(alertRate, fmt.Sprintf("🚦 Webhook: rate limits refused %d requests from %s within %s.", a.cfg.RateLimited, source, a.cfg.Window))
+	}
+}
+
+// prune drops refusal counts whose window has passed, at most once per
+// window. a.mu must be held.
+func (a *alerter) prune(now time.Time) {
+	if now.Sub(a.lastPrune) < a.cfg.Window {
+		return
+	}
+	a.lastPrune = now
+	for source, w := range a.refused {
+		if now.Sub(w.windowStart) >= a.cfg.Window {
+			delete(a.refused, source)
+		}
+	}
+}
+
+// send notifies admins unless an alert of the same kind went out within
+// the cooldown, in which case it is counted and held back.
+func (a *alerter) send(kind, text string) {
+	a.mu.Lock()
+	now := time.Now()
+	if last, ok := a.lastSent[kind]; ok && now.Sub(last) < a.cfg.Cooldown {
+		a.held[kind]++
+		a.mu.Unlock()
+		return
+	}
+	if n := a.held[kind]; n > 0 {
+		text += fmt.Sprintf("\n\n%d similar alert(s) were held back since the last one.", n)
+	}
+	a.held[kind] = 0
+	a.lastSent[kind] = now
+	a.mu.Unlock()
+
+	go a.cfg.Notify(text)
+}
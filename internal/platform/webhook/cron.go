@@ -0,0 +1,161 @@
+// On-demand cron job runs over HTTP
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// cronPrefix returns the path under which POST <path>/cron/<job id> runs
+// cron jobs.
+func cronPrefix(path string) string {
+	return strings.TrimSuffix(path, "/") + "/cron/"
+}
+
+// cronRunTimeout bounds one triggered run, llm_digest generation included.
+// The response deadline is extended to match.
+const cronRunTimeout = 4 * time.Minute
+
+// ErrUnknownCronJob is returned by a CronRunner for a job that doesn't
+// exist; the endpoint answers 404.
+var ErrUnknownCronJob = errors.New("unknown cron job")
+
+// CronRunner runs the cron job id now and returns the message it sent.
+type CronRunner func(ctx context.Context, id string) (string, error)
+
+// SetCronRunner sets how the cron endpoint runs jobs, once the scheduler is
+// up. Until then the endpoint answers 503.
+func (s *Server) SetCronRunner(run CronRunner) {
+	s.cronMu.Lock()
+	defer s.cronMu.Unlock()
+	s.cronRun = run
+}
+
+// handleCron runs the cron job named in the path for an authenticated,
+// allowed user, if Config.CronJobs lists it. It checks the request like
+// Config.Path does, but needs no body.
+func (s *Server) handleCron(w http.ResponseWriter, r *http.Request) {
+	requestID := generateRequestID()
+	setSecurityHeaders(w, requestID)
+	clientIP := getClientIP(r)
+	rt := s.routes[0]
+
+	entry := s.requests.begin(w, r, requestID, clientIP)
+	defer entry.finish()
+	w = entry.writer(w)
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.draining.Load() {
+		writeDraining(w)
+		return
+	}
+	if s.failureTracker.isLocked(clientIP) {
+		writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Too many failures, try again later")
+		return
+	}
+	if s.ipLimiter != nil && !s.ipLimiter.allow(clientIP) {
+		setRateLimitHeaders(w, s.ipLimiter, clientIP)
+		s.logger.Warn("webhook rate limited by IP", "ip", clientIP, "request_id", requestID)
+		s.alerts.rateLimited("IP " + clientIP)
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
+		return
+	}
+	if !rt.checkIP(r) {
+		s.logger.Warn("webhook blocked by IP", "ip", clientIP, "request_id", requestID)
+		writeError(w, http.StatusForbidden, CodeForbiddenIP, "IP not allowed")
+		return
+	}
+	// Anyone could run jobs on an unauthenticated endpoint
+	if rt.AuthMethod == "" || rt.AuthMethod == "none" {
+		writeError(w, http.StatusForbidden, CodeForbidden, "Cron triggers require authentication")
+		return
+	}
+	nonce, ok := s.checkReplayHeaders(w, r, clientIP, requestID)
+	if !ok {
+		return
+	}
+
+	defer func() { _ = r.Body.Close() }()
+	userID, ok := rt.authenticate(r)
+	entry.auth(ok, userID)
+	if !ok {
+		count, locked := s.failureTracker.recordFailure(clientIP)
+		s.logger.Warn("webhook auth failed", "path", r.URL.Path, "ip", clientIP, "request_id", requestID)
+		s.alerts.authFailed(clientIP, count, locked)
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	s.failureTracker.clearFailures(clientIP)
+	if s.config.RequireNonce && !s.useNonce(nonce) {
+		s.logger.Warn("webhook rejected: duplicate nonce (replay attack)", "ip", clientIP, "request_id", requestID, "nonce", nonce)
+		writeError(w, http.StatusConflict, CodeReplay, "Duplicate nonce")
+		return
+	}
+
+	if userID == "" {
+		userID = r.Header.Get("X-User-ID")
+	}
+	entry.user(userID)
+	if userID == "" || !rt.checkUser(userID) {
+		s.logger.Warn("webhook cron trigger blocked by user allowlist", "user_id", userID, "ip", clientIP, "request_id", requestID)
+		writeError(w, http.StatusForbidden, CodeForbiddenUser, "User not allowed")
+		return
+	}
+	if s.userLimiter != nil && !s.userLimiter.allow(userID) {
+		setRateLimitHeaders(w, s.userLimiter, userID)
+		s.logger.Warn("webhook rate limited by user", "user_id", userID, "ip", clientIP, "request_id", requestID)
+		s.alerts.rateLimited("user " + userID)
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
+		return
+	}
+
+	jobID := strings.TrimPrefix(r.URL.Path, cronPrefix(s.config.Path))
+	if !slices.Contains(s.config.CronJobs, jobID) {
+		s.logger.Warn("webhook cron trigger denied: job not triggerable", "job", jobID, "user_id", userID, "request_id", requestID)
+		writeError(w, http.StatusForbidden, CodeForbidden, "Job not triggerable")
+		return
+	}
+	s.cronMu.RLock()
+	run := s.cronRun
+	s.cronMu.RUnlock()
+	if run == nil {
+		writeError(w, http.StatusServiceUnavailable, CodeUnavailable, "Cron is not running")
+		return
+	}
+
+	s.logger.Info("webhook cron trigger", "job", jobID, "user_id", userID, "ip", clientIP, "request_id", requestID)
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(cronRunTimeout + 10*time.Second))
+	ctx, cancel := context.WithTimeout(r.Context(), cronRunTimeout)
+	defer cancel()
+	message, err := run(ctx, jobID)
+
+	status := http.StatusOK
+	result := map[string]interface{}{"ok": err == nil, "job": jobID, "request_id": requestID}
+	switch {
+	case errors.Is(err, ErrUnknownCronJob):
+		status = http.StatusNotFound
+		result["error"] = errorDetail{Code: CodeNotFound, Message: "Job not found"}
+	case err != nil:
+		s.logger.Warn("webhook cron job failed", "job", jobID, "error", err, "request_id", requestID)
+		status = http.StatusInternalServerError
+		result["error"] = errorDetail{Code: CodeFailed, Message: err.Error()}
+		if message != "" { // generated, but not delivered everywhere
+			result["message"] = message
+		}
+	default:
+		result["message"] = message
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(result)
+}
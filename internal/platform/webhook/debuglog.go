@@ -0,0 +1,279 @@
+// Recent-request ring buffer behind GET /debug/requests
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DebugPath serves the recorded requests when Config.DebugRequests is set.
+const DebugPath = "/debug/requests"
+
+// Limits that keep the request log's memory bounded whatever the config says.
+const (
+	maxDebugRequests   = 1000 // entries kept
+	maxDebugBodyBytes  = 4096 // body bytes kept per entry
+	maxDebugHeaders    = 32   // headers kept per entry
+	maxDebugHeaderSize = 256  // bytes kept per header value
+)
+
+// redacted replaces the value of headers that carry credentials.
+const redacted = "[REDACTED]"
+
+// secretHeaderParts mark a header as carrying credentials when its
+// lowercase name contains one of them.
+var secretHeaderParts = []string{"authorization", "cookie", "signature", "token", "secret", "password", "key"}
+
+// RequestRecord is one webhook request as kept by the request log.
+type RequestRecord struct {
+	Time          time.Time         `json:"time"`
+	RequestID     string            `json:"request_id"`
+	Method        string            `json:"method"`
+	Path          string            `json:"path"`
+	IP            string            `json:"ip"`
+	Headers       map[string]string `json:"headers"`
+	BodySize      int               `json:"body_size"`
+	BodySHA256    string            `json:"body_sha256,omitempty"`
+	Body          string            `json:"body,omitempty"`
+	BodyTruncated bool              `json:"body_truncated,omitempty"`
+	Auth          string            `json:"auth"` // "ok", "failed", or "" if rejected before auth
+	UserID        string            `json:"user_id,omitempty"`
+	Status        int               `json:"status"`
+	DurationMS    int64             `json:"duration_ms"`
+}
+
+// requestLog keeps the last size requests, overwriting the oldest.
+type requestLog struct {
+	mu        sync.Mutex
+	entries   []RequestRecord
+	next      int
+	full      bool
+	bodyBytes int
+}
+
+func newRequestLog(size, bodyBytes int) *requestLog {
+	return &requestLog{
+		entries:   make([]RequestRecord, min(size, maxDebugRequests)),
+		bodyBytes: min(max(bodyBytes, 0), maxDebugBodyBytes),
+	}
+}
+
+func (l *requestLog) add(rec RequestRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = rec
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// snapshot returns the kept requests, newest first.
+func (l *requestLog) snapshot() []RequestRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []RequestRecord
+	if l.full {
+		out = append(out, l.entries[l.next:]...)
+	}
+	out = append(out, l.entries[:l.next]...)
+	slices.Reverse(out)
+	return out
+}
+
+// debugEntry collects one request's record while it is served. Its methods
+// do nothing on a nil entry, so serveRoute need not check whether the log
+// is enabled.
+type debugEntry struct {
+	http.ResponseWriter
+	log   *requestLog
+	rec   RequestRecord
+	start time.Time
+}
+
+// begin starts recording r; finish adds the record to the log. It returns
+// nil when l is nil.
+func (l *requestLog) begin(w http.ResponseWriter, r *http.Request, requestID, clientIP string) *debugEntry {
+	if l == nil {
+		return nil
+	}
+	return &debugEntry{
+		ResponseWriter: w,
+		log:            l,
+		start:          time.Now(),
+		rec: RequestRecord{
+			Time:      time.Now().UTC(),
+			RequestID: requestID,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			IP:        clientIP,
+			Headers:   debugHeaders(r.Header),
+		},
+	}
+}
+
+// WriteHeader records the response status.
+func (e *debugEntry) WriteHeader(status int) {
+	if e.rec.Status == 0 {
+		e.rec.Status = status
+	}
+	e.ResponseWriter.WriteHeader(status)
+}
+
+func (e *debugEntry) Write(b []byte) (int, error) {
+	if e.rec.Status == 0 {
+		e.rec.Status = http.StatusOK
+	}
+	return e.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (e *debugEntry) Unwrap() http.ResponseWriter {
+	return e.ResponseWriter
+}
+
+// writer returns the writer to respond through: e itself, so the status is
+// recorded, or w when e is nil.
+func (e *debugEntry) writer(w http.ResponseWriter) http.ResponseWriter {
+	if e == nil {
+		return w
+	}
+	return e
+}
+
+func (e *debugEntry) auth(ok bool, userID string) {
+	if e == nil {
+		return
+	}
+	e.rec.Auth = "failed"
+	if ok {
+		e.rec.Auth = "ok"
+	}
+	e.rec.UserID = userID
+}
+
+func (e *debugEntry) user(userID string) {
+	if e != nil {
+		e.rec.UserID = userID
+	}
+}
+
+// body records the body's size and hash, and its first bodyBytes bytes.
+func (e *debugEntry) body(body []byte) {
+	if e == nil {
+		return
+	}
+	sum := sha256.Sum256(body)
+	e.rec.BodySize = len(body)
+	e.rec.BodySHA256 = hex.EncodeToString(sum[:])
+	if n := e.log.bodyBytes; n > 0 {
+		e.rec.BodyTruncated = len(body) > n
+		e.rec.Body = strings.ToValidUTF8(string(body[:min(len(body), n)]), "�")
+	}
+}
+
+func (e *debugEntry) finish() {
+	if e == nil {
+		return
+	}
+	e.rec.DurationMS = time.Since(e.start).Milliseconds()
+	e.log.add(e.rec)
+}
+
+// debugHeaders copies h for the log with credentials redacted and sizes capped.
+func debugHeaders(h http.Header) map[string]string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > maxDebugHeaders {
+		names = names[:maxDebugHeaders]
+	}
+
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		if isSecretHeader(name) {
+			out[name] = redacted
+			continue
+		}
+		value := strings.Join(h[name], ", ")
+		if len(value) > maxDebugHeaderSize {
+			value = strings.ToValidUTF8(value[:maxDebugHeaderSize], "") + "…"
+		}
+		out[name] = value
+	}
+	return out
+}
+
+func isSecretHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range secretHeaderParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleDebugRequests returns the request log as JSON to an admin who
+// passes the drain, rate limit, IP and auth checks of Config.Path.
+// Identities come from the auth mapping only, so auth_method none or a
+// legacy single token never grants access.
+func (s *Server) handleDebugRequests(w http.ResponseWriter, r *http.Request) {
+	requestID := generateRequestID()
+	setSecurityHeaders(w, requestID)
+	clientIP := getClientIP(r)
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.draining.Load() {
+		writeDraining(w)
+		return
+	}
+	if s.failureTracker.isLocked(clientIP) {
+		writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Too many failures, try again later")
+		return
+	}
+	if s.ipLimiter != nil && !s.ipLimiter.allow(clientIP) {
+		setRateLimitHeaders(w, s.ipLimiter, clientIP)
+		s.logger.Warn("webhook rate limited by IP", "ip", clientIP, "request_id", requestID)
+		s.alerts.rateLimited("IP " + clientIP)
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
+		return
+	}
+	if !s.checkIP(r) {
+		s.logger.Warn("webhook debug blocked by IP", "ip", clientIP, "request_id", requestID)
+		writeError(w, http.StatusForbidden, CodeForbiddenIP, "IP not allowed")
+		return
+	}
+	userID, ok := s.routes[0].authenticate(r)
+	if !ok || userID == "" {
+		count, locked := s.failureTracker.recordFailure(clientIP)
+		s.logger.Warn("webhook debug auth failed", "ip", clientIP)
+		s.alerts.authFailed(clientIP, count, locked)
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+		return
+	}
+	s.failureTracker.clearFailures(clientIP)
+	if !slices.Contains(s.config.DebugAdmins, userID) {
+		s.logger.Warn("webhook debug denied: not an admin", "user_id", userID, "ip", clientIP)
+		writeError(w, http.StatusForbidden, CodeForbiddenUser, "Not a webhook admin")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"requests": s.requests.snapshot(),
+	})
+}
@@ -0,0 +1,51 @@
+// JSON error responses
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes of webhook error responses. Callers can branch on them; the
+// messages are for people and may change.
+const (
+	CodeUnauthorized     = "unauthorized"           // 401: missing or wrong credentials
+	CodeRateLimited      = "rate_limited"           // 429: over a rate limit, or IP locked out after auth failures
+	CodeForbiddenUser    = "forbidden_user"         // 403: no user ID, or the user is not allowed
+	CodeForbiddenIP      = "forbidden_ip"           // 403: the IP is not allowed
+	CodeForbidden        = "forbidden"              // 403: the request is not allowed for another reason
+	CodeBadPayload       = "bad_payload"            // 400/413: unreadable or too large body, or no message in it
+	CodeUnsupportedMedia = "unsupported_media_type" // 415: Content-Type not in require_content_type
+	CodeReplay           = "replay"                 // 400/409: X-Timestamp or X-Nonce missing, stale or reused
+	CodeMethodNotAllowed = "method_not_allowed"     // 405
+	CodeUnavailable      = "unavailable"            // 503: shutting down, or the feature is not running
+	CodeNotFound         = "not_found"              // 404
+	CodeFailed           = "failed"                 // 500: the request was accepted but failed
+)
+
+// errorBody is the JSON envelope of an error response.
+type errorBody struct {
+	OK        bool        `json:"ok"`
+	Error     errorDetail `json:"error"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError answers with status and a JSON error envelope:
+// {"ok":false,"error":{"code":...,"message":...},"request_id":...}. The
+// request ID is the X-Request-ID set by setSecurityHeaders.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorBody{
+		Error:     errorDetail{Code: code, Message: message},
+		RequestID: h.Get("X-Request-ID"),
+	})
+}
@@ -0,0 +1,124 @@
+// Request signature algorithms for hmac auth
+package webhook
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- GitHub's legacy X-Hub-Signature, opt-in
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// Signature algorithms accepted in Config.HMACAlgorithms.
+const (
+	AlgSHA256  = "sha256"  // X-Hub-Signature-256 or X-Signature: "sha256=" + hex HMAC
+	AlgSHA1    = "sha1"    // X-Hub-Signature: "sha1=" + hex HMAC (legacy GitHub)
+	AlgEd25519 = "ed25519" // X-Signature-Ed25519: hex signature by Ed25519PublicKey
+)
+
+// signatureHeaders lists the headers that carry each algorithm's signature.
+var signatureHeaders = map[string][]string{
+	AlgSHA256:  {"X-Hub-Signature-256", "X-Signature"},
+	AlgSHA1:    {"X-Hub-Signature"},
+	AlgEd25519: {"X-Signature-Ed25519"},
+}
+
+// signatureAlgorithms validates algs and returns them, defaulting to sha256.
+func signatureAlgorithms(algs []string, ed25519Key ed25519.PublicKey) ([]string, error) {
+	if len(algs) == 0 {
+		return []string{AlgSHA256}, nil
+	}
+	out := make([]string, len(algs))
+	for i, alg := range algs {
+		alg = strings.ToLower(strings.TrimSpace(alg))
+		if _, ok := signatureHeaders[alg]; !ok {
+			return nil, fmt.Errorf("unknown hmac algorithm %q (want sha256, sha1 or ed25519)", alg)
+		}
+		if alg == AlgEd25519 && ed25519Key == nil {
+			return nil, fmt.Errorf("hmac algorithm ed25519 requires ed25519_public_key")
+		}
+		out[i] = alg
+	}
+	return out, nil
+}
+
+// parseEd25519Key decodes a hex or base64 Ed25519 public key. Empty is nil.
+func parseEd25519Key(s string) (ed25519.PublicKey, error) {
+	if s == "" {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("ed25519_public_key must be %d bytes, hex or base64 encoded", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// signatureHeader returns the first non-empty header carrying alg's signature.
+func signatureHeader(h http.Header, alg string) string {
+	for _, name := range signatureHeaders[alg] {
+		if sig := h.Get(name); sig != "" {
+			return sig
+		}
+	}
+	return ""
+}
+
+// verifySignature checks the alg signature in h over payload. HMAC
+// algorithms try each HMACUsers secret, then HMACSecret, and return the
+// matching secret's user ID; Ed25519 carries no identity.
+func (rt *route) verifySignature(alg string, h http.Header, payload []byte) (string, bool) {
+	sig := signatureHeader(h, alg)
+	if sig == "" {
+		return "", false
+	}
+
+	if alg == AlgEd25519 {
+		raw, err := hex.DecodeString(strings.TrimPrefix(sig, "ed25519="))
+		if err != nil || len(raw) != ed25519.SignatureSize {
+			return "", false
+		}
+		return "", ed25519.Verify(rt.ed25519Key, payload, raw)
+	}
+
+	if len(rt.HMACUsers) > 0 {
+		for secret, userID := range rt.HMACUsers {
+			if validSignature(alg, sig, secret, payload) {
+				return userID, true
+			}
+		}
+		return "", false
+	}
+	// Legacy: single secret
+	if rt.HMACSecret == "" {
+		return "", false
+	}
+	return "", validSignature(alg, sig, rt.HMACSecret, payload)
+}
+
+// validSignature reports whether sig is alg + "=" + the hex HMAC of payload
+// under secret, compared in constant time.
+func validSignature(alg, sig, secret string, payload []byte) bool {
+	var newHash func() hash.Hash
+	switch alg {
+	case AlgSHA256:
+		newHash = sha256.New
+	case AlgSHA1:
+		newHash = sha1.New
+	default:
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(payload)
+	expected := alg + "=" + hex.EncodeToString(mac.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(sig), []byte(expected)) == 1
+}
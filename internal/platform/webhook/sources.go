@@ -0,0 +1,213 @@
+// Configured payload schemas for known webhook sources
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SourceConfig describes how to read payloads from one webhook source. A
+// source matches a request when Header (or, if unset, the JSONPath Field)
+// equals Match; an empty Match accepts any non-empty value. Paths use a
+// JSONPath subset: "$.build.log", "$.commits[0].message", "$['user-id']".
+type SourceConfig struct {
+	Name     string
+	Header   string // request header to match, e.g. "X-Event-Source"
+	Field    string // JSONPath to match instead of a header
+	Match    string // expected header or field value
+	TextPath string // JSONPath to the message text (required)
+	UserPath string // JSONPath to the user ID (optional)
+
+	MaxBodySize int64 // body limit for this source (0 = Config.MaxBodySize)
+}
+
+// source is a SourceConfig with its paths compiled.
+type source struct {
+	cfg   SourceConfig
+	field jsonPath
+	text  jsonPath
+	user  jsonPath
+}
+
+// compileSources validates the configured sources.
+func compileSources(cfgs []SourceConfig) ([]source, error) {
+	sources := make([]source, 0, len(cfgs))
+	for i, c := range cfgs {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if c.Header == "" && c.Field == "" {
+			return nil, fmt.Errorf("webhook source %s: header or field is required", name)
+		}
+		if c.MaxBodySize < 0 {
+			return nil, fmt.Errorf("webhook source %s: max body size must not be negative", name)
+		}
+		if c.TextPath == "" {
+			return nil, fmt.Errorf("webhook source %s: text path is required", name)
+		}
+		src := source{cfg: c}
+		var err error
+		if c.Field != "" {
+			if src.field, err = parseJSONPath(c.Field); err != nil {
+				return nil, fmt.Errorf("webhook source %s: field: %w", name, err)
+			}
+		}
+		if src.text, err = parseJSONPath(c.TextPath); err != nil {
+			return nil, fmt.Errorf("webhook source %s: text path: %w", name, err)
+		}
+		if c.UserPath != "" {
+			if src.user, err = parseJSONPath(c.UserPath); err != nil {
+				return nil, fmt.Errorf("webhook source %s: user path: %w", name, err)
+			}
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// matches reports whether the request comes from this source.
+func (src *source) matches(r *http.Request, data any) bool {
+	var got string
+	if src.cfg.Header != "" {
+		got = r.Header.Get(src.cfg.Header)
+	} else {
+		got, _ = src.field.lookupString(data)
+	}
+	if src.cfg.Match == "" {
+		return got != ""
+	}
+	return strings.EqualFold(got, src.cfg.Match)
+}
+
+// extract reads the text and user ID from a matching payload.
+func (src *source) extract(data any) (text, userID string) {
+	text, _ = src.text.lookupString(data)
+	if src.user != nil {
+		userID, _ = src.user.lookupString(data)
+	}
+	return text, userID
+}
+
+// sourceLimit returns the body limit for a payload from src (nil = none).
+func (rt *route) sourceLimit(src *source) int64 {
+	if src != nil && src.cfg.MaxBodySize > 0 {
+		return src.cfg.MaxBodySize
+	}
+	return rt.maxBodySize
+}
+
+// readLimit returns how much of the body to read. A source identified by a
+// header sets the limit up front; sources matched on a JSON field are only
+// known after parsing, so the largest of their limits applies until then.
+func (rt *route) readLimit(r *http.Request) int64 {
+	limit := rt.maxBodySize
+	for i := range rt.sources {
+		src := &rt.sources[i]
+		if src.cfg.Header != "" {
+			if src.matches(r, nil) {
+				return rt.sourceLimit(src)
+			}
+			continue
+		}
+		limit = max(limit, src.cfg.MaxBodySize)
+	}
+	return limit
+}
+
+// maxBodyLimit returns the largest body limit of any source.
+func (rt *route) maxBodyLimit() int64 {
+	limit := rt.maxBodySize
+	for _, src := range rt.sources {
+		limit = max(limit, src.cfg.MaxBodySize)
+	}
+	return limit
+}
+
+// jsonPath is a parsed path: each step is a string key or an int index.
+type jsonPath []any
+
+// parseJSONPath parses "$" followed by ".key", "['key']" or "[n]" steps.
+func parseJSONPath(expr string) (jsonPath, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(expr), "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", expr)
+	}
+	var path jsonPath
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty key", expr)
+			}
+			path = append(path, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed [", expr)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				path = append(path, inner[1:len(inner)-1])
+				continue
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: bad index [%s]", expr, inner)
+			}
+			path = append(path, n)
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return path, nil
+}
+
+// lookup walks data along the path.
+func (p jsonPath) lookup(data any) (any, bool) {
+	for _, step := range p {
+		switch k := step.(type) {
+		case string:
+			m, ok := data.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if data, ok = m[k]; !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := data.([]interface{})
+			if !ok || k >= len(a) {
+				return nil, false
+			}
+			data = a[k]
+		}
+	}
+	return data, true
+}
+
+// lookupString returns the scalar at the path as a string.
+func (p jsonPath) lookupString(data any) (string, bool) {
+	v, ok := p.lookup(data)
+	if !ok {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return v, v != ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
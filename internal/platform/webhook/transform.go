@@ -0,0 +1,96 @@
+// Route-level transform expressions for arbitrary payloads
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// Limits on transform expressions and what they may produce.
+const (
+	maxTransformLen    = 1024                   // expression length in bytes
+	maxTransformOutput = 64 << 10               // extracted text in bytes
+	transformTimeout   = 100 * time.Millisecond // per evaluation
+)
+
+var errTransformTimeout = errors.New("transform timed out")
+
+// transform extracts the message from a JSON payload with a jq expression
+// (https://jqlang.github.io/jq/manual/), run by gojq. The first result is
+// used: a string is the text; an object supplies "text" and, if present,
+// "user_id", e.g. {text: .issue.title, user_id: .sender.login}. The
+// expression sees no environment variables.
+type transform struct {
+	expr string
+	code *gojq.Code
+}
+
+// compileTransform validates expr. It returns nil when expr is empty.
+func compileTransform(expr string) (*transform, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+	if len(expr) > maxTransformLen {
+		return nil, fmt.Errorf("transform is longer than %d bytes", maxTransformLen)
+	}
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("transform %q: %w", expr, err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("transform %q: %w", expr, err)
+	}
+	return &transform{expr: expr, code: code}, nil
+}
+
+// apply evaluates the transform against the decoded payload data, which
+// may be any JSON value, giving up after transformTimeout. Text longer than
+// maxTransformOutput is cut short.
+func (t *transform) apply(ctx context.Context, data any) (text, userID string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, transformTimeout)
+	defer cancel()
+
+	v, ok := t.code.RunWithContext(ctx, data).Next()
+	if !ok {
+		return "", "", nil
+	}
+	if err, isErr := v.(error); isErr {
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return "", "", errTransformTimeout
+		}
+		return "", "", fmt.Errorf("transform failed: %w", err)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		text = transformString(v["text"])
+		userID = transformString(v["user_id"])
+	default:
+		text = transformString(v)
+	}
+	if len(text) > maxTransformOutput {
+		text = strings.ToValidUTF8(text[:maxTransformOutput], "")
+	}
+	return strings.TrimSpace(text), strings.TrimSpace(userID), nil
+}
+
+// transformString renders a transform result as text: strings as they
+// are, other scalars as JSON, and null, arrays and objects as "".
+func transformString(v any) string {
+	switch v := v.(type) {
+	case nil, []any, map[string]any:
+		return ""
+	case string:
+		return v
+	default:
+		b, _ := gojq.Marshal(v)
+		return string(b)
+	}
+}
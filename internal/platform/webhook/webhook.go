@@ -4,25 +4,30 @@ package webhook
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/rand"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net"
 	"net/http"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kusa/magabot/internal/platform"
 	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/telemetry"
+	"github.com/kusa/magabot/internal/util"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // rateLimiter tracks request rates per key
@@ -75,6 +80,20 @@ func (rl *rateLimiter) allow(key string) bool {
 	return true
 }
 
+// status reports key's limit, requests left and when its window resets,
+// without counting a request.
+func (rl *rateLimiter) status(key string) (limit, remaining int, reset time.Time) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	now := time.Now()
+	w, exists := rl.requests[key]
+	if !exists || now.Sub(w.windowStart) >= rl.window {
+		return rl.limit, rl.limit, now.Add(rl.window)
+	}
+	return rl.limit, max(rl.limit-w.count, 0), w.windowStart.Add(rl.window)
+}
+
 func (rl *rateLimiter) cleanup() {
 	ticker := time.NewTicker(rl.window)
 	defer ticker.Stop()
@@ -130,7 +149,9 @@ func (ft *failureTracker) isLocked(key string) bool {
 	return false
 }
 
-func (ft *failureTracker) recordFailure(key string) {
+// recordFailure counts a failed auth from key and returns its failures in
+// a row and whether key is now locked out.
+func (ft *failureTracker) recordFailure(key string) (count int, locked bool) {
 	ft.mu.Lock()
 	defer ft.mu.Unlock()
 
@@ -151,6 +172,7 @@ func (ft *failureTracker) recordFailure(key string) {
 	if r.count >= ft.maxFails {
 		r.lockedUntil = time.Now().Add(ft.lockout)
 	}
+	return r.count, r.count >= ft.maxFails
 }
 
 func (ft *failureTracker) clearFailures(key string) {
@@ -170,10 +192,21 @@ type Server struct {
 	ipLimiter      *rateLimiter
 	userLimiter    *rateLimiter
 	failureTracker *failureTracker
+	alerts         *alerter // nil when Config.Alerts has no Notify
 	seenNonces     map[string]time.Time
 	noncesMu       sync.RWMutex
+	routes         []*route    // routes[0] serves Config.Path
+	draining       atomic.Bool // shutting down: reject new requests
+	ready          atomic.Bool // wiring done, see SetReady
+	requests       *requestLog // recent requests for DebugPath; nil when disabled
+	cronRun        CronRunner  // runs CronJobs; nil until SetCronRunner
+	cronMu         sync.RWMutex
 }
 
+// ProfileStrict is the security profile that binds X-Timestamp and X-Nonce
+// to the HMAC signature.
+const ProfileStrict = "strict"
+
 // Config for webhook server
 type Config struct {
 	Port         int
@@ -188,9 +221,13 @@ type Config struct {
 	HMACUsers    map[string]string // secret -> user_id mapping
 	AllowedIPs   []string
 	AllowedUsers []string // Required: allowed user IDs
-	MaxBodySize  int64
+	MaxBodySize  int64    // default body limit in bytes; sources may override
 	Logger       *slog.Logger
 
+	// RequireContentType rejects requests whose media type is not listed
+	// (e.g. "application/json") with 415. Empty accepts any.
+	RequireContentType []string
+
 	// Rate limiting
 	RateLimitPerIP   int           // requests per window per IP (0 = disabled)
 	RateLimitPerUser int           // requests per window per user (0 = disabled)
@@ -201,6 +238,127 @@ type Config struct {
 	AuthLockoutTime  time.Duration // lockout duration (default: 15 minutes)
 	RequireTimestamp bool          // require X-Timestamp header within 5 minutes
 	RequireNonce     bool          // require X-Nonce header (replay prevention)
+
+	// Alerts notify admins of repeated auth failures and sustained rate
+	// limiting
+	Alerts AlertConfig
+
+	// SecurityProfile "strict" requires hmac auth, X-Timestamp and X-Nonce,
+	// and binds them to the signature (see signedPayload). Empty keeps the
+	// checks independent.
+	SecurityProfile string
+
+	// HMACAlgorithms are the signatures hmac auth accepts: AlgSHA256
+	// (default), AlgSHA1 and AlgEd25519, which verifies with
+	// Ed25519PublicKey (hex or base64).
+	HMACAlgorithms   []string
+	Ed25519PublicKey string
+
+	// Payload schemas, tried in order before the built-in heuristics
+	Sources []SourceConfig
+
+	// Transform is a jq expression extracting the text and user ID from
+	// payloads no source matches (see transform). Empty uses the built-in
+	// heuristics.
+	Transform string
+
+	// Routes are extra endpoints served next to Path, each with its own
+	// auth, allowlists and sources. Rate limits, body and content-type
+	// limits, replay checks and the security profile are shared.
+	Routes []Route
+
+	// Ready backs /health/ready once SetReady(true) was called and a
+	// handler is set: a non-nil error reports the service as not ready
+	// (503). Nil means ready from then on.
+	Ready func() error
+
+	// DebugRequests keeps the last N requests in memory (at most 1000) and
+	// serves them as JSON on GET DebugPath to DebugAdmins, who authenticate
+	// like Config.Path. 0 disables both. Credential headers are redacted;
+	// bodies are kept as a SHA-256 hash plus their first DebugBodyBytes
+	// bytes (0 = hash only, at most 4096).
+	DebugRequests  int
+	DebugBodyBytes int
+	DebugAdmins    []string
+
+	// CronJobs are the IDs of the cron jobs that POST <Path>/cron/<id> may
+	// run (see SetCronRunner), authenticated like Path. Empty disables the
+	// endpoint.
+	CronJobs []string
+}
+
+// Route configures one extra webhook endpoint. Nothing is inherited from
+// the top-level Config, so each route's security stands on its own.
+type Route struct {
+	Path         string
+	AuthMethod   string            // none, bearer, basic, hmac
+	BearerTokens map[string]string // token -> user_id
+	BearerToken  string            // legacy single token
+	BasicUser    string
+	BasicPass    string
+	HMACSecret   string
+	HMACUsers    map[string]string // secret -> user_id
+	AllowedIPs   []string
+	AllowedUsers []string
+	Sources      []SourceConfig
+	Transform    string // see Config.Transform
+
+	HMACAlgorithms   []string // see Config.HMACAlgorithms
+	Ed25519PublicKey string
+}
+
+// route is a Route ready to serve: sources compiled and the server-wide
+// body limit and security profile applied.
+type route struct {
+	Route
+	sources     []source
+	transform   *transform
+	maxBodySize int64
+	strict      bool     // ProfileStrict: signatures cover X-Timestamp and X-Nonce
+	algorithms  []string // hmac signature algorithms, tried in order
+	ed25519Key  ed25519.PublicKey
+	logger      *slog.Logger
+
+	authMu sync.RWMutex // guards BearerTokens and AllowedUsers, replaced on reload
+}
+
+// newRoute validates and compiles a route.
+func newRoute(rc Route, cfg *Config) (*route, error) {
+	if !strings.HasPrefix(rc.Path, "/") {
+		return nil, fmt.Errorf("webhook route %q: path must start with /", rc.Path)
+	}
+	if cfg.SecurityProfile == ProfileStrict && rc.AuthMethod != "hmac" {
+		return nil, fmt.Errorf("webhook route %s: security profile %q requires auth_method hmac", rc.Path, ProfileStrict)
+	}
+	if rc.AuthMethod == "basic" && (rc.BasicUser == "" || rc.BasicPass == "") {
+		return nil, fmt.Errorf("webhook route %s: auth_method basic requires basic_user and basic_pass", rc.Path)
+	}
+	sources, err := compileSources(rc.Sources)
+	if err != nil {
+		return nil, fmt.Errorf("webhook route %s: %w", rc.Path, err)
+	}
+	tr, err := compileTransform(rc.Transform)
+	if err != nil {
+		return nil, fmt.Errorf("webhook route %s: %w", rc.Path, err)
+	}
+	key, err := parseEd25519Key(rc.Ed25519PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("webhook route %s: %w", rc.Path, err)
+	}
+	algorithms, err := signatureAlgorithms(rc.HMACAlgorithms, key)
+	if err != nil {
+		return nil, fmt.Errorf("webhook route %s: %w", rc.Path, err)
+	}
+	return &route{
+		Route:       rc,
+		sources:     sources,
+		transform:   tr,
+		maxBodySize: cfg.MaxBodySize,
+		strict:      cfg.SecurityProfile == ProfileStrict,
+		algorithms:  algorithms,
+		ed25519Key:  key,
+		logger:      cfg.Logger,
+	}, nil
 }
 
 // New creates a new webhook server
@@ -226,13 +384,75 @@ func New(cfg *Config) (*Server, error) {
 	if cfg.AuthLockoutTime == 0 {
 		cfg.AuthLockoutTime = 15 * time.Minute
 	}
+	switch cfg.SecurityProfile {
+	case "":
+	case ProfileStrict:
+		if cfg.AuthMethod != "hmac" {
+			return nil, fmt.Errorf("webhook security profile %q requires auth_method hmac", ProfileStrict)
+		}
+		cfg.RequireTimestamp = true
+		cfg.RequireNonce = true
+	default:
+		return nil, fmt.Errorf("unknown webhook security profile %q", cfg.SecurityProfile)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	main, err := newRoute(Route{
+		Path:         cfg.Path,
+		AuthMethod:   cfg.AuthMethod,
+		BearerTokens: cfg.BearerTokens,
+		BearerToken:  cfg.BearerToken,
+		BasicUser:    cfg.BasicUser,
+		BasicPass:    cfg.BasicPass,
+		HMACSecret:   cfg.HMACSecret,
+		HMACUsers:    cfg.HMACUsers,
+		AllowedIPs:   cfg.AllowedIPs,
+		AllowedUsers: cfg.AllowedUsers,
+		Transform:    cfg.Transform,
+
+		HMACAlgorithms:   cfg.HMACAlgorithms,
+		Ed25519PublicKey: cfg.Ed25519PublicKey,
+	}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if main.sources, err = compileSources(cfg.Sources); err != nil {
+		return nil, err
+	}
+	routes := []*route{main}
+	seen := map[string]bool{cfg.Path: true, "/health": true, "/health/live": true, "/health/ready": true}
+	if cfg.DebugRequests > 0 {
+		seen[DebugPath] = true
+	}
+	if len(cfg.CronJobs) > 0 {
+		seen[cronPrefix(cfg.Path)] = true
+	}
+	for _, rc := range cfg.Routes {
+		if seen[rc.Path] {
+			return nil, fmt.Errorf("webhook route %s: path already in use", rc.Path)
+		}
+		seen[rc.Path] = true
+		rt, err := newRoute(rc, cfg)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, rt)
+	}
 
 	s := &Server{
 		config:         cfg,
 		logger:         cfg.Logger,
 		done:           make(chan struct{}),
 		failureTracker: newFailureTracker(cfg.MaxAuthFailures, cfg.AuthLockoutTime),
+		alerts:         newAlerter(cfg.Alerts, cfg.MaxAuthFailures, cfg.AuthLockoutTime),
 		seenNonces:     make(map[string]time.Time),
+		routes:         routes,
+	}
+
+	if cfg.DebugRequests > 0 {
+		s.requests = newRequestLog(cfg.DebugRequests, cfg.DebugBodyBytes)
 	}
 
 	// Initialize rate limiters if configured
@@ -254,16 +474,33 @@ func (s *Server) Name() string {
 	return "webhook"
 }
 
-// Start starts the webhook server
-func (s *Server) Start(ctx context.Context) error {
+// newMux registers the webhook routes and health endpoints.
+func (s *Server) newMux() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc(s.config.Path, s.handleWebhook)
-	mux.HandleFunc("/health", s.handleHealth)
+	for _, rt := range s.routes[1:] {
+		mux.HandleFunc(rt.Path, func(w http.ResponseWriter, r *http.Request) {
+			s.serveRoute(w, r, rt)
+		})
+	}
+	mux.HandleFunc("/health", s.handleHealth) // alias for /health/live
+	mux.HandleFunc("/health/live", s.handleHealth)
+	mux.HandleFunc("/health/ready", s.handleReady)
+	if s.requests != nil {
+		mux.HandleFunc(DebugPath, s.handleDebugRequests)
+	}
+	if len(s.config.CronJobs) > 0 {
+		mux.HandleFunc(cronPrefix(s.config.Path), s.handleCron)
+	}
+	return mux
+}
 
+// Start starts the webhook server
+func (s *Server) Start(ctx context.Context) error {
 	addr := fmt.Sprintf("%s:%d", s.config.Bind, s.config.Port)
 	s.server = &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      s.newMux(),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -272,7 +509,11 @@ func (s *Server) Start(ctx context.Context) error {
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
-		s.logger.Info("webhook server starting", "addr", addr, "path", s.config.Path)
+		paths := make([]string, len(s.routes))
+		for i, rt := range s.routes {
+			paths[i] = rt.Path
+		}
+		s.logger.Info("webhook server starting", "addr", addr, "paths", paths)
 		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
 			s.logger.Error("webhook server error", "error", err)
 		}
@@ -338,9 +579,39 @@ func (s *Server) cleanupNonces() {
 
 // generateRequestID creates a unique request ID for tracking
 func generateRequestID() string {
-	b := make([]byte, 8)
-	_, _ = rand.Read(b)
-	return hex.EncodeToString(b)
+	return telemetry.NewRequestID()
+}
+
+// deliveryID identifies a webhook delivery so sender retries can be dropped.
+// A sender-supplied nonce, idempotency key or request ID is used when present;
+// otherwise the body, user and X-Timestamp are hashed.
+func deliveryID(r *http.Request, body []byte, userID string) string {
+	for _, h := range []string{"X-Nonce", "Idempotency-Key", "X-Request-ID"} {
+		if v := r.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	sum := sha256.New()
+	sum.Write(body)
+	sum.Write([]byte{0})
+	sum.Write([]byte(userID))
+	sum.Write([]byte{0})
+	sum.Write([]byte(r.Header.Get("X-Timestamp")))
+	return "sha256:" + hex.EncodeToString(sum.Sum(nil)[:16])
+}
+
+// setRateLimitHeaders reports rl's state for key in X-RateLimit-* headers
+// so clients can slow down before they are limited. When both the IP and
+// user limiters apply, the one with fewer requests left wins.
+func setRateLimitHeaders(w http.ResponseWriter, rl *rateLimiter, key string) {
+	limit, remaining, reset := rl.status(key)
+	h := w.Header()
+	if prev, err := strconv.Atoi(h.Get("X-RateLimit-Remaining")); err == nil && prev < remaining {
+		return
+	}
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
 }
 
 // setSecurityHeaders adds security headers to response
@@ -352,103 +623,119 @@ func setSecurityHeaders(w http.ResponseWriter, requestID string) {
 	w.Header().Set("Content-Security-Policy", "default-src 'none'")
 }
 
-// handleWebhook handles incoming webhook requests
+// handleWebhook handles incoming webhook requests on Config.Path
 func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	s.serveRoute(w, r, s.routes[0])
+}
+
+// serveRoute handles a webhook request using rt's auth, allowlists and sources
+func (s *Server) serveRoute(w http.ResponseWriter, r *http.Request, rt *route) {
 	requestID := generateRequestID()
 	setSecurityHeaders(w, requestID)
 	clientIP := getClientIP(r)
 
+	entry := s.requests.begin(w, r, requestID, clientIP)
+	defer entry.finish()
+	w = entry.writer(w)
+
 	// Only POST allowed
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if s.draining.Load() {
+		writeDraining(w)
 		return
 	}
 
 	// Check if IP is locked out due to auth failures
 	if s.failureTracker.isLocked(clientIP) {
 		s.logger.Warn("webhook blocked: IP locked out", "ip", clientIP, "request_id", requestID)
-		http.Error(w, "Too many failures, try again later", http.StatusTooManyRequests)
+		writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Too many failures, try again later")
 		return
 	}
 
 	// IP rate limiting
 	if s.ipLimiter != nil && !s.ipLimiter.allow(clientIP) {
+		setRateLimitHeaders(w, s.ipLimiter, clientIP)
 		s.logger.Warn("webhook rate limited by IP", "ip", clientIP, "request_id", requestID)
+		s.alerts.rateLimited("IP " + clientIP)
 		w.Header().Set("Retry-After", "60")
-		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
 		return
 	}
+	if s.ipLimiter != nil {
+		setRateLimitHeaders(w, s.ipLimiter, clientIP)
+	}
 
 	// IP whitelist check
-	if !s.checkIP(r) {
+	if !rt.checkIP(r) {
 		s.logger.Warn("webhook blocked by IP", "ip", clientIP, "request_id", requestID)
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		writeError(w, http.StatusForbidden, CodeForbiddenIP, "IP not allowed")
 		return
 	}
 
-	// Timestamp validation (replay prevention)
-	if s.config.RequireTimestamp {
-		ts := r.Header.Get("X-Timestamp")
-		if ts == "" {
-			s.logger.Warn("webhook rejected: missing timestamp", "ip", clientIP, "request_id", requestID)
-			http.Error(w, "X-Timestamp header required", http.StatusBadRequest)
-			return
-		}
-		tsInt, err := strconv.ParseInt(ts, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid timestamp", http.StatusBadRequest)
-			return
-		}
-		reqTime := time.Unix(tsInt, 0)
-		if time.Since(reqTime).Abs() > 5*time.Minute {
-			s.logger.Warn("webhook rejected: timestamp too old/future", "ip", clientIP, "request_id", requestID, "timestamp", ts)
-			http.Error(w, "Timestamp out of range", http.StatusBadRequest)
-			return
-		}
+	if !s.checkContentType(r) {
+		s.logger.Warn("webhook rejected: content type", "content_type", r.Header.Get("Content-Type"), "ip", clientIP, "request_id", requestID)
+		writeError(w, http.StatusUnsupportedMediaType, CodeUnsupportedMedia, "Unsupported media type")
+		return
 	}
 
-	// Nonce validation (replay prevention)
-	if s.config.RequireNonce {
-		nonce := r.Header.Get("X-Nonce")
-		if nonce == "" {
-			s.logger.Warn("webhook rejected: missing nonce", "ip", clientIP, "request_id", requestID)
-			http.Error(w, "X-Nonce header required", http.StatusBadRequest)
-			return
-		}
-		s.noncesMu.Lock()
-		if _, seen := s.seenNonces[nonce]; seen {
-			s.noncesMu.Unlock()
-			s.logger.Warn("webhook rejected: duplicate nonce (replay attack)", "ip", clientIP, "request_id", requestID, "nonce", nonce)
-			http.Error(w, "Duplicate nonce", http.StatusConflict)
+	nonce, ok := s.checkReplayHeaders(w, r, clientIP, requestID)
+	if !ok {
+		return
+	}
+
+	// Read body. HMAC verification needs it, so that happens before
+	// authenticating; otherwise only authenticated requests are read.
+	defer func() { _ = r.Body.Close() }()
+	var body []byte
+	var bodyRead bool
+	if rt.AuthMethod == "hmac" {
+		if body, ok = rt.readBody(w, r); !ok {
 			return
 		}
-		s.seenNonces[nonce] = time.Now()
-		s.noncesMu.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		bodyRead = true
+		entry.body(body)
 	}
 
 	// Authentication - returns user_id from token mapping
-	authUserID, ok := s.authenticate(r)
+	authUserID, ok := rt.authenticate(r)
+	entry.auth(ok, authUserID)
 	if !ok {
-		s.failureTracker.recordFailure(clientIP)
-		s.logger.Warn("webhook auth failed", "ip", clientIP, "request_id", requestID)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		count, locked := s.failureTracker.recordFailure(clientIP)
+		s.logger.Warn("webhook auth failed", "path", rt.Path, "ip", clientIP, "request_id", requestID)
+		s.alerts.authFailed(clientIP, count, locked)
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
 		return
 	}
 	// Clear failures on successful auth
 	s.failureTracker.clearFailures(clientIP)
 
-	// Read body
-	body, err := io.ReadAll(io.LimitReader(r.Body, s.config.MaxBodySize))
-	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+	if s.config.RequireNonce && !s.useNonce(nonce) {
+		s.logger.Warn("webhook rejected: duplicate nonce (replay attack)", "ip", clientIP, "request_id", requestID, "nonce", nonce)
+		writeError(w, http.StatusConflict, CodeReplay, "Duplicate nonce")
 		return
 	}
-	defer func() { _ = r.Body.Close() }()
+
+	if !bodyRead {
+		if body, ok = rt.readBody(w, r); !ok {
+			return
+		}
+		entry.body(body)
+	}
 
 	// Parse message from payload
-	text, payloadUserID := s.parsePayload(body, r)
+	text, payloadUserID, src := rt.parseBody(body, r)
+	if int64(len(body)) > rt.sourceLimit(src) {
+		s.logger.Warn("webhook rejected: body too large", "size", len(body), "ip", clientIP, "request_id", requestID)
+		writeError(w, http.StatusRequestEntityTooLarge, CodeBadPayload, "Request body too large")
+		return
+	}
 	if text == "" {
-		http.Error(w, "No message found", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, CodeBadPayload, "No message found")
 		return
 	}
 
@@ -463,29 +750,37 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 			userID = r.Header.Get("X-Webhook-Source")
 		}
 	}
+	entry.user(userID)
 	if userID == "" {
 		s.logger.Warn("webhook rejected: no user_id", "ip", clientIP, "request_id", requestID)
-		http.Error(w, "Forbidden: user_id required", http.StatusForbidden)
+		writeError(w, http.StatusForbidden, CodeForbiddenUser, "user_id required")
 		return
 	}
 
 	// User allowlist check (mandatory)
-	if !s.checkUser(userID) {
-		s.logger.Warn("webhook blocked by user allowlist", "user_id", userID, "ip", clientIP, "request_id", requestID)
-		http.Error(w, "Forbidden: user not allowed", http.StatusForbidden)
+	if !rt.checkUser(userID) {
+		s.logger.Warn("webhook blocked by user allowlist", "path", rt.Path, "user_id", userID, "ip", clientIP, "request_id", requestID)
+		writeError(w, http.StatusForbidden, CodeForbiddenUser, "User not allowed")
 		return
 	}
 
 	// User rate limiting
 	if s.userLimiter != nil && !s.userLimiter.allow(userID) {
+		setRateLimitHeaders(w, s.userLimiter, userID)
 		s.logger.Warn("webhook rate limited by user", "user_id", userID, "ip", clientIP, "request_id", requestID)
+		s.alerts.rateLimited("user " + userID)
 		w.Header().Set("Retry-After", "60")
-		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		writeError(w, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
 		return
 	}
+	if s.userLimiter != nil {
+		setRateLimitHeaders(w, s.userLimiter, userID)
+	}
 
 	// Build router message
 	msg := &router.Message{
+		ID:        deliveryID(r, body, userID),
+		RequestID: requestID,
 		Platform:  "webhook",
 		ChatID:    clientIP,
 		UserID:    userID,
@@ -498,12 +793,23 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		msg.UserID = "webhook"
 	}
 
-	s.logger.Info("webhook received", "user_id", userID, "ip", clientIP, "request_id", requestID)
+	s.logger.Info("webhook received", "path", rt.Path, "user_id", userID, "ip", clientIP, "request_id", requestID)
 
 	// Process
 	if handler := s.GetHandler(); handler != nil {
-		response, err := handler(r.Context(), msg)
+		ctx, span := telemetry.Start(r.Context(), "message",
+			attribute.String("platform", "webhook"),
+			attribute.String("webhook.path", rt.Path),
+		)
+		defer span.End()
+
+		response, err := handler(ctx, msg)
+		if errors.Is(err, router.ErrDraining) {
+			writeDraining(w)
+			return
+		}
 		if err != nil {
+			telemetry.RecordError(span, err)
 			s.logger.Warn("handler error", "error", err, "request_id", requestID)
 		}
 
@@ -523,7 +829,61 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleHealth handles health check with optional metrics
+// checkReplayHeaders validates X-Timestamp and requires X-Nonce when the
+// config asks for them, answering the request when they fail. The nonce it
+// returns is only recorded (useNonce) once the request authenticates, so
+// forged requests cannot burn nonces.
+func (s *Server) checkReplayHeaders(w http.ResponseWriter, r *http.Request, clientIP, requestID string) (nonce string, ok bool) {
+	if s.config.RequireTimestamp {
+		ts := r.Header.Get("X-Timestamp")
+		if ts == "" {
+			s.logger.Warn("webhook rejected: missing timestamp", "ip", clientIP, "request_id", requestID)
+			writeError(w, http.StatusBadRequest, CodeReplay, "X-Timestamp header required")
+			return "", false
+		}
+		tsInt, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, CodeReplay, "Invalid timestamp")
+			return "", false
+		}
+		reqTime := time.Unix(tsInt, 0)
+		if time.Since(reqTime).Abs() > 5*time.Minute {
+			s.logger.Warn("webhook rejected: timestamp too old/future", "ip", clientIP, "request_id", requestID, "timestamp", ts)
+			writeError(w, http.StatusBadRequest, CodeReplay, "Timestamp out of range")
+			return "", false
+		}
+	}
+
+	nonce = r.Header.Get("X-Nonce")
+	if s.config.RequireNonce && nonce == "" {
+		s.logger.Warn("webhook rejected: missing nonce", "ip", clientIP, "request_id", requestID)
+		writeError(w, http.StatusBadRequest, CodeReplay, "X-Nonce header required")
+		return "", false
+	}
+	return nonce, true
+}
+
+// Drain makes the server answer new webhooks with 503 while the router
+// finishes in-flight messages.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// SetReady marks the server ready to process webhooks, once its handler is
+// set and the router has started. Until then /health/ready answers 503 so
+// load balancers hold traffic back.
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// writeDraining rejects a request during shutdown.
+func writeDraining(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "10")
+	writeError(w, http.StatusServiceUnavailable, CodeUnavailable, "Shutting down, try again shortly")
+}
+
+// handleHealth is the liveness probe: OK whenever the server is serving,
+// with optional metrics
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Cache-Control", "no-store")
@@ -549,12 +909,42 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("OK"))
 }
 
+// handleReady reports readiness: 200 once the server is ready (see
+// SetReady) and the Ready check passes, 503 with the reason until then.
+func (s *Server) handleReady(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if s.draining.Load() {
+		http.Error(w, "Not ready: shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if !s.ready.Load() || s.GetHandler() == nil {
+		http.Error(w, "Not ready: starting", http.StatusServiceUnavailable)
+		return
+	}
+	if s.config.Ready != nil {
+		if err := s.config.Ready(); err != nil {
+			http.Error(w, "Not ready: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK"))
+}
+
+// authenticate authenticates a request to the Config.Path route.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	return s.routes[0].authenticate(r)
+}
+
 // authenticate verifies the request and returns the user_id from token mapping.
 // Returns (userID, true) on success, ("", false) on failure.
 // If using token-to-user mapping, the token determines the user identity (secure).
 // If using legacy single token, returns ("", true) and user_id comes from payload (less secure).
-func (s *Server) authenticate(r *http.Request) (string, bool) {
-	switch s.config.AuthMethod {
+func (rt *route) authenticate(r *http.Request) (string, bool) {
+	switch rt.AuthMethod {
 	case "none", "":
 		return "", true
 
@@ -565,9 +955,13 @@ func (s *Server) authenticate(r *http.Request) (string, bool) {
 		}
 		token := strings.TrimPrefix(auth, "Bearer ")
 
+		rt.authMu.RLock()
+		tokens := rt.BearerTokens
+		rt.authMu.RUnlock()
+
 		// Check token-to-user mapping (secure: token IS the identity)
-		if len(s.config.BearerTokens) > 0 {
-			for t, userID := range s.config.BearerTokens {
+		if len(tokens) > 0 {
+			for t, userID := range tokens {
 				if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
 					return userID, true
 				}
@@ -575,8 +969,9 @@ func (s *Server) authenticate(r *http.Request) (string, bool) {
 			return "", false
 		}
 
-		// Legacy: single token (user_id from payload)
-		if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.BearerToken)) == 1 {
+		// Legacy: single token (user_id from payload). Empty never matches,
+		// which matters once the last mapped token has been revoked.
+		if rt.BearerToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(rt.BearerToken)) == 1 {
 			return "", true
 		}
 		return "", false
@@ -586,51 +981,41 @@ func (s *Server) authenticate(r *http.Request) (string, bool) {
 		if !ok {
 			return "", false
 		}
-		if subtle.ConstantTimeCompare([]byte(user), []byte(s.config.BasicUser)) == 1 &&
-			subtle.ConstantTimeCompare([]byte(pass), []byte(s.config.BasicPass)) == 1 {
+		if subtle.ConstantTimeCompare([]byte(user), []byte(rt.BasicUser)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(pass), []byte(rt.BasicPass)) == 1 {
 			return user, true // username is the user_id
 		}
 		return "", false
 
 	case "hmac":
-		// Check X-Hub-Signature-256 (GitHub style)
-		sig := r.Header.Get("X-Hub-Signature-256")
-		if sig == "" {
-			sig = r.Header.Get("X-Signature")
+		// At least one configured algorithm must have sent a signature
+		signedWith := false
+		for _, alg := range rt.algorithms {
+			if signatureHeader(r.Header, alg) != "" {
+				signedWith = true
+				break
+			}
 		}
-		if sig == "" {
+		if !signedWith {
 			return "", false
 		}
 
-		// Read body for signature verification
-		body, err := io.ReadAll(io.LimitReader(r.Body, s.config.MaxBodySize))
+		// Read body for signature verification (handleWebhook bounds it)
+		body, err := io.ReadAll(io.LimitReader(r.Body, rt.maxBodyLimit()))
 		if err != nil {
 			return "", false
 		}
 		r.Body = io.NopCloser(bytes.NewReader(body))
 
-		// Check HMAC-to-user mapping
-		if len(s.config.HMACUsers) > 0 {
-			for secret, userID := range s.config.HMACUsers {
-				mac := hmac.New(sha256.New, []byte(secret))
-				mac.Write(body)
-				expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
-				if subtle.ConstantTimeCompare([]byte(sig), []byte(expected)) == 1 {
-					return userID, true
-				}
-			}
-			return "", false
+		signed := body
+		if rt.strict {
+			signed = signedPayload(r.Header.Get("X-Timestamp"), r.Header.Get("X-Nonce"), body)
 		}
 
-		// Legacy: single secret
-		if s.config.HMACSecret == "" {
-			return "", false
-		}
-		mac := hmac.New(sha256.New, []byte(s.config.HMACSecret))
-		mac.Write(body)
-		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
-		if subtle.ConstantTimeCompare([]byte(sig), []byte(expected)) == 1 {
-			return "", true
+		for _, alg := range rt.algorithms {
+			if userID, ok := rt.verifySignature(alg, r.Header, signed); ok {
+				return userID, true
+			}
 		}
 		return "", false
 	}
@@ -638,9 +1023,42 @@ func (s *Server) authenticate(r *http.Request) (string, bool) {
 	return "", false
 }
 
-// checkIP checks if the client IP is allowed
+// signedPayload returns the bytes signed under the strict profile:
+//
+//	X-Timestamp + "." + X-Nonce + "." + body
+//
+// The header values are used exactly as sent (the timestamp as decimal Unix
+// seconds), and body is the raw request body. The signature header carries
+// "sha256=" + hex(HMAC-SHA256(secret, payload)), so changing the timestamp
+// or nonce of a captured request invalidates its signature.
+func signedPayload(timestamp, nonce string, body []byte) []byte {
+	payload := make([]byte, 0, len(timestamp)+len(nonce)+len(body)+2)
+	payload = append(payload, timestamp...)
+	payload = append(payload, '.')
+	payload = append(payload, nonce...)
+	payload = append(payload, '.')
+	return append(payload, body...)
+}
+
+// useNonce records nonce and reports false if it was already seen.
+func (s *Server) useNonce(nonce string) bool {
+	s.noncesMu.Lock()
+	defer s.noncesMu.Unlock()
+	if _, seen := s.seenNonces[nonce]; seen {
+		return false
+	}
+	s.seenNonces[nonce] = time.Now()
+	return true
+}
+
+// checkIP checks the client IP against the Config.Path route's allowlist.
 func (s *Server) checkIP(r *http.Request) bool {
-	if len(s.config.AllowedIPs) == 0 {
+	return s.routes[0].checkIP(r)
+}
+
+// checkIP checks if the client IP is allowed
+func (rt *route) checkIP(r *http.Request) bool {
+	if len(rt.AllowedIPs) == 0 {
 		return true
 	}
 
@@ -649,7 +1067,7 @@ func (s *Server) checkIP(r *http.Request) bool {
 		return false
 	}
 
-	for _, allowed := range s.config.AllowedIPs {
+	for _, allowed := range rt.AllowedIPs {
 		if strings.Contains(allowed, "/") {
 			_, cidr, err := net.ParseCIDR(allowed)
 			if err == nil && cidr.Contains(clientIP) {
@@ -665,80 +1083,161 @@ func (s *Server) checkIP(r *http.Request) bool {
 	return false
 }
 
-// checkUser checks if the user ID is allowed
-func (s *Server) checkUser(userID string) bool {
-	if len(s.config.AllowedUsers) == 0 {
-		return true // No allowlist = allow all
+// checkContentType checks the request media type against RequireContentType
+func (s *Server) checkContentType(r *http.Request) bool {
+	if len(s.config.RequireContentType) == 0 {
+		return true
 	}
-
-	for _, allowed := range s.config.AllowedUsers {
-		if allowed == userID {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, ct := range s.config.RequireContentType {
+		if strings.EqualFold(mediaType, ct) {
 			return true
 		}
-		// Support wildcards: "telegram:*" matches any telegram user
-		if strings.HasSuffix(allowed, ":*") {
-			prefix := strings.TrimSuffix(allowed, "*")
-			if strings.HasPrefix(userID, prefix) {
-				return true
-			}
+	}
+	return false
+}
+
+// readBody reads the request body up to the applicable size limit,
+// answering 413 when it is exceeded.
+func (rt *route) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, rt.readLimit(r)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			rt.logger.Warn("webhook rejected: body too large", "limit", tooLarge.Limit, "ip", getClientIP(r))
+			writeError(w, http.StatusRequestEntityTooLarge, CodeBadPayload, "Request body too large")
+		} else {
+			writeError(w, http.StatusBadRequest, CodeBadPayload, "Bad request")
 		}
+		return nil, false
 	}
+	return body, true
+}
 
-	return false
+// checkUser checks the user ID against the Config.Path route's allowlist.
+func (s *Server) checkUser(userID string) bool {
+	return s.routes[0].checkUser(userID)
+}
+
+// checkUser checks if the user ID is allowed
+func (rt *route) checkUser(userID string) bool {
+	rt.authMu.RLock()
+	allowed := rt.AllowedUsers
+	rt.authMu.RUnlock()
+
+	if len(allowed) == 0 {
+		return true // No allowlist = allow all
+	}
+
+	// Glob patterns: "telegram:*", "*@example.com", "github:org/*", "*"
+	return util.ContainsMatch(allowed, userID)
+}
+
+// SetBearerTokens replaces the Config.Path route's token-to-user mapping
+// and user allowlist, so tokens can be added or revoked without a restart.
+// Requests already past authentication are not affected.
+func (s *Server) SetBearerTokens(tokens map[string]string, allowedUsers []string) {
+	rt := s.routes[0]
+	rt.authMu.Lock()
+	rt.BearerTokens = tokens
+	rt.AllowedUsers = allowedUsers
+	rt.authMu.Unlock()
+	s.logger.Info("webhook bearer tokens reloaded", "tokens", len(tokens), "allowed_users", len(allowedUsers))
 }
 
-// parsePayload extracts message and user ID from payload
+// parsePayload extracts message and user ID from payload. Configured
+// sources are consulted first, then the transform, then the built-in
+// heuristics.
 func (s *Server) parsePayload(body []byte, r *http.Request) (text string, userID string) {
-	// Try JSON
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err == nil {
-		// Extract user ID from common fields
-		for _, field := range []string{"user_id", "userId", "user", "sender", "from"} {
-			if v, ok := data[field].(string); ok && v != "" {
-				userID = v
-				break
+	text, userID, _ = s.routes[0].parseBody(body, r)
+	return text, userID
+}
+
+// parseBody is parsePayload that also returns the configured source the
+// payload matched, or nil.
+func (rt *route) parseBody(body []byte, r *http.Request) (text, userID string, matched *source) {
+	// Try JSON: any value for sources and the transform, objects only for
+	// the heuristics
+	var payload any
+	if err := json.Unmarshal(body, &payload); err == nil {
+		for i := range rt.sources {
+			src := &rt.sources[i]
+			if !src.matches(r, payload) {
+				continue
 			}
+			if matched == nil {
+				matched = src
+			}
+			if text, userID = src.extract(payload); text != "" {
+				return text, userID, src
+			}
+			rt.logger.Debug("webhook source matched without text", "source", src.cfg.Name)
 		}
-
-		// Common message fields
-		for _, field := range []string{"message", "text", "content", "body", "msg"} {
-			if v, ok := data[field].(string); ok && v != "" {
-				text = v
-				break
+		if rt.transform != nil {
+			text, userID, err := rt.transform.apply(r.Context(), payload)
+			if err != nil {
+				rt.logger.Warn("webhook transform failed", "path", rt.Path, "error", err)
+			} else if text != "" {
+				return text, userID, matched
 			}
 		}
-		if text != "" {
-			return text, userID
+		if data, ok := payload.(map[string]interface{}); ok {
+			if text, userID, ok := parseKnownPayload(data); ok {
+				return text, userID, matched
+			}
 		}
+	}
 
-		// GitHub webhook
-		if commits, ok := data["commits"].([]interface{}); ok && len(commits) > 0 {
-			if commit, ok := commits[0].(map[string]interface{}); ok {
-				if msg, ok := commit["message"].(string); ok {
-					text = fmt.Sprintf("GitHub push: %s", msg)
-				}
+	// Fallback: raw body as text
+	return string(body), "", matched
+}
+
+// parseKnownPayload extracts message and user ID using common field names
+// and the GitHub and Grafana payload shapes. ok is false when the payload
+// is not recognized.
+func parseKnownPayload(data map[string]interface{}) (text, userID string, ok bool) {
+	// Extract user ID from common fields
+	for _, field := range []string{"user_id", "userId", "user", "sender", "from"} {
+		if v, ok := data[field].(string); ok && v != "" {
+			userID = v
+			break
+		}
+	}
+
+	// Common message fields
+	for _, field := range []string{"message", "text", "content", "body", "msg"} {
+		if v, ok := data[field].(string); ok && v != "" {
+			return v, userID, true
+		}
+	}
+
+	// GitHub webhook
+	if commits, ok := data["commits"].([]interface{}); ok && len(commits) > 0 {
+		if commit, ok := commits[0].(map[string]interface{}); ok {
+			if msg, ok := commit["message"].(string); ok {
+				text = fmt.Sprintf("GitHub push: %s", msg)
 			}
-			// GitHub sender
-			if sender, ok := data["sender"].(map[string]interface{}); ok {
-				if login, ok := sender["login"].(string); ok {
-					userID = "github:" + login
-				}
+		}
+		// GitHub sender
+		if sender, ok := data["sender"].(map[string]interface{}); ok {
+			if login, ok := sender["login"].(string); ok {
+				userID = "github:" + login
 			}
-			return text, userID
 		}
+		return text, userID, true
+	}
 
-		// Grafana alert
-		if title, ok := data["title"].(string); ok {
-			if state, ok := data["state"].(string); ok {
-				text = fmt.Sprintf("Grafana [%s]: %s", state, title)
-				userID = "grafana"
-				return text, userID
-			}
+	// Grafana alert
+	if title, ok := data["title"].(string); ok {
+		if state, ok := data["state"].(string); ok {
+			return fmt.Sprintf("Grafana [%s]: %s", state, title), "grafana", true
 		}
 	}
 
-	// Fallback: raw body as text
-	return string(body), ""
+	return "", "", false
 }
 
 // getClientIP returns the direct TCP peer address for security checks.
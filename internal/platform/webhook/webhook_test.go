@@ -3,8 +3,11 @@ package webhook
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"crypto/hmac"
+	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -12,8 +15,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/kusa/magabot/internal/router"
 )
@@ -87,9 +93,7 @@ func TestHandleWebhook(t *testing.T) {
 		rec := httptest.NewRecorder()
 		s.handleWebhook(rec, req)
 
-		if rec.Code != http.StatusMethodNotAllowed {
-			t.Errorf("Expected 405, got %d", rec.Code)
-		}
+		checkError(t, rec, http.StatusMethodNotAllowed, CodeMethodNotAllowed)
 	})
 
 	t.Run("ValidPost", func(t *testing.T) {
@@ -128,6 +132,68 @@ func TestHandleWebhook(t *testing.T) {
 	})
 }
 
+func TestHandleWebhookLimits(t *testing.T) {
+	s := newTestServer(&Config{
+		AuthMethod:         "none",
+		AllowedUsers:       []string{"testuser"},
+		MaxBodySize:        64,
+		RequireContentType: []string{"application/json"},
+		Sources: []SourceConfig{
+			{Name: "camera", Header: "X-Event-Source", Match: "camera", TextPath: "$.message", UserPath: "$.user_id", MaxBodySize: 1024},
+			{Name: "ping", Field: "$.kind", Match: "ping", TextPath: "$.message", MaxBodySize: 40},
+		},
+	})
+	post := func(body, contentType, source string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", contentType)
+		if source != "" {
+			req.Header.Set("X-Event-Source", source)
+		}
+		req.RemoteAddr = "127.0.0.1:12345"
+		rec := httptest.NewRecorder()
+		s.handleWebhook(rec, req)
+		return rec.Code
+	}
+	large := `{"message": "` + strings.Repeat("x", 200) + `", "user_id": "testuser"}`
+
+	t.Run("WithinLimit", func(t *testing.T) {
+		if code := post(`{"message": "hi", "user_id": "testuser"}`, "application/json; charset=utf-8", ""); code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", code)
+		}
+	})
+
+	t.Run("Oversized", func(t *testing.T) {
+		if code := post(large, "application/json", ""); code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected 413, got %d", code)
+		}
+	})
+
+	t.Run("SourceAllowsLarger", func(t *testing.T) {
+		if code := post(large, "application/json", "camera"); code != http.StatusOK {
+			t.Errorf("Expected 200 under the source limit, got %d", code)
+		}
+	})
+
+	t.Run("FieldSourceSmaller", func(t *testing.T) {
+		body := `{"kind": "ping", "message": "pong pong pong", "user_id": "testuser"}`
+		if code := post(body, "application/json", ""); code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected 413 over the source limit, got %d", code)
+		}
+	})
+
+	t.Run("WrongContentType", func(t *testing.T) {
+		if code := post("hello", "text/plain", ""); code != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected 415, got %d", code)
+		}
+	})
+
+	t.Run("MissingContentType", func(t *testing.T) {
+		if code := post(`{"message": "hi"}`, "", ""); code != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected 415, got %d", code)
+		}
+	})
+}
+
 func TestAuthenticateBearerToken(t *testing.T) {
 	s := newTestServer(&Config{
 		AuthMethod:  "bearer",
@@ -411,6 +477,165 @@ func TestParsePayload(t *testing.T) {
 	})
 }
 
+func TestParsePayloadSources(t *testing.T) {
+	s := newTestServer(&Config{Sources: []SourceConfig{
+		{Name: "jenkins", Header: "X-Event-Source", Match: "jenkins", TextPath: "$.build.log", UserPath: "$.build['triggered-by']"},
+		{Name: "alerts", Field: "$.kind", Match: "alert", TextPath: "$.alerts[0].summary", UserPath: "$.team_id"},
+	}})
+
+	t.Run("HeaderMatch", func(t *testing.T) {
+		body := []byte(`{"message": "ignored", "build": {"log": "build #12 failed", "triggered-by": "jenkins:ci"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Event-Source", "jenkins")
+		text, userID := s.parsePayload(body, req)
+		if text != "build #12 failed" || userID != "jenkins:ci" {
+			t.Errorf("Expected jenkins schema, got %q / %q", text, userID)
+		}
+	})
+
+	t.Run("FieldMatch", func(t *testing.T) {
+		body := []byte(`{"kind": "alert", "team_id": 42, "alerts": [{"summary": "disk full"}]}`)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		text, userID := s.parsePayload(body, req)
+		if text != "disk full" || userID != "42" {
+			t.Errorf("Expected alerts schema, got %q / %q", text, userID)
+		}
+	})
+
+	t.Run("NoMatchFallsBack", func(t *testing.T) {
+		body := []byte(`{"message": "hello", "user_id": "u1", "build": {"log": "x"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		text, userID := s.parsePayload(body, req)
+		if text != "hello" || userID != "u1" {
+			t.Errorf("Expected heuristics, got %q / %q", text, userID)
+		}
+	})
+
+	t.Run("MissingTextFallsBack", func(t *testing.T) {
+		body := []byte(`{"text": "plain"}`)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Event-Source", "jenkins")
+		text, _ := s.parsePayload(body, req)
+		if text != "plain" {
+			t.Errorf("Expected heuristics when the schema path is missing, got %q", text)
+		}
+	})
+}
+
+func TestNewInvalidSource(t *testing.T) {
+	tests := map[string]SourceConfig{
+		"no matcher":   {Name: "x", TextPath: "$.text"},
+		"no text path": {Name: "x", Header: "X-Source"},
+		"no dollar":    {Name: "x", Header: "X-Source", TextPath: "text"},
+		"bad index":    {Name: "x", Header: "X-Source", TextPath: "$.items[first]"},
+		"unclosed":     {Name: "x", Header: "X-Source", TextPath: "$.items[0"},
+		"empty key":    {Name: "x", Field: "$..kind", TextPath: "$.text"},
+	}
+	for name, src := range tests {
+		if _, err := New(&Config{Sources: []SourceConfig{src}}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParsePayloadTransform(t *testing.T) {
+	s := newTestServer(&Config{
+		Sources:   []SourceConfig{{Name: "jenkins", Header: "X-Event-Source", Match: "jenkins", TextPath: "$.build.log"}},
+		Transform: `{text: .issue.title, user_id: .sender.login}`,
+	})
+
+	t.Run("Object", func(t *testing.T) {
+		body := []byte(`{"message": "ignored", "issue": {"title": "Crash on start"}, "sender": {"login": "octocat"}}`)
+		text, userID := s.parsePayload(body, httptest.NewRequest(http.MethodPost, "/", nil))
+		if text != "Crash on start" || userID != "octocat" {
+			t.Errorf("Expected transform result, got %q / %q", text, userID)
+		}
+	})
+
+	t.Run("SourceFirst", func(t *testing.T) {
+		body := []byte(`{"build": {"log": "build #3 failed"}, "issue": {"title": "x"}}`)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Event-Source", "jenkins")
+		if text, _ := s.parsePayload(body, req); text != "build #3 failed" {
+			t.Errorf("Expected the matching source to win, got %q", text)
+		}
+	})
+
+	t.Run("NoTextFallsBack", func(t *testing.T) {
+		body := []byte(`{"message": "hello", "user_id": "u1"}`)
+		text, userID := s.parsePayload(body, httptest.NewRequest(http.MethodPost, "/", nil))
+		if text != "hello" || userID != "u1" {
+			t.Errorf("Expected heuristics, got %q / %q", text, userID)
+		}
+	})
+
+	t.Run("Scalar", func(t *testing.T) {
+		s := newTestServer(&Config{Transform: ".commits[0].message"})
+		body := []byte(`{"commits": [{"message": "fix build"}], "text": "ignored"}`)
+		if text, _ := s.parsePayload(body, httptest.NewRequest(http.MethodPost, "/", nil)); text != "fix build" {
+			t.Errorf("Expected scalar transform result, got %q", text)
+		}
+	})
+
+	t.Run("TopLevelArray", func(t *testing.T) {
+		s := newTestServer(&Config{Transform: `map(.summary) | join("; ")`})
+		body := []byte(`[{"summary": "disk full"}, {"summary": "cpu hot"}]`)
+		if text, _ := s.parsePayload(body, httptest.NewRequest(http.MethodPost, "/", nil)); text != "disk full; cpu hot" {
+			t.Errorf("Expected the array to be transformed, got %q", text)
+		}
+	})
+
+	t.Run("NoEnvironment", func(t *testing.T) {
+		t.Setenv("MAGABOT_TEST_SECRET", "hunter2")
+		s := newTestServer(&Config{Transform: `{text: ("env:" + ($ENV.MAGABOT_TEST_SECRET // "none"))}`})
+		if text, _ := s.parsePayload([]byte(`{}`), httptest.NewRequest(http.MethodPost, "/", nil)); text != "env:none" {
+			t.Errorf("Expected no environment, got %q", text)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		s := newTestServer(&Config{Transform: `{text: (last(range(1e12)) | tostring)}`})
+		start := time.Now()
+		text, _ := s.parsePayload([]byte(`{"message": "fallback"}`), httptest.NewRequest(http.MethodPost, "/", nil))
+		if text != "fallback" {
+			t.Errorf("Expected the heuristics after a timeout, got %q", text)
+		}
+		if elapsed := time.Since(start); elapsed > 10*transformTimeout {
+			t.Errorf("Evaluation ran for %s after the timeout", elapsed)
+		}
+	})
+
+	t.Run("OutputBounded", func(t *testing.T) {
+		s := newTestServer(&Config{Transform: ".log"})
+		body := []byte(`{"log": "` + strings.Repeat("é", maxTransformOutput) + `"}`)
+		text, _ := s.parsePayload(body, httptest.NewRequest(http.MethodPost, "/", nil))
+		if len(text) > maxTransformOutput || !utf8.ValidString(text) {
+			t.Errorf("Expected at most %d bytes of valid UTF-8, got %d", maxTransformOutput, len(text))
+		}
+	})
+}
+
+func TestNewInvalidTransform(t *testing.T) {
+	tests := map[string]string{
+		"unclosed object":  `{text: .issue.title`,
+		"unbalanced":       `.issue.title)`,
+		"unclosed quote":   `{"text: .issue.title}`,
+		"unknown function": `nope(.x)`,
+		"too long":         strings.Repeat(".a", maxTransformLen),
+	}
+	for name, expr := range tests {
+		if _, err := New(&Config{Transform: expr}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+		if _, err := New(&Config{Routes: []Route{{Path: "/webhook/x", Transform: expr}}}); err == nil {
+			t.Errorf("%s: expected error on route", name)
+		}
+	}
+	if _, err := New(&Config{Transform: `{text: (.commits | reverse | .[0].message)}`}); err != nil {
+		t.Errorf("Valid expression rejected: %v", err)
+	}
+}
+
 func TestCheckUser(t *testing.T) {
 	t.Run("EmptyAllowlist", func(t *testing.T) {
 		s := newTestServer(&Config{})
@@ -442,6 +667,48 @@ func TestCheckUser(t *testing.T) {
 		}
 	})
 
+	t.Run("WildcardSuffix", func(t *testing.T) {
+		s := newTestServer(&Config{AllowedUsers: []string{"*@example.com"}})
+		if !s.checkUser("alice@example.com") {
+			t.Error("Should allow alice@example.com with *@example.com wildcard")
+		}
+		if s.checkUser("alice@example.org") {
+			t.Error("Should block alice@example.org with *@example.com wildcard")
+		}
+	})
+
+	t.Run("WildcardMiddle", func(t *testing.T) {
+		s := newTestServer(&Config{AllowedUsers: []string{"github:org/*/bot"}})
+		if !s.checkUser("github:org/ci/bot") {
+			t.Error("Should allow github:org/ci/bot with github:org/*/bot wildcard")
+		}
+		if s.checkUser("github:org/ci/human") {
+			t.Error("Should block github:org/ci/human with github:org/*/bot wildcard")
+		}
+		if !s.checkUser("github:org/a/b/bot") {
+			t.Error("Wildcard should match across '/'")
+		}
+	})
+
+	t.Run("WildcardAll", func(t *testing.T) {
+		s := newTestServer(&Config{AllowedUsers: []string{"*"}})
+		for _, id := range []string{"user1", "telegram:12345", "github:org/team/alice"} {
+			if !s.checkUser(id) {
+				t.Errorf("Bare * should allow %s", id)
+			}
+		}
+	})
+
+	t.Run("MalformedPattern", func(t *testing.T) {
+		s := newTestServer(&Config{AllowedUsers: []string{"user[1"}})
+		if s.checkUser("user1") {
+			t.Error("Malformed pattern should match nothing")
+		}
+		if !s.checkUser("user[1") {
+			t.Error("Exact match should still apply to a malformed pattern")
+		}
+	})
+
 	t.Run("MultipleAllowed", func(t *testing.T) {
 		s := newTestServer(&Config{AllowedUsers: []string{"user1", "telegram:*", "github:octocat"}})
 		if !s.checkUser("user1") {
@@ -459,6 +726,78 @@ func TestCheckUser(t *testing.T) {
 	})
 }
 
+func TestHandleReady(t *testing.T) {
+	noop := func(ctx context.Context, msg *router.Message) (string, error) { return "", nil }
+
+	t.Run("NoCheck", func(t *testing.T) {
+		s := newTestServer(&Config{})
+		s.SetHandler(noop)
+		s.SetReady(true)
+		rec := httptest.NewRecorder()
+		s.handleReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Starting", func(t *testing.T) {
+		s := newTestServer(&Config{})
+		req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+
+		rec := httptest.NewRecorder()
+		s.handleReady(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 before wiring, got %d", rec.Code)
+		}
+
+		s.SetReady(true)
+		rec = httptest.NewRecorder()
+		s.handleReady(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 without a handler, got %d", rec.Code)
+		}
+
+		s.SetHandler(noop)
+		rec = httptest.NewRecorder()
+		s.handleReady(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected 200 once ready, got %d", rec.Code)
+		}
+
+		// Liveness doesn't wait for wiring
+		s = newTestServer(&Config{})
+		rec = httptest.NewRecorder()
+		s.handleHealth(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected liveness 200 while starting, got %d", rec.Code)
+		}
+	})
+
+	t.Run("NotReady", func(t *testing.T) {
+		ready := fmt.Errorf("platforms not connected: slack")
+		s := newTestServer(&Config{Ready: func() error { return ready }})
+		s.SetHandler(noop)
+		s.SetReady(true)
+		req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+
+		rec := httptest.NewRecorder()
+		s.handleReady(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503, got %d", rec.Code)
+		}
+		if !bytes.Contains(rec.Body.Bytes(), []byte("slack")) {
+			t.Errorf("Expected reason in body, got %s", rec.Body.String())
+		}
+
+		ready = nil
+		rec = httptest.NewRecorder()
+		s.handleReady(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected 200 once ready, got %d", rec.Code)
+		}
+	})
+}
+
 func TestHandleHealth(t *testing.T) {
 	s := newTestServer(&Config{})
 
@@ -595,9 +934,7 @@ func TestWebhookRejectsEmptyUserID(t *testing.T) {
 
 	s.handleWebhook(rec, req)
 
-	if rec.Code != http.StatusForbidden {
-		t.Errorf("Expected 403 for missing user_id, got %d", rec.Code)
-	}
+	checkError(t, rec, http.StatusForbidden, CodeForbiddenUser)
 }
 
 func TestWebhookRejectsUnauthorizedUser(t *testing.T) {
@@ -613,9 +950,7 @@ func TestWebhookRejectsUnauthorizedUser(t *testing.T) {
 
 	s.handleWebhook(rec, req)
 
-	if rec.Code != http.StatusForbidden {
-		t.Errorf("Expected 403 for unauthorized user, got %d", rec.Code)
-	}
+	checkError(t, rec, http.StatusForbidden, CodeForbiddenUser)
 }
 
 func TestBearerTokensMapping(t *testing.T) {
@@ -659,12 +994,38 @@ func TestBearerTokensMapping(t *testing.T) {
 
 		s.handleWebhook(rec, req)
 
-		if rec.Code != http.StatusUnauthorized {
-			t.Errorf("Expected 401, got %d", rec.Code)
-		}
+		checkError(t, rec, http.StatusUnauthorized, CodeUnauthorized)
 	})
 }
 
+func TestSetBearerTokens(t *testing.T) {
+	s := newTestServer(&Config{
+		AuthMethod:   "bearer",
+		BearerTokens: map[string]string{"token-for-alice": "alice"},
+		AllowedUsers: []string{"alice"},
+	})
+
+	authAs := func(token string) (string, bool) {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		userID, ok := s.authenticate(req)
+		return userID, ok && s.checkUser(userID)
+	}
+
+	s.SetBearerTokens(map[string]string{"token-for-bob": "bob"}, []string{"bob"})
+	if _, ok := authAs("token-for-alice"); ok {
+		t.Error("revoked token should be rejected")
+	}
+	if userID, ok := authAs("token-for-bob"); !ok || userID != "bob" {
+		t.Errorf("new token: got (%q, %v), want (bob, true)", userID, ok)
+	}
+
+	s.SetBearerTokens(nil, []string{"bob"})
+	if _, ok := authAs(""); ok {
+		t.Error("empty token should be rejected once every token is revoked")
+	}
+}
+
 // ==== Security Features Tests ====
 
 func TestRateLimiter(t *testing.T) {
@@ -736,6 +1097,118 @@ func TestFailureTracker(t *testing.T) {
 	}
 }
 
+func TestAlerts(t *testing.T) {
+	receive := func(t *testing.T, alerts chan string) string {
+		t.Helper()
+		select {
+		case text := <-alerts:
+			return text
+		case <-time.After(2 * time.Second):
+			t.Fatal("no alert sent")
+			return ""
+		}
+	}
+	expectNone := func(t *testing.T, alerts chan string) {
+		t.Helper()
+		select {
+		case text := <-alerts:
+			t.Errorf("Unexpected alert: %q", text)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	post := func(s *Server, ip, token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"message": "test"}`))
+		req.RemoteAddr = ip + ":12345"
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.handleWebhook(rec, req)
+		return rec.Code
+	}
+
+	t.Run("AuthFailures", func(t *testing.T) {
+		alerts := make(chan string, 10)
+		s := newTestServer(&Config{
+			AuthMethod:      "bearer",
+			BearerTokens:    map[string]string{"token-for-alice": "alice"},
+			AllowedUsers:    []string{"alice"},
+			MaxAuthFailures: 3,
+			Alerts:          AlertConfig{Notify: func(text string) { alerts <- text }},
+		})
+
+		for i := 0; i < 2; i++ {
+			post(s, "10.0.0.1", "wrong-token")
+		}
+		expectNone(t, alerts)
+
+		post(s, "10.0.0.1", "wrong-token")
+		if text := receive(t, alerts); !strings.Contains(text, "10.0.0.1") || !strings.Contains(text, "locked out") {
+			t.Errorf("Unexpected alert: %q", text)
+		}
+
+		// A second IP within the cooldown is held back
+		for i := 0; i < 3; i++ {
+			post(s, "10.0.0.2", "wrong-token")
+		}
+		expectNone(t, alerts)
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		alerts := make(chan string, 10)
+		s := newTestServer(&Config{
+			AuthMethod:     "bearer",
+			BearerTokens:   map[string]string{"token-for-alice": "alice"},
+			AllowedUsers:   []string{"alice"},
+			RateLimitPerIP: 1,
+			Alerts:         AlertConfig{Notify: func(text string) { alerts <- text }, RateLimited: 3},
+		})
+
+		for i := 0; i < 3; i++ {
+			post(s, "10.0.0.3", "token-for-alice")
+		}
+		expectNone(t, alerts)
+
+		if code := post(s, "10.0.0.3", "token-for-alice"); code != http.StatusTooManyRequests {
+			t.Fatalf("Expected 429, got %d", code)
+		}
+		if text := receive(t, alerts); !strings.Contains(text, "IP 10.0.0.3") {
+			t.Errorf("Unexpected alert: %q", text)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		s := newTestServer(&Config{AuthMethod: "bearer", BearerToken: "token", MaxAuthFailures: 1})
+		if s.alerts != nil {
+			t.Fatal("Expected no alerter without Notify")
+		}
+		post(s, "10.0.0.4", "wrong-token") // must not panic
+	})
+}
+
+func TestAlerterCooldown(t *testing.T) {
+	alerts := make(chan string, 10)
+	a := newAlerter(AlertConfig{Notify: func(text string) { alerts <- text }, Cooldown: 50 * time.Millisecond}, 5, time.Minute)
+
+	a.authFailed("10.0.0.1", 5, true)
+	<-alerts
+	a.authFailed("10.0.0.2", 5, true)
+	a.authFailed("10.0.0.3", 5, true)
+	a.authFailed("10.0.0.4", 4, false) // below the threshold
+
+	time.Sleep(60 * time.Millisecond)
+	a.authFailed("10.0.0.5", 5, true)
+	select {
+	case text := <-alerts:
+		if !strings.Contains(text, "10.0.0.5") || !strings.Contains(text, "2 similar alert(s)") {
+			t.Errorf("Expected the held-back count in the alert, got %q", text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert after the cooldown")
+	}
+	if len(alerts) != 0 {
+		t.Errorf("Expected one alert after the cooldown, got %d more", len(alerts))
+	}
+}
+
 // ==== Integration Tests: Rate Limiting ====
 
 func TestIPRateLimitingIntegration(t *testing.T) {
@@ -766,9 +1239,7 @@ func TestIPRateLimitingIntegration(t *testing.T) {
 
 		// 4th request should be rate limited
 		rec := makeRequest("192.168.1.100")
-		if rec.Code != http.StatusTooManyRequests {
-			t.Errorf("Request 4 should be rate limited, got %d", rec.Code)
-		}
+		checkError(t, rec, http.StatusTooManyRequests, CodeRateLimited)
 
 		// Should have Retry-After header
 		if rec.Header().Get("Retry-After") == "" {
@@ -898,9 +1369,7 @@ func TestUserRateLimitingIntegration(t *testing.T) {
 
 		// User1: 4th blocked even from new IP
 		rec := makeRequest("user1", "10.0.0.99")
-		if rec.Code != http.StatusTooManyRequests {
-			t.Errorf("User1 request 4 should be rate limited, got %d", rec.Code)
-		}
+		checkError(t, rec, http.StatusTooManyRequests, CodeRateLimited)
 		if rec.Header().Get("Retry-After") == "" {
 			t.Error("Rate limited response should have Retry-After header")
 		}
@@ -1231,9 +1700,7 @@ func TestTimestampValidation(t *testing.T) {
 		rec := httptest.NewRecorder()
 		s.handleWebhook(rec, req)
 
-		if rec.Code != http.StatusBadRequest {
-			t.Errorf("Missing timestamp should return 400, got %d", rec.Code)
-		}
+		checkError(t, rec, http.StatusBadRequest, CodeReplay)
 	})
 
 	t.Run("ValidTimestamp", func(t *testing.T) {
@@ -1258,9 +1725,7 @@ func TestTimestampValidation(t *testing.T) {
 		rec := httptest.NewRecorder()
 		s.handleWebhook(rec, req)
 
-		if rec.Code != http.StatusBadRequest {
-			t.Errorf("Old timestamp should return 400, got %d", rec.Code)
-		}
+		checkError(t, rec, http.StatusBadRequest, CodeReplay)
 	})
 
 	t.Run("FutureTimestamp", func(t *testing.T) {
@@ -1272,9 +1737,7 @@ func TestTimestampValidation(t *testing.T) {
 		rec := httptest.NewRecorder()
 		s.handleWebhook(rec, req)
 
-		if rec.Code != http.StatusBadRequest {
-			t.Errorf("Future timestamp should return 400, got %d", rec.Code)
-		}
+		checkError(t, rec, http.StatusBadRequest, CodeReplay)
 	})
 }
 
@@ -1292,9 +1755,7 @@ func TestNonceValidation(t *testing.T) {
 		rec := httptest.NewRecorder()
 		s.handleWebhook(rec, req)
 
-		if rec.Code != http.StatusBadRequest {
-			t.Errorf("Missing nonce should return 400, got %d", rec.Code)
-		}
+		checkError(t, rec, http.StatusBadRequest, CodeReplay)
 	})
 
 	t.Run("ValidNonce", func(t *testing.T) {
@@ -1331,9 +1792,7 @@ func TestNonceValidation(t *testing.T) {
 		rec2 := httptest.NewRecorder()
 		s.handleWebhook(rec2, req2)
 
-		if rec2.Code != http.StatusConflict {
-			t.Errorf("Duplicate nonce should return 409, got %d", rec2.Code)
-		}
+		checkError(t, rec2, http.StatusConflict, CodeReplay)
 	})
 }
 
@@ -1437,8 +1896,648 @@ func TestHMACUsersMapping(t *testing.T) {
 
 		s.handleWebhook(rec, req)
 
-		if rec.Code != http.StatusUnauthorized {
-			t.Errorf("Expected 401, got %d", rec.Code)
+		checkError(t, rec, http.StatusUnauthorized, CodeUnauthorized)
+	})
+}
+
+func TestDeliveryID(t *testing.T) {
+	body := []byte(`{"message":"hi"}`)
+	req := func(headers map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+
+	if got := deliveryID(req(map[string]string{"X-Nonce": "n1", "X-Request-ID": "r1"}), body, "u"); got != "n1" {
+		t.Errorf("nonce should win, got %q", got)
+	}
+	if got := deliveryID(req(map[string]string{"Idempotency-Key": "k1"}), body, "u"); got != "k1" {
+		t.Errorf("idempotency key not used, got %q", got)
+	}
+
+	a := deliveryID(req(map[string]string{"X-Timestamp": "100"}), body, "u")
+	if b := deliveryID(req(map[string]string{"X-Timestamp": "100"}), body, "u"); a != b {
+		t.Error("same body, user and timestamp should hash identically")
+	}
+	if b := deliveryID(req(map[string]string{"X-Timestamp": "101"}), body, "u"); a == b {
+		t.Error("different timestamp should change the hash")
+	}
+	if b := deliveryID(req(map[string]string{"X-Timestamp": "100"}), body, "other"); a == b {
+		t.Error("different user should change the hash")
+	}
+}
+
+func TestHandleWebhookDraining(t *testing.T) {
+	body := `{"message":"hi","user_id":"user1"}`
+	post := func(s *Server) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.handleWebhook(rec, req)
+		return rec
+	}
+
+	t.Run("DrainNotified", func(t *testing.T) {
+		called := false
+		s := newTestServer(&Config{AuthMethod: "none", AllowedUsers: []string{"user1"}})
+		s.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+			called = true
+			return "ok", nil
+		})
+		s.Drain()
+
+		rec := post(s)
+		checkError(t, rec, http.StatusServiceUnavailable, CodeUnavailable)
+		if rec.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After header")
+		}
+		if called {
+			t.Error("Handler should not run while draining")
+		}
+
+		rec = httptest.NewRecorder()
+		s.handleReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected readiness 503 while draining, got %d", rec.Code)
+		}
+	})
+
+	t.Run("HandlerDraining", func(t *testing.T) {
+		s := newTestServer(&Config{AuthMethod: "none", AllowedUsers: []string{"user1"}})
+		s.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+			return "", router.ErrDraining
+		})
+		checkError(t, post(s), http.StatusServiceUnavailable, CodeUnavailable)
+	})
+}
+
+func TestStrictProfile(t *testing.T) {
+	const secret = "strict-secret"
+
+	if _, err := New(&Config{AuthMethod: "bearer", SecurityProfile: ProfileStrict}); err == nil {
+		t.Error("strict profile without hmac auth should fail")
+	}
+	if _, err := New(&Config{AuthMethod: "hmac", SecurityProfile: "paranoid"}); err == nil {
+		t.Error("unknown security profile should fail")
+	}
+
+	s := newTestServer(&Config{
+		AuthMethod:      "hmac",
+		HMACUsers:       map[string]string{secret: "ci"},
+		AllowedUsers:    []string{"ci"},
+		SecurityProfile: ProfileStrict,
+	})
+
+	// sign is how a client signs a strict request: HMAC-SHA256 over
+	// timestamp + "." + nonce + "." + body, sent as "sha256=<hex>".
+	sign := func(timestamp, nonce string, body []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "." + nonce + "."))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	post := func(timestamp, nonce, sig string, body []byte) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+		if timestamp != "" {
+			req.Header.Set("X-Timestamp", timestamp)
+		}
+		if nonce != "" {
+			req.Header.Set("X-Nonce", nonce)
+		}
+		req.Header.Set("X-Signature", sig)
+		req.RemoteAddr = "127.0.0.1:12345"
+		rec := httptest.NewRecorder()
+		s.handleWebhook(rec, req)
+		return rec.Code
+	}
+
+	body := []byte(`{"message": "deploy finished"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	t.Run("Valid", func(t *testing.T) {
+		if code := post(now, "n-1", sign(now, "n-1", body), body); code != http.StatusOK {
+			t.Errorf("signed request = %d, want 200", code)
+		}
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		if code := post(now, "n-1", sign(now, "n-1", body), body); code != http.StatusConflict {
+			t.Errorf("replayed request = %d, want 409", code)
+		}
+	})
+
+	t.Run("SwappedNonce", func(t *testing.T) {
+		// A captured signature cannot be reused with a fresh nonce
+		if code := post(now, "n-2", sign(now, "n-1", body), body); code != http.StatusUnauthorized {
+			t.Errorf("swapped nonce = %d, want 401", code)
+		}
+	})
+
+	t.Run("SwappedTimestamp", func(t *testing.T) {
+		later := strconv.FormatInt(time.Now().Unix()+1, 10)
+		if code := post(later, "n-3", sign(now, "n-3", body), body); code != http.StatusUnauthorized {
+			t.Errorf("swapped timestamp = %d, want 401", code)
+		}
+		// The rejected request did not consume its nonce
+		if code := post(now, "n-3", sign(now, "n-3", body), body); code != http.StatusOK {
+			t.Errorf("nonce after forged attempt = %d, want 200", code)
+		}
+	})
+
+	t.Run("BodyOnlySignature", func(t *testing.T) {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if code := post(now, "n-4", sig, body); code != http.StatusUnauthorized {
+			t.Errorf("body-only signature = %d, want 401", code)
+		}
+	})
+
+	t.Run("MissingHeaders", func(t *testing.T) {
+		if code := post("", "n-5", sign("", "n-5", body), body); code != http.StatusBadRequest {
+			t.Errorf("missing timestamp = %d, want 400", code)
+		}
+		if code := post(now, "", sign(now, "", body), body); code != http.StatusBadRequest {
+			t.Errorf("missing nonce = %d, want 400", code)
+		}
+	})
+}
+
+func TestRoutes(t *testing.T) {
+	const secret = "alerts-secret"
+	s := newTestServer(&Config{
+		AuthMethod:   "bearer",
+		BearerTokens: map[string]string{"main-token": "ci"},
+		AllowedUsers: []string{"ci"},
+		Routes: []Route{
+			{
+				Path:         "/webhook/github",
+				AuthMethod:   "bearer",
+				BearerTokens: map[string]string{"gh-token": "github"},
+				AllowedUsers: []string{"github"},
+			},
+			{
+				Path:         "/webhook/alerts",
+				AuthMethod:   "hmac",
+				HMACUsers:    map[string]string{secret: "grafana"},
+				AllowedUsers: []string{"grafana"},
+				Sources:      []SourceConfig{{Name: "alerts", Field: "$.alert.summary", TextPath: "$.alert.summary"}},
+			},
+			{
+				Path:         "/webhook/jenkins",
+				AuthMethod:   "basic",
+				BasicUser:    "jenkins",
+				BasicPass:    "build-pass",
+				AllowedUsers: []string{"jenkins"},
+			},
+		},
+	})
+	if s == nil {
+		t.Fatal("New failed")
+	}
+	var got []*router.Message
+	s.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+		got = append(got, msg)
+		return "", nil
+	})
+	mux := s.newMux()
+
+	post := func(path, body string, header map[string]string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:12345"
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	alert := `{"alert": {"summary": "disk full"}}`
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		header map[string]string
+		want   int
+	}{
+		{"main route", "/webhook", `{"message": "deploy"}`, map[string]string{"Authorization": "Bearer main-token"}, http.StatusOK},
+		{"github route", "/webhook/github", `{"message": "push"}`, map[string]string{"Authorization": "Bearer gh-token"}, http.StatusOK},
+		{"github token on main route", "/webhook", `{"message": "push"}`, map[string]string{"Authorization": "Bearer gh-token"}, http.StatusUnauthorized},
+		{"main token on github route", "/webhook/github", `{"message": "push"}`, map[string]string{"Authorization": "Bearer main-token"}, http.StatusUnauthorized},
+		{"alerts route", "/webhook/alerts", alert, map[string]string{"X-Signature": sign(alert)}, http.StatusOK},
+		{"alerts without signature", "/webhook/alerts", alert, map[string]string{"Authorization": "Bearer gh-token"}, http.StatusUnauthorized},
+		{"jenkins route", "/webhook/jenkins", `{"message": "built"}`, map[string]string{"Authorization": basic("jenkins", "build-pass")}, http.StatusOK},
+		{"jenkins wrong password", "/webhook/jenkins", `{"message": "built"}`, map[string]string{"Authorization": basic("jenkins", "nope")}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if code := post(tt.path, tt.body, tt.header); code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	if len(got) != 4 {
+		t.Fatalf("handler got %d messages, want 4", len(got))
+	}
+	if got[1].UserID != "github" || got[2].UserID != "grafana" || got[2].Text != "disk full" {
+		t.Errorf("route messages = %+v, %+v", got[1], got[2])
+	}
+	if got[3].UserID != "jenkins" {
+		t.Errorf("basic route user = %q, want jenkins", got[3].UserID)
+	}
+}
+
+func TestNewInvalidRoutes(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"relative path", Config{Routes: []Route{{Path: "alerts"}}}},
+		{"duplicate path", Config{Routes: []Route{{Path: "/a"}, {Path: "/a"}}}},
+		{"shadows main path", Config{Routes: []Route{{Path: "/webhook"}}}},
+		{"shadows health", Config{Routes: []Route{{Path: "/health"}}}},
+		{"strict without hmac", Config{AuthMethod: "hmac", SecurityProfile: ProfileStrict, Routes: []Route{{Path: "/a", AuthMethod: "bearer"}}}},
+		{"bad source", Config{Routes: []Route{{Path: "/a", Sources: []SourceConfig{{Name: "x"}}}}}},
+		{"unknown algorithm", Config{AuthMethod: "hmac", HMACAlgorithms: []string{"md5"}}},
+		{"ed25519 without key", Config{AuthMethod: "hmac", HMACAlgorithms: []string{"ed25519"}}},
+		{"bad ed25519 key", Config{Routes: []Route{{Path: "/a", Ed25519PublicKey: "abcd"}}}},
+		{"basic without password", Config{Routes: []Route{{Path: "/a", AuthMethod: "basic", BasicUser: "ci"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := New(&tt.cfg); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	s := newTestServer(&Config{
+		AuthMethod:       "none",
+		AllowedUsers:     []string{"testuser"},
+		RateLimitPerIP:   3,
+		RateLimitPerUser: 5,
+		RateLimitWindow:  time.Minute,
+	})
+	defer s.ipLimiter.stop()
+	defer s.userLimiter.stop()
+
+	makeRequest := func() *httptest.ResponseRecorder {
+		body := bytes.NewReader([]byte(`{"message": "test", "user_id": "testuser"}`))
+		req := httptest.NewRequest(http.MethodPost, "/webhook", body)
+		req.RemoteAddr = "192.168.1.50:12345"
+		rec := httptest.NewRecorder()
+		s.handleWebhook(rec, req)
+		return rec
+	}
+
+	for i, want := range []string{"2", "1", "0"} {
+		rec := makeRequest()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("request %d: X-RateLimit-Limit = %q, want 3 (the tighter IP limit)", i+1, got)
 		}
+		if got := rec.Header().Get("X-RateLimit-Remaining"); got != want {
+			t.Errorf("request %d: X-RateLimit-Remaining = %q, want %s", i+1, got, want)
+		}
+		reset, err := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil || reset < time.Now().Unix() || reset > time.Now().Add(time.Minute+time.Second).Unix() {
+			t.Errorf("request %d: X-RateLimit-Reset = %q", i+1, rec.Header().Get("X-RateLimit-Reset"))
+		}
+	}
+
+	rec := makeRequest()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request 4: status %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("429 headers = %v", rec.Header())
+	}
+}
+
+func TestRateLimiterStatus(t *testing.T) {
+	rl := newRateLimiter(2, time.Minute)
+	defer rl.stop()
+
+	if limit, remaining, _ := rl.status("k"); limit != 2 || remaining != 2 {
+		t.Errorf("fresh key: limit %d remaining %d", limit, remaining)
+	}
+	rl.allow("k")
+	_, remaining, _ := rl.status("k")
+	_, again, _ := rl.status("k")
+	if remaining != 1 || again != 1 {
+		t.Errorf("status consumed a request: %d then %d", remaining, again)
+	}
+}
+
+func TestHMACAlgorithms(t *testing.T) {
+	secret := "my-webhook-secret"
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+
+	body := []byte(`{"event": "push"}`)
+	hmacHex := func(alg string) string {
+		newHash := sha256.New
+		if alg == AlgSHA1 {
+			newHash = sha1.New
+		}
+		mac := hmac.New(newHash, []byte(secret))
+		mac.Write(body)
+		return alg + "=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	all := newTestServer(&Config{
+		AuthMethod:       "hmac",
+		HMACSecret:       secret,
+		HMACAlgorithms:   []string{"sha256", "SHA1", "ed25519"},
+		Ed25519PublicKey: hex.EncodeToString(pub),
+	})
+	sha256Only := newTestServer(&Config{AuthMethod: "hmac", HMACSecret: secret})
+
+	tests := []struct {
+		name   string
+		s      *Server
+		header string
+		value  string
+		want   bool
+	}{
+		{"sha256", all, "X-Hub-Signature-256", hmacHex(AlgSHA256), true},
+		{"sha256 X-Signature", all, "X-Signature", hmacHex(AlgSHA256), true},
+		{"sha1", all, "X-Hub-Signature", hmacHex(AlgSHA1), true},
+		{"ed25519", all, "X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(priv, body)), true},
+		{"sha1 in sha256 header", all, "X-Hub-Signature-256", hmacHex(AlgSHA1), false},
+		{"sha256 in sha1 header", all, "X-Hub-Signature", hmacHex(AlgSHA256), false},
+		{"ed25519 wrong key", all, "X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(otherPriv, body)), false},
+		{"ed25519 not hex", all, "X-Signature-Ed25519", "not-a-signature", false},
+		{"sha1 not configured", sha256Only, "X-Hub-Signature", hmacHex(AlgSHA1), false},
+		{"default sha256", sha256Only, "X-Hub-Signature-256", hmacHex(AlgSHA256), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+			req.Header.Set(tt.header, tt.value)
+			if _, ok := tt.s.authenticate(req); ok != tt.want {
+				t.Errorf("authenticate() = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestLogRing(t *testing.T) {
+	l := newRequestLog(3, 0)
+	for i := 1; i <= 5; i++ {
+		l.add(RequestRecord{RequestID: strconv.Itoa(i)})
+	}
+	got := l.snapshot()
+	if len(got) != 3 {
+		t.Fatalf("kept %d entries, want 3", len(got))
+	}
+	for i, want := range []string{"5", "4", "3"} {
+		if got[i].RequestID != want {
+			t.Errorf("entry %d = %s, want %s (newest first)", i, got[i].RequestID, want)
+		}
+	}
+
+	if n := len(newRequestLog(1_000_000, 1_000_000).entries); n != maxDebugRequests {
+		t.Errorf("ring size = %d, want capped at %d", n, maxDebugRequests)
+	}
+}
+
+func TestDebugRequests(t *testing.T) {
+	s := newTestServer(&Config{
+		AuthMethod:     "bearer",
+		BearerTokens:   map[string]string{"admin-token": "admin", "user-token": "alice"},
+		AllowedUsers:   []string{"admin", "alice"},
+		DebugRequests:  10,
+		DebugBodyBytes: 8,
+		DebugAdmins:    []string{"admin"},
+	})
+	mux := s.newMux()
+
+	serve := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:12345"
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"message": "hello"}`
+	serve(http.MethodPost, "/webhook", body, map[string]string{"Authorization": "Bearer user-token", "X-Hub-Signature-256": "sha256=abc", "X-Trace": "t1"})
+	serve(http.MethodPost, "/webhook", body, map[string]string{"Authorization": "Bearer wrong"})
+
+	for _, tt := range []struct {
+		name   string
+		header map[string]string
+		want   int
+	}{
+		{"no token", nil, http.StatusUnauthorized},
+		{"non-admin", map[string]string{"Authorization": "Bearer user-token"}, http.StatusForbidden},
+	} {
+		if rec := serve(http.MethodGet, DebugPath, "", tt.header); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	rec := serve(http.MethodGet, DebugPath, "", map[string]string{"Authorization": "Bearer admin-token"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin: status %d, want 200", rec.Code)
+	}
+	var resp struct{ Requests []RequestRecord }
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Requests) != 2 {
+		t.Fatalf("got %d requests, want 2 (debug calls are not logged)", len(resp.Requests))
+	}
+
+	failed, ok := resp.Requests[0], resp.Requests[1]
+	if failed.Auth != "failed" || failed.Status != http.StatusUnauthorized || failed.BodySize != 0 {
+		t.Errorf("failed request = auth %q status %d body %d, want failed/401/unread", failed.Auth, failed.Status, failed.BodySize)
+	}
+	if ok.Auth != "ok" || ok.UserID != "alice" || ok.Status != http.StatusOK {
+		t.Errorf("accepted request = auth %q user %q status %d, want ok/alice/200", ok.Auth, ok.UserID, ok.Status)
+	}
+	sum := sha256.Sum256([]byte(body))
+	if ok.BodySize != len(body) || ok.BodySHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("body size/hash = %d/%s, want %d/%x", ok.BodySize, ok.BodySHA256, len(body), sum)
+	}
+	if ok.Body != body[:8] || !ok.BodyTruncated {
+		t.Errorf("body = %q (truncated %v), want %q truncated", ok.Body, ok.BodyTruncated, body[:8])
+	}
+	for _, h := range []string{"Authorization", "X-Hub-Signature-256"} {
+		if ok.Headers[h] != redacted {
+			t.Errorf("header %s = %q, want redacted", h, ok.Headers[h])
+		}
+	}
+	if ok.Headers["X-Trace"] != "t1" {
+		t.Errorf("header X-Trace = %q, want t1", ok.Headers["X-Trace"])
+	}
+}
+
+func TestDebugRequestsIPAndDrain(t *testing.T) {
+	s := newTestServer(&Config{
+		AuthMethod:    "bearer",
+		BearerTokens:  map[string]string{"admin-token": "admin"},
+		AllowedUsers:  []string{"admin"},
+		AllowedIPs:    []string{"10.0.0.1"},
+		DebugRequests: 10,
+		DebugAdmins:   []string{"admin"},
+	})
+	mux := s.newMux()
+	serve := func(ip string) int {
+		req := httptest.NewRequest(http.MethodGet, DebugPath, nil)
+		req.RemoteAddr = ip + ":12345"
+		req.Header.Set("Authorization", "Bearer admin-token")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("10.0.0.2"); code != http.StatusForbidden {
+		t.Errorf("other IP: status %d, want 403", code)
+	}
+	if code := serve("10.0.0.1"); code != http.StatusOK {
+		t.Errorf("allowed IP: status %d, want 200", code)
+	}
+	s.draining.Store(true)
+	if code := serve("10.0.0.1"); code != http.StatusServiceUnavailable {
+		t.Errorf("draining: status %d, want 503", code)
+	}
+}
+
+func TestDebugRequestsDisabled(t *testing.T) {
+	s := newTestServer(&Config{AuthMethod: "none", AllowedUsers: []string{"alice"}})
+	if s.requests != nil {
+		t.Error("request log should be off by default")
+	}
+	req := httptest.NewRequest(http.MethodGet, DebugPath, nil)
+	rec := httptest.NewRecorder()
+	s.newMux().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404 when disabled", rec.Code)
+	}
+}
+
+func TestCronTrigger(t *testing.T) {
+	s := newTestServer(&Config{
+		AuthMethod:    "bearer",
+		BearerTokens:  map[string]string{"ci-token": "ci", "other-token": "mallory"},
+		AllowedUsers:  []string{"ci"},
+		CronJobs:      []string{"nightly", "gone", "broken"},
+		DebugRequests: 10, // responses go through the request log's writer
+	})
+	mux := s.newMux()
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	checkError(t, serve(http.MethodPost, "/webhook/cron/nightly", "ci-token"), http.StatusServiceUnavailable, CodeUnavailable)
+
+	var ran []string
+	s.SetCronRunner(func(_ context.Context, id string) (string, error) {
+		ran = append(ran, id)
+		switch id {
+		case "gone":
+			return "", fmt.Errorf("%w: %s", ErrUnknownCronJob, id)
+		case "broken":
+			return "digest", fmt.Errorf("send to slack failed")
+		}
+		return "Good morning!", nil
 	})
+
+	rec := serve(http.MethodPost, "/webhook/cron/nightly", "ci-token")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("trigger: status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["ok"] != true || resp["job"] != "nightly" || resp["message"] != "Good morning!" {
+		t.Errorf("response = %v", resp)
+	}
+
+	for _, tt := range []struct {
+		name, method, path, token string
+		status                    int
+		code                      string
+	}{
+		{"GET", http.MethodGet, "/webhook/cron/nightly", "ci-token", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"no token", http.MethodPost, "/webhook/cron/nightly", "", http.StatusUnauthorized, CodeUnauthorized},
+		{"user not allowed", http.MethodPost, "/webhook/cron/nightly", "other-token", http.StatusForbidden, CodeForbiddenUser},
+		{"job not listed", http.MethodPost, "/webhook/cron/secret", "ci-token", http.StatusForbidden, CodeForbidden},
+		{"job missing", http.MethodPost, "/webhook/cron/gone", "ci-token", http.StatusNotFound, CodeNotFound},
+		{"job failed", http.MethodPost, "/webhook/cron/broken", "ci-token", http.StatusInternalServerError, CodeFailed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			checkError(t, serve(tt.method, tt.path, tt.token), tt.status, tt.code)
+		})
+	}
+	if want := []string{"nightly", "gone", "broken"}; strings.Join(ran, ",") != strings.Join(want, ",") {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+
+	open := newTestServer(&Config{AuthMethod: "none", AllowedUsers: []string{"*"}, CronJobs: []string{"nightly"}})
+	open.SetCronRunner(func(context.Context, string) (string, error) {
+		t.Error("job ran without authentication")
+		return "", nil
+	})
+	req := httptest.NewRequest(http.MethodPost, "/webhook/cron/nightly", nil)
+	req.Header.Set("X-User-ID", "anyone")
+	rec = httptest.NewRecorder()
+	open.newMux().ServeHTTP(rec, req)
+	checkError(t, rec, http.StatusForbidden, CodeForbidden)
+}
+
+// checkError fails t unless rec is a JSON error envelope with status and code.
+func checkError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Errorf("status = %d, want %d (body %s)", rec.Code, status, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var resp struct {
+		OK    *bool `json:"ok"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode error body %q: %v", rec.Body, err)
+	}
+	if resp.OK == nil || *resp.OK || resp.Error.Code != code || resp.Error.Message == "" {
+		t.Errorf("error body = %s, want ok false and code %q with a message", rec.Body, code)
+	}
+	if resp.RequestID == "" || resp.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Errorf("request_id = %q, want the X-Request-ID header %q", resp.RequestID, rec.Header().Get("X-Request-ID"))
+	}
 }
@@ -0,0 +1,204 @@
+// Reconnection with backoff after the WhatsApp connection drops
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// ReconnectConfig controls how the bot reconnects after the connection drops.
+type ReconnectConfig struct {
+	InitialDelay time.Duration // Delay before the first attempt, doubled after each failure (default 2s)
+	MaxDelay     time.Duration // Cap on the delay between attempts (default 5m)
+	NotifyAfter  int           // Failed attempts before OnReconnectFailing is called (default 5)
+}
+
+// Reconnect defaults.
+const (
+	defaultReconnectDelay    = 2 * time.Second
+	defaultMaxReconnectDelay = 5 * time.Minute
+	defaultNotifyAfter       = 5
+)
+
+// loginTimeout bounds how long a reconnect attempt waits for the login that
+// follows a successful websocket connect.
+const loginTimeout = 30 * time.Second
+
+// errNotPaired means the stored session is gone and the device must be paired again.
+var errNotPaired = errors.New("no paired device in the session store")
+
+func (c ReconnectConfig) withDefaults() ReconnectConfig {
+	if c.InitialDelay <= 0 {
+		c.InitialDelay = defaultReconnectDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = defaultMaxReconnectDelay
+	}
+	if c.MaxDelay < c.InitialDelay {
+		c.MaxDelay = c.InitialDelay
+	}
+	if c.NotifyAfter <= 0 {
+		c.NotifyAfter = defaultNotifyAfter
+	}
+	return c
+}
+
+// nextDelay doubles delay up to the configured cap.
+func (c ReconnectConfig) nextDelay(delay time.Duration) time.Duration {
+	return min(delay*2, c.MaxDelay)
+}
+
+// newClient creates a client for the device stored in the session database.
+// The returned client is not connected.
+func (b *Bot) newClient(ctx context.Context) (*whatsmeow.Client, error) {
+	deviceStore, err := b.container.GetFirstDevice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get device: %w", err)
+	}
+	client := whatsmeow.NewClient(deviceStore, waLog.Noop)
+	client.AddEventHandler(b.eventHandler)
+	// Reconnection is handled by reconnectLoop, with backoff and alerts
+	client.EnableAutoReconnect = false
+	return client, nil
+}
+
+// connect replaces the client with a fresh one restored from the session
+// database and waits until it is logged in.
+func (b *Bot) connect() error {
+	client, err := b.newClient(context.Background())
+	if err != nil {
+		return err
+	}
+	if client.Store.ID == nil {
+		return errNotPaired
+	}
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	if !client.WaitForConnection(loginTimeout) {
+		client.Disconnect()
+		return fmt.Errorf("not logged in after %s", loginTimeout)
+	}
+
+	b.mu.Lock()
+	old := b.client
+	b.client = client
+	b.mu.Unlock()
+	if old != nil {
+		old.RemoveEventHandlers()
+		old.Disconnect()
+	}
+	return nil
+}
+
+// startReconnect starts reconnectLoop unless it is already running, the bot
+// is stopping or the device was logged out.
+func (b *Bot) startReconnect() {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	if b.reconnecting || b.stopping || b.loggedOut {
+		return
+	}
+	b.reconnecting = true
+	b.attempts = 0
+	b.downSince = time.Now()
+	b.wg.Add(1)
+	go b.reconnectLoop()
+}
+
+// reconnectLoop retries the connection with exponential backoff until it
+// succeeds, the bot stops or the device is logged out. Admins are alerted
+// once after NotifyAfter failures, and told again when it recovers.
+func (b *Bot) reconnectLoop() {
+	defer b.wg.Done()
+	rc := b.reconnect
+	delay := rc.InitialDelay
+	notified := false
+
+	defer func() {
+		b.stateMu.Lock()
+		b.reconnecting = false
+		b.stateMu.Unlock()
+	}()
+
+	for attempt := 1; ; attempt++ {
+		b.logger.Info("whatsapp reconnecting", "attempt", attempt, "delay", delay)
+		select {
+		case <-b.done:
+			return
+		case <-time.After(delay):
+		}
+
+		err := b.dial()
+		if err == nil {
+			downtime := time.Since(b.downSinceTime())
+			b.logger.Info("whatsapp reconnected", "attempts", attempt, "downtime", downtime.Round(time.Second))
+			if notified && b.onReconnected != nil {
+				b.onReconnected(attempt, downtime)
+			}
+			return
+		}
+
+		b.stateMu.Lock()
+		b.attempts = attempt
+		loggedOut := b.loggedOut
+		b.stateMu.Unlock()
+
+		b.logger.Warn("whatsapp reconnect failed", "attempt", attempt, "error", err)
+		if loggedOut || errors.Is(err, errNotPaired) {
+			b.markLoggedOut()
+			return
+		}
+		if attempt == rc.NotifyAfter && b.onReconnectFailing != nil {
+			b.onReconnectFailing(attempt, err)
+			notified = true
+		}
+		delay = rc.nextDelay(delay)
+	}
+}
+
+// markLoggedOut stops reconnecting until the device is paired again and
+// alerts admins once.
+func (b *Bot) markLoggedOut() {
+	b.stateMu.Lock()
+	already := b.loggedOut
+	b.loggedOut = true
+	b.stateMu.Unlock()
+	if !already && b.onLoggedOut != nil {
+		b.onLoggedOut()
+	}
+}
+
+func (b *Bot) downSinceTime() time.Time {
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+	return b.downSince
+}
+
+// ConnectionState describes the connection for /status, e.g. "connected"
+// or "reconnecting (attempt 3, down 2m)".
+func (b *Bot) ConnectionState() string {
+	b.stateMu.Lock()
+	reconnecting, attempts, downSince, loggedOut := b.reconnecting, b.attempts, b.downSince, b.loggedOut
+	b.stateMu.Unlock()
+
+	client := b.getClient()
+	switch {
+	case loggedOut:
+		return "logged out, run 'magabot setup platform' to pair again"
+	case reconnecting:
+		return fmt.Sprintf("reconnecting (attempt %d, down %s)", attempts+1, time.Since(downSince).Round(time.Second))
+	case client == nil:
+		return "not started"
+	case client.Store.ID == nil:
+		return "waiting for QR pairing"
+	case client.IsConnected():
+		return "connected"
+	}
+	return "disconnected"
+}
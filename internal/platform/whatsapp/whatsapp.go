@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"mime"
 	"os"
 	"path/filepath"
 	"sync"
@@ -17,10 +18,12 @@ import (
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waLog "go.mau.fi/whatsmeow/util/log"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/kusa/magabot/internal/platform"
 	"github.com/kusa/magabot/internal/router"
+	"github.com/kusa/magabot/internal/telemetry"
 	"github.com/kusa/magabot/internal/util"
 )
 
@@ -35,17 +38,41 @@ type Bot struct {
 	dataDir       string // platform-specific data dir (e.g. data/platform/whatsapp)
 	downloadsDir  string // where downloaded voice files are saved
 	onPairFailure func()
+	maxLen        int
 	done          chan struct{}
 	mu            sync.RWMutex // protects client
 	wg            sync.WaitGroup
+
+	reconnect          ReconnectConfig
+	dial               func() error // connects a fresh client; b.connect outside tests
+	onReconnectFailing func(attempts int, err error)
+	onReconnected      func(attempts int, downtime time.Duration)
+	onLoggedOut        func()
+
+	stateMu      sync.Mutex // protects the connection state below
+	reconnecting bool
+	stopping     bool
+	loggedOut    bool
+	attempts     int // failed reconnect attempts so far
+	downSince    time.Time
 }
 
 // Config for WhatsApp bot
 type Config struct {
 	DataDir       string // Platform data directory (DB + QR file live here)
+	DBPath        string // Session database path (default: DataDir/whatsapp.db)
 	DownloadsDir  string // Directory for downloaded voice files
 	OnPairFailure func() // Called when QR pairing fails after all retries
+	MaxLen        int    // Split outgoing messages longer than this (default: 4096)
 	Logger        *slog.Logger
+
+	Reconnect ReconnectConfig
+	// OnReconnectFailing is called once per outage, after Reconnect.NotifyAfter
+	// failed attempts; OnReconnected follows when that outage ends.
+	OnReconnectFailing func(attempts int, err error)
+	OnReconnected      func(attempts int, downtime time.Duration)
+	// OnLoggedOut is called when WhatsApp ends the session, which needs a new pairing.
+	OnLoggedOut func()
 }
 
 // New creates a new WhatsApp bot
@@ -61,7 +88,12 @@ func New(cfg *Config) (*Bot, error) {
 		return nil, fmt.Errorf("create data dir: %w", err)
 	}
 
-	dbPath := filepath.Join(dataDir, "whatsapp.db")
+	dbPath := cfg.DBPath
+	if dbPath == "" {
+		dbPath = filepath.Join(dataDir, "whatsapp.db")
+	} else if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
+		return nil, fmt.Errorf("create db dir: %w", err)
+	}
 	dbURI := fmt.Sprintf("file:%s?_foreign_keys=on", dbPath)
 	container, err := sqlstore.New(context.Background(), "sqlite3", dbURI, waLog.Noop)
 	if err != nil {
@@ -73,14 +105,26 @@ func New(cfg *Config) (*Bot, error) {
 		downloadsDir = filepath.Join(dataDir, "downloads")
 	}
 
-	return &Bot{
-		container:     container,
-		logger:        cfg.Logger,
-		dataDir:       dataDir,
-		downloadsDir:  downloadsDir,
-		onPairFailure: cfg.OnPairFailure,
-		done:          make(chan struct{}),
-	}, nil
+	maxLen := cfg.MaxLen
+	if maxLen <= 0 {
+		maxLen = whatsAppMaxLen
+	}
+
+	b := &Bot{
+		container:          container,
+		logger:             cfg.Logger,
+		dataDir:            dataDir,
+		downloadsDir:       downloadsDir,
+		onPairFailure:      cfg.OnPairFailure,
+		maxLen:             maxLen,
+		done:               make(chan struct{}),
+		reconnect:          cfg.Reconnect.withDefaults(),
+		onReconnectFailing: cfg.OnReconnectFailing,
+		onReconnected:      cfg.OnReconnected,
+		onLoggedOut:        cfg.OnLoggedOut,
+	}
+	b.dial = b.connect
+	return b, nil
 }
 
 // getClient returns a snapshot of the current WhatsApp client (thread-safe).
@@ -98,15 +142,11 @@ func (b *Bot) Name() string {
 
 // Start starts the WhatsApp client
 func (b *Bot) Start(ctx context.Context) error {
-	deviceStore, err := b.container.GetFirstDevice(ctx)
+	client, err := b.newClient(ctx)
 	if err != nil {
-		return fmt.Errorf("get device: %w", err)
+		return err
 	}
 
-	client := whatsmeow.NewClient(deviceStore, waLog.Noop)
-	client.AddEventHandler(b.eventHandler)
-	client.EnableAutoReconnect = true
-
 	b.mu.Lock()
 	b.client = client
 	b.mu.Unlock()
@@ -194,6 +234,9 @@ func (b *Bot) qrLoop(ctx context.Context) {
 
 // Stop stops the WhatsApp client
 func (b *Bot) Stop() error {
+	b.stateMu.Lock()
+	b.stopping = true
+	b.stateMu.Unlock()
 	close(b.done)
 
 	if client := b.getClient(); client != nil {
@@ -216,10 +259,18 @@ func (b *Bot) Send(chatID, message string) error {
 		return fmt.Errorf("invalid chat ID %q: %w", chatID, err)
 	}
 
-	_, err = client.SendMessage(context.Background(), jid, &waE2E.Message{
-		Conversation: proto.String(message),
-	})
-	return err
+	chunks := platform.SplitMessage(message, b.maxLen)
+	for i, chunk := range chunks {
+		if _, err := client.SendMessage(context.Background(), jid, &waE2E.Message{
+			Conversation: proto.String(chunk),
+		}); err != nil {
+			if i == 0 {
+				return err
+			}
+			return &router.PartialSendError{Unsent: chunks[i:], Err: err}
+		}
+	}
+	return nil
 }
 
 // SendVoice uploads and sends an OGG Opus audio as a WhatsApp PTT voice message.
@@ -261,6 +312,149 @@ func (b *Bot) SendVoice(chatID string, audio []byte) error {
 	return err
 }
 
+// SendFile uploads and sends data as a WhatsApp document.
+func (b *Bot) SendFile(chatID, name string, data []byte) error {
+	client := b.getClient()
+	if client == nil || !client.IsConnected() {
+		return fmt.Errorf("WhatsApp not connected")
+	}
+
+	jid, err := types.ParseJID(chatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID %q: %w", chatID, err)
+	}
+
+	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaDocument)
+	if err != nil {
+		return fmt.Errorf("upload document: %w", err)
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	_, err = client.SendMessage(context.Background(), jid, &waE2E.Message{
+		DocumentMessage: &waE2E.DocumentMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(data))),
+			Mimetype:      proto.String(mimeType),
+			FileName:      proto.String(name),
+			Title:         proto.String(name),
+		},
+	})
+	return err
+}
+
+// WhatsApp media limits
+const (
+	whatsappMaxImage    = 16 << 20 // bytes
+	whatsappMaxDocument = 100 << 20
+	whatsappMaxCaption  = 1024 // characters
+)
+
+// SendMedia uploads JPEG and PNG images as image messages and other files
+// as documents. A caption too long for WhatsApp follows its file as a
+// message.
+func (b *Bot) SendMedia(chatID string, files []router.MediaFile, wait func() error) error {
+	client := b.getClient()
+	if client == nil || !client.IsConnected() {
+		return fmt.Errorf("WhatsApp not connected")
+	}
+
+	jid, err := types.ParseJID(chatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID %q: %w", chatID, err)
+	}
+	for _, f := range files {
+		limit := whatsappMaxDocument
+		if whatsappImage(f) {
+			limit = whatsappMaxImage
+		}
+		if err := f.CheckSize("whatsapp", limit); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		caption, after := f.Caption, ""
+		if len([]rune(caption)) > whatsappMaxCaption {
+			caption, after = "", caption
+		}
+		if err := wait(); err != nil {
+			return err
+		}
+		if err := b.sendMedia(client, jid, f, caption); err != nil {
+			return err
+		}
+		if after != "" {
+			if err := wait(); err != nil {
+				return err
+			}
+			if err := b.Send(chatID, after); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sendMedia uploads and sends one file with caption.
+func (b *Bot) sendMedia(client *whatsmeow.Client, jid types.JID, f router.MediaFile, caption string) error {
+	image := whatsappImage(f)
+	kind := whatsmeow.MediaDocument
+	if image {
+		kind = whatsmeow.MediaImage
+	}
+	uploaded, err := client.Upload(context.Background(), f.Data, kind)
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", f.Name, err)
+	}
+
+	var text *string
+	if caption != "" {
+		text = proto.String(caption)
+	}
+	msg := &waE2E.Message{}
+	if image {
+		msg.ImageMessage = &waE2E.ImageMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(f.Data))),
+			Mimetype:      proto.String(f.Type()),
+			Caption:       text,
+		}
+	} else {
+		msg.DocumentMessage = &waE2E.DocumentMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(f.Data))),
+			Mimetype:      proto.String(f.Type()),
+			FileName:      proto.String(f.Name),
+			Title:         proto.String(f.Name),
+			Caption:       text,
+		}
+	}
+	_, err = client.SendMessage(context.Background(), jid, msg)
+	return err
+}
+
+// whatsappImage reports whether f goes out as an image rather than a
+// document.
+func whatsappImage(f router.MediaFile) bool {
+	t := f.Type()
+	return t == "image/jpeg" || t == "image/png"
+}
+
 // SetHandler is provided by platform.Base.
 
 // IsConnected returns connection status
@@ -275,17 +469,25 @@ func (b *Bot) eventHandler(evt interface{}) {
 	case *events.Message:
 		b.handleMessage(v)
 	case *events.Connected:
+		b.stateMu.Lock()
+		b.loggedOut = false
+		b.stateMu.Unlock()
 		b.logger.Info("connected to WhatsApp")
 	case *events.Disconnected:
-		b.logger.Info("disconnected from WhatsApp (auto-reconnect enabled)")
+		// Drops during QR pairing are handled by qrLoop
+		if client := b.getClient(); client != nil && client.Store.ID != nil {
+			b.logger.Warn("disconnected from WhatsApp")
+			b.startReconnect()
+		}
 	case *events.LoggedOut:
 		b.logger.Warn("logged out from WhatsApp — re-run setup to pair again")
+		b.markLoggedOut()
 	}
 }
 
 // handleMessage processes an incoming WhatsApp message
 
-// whatsAppMaxLen is the max message length for splitting long responses.
+// whatsAppMaxLen is the default length for splitting long responses.
 const whatsAppMaxLen = 4096
 
 func (b *Bot) handleMessage(evt *events.Message) {
@@ -339,6 +541,7 @@ func (b *Bot) handleMessage(evt *events.Message) {
 	userID := evt.Info.Sender.ToNonAD().String()
 
 	msg := &router.Message{
+		ID:        evt.Info.ID,
 		Platform:  "whatsapp",
 		ChatID:    chatID,
 		UserID:    userID,
@@ -353,7 +556,8 @@ func (b *Bot) handleMessage(evt *events.Message) {
 		msg.ReplyTo = rc
 	}
 
-	ctx := context.Background()
+	ctx, span := telemetry.Start(context.Background(), "message", attribute.String("platform", "whatsapp"))
+	defer span.End()
 
 	// Mark incoming message as read
 	if client != nil && client.IsConnected() {
@@ -378,12 +582,15 @@ func (b *Bot) handleMessage(evt *events.Message) {
 		if !ok {
 			return
 		}
+		_, sendSpan := telemetry.Start(ctx, "platform.send", attribute.Bool("streaming", true))
+		defer sendSpan.End()
 
-		for _, chunk := range platform.SplitMessage(platform.SanitizeText("whatsapp", newPortion), whatsAppMaxLen) {
+		for _, chunk := range platform.SplitMessage(platform.SanitizeText("whatsapp", newPortion), b.maxLen) {
 			if _, err := client.SendMessage(ctx, jid, &waE2E.Message{
 				Conversation: proto.String(chunk),
 			}); err != nil {
 				b.logger.Debug("stream: send failed", "error", err)
+				telemetry.RecordError(sendSpan, err)
 				return
 			}
 		}
@@ -392,6 +599,7 @@ func (b *Bot) handleMessage(evt *events.Message) {
 
 	response, err := handler(ctx, msg)
 	if err != nil {
+		telemetry.RecordError(span, err)
 		b.logger.Warn("handler error", "error", err)
 		return
 	}
@@ -411,14 +619,17 @@ func (b *Bot) handleMessage(evt *events.Message) {
 		return
 	}
 	finalText = platform.SanitizeText("whatsapp", finalText)
+	_, sendSpan := telemetry.Start(ctx, "platform.send")
+	defer sendSpan.End()
 
 	// Split and send remaining text
-	for _, chunk := range platform.SplitMessage(finalText, whatsAppMaxLen) {
+	for _, chunk := range platform.SplitMessage(finalText, b.maxLen) {
 		if client != nil && client.IsConnected() {
 			if _, err := client.SendMessage(ctx, jid, &waE2E.Message{
 				Conversation: proto.String(chunk),
 			}); err != nil {
 				b.logger.Error("send chunk failed", "error", err)
+				telemetry.RecordError(sendSpan, err)
 			}
 		}
 	}
@@ -1,11 +1,18 @@
 package whatsapp
 
 import (
+	"errors"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/router"
 )
 
 func TestSaveVoice(t *testing.T) {
@@ -63,3 +70,136 @@ func TestSaveVoice_CreatesDir(t *testing.T) {
 		t.Error("expected downloadsDir to be created")
 	}
 }
+
+func TestReconnectLoop(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		dials    int
+		failing  int
+		recovers int
+	)
+	b := &Bot{
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		done:      make(chan struct{}),
+		reconnect: ReconnectConfig{InitialDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond, NotifyAfter: 3}.withDefaults(),
+		onReconnectFailing: func(attempts int, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failing++
+			if attempts != 3 {
+				t.Errorf("OnReconnectFailing attempts = %d, want 3", attempts)
+			}
+		},
+		onReconnected: func(attempts int, _ time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			recovers++
+			if attempts != 5 {
+				t.Errorf("OnReconnected attempts = %d, want 5", attempts)
+			}
+		},
+	}
+	b.dial = func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if dials < 5 {
+			return errors.New("network down")
+		}
+		return nil
+	}
+
+	b.startReconnect()
+	b.startReconnect() // already running: ignored
+	b.wg.Wait()
+
+	if dials != 5 || failing != 1 || recovers != 1 {
+		t.Errorf("dials=%d failing=%d recovers=%d, want 5, 1, 1", dials, failing, recovers)
+	}
+	if b.reconnecting {
+		t.Error("reconnecting should be cleared after success")
+	}
+}
+
+func TestReconnectLoop_LoggedOut(t *testing.T) {
+	loggedOut := 0
+	b := &Bot{
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		done:        make(chan struct{}),
+		reconnect:   ReconnectConfig{InitialDelay: time.Millisecond}.withDefaults(),
+		dial:        func() error { return errNotPaired },
+		onLoggedOut: func() { loggedOut++ },
+	}
+
+	b.startReconnect()
+	b.wg.Wait()
+	if loggedOut != 1 {
+		t.Errorf("OnLoggedOut called %d times, want 1", loggedOut)
+	}
+	if got := b.ConnectionState(); !strings.HasPrefix(got, "logged out") {
+		t.Errorf("ConnectionState = %q", got)
+	}
+
+	b.startReconnect() // logged out: needs pairing, not retries
+	if b.reconnecting {
+		t.Error("should not reconnect a logged-out device")
+	}
+}
+
+func TestReconnectLoop_Stop(t *testing.T) {
+	b := &Bot{
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		done:      make(chan struct{}),
+		reconnect: ReconnectConfig{InitialDelay: time.Hour}.withDefaults(),
+		dial:      func() error { t.Error("dial after stop"); return nil },
+	}
+	b.startReconnect()
+	if !strings.HasPrefix(b.ConnectionState(), "reconnecting (attempt 1,") {
+		t.Errorf("ConnectionState = %q", b.ConnectionState())
+	}
+	if err := b.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	b.startReconnect() // stopping: ignored
+}
+
+func TestReconnectDelay(t *testing.T) {
+	rc := ReconnectConfig{}.withDefaults()
+	if rc.InitialDelay != 2*time.Second || rc.MaxDelay != 5*time.Minute || rc.NotifyAfter != 5 {
+		t.Errorf("defaults = %+v", rc)
+	}
+	delay := rc.InitialDelay
+	for i := 0; i < 10; i++ {
+		delay = rc.nextDelay(delay)
+	}
+	if delay != rc.MaxDelay {
+		t.Errorf("delay after 10 failures = %s, want the %s cap", delay, rc.MaxDelay)
+	}
+}
+
+func TestWhatsappImage(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	tests := []struct {
+		file  router.MediaFile
+		image bool
+	}{
+		{router.MediaFile{Name: "chart.jpg"}, true},
+		{router.MediaFile{Name: "chart", Data: png}, true},                             // sniffed
+		{router.MediaFile{Name: "x.bin", MIMEType: "image/PNG; charset=binary"}, true}, // explicit type wins
+		{router.MediaFile{Name: "anim.gif"}, false},
+		{router.MediaFile{Name: "transcript.md", Data: []byte("# Chat")}, false},
+	}
+	for _, tt := range tests {
+		if got := whatsappImage(tt.file); got != tt.image {
+			t.Errorf("whatsappImage(%s, %q) = %v, want %v", tt.file.Name, tt.file.Type(), got, tt.image)
+		}
+	}
+
+	big := router.MediaFile{Name: "big.jpg", Data: make([]byte, 11)}
+	if err := big.CheckSize("whatsapp", 10); !errors.Is(err, router.ErrMediaTooLarge) {
+		t.Errorf("CheckSize over the limit = %v, want ErrMediaTooLarge", err)
+	}
+	if err := big.CheckSize("whatsapp", 11); err != nil {
+		t.Errorf("CheckSize at the limit = %v", err)
+	}
+}
@@ -10,9 +10,14 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/kusa/magabot/internal/embedding"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusandriadi/allm-go"
 )
 
 // State represents the lifecycle state of a plugin.
@@ -109,6 +114,16 @@ type Context interface {
 	// RegisterHook registers an event hook.
 	RegisterHook(event string, handler HookHandler) error
 
+	// RegisterLLMProvider adds an LLM provider to the LLM router under its
+	// Name, where it can be made main or picked by routing rules like the
+	// built-in ones. It is removed when the plugin stops.
+	RegisterLLMProvider(p llm.Provider) error
+
+	// RegisterEmbeddingBackend makes e the embedding provider called name,
+	// for memory and knowledge base embeddings. It is removed when the
+	// plugin stops.
+	RegisterEmbeddingBackend(name string, e embedding.Embedder) error
+
 	// GetPlugin returns another loaded plugin by ID.
 	GetPlugin(id string) (Plugin, error)
 
@@ -155,11 +170,16 @@ type Manager struct {
 
 	plugins        map[string]*Registration
 	pluginDirs     []string
+	allowlist      []string
+	denylist       []string
 	dataDir        string
 	commands       map[string]commandEntry
 	hooks          map[string][]hookEntry
 	eventListeners map[string][]eventListener
+	llmProviders   map[string]string // LLM provider name -> plugin ID
+	embedders      map[string]string // embedding provider name -> plugin ID
 	messageSender  MessageSender
+	llmRegistry    LLMRegistry
 	logger         *slog.Logger
 }
 
@@ -183,11 +203,24 @@ type MessageSender interface {
 	Send(platform, chatID, message string) error
 }
 
+// LLMRegistry is where plugins' LLM providers are registered; *llm.Router
+// implements it.
+type LLMRegistry interface {
+	Register(name string, client *allm.Client)
+	Unregister(name string)
+	Providers() []string
+}
+
+var _ LLMRegistry = (*llm.Router)(nil)
+
 // Config holds manager configuration.
 type Config struct {
 	PluginDirs    []string
+	Allowlist     []string // plugin IDs RegisterBuiltins may register (empty = all)
+	Denylist      []string // plugin IDs RegisterBuiltins never registers
 	DataDir       string
 	MessageSender MessageSender
+	LLMRegistry   LLMRegistry
 	Logger        *slog.Logger
 }
 
@@ -200,11 +233,16 @@ func NewManager(cfg Config) *Manager {
 	return &Manager{
 		plugins:        make(map[string]*Registration),
 		pluginDirs:     cfg.PluginDirs,
+		allowlist:      cfg.Allowlist,
+		denylist:       cfg.Denylist,
 		dataDir:        cfg.DataDir,
 		commands:       make(map[string]commandEntry),
 		hooks:          make(map[string][]hookEntry),
 		eventListeners: make(map[string][]eventListener),
+		llmProviders:   make(map[string]string),
+		embedders:      make(map[string]string),
 		messageSender:  cfg.MessageSender,
+		llmRegistry:    cfg.LLMRegistry,
 		logger:         cfg.Logger,
 	}
 }
@@ -291,6 +329,40 @@ func (m *Manager) Register(p Plugin) error {
 	return nil
 }
 
+var (
+	builtinsMu sync.Mutex
+	builtins   []Plugin
+)
+
+// RegisterBuiltin adds p to the plugins compiled into the binary. Plugin
+// packages call it from init and are linked in with a blank import; the
+// daemon registers them all with RegisterBuiltins.
+func RegisterBuiltin(p Plugin) {
+	builtinsMu.Lock()
+	defer builtinsMu.Unlock()
+	builtins = append(builtins, p)
+}
+
+// RegisterBuiltins registers the plugins added with RegisterBuiltin that
+// the allowlist and denylist let through. A plugin that fails to register
+// is logged and skipped.
+func (m *Manager) RegisterBuiltins() {
+	builtinsMu.Lock()
+	plugins := slices.Clone(builtins)
+	builtinsMu.Unlock()
+
+	for _, p := range plugins {
+		id := p.Metadata().ID
+		if slices.Contains(m.denylist, id) || (len(m.allowlist) > 0 && !slices.Contains(m.allowlist, id)) {
+			m.logger.Info("plugin not loaded, excluded by config", "id", id)
+			continue
+		}
+		if err := m.Register(p); err != nil {
+			m.logger.Warn("failed to register plugin", "id", p.Metadata().ID, "error", err)
+		}
+	}
+}
+
 // Init initializes a registered plugin.
 func (m *Manager) Init(id string) error {
 	m.mu.Lock()
@@ -334,10 +406,11 @@ func (m *Manager) Init(id string) error {
 		}
 	}
 
-	// Initialize
+	// Initialize; a failed plugin doesn't keep what it registered
 	if err := reg.Plugin.Init(ctx); err != nil {
 		reg.State = StateError
 		reg.Error = err
+		m.unregisterPlugin(id)
 		m.logger.Error("plugin init failed", "id", id, "error", err)
 		return err
 	}
@@ -415,7 +488,7 @@ func (m *Manager) Stop(id string) error {
 	reg.StoppedAt = &now
 	m.logger.Info("plugin stopped", "id", id)
 
-	// Unregister commands and hooks
+	// Unregister commands, hooks and providers
 	m.unregisterPlugin(id)
 
 	return nil
@@ -589,8 +662,16 @@ func (m *Manager) Emit(event string, data interface{}) {
 	}
 }
 
-// unregisterPlugin removes all commands and hooks for a plugin.
+// unregisterPlugin removes all commands, hooks and providers of a plugin.
 func (m *Manager) unregisterPlugin(id string) {
+	var llmNames []string
+	defer func() {
+		// Outside the lock: the router may probe the providers left
+		for _, name := range llmNames {
+			m.llmRegistry.Unregister(name)
+		}
+	}()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -622,6 +703,20 @@ func (m *Manager) unregisterPlugin(id string) {
 		}
 		m.eventListeners[event] = filtered
 	}
+
+	// Remove providers
+	for name, pluginID := range m.llmProviders {
+		if pluginID == id {
+			llmNames = append(llmNames, name)
+			delete(m.llmProviders, name)
+		}
+	}
+	for name, pluginID := range m.embedders {
+		if pluginID == id {
+			embedding.UnregisterEmbedder(embedding.Provider(name))
+			delete(m.embedders, name)
+		}
+	}
 }
 
 // SaveConfig persists plugin configs to disk.
@@ -768,6 +863,43 @@ func (c *pluginContext) RegisterHook(event string, handler HookHandler) error {
 	return nil
 }
 
+func (c *pluginContext) RegisterLLMProvider(p llm.Provider) error {
+	if c.manager.llmRegistry == nil {
+		return fmt.Errorf("LLM router not configured")
+	}
+	name := p.Name()
+	if name == "" {
+		return fmt.Errorf("LLM provider name cannot be empty")
+	}
+
+	c.manager.mu.Lock()
+	if _, taken := c.manager.llmProviders[name]; taken || slices.Contains(c.manager.llmRegistry.Providers(), name) {
+		c.manager.mu.Unlock()
+		return fmt.Errorf("LLM provider already registered: %s", name)
+	}
+	c.manager.llmProviders[name] = c.pluginID
+	c.manager.mu.Unlock()
+
+	// The router may probe the provider, so don't hold the manager lock
+	c.manager.llmRegistry.Register(name, allm.New(p))
+
+	c.logger.Debug("registered LLM provider", "name", name)
+	return nil
+}
+
+func (c *pluginContext) RegisterEmbeddingBackend(name string, e embedding.Embedder) error {
+	c.manager.mu.Lock()
+	defer c.manager.mu.Unlock()
+
+	if err := embedding.RegisterEmbedder(embedding.Provider(name), e); err != nil {
+		return err
+	}
+	c.manager.embedders[name] = c.pluginID
+
+	c.logger.Debug("registered embedding backend", "name", name)
+	return nil
+}
+
 func (c *pluginContext) GetPlugin(id string) (Plugin, error) {
 	reg := c.manager.Get(id)
 	if reg == nil {
@@ -6,6 +6,11 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/kusa/magabot/internal/embedding"
+	"github.com/kusa/magabot/internal/llm"
+	"github.com/kusandriadi/allm-go"
+	"github.com/kusandriadi/allm-go/allmtest"
 )
 
 // testPlugin is a mock plugin for testing.
@@ -470,3 +475,121 @@ func TestPluginContextDataDir(t *testing.T) {
 		t.Error("plugin data directory should exist")
 	}
 }
+
+// providerPlugin registers a mock LLM provider and embedding backend on
+// Init.
+type providerPlugin struct {
+	testPlugin
+	provider *allmtest.MockProvider
+}
+
+func (p *providerPlugin) Init(ctx Context) error {
+	if err := ctx.RegisterLLMProvider(p.provider); err != nil {
+		return err
+	}
+	return ctx.RegisterEmbeddingBackend("plugin-embed", embedFunc(func(texts []string) [][]float32 {
+		out := make([][]float32, len(texts))
+		for i, text := range texts {
+			out[i] = []float32{float32(len(text))}
+		}
+		return out
+	}))
+}
+
+type embedFunc func(texts []string) [][]float32
+
+func (f embedFunc) Embed(_ context.Context, texts []string, _ bool) ([][]float32, error) {
+	return f(texts), nil
+}
+
+func TestPluginProviders(t *testing.T) {
+	router := llm.NewRouter(&llm.Config{})
+	mgr := NewManager(Config{DataDir: t.TempDir(), LLMRegistry: router})
+
+	p := &providerPlugin{
+		testPlugin: testPlugin{meta: Metadata{ID: "provider-plugin", Name: "Provider Plugin"}},
+		provider:   allmtest.NewMockProvider("plugin-llm", allmtest.WithResponse(&allm.Response{Content: "from the plugin"})),
+	}
+	if err := mgr.Register(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := router.Providers(); len(got) != 1 || got[0] != "plugin-llm" {
+		t.Fatalf("Providers() = %v, want the plugin's provider", got)
+	}
+	if router.MainProvider() != "plugin-llm" {
+		t.Errorf("MainProvider() = %q, want the only provider", router.MainProvider())
+	}
+	resp, err := router.QuickChat(context.Background(), "hi")
+	if err != nil || resp != "from the plugin" {
+		t.Errorf("Chat = %q, %v", resp, err)
+	}
+
+	client := embedding.NewClient(embedding.Config{Provider: "plugin-embed"})
+	emb, err := client.EmbedOne(context.Background(), "four")
+	if err != nil || len(emb.Vector) != 1 || emb.Vector[0] != 4 {
+		t.Errorf("EmbedOne = %+v, %v", emb, err)
+	}
+
+	// A second plugin can't take the same names
+	other := &providerPlugin{
+		testPlugin: testPlugin{meta: Metadata{ID: "other-plugin"}},
+		provider:   allmtest.NewMockProvider("plugin-llm"),
+	}
+	_ = mgr.Register(other)
+	if err := mgr.Init("other-plugin"); err == nil {
+		t.Error("a second plugin registered the same LLM provider name")
+	}
+
+	if err := mgr.Stop("provider-plugin"); err != nil {
+		t.Fatal(err)
+	}
+	if got := router.Providers(); len(got) != 0 {
+		t.Errorf("Providers() after Stop = %v, want none", got)
+	}
+	if _, err := client.EmbedOne(context.Background(), "four"); err == nil {
+		t.Error("embedding backend still works after the plugin stopped")
+	}
+}
+
+func TestRegisterProviderWithoutRouter(t *testing.T) {
+	mgr := NewManager(Config{DataDir: t.TempDir()})
+	ctx := &pluginContext{manager: mgr, pluginID: "p", logger: mgr.logger}
+	if err := ctx.RegisterLLMProvider(allmtest.NewMockProvider("x")); err == nil {
+		t.Error("RegisterLLMProvider succeeded without an LLM router")
+	}
+	if err := ctx.RegisterEmbeddingBackend("openai", embedFunc(nil)); err == nil {
+		t.Error("RegisterEmbeddingBackend replaced a built-in provider")
+	}
+}
+
+func TestRegisterBuiltins(t *testing.T) {
+	builtinsMu.Lock()
+	saved := builtins
+	builtins = nil
+	builtinsMu.Unlock()
+	t.Cleanup(func() {
+		builtinsMu.Lock()
+		builtins = saved
+		builtinsMu.Unlock()
+	})
+
+	RegisterBuiltin(&testPlugin{meta: Metadata{ID: "builtin"}})
+	RegisterBuiltin(&testPlugin{meta: Metadata{ID: "../bad"}})
+	RegisterBuiltin(&testPlugin{meta: Metadata{ID: "denied"}})
+
+	mgr := NewManager(Config{DataDir: t.TempDir(), Denylist: []string{"denied"}})
+	mgr.RegisterBuiltins()
+	if got := mgr.List(); len(got) != 1 || got[0].Metadata.ID != "builtin" {
+		t.Errorf("List() = %v, want only the valid builtin plugin", got)
+	}
+
+	mgr = NewManager(Config{DataDir: t.TempDir(), Allowlist: []string{"denied"}})
+	mgr.RegisterBuiltins()
+	if got := mgr.List(); len(got) != 1 || got[0].Metadata.ID != "denied" {
+		t.Errorf("List() = %v, want only the allowlisted plugin", got)
+	}
+}
@@ -0,0 +1,74 @@
+// Deduplication of redelivered platform messages
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow is how long a message key is remembered when
+// platforms.dedup_window is not set.
+const DefaultDedupWindow = 2 * time.Minute
+
+// dedupCache remembers recently seen message keys so a message redelivered
+// by an at-least-once platform (webhook retries, reconnect replays) is only
+// handled once.
+type dedupCache struct {
+	window    time.Duration
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func newDedupCache(window time.Duration) *dedupCache {
+	return &dedupCache{
+		window: window,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// duplicate records key and reports whether it was already seen within the
+// window. A zero window disables deduplication.
+func (c *dedupCache) duplicate(key string) bool {
+	if c == nil || c.window <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Sub(c.lastPrune) > c.window {
+		for k, t := range c.seen {
+			if now.Sub(t) > c.window {
+				delete(c.seen, k)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	if t, ok := c.seen[key]; ok && now.Sub(t) <= c.window {
+		return true
+	}
+	c.seen[key] = now
+	return false
+}
+
+// dedupKey identifies a message for deduplication: the platform message ID
+// when the platform provides one, otherwise a hash of sender, chat, text and
+// the platform timestamp.
+func dedupKey(msg *Message) string {
+	if msg.ID != "" {
+		return msg.Platform + ":" + msg.ChatID + ":" + msg.ID
+	}
+	h := sha256.New()
+	for _, part := range []string{msg.Platform, msg.ChatID, msg.UserID, msg.Text, strconv.FormatInt(msg.Timestamp.UnixNano(), 10)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return msg.Platform + ":#" + hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,92 @@
+// Draining in-flight messages on shutdown
+package router
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultDrainTimeout bounds how long Stop waits for in-flight messages.
+const DefaultDrainTimeout = 10 * time.Second
+
+// ErrDraining is returned for messages that arrive while the router is
+// shutting down.
+var ErrDraining = errors.New("shutting down, try again shortly")
+
+// Drainer is implemented by platforms that want to know when the router
+// stops accepting messages, e.g. to reject new requests early.
+type Drainer interface {
+	// Drain is called once when shutdown begins
+	Drain()
+}
+
+// begin registers an in-flight message; false once draining has started.
+func (r *Router) begin() bool {
+	r.drainMu.Lock()
+	defer r.drainMu.Unlock()
+	if r.draining {
+		return false
+	}
+	r.inflight.Add(1)
+	r.active++
+	return true
+}
+
+// end marks an in-flight message as finished.
+func (r *Router) end() {
+	r.drainMu.Lock()
+	r.active--
+	r.drainMu.Unlock()
+	r.inflight.Done()
+}
+
+// InFlight returns the number of messages currently being handled.
+func (r *Router) InFlight() int {
+	r.drainMu.Lock()
+	defer r.drainMu.Unlock()
+	return r.active
+}
+
+// Shutdown stops accepting messages, waits for in-flight ones to finish
+// until ctx is done, dead-letters pending send retries and held messages,
+// then stops all platforms. It returns how many messages were still being
+// handled when the wait ended.
+func (r *Router) Shutdown(ctx context.Context) int {
+	r.drainMu.Lock()
+	alreadyDraining := r.draining
+	r.draining = true
+	r.drainMu.Unlock()
+
+	if !alreadyDraining {
+		r.mu.RLock()
+		for _, p := range r.platforms {
+			if d, ok := p.(Drainer); ok {
+				d.Drain()
+			}
+		}
+		r.mu.RUnlock()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(done)
+	}()
+
+	remaining := 0
+	select {
+	case <-done:
+	case <-ctx.Done():
+		remaining = r.InFlight()
+	}
+
+	// Sends still waiting to be retried, and messages held for quiet
+	// hours, go to the dead-letter log
+	r.outbox.cancel()
+	r.outbox.wg.Wait()
+	r.deadLetterHeld()
+
+	r.stopPlatforms()
+	return remaining
+}
@@ -0,0 +1,115 @@
+// Reaction-based feedback on LLM responses
+package router
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kusa/magabot/internal/security"
+)
+
+// Feedback reactions the bot seeds on its answers and counts as ratings.
+const (
+	FeedbackUp   = "👍"
+	FeedbackDown = "👎"
+)
+
+// Reaction is a user adding or removing an emoji reaction on a message.
+type Reaction struct {
+	Platform  string
+	ChatID    string
+	MessageID string
+	UserID    string
+	Emoji     string // Unicode emoji; platforms translate names such as Slack's "+1"
+	Removed   bool
+}
+
+// ReactionHandler handles reaction events from a platform.
+type ReactionHandler func(ctx context.Context, r *Reaction)
+
+// Reactor is implemented by platforms that support message reactions.
+type Reactor interface {
+	// React adds an emoji reaction from the bot to a message
+	React(chatID, messageID, emoji string) error
+
+	// MaxReactions returns how many reactions the bot may put on one
+	// message, or 0 when there is no limit
+	MaxReactions() int
+
+	// SetReactionHandler sets the callback for user reaction events
+	SetReactionHandler(h ReactionHandler)
+}
+
+// feedbackRating maps a reaction to a rating: +1, -1, or 0 for other emoji.
+func feedbackRating(emoji string) int {
+	// Strip skin-tone and variation modifiers ("👍🏽", "👍️")
+	switch {
+	case strings.HasPrefix(emoji, FeedbackUp):
+		return 1
+	case strings.HasPrefix(emoji, FeedbackDown):
+		return -1
+	}
+	return 0
+}
+
+// trackFeedback arranges for the response to msg to be recorded and seeded
+// with feedback reactions once the platform has sent it.
+func (r *Router) trackFeedback(msg *Message) {
+	if r.cfg == nil || !r.cfg.Platforms.Feedback || msg.Provider == "" {
+		return
+	}
+	r.mu.RLock()
+	p, ok := r.platforms[msg.Platform].(Reactor)
+	r.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	platform, provider, model := msg.Platform, msg.Provider, msg.Model
+	msg.OnSent = func(chatID, messageID string) {
+		if err := r.store.SaveFeedbackTarget(platform, chatID, messageID, provider, model); err != nil {
+			r.logger.Warn("save feedback target failed", "platform", platform, "error", err)
+			return
+		}
+		emojis := []string{FeedbackUp, FeedbackDown}
+		if n := p.MaxReactions(); n > 0 && n < len(emojis) {
+			emojis = emojis[:n]
+		}
+		for _, e := range emojis {
+			if err := p.React(chatID, messageID, e); err != nil {
+				r.logger.Debug("add feedback reaction failed", "platform", platform, "error", err)
+				return
+			}
+		}
+	}
+}
+
+// handleReaction records a user's rating of a tracked bot response.
+// Reactions on other messages, non-feedback emoji and reactions from users
+// who may not talk to the bot are ignored.
+func (r *Router) handleReaction(_ context.Context, rc *Reaction) {
+	rating := feedbackRating(rc.Emoji)
+	if rating == 0 || r.cfg == nil || !r.cfg.Platforms.Feedback {
+		return
+	}
+	hashedUser := security.HashUserID(rc.Platform, rc.UserID)
+	if !r.cfg.IsAllowed(rc.Platform, rc.UserID, rc.ChatID, rc.ChatID != rc.UserID) &&
+		(r.authorizer == nil || !r.authorizer.IsAuthorized(rc.Platform, rc.UserID)) {
+		r.logger.Debug("reaction from unauthorized user ignored", "platform", rc.Platform, "user_hash", hashedUser)
+		return
+	}
+
+	var err error
+	if rc.Removed {
+		err = r.store.ClearFeedback(rc.Platform, rc.ChatID, rc.MessageID, hashedUser, rating)
+	} else {
+		var tracked bool
+		tracked, err = r.store.RecordFeedback(rc.Platform, rc.ChatID, rc.MessageID, hashedUser, rating)
+		if err == nil && tracked {
+			r.logger.Debug("feedback recorded", "platform", rc.Platform, "rating", rating, "user_hash", hashedUser)
+		}
+	}
+	if err != nil {
+		r.logger.Warn("record feedback failed", "platform", rc.Platform, "error", err)
+	}
+}
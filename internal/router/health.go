@@ -0,0 +1,81 @@
+// Platform connection state for readiness probes
+package router
+
+import "sort"
+
+// ConnectionChecker is implemented by platforms that hold a connection which
+// can drop after Start, such as WhatsApp's websocket or Slack's Socket Mode.
+type ConnectionChecker interface {
+	// IsConnected reports whether the platform is currently connected
+	IsConnected() bool
+}
+
+// ConnectionStateReporter is implemented by platforms that can describe
+// their connection in more detail than connected or not, such as a reconnect
+// in progress.
+type ConnectionStateReporter interface {
+	// ConnectionState returns a short human-readable state, e.g. "connected"
+	ConnectionState() string
+}
+
+func (r *Router) setStarted(name string, started bool) {
+	r.startedMu.Lock()
+	r.started[name] = started
+	r.startedMu.Unlock()
+}
+
+// PlatformStatus reports, per registered platform, whether it has started
+// and, if it implements ConnectionChecker, is still connected.
+func (r *Router) PlatformStatus() map[string]bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	r.startedMu.Lock()
+	defer r.startedMu.Unlock()
+
+	status := make(map[string]bool, len(r.platforms))
+	for name, p := range r.platforms {
+		ok := r.started[name]
+		if cc, isChecker := p.(ConnectionChecker); ok && isChecker {
+			ok = cc.IsConnected()
+		}
+		status[name] = ok
+	}
+	return status
+}
+
+// PlatformStates returns a human-readable connection state per registered
+// platform, from ConnectionStateReporter when implemented and otherwise from
+// PlatformStatus.
+func (r *Router) PlatformStates() map[string]string {
+	status := r.PlatformStatus()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	states := make(map[string]string, len(status))
+	for name, ok := range status {
+		switch p := r.platforms[name].(type) {
+		case ConnectionStateReporter:
+			states[name] = p.ConnectionState()
+		default:
+			if ok {
+				states[name] = "connected"
+			} else {
+				states[name] = "disconnected"
+			}
+		}
+	}
+	return states
+}
+
+// DisconnectedPlatforms returns the sorted names of registered platforms that
+// are not connected; empty when every platform is ready.
+func (r *Router) DisconnectedPlatforms() []string {
+	var names []string
+	for name, ok := range r.PlatformStatus() {
+		if !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,96 @@
+// Sending images and documents
+package router
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// ErrMediaTooLarge is returned when a file is over the platform's size
+// limit for its kind.
+var ErrMediaTooLarge = errors.New("file too large for the platform")
+
+// MediaFile is a file for SendMedia.
+type MediaFile struct {
+	Name     string // file name shown to the recipient
+	MIMEType string // empty: from Name's extension, else sniffed from Data
+	Data     []byte
+	Caption  string // text sent with the file; may be empty
+}
+
+// Type returns the file's MIME type without parameters.
+func (f MediaFile) Type() string {
+	t := f.MIMEType
+	if t == "" {
+		t = mime.TypeByExtension(filepath.Ext(f.Name))
+	}
+	if t == "" {
+		t = http.DetectContentType(f.Data)
+	}
+	t, _, _ = strings.Cut(t, ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// IsImage reports whether the file is an image.
+func (f MediaFile) IsImage() bool {
+	return strings.HasPrefix(f.Type(), "image/")
+}
+
+// CheckSize returns an ErrMediaTooLarge error when the file is over limit
+// bytes on platform.
+func (f MediaFile) CheckSize(platform string, limit int) error {
+	if len(f.Data) > limit {
+		return fmt.Errorf("%w: %s is %d bytes, %s allows %d", ErrMediaTooLarge, f.Name, len(f.Data), platform, limit)
+	}
+	return nil
+}
+
+// MediaSender is implemented by platforms that can send files with
+// captions, images shown inline where the platform can.
+type MediaSender interface {
+	// SendMedia sends files in order, each with its caption. It calls wait
+	// before each message it sends, a file or a caption on its own, and
+	// stops with wait's error.
+	SendMedia(chatID string, files []MediaFile, wait func() error) error
+}
+
+// SendMedia sends files to a specific platform and chat. Platforms without
+// MediaSender get each file through SendFile, followed by its caption.
+func (r *Router) SendMedia(platform, chatID string, files []MediaFile) error {
+	r.mu.RLock()
+	p, ok := r.platforms[platform]
+	r.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown platform: %s", platform)
+	}
+	if ms, ok := p.(MediaSender); ok {
+		// Each message waits for the send limit just before it goes out
+		return ms.SendMedia(chatID, files, func() error {
+			return r.throttle.wait(r.outbox.ctx, platform, chatID)
+		})
+	}
+	fs, ok := p.(FileSender)
+	if !ok {
+		return fmt.Errorf("%s does not support file attachments", platform)
+	}
+	for _, f := range files {
+		if err := r.throttle.wait(r.outbox.ctx, platform, chatID); err != nil {
+			return err
+		}
+		if err := fs.SendFile(chatID, f.Name, f.Data); err != nil {
+			return err
+		}
+		if f.Caption != "" {
+			// A queued caption is still sent; carry on with the files
+			if err := r.Send(platform, chatID, f.Caption); err != nil && !errors.Is(err, ErrSendQueued) {
+				return err
+			}
+		}
+	}
+	return nil
+}
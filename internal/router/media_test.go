@@ -0,0 +1,71 @@
+package router
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+// mediaPlatform records the files sent through MediaSender.
+type mediaPlatform struct {
+	recordPlatform
+}
+
+func (p *mediaPlatform) SendMedia(chatID string, files []MediaFile, wait func() error) error {
+	for _, f := range files {
+		if err := wait(); err != nil {
+			return err
+		}
+		_ = p.Send(chatID, "file "+f.Name)
+	}
+	return nil
+}
+
+// filePlatform records the files sent through FileSender.
+type filePlatform struct {
+	recordPlatform
+}
+
+func (p *filePlatform) SendFile(chatID, name string, _ []byte) error {
+	return p.Send(chatID, "file "+name)
+}
+
+func TestSendMediaWaitsPerFile(t *testing.T) {
+	r := NewRouter(nil, nil, nil, nil, nil, slog.Default())
+	p := &mediaPlatform{recordPlatform{name: "telegram"}}
+	r.Register(p)
+
+	// One message per second to a Telegram chat: after shutdown the first
+	// file still has its slot, the second's wait fails
+	r.outbox.cancel()
+	err := r.SendMedia("telegram", "1", []MediaFile{{Name: "a.png"}, {Name: "b.png"}})
+	if err == nil {
+		t.Error("SendMedia succeeded though the second file could not wait for its slot")
+	}
+	if got := p.messages(); !slices.Equal(got, []string{"1: file a.png"}) {
+		t.Errorf("sent %v, want the first file before the failed wait", got)
+	}
+}
+
+func TestSendMediaFileSenderFallback(t *testing.T) {
+	r := NewRouter(nil, nil, nil, nil, nil, slog.Default())
+	p := &filePlatform{recordPlatform{name: "test"}}
+	r.Register(p)
+	r.Register(&recordPlatform{name: "textonly"})
+
+	files := []MediaFile{{Name: "a.pdf", Caption: "first"}, {Name: "b.pdf"}}
+	if err := r.SendMedia("test", "1", files); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1: file a.pdf", "1: first", "1: file b.pdf"}
+	if got := p.messages(); !slices.Equal(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+
+	if err := r.SendMedia("textonly", "1", files); err == nil {
+		t.Error("SendMedia succeeded on a platform without file support")
+	}
+	if err := r.SendMedia("nowhere", "1", files); err == nil {
+		t.Error("SendMedia succeeded on an unknown platform")
+	}
+}
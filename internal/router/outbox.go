@@ -0,0 +1,245 @@
+// Retrying outbound sends and dead-lettering the ones that keep failing
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kusa/magabot/internal/storage"
+)
+
+const (
+	// DefaultSendRetries is how many times a failed Send is retried when
+	// platforms.send_retries is not set.
+	DefaultSendRetries = 3
+
+	// DefaultSendBackoff is the wait before the first retry when
+	// platforms.send_backoff is not set; it doubles after each attempt.
+	DefaultSendBackoff = 2 * time.Second
+
+	// replayInterval is how often dead letters queued by
+	// `magabot deadletter replay` are picked up.
+	replayInterval = time.Minute
+)
+
+// ErrSendQueued is returned by Send when a message failed to send and is
+// being retried in the background.
+var ErrSendQueued = errors.New("send failed, queued for retry")
+
+// PartialSendError is returned by a Platform's Send that delivered some of
+// a long message's chunks before failing. Unsent holds the text of the rest,
+// each part small enough for one message, so a retry doesn't repeat what
+// the chat already got.
+type PartialSendError struct {
+	Unsent []string
+	Err    error
+}
+
+func (e *PartialSendError) Error() string {
+	return fmt.Sprintf("%v (%d parts unsent)", e.Err, len(e.Unsent))
+}
+
+func (e *PartialSendError) Unwrap() error { return e.Err }
+
+// outbox retries failed sends in the background and records messages that
+// exhaust their retries as dead letters.
+type outbox struct {
+	retries int
+	backoff time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup // retries and the replay loop
+}
+
+func newOutbox(retries int, backoff time.Duration) *outbox {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &outbox{retries: retries, backoff: backoff, ctx: ctx, cancel: cancel}
+}
+
+// Send sends a message to a specific platform and chat. A failed send is
+// retried in the background with backoff and, once retries run out, kept in
+// the dead-letter log; Send then returns an error wrapping ErrSendQueued.
+// Other errors mean the message was not queued for retry.
+func (r *Router) Send(platform, chatID, message string) error {
+	r.mu.RLock()
+	p, ok := r.platforms[platform]
+	r.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown platform: %s", platform)
+	}
+
+	// Wait for the platform's send limit; on shutdown the message is kept
+	if err := r.throttle.wait(r.outbox.ctx, platform, chatID); err != nil {
+		r.deadLetter(platform, chatID, message, err, 0)
+		return err
+	}
+	err := p.Send(chatID, message)
+	if err == nil {
+		return nil
+	}
+	unsent := unsentParts(message, err)
+	if r.outbox.retries <= 0 || r.outbox.ctx.Err() != nil {
+		r.deadLetter(platform, chatID, joinParts(unsent), err, 1)
+		return err
+	}
+
+	r.logger.Warn("send failed, retrying", "platform", platform, "unsent_parts", len(unsent), "error", err)
+	r.outbox.wg.Add(1)
+	go func() {
+		defer r.outbox.wg.Done()
+		r.retrySend(p, chatID, unsent, err)
+	}()
+	return fmt.Errorf("%w: %w", ErrSendQueued, err)
+}
+
+// retrySend retries the unsent parts of a failed send until they are all
+// sent, retries run out or the router shuts down, dead-lettering what is
+// left in the latter two cases.
+func (r *Router) retrySend(p Platform, chatID string, unsent []string, err error) {
+	delay := r.outbox.backoff
+	attempts := 1
+	for attempts <= r.outbox.retries {
+		select {
+		case <-r.outbox.ctx.Done():
+			r.deadLetter(p.Name(), chatID, joinParts(unsent), err, attempts)
+			return
+		case <-time.After(delay):
+		}
+		if r.throttle.wait(r.outbox.ctx, p.Name(), chatID) != nil {
+			r.deadLetter(p.Name(), chatID, joinParts(unsent), err, attempts)
+			return
+		}
+		attempts++
+		if unsent, err = sendParts(p, chatID, unsent); err == nil {
+			r.logger.Info("send succeeded after retry", "platform", p.Name(), "attempts", attempts)
+			return
+		}
+		delay *= 2
+	}
+	r.deadLetter(p.Name(), chatID, joinParts(unsent), err, attempts)
+}
+
+// sendParts sends parts in order, returning the ones still unsent when a
+// send fails.
+func sendParts(p Platform, chatID string, parts []string) ([]string, error) {
+	for i, part := range parts {
+		if err := p.Send(chatID, part); err != nil {
+			return slices.Concat(unsentParts(part, err), parts[i+1:]), err
+		}
+	}
+	return nil, nil
+}
+
+// unsentParts returns what is left to send of message after Send failed
+// with err: the chunks a *PartialSendError lists, else the whole message.
+func unsentParts(message string, err error) []string {
+	var pe *PartialSendError
+	if errors.As(err, &pe) && len(pe.Unsent) > 0 {
+		return pe.Unsent
+	}
+	return []string{message}
+}
+
+// joinParts joins unsent parts back into one message for the dead-letter
+// log; replaying it splits it again.
+func joinParts(parts []string) string {
+	return strings.Join(parts, "\n\n")
+}
+
+// deadLetter stores an undeliverable message, encrypted if a vault is set.
+func (r *Router) deadLetter(platform, chatID, message string, sendErr error, attempts int) {
+	r.logger.Error("send failed, message dead-lettered", "platform", platform, "attempts", attempts, "error", sendErr)
+	if r.store == nil {
+		return
+	}
+	content := message
+	if r.vault != nil {
+		encrypted, err := r.vault.Encrypt([]byte(message))
+		if err != nil {
+			r.logger.Error("encrypt dead letter failed", "error", err)
+			return
+		}
+		content = encrypted
+	}
+	if err := r.store.SaveDeadLetter(&storage.DeadLetter{
+		Platform: platform,
+		ChatID:   chatID,
+		Content:  content,
+		Error:    sendErr.Error(),
+		Attempts: attempts,
+	}); err != nil {
+		r.logger.Error("save dead letter failed", "error", err)
+	}
+}
+
+// replayLoop resends queued dead letters until the router shuts down.
+func (r *Router) replayLoop() {
+	defer r.outbox.wg.Done()
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+	for {
+		r.ReplayDeadLetters()
+		select {
+		case <-r.outbox.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReplayDeadLetters sends each dead letter queued for replay once, removing
+// the ones delivered. It returns how many were sent.
+func (r *Router) ReplayDeadLetters() int {
+	if r.store == nil {
+		return 0
+	}
+	pending, err := r.store.PendingReplays()
+	if err != nil {
+		r.logger.Error("load dead letters failed", "error", err)
+		return 0
+	}
+
+	sent := 0
+	for _, dl := range pending {
+		if err := r.replay(dl); err != nil {
+			r.logger.Warn("dead letter replay failed", "id", dl.ID, "platform", dl.Platform, "error", err)
+			if err := r.store.FailDeadLetterReplay(dl.ID, err.Error()); err != nil {
+				r.logger.Error("update dead letter failed", "id", dl.ID, "error", err)
+			}
+			continue
+		}
+		if err := r.store.DeleteDeadLetter(dl.ID); err != nil {
+			r.logger.Error("delete dead letter failed", "id", dl.ID, "error", err)
+		}
+		sent++
+	}
+	return sent
+}
+
+func (r *Router) replay(dl storage.DeadLetter) error {
+	message := dl.Content
+	if r.vault != nil {
+		plain, err := r.vault.Decrypt(dl.Content)
+		if err != nil {
+			return fmt.Errorf("decrypt: %w", err)
+		}
+		message = string(plain)
+	}
+
+	r.mu.RLock()
+	p, ok := r.platforms[dl.Platform]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown platform: %s", dl.Platform)
+	}
+	if err := r.throttle.wait(r.outbox.ctx, dl.Platform, dl.ChatID); err != nil {
+		return err
+	}
+	return p.Send(dl.ChatID, message)
+}
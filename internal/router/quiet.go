@@ -0,0 +1,111 @@
+// Holding proactive messages back during quiet hours
+package router
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kusa/magabot/internal/cron"
+)
+
+// errQuietHours is recorded on messages still held for quiet hours at
+// shutdown.
+var errQuietHours = errors.New("held for quiet hours")
+
+type heldMessage struct {
+	platform, chatID, message string
+}
+
+// quietHours holds proactive messages while its window is active and sends
+// them when it ends.
+type quietHours struct {
+	mu    sync.Mutex
+	hours *cron.QuietHours // nil = off
+	held  []heldMessage
+	timer *time.Timer // flushes held when the window ends
+}
+
+// SetQuietHours sets the window during which Notify holds messages back.
+// nil turns quiet hours off and sends whatever is held.
+func (r *Router) SetQuietHours(q *cron.QuietHours) {
+	r.quiet.mu.Lock()
+	r.quiet.hours = q
+	r.quiet.mu.Unlock()
+	if q == nil {
+		r.flushHeld()
+	}
+}
+
+// Notify sends a proactive message, one not answering a user such as a
+// cron post or a background task notice. During quiet hours for the
+// platform it is held and sent when they end. Replies to users go through
+// Send and are never held.
+func (r *Router) Notify(platform, chatID, message string) error {
+	if r.Hold(platform, chatID, message) {
+		return nil
+	}
+	return r.Send(platform, chatID, message)
+}
+
+// Hold queues a proactive message for a registered platform when quiet
+// hours are on for it, reporting whether it did. Held messages go out
+// through Send when the window ends, for senders that bypass Notify.
+func (r *Router) Hold(platform, chatID, message string) bool {
+	r.mu.RLock()
+	_, ok := r.platforms[platform]
+	r.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	q := &r.quiet
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	if q.hours == nil || !q.hours.Applies(platform) || !q.hours.Active(now) {
+		return false
+	}
+	q.held = append(q.held, heldMessage{platform, chatID, message})
+	if q.timer == nil {
+		q.timer = time.AfterFunc(q.hours.Ends(now).Sub(now), r.flushHeld)
+	}
+	r.logger.Debug("message held for quiet hours", "platform", platform)
+	return true
+}
+
+// flushHeld sends the messages held for quiet hours.
+func (r *Router) flushHeld() {
+	held := r.takeHeld()
+	if len(held) == 0 {
+		return
+	}
+	r.logger.Info("quiet hours over, sending held messages", "count", len(held))
+	for _, m := range held {
+		if err := r.Send(m.platform, m.chatID, m.message); err != nil {
+			r.logger.Warn("send held message failed", "platform", m.platform, "error", err)
+		}
+	}
+}
+
+// deadLetterHeld keeps messages still held at shutdown in the dead-letter
+// log, from where `magabot deadletter replay` can send them.
+func (r *Router) deadLetterHeld() {
+	for _, m := range r.takeHeld() {
+		r.deadLetter(m.platform, m.chatID, m.message, errQuietHours, 0)
+	}
+}
+
+// takeHeld empties the held queue and stops its flush timer.
+func (r *Router) takeHeld() []heldMessage {
+	q := &r.quiet
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	held := q.held
+	q.held = nil
+	return held
+}
@@ -0,0 +1,128 @@
+package router
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/cron"
+	"github.com/kusa/magabot/internal/storage"
+)
+
+// recordPlatform records the messages sent to it.
+type recordPlatform struct {
+	name string
+	mu   sync.Mutex
+	sent []string
+}
+
+func (p *recordPlatform) Name() string                   { return p.name }
+func (p *recordPlatform) Start(context.Context) error    { return nil }
+func (p *recordPlatform) Stop() error                    { return nil }
+func (p *recordPlatform) SendVoice(string, []byte) error { return nil }
+func (p *recordPlatform) SetHandler(MessageHandler)      {}
+func (p *recordPlatform) Send(chatID, message string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, chatID+": "+message)
+	return nil
+}
+
+func (p *recordPlatform) messages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.sent)
+}
+
+// quietNow returns quiet hours active from an hour ago to an hour from now.
+func quietNow(t *testing.T, platforms ...string) *cron.QuietHours {
+	t.Helper()
+	now := time.Now()
+	q, err := cron.ParseQuietHours(now.Add(-time.Hour).Format("15:04"), now.Add(time.Hour).Format("15:04"), "", platforms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func newQuietRouter(t *testing.T, store *storage.Store) (*Router, *recordPlatform, *recordPlatform) {
+	t.Helper()
+	r := NewRouter(store, nil, nil, nil, nil, slog.Default())
+	tg, dc := &recordPlatform{name: "telegram"}, &recordPlatform{name: "discord"}
+	r.Register(tg)
+	r.Register(dc)
+	return r, tg, dc
+}
+
+func TestNotifyHoldsDuringQuietHours(t *testing.T) {
+	r, tg, dc := newQuietRouter(t, nil)
+	r.SetQuietHours(quietNow(t, "telegram"))
+
+	if err := r.Notify("telegram", "1", "cron post"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Notify("discord", "2", "not quiet here"); err != nil {
+		t.Fatal(err)
+	}
+	if r.Hold("slack", "3", "unknown platform") {
+		t.Error("Hold held a message for an unregistered platform")
+	}
+	// Replies go through Send and are never held
+	if err := r.Send("telegram", "1", "reply"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := tg.messages(); !slices.Equal(got, []string{"1: reply"}) {
+		t.Errorf("telegram got %v during quiet hours, want only the reply", got)
+	}
+	if got := dc.messages(); !slices.Equal(got, []string{"2: not quiet here"}) {
+		t.Errorf("discord got %v, want the notice sent", got)
+	}
+
+	r.flushHeld()
+	if got := tg.messages(); !slices.Equal(got, []string{"1: reply", "1: cron post"}) {
+		t.Errorf("telegram got %v after the window, want the held post", got)
+	}
+	r.flushHeld()
+	if got := tg.messages(); len(got) != 2 {
+		t.Errorf("a second flush sent %v again", got)
+	}
+}
+
+func TestSetQuietHoursOffFlushes(t *testing.T) {
+	r, tg, _ := newQuietRouter(t, nil)
+	r.SetQuietHours(quietNow(t))
+	_ = r.Notify("telegram", "1", "task done")
+	r.SetQuietHours(nil)
+	if got := tg.messages(); !slices.Equal(got, []string{"1: task done"}) {
+		t.Errorf("telegram got %v, want the held notice sent when quiet hours go off", got)
+	}
+}
+
+func TestShutdownDeadLettersHeld(t *testing.T) {
+	store, err := storage.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+	r, tg, _ := newQuietRouter(t, store)
+	r.SetQuietHours(quietNow(t))
+	_ = r.Notify("telegram", "1", "night post")
+
+	r.Shutdown(context.Background())
+
+	if got := tg.messages(); len(got) != 0 {
+		t.Errorf("telegram got %v, want nothing sent at shutdown", got)
+	}
+	letters, err := store.ListDeadLetters(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Content != "night post" || letters[0].Error != errQuietHours.Error() {
+		t.Errorf("dead letters = %+v, want the held post", letters)
+	}
+}
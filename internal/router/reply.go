@@ -0,0 +1,149 @@
+// Structured replies with buttons
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ButtonPrefix marks message text that came from a button press rather than
+// typed input: a press on a button with Data "yes" arrives as "btn:yes".
+const ButtonPrefix = "btn:"
+
+// Button is a choice offered with a reply. Data is what the handler receives
+// (prefixed with ButtonPrefix) when the user picks it.
+type Button struct {
+	Text string
+	Data string
+}
+
+// Reply is a handler response with optional rows of buttons.
+type Reply struct {
+	Text    string
+	Buttons [][]Button
+}
+
+// ButtonSender is implemented by platforms that render buttons natively.
+// Other platforms get a numbered text fallback the user answers by number.
+type ButtonSender interface {
+	// SendButtons sends text with the reply's buttons attached
+	SendButtons(chatID string, reply Reply) error
+}
+
+// Respond attaches reply's buttons to the response for msg and returns its
+// text, so a handler can end with `return msg.Respond(reply), nil`.
+func (m *Message) Respond(reply Reply) string {
+	m.Buttons = reply.Buttons
+	return reply.Text
+}
+
+// ButtonData reports whether text is a button press and returns its data.
+func ButtonData(text string) (string, bool) {
+	if !strings.HasPrefix(text, ButtonPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(text, ButtonPrefix), true
+}
+
+// flatten returns the buttons in display order.
+func (r Reply) flatten() []Button {
+	var all []Button
+	for _, row := range r.Buttons {
+		all = append(all, row...)
+	}
+	return all
+}
+
+// FallbackText renders the reply as text with a numbered list of choices.
+func (r Reply) FallbackText() string {
+	buttons := r.flatten()
+	if len(buttons) == 0 {
+		return r.Text
+	}
+	var sb strings.Builder
+	sb.WriteString(r.Text)
+	if r.Text != "" {
+		sb.WriteString("\n\n")
+	}
+	for i, b := range buttons {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, b.Text)
+	}
+	sb.WriteString("\nReply with a number to choose.")
+	return sb.String()
+}
+
+// choiceCache remembers the buttons last offered as text in each chat so a
+// numeric answer can be turned back into a button press.
+type choiceCache struct {
+	mu      sync.Mutex
+	pending map[string][]Button // platform:chatID → offered buttons
+}
+
+func newChoiceCache() *choiceCache {
+	return &choiceCache{pending: make(map[string][]Button)}
+}
+
+// offer records the choices shown in a chat, replacing earlier ones.
+func (c *choiceCache) offer(key string, buttons []Button) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(buttons) == 0 {
+		delete(c.pending, key)
+		return
+	}
+	c.pending[key] = buttons
+}
+
+// resolve maps a numeric answer to its button press text. Any message in
+// the chat clears the pending choices, so numbers are only read once.
+func (c *choiceCache) resolve(key, text string) (string, bool) {
+	c.mu.Lock()
+	buttons, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || n < 1 || n > len(buttons) {
+		return "", false
+	}
+	return ButtonPrefix + buttons[n-1].Data, true
+}
+
+// renderButtons turns msg's buttons into a text fallback on platforms that
+// cannot show them, returning the response to send.
+func (r *Router) renderButtons(msg *Message, response string) string {
+	if len(msg.Buttons) == 0 {
+		return response
+	}
+	r.mu.RLock()
+	_, native := r.platforms[msg.Platform].(ButtonSender)
+	r.mu.RUnlock()
+	if native {
+		return response
+	}
+	reply := Reply{Text: response, Buttons: msg.Buttons}
+	r.choices.offer(msg.Platform+":"+msg.ChatID, reply.flatten())
+	msg.Buttons = nil
+	return reply.FallbackText()
+}
+
+// SendReply sends a reply with buttons to a specific platform and chat,
+// falling back to numbered text where buttons are unsupported.
+func (r *Router) SendReply(platform, chatID string, reply Reply) error {
+	r.mu.RLock()
+	p, ok := r.platforms[platform]
+	r.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown platform: %s", platform)
+	}
+	if bs, ok := p.(ButtonSender); ok && len(reply.Buttons) > 0 {
+		return bs.SendButtons(chatID, reply)
+	}
+	r.choices.offer(platform+":"+chatID, reply.flatten())
+	return p.Send(chatID, reply.FallbackText())
+}
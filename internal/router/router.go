@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +14,8 @@ import (
 	"github.com/kusa/magabot/internal/hooks"
 	"github.com/kusa/magabot/internal/security"
 	"github.com/kusa/magabot/internal/storage"
+	"github.com/kusa/magabot/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Platform interface for chat platforms
@@ -35,6 +39,12 @@ type Platform interface {
 	SetHandler(handler MessageHandler)
 }
 
+// FileSender is implemented by platforms that can send file attachments.
+type FileSender interface {
+	// SendFile sends data as a file named name
+	SendFile(chatID, name string, data []byte) error
+}
+
 // ReplyContext holds context about the message being replied to
 type ReplyContext struct {
 	Text     string // Text content of the quoted message
@@ -44,21 +54,52 @@ type ReplyContext struct {
 
 // Message represents an incoming message
 type Message struct {
+	ID             string // Platform message ID (or webhook delivery ID); used for deduplication
+	RequestID      string // Correlates the message's logs, LLM calls and hooks; set by the router when the platform has none
 	Platform       string
 	ChatID         string
+	ThreadID       string // Thread root within the chat (Slack thread ts); empty when not threaded
 	UserID         string
 	Username       string
+	Locale         string // Client language tag (e.g. "id", "en-US") when the platform reports it
 	Text           string
 	Media          []string      // File paths for images/voice/documents
 	ReplyTo        *ReplyContext // Quoted/replied-to message context
 	Timestamp      time.Time
 	Raw            interface{}       // Platform-specific raw message
 	StreamCallback func(text string) // Called with accumulated text during LLM streaming; nil = no streaming
+
+	// Provider and Model are set by the handler when an LLM produced the
+	// response; command replies leave them empty and get no feedback prompt.
+	Provider string
+	Model    string
+
+	// InputTokens and OutputTokens are set by the handler to the LLM tokens
+	// the response took, counted against the daily quota.
+	InputTokens  int
+	OutputTokens int
+
+	// OnSent is set by the router when feedback is collected for the response.
+	// Platforms call it with the chat and message ID of the last message they
+	// sent for the response; nil means there is nothing to report.
+	OnSent func(chatID, messageID string)
+
+	// Buttons are set by the handler (see Respond) to offer choices with the
+	// response. Platforms that implement ButtonSender render them.
+	Buttons [][]Button
 }
 
 // MessageHandler handles incoming messages
 type MessageHandler func(ctx context.Context, msg *Message) (string, error)
 
+// NewUserHandler is called with the first message of a user without
+// access and returns the reply they get.
+type NewUserHandler func(msg *Message) string
+
+// QuotaHandler returns the reply a user who used up their daily quota gets;
+// resetAt is when the quota resets.
+type QuotaHandler func(msg *Message, resetAt time.Time) string
+
 // Router routes messages between platforms
 type Router struct {
 	platforms    map[string]Platform
@@ -71,13 +112,48 @@ type Router struct {
 	authAttempts *security.AuthAttempts
 	auditLogger  *security.AuditLogger
 	hooks        *hooks.Manager
+	dedup        *dedupCache
+	choices      *choiceCache
+	outbox       *outbox
+	throttle     *sendThrottle
 	handler      MessageHandler
+	newUser      NewUserHandler
+	quota        *security.DailyQuota // nil = no daily quota
+	quotaReply   QuotaHandler
+	quiet        quietHours
 	logger       *slog.Logger
 	mu           sync.RWMutex
+
+	startedMu sync.Mutex
+	started   map[string]bool // platforms whose Start returned successfully
+
+	drainMu  sync.Mutex
+	draining bool           // no new messages accepted
+	active   int            // messages being handled
+	inflight sync.WaitGroup // tracks active
 }
 
 // NewRouter creates a new router
 func NewRouter(store *storage.Store, vault *security.Vault, cfg *config.Config, authorizer *security.Authorizer, rateLimiter *security.RateLimiter, logger *slog.Logger) *Router {
+	dedupWindow := DefaultDedupWindow
+	sendRetries, sendBackoff := DefaultSendRetries, DefaultSendBackoff
+	if cfg != nil {
+		if cfg.Platforms.DedupWindow != nil {
+			dedupWindow = cfg.Platforms.DedupWindow.Duration()
+		}
+		if cfg.Platforms.SendRetries != nil {
+			sendRetries = *cfg.Platforms.SendRetries
+		}
+		if cfg.Platforms.SendBackoff != nil {
+			sendBackoff = cfg.Platforms.SendBackoff.Duration()
+		}
+	}
+	sendLimits := maps.Clone(DefaultSendLimits)
+	if cfg != nil {
+		for platform, l := range cfg.Platforms.SendLimits {
+			sendLimits[platform] = SendLimit{PerSecond: l.PerSecond, PerChat: l.PerChat}
+		}
+	}
 	return &Router{
 		platforms:    make(map[string]Platform),
 		store:        store,
@@ -87,7 +163,12 @@ func NewRouter(store *storage.Store, vault *security.Vault, cfg *config.Config,
 		rateLimiter:  rateLimiter,
 		sessionMgr:   security.NewSessionManager(),
 		authAttempts: security.NewAuthAttempts(),
+		dedup:        newDedupCache(dedupWindow),
+		choices:      newChoiceCache(),
+		outbox:       newOutbox(sendRetries, sendBackoff),
+		throttle:     newSendThrottle(sendLimits),
 		logger:       logger,
+		started:      make(map[string]bool),
 	}
 }
 
@@ -105,6 +186,23 @@ func (r *Router) SetHooks(h *hooks.Manager) {
 	r.hooks = h
 }
 
+// SetNewUserHandler sets what a user without access is told on their first
+// message. Without one they are denied silently.
+func (r *Router) SetNewUserHandler(h NewUserHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.newUser = h
+}
+
+// SetDailyQuota caps each user's messages and tokens per day. Admins are
+// exempt; users over the quota get reply's answer instead of the handler's.
+func (r *Router) SetDailyQuota(q *security.DailyQuota, reply QuotaHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quota = q
+	r.quotaReply = reply
+}
+
 // Register registers a platform
 func (r *Router) Register(p Platform) {
 	r.mu.Lock()
@@ -112,6 +210,9 @@ func (r *Router) Register(p Platform) {
 
 	r.platforms[p.Name()] = p
 	p.SetHandler(r.handleMessage)
+	if rp, ok := p.(Reactor); ok {
+		rp.SetReactionHandler(r.handleReaction)
+	}
 }
 
 // SetHandler sets the global message handler
@@ -121,7 +222,7 @@ func (r *Router) SetHandler(h MessageHandler) {
 	r.handler = h
 }
 
-// Start starts all registered platforms
+// Start starts all registered platforms and the dead-letter replay loop
 func (r *Router) Start(ctx context.Context) error {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -131,37 +232,105 @@ func (r *Router) Start(ctx context.Context) error {
 		if err := p.Start(ctx); err != nil {
 			return fmt.Errorf("start %s: %w", name, err)
 		}
+		r.setStarted(name, true)
 	}
 
+	r.outbox.wg.Add(1)
+	go r.replayLoop()
+
 	return nil
 }
 
-// Stop stops all platforms
+// Stop drains in-flight messages for up to DefaultDrainTimeout, then stops
+// all platforms.
 func (r *Router) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDrainTimeout)
+	defer cancel()
+	if n := r.Shutdown(ctx); n > 0 {
+		r.logger.Warn("stopped with messages still in flight", "active", n)
+	}
+}
+
+// stopPlatforms stops all platforms
+func (r *Router) stopPlatforms() {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	for name, p := range r.platforms {
 		r.logger.Info("stopping platform", "platform", name)
+		r.setStarted(name, false)
 		if err := p.Stop(); err != nil {
 			r.logger.Error("stop platform failed", "platform", name, "error", err)
 		}
 	}
 }
 
-// handleMessage processes incoming messages
+// onboard returns the new user handler's reply the first time a user
+// without access writes, and "" after that or when there is no handler.
+func (r *Router) onboard(msg *Message, hashedUser string) string {
+	r.mu.RLock()
+	newUser := r.newUser
+	r.mu.RUnlock()
+	if newUser == nil {
+		return ""
+	}
+
+	first, err := r.store.MarkSeen(msg.Platform, hashedUser)
+	if err != nil {
+		r.logger.Warn("mark user seen failed", "platform", msg.Platform, "error", err)
+		return ""
+	}
+	if !first {
+		return ""
+	}
+	r.logger.Info("new user asked for access", "platform", msg.Platform, "user_hash", hashedUser)
+	return newUser(msg)
+}
+
+// handleMessage processes incoming messages in a router.handle span,
+// carrying the message's request ID in ctx
 func (r *Router) handleMessage(ctx context.Context, msg *Message) (string, error) {
+	if msg.RequestID == "" {
+		msg.RequestID = telemetry.NewRequestID()
+	}
+	ctx = telemetry.WithRequestID(ctx, msg.RequestID)
+	ctx, span := telemetry.Start(ctx, "router.handle",
+		attribute.String("platform", msg.Platform),
+		attribute.String("request_id", msg.RequestID),
+	)
+	defer span.End()
+
+	response, err := r.process(ctx, msg)
+	telemetry.RecordError(span, err)
+	if msg.Provider != "" {
+		span.SetAttributes(attribute.String("llm.provider", msg.Provider), attribute.String("llm.model", msg.Model))
+	}
+	return response, err
+}
+
+// process checks access and limits, then passes msg to the handler
+func (r *Router) process(ctx context.Context, msg *Message) (string, error) {
+	if !r.begin() {
+		return "", ErrDraining
+	}
+	defer r.end()
+
 	userKey := fmt.Sprintf("%s:%s", msg.Platform, msg.UserID)
 	hashedUser := security.HashUserID(msg.Platform, msg.UserID)
+	logger := r.logger.With("request_id", msg.RequestID)
+	var audit *security.AuditLogger
+	if r.auditLogger != nil {
+		audit = r.auditLogger.WithRequestID(msg.RequestID)
+	}
 
 	// Check account lockout (A07 fix)
 	if r.authAttempts.IsLocked(userKey) {
-		r.logger.Warn("account locked",
+		logger.Warn("account locked",
 			"platform", msg.Platform,
 			"user_hash", hashedUser,
 		)
-		if r.auditLogger != nil {
-			r.auditLogger.LogAuthLockout(msg.Platform, msg.UserID)
+		if audit != nil {
+			audit.LogAuthLockout(msg.Platform, msg.UserID)
 		}
 		return "", security.ErrAccountLocked
 	}
@@ -175,7 +344,7 @@ func (r *Router) handleMessage(ctx context.Context, msg *Message) (string, error
 		isAllowed = r.authorizer.IsAuthorized(msg.Platform, msg.UserID)
 	}
 	if !isAllowed {
-		r.logger.Warn("unauthorized user",
+		logger.Warn("unauthorized user",
 			"platform", msg.Platform,
 			"user_hash", hashedUser,
 		)
@@ -183,10 +352,13 @@ func (r *Router) handleMessage(ctx context.Context, msg *Message) (string, error
 
 		// Track failed attempts (A07 fix)
 		r.authAttempts.RecordFailure(userKey)
-		if r.auditLogger != nil {
-			r.auditLogger.LogAuthFailure(msg.Platform, msg.UserID, "not in allowlist")
+		if audit != nil {
+			audit.LogAuthFailure(msg.Platform, msg.UserID, "not in allowlist")
 		}
 
+		if reply := r.onboard(msg, hashedUser); reply != "" {
+			return reply, nil
+		}
 		return "", security.ErrNotAuthorized
 	}
 
@@ -196,28 +368,60 @@ func (r *Router) handleMessage(ctx context.Context, msg *Message) (string, error
 	// Update/create session (A07 fix)
 	r.sessionMgr.GetOrCreate(msg.Platform, msg.UserID)
 
+	// Drop redeliveries before they count against rate limits or reach the LLM
+	if r.dedup.duplicate(dedupKey(msg)) {
+		logger.Debug("duplicate message dropped", "platform", msg.Platform, "user_hash", hashedUser, "message_id", msg.ID)
+		return "", nil
+	}
+
+	// A number answering a text-rendered button list counts as a press
+	if pressed, ok := r.choices.resolve(msg.Platform+":"+msg.ChatID, msg.Text); ok {
+		msg.Text = pressed
+	}
+
 	// Rate limit check
-	isCommand := len(msg.Text) > 0 && msg.Text[0] == '/'
+	prefix := "/"
+	if r.cfg != nil {
+		prefix = r.cfg.CommandPrefix(msg.Platform)
+	}
+	isCommand := strings.HasPrefix(msg.Text, prefix)
 	if isCommand {
 		if !r.rateLimiter.AllowCommand(userKey) {
-			r.logger.Warn("rate limited (command)", "user_hash", hashedUser)
-			if r.auditLogger != nil {
-				r.auditLogger.LogRateLimited(msg.Platform, msg.UserID)
+			logger.Warn("rate limited (command)", "user_hash", hashedUser)
+			if audit != nil {
+				audit.LogRateLimited(msg.Platform, msg.UserID)
 			}
 			return "", security.ErrRateLimited
 		}
 	} else {
 		if !r.rateLimiter.AllowMessage(userKey) {
-			r.logger.Warn("rate limited (message)", "user_hash", hashedUser)
-			if r.auditLogger != nil {
-				r.auditLogger.LogRateLimited(msg.Platform, msg.UserID)
+			logger.Warn("rate limited (message)", "user_hash", hashedUser)
+			if audit != nil {
+				audit.LogRateLimited(msg.Platform, msg.UserID)
 			}
 			return "", security.ErrRateLimited
 		}
 	}
 
+	// Daily budget for messages that may reach the LLM; admins are exempt
+	quota, quotaReply := r.dailyQuota(msg, isCommand)
+	if quota != nil {
+		ok, resetAt, err := quota.Allow(hashedUser)
+		if err != nil {
+			logger.Warn("load daily quota failed", "user_hash", hashedUser, "error", err)
+		}
+		if !ok {
+			logger.Info("daily quota reached", "user_hash", hashedUser)
+			_ = r.store.AuditLog(msg.Platform, hashedUser, "daily_quota", "")
+			if quotaReply == nil {
+				return "", security.ErrRateLimited
+			}
+			return quotaReply(msg, resetAt), nil
+		}
+	}
+
 	// Log incoming message (encrypted if vault available, plaintext otherwise)
-	r.encryptAndStore(msg.Platform, msg.ChatID, hashedUser, msg.Username, msg.Text, msg.Timestamp, "in")
+	r.encryptAndStore(msg.Platform, msg.ChatID, hashedUser, msg.Username, msg.Text, msg.Timestamp, "in", "")
 
 	// Fire pre_message hook (can block or modify the message text)
 	r.mu.RLock()
@@ -226,13 +430,14 @@ func (r *Router) handleMessage(ctx context.Context, msg *Message) (string, error
 
 	if hooksMgr != nil && hooksMgr.HasHooks(hooks.PreMessage) {
 		result := hooksMgr.Fire(hooks.PreMessage, &hooks.EventData{
-			Platform: msg.Platform,
-			UserID:   msg.UserID,
-			ChatID:   msg.ChatID,
-			Text:     msg.Text,
+			RequestID: msg.RequestID,
+			Platform:  msg.Platform,
+			UserID:    msg.UserID,
+			ChatID:    msg.ChatID,
+			Text:      msg.Text,
 		})
 		if result.Blocked {
-			r.logger.Info("message blocked by pre_message hook", "user_hash", hashedUser)
+			logger.Info("message blocked by pre_message hook", "user_hash", hashedUser)
 			return "", nil
 		}
 		if result.Output != "" {
@@ -249,30 +454,62 @@ func (r *Router) handleMessage(ctx context.Context, msg *Message) (string, error
 		return "", nil
 	}
 
+	return r.respond(ctx, msg, handler, quota, 1, logger)
+}
+
+// dailyQuota returns the daily quota msg counts against and the reply for
+// users over it. Commands and admins are exempt (nil quota).
+func (r *Router) dailyQuota(msg *Message, isCommand bool) (*security.DailyQuota, QuotaHandler) {
+	r.mu.RLock()
+	quota, quotaReply := r.quota, r.quotaReply
+	r.mu.RUnlock()
+	if isCommand || (r.cfg != nil && r.cfg.IsPlatformAdmin(msg.Platform, msg.UserID)) {
+		return nil, quotaReply
+	}
+	return quota, quotaReply
+}
+
+// respond runs handler for msg and post-processes its response: charging
+// messages and the tokens used to quota, firing the on_error and
+// post_response hooks, and logging the reply.
+func (r *Router) respond(ctx context.Context, msg *Message, handler MessageHandler, quota *security.DailyQuota, messages int64, logger *slog.Logger) (string, error) {
+	hashedUser := security.HashUserID(msg.Platform, msg.UserID)
+	r.mu.RLock()
+	hooksMgr := r.hooks
+	r.mu.RUnlock()
+
 	response, err := handler(ctx, msg)
+	if quota != nil {
+		if err := quota.Charge(hashedUser, messages, int64(msg.InputTokens+msg.OutputTokens)); err != nil {
+			logger.Warn("save daily quota failed", "user_hash", hashedUser, "error", err)
+		}
+	}
 	if err != nil {
-		r.logger.Error("handler error", "error", err, "user_hash", hashedUser)
+		logger.Error("handler error", "error", err, "user_hash", hashedUser)
 		// Fire on_error hook
 		if hooksMgr != nil {
 			hooksMgr.FireAsync(hooks.OnError, &hooks.EventData{
-				Platform: msg.Platform,
-				UserID:   msg.UserID,
-				ChatID:   msg.ChatID,
-				Text:     msg.Text,
-				Error:    err.Error(),
+				RequestID: msg.RequestID,
+				Platform:  msg.Platform,
+				UserID:    msg.UserID,
+				ChatID:    msg.ChatID,
+				Text:      msg.Text,
+				Error:     err.Error(),
 			})
 		}
 		return "", err
 	}
+	response = r.renderButtons(msg, response)
 
 	// Fire post_response hook (can modify the response text)
 	if hooksMgr != nil && response != "" && hooksMgr.HasHooks(hooks.PostResponse) {
 		result := hooksMgr.Fire(hooks.PostResponse, &hooks.EventData{
-			Platform: msg.Platform,
-			UserID:   msg.UserID,
-			ChatID:   msg.ChatID,
-			Text:     msg.Text,
-			Response: response,
+			RequestID: msg.RequestID,
+			Platform:  msg.Platform,
+			UserID:    msg.UserID,
+			ChatID:    msg.ChatID,
+			Text:      msg.Text,
+			Response:  response,
 		})
 		if result.Output != "" {
 			response = result.Output
@@ -281,14 +518,56 @@ func (r *Router) handleMessage(ctx context.Context, msg *Message) (string, error
 
 	// Log outgoing message
 	if response != "" {
-		r.encryptAndStore(msg.Platform, msg.ChatID, "bot", "", response, time.Now(), "out")
+		r.trackFeedback(msg)
+		r.encryptAndStore(msg.Platform, msg.ChatID, "bot", "", response, time.Now(), "out", msg.Provider)
 	}
 
 	return response, nil
 }
 
-// Send sends a message to a specific platform and chat
-func (r *Router) Send(platform, chatID, message string) error {
+// Answer runs handler for msg outside the platform's dispatch and sends the
+// response, with the quota, hooks and history of a normal reply. A handler
+// that holds a message back (to answer it together with the user's next
+// ones) answers it this way once it is ready. msg must already have passed
+// the router's checks, which are not repeated; its daily quota is charged
+// only for tokens.
+func (r *Router) Answer(ctx context.Context, msg *Message, handler MessageHandler) error {
+	if !r.begin() {
+		return ErrDraining
+	}
+	defer r.end()
+
+	if msg.RequestID == "" {
+		msg.RequestID = telemetry.NewRequestID()
+	}
+	ctx = telemetry.WithRequestID(ctx, msg.RequestID)
+	ctx, span := telemetry.Start(ctx, "router.answer",
+		attribute.String("platform", msg.Platform),
+		attribute.String("request_id", msg.RequestID),
+	)
+	defer span.End()
+
+	quota, _ := r.dailyQuota(msg, false)
+	response, err := r.respond(ctx, msg, handler, quota, 0, r.logger.With("request_id", msg.RequestID))
+	telemetry.RecordError(span, err)
+	if err != nil {
+		// As the platforms do, so the user isn't left without an answer
+		if err != security.ErrNotAuthorized && err != security.ErrAccountLocked {
+			_ = r.Send(msg.Platform, msg.ChatID, "⚠️ "+err.Error())
+		}
+		return err
+	}
+	if response == "" {
+		return nil
+	}
+	if len(msg.Buttons) > 0 {
+		return r.SendReply(msg.Platform, msg.ChatID, Reply{Text: response, Buttons: msg.Buttons})
+	}
+	return r.Send(msg.Platform, msg.ChatID, response)
+}
+
+// SendVoice sends an OGG Opus voice message to a specific platform and chat
+func (r *Router) SendVoice(platform, chatID string, audio []byte) error {
 	r.mu.RLock()
 	p, ok := r.platforms[platform]
 	r.mu.RUnlock()
@@ -296,12 +575,15 @@ func (r *Router) Send(platform, chatID, message string) error {
 	if !ok {
 		return fmt.Errorf("unknown platform: %s", platform)
 	}
+	if err := r.throttle.wait(r.outbox.ctx, platform, chatID); err != nil {
+		return err
+	}
 
-	return p.Send(chatID, message)
+	return p.SendVoice(chatID, audio)
 }
 
-// SendVoice sends an OGG Opus voice message to a specific platform and chat
-func (r *Router) SendVoice(platform, chatID string, audio []byte) error {
+// SendFile sends a file attachment to a specific platform and chat
+func (r *Router) SendFile(platform, chatID, name string, data []byte) error {
 	r.mu.RLock()
 	p, ok := r.platforms[platform]
 	r.mu.RUnlock()
@@ -309,12 +591,20 @@ func (r *Router) SendVoice(platform, chatID string, audio []byte) error {
 	if !ok {
 		return fmt.Errorf("unknown platform: %s", platform)
 	}
+	fs, ok := p.(FileSender)
+	if !ok {
+		return fmt.Errorf("%s does not support file attachments", platform)
+	}
+	if err := r.throttle.wait(r.outbox.ctx, platform, chatID); err != nil {
+		return err
+	}
 
-	return p.SendVoice(chatID, audio)
+	return fs.SendFile(chatID, name, data)
 }
 
 // encryptAndStore encrypts content (if vault available) and saves a message to the store.
-func (r *Router) encryptAndStore(platform, chatID, userID, username, content string, ts time.Time, direction string) {
+// provider names the LLM provider behind an outgoing answer, if any.
+func (r *Router) encryptAndStore(platform, chatID, userID, username, content string, ts time.Time, direction, provider string) {
 	var toStore string
 	if r.vault != nil {
 		encrypted, err := r.vault.Encrypt([]byte(content))
@@ -335,6 +625,7 @@ func (r *Router) encryptAndStore(platform, chatID, userID, username, content str
 			Content:   toStore,
 			Timestamp: ts,
 			Direction: direction,
+			Provider:  provider,
 		}); err != nil {
 			r.logger.Error("save message failed", "error", err, "direction", direction)
 		}
@@ -0,0 +1,144 @@
+// Pacing outbound sends to stay under each platform's rate limits
+package router
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SendLimit is how fast messages may be sent on a platform, in messages per
+// second. Zero means no limit.
+type SendLimit struct {
+	PerSecond float64 // across all chats
+	PerChat   float64 // to one chat
+}
+
+// DefaultSendLimits are the platforms' documented limits, used when
+// platforms.send_limits has no entry for a platform.
+var DefaultSendLimits = map[string]SendLimit{
+	"telegram": {PerSecond: 30, PerChat: 1},
+	"discord":  {PerSecond: 50, PerChat: 1},
+	"slack":    {PerChat: 1},
+	"whatsapp": {PerSecond: 20, PerChat: 1},
+}
+
+// maxIdleBuckets is how many per-chat buckets are kept before idle ones
+// are dropped, at most once a second.
+const maxIdleBuckets = 1024
+
+// bucket is a token bucket refilled at rate tokens per second, holding at
+// most burst.
+type bucket struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+func newBucket(rate float64, now time.Time) *bucket {
+	burst := max(rate, 1)
+	return &bucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+// Tokens may go negative, so waiting callers are served in order.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// full reports whether b has refilled completely by now, so dropping it
+// changes nothing.
+func (b *bucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// sendThrottle queues sends to keep each platform under its SendLimit, both
+// overall and per chat.
+type sendThrottle struct {
+	limits    map[string]SendLimit
+	mu        sync.Mutex
+	platform  map[string]*bucket
+	chat      map[string]*bucket // platform + "\x00" + chat ID
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func newSendThrottle(limits map[string]SendLimit) *sendThrottle {
+	return &sendThrottle{
+		limits:   limits,
+		platform: make(map[string]*bucket),
+		chat:     make(map[string]*bucket),
+		now:      time.Now,
+	}
+}
+
+// reserve takes a send slot for chatID on platform and returns how long to
+// wait before sending.
+func (t *sendThrottle) reserve(platform, chatID string) time.Duration {
+	limit := t.limits[platform]
+	if limit.PerSecond <= 0 && limit.PerChat <= 0 {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+
+	var wait time.Duration
+	if limit.PerSecond > 0 {
+		b := t.platform[platform]
+		if b == nil {
+			b = newBucket(limit.PerSecond, now)
+			t.platform[platform] = b
+		}
+		wait = b.reserve(now)
+	}
+	if limit.PerChat > 0 {
+		if len(t.chat) >= maxIdleBuckets && now.Sub(t.lastPrune) > time.Second {
+			for key, b := range t.chat {
+				if b.full(now) {
+					delete(t.chat, key)
+				}
+			}
+			t.lastPrune = now
+		}
+		key := platform + "\x00" + chatID
+		b := t.chat[key]
+		if b == nil {
+			b = newBucket(limit.PerChat, now)
+			t.chat[key] = b
+		}
+		wait = max(wait, b.reserve(now))
+	}
+	return wait
+}
+
+// wait blocks until a message may be sent to chatID on platform, or ctx is
+// done.
+func (t *sendThrottle) wait(ctx context.Context, platform, chatID string) error {
+	d := t.reserve(platform, chatID)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitToSend blocks until a message may be sent to chatID on platform under
+// its send limit, or ctx is done. Send and the other send methods already
+// wait; senders that reach a platform another way, such as cron notifiers,
+// call it to share the limit.
+func (r *Router) WaitToSend(ctx context.Context, platform, chatID string) error {
+	return r.throttle.wait(ctx, platform, chatID)
+}
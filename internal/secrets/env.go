@@ -70,12 +70,16 @@ func mapSecretToEnvVar(key string) string {
 		return "MINIMAX_API_KEY"
 	case KeyBraveAPIKey:
 		return "BRAVE_API_KEY"
+	case KeyEmbeddingAPIKey:
+		return "EMBEDDING_API_KEY"
 	case KeyTelegramToken:
 		return "TELEGRAM_BOT_TOKEN"
 	case KeySlackBotToken:
 		return "SLACK_BOT_TOKEN"
 	case KeySlackAppToken:
 		return "SLACK_APP_TOKEN"
+	case KeySlackSigningSecret:
+		return "SLACK_SIGNING_SECRET"
 	case KeyEncryptionKey:
 		return "MAGABOT_ENCRYPTION_KEY"
 	default:
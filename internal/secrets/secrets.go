@@ -103,6 +103,7 @@ const (
 	KeyTelegramToken       = "magabot/telegram/bot_token"
 	KeySlackBotToken       = "magabot/slack/bot_token"
 	KeySlackAppToken       = "magabot/slack/app_token"
+	KeySlackSigningSecret  = "magabot/slack/signing_secret"
 	KeyAnthropicAPIKey     = "magabot/llm/anthropic_api_key"
 	KeyClaudeCodeAuthToken = "magabot/llm/claude_code_auth_token"
 	KeyOpenAIAPIKey        = "magabot/llm/openai_api_key"
@@ -110,6 +111,7 @@ const (
 	KeyKimiAPIKey          = "magabot/llm/kimi_api_key"
 	KeyMiniMaxAPIKey       = "magabot/llm/minimax_api_key"
 	KeyBraveAPIKey         = "magabot/tools/brave_api_key"
+	KeyEmbeddingAPIKey     = "magabot/embedding/api_key"
 )
 
 // Config for secrets manager
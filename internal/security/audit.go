@@ -51,6 +51,10 @@ type AuditLogger struct {
 	mu        sync.Mutex
 	logPath   string
 	maxSizeMB int
+
+	// Set on loggers from WithRequestID, which write through parent
+	parent    *AuditLogger
+	requestID string
 }
 
 // NewAuditLogger creates a new audit logger
@@ -72,8 +76,24 @@ func NewAuditLogger(logDir string) (*AuditLogger, error) {
 	}, nil
 }
 
+// WithRequestID returns a logger writing to the same audit log whose
+// events carry requestID, so they can be matched with the message's other
+// logs.
+func (a *AuditLogger) WithRequestID(requestID string) *AuditLogger {
+	if a.parent != nil {
+		a = a.parent
+	}
+	return &AuditLogger{parent: a, requestID: requestID}
+}
+
 // Log writes a security event to the audit log
 func (a *AuditLogger) Log(event SecurityEvent) error {
+	if a.parent != nil {
+		if event.RequestID == "" {
+			event.RequestID = a.requestID
+		}
+		return a.parent.Log(event)
+	}
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
 	}
@@ -145,6 +165,9 @@ func (a *AuditLogger) rotateIfNeeded() {
 
 // Close closes the audit logger
 func (a *AuditLogger) Close() error {
+	if a.parent != nil {
+		return nil // the parent owns the file
+	}
 	if closer, ok := a.writer.(io.Closer); ok {
 		return closer.Close()
 	}
@@ -237,6 +237,34 @@ func TestAuditLoggerConcurrency(t *testing.T) {
 	}
 }
 
+func TestAuditLoggerWithRequestID(t *testing.T) {
+	dir := t.TempDir()
+	logger, _ := NewAuditLogger(dir)
+	defer func() { _ = logger.Close() }()
+
+	scoped := logger.WithRequestID("req-1").WithRequestID("req-2")
+	scoped.LogRateLimited("telegram", "user1")
+	if err := scoped.Close(); err != nil {
+		t.Errorf("Close on a scoped logger: %v", err)
+	}
+	logger.LogRateLimited("telegram", "user1")
+
+	data, _ := os.ReadFile(filepath.Join(dir, "security.log"))
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %s", len(lines), data)
+	}
+	var first, second SecurityEvent
+	_ = json.Unmarshal([]byte(lines[0]), &first)
+	_ = json.Unmarshal([]byte(lines[1]), &second)
+	if first.RequestID != "req-2" || first.Severity != "warning" {
+		t.Errorf("scoped event = %+v, want request ID req-2", first)
+	}
+	if second.RequestID != "" {
+		t.Errorf("unscoped event request ID = %q", second.RequestID)
+	}
+}
+
 func TestAuditLoggerLogFormat(t *testing.T) {
 	dir := t.TempDir()
 	logger, _ := NewAuditLogger(dir)
@@ -0,0 +1,34 @@
+package security
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// KeySize is the length in bytes of the key NewVault expects.
+const KeySize = 32
+
+// Argon2id parameters for DeriveKey. They are part of the derived key:
+// changing any of them changes every key derived from a passphrase.
+const (
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024 // KiB
+	kdfThreads = 4
+	SaltSize   = 16
+)
+
+// DeriveKey derives a length-byte key from passphrase and salt with
+// argon2id. The same passphrase and salt give the same key on any machine.
+func DeriveKey(passphrase string, salt []byte, length int) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	if len(salt) < SaltSize {
+		return nil, errors.New("salt must be at least 16 bytes")
+	}
+	if length <= 0 {
+		return nil, errors.New("invalid key length")
+	}
+	return argon2.IDKey([]byte(passphrase), salt, kdfTime, kdfMemory, kdfThreads, uint32(length)), nil
+}
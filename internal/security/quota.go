@@ -0,0 +1,113 @@
+// Package security - Daily per-user message and token budget
+package security
+
+import (
+	"sync"
+	"time"
+)
+
+// QuotaStore persists daily quota usage so it survives restarts. Days are
+// "2006-01-02" in the quota's timezone.
+type QuotaStore interface {
+	QuotaUsage(userKey, day string) (messages, tokens int64, err error)
+	AddQuotaUsage(userKey, day string, messages, tokens int64) error
+	PurgeQuotaBefore(day string) error
+}
+
+// quotaDayLayout formats the day a usage counts against.
+const quotaDayLayout = "2006-01-02"
+
+type quotaUsage struct {
+	messages, tokens int64
+}
+
+// DailyQuota caps the messages and tokens each user may use per day. The
+// day ends at midnight in the configured timezone.
+type DailyQuota struct {
+	maxMessages int64 // 0 = unlimited
+	maxTokens   int64 // 0 = unlimited
+	loc         *time.Location
+	store       QuotaStore
+	now         func() time.Time
+
+	mu    sync.Mutex
+	day   string
+	usage map[string]*quotaUsage // today's usage per user, loaded lazily
+}
+
+// NewDailyQuota creates a daily quota. A nil loc uses local time.
+func NewDailyQuota(maxMessages, maxTokens int64, loc *time.Location, store QuotaStore) *DailyQuota {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &DailyQuota{
+		maxMessages: maxMessages,
+		maxTokens:   maxTokens,
+		loc:         loc,
+		store:       store,
+		now:         time.Now,
+		usage:       make(map[string]*quotaUsage),
+	}
+}
+
+// Allow reports whether userKey has budget left today, and when the day
+// ends. A usage that can't be loaded allows the message; the error is
+// returned for logging.
+func (q *DailyQuota) Allow(userKey string) (bool, time.Time, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	resetAt := q.rollover()
+	u, err := q.load(userKey)
+	if err != nil {
+		return true, resetAt, err
+	}
+	if q.maxMessages > 0 && u.messages >= q.maxMessages {
+		return false, resetAt, nil
+	}
+	if q.maxTokens > 0 && u.tokens >= q.maxTokens {
+		return false, resetAt, nil
+	}
+	return true, resetAt, nil
+}
+
+// Charge adds messages and tokens to userKey's usage today.
+func (q *DailyQuota) Charge(userKey string, messages, tokens int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	u, err := q.load(userKey)
+	if err != nil {
+		return err
+	}
+	u.messages += messages
+	u.tokens += tokens
+	return q.store.AddQuotaUsage(userKey, q.day, messages, tokens)
+}
+
+// rollover starts a new day when midnight has passed, forgetting the old
+// day's usage, and returns when the current day ends. Caller must hold mu.
+func (q *DailyQuota) rollover() time.Time {
+	now := q.now().In(q.loc)
+	if day := now.Format(quotaDayLayout); day != q.day {
+		q.day = day
+		clear(q.usage)
+		_ = q.store.PurgeQuotaBefore(day)
+	}
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, q.loc)
+}
+
+// load returns userKey's usage today, reading it from the store the first
+// time. Caller must hold mu.
+func (q *DailyQuota) load(userKey string) (*quotaUsage, error) {
+	if u, ok := q.usage[userKey]; ok {
+		return u, nil
+	}
+	messages, tokens, err := q.store.QuotaUsage(userKey, q.day)
+	if err != nil {
+		return nil, err
+	}
+	u := &quotaUsage{messages: messages, tokens: tokens}
+	q.usage[userKey] = u
+	return u, nil
+}
@@ -0,0 +1,99 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+// memQuotaStore is an in-memory QuotaStore.
+type memQuotaStore struct {
+	usage map[string][2]int64 // userKey|day -> messages, tokens
+}
+
+func newMemQuotaStore() *memQuotaStore {
+	return &memQuotaStore{usage: make(map[string][2]int64)}
+}
+
+func (m *memQuotaStore) QuotaUsage(userKey, day string) (int64, int64, error) {
+	u := m.usage[userKey+"|"+day]
+	return u[0], u[1], nil
+}
+
+func (m *memQuotaStore) AddQuotaUsage(userKey, day string, messages, tokens int64) error {
+	u := m.usage[userKey+"|"+day]
+	m.usage[userKey+"|"+day] = [2]int64{u[0] + messages, u[1] + tokens}
+	return nil
+}
+
+func (m *memQuotaStore) PurgeQuotaBefore(day string) error {
+	for key := range m.usage {
+		if key[len(key)-len(day):] < day {
+			delete(m.usage, key)
+		}
+	}
+	return nil
+}
+
+func TestDailyQuotaMessages(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*3600)
+	now := time.Date(2026, 3, 1, 22, 0, 0, 0, jakarta)
+	store := newMemQuotaStore()
+	q := NewDailyQuota(3, 0, jakarta, store)
+	q.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if ok, _, _ := q.Allow("alice"); !ok {
+			t.Fatalf("message %d refused under the quota", i+1)
+		}
+		if err := q.Charge("alice", 1, 500); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ok, resetAt, err := q.Allow("alice")
+	if ok || err != nil {
+		t.Fatalf("Allow after 3 messages = %v, %v; want refused", ok, err)
+	}
+	if want := time.Date(2026, 3, 2, 0, 0, 0, 0, jakarta); !resetAt.Equal(want) {
+		t.Errorf("resetAt = %v, want %v", resetAt, want)
+	}
+	if ok, _, _ := q.Allow("bob"); !ok {
+		t.Error("another user shares alice's quota")
+	}
+
+	// Survives a restart
+	restarted := NewDailyQuota(3, 0, jakarta, store)
+	restarted.now = q.now
+	if ok, _, _ := restarted.Allow("alice"); ok {
+		t.Error("quota forgotten after a restart")
+	}
+
+	// Resets at midnight in the quota's timezone, not UTC
+	now = time.Date(2026, 3, 1, 23, 59, 0, 0, jakarta)
+	if ok, _, _ := q.Allow("alice"); ok {
+		t.Error("quota reset before midnight")
+	}
+	now = time.Date(2026, 3, 2, 0, 0, 0, 0, jakarta)
+	if ok, _, _ := q.Allow("alice"); !ok {
+		t.Error("quota not reset at midnight")
+	}
+	if len(store.usage) != 0 {
+		t.Errorf("yesterday's usage kept: %v", store.usage)
+	}
+}
+
+func TestDailyQuotaTokens(t *testing.T) {
+	q := NewDailyQuota(0, 1000, time.UTC, newMemQuotaStore())
+
+	if err := q.Charge("alice", 1, 999); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _, _ := q.Allow("alice"); !ok {
+		t.Error("refused with 1 token left")
+	}
+	if err := q.Charge("alice", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _, _ := q.Allow("alice"); ok {
+		t.Error("allowed with the token budget used up")
+	}
+}
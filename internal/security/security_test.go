@@ -810,3 +810,30 @@ func TestAuthAttemptsConcurrency(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestDeriveKey(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	k1, err := DeriveKey("correct horse", salt, KeySize)
+	if err != nil {
+		t.Fatalf("DeriveKey failed: %v", err)
+	}
+	k2, _ := DeriveKey("correct horse", salt, KeySize)
+	if len(k1) != KeySize || string(k1) != string(k2) {
+		t.Fatal("Same passphrase and salt should give the same key")
+	}
+	if _, err := NewVault(base64.StdEncoding.EncodeToString(k1)); err != nil {
+		t.Errorf("Derived key rejected by NewVault: %v", err)
+	}
+
+	other, _ := DeriveKey("correct horse", []byte("fedcba9876543210"), KeySize)
+	if string(other) == string(k1) {
+		t.Error("Different salts should give different keys")
+	}
+
+	if _, err := DeriveKey("", salt, KeySize); err == nil {
+		t.Error("Expected an error for an empty passphrase")
+	}
+	if _, err := DeriveKey("correct horse", salt[:8], KeySize); err == nil {
+		t.Error("Expected an error for a short salt")
+	}
+}
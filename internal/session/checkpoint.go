@@ -0,0 +1,112 @@
+// Named snapshots of a session's conversation history
+package session
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxCheckpoints bounds the checkpoints kept per session.
+const MaxCheckpoints = 10
+
+// maxCheckpointName bounds checkpoint name length.
+const maxCheckpointName = 32
+
+var (
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+	ErrCheckpointLimit    = errors.New("checkpoint limit reached")
+	ErrCheckpointName     = errors.New("invalid checkpoint name")
+)
+
+// Checkpoint is an immutable snapshot of a session's history. Its Messages
+// are never modified after creation, so they may be shared freely.
+type Checkpoint struct {
+	Name      string    `json:"name"`
+	Messages  []Message `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveCheckpoint snapshots the session's current history under name,
+// replacing an existing checkpoint with the same name.
+func (m *Manager) SaveCheckpoint(session *Session, name string) (Checkpoint, error) {
+	if name == "" || len(name) > maxCheckpointName {
+		return Checkpoint{}, ErrCheckpointName
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msgs := make([]Message, len(session.Messages))
+	copy(msgs, session.Messages)
+	cp := Checkpoint{Name: name, Messages: msgs, CreatedAt: time.Now()}
+	return cp, m.putCheckpoint(session, cp)
+}
+
+// AddCheckpoint stores a previously saved checkpoint, e.g. one restored from
+// the database. The limit applies as for SaveCheckpoint.
+func (m *Manager) AddCheckpoint(session *Session, cp Checkpoint) error {
+	if cp.Name == "" || len(cp.Name) > maxCheckpointName {
+		return ErrCheckpointName
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.putCheckpoint(session, cp)
+}
+
+// putCheckpoint replaces or appends cp (caller must hold mu).
+func (m *Manager) putCheckpoint(session *Session, cp Checkpoint) error {
+	for i := range session.Checkpoints {
+		if session.Checkpoints[i].Name == cp.Name {
+			session.Checkpoints[i] = cp
+			return nil
+		}
+	}
+	if len(session.Checkpoints) >= MaxCheckpoints {
+		return ErrCheckpointLimit
+	}
+	session.Checkpoints = append(session.Checkpoints, cp)
+	return nil
+}
+
+// LoadCheckpoint makes the named checkpoint the session's active history.
+// The checkpoint itself is kept, so it can be loaded again later.
+func (m *Manager) LoadCheckpoint(session *Session, name string) (Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, cp := range session.Checkpoints {
+		if cp.Name == name {
+			msgs := make([]Message, len(cp.Messages))
+			copy(msgs, cp.Messages)
+			session.Messages = msgs
+			session.UpdatedAt = time.Now()
+			return cp, nil
+		}
+	}
+	return Checkpoint{}, ErrCheckpointNotFound
+}
+
+// DeleteCheckpoint removes the named checkpoint. It reports whether one existed.
+func (m *Manager) DeleteCheckpoint(session *Session, name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, cp := range session.Checkpoints {
+		if cp.Name == name {
+			session.Checkpoints = append(session.Checkpoints[:i:i], session.Checkpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ListCheckpoints returns the session's checkpoints, oldest first.
+func (m *Manager) ListCheckpoints(session *Session) []Checkpoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Checkpoint, len(session.Checkpoints))
+	copy(out, session.Checkpoints)
+	return out
+}
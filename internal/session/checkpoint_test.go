@@ -0,0 +1,66 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCheckpoints(t *testing.T) {
+	mgr := NewManager(nil, 50, nil)
+	sess := mgr.GetOrCreate("telegram", "chat1", "user1")
+
+	mgr.AddMessage(sess, "user", "plan a trip")
+	mgr.AddMessage(sess, "assistant", "where to?")
+	if _, err := mgr.SaveCheckpoint(sess, "start"); err != nil {
+		t.Fatalf("SaveCheckpoint: %v", err)
+	}
+
+	// Explore a branch, then go back
+	mgr.AddMessage(sess, "user", "Japan")
+	if _, err := mgr.SaveCheckpoint(sess, "japan"); err != nil {
+		t.Fatalf("SaveCheckpoint: %v", err)
+	}
+	cp, err := mgr.LoadCheckpoint(sess, "start")
+	if err != nil {
+		t.Fatalf("LoadCheckpoint: %v", err)
+	}
+	if got := mgr.GetHistory(sess, 0); len(got) != 2 || len(cp.Messages) != 2 {
+		t.Fatalf("history after load = %d messages, want 2", len(got))
+	}
+
+	// New messages must not leak into the snapshot
+	mgr.AddMessage(sess, "user", "Italy")
+	if cps := mgr.ListCheckpoints(sess); len(cps) != 2 || len(cps[0].Messages) != 2 || len(cps[1].Messages) != 3 {
+		t.Errorf("checkpoints changed after load: %+v", cps)
+	}
+
+	if _, err := mgr.LoadCheckpoint(sess, "missing"); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("LoadCheckpoint(missing) = %v, want ErrCheckpointNotFound", err)
+	}
+	if _, err := mgr.SaveCheckpoint(sess, ""); !errors.Is(err, ErrCheckpointName) {
+		t.Errorf("SaveCheckpoint(\"\") = %v, want ErrCheckpointName", err)
+	}
+
+	if !mgr.DeleteCheckpoint(sess, "japan") || mgr.DeleteCheckpoint(sess, "japan") {
+		t.Error("DeleteCheckpoint should succeed once")
+	}
+}
+
+func TestCheckpointLimit(t *testing.T) {
+	mgr := NewManager(nil, 50, nil)
+	sess := mgr.GetOrCreate("telegram", "chat1", "user1")
+
+	for i := range MaxCheckpoints {
+		if _, err := mgr.SaveCheckpoint(sess, fmt.Sprintf("cp%d", i)); err != nil {
+			t.Fatalf("SaveCheckpoint %d: %v", i, err)
+		}
+	}
+	if _, err := mgr.SaveCheckpoint(sess, "extra"); !errors.Is(err, ErrCheckpointLimit) {
+		t.Errorf("SaveCheckpoint over limit = %v, want ErrCheckpointLimit", err)
+	}
+	// Overwriting an existing name is still allowed
+	if _, err := mgr.SaveCheckpoint(sess, "cp0"); err != nil {
+		t.Errorf("overwrite at limit: %v", err)
+	}
+}
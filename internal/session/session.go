@@ -2,9 +2,12 @@
 package session
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -46,13 +49,15 @@ type Session struct {
 	Type        string                 `json:"type"`                // main, sub, background
 	UserID      string                 `json:"user_id"`
 	Platform    string                 `json:"platform"`
-	ChatID      string                 `json:"chat_id"`
+	ChatID      string                 `json:"chat_id"` // In ModeUser, the chat the user last wrote in; guarded by the Manager
+	ThreadID    string                 `json:"thread_id,omitempty"`
 	Task        string                 `json:"task,omitempty"` // For sub-sessions
 	Status      Status                 `json:"status"`
 	Result      string                 `json:"result,omitempty"`
 	Error       string                 `json:"error,omitempty"`
 	Context     map[string]interface{} `json:"context,omitempty"`
 	Messages    []Message              `json:"messages,omitempty"`
+	Checkpoints []Checkpoint           `json:"checkpoints,omitempty"`
 	CreatedAt   time.Time              `json:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at"`
 	CompletedAt *time.Time             `json:"completed_at,omitempty"`
@@ -64,6 +69,11 @@ type Message struct {
 	Role      string    `json:"role"` // user, assistant, system
 	Content   string    `json:"content"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// Set on assistant turns answered by an LLM; not persisted across restarts
+	Model        string `json:"model,omitempty"`
+	InputTokens  int    `json:"input_tokens,omitempty"`
+	OutputTokens int    `json:"output_tokens,omitempty"`
 }
 
 // TaskFunc is the function signature for background tasks
@@ -72,15 +82,33 @@ type TaskFunc func(ctx context.Context, session *Session) (result string, err er
 // NotifyFunc is called when a sub-session completes
 type NotifyFunc func(platform, chatID, message string) error
 
+// Loader reads the stored history and checkpoints of the session under key,
+// oldest first, so a session evicted from memory comes back intact.
+type Loader func(key string) ([]Message, []Checkpoint, error)
+
+// ContextStore keeps the context (persona, language, ...) of sessions
+// evicted from memory until they are created again.
+type ContextStore interface {
+	SaveSessionContext(key string, context map[string]interface{}) error
+	TakeSessionContext(key string) (map[string]interface{}, error)
+}
+
 // Manager manages multiple sessions
 type Manager struct {
-	mu         sync.RWMutex
-	sessions   map[string]*Session
-	notify     NotifyFunc
-	taskRunner TaskRunner
-	maxHistory int          // Max messages to keep per session
-	subCounter atomic.Int64 // monotonic counter for unique sub-session IDs
-	logger     *slog.Logger
+	mu          sync.RWMutex
+	sessions    map[string]*Session
+	notify      NotifyFunc
+	taskRunner  TaskRunner
+	loader      Loader
+	contexts    ContextStore
+	users       map[string]map[string]interface{} // platform:userID -> per-user context
+	mode        Mode
+	maxHistory  int                      // Max messages to keep per session
+	maxSessions int                      // Max main sessions in memory (0 = unlimited)
+	lru         *list.List               // main session keys, most recently used first
+	lruIndex    map[string]*list.Element // key -> element in lru
+	subCounter  atomic.Int64             // monotonic counter for unique sub-session IDs
+	logger      *slog.Logger
 }
 
 // TaskRunner executes tasks (usually LLM calls)
@@ -99,44 +127,322 @@ func NewManager(notify NotifyFunc, maxHistory int, logger *slog.Logger) *Manager
 
 	return &Manager{
 		sessions:   make(map[string]*Session),
+		users:      make(map[string]map[string]interface{}),
 		notify:     notify,
 		maxHistory: maxHistory,
+		lru:        list.New(),
+		lruIndex:   make(map[string]*list.Element),
 		logger:     logger,
 	}
 }
 
+// SetMaxSessions caps the main sessions kept in memory. Creating one more
+// evicts the least recently used; 0 means no cap.
+func (m *Manager) SetMaxSessions(n int) {
+	m.mu.Lock()
+	m.maxSessions = max(n, 0)
+	evicted := m.evict()
+	m.mu.Unlock()
+	m.park(evicted)
+}
+
+// SetLoader sets how sessions not in memory are read back from storage.
+// Without one, an evicted session starts with an empty history.
+func (m *Manager) SetLoader(loader Loader) {
+	m.loader = loader
+}
+
+// SetContextStore sets where evicted sessions keep their context. Without
+// one, an evicted session comes back without it.
+func (m *Manager) SetContextStore(store ContextStore) {
+	m.contexts = store
+}
+
 // SetTaskRunner sets the task runner (LLM)
 func (m *Manager) SetTaskRunner(runner TaskRunner) {
 	m.taskRunner = runner
 }
 
-// GetOrCreate gets an existing session or creates a new one
-func (m *Manager) GetOrCreate(platform, chatID, userID string) *Session {
-	key := fmt.Sprintf("%s:%s", platform, chatID)
+// Mode decides which messages share a session history.
+type Mode string
+
+const (
+	// ModeChat shares one history per chat, or per thread in it, between
+	// everyone who writes there. This is the default.
+	ModeChat Mode = "chat"
+	// ModeUser gives each user one history per platform that follows them
+	// across DMs and groups, so context from one chat is recalled in another.
+	ModeUser Mode = "user"
+	// ModeUserPerChat gives each user a private history in every chat or
+	// thread, so participants of a group never see each other's context.
+	ModeUserPerChat Mode = "user_per_chat"
+)
 
+// ParseMode validates a session mode name. Empty means ModeChat.
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(s); mode {
+	case "":
+		return ModeChat, nil
+	case ModeChat, ModeUser, ModeUserPerChat:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown session mode %q (want chat, user or user_per_chat)", s)
+}
+
+// SetMode sets how sessions are keyed. Call it before the first session is
+// created; existing sessions keep their keys.
+func (m *Manager) SetMode(mode Mode) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.mode = mode
+}
+
+// threadSep separates the thread ID in a session key. Chat IDs may contain
+// ':' (Telegram forum topics), so a different separator keeps keys parseable.
+const threadSep = "#"
+
+// userSep separates the user ID in the keys of per-user modes. WhatsApp
+// chat IDs contain '@', so it is not usable here.
+const userSep = "|"
+
+// Key returns the session key for a chat, or for one thread in it when
+// threadID is set: "platform:chatID" or "platform:chatID#threadID".
+func Key(platform, chatID, threadID string) string {
+	key := fmt.Sprintf("%s:%s", platform, chatID)
+	if threadID != "" {
+		key += threadSep + threadID
+	}
+	return key
+}
+
+// ModeKey returns the session key for a message under mode:
+//
+//	chat:          "platform:chatID#threadID" (as Key)
+//	user:          "platform:|userID"
+//	user_per_chat: "platform:chatID#threadID|userID"
+//
+// Per-user modes fall back to the chat key when userID is empty.
+func ModeKey(mode Mode, platform, chatID, threadID, userID string) string {
+	if userID == "" {
+		return Key(platform, chatID, threadID)
+	}
+	switch mode {
+	case ModeUser:
+		return platform + ":" + userSep + userID
+	case ModeUserPerChat:
+		return Key(platform, chatID, threadID) + userSep + userID
+	}
+	return Key(platform, chatID, threadID)
+}
+
+// ParseKey splits a key produced by Key or ModeKey. userID is empty for chat
+// keys and chatID is empty for user keys. ok is false for malformed keys.
+func ParseKey(key string) (platform, chatID, threadID, userID string, ok bool) {
+	platform, rest, found := strings.Cut(key, ":")
+	if !found || platform == "" || rest == "" {
+		return "", "", "", "", false
+	}
+	rest, userID, _ = strings.Cut(rest, userSep)
+	chatID, threadID, _ = strings.Cut(rest, threadSep)
+	if chatID == "" && (threadID != "" || userID == "") {
+		return "", "", "", "", false
+	}
+	return platform, chatID, threadID, userID, true
+}
+
+// GetOrCreate gets an existing chat-level session or creates a new one
+func (m *Manager) GetOrCreate(platform, chatID, userID string) *Session {
+	return m.GetOrCreateThread(platform, chatID, "", userID)
+}
+
+// GetOrCreateThread gets or creates the session for a thread within a chat,
+// so concurrent threads keep separate history. An empty threadID means the
+// chat-level session. The manager's Mode decides whether userID is part of
+// the key.
+func (m *Manager) GetOrCreateThread(platform, chatID, threadID, userID string) *Session {
+	m.mu.Lock()
+	key := ModeKey(m.mode, platform, chatID, threadID, userID)
+	session, ok := m.sessions[key]
+	if !ok {
+		// Read storage without m.mu, so other chats aren't held up
+		m.mu.Unlock()
+		st := m.load(key)
+		m.mu.Lock()
+		session = m.create(key, platform, chatID, threadID, userID, st)
+	}
+	m.touch(key)
+	if m.mode == ModeUser && chatID != "" {
+		// Background task results go to the chat the user last wrote in,
+		// never to a group they happened to start the session in. Still
+		// under m.mu, as Spawn reads them under it.
+		session.ChatID, session.ThreadID = chatID, threadID
+	}
+	evicted := m.evict()
+	m.mu.Unlock()
+	m.park(evicted)
+	return session
+}
+
+// Restore gets or creates the session stored under key, as found in the
+// database. It works for keys of any mode; ok is false for malformed keys.
+func (m *Manager) Restore(key string) (*Session, bool) {
+	platform, chatID, threadID, userID, ok := ParseKey(key)
+	if !ok {
+		return nil, false
+	}
+
+	m.mu.RLock()
+	session, ok := m.sessions[key]
+	m.mu.RUnlock()
+	var st stored
+	if !ok {
+		st = m.load(key)
+	}
+
+	m.mu.Lock()
+	session = m.create(key, platform, chatID, threadID, userID, st)
+	m.touch(key)
+	evicted := m.evict()
+	m.mu.Unlock()
+	m.park(evicted)
+	return session, true
+}
+
+// stored is what a main session brings back from storage.
+type stored struct {
+	messages    []Message
+	checkpoints []Checkpoint
+	context     map[string]interface{}
+}
+
+// load reads the session under key back from storage: its history and
+// checkpoints from the Loader, its context from the ContextStore. These are
+// database reads; the caller must not hold m.mu.
+func (m *Manager) load(key string) stored {
+	var st stored
+	if m.loader != nil {
+		msgs, cps, err := m.loader(key)
+		if err != nil {
+			m.logger.Warn("load session failed", "session", key, "error", err)
+		}
+		st.messages, st.checkpoints = msgs, cps
+	}
+	if m.contexts != nil {
+		ctx, err := m.contexts.TakeSessionContext(key)
+		if err != nil {
+			m.logger.Warn("load session context failed", "session", key, "error", err)
+		}
+		st.context = ctx
+	}
+	return st
+}
 
+// create adds a main session under key from what load read, or returns the
+// one added while storage was read. The caller must hold m.mu, touch the
+// key and evict.
+func (m *Manager) create(key, platform, chatID, threadID, userID string, st stored) *Session {
 	if session, ok := m.sessions[key]; ok {
+		if session.Context == nil {
+			// The context was taken from the store for this load
+			session.Context = st.context
+		}
 		return session
 	}
-
 	session := &Session{
 		ID:        key,
 		Type:      "main",
 		UserID:    userID,
 		Platform:  platform,
 		ChatID:    chatID,
+		ThreadID:  threadID,
 		Status:    StatusRunning,
 		Messages:  make([]Message, 0),
+		Context:   st.context,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	msgs := st.messages
+	if len(msgs) > m.maxHistory {
+		msgs = msgs[len(msgs)-m.maxHistory:]
+	}
+	session.Messages = append(session.Messages, msgs...)
+	cps := st.checkpoints
+	if len(cps) > MaxCheckpoints {
+		cps = cps[len(cps)-MaxCheckpoints:]
+	}
+	session.Checkpoints = cps
 
 	m.sessions[key] = session
 	return session
 }
 
+// touch marks the main session under key as most recently used. The caller
+// must hold m.mu.
+func (m *Manager) touch(key string) {
+	if el, ok := m.lruIndex[key]; ok {
+		m.lru.MoveToFront(el)
+		return
+	}
+	m.lruIndex[key] = m.lru.PushFront(key)
+}
+
+// parked is the context of an evicted session, for the ContextStore.
+type parked struct {
+	key     string
+	context map[string]interface{}
+}
+
+// evict drops least recently used main sessions until the cap is met and
+// returns their contexts for park. Their history stays in storage for the
+// Loader. The caller must hold m.mu.
+func (m *Manager) evict() []parked {
+	var evicted []parked
+	for m.maxSessions > 0 && m.lru.Len() > m.maxSessions {
+		evicted = m.evictKey(m.lru.Back().Value.(string), evicted)
+	}
+	return evicted
+}
+
+// evictKey removes the main session under key, appending its context to
+// evicted when it has one. The caller must hold m.mu.
+func (m *Manager) evictKey(key string, evicted []parked) []parked {
+	if session := m.sessions[key]; session != nil && len(session.Context) > 0 {
+		evicted = append(evicted, parked{key: key, context: maps.Clone(session.Context)})
+	}
+	m.remove(key)
+	return evicted
+}
+
+// park saves evicted sessions' contexts in the ContextStore. These are
+// database writes; the caller must not hold m.mu.
+func (m *Manager) park(evicted []parked) {
+	if m.contexts == nil {
+		return
+	}
+	for _, p := range evicted {
+		if err := m.contexts.SaveSessionContext(p.key, p.context); err != nil {
+			m.logger.Warn("save session context failed", "session", p.key, "error", err)
+		}
+	}
+}
+
+// remove forgets the main session under key. The caller must hold m.mu.
+func (m *Manager) remove(key string) {
+	if el, ok := m.lruIndex[key]; ok {
+		m.lru.Remove(el)
+		delete(m.lruIndex, key)
+	}
+	delete(m.sessions, key)
+	m.logger.Debug("evicted session", "session", key)
+}
+
+// Count returns the number of main sessions in memory.
+func (m *Manager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lru.Len()
+}
+
 // Get retrieves a session by ID
 func (m *Manager) Get(id string) *Session {
 	m.mu.RLock()
@@ -157,14 +463,19 @@ func (m *Manager) SessionStatus(id string) (Status, string, string, *time.Time)
 
 // AddMessage adds a message to session history
 func (m *Manager) AddMessage(session *Session, role, content string) {
+	m.AppendMessage(session, Message{Role: role, Content: content})
+}
+
+// AppendMessage adds a message with metadata to session history. A zero
+// Timestamp is set to now.
+func (m *Manager) AppendMessage(session *Session, msg Message) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	session.Messages = append(session.Messages, Message{
-		Role:      role,
-		Content:   content,
-		Timestamp: time.Now(),
-	})
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	session.Messages = append(session.Messages, msg)
 	session.UpdatedAt = time.Now()
 
 	// Trim history if too long
@@ -180,6 +491,21 @@ func (m *Manager) ClearMessages(session *Session) {
 	session.Messages = session.Messages[:0]
 }
 
+// Turns returns how many user messages the session's history holds, which
+// is capped by the manager's maxHistory.
+func (m *Manager) Turns(session *Session) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, msg := range session.Messages {
+		if msg.Role == "user" {
+			n++
+		}
+	}
+	return n
+}
+
 // GetHistory returns recent messages for context (returns a copy to avoid races)
 func (m *Manager) GetHistory(session *Session, limit int) []Message {
 	m.mu.RLock()
@@ -205,6 +531,9 @@ func (m *Manager) GetHistory(session *Session, limit int) []Message {
 func (m *Manager) Spawn(parent *Session, task string) (*Session, error) {
 	subID := fmt.Sprintf("%s:sub:%d", parent.ID, m.subCounter.Add(1))
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	sub := &Session{
 		ID:        subID,
 		ParentID:  parent.ID,
@@ -218,9 +547,7 @@ func (m *Manager) Spawn(parent *Session, task string) (*Session, error) {
 		UpdatedAt: time.Now(),
 	}
 
-	m.mu.Lock()
 	m.sessions[subID] = sub
-	m.mu.Unlock()
 
 	// Run task in background
 	go m.runSubSession(sub)
@@ -283,6 +610,9 @@ func (m *Manager) notifyCompletion(session *Session) {
 		return
 	}
 
+	// Read the session under the lock; Cancel may still be changing it
+	m.mu.RLock()
+	platform, chatID := session.Platform, session.ChatID
 	var message string
 	if session.Status == StatusComplete {
 		message = fmt.Sprintf("✅ *Task Complete*\n\n📋 %s\n\n%s",
@@ -293,8 +623,9 @@ func (m *Manager) notifyCompletion(session *Session) {
 			util.Truncate(session.Task, 100),
 			session.Error)
 	}
+	m.mu.RUnlock()
 
-	if err := m.notify(session.Platform, session.ChatID, message); err != nil {
+	if err := m.notify(platform, chatID, message); err != nil {
 		m.logger.Error("failed to notify", "error", err)
 	}
 }
@@ -371,7 +702,7 @@ func (m *Manager) Clear(olderThan time.Duration) int {
 	for id, session := range m.sessions {
 		if session.Status == StatusComplete || session.Status == StatusFailed || session.Status == StatusCanceled {
 			if session.CompletedAt != nil && session.CompletedAt.Before(cutoff) {
-				delete(m.sessions, id)
+				m.remove(id)
 				count++
 			}
 		}
@@ -380,6 +711,27 @@ func (m *Manager) Clear(olderThan time.Duration) int {
 	return count
 }
 
+// EvictIdle removes main sessions with no message for longer than idle and
+// returns how many were removed. Their history stays in storage.
+func (m *Manager) EvictIdle(idle time.Duration) int {
+	m.mu.Lock()
+	cutoff := time.Now().Add(-idle)
+	count := 0
+	var evicted []parked
+	for el := m.lru.Back(); el != nil; {
+		prev := el.Prev()
+		key := el.Value.(string)
+		if session := m.sessions[key]; session == nil || session.UpdatedAt.Before(cutoff) {
+			evicted = m.evictKey(key, evicted)
+			count++
+		}
+		el = prev
+	}
+	m.mu.Unlock()
+	m.park(evicted)
+	return count
+}
+
 // SetContext sets session context (for passing data between messages)
 func (m *Manager) SetContext(session *Session, key string, value interface{}) {
 	m.mu.Lock()
@@ -401,3 +753,24 @@ func (m *Manager) GetContext(session *Session, key string) interface{} {
 	}
 	return session.Context[key]
 }
+
+// SetUserContext sets a value that follows userID on platform across all
+// their chats and sessions, such as their language.
+func (m *Manager) SetUserContext(platform, userID, key string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := platform + ":" + userID
+	if m.users[id] == nil {
+		m.users[id] = make(map[string]interface{})
+	}
+	m.users[id][key] = value
+}
+
+// GetUserContext gets a value set with SetUserContext
+func (m *Manager) GetUserContext(platform, userID, key string) interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.users[platform+":"+userID][key]
+}
@@ -104,6 +104,152 @@ func TestGetOrCreate(t *testing.T) {
 			t.Error("Different platforms should have different sessions")
 		}
 	})
+	t.Run("Threads", func(t *testing.T) {
+		chat := mgr.GetOrCreate("slack", "C1", "user1")
+		t1 := mgr.GetOrCreateThread("slack", "C1", "1700.1", "user1")
+		t2 := mgr.GetOrCreateThread("slack", "C1", "1700.2", "user1")
+		if chat == t1 || t1 == t2 {
+			t.Error("Each thread should have its own session")
+		}
+		if again := mgr.GetOrCreateThread("slack", "C1", "1700.1", "user2"); again != t1 {
+			t.Error("Same thread should return the same session")
+		}
+		if noThread := mgr.GetOrCreateThread("slack", "C1", "", "user1"); noThread != chat {
+			t.Error("Empty thread ID should fall back to the chat session")
+		}
+		if t1.ThreadID != "1700.1" || t1.ChatID != "C1" {
+			t.Errorf("thread session = chat %q thread %q", t1.ChatID, t1.ThreadID)
+		}
+	})
+}
+
+func TestSessionKey(t *testing.T) {
+	tests := []struct {
+		platform, chatID, threadID string
+		want                       string
+	}{
+		{"telegram", "123", "", "telegram:123"},
+		{"telegram", "-100:42", "", "telegram:-100:42"}, // forum topic chat ID
+		{"slack", "C1", "1700000000.000100", "slack:C1#1700000000.000100"},
+		{"discord", "9", "10", "discord:9#10"},
+	}
+	for _, tt := range tests {
+		key := Key(tt.platform, tt.chatID, tt.threadID)
+		if key != tt.want {
+			t.Errorf("Key(%q, %q, %q) = %q, want %q", tt.platform, tt.chatID, tt.threadID, key, tt.want)
+		}
+		platform, chatID, threadID, userID, ok := ParseKey(key)
+		if !ok || platform != tt.platform || chatID != tt.chatID || threadID != tt.threadID || userID != "" {
+			t.Errorf("ParseKey(%q) = %q, %q, %q, %q, %v", key, platform, chatID, threadID, userID, ok)
+		}
+	}
+
+	for _, bad := range []string{"", "telegram", "telegram:", ":123", "slack:#1700.1", "telegram:|", "slack:#1700.1|U1"} {
+		if _, _, _, _, ok := ParseKey(bad); ok {
+			t.Errorf("ParseKey(%q) should fail", bad)
+		}
+	}
+}
+
+func TestModeKey(t *testing.T) {
+	tests := []struct {
+		mode                               Mode
+		platform, chatID, threadID, userID string
+		want, wantChatID, wantThreadID     string
+	}{
+		{ModeChat, "telegram", "-100", "", "42", "telegram:-100", "-100", ""},
+		{ModeChat, "slack", "C1", "1700.1", "U1", "slack:C1#1700.1", "C1", "1700.1"},
+		{"", "telegram", "-100", "", "42", "telegram:-100", "-100", ""},
+		{ModeUser, "telegram", "-100", "", "42", "telegram:|42", "", ""},
+		{ModeUser, "slack", "C1", "1700.1", "U1", "slack:|U1", "", ""},
+		{ModeUser, "whatsapp", "123@g.us", "", "62812@s.whatsapp.net", "whatsapp:|62812@s.whatsapp.net", "", ""},
+		{ModeUserPerChat, "telegram", "-100:7", "", "42", "telegram:-100:7|42", "-100:7", ""},
+		{ModeUserPerChat, "slack", "C1", "1700.1", "U1", "slack:C1#1700.1|U1", "C1", "1700.1"},
+		{ModeUserPerChat, "whatsapp", "123@g.us", "", "62812@s.whatsapp.net", "whatsapp:123@g.us|62812@s.whatsapp.net", "123@g.us", ""},
+		// No user to key by: per-user modes fall back to the chat key
+		{ModeUser, "webhook", "hook", "", "", "webhook:hook", "hook", ""},
+		{ModeUserPerChat, "webhook", "hook", "", "", "webhook:hook", "hook", ""},
+	}
+	for _, tt := range tests {
+		key := ModeKey(tt.mode, tt.platform, tt.chatID, tt.threadID, tt.userID)
+		if key != tt.want {
+			t.Errorf("ModeKey(%q, %q, %q, %q, %q) = %q, want %q", tt.mode, tt.platform, tt.chatID, tt.threadID, tt.userID, key, tt.want)
+			continue
+		}
+		platform, chatID, threadID, userID, ok := ParseKey(key)
+		wantUserID := tt.userID
+		if tt.mode == ModeChat || tt.mode == "" {
+			wantUserID = ""
+		}
+		if !ok || platform != tt.platform || chatID != tt.wantChatID || threadID != tt.wantThreadID || userID != wantUserID {
+			t.Errorf("ParseKey(%q) = %q, %q, %q, %q, %v", key, platform, chatID, threadID, userID, ok)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": ModeChat, "chat": ModeChat, "user": ModeUser, "user_per_chat": ModeUserPerChat} {
+		if got, err := ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMode("group"); err == nil {
+		t.Error("ParseMode(\"group\") should fail")
+	}
+}
+
+func TestManagerMode(t *testing.T) {
+	t.Run("chat shares a group", func(t *testing.T) {
+		mgr := NewManager(nil, 50, nil)
+		if mgr.GetOrCreate("telegram", "-100", "alice") != mgr.GetOrCreate("telegram", "-100", "bob") {
+			t.Error("chat mode should share one session per group")
+		}
+	})
+
+	t.Run("user_per_chat isolates participants", func(t *testing.T) {
+		mgr := NewManager(nil, 50, nil)
+		mgr.SetMode(ModeUserPerChat)
+		alice := mgr.GetOrCreate("telegram", "-100", "alice")
+		mgr.AddMessage(alice, "user", "my secret")
+		bob := mgr.GetOrCreate("telegram", "-100", "bob")
+		if alice == bob || len(mgr.GetHistory(bob, 0)) != 0 {
+			t.Error("bob should not see alice's history")
+		}
+		if mgr.GetOrCreate("telegram", "-200", "alice") == alice {
+			t.Error("user_per_chat should give alice a new session in another chat")
+		}
+	})
+
+	t.Run("user follows the user across chats", func(t *testing.T) {
+		mgr := NewManager(nil, 50, nil)
+		mgr.SetMode(ModeUser)
+		group := mgr.GetOrCreate("telegram", "-100", "alice")
+		dm := mgr.GetOrCreate("telegram", "alice", "alice")
+		if group != dm {
+			t.Fatal("user mode should keep one session per user")
+		}
+		if dm.ChatID != "alice" {
+			t.Errorf("ChatID = %q, want the chat last written in", dm.ChatID)
+		}
+		if mgr.GetOrCreate("telegram", "-100", "bob") == group {
+			t.Error("bob should not share alice's session")
+		}
+	})
+
+	t.Run("restore keeps stored keys", func(t *testing.T) {
+		mgr := NewManager(nil, 50, nil)
+		mgr.SetMode(ModeUserPerChat)
+		sess, ok := mgr.Restore("slack:C1#1700.1|U1")
+		if !ok || sess.ChatID != "C1" || sess.ThreadID != "1700.1" || sess.UserID != "U1" {
+			t.Fatalf("Restore = %+v, %v", sess, ok)
+		}
+		if mgr.GetOrCreateThread("slack", "C1", "1700.1", "U1") != sess {
+			t.Error("restored session should be found by its message")
+		}
+		if _, ok := mgr.Restore("slack:"); ok {
+			t.Error("Restore should reject malformed keys")
+		}
+	})
 }
 
 func TestGet(t *testing.T) {
@@ -171,6 +317,26 @@ func TestAddMessage(t *testing.T) {
 	})
 }
 
+func TestTurns(t *testing.T) {
+	mgr := NewManager(nil, 100, nil)
+	sess := mgr.GetOrCreate("telegram", "chat1", "user1")
+
+	if n := mgr.Turns(sess); n != 0 {
+		t.Errorf("new session has %d turns, want 0", n)
+	}
+	for i := 0; i < 3; i++ {
+		mgr.AddMessage(sess, "user", "question")
+		mgr.AddMessage(sess, "assistant", "answer")
+	}
+	if n := mgr.Turns(sess); n != 3 {
+		t.Errorf("Turns = %d, want 3", n)
+	}
+	mgr.ClearMessages(sess)
+	if n := mgr.Turns(sess); n != 0 {
+		t.Errorf("Turns after clear = %d, want 0", n)
+	}
+}
+
 func TestGetHistory(t *testing.T) {
 	mgr := NewManager(nil, 50, nil)
 	sess := mgr.GetOrCreate("telegram", "chat1", "user1")
@@ -427,6 +593,152 @@ func TestClear(t *testing.T) {
 	}
 }
 
+func TestMaxSessions(t *testing.T) {
+	// stored stands in for the database the daemon writes messages to
+	stored := map[string][]Message{}
+	mgr := NewManager(nil, 50, nil)
+	mgr.SetMaxSessions(2)
+	mgr.SetLoader(func(key string) ([]Message, []Checkpoint, error) {
+		return stored[key], nil, nil
+	})
+
+	sess1 := mgr.GetOrCreate("telegram", "chat1", "user1")
+	mgr.AddMessage(sess1, "user", "remember me")
+	stored[sess1.ID] = mgr.GetHistory(sess1, 0)
+	mgr.GetOrCreate("telegram", "chat2", "user2")
+	mgr.GetOrCreate("telegram", "chat1", "user1") // chat1 is now the most recent
+	mgr.GetOrCreate("telegram", "chat3", "user3")
+
+	if n := mgr.Count(); n != 2 {
+		t.Fatalf("Count = %d, want 2", n)
+	}
+	if mgr.Get(Key("telegram", "chat2", "")) != nil {
+		t.Error("least recently used session chat2 should be evicted")
+	}
+	if mgr.Get(sess1.ID) == nil {
+		t.Error("recently used session chat1 should be kept")
+	}
+
+	// Evict chat1 too, then bring it back from storage
+	mgr.GetOrCreate("telegram", "chat4", "user4")
+	mgr.GetOrCreate("telegram", "chat5", "user5")
+	if mgr.Get(sess1.ID) != nil {
+		t.Fatal("chat1 should be evicted")
+	}
+	restored := mgr.GetOrCreate("telegram", "chat1", "user1")
+	history := mgr.GetHistory(restored, 0)
+	if len(history) != 1 || history[0].Content != "remember me" {
+		t.Errorf("restored history = %v, want the persisted message", history)
+	}
+	if n := mgr.Count(); n != 2 {
+		t.Errorf("Count after restore = %d, want 2", n)
+	}
+}
+
+// mapContextStore is an in-memory ContextStore.
+type mapContextStore struct {
+	mu       sync.Mutex
+	contexts map[string]map[string]interface{}
+}
+
+func (s *mapContextStore) SaveSessionContext(key string, context map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contexts[key] = context
+	return nil
+}
+
+func (s *mapContextStore) TakeSessionContext(key string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	context := s.contexts[key]
+	delete(s.contexts, key)
+	return context, nil
+}
+
+func TestMaxSessions_KeepsContext(t *testing.T) {
+	store := &mapContextStore{contexts: map[string]map[string]interface{}{}}
+	mgr := NewManager(nil, 50, nil)
+	mgr.SetMaxSessions(1)
+	mgr.SetContextStore(store)
+
+	sess := mgr.GetOrCreate("telegram", "chat1", "user1")
+	mgr.SetContext(sess, "persona", "pirate")
+	mgr.GetOrCreate("telegram", "chat2", "user2")
+	if mgr.Get(sess.ID) != nil {
+		t.Fatal("chat1 should be evicted")
+	}
+	if store.contexts[sess.ID]["persona"] != "pirate" {
+		t.Fatalf("evicted context not saved: %v", store.contexts)
+	}
+
+	restored := mgr.GetOrCreate("telegram", "chat1", "user1")
+	if got := mgr.GetContext(restored, "persona"); got != "pirate" {
+		t.Errorf("restored persona = %v, want pirate", got)
+	}
+
+	// Idle eviction keeps it too
+	mgr.EvictIdle(0)
+	restored, _ = mgr.Restore(sess.ID)
+	if got := mgr.GetContext(restored, "persona"); got != "pirate" {
+		t.Errorf("persona after idle eviction = %v, want pirate", got)
+	}
+}
+
+func TestGetOrCreate_LoadsWithoutLock(t *testing.T) {
+	mgr := NewManager(nil, 50, nil)
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	mgr.SetLoader(func(key string) ([]Message, []Checkpoint, error) {
+		if key == Key("telegram", "slow", "") {
+			close(loading)
+			<-release
+		}
+		return nil, nil, nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mgr.GetOrCreate("telegram", "slow", "user1")
+	}()
+	<-loading
+
+	// Another chat isn't held up by the slow load
+	fast := make(chan struct{})
+	go func() {
+		mgr.GetOrCreate("telegram", "fast", "user2")
+		close(fast)
+	}()
+	select {
+	case <-fast:
+	case <-time.After(2 * time.Second):
+		t.Fatal("GetOrCreate blocked behind another chat's load")
+	}
+	close(release)
+	<-done
+	if n := mgr.Count(); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+}
+
+func TestEvictIdle(t *testing.T) {
+	mgr := NewManager(nil, 50, nil)
+	idle := mgr.GetOrCreate("telegram", "chat1", "user1")
+	active := mgr.GetOrCreate("telegram", "chat2", "user2")
+	idle.UpdatedAt = time.Now().Add(-2 * time.Hour)
+
+	if n := mgr.EvictIdle(time.Hour); n != 1 {
+		t.Errorf("EvictIdle = %d, want 1", n)
+	}
+	if mgr.Get(idle.ID) != nil || mgr.Get(active.ID) == nil {
+		t.Error("only the idle session should be evicted")
+	}
+	if n := mgr.Count(); n != 1 {
+		t.Errorf("Count = %d, want 1", n)
+	}
+}
+
 func TestContext(t *testing.T) {
 	mgr := NewManager(nil, 50, nil)
 	sess := mgr.GetOrCreate("telegram", "chat1", "user1")
@@ -517,6 +829,40 @@ func TestConcurrency(t *testing.T) {
 			t.Errorf("Expected 50 messages, got %d", len(sess.Messages))
 		}
 	})
+
+	t.Run("ModeUserSwitchesChat", func(t *testing.T) {
+		// The reply chat moves while tasks are spawned from the session
+		var notified sync.WaitGroup
+		mgr := NewManager(func(p, c, m string) error {
+			if c != "dm" && c != "group" {
+				t.Errorf("task result sent to chat %q", c)
+			}
+			notified.Done()
+			return nil
+		}, 50, nil)
+		mgr.SetMode(ModeUser)
+		mgr.SetTaskRunner(&MockTaskRunner{result: "done"})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			notified.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				chat := "dm"
+				if n%2 == 1 {
+					chat = "group"
+				}
+				sess := mgr.GetOrCreateThread("telegram", chat, "", "user")
+				if _, err := mgr.Spawn(sess, "task"); err != nil {
+					t.Error(err)
+					notified.Done()
+				}
+			}(i)
+		}
+		wg.Wait()
+		notified.Wait()
+	})
 }
 
 func TestStatus(t *testing.T) {
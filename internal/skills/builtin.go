@@ -1,6 +1,10 @@
 // Built-in skills that come with Magabot
 package skills
 
+// ProfilePrecise is the llm.profiles entry built-in skills with structured
+// answers use for their commands.
+const ProfilePrecise = "precise"
+
 // BuiltinSkills returns the built-in skill definitions
 func BuiltinSkills() []*Skill {
 	return []*Skill{
@@ -46,6 +50,7 @@ func translateSkill() *Skill {
 			Type:   "prompt",
 			Prompt: "The user wants to translate text. Identify the source language (auto-detect if not specified) and target language. Provide the translation clearly.",
 		},
+		Profile: ProfilePrecise,
 	}
 }
 
@@ -63,6 +68,7 @@ func summarizeSkill() *Skill {
 			Type:   "prompt",
 			Prompt: "The user wants a summary. Provide a concise summary with key points. Use bullet points for clarity.",
 		},
+		Profile: ProfilePrecise,
 	}
 }
 
@@ -103,6 +109,7 @@ func mathSkill() *Skill {
 			Type:   "prompt",
 			Prompt: "The user has a math question. Show the step-by-step solution clearly. Use proper mathematical notation.",
 		},
+		Profile: ProfilePrecise,
 	}
 }
 
@@ -0,0 +1,212 @@
+// Sandboxed execution of script skills
+package skills
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// maxScriptOutput caps captured script output to prevent OOM.
+const maxScriptOutput = 64 * 1024
+
+// ErrSandboxDenied is returned when the sandbox blocks a skill action.
+var ErrSandboxDenied = errors.New("blocked by skill sandbox")
+
+// ErrScriptsDisabled is returned for script skills unless AllowScripts is set.
+var ErrScriptsDisabled = errors.New("script skills are disabled (set skills.allow_scripts)")
+
+// SandboxConfig restricts what skills may run and reach.
+//
+// Script skills only run when AllowScripts is set. Outside sandbox mode they
+// run through the shell, but still with only the variables in scriptEnvKeys,
+// so secrets in the daemon's environment are never inherited.
+//
+// When enabled, script skills run without a shell: the script must be a single
+// command whose name is in AllowedCommands (shell operators, substitutions and
+// redirects are rejected). The process starts in the skill's directory with a
+// minimal environment, so secrets in the daemon's environment are not
+// inherited. API skills may only call hosts in AllowedHosts.
+//
+// Network isolation for scripts uses a separate network namespace and is only
+// available on Linux; it applies when AllowedHosts is empty. Elsewhere, or
+// when hosts are whitelisted, scripts keep network access (per-host filtering
+// of arbitrary processes would need a proxy).
+type SandboxConfig struct {
+	AllowScripts    bool
+	Enabled         bool
+	AllowedCommands []string // Command names, or paths relative to the skill dir
+	AllowedHosts    []string // Hosts API skills may call; "*.example.com" allowed
+}
+
+// sandboxEnvKeys are the only variables passed to sandboxed scripts.
+var sandboxEnvKeys = []string{"PATH", "LANG", "LC_ALL", "TZ"}
+
+// scriptEnvKeys are the only variables passed to scripts outside the sandbox.
+var scriptEnvKeys = append([]string{"HOME", "USER", "TMPDIR", "SHELL"}, sandboxEnvKeys...)
+
+// SetSandbox configures sandboxing for script and API skills.
+// Call before the manager is used concurrently.
+func (m *Manager) SetSandbox(cfg SandboxConfig) {
+	m.sandbox = cfg
+}
+
+// SandboxEnabled reports whether sandbox mode is on.
+func (m *Manager) SandboxEnabled() bool {
+	return m.sandbox.Enabled
+}
+
+// skillWorkDir returns the directory a skill's process runs in.
+func (m *Manager) skillWorkDir(skill *Skill) string {
+	if skill.Path != "" {
+		return skill.Path
+	}
+	return m.skillsDir
+}
+
+// scriptEnv returns the environment for a script process: the variables in
+// scriptEnvKeys, or for sandboxed scripts only locale/PATH variables with
+// HOME and TMPDIR pointing into the jail.
+func (m *Manager) scriptEnv(skill *Skill, workDir string, in Input) []string {
+	env := []string{
+		"MAGABOT_SKILL=" + skill.Name,
+		"MAGABOT_USER_ID=" + in.UserID,
+		"MAGABOT_PLATFORM=" + in.Platform,
+	}
+	keys := scriptEnvKeys
+	if m.sandbox.Enabled {
+		keys = sandboxEnvKeys
+	}
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	if !m.sandbox.Enabled {
+		return env
+	}
+	return append(env, "HOME="+workDir, "TMPDIR="+workDir)
+}
+
+// sandboxCommand validates a script against the allowlist and returns the
+// binary and arguments to exec directly (no shell).
+func (m *Manager) sandboxCommand(script, workDir string) (string, []string, error) {
+	args, err := splitCommand(script)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrSandboxDenied, err)
+	}
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("%w: empty script", ErrSandboxDenied)
+	}
+
+	name := args[0]
+	allowed := false
+	for _, c := range m.sandbox.AllowedCommands {
+		if c == name {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", nil, fmt.Errorf("%w: command %q is not in skills.allowed_commands", ErrSandboxDenied, name)
+	}
+
+	// Paths must stay inside the skill directory
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		bin := filepath.Join(workDir, name)
+		rel, err := filepath.Rel(workDir, bin)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(name) {
+			return "", nil, fmt.Errorf("%w: %q is outside the skill directory", ErrSandboxDenied, name)
+		}
+		return bin, args[1:], nil
+	}
+	return name, args[1:], nil
+}
+
+// isolateNetwork reports whether a sandboxed script should get its own
+// (empty) network namespace.
+func (m *Manager) isolateNetwork() bool {
+	return m.sandbox.Enabled && len(m.sandbox.AllowedHosts) == 0 && runtime.GOOS == "linux"
+}
+
+// checkHost enforces AllowedHosts for API skills in sandbox mode.
+func (m *Manager) checkHost(rawURL string) error {
+	if !m.sandbox.Enabled {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("%w: invalid URL %q", ErrSandboxDenied, rawURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range m.sandbox.AllowedHosts {
+		h = strings.ToLower(h)
+		if h == host || (strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %q is not in skills.allowed_hosts", ErrSandboxDenied, host)
+}
+
+// splitCommand splits a command line into words, honoring single and double
+// quotes. Anything that would need a shell (operators, substitution,
+// redirects, globbing, escapes) is rejected.
+func splitCommand(s string) ([]string, error) {
+	var (
+		args  []string
+		cur   strings.Builder
+		quote rune
+		inArg bool
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		case strings.ContainsRune(";&|<>`$(){}*?[]~\\!\n\r#", r):
+			return nil, fmt.Errorf("shell syntax %q is not allowed", r)
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// cappedBuffer collects up to maxScriptOutput bytes and discards the rest.
+type cappedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := maxScriptOutput - b.Len(); room < len(p) {
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package skills
+
+import (
+	"os"
+	"syscall"
+)
+
+// sandboxSysProcAttr places the process in new user and network namespaces,
+// leaving it with only a loopback interface.
+func sandboxSysProcAttr() *syscall.SysProcAttr {
+	uid, gid := os.Getuid(), os.Getgid()
+	return &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package skills
+
+import "syscall"
+
+// sandboxSysProcAttr is a no-op: network namespaces are Linux-only, so
+// sandboxing elsewhere is limited to the allowlist, env and working directory.
+func sandboxSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
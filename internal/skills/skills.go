@@ -3,16 +3,31 @@ package skills
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/kusa/magabot/internal/util"
 	"gopkg.in/yaml.v3"
 )
 
+// Execution limit defaults
+const (
+	DefaultTimeout       = 30 * time.Second
+	DefaultMaxConcurrent = 4
+)
+
+// ErrTimeout is returned when a skill does not finish within its timeout.
+var ErrTimeout = errors.New("skill timed out")
+
 // Skill represents a loaded skill
 type Skill struct {
 	// Metadata
@@ -31,6 +46,13 @@ type Skill struct {
 	// Context injection
 	SystemPrompt string `yaml:"system_prompt"`
 
+	// llm.profiles entry for messages that start with one of the skill's
+	// commands, e.g. "precise" for structured output
+	Profile string `yaml:"profile,omitempty"`
+
+	// Execution timeout override (default: manager timeout)
+	Timeout util.Duration `yaml:"timeout,omitempty"`
+
 	// File path (set at load time)
 	Path string `yaml:"-"`
 }
@@ -76,21 +98,66 @@ type APIAction struct {
 	Body    string            `yaml:"body"`
 }
 
+// Input is the message a skill is executed with. UserID and Platform are
+// passed to script skills as MAGABOT_USER_ID and MAGABOT_PLATFORM.
+type Input struct {
+	Message  string
+	UserID   string
+	Platform string
+}
+
 // Manager manages all loaded skills
 type Manager struct {
 	skills     map[string]*Skill
 	skillsDir  string
 	mu         sync.RWMutex
 	compiledRe map[string]*regexp.Regexp
+	timeout    time.Duration
+	slots      chan struct{} // concurrency cap for Execute
+	logger     *slog.Logger
+	run        func(ctx context.Context, skill *Skill, in Input) (string, error)
+	sandbox    SandboxConfig
 }
 
 // NewManager creates a new skill manager
 func NewManager(skillsDir string) *Manager {
-	return &Manager{
+	m := &Manager{
 		skills:     make(map[string]*Skill),
 		skillsDir:  skillsDir,
 		compiledRe: make(map[string]*regexp.Regexp),
+		timeout:    DefaultTimeout,
+		slots:      make(chan struct{}, DefaultMaxConcurrent),
+		logger:     slog.Default(),
+	}
+	m.run = m.runAction
+	return m
+}
+
+// SetLimits sets the default per-skill timeout and the maximum number of
+// skills executing at once. Non-positive values keep the defaults.
+// Call before the manager is used concurrently.
+func (m *Manager) SetLimits(timeout time.Duration, maxConcurrent int) {
+	if timeout > 0 {
+		m.timeout = timeout
+	}
+	if maxConcurrent > 0 {
+		m.slots = make(chan struct{}, maxConcurrent)
+	}
+}
+
+// SetLogger sets the logger used for execution warnings.
+func (m *Manager) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		m.logger = logger
+	}
+}
+
+// TimeoutFor returns the effective execution timeout for a skill.
+func (m *Manager) TimeoutFor(skill *Skill) time.Duration {
+	if d := skill.Timeout.Duration(); d > 0 {
+		return d
 	}
+	return m.timeout
 }
 
 // LoadAll loads all skills from the skills directory
@@ -221,6 +288,30 @@ func (m *Manager) IsSkillCommand(message string) bool {
 	return false
 }
 
+// CommandProfile returns the profile of the skill whose command starts the
+// message, or "" when there is none or it sets no profile.
+func (m *Manager) CommandProfile(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return ""
+	}
+	cmdLower := strings.ToLower(fields[0])
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, skill := range m.skills {
+		if skill.Profile == "" {
+			continue
+		}
+		for _, cmd := range skill.Triggers.Commands {
+			if cmdLower == cmd {
+				return skill.Profile
+			}
+		}
+	}
+	return ""
+}
+
 // List returns all loaded skills
 func (m *Manager) List() []*Skill {
 	m.mu.RLock()
@@ -250,6 +341,13 @@ func (m *Manager) Match(message string) []*Skill {
 	return matched
 }
 
+// Matches reports whether a message triggers the given skill
+func (m *Manager) Matches(skill *Skill, message string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.matchSkill(skill, message, strings.ToLower(message))
+}
+
 // matchSkill checks if a message matches a skill's triggers
 func (m *Manager) matchSkill(skill *Skill, message, messageLower string) bool {
 	// Always active skills
@@ -313,36 +411,138 @@ func (m *Manager) GetMatchedPrompts(message string) string {
 	return strings.Join(prompts, "\n\n")
 }
 
-// Execute executes a skill's action
+// Execute executes a skill's action within its timeout. At most
+// max-concurrent skills run at once; waiting for a slot counts against the
+// timeout, and an action that outlives its timeout keeps its slot until it
+// returns. Returns ErrTimeout if the skill does not finish in time.
 func (m *Manager) Execute(ctx context.Context, skill *Skill, message string) (string, error) {
+	return m.ExecuteInput(ctx, skill, Input{Message: message})
+}
+
+// ExecuteInput is like Execute but also carries the sender and platform.
+func (m *Manager) ExecuteInput(ctx context.Context, skill *Skill, in Input) (string, error) {
+	timeout := m.TimeoutFor(skill)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	slots := m.slots
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return "", m.execErr(ctx, skill, timeout)
+	}
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		// Freed when the action returns, not when the caller gives up on it
+		defer func() { <-slots }()
+		out, err := m.run(ctx, skill, in)
+		done <- result{out, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.out, r.err
+	case <-ctx.Done():
+		return "", m.execErr(ctx, skill, timeout)
+	}
+}
+
+// execErr maps a finished context to ErrTimeout (logging the skill) or the
+// caller's cancellation error.
+func (m *Manager) execErr(ctx context.Context, skill *Skill, timeout time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		m.logger.Warn("skill timed out", "skill", skill.Name, "timeout", timeout)
+		return fmt.Errorf("%w: %s after %s", ErrTimeout, skill.Name, timeout)
+	}
+	return ctx.Err()
+}
+
+// runAction dispatches on the action type. Implementations must honor ctx.
+func (m *Manager) runAction(ctx context.Context, skill *Skill, in Input) (string, error) {
 	switch skill.Actions.Type {
 	case "prompt":
 		// Return prompt to be injected (handled by LLM)
 		return skill.Actions.Prompt, nil
 
 	case "script":
-		return m.executeScript(ctx, skill, message)
+		return m.executeScript(ctx, skill, in)
 
 	case "api":
-		return m.executeAPI(ctx, skill, message)
+		return m.executeAPI(ctx, skill, in)
 
 	default:
 		return "", fmt.Errorf("unknown action type: %s", skill.Actions.Type)
 	}
 }
 
-// executeScript runs a shell script
-func (m *Manager) executeScript(ctx context.Context, skill *Skill, message string) (string, error) {
-	// Security: Scripts run in skill directory with limited env
-	// This is a placeholder - full implementation would use exec.CommandContext
-	return fmt.Sprintf("Script execution: %s", skill.Actions.Script), nil
+// executeScript runs the skill's script in its directory with the user
+// message on stdin. In sandbox mode the script is exec'd without a shell
+// under the sandbox restrictions (see SandboxConfig).
+func (m *Manager) executeScript(ctx context.Context, skill *Skill, in Input) (string, error) {
+	if !m.sandbox.AllowScripts {
+		m.logger.Warn("script skill not run", "skill", skill.Name, "error", ErrScriptsDisabled)
+		return "", ErrScriptsDisabled
+	}
+	workDir := m.skillWorkDir(skill)
+
+	var cmd *exec.Cmd
+	if m.sandbox.Enabled {
+		bin, args, err := m.sandboxCommand(skill.Actions.Script, workDir)
+		if err != nil {
+			m.logger.Warn("skill blocked by sandbox", "skill", skill.Name, "error", err)
+			return "", err
+		}
+		cmd = exec.CommandContext(ctx, bin, args...) // #nosec G204 -- allowlisted by sandbox
+		if m.isolateNetwork() {
+			cmd.SysProcAttr = sandboxSysProcAttr()
+		}
+	} else {
+		shell, args := "sh", []string{"-c", skill.Actions.Script}
+		if runtime.GOOS == "windows" {
+			shell, args = "cmd", []string{"/C", skill.Actions.Script}
+		}
+		cmd = exec.CommandContext(ctx, shell, args...) // #nosec G204 -- script comes from the installed skill
+	}
+	cmd.Dir = workDir
+	cmd.Env = m.scriptEnv(skill, workDir, in)
+	cmd.Stdin = strings.NewReader(in.Message)
+
+	var stdout, stderr cappedBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		if cmd.SysProcAttr != nil {
+			// Usually unprivileged user namespaces being turned off
+			return "", fmt.Errorf("%w: cannot isolate the script's network (user namespaces unavailable: %v); "+
+				"enable them or set skills.allowed_hosts", ErrSandboxDenied, err)
+		}
+		return "", fmt.Errorf("script failed: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		m.logger.Warn("skill script failed", "skill", skill.Name, "error", err,
+			"stderr", strings.TrimSpace(stderr.String()))
+		return "", fmt.Errorf("script failed: %w", err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
 }
 
 // executeAPI calls an external API
-func (m *Manager) executeAPI(ctx context.Context, skill *Skill, message string) (string, error) {
+func (m *Manager) executeAPI(ctx context.Context, skill *Skill, in Input) (string, error) {
 	if skill.Actions.API == nil {
 		return "", fmt.Errorf("API configuration missing")
 	}
+	if err := m.checkHost(skill.Actions.API.URL); err != nil {
+		m.logger.Warn("skill blocked by sandbox", "skill", skill.Name, "error", err)
+		return "", err
+	}
 
 	// This is a placeholder - full implementation would use http.Client
 	return fmt.Sprintf("API call: %s %s", skill.Actions.API.Method, skill.Actions.API.URL), nil
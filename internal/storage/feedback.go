@@ -0,0 +1,82 @@
+// Ratings collected from reactions on bot responses
+package storage
+
+import "fmt"
+
+// FeedbackStat summarizes ratings for one provider/model pair.
+type FeedbackStat struct {
+	Provider  string
+	Model     string
+	Responses int // Responses offered for rating
+	Up        int
+	Down      int
+}
+
+// SaveFeedbackTarget records a bot response that users may rate.
+func (s *Store) SaveFeedbackTarget(platform, chatID, messageID, provider, model string) error {
+	_, err := s.db.Exec(
+		`INSERT OR IGNORE INTO feedback_responses (platform, chat_id, message_id, provider, model)
+		 VALUES (?, ?, ?, ?, ?)`,
+		platform, chatID, messageID, provider, model,
+	)
+	return err
+}
+
+// RecordFeedback stores a user's rating (+1 or -1) of a response, replacing
+// any earlier rating by the same user. It reports false when the message is
+// not a tracked response.
+func (s *Store) RecordFeedback(platform, chatID, messageID, userID string, rating int) (bool, error) {
+	if rating != 1 && rating != -1 {
+		return false, fmt.Errorf("invalid rating %d", rating)
+	}
+	res, err := s.db.Exec(
+		`INSERT INTO feedback (response_id, user_id, rating, updated_at)
+		 SELECT id, ?, ?, CURRENT_TIMESTAMP FROM feedback_responses
+		 WHERE platform = ? AND chat_id = ? AND message_id = ?
+		 ON CONFLICT(response_id, user_id) DO UPDATE SET rating = excluded.rating, updated_at = excluded.updated_at`,
+		userID, rating, platform, chatID, messageID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ClearFeedback removes a user's rating of a response if it equals rating,
+// so withdrawing 👍 does not erase a 👎 given afterwards.
+func (s *Store) ClearFeedback(platform, chatID, messageID, userID string, rating int) error {
+	_, err := s.db.Exec(
+		`DELETE FROM feedback WHERE user_id = ? AND rating = ? AND response_id IN (
+			SELECT id FROM feedback_responses WHERE platform = ? AND chat_id = ? AND message_id = ?
+		 )`,
+		userID, rating, platform, chatID, messageID,
+	)
+	return err
+}
+
+// FeedbackStats returns rating totals per provider and model, most rated first.
+func (s *Store) FeedbackStats() ([]FeedbackStat, error) {
+	rows, err := s.db.Query(
+		`SELECT r.provider, r.model, COUNT(DISTINCT r.id),
+		        COALESCE(SUM(CASE WHEN f.rating > 0 THEN 1 ELSE 0 END), 0),
+		        COALESCE(SUM(CASE WHEN f.rating < 0 THEN 1 ELSE 0 END), 0)
+		 FROM feedback_responses r LEFT JOIN feedback f ON f.response_id = r.id
+		 GROUP BY r.provider, r.model
+		 ORDER BY COUNT(f.rating) DESC, r.provider, r.model`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var stats []FeedbackStat
+	for rows.Next() {
+		var st FeedbackStat
+		if err := rows.Scan(&st.Provider, &st.Model, &st.Responses, &st.Up, &st.Down); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
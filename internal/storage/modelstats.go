@@ -0,0 +1,106 @@
+// Saved snapshots of LLM latency and error stats
+package storage
+
+import "time"
+
+// ModelStatSnapshot is the rolling stats of one provider and model at one
+// point in time.
+type ModelStatSnapshot struct {
+	Provider    string
+	Model       string
+	Requests    int
+	Errors      int
+	P50         time.Duration
+	P95         time.Duration
+	LastError   string
+	LastErrorAt time.Time // zero when the model never failed
+	TakenAt     time.Time
+}
+
+// SaveModelStats stores a set of snapshots taken together.
+func (s *Store) SaveModelStats(snaps []ModelStatSnapshot) error {
+	if len(snaps) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, st := range snaps {
+		var lastErrAt any
+		if !st.LastErrorAt.IsZero() {
+			lastErrAt = st.LastErrorAt.UTC()
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO model_stats (provider, model, requests, errors, p50_ms, p95_ms, last_error, last_error_at, taken_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			st.Provider, st.Model, st.Requests, st.Errors, st.P50.Milliseconds(), st.P95.Milliseconds(),
+			st.LastError, lastErrAt, st.TakenAt.UTC(),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LatestModelStats returns the newest snapshot of every provider and model.
+func (s *Store) LatestModelStats() ([]ModelStatSnapshot, error) {
+	return s.queryModelStats(
+		`SELECT provider, model, requests, errors, p50_ms, p95_ms, last_error, last_error_at, taken_at
+		 FROM model_stats m
+		 WHERE id = (SELECT MAX(id) FROM model_stats WHERE provider = m.provider AND model = m.model)
+		 ORDER BY provider, model`,
+	)
+}
+
+// ModelStatHistory returns the snapshots of a provider and model taken
+// since a time, oldest first, for health trends.
+func (s *Store) ModelStatHistory(provider, model string, since time.Time) ([]ModelStatSnapshot, error) {
+	return s.queryModelStats(
+		`SELECT provider, model, requests, errors, p50_ms, p95_ms, last_error, last_error_at, taken_at
+		 FROM model_stats WHERE provider = ? AND model = ? AND taken_at >= ?
+		 ORDER BY taken_at`,
+		provider, model, since.UTC(),
+	)
+}
+
+// queryModelStats runs a snapshot query and decodes its rows.
+func (s *Store) queryModelStats(query string, args ...any) ([]ModelStatSnapshot, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []ModelStatSnapshot
+	for rows.Next() {
+		var st ModelStatSnapshot
+		var p50, p95 int64
+		var lastErrAt *time.Time
+		if err := rows.Scan(&st.Provider, &st.Model, &st.Requests, &st.Errors, &p50, &p95,
+			&st.LastError, &lastErrAt, &st.TakenAt); err != nil {
+			return nil, err
+		}
+		st.P50, st.P95 = time.Duration(p50)*time.Millisecond, time.Duration(p95)*time.Millisecond
+		if lastErrAt != nil {
+			st.LastErrorAt = *lastErrAt
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+// PurgeOldModelStats deletes snapshots older than retention days.
+func (s *Store) PurgeOldModelStats(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).UTC()
+	result, err := s.db.Exec(`DELETE FROM model_stats WHERE taken_at < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,27 @@
+// Daily per-user usage for security.DailyQuota
+package storage
+
+// QuotaUsage returns the messages and tokens userID used on day.
+func (s *Store) QuotaUsage(userID, day string) (messages, tokens int64, err error) {
+	err = s.db.QueryRow(
+		`SELECT COALESCE(SUM(messages), 0), COALESCE(SUM(tokens), 0) FROM daily_quota WHERE user_id = ? AND day = ?`,
+		userID, day,
+	).Scan(&messages, &tokens)
+	return messages, tokens, err
+}
+
+// AddQuotaUsage adds messages and tokens to userID's usage on day.
+func (s *Store) AddQuotaUsage(userID, day string, messages, tokens int64) error {
+	_, err := s.db.Exec(
+		`INSERT INTO daily_quota (user_id, day, messages, tokens) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(user_id, day) DO UPDATE SET messages = messages + excluded.messages, tokens = tokens + excluded.tokens`,
+		userID, day, messages, tokens,
+	)
+	return err
+}
+
+// PurgeQuotaBefore deletes usage of days before day.
+func (s *Store) PurgeQuotaBefore(day string) error {
+	_, err := s.db.Exec(`DELETE FROM daily_quota WHERE day < ?`, day)
+	return err
+}
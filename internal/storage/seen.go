@@ -0,0 +1,18 @@
+// Users seen knocking without access
+package storage
+
+import "time"
+
+// MarkSeen records that a user without access has messaged the bot and
+// reports whether this is the first time. userID is the hashed ID.
+func (s *Store) MarkSeen(platform, userID string) (bool, error) {
+	res, err := s.db.Exec(
+		`INSERT OR IGNORE INTO seen_users (platform, user_id, first_seen) VALUES (?, ?, ?)`,
+		platform, userID, time.Now(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
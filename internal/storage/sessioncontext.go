@@ -0,0 +1,65 @@
+// Settings of sessions evicted from memory
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveSessionContext keeps the context (persona, language, ...) of a
+// session evicted from memory, replacing any kept before.
+func (s *Store) SaveSessionContext(sessionKey string, context map[string]interface{}) error {
+	data, err := json.Marshal(context)
+	if err != nil {
+		return fmt.Errorf("encode session context: %w", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO session_context (session_key, context, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)`,
+		sessionKey, string(data),
+	)
+	return err
+}
+
+// PurgeOldSessionContexts deletes the contexts of sessions evicted more than
+// retention days ago that never came back.
+func (s *Store) PurgeOldSessionContexts(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).UTC().Format(time.DateTime)
+	result, err := s.db.Exec(`DELETE FROM session_context WHERE updated_at < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// TakeSessionContext returns and removes the context kept for a session, or
+// nil when there is none. Values come back as JSON decodes them.
+func (s *Store) TakeSessionContext(sessionKey string) (map[string]interface{}, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var data string
+	err = tx.QueryRow(`SELECT context FROM session_context WHERE session_key = ?`, sessionKey).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM session_context WHERE session_key = ?`, sessionKey); err != nil {
+		return nil, err
+	}
+	var context map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &context); err != nil {
+		return nil, fmt.Errorf("decode session context: %w", err)
+	}
+	return context, tx.Commit()
+}
@@ -0,0 +1,65 @@
+// Message volume over time for analytics
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatBucket counts the messages of one platform and provider within one
+// time bucket. Provider is empty for incoming messages and replies that did
+// not come from an LLM.
+type StatBucket struct {
+	Start    time.Time // Bucket start, local time
+	Platform string
+	Provider string
+	In       int64 // Messages from users
+	Out      int64 // Bot replies
+}
+
+// bucketExprs maps MessageStats groupBy values to SQL expressions for the
+// local start of a message's bucket. Weeks start on Monday.
+var bucketExprs = map[string]string{
+	"hour":  `strftime('%Y-%m-%d %H:00:00', timestamp, 'localtime')`,
+	"day":   `strftime('%Y-%m-%d 00:00:00', timestamp, 'localtime')`,
+	"week":  `strftime('%Y-%m-%d 00:00:00', timestamp, 'localtime', '-6 days', 'weekday 1')`,
+	"month": `strftime('%Y-%m-01 00:00:00', timestamp, 'localtime')`,
+}
+
+// MessageStats returns message counts for [from, to) grouped into hour, day,
+// week or month buckets per platform and provider, oldest bucket first.
+// Buckets without messages are omitted.
+func (s *Store) MessageStats(from, to time.Time, groupBy string) ([]StatBucket, error) {
+	expr, ok := bucketExprs[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid groupBy %q: use hour, day, week or month", groupBy)
+	}
+	// The range filter compares the indexed timestamp column directly
+	rows, err := s.db.Query(
+		`SELECT `+expr+` AS bucket, platform, provider,
+		        SUM(CASE WHEN direction = 'in' THEN 1 ELSE 0 END),
+		        SUM(CASE WHEN direction = 'out' THEN 1 ELSE 0 END)
+		 FROM messages WHERE timestamp >= ? AND timestamp < ?
+		 GROUP BY bucket, platform, provider
+		 ORDER BY bucket, platform, provider`,
+		from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []StatBucket
+	for rows.Next() {
+		var b StatBucket
+		var start string
+		if err := rows.Scan(&start, &b.Platform, &b.Provider, &b.In, &b.Out); err != nil {
+			return nil, err
+		}
+		if b.Start, err = time.ParseInLocation(time.DateTime, start, time.Local); err != nil {
+			return nil, fmt.Errorf("parse bucket %q: %w", start, err)
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
@@ -26,6 +26,7 @@ type Message struct {
 	Content   string // Encrypted
 	Timestamp time.Time
 	Direction string // "in" or "out"
+	Provider  string // LLM provider that produced an outgoing answer; empty otherwise
 }
 
 // Session represents a platform session
@@ -121,6 +122,79 @@ func (s *Store) migrate() error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_conv_session ON conversation_history(session_key)`,
 		`CREATE INDEX IF NOT EXISTS idx_conv_session_ts ON conversation_history(session_key, timestamp)`,
+
+		`CREATE TABLE IF NOT EXISTS feedback_responses (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			platform TEXT NOT NULL,
+			chat_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			model TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(platform, chat_id, message_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS feedback (
+			response_id INTEGER NOT NULL REFERENCES feedback_responses(id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL,
+			rating INTEGER NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (response_id, user_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS conversation_checkpoints (
+			session_key TEXT NOT NULL,
+			name TEXT NOT NULL,
+			messages TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (session_key, name)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS session_context (
+			session_key TEXT PRIMARY KEY,
+			context TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS dead_letters (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			platform TEXT NOT NULL,
+			chat_id TEXT NOT NULL,
+			content TEXT NOT NULL,
+			error TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			replay INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS seen_users (
+			platform TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			first_seen DATETIME NOT NULL,
+			PRIMARY KEY (platform, user_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS model_stats (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			provider TEXT NOT NULL,
+			model TEXT NOT NULL,
+			requests INTEGER NOT NULL,
+			errors INTEGER NOT NULL,
+			p50_ms INTEGER NOT NULL,
+			p95_ms INTEGER NOT NULL,
+			last_error TEXT NOT NULL DEFAULT '',
+			last_error_at DATETIME,
+			taken_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_model_stats_model ON model_stats(provider, model, taken_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_model_stats_taken ON model_stats(taken_at)`,
+
+		`CREATE TABLE IF NOT EXISTS daily_quota (
+			user_id TEXT NOT NULL,
+			day TEXT NOT NULL,
+			messages INTEGER NOT NULL DEFAULT 0,
+			tokens INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, day)
+		)`,
 	}
 
 	for _, m := range migrations {
@@ -129,9 +203,29 @@ func (s *Store) migrate() error {
 		}
 	}
 
+	// Columns added after their table was first released
+	if err := s.addColumn("messages", "provider", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("migration: %w", err)
+	}
+
 	return nil
 }
 
+// addColumn adds a column to an existing table unless it is already there.
+func (s *Store) addColumn(table, column, definition string) error {
+	var n int
+	if err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column,
+	).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
 // Close closes the database
 func (s *Store) Close() error {
 	return s.db.Close()
@@ -140,9 +234,9 @@ func (s *Store) Close() error {
 // SaveMessage saves a message
 func (s *Store) SaveMessage(msg *Message) error {
 	_, err := s.db.Exec(
-		`INSERT INTO messages (platform, chat_id, user_id, username, content, timestamp, direction)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		msg.Platform, msg.ChatID, msg.UserID, msg.Username, msg.Content, msg.Timestamp, msg.Direction,
+		`INSERT INTO messages (platform, chat_id, user_id, username, content, timestamp, direction, provider)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		msg.Platform, msg.ChatID, msg.UserID, msg.Username, msg.Content, msg.Timestamp, msg.Direction, msg.Provider,
 	)
 	return err
 }
@@ -150,7 +244,7 @@ func (s *Store) SaveMessage(msg *Message) error {
 // GetMessages retrieves messages for a chat
 func (s *Store) GetMessages(platform, chatID string, limit int) ([]Message, error) {
 	rows, err := s.db.Query(
-		`SELECT id, platform, chat_id, user_id, username, content, timestamp, direction
+		`SELECT id, platform, chat_id, user_id, username, content, timestamp, direction, provider
 		 FROM messages WHERE platform = ? AND chat_id = ?
 		 ORDER BY timestamp DESC LIMIT ?`,
 		platform, chatID, limit,
@@ -163,7 +257,7 @@ func (s *Store) GetMessages(platform, chatID string, limit int) ([]Message, erro
 	var messages []Message
 	for rows.Next() {
 		var m Message
-		if err := rows.Scan(&m.ID, &m.Platform, &m.ChatID, &m.UserID, &m.Username, &m.Content, &m.Timestamp, &m.Direction); err != nil {
+		if err := rows.Scan(&m.ID, &m.Platform, &m.ChatID, &m.UserID, &m.Username, &m.Content, &m.Timestamp, &m.Direction, &m.Provider); err != nil {
 			return nil, err
 		}
 		messages = append(messages, m)
@@ -292,6 +386,20 @@ func (s *Store) PurgeOldMessages(retentionDays int) (int64, error) {
 	return result.RowsAffected()
 }
 
+// PurgeOldAuditLogs deletes audit log entries older than retention days.
+func (s *Store) PurgeOldAuditLogs(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	// timestamp defaults to CURRENT_TIMESTAMP, which SQLite writes in UTC
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).UTC().Format(time.DateTime)
+	result, err := s.db.Exec(`DELETE FROM audit_log WHERE timestamp < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // Stats returns storage statistics
 func (s *Store) Stats() (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
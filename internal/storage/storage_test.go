@@ -1,6 +1,7 @@
 package storage_test
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
@@ -30,6 +31,34 @@ func TestNew_ValidPath(t *testing.T) {
 	defer func() { _ = store.Close() }()
 }
 
+func TestNew_AddsProviderColumn(t *testing.T) {
+	// A database created before messages had a provider column
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, platform TEXT NOT NULL, chat_id TEXT NOT NULL,
+		user_id TEXT NOT NULL, username TEXT, content TEXT NOT NULL, timestamp DATETIME NOT NULL,
+		direction TEXT NOT NULL, created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	for i := 0; i < 2; i++ { // migrating twice is a no-op
+		store, err := storage.New(dbPath)
+		if err != nil {
+			t.Fatalf("New on old schema: %v", err)
+		}
+		if err := store.SaveMessage(&storage.Message{Platform: "telegram", ChatID: "c", UserID: "u", Content: "x", Timestamp: time.Now(), Direction: "out", Provider: "openai"}); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+		_ = store.Close()
+	}
+}
+
 func TestNew_InvalidPath(t *testing.T) {
 	// Use a regular file as the parent so MkdirAll fails on all platforms.
 	blocker := filepath.Join(t.TempDir(), "blocker")
@@ -313,6 +342,66 @@ func TestPurgeOldMessages_ZeroDays(t *testing.T) {
 	}
 }
 
+func TestPurgeOldAuditLogAndDeadLetters(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+
+	for i := 0; i < 3; i++ {
+		_ = store.AuditLog("telegram", "u", "login", "")
+		_ = store.SaveDeadLetter(&storage.DeadLetter{Platform: "telegram", ChatID: "42", Content: "x", Error: "timeout", Attempts: 3})
+	}
+	_, _ = store.QueueDeadLetterReplay(2)
+	for _, key := range []string{"telegram:old", "telegram:new"} {
+		_ = store.SaveSessionContext(key, map[string]interface{}{"persona": "pirate"})
+	}
+
+	// Backdate the first two rows of each table
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	old := time.Now().AddDate(0, 0, -40).UTC().Format(time.DateTime)
+	if _, err := db.Exec(`UPDATE audit_log SET timestamp = ? WHERE id <= 2`, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE dead_letters SET created_at = ? WHERE id <= 2`, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE session_context SET updated_at = ? WHERE session_key = 'telegram:old'`, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := store.PurgeOldAuditLogs(0); err != nil || n != 0 {
+		t.Errorf("PurgeOldAuditLogs(0) = %d, %v", n, err)
+	}
+	if n, err := store.PurgeOldAuditLogs(30); err != nil || n != 2 {
+		t.Errorf("PurgeOldAuditLogs(30) = %d, %v, want 2", n, err)
+	}
+	if entries, _ := store.GetAuditLogs(10); len(entries) != 1 {
+		t.Errorf("audit entries left = %d, want 1", len(entries))
+	}
+
+	// Dead letter 2 is queued for replay and survives
+	if n, err := store.PurgeOldDeadLetters(30); err != nil || n != 1 {
+		t.Errorf("PurgeOldDeadLetters(30) = %d, %v, want 1", n, err)
+	}
+	if list, _ := store.ListDeadLetters(10); len(list) != 2 {
+		t.Errorf("dead letters left = %d, want 2", len(list))
+	}
+
+	if n, err := store.PurgeOldSessionContexts(30); err != nil || n != 1 {
+		t.Errorf("PurgeOldSessionContexts(30) = %d, %v, want 1", n, err)
+	}
+	if got, _ := store.TakeSessionContext("telegram:new"); got == nil {
+		t.Error("recent session context was purged")
+	}
+}
+
 func TestSetGetConfig(t *testing.T) {
 	store := newTestStore(t)
 
@@ -422,3 +511,320 @@ func TestClose(t *testing.T) {
 		t.Error("expected error after Close, got nil")
 	}
 }
+
+func TestFeedback(t *testing.T) {
+	store := newTestStore(t)
+
+	if err := store.SaveFeedbackTarget("slack", "C1", "100.1", "anthropic", "claude"); err != nil {
+		t.Fatalf("SaveFeedbackTarget: %v", err)
+	}
+	if err := store.SaveFeedbackTarget("slack", "C1", "100.2", "openai", "gpt"); err != nil {
+		t.Fatalf("SaveFeedbackTarget: %v", err)
+	}
+
+	// Untracked messages are ignored
+	if tracked, err := store.RecordFeedback("slack", "C1", "999", "u1", 1); err != nil || tracked {
+		t.Errorf("untracked message: tracked=%v err=%v", tracked, err)
+	}
+
+	for _, r := range []struct {
+		msg, user string
+		rating    int
+	}{
+		{"100.1", "u1", 1},
+		{"100.1", "u2", 1},
+		{"100.1", "u2", -1}, // changed mind
+		{"100.2", "u1", -1},
+	} {
+		if tracked, err := store.RecordFeedback("slack", "C1", r.msg, r.user, r.rating); err != nil || !tracked {
+			t.Fatalf("RecordFeedback(%s, %s): tracked=%v err=%v", r.msg, r.user, tracked, err)
+		}
+	}
+
+	// Removing a reaction the user no longer has is a no-op
+	if err := store.ClearFeedback("slack", "C1", "100.1", "u2", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ClearFeedback("slack", "C1", "100.2", "u1", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := store.FeedbackStats()
+	if err != nil {
+		t.Fatalf("FeedbackStats: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 models, got %+v", stats)
+	}
+	want := storage.FeedbackStat{Provider: "anthropic", Model: "claude", Responses: 1, Up: 1, Down: 1}
+	if stats[0] != want {
+		t.Errorf("stats[0] = %+v, want %+v", stats[0], want)
+	}
+	if got := stats[1]; got.Model != "gpt" || got.Responses != 1 || got.Up != 0 || got.Down != 0 {
+		t.Errorf("stats[1] = %+v, want gpt with no ratings", got)
+	}
+
+	if _, err := store.RecordFeedback("slack", "C1", "100.1", "u1", 5); err == nil {
+		t.Error("expected error for invalid rating")
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now().Truncate(time.Second)
+
+	cp := &storage.Checkpoint{
+		SessionKey: "slack:C1#100.1",
+		Name:       "start",
+		Messages: []storage.ConversationMessage{
+			{Role: "user", Content: "hi", Timestamp: now},
+			{Role: "assistant", Content: "hello", Timestamp: now},
+		},
+		CreatedAt: now,
+	}
+	if err := store.SaveCheckpoint(cp); err != nil {
+		t.Fatalf("SaveCheckpoint: %v", err)
+	}
+	cp.Messages = cp.Messages[:1]
+	if err := store.SaveCheckpoint(cp); err != nil {
+		t.Fatalf("SaveCheckpoint overwrite: %v", err)
+	}
+
+	cps, err := store.ListCheckpoints()
+	if err != nil {
+		t.Fatalf("ListCheckpoints: %v", err)
+	}
+	if len(cps) != 1 || cps[0].Name != "start" || len(cps[0].Messages) != 1 || cps[0].Messages[0].Content != "hi" {
+		t.Fatalf("unexpected checkpoints: %+v", cps)
+	}
+
+	_ = store.SaveConversationMessage(cp.SessionKey, "user", "branch", now)
+	if err := store.ReplaceConversationHistory(cp.SessionKey, cps[0].Messages); err != nil {
+		t.Fatalf("ReplaceConversationHistory: %v", err)
+	}
+	history, _ := store.GetConversationHistory(cp.SessionKey, 10)
+	if len(history) != 1 || history[0].Content != "hi" {
+		t.Errorf("history after replace = %+v", history)
+	}
+
+	if err := store.DeleteCheckpoint(cp.SessionKey, "start"); err != nil {
+		t.Fatalf("DeleteCheckpoint: %v", err)
+	}
+	if cps, _ := store.ListCheckpoints(); len(cps) != 0 {
+		t.Errorf("checkpoints after delete = %+v", cps)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	store := newTestStore(t)
+
+	for _, text := range []string{"first", "second"} {
+		if err := store.SaveDeadLetter(&storage.DeadLetter{Platform: "telegram", ChatID: "42", Content: text, Error: "timeout", Attempts: 3}); err != nil {
+			t.Fatalf("SaveDeadLetter: %v", err)
+		}
+	}
+
+	list, err := store.ListDeadLetters(10)
+	if err != nil {
+		t.Fatalf("ListDeadLetters: %v", err)
+	}
+	if len(list) != 2 || list[0].Content != "second" || list[0].Attempts != 3 || list[0].Replay {
+		t.Fatalf("unexpected dead letters: %+v", list)
+	}
+
+	if pending, _ := store.PendingReplays(); len(pending) != 0 {
+		t.Fatalf("pending before replay = %+v", pending)
+	}
+	if n, err := store.QueueDeadLetterReplay(list[1].ID); err != nil || n != 1 {
+		t.Fatalf("QueueDeadLetterReplay = %d, %v", n, err)
+	}
+	pending, _ := store.PendingReplays()
+	if len(pending) != 1 || pending[0].Content != "first" {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	if err := store.FailDeadLetterReplay(pending[0].ID, "blocked"); err != nil {
+		t.Fatalf("FailDeadLetterReplay: %v", err)
+	}
+	if pending, _ := store.PendingReplays(); len(pending) != 0 {
+		t.Errorf("pending after failed replay = %+v", pending)
+	}
+
+	if n, _ := store.QueueDeadLetterReplay(); n != 2 {
+		t.Errorf("QueueDeadLetterReplay all = %d, want 2", n)
+	}
+	if err := store.DeleteDeadLetter(list[0].ID); err != nil {
+		t.Fatalf("DeleteDeadLetter: %v", err)
+	}
+	list, _ = store.ListDeadLetters(10)
+	if len(list) != 1 || list[0].Error != "blocked" || list[0].Attempts != 4 || !list[0].Replay {
+		t.Errorf("after delete = %+v", list)
+	}
+}
+
+func TestMessageStats(t *testing.T) {
+	store := newTestStore(t)
+
+	day := time.Date(2026, 3, 4, 0, 0, 0, 0, time.Local) // a Wednesday
+	save := func(platform, direction, provider string, ts time.Time) {
+		t.Helper()
+		if err := store.SaveMessage(&storage.Message{
+			Platform: platform, ChatID: "c", UserID: "u", Content: "x",
+			Timestamp: ts, Direction: direction, Provider: provider,
+		}); err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+	save("telegram", "in", "", day.Add(9*time.Hour))
+	save("telegram", "out", "anthropic", day.Add(9*time.Hour+time.Second))
+	save("telegram", "in", "", day.Add(10*time.Hour))
+	save("telegram", "out", "anthropic", day.Add(10*time.Hour+time.Second))
+	save("slack", "in", "", day.Add(30*time.Hour))
+	save("slack", "out", "openai", day.Add(30*time.Hour+time.Second))
+	save("slack", "in", "", day.AddDate(0, 0, 30)) // outside the range
+
+	from, to := day, day.AddDate(0, 0, 7)
+	got, err := store.MessageStats(from, to, "day")
+	if err != nil {
+		t.Fatalf("MessageStats: %v", err)
+	}
+	want := []storage.StatBucket{
+		{Start: day, Platform: "telegram", Provider: "", In: 2},
+		{Start: day, Platform: "telegram", Provider: "anthropic", Out: 2},
+		{Start: day.AddDate(0, 0, 1), Platform: "slack", Provider: "", In: 1},
+		{Start: day.AddDate(0, 0, 1), Platform: "slack", Provider: "openai", Out: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("buckets = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || got[i].Platform != want[i].Platform ||
+			got[i].Provider != want[i].Provider || got[i].In != want[i].In || got[i].Out != want[i].Out {
+			t.Errorf("bucket %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	hours, _ := store.MessageStats(from, to, "hour")
+	if len(hours) != 6 || hours[2].Start.Hour() != 10 {
+		t.Errorf("hourly buckets = %+v", hours)
+	}
+	weeks, _ := store.MessageStats(from, to, "week")
+	monday := day.AddDate(0, 0, -2)
+	if len(weeks) != 4 || !weeks[0].Start.Equal(monday) {
+		t.Errorf("weekly buckets = %+v, want 4 starting %v", weeks, monday)
+	}
+	if _, err := store.MessageStats(from, to, "year"); err == nil {
+		t.Error("invalid groupBy should fail")
+	}
+}
+
+func TestMarkSeen(t *testing.T) {
+	store := newTestStore(t)
+
+	for i, want := range []bool{true, false} {
+		first, err := store.MarkSeen("telegram", "hash1")
+		if err != nil {
+			t.Fatalf("MarkSeen: %v", err)
+		}
+		if first != want {
+			t.Errorf("call %d: first = %v, want %v", i+1, first, want)
+		}
+	}
+	if first, err := store.MarkSeen("slack", "hash1"); err != nil || !first {
+		t.Errorf("MarkSeen on another platform = %v, %v; want true", first, err)
+	}
+}
+
+func TestModelStats(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now().Truncate(time.Second)
+	failedAt := now.Add(-time.Minute)
+
+	if err := store.SaveModelStats([]storage.ModelStatSnapshot{
+		{Provider: "openai", Model: "gpt-4o", Requests: 10, P50: 800 * time.Millisecond, P95: 2 * time.Second, TakenAt: now.Add(-2 * time.Hour)},
+		{Provider: "anthropic", Model: "claude", Requests: 4, Errors: 1, LastError: "overloaded", LastErrorAt: failedAt, TakenAt: now.Add(-2 * time.Hour)},
+	}); err != nil {
+		t.Fatalf("SaveModelStats: %v", err)
+	}
+	if err := store.SaveModelStats([]storage.ModelStatSnapshot{
+		{Provider: "openai", Model: "gpt-4o", Requests: 20, Errors: 2, P50: 900 * time.Millisecond, P95: 3 * time.Second, TakenAt: now},
+	}); err != nil {
+		t.Fatalf("SaveModelStats: %v", err)
+	}
+
+	latest, err := store.LatestModelStats()
+	if err != nil {
+		t.Fatalf("LatestModelStats: %v", err)
+	}
+	if len(latest) != 2 {
+		t.Fatalf("LatestModelStats returned %d snapshots, want 2", len(latest))
+	}
+	if a := latest[0]; a.Provider != "anthropic" || a.LastError != "overloaded" || !a.LastErrorAt.Equal(failedAt) {
+		t.Errorf("anthropic snapshot = %+v", a)
+	}
+	if o := latest[1]; o.Requests != 20 || o.P95 != 3*time.Second || !o.LastErrorAt.IsZero() || !o.TakenAt.Equal(now) {
+		t.Errorf("openai snapshot = %+v, want the newest", o)
+	}
+
+	history, err := store.ModelStatHistory("openai", "gpt-4o", now.Add(-3*time.Hour))
+	if err != nil {
+		t.Fatalf("ModelStatHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Requests != 10 || history[1].Requests != 20 {
+		t.Errorf("ModelStatHistory = %+v, want both snapshots oldest first", history)
+	}
+
+	// Retention counts in days; nothing here is a day old
+	if n, err := store.PurgeOldModelStats(1); err != nil || n != 0 {
+		t.Errorf("PurgeOldModelStats = %d, %v; want 0", n, err)
+	}
+}
+
+func TestQuotaUsage(t *testing.T) {
+	store := newTestStore(t)
+
+	for _, add := range [][2]int64{{1, 300}, {1, 200}} {
+		if err := store.AddQuotaUsage("hash1", "2026-03-01", add[0], add[1]); err != nil {
+			t.Fatalf("AddQuotaUsage: %v", err)
+		}
+	}
+	_ = store.AddQuotaUsage("hash1", "2026-03-02", 1, 50)
+
+	if m, tok, err := store.QuotaUsage("hash1", "2026-03-01"); err != nil || m != 2 || tok != 500 {
+		t.Errorf("QuotaUsage = %d, %d, %v; want 2, 500", m, tok, err)
+	}
+	if m, tok, err := store.QuotaUsage("hash2", "2026-03-01"); err != nil || m != 0 || tok != 0 {
+		t.Errorf("QuotaUsage of an unknown user = %d, %d, %v; want zeros", m, tok, err)
+	}
+
+	if err := store.PurgeQuotaBefore("2026-03-02"); err != nil {
+		t.Fatal(err)
+	}
+	if m, _, _ := store.QuotaUsage("hash1", "2026-03-01"); m != 0 {
+		t.Errorf("usage before the purge day kept: %d messages", m)
+	}
+	if m, _, _ := store.QuotaUsage("hash1", "2026-03-02"); m != 1 {
+		t.Errorf("usage on the purge day = %d messages, want 1", m)
+	}
+}
+
+func TestSessionContext(t *testing.T) {
+	store := newTestStore(t)
+
+	if got, err := store.TakeSessionContext("telegram:1"); err != nil || got != nil {
+		t.Fatalf("TakeSessionContext with none kept = (%v, %v)", got, err)
+	}
+	if err := store.SaveSessionContext("telegram:1", map[string]interface{}{"persona": "pirate", "temperature": 0.4}); err != nil {
+		t.Fatalf("SaveSessionContext: %v", err)
+	}
+	got, err := store.TakeSessionContext("telegram:1")
+	if err != nil {
+		t.Fatalf("TakeSessionContext: %v", err)
+	}
+	if got["persona"] != "pirate" || got["temperature"] != 0.4 {
+		t.Errorf("context = %v", got)
+	}
+	if again, _ := store.TakeSessionContext("telegram:1"); again != nil {
+		t.Errorf("context not removed when taken: %v", again)
+	}
+}
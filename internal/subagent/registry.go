@@ -587,8 +587,18 @@ func (r *Registry) WaitFor(id string, timeout time.Duration) (*Agent, error) {
 	}
 }
 
-// Cleanup removes completed agents older than the specified duration.
+// Cleanup removes completed agents older than the specified duration and
+// saves the registry when any were removed.
 func (r *Registry) Cleanup(olderThan time.Duration) int {
+	count := r.cleanup(olderThan)
+	if count > 0 {
+		r.logger.Info("cleaned up completed agents", "count", count)
+		r.persist()
+	}
+	return count
+}
+
+func (r *Registry) cleanup(olderThan time.Duration) int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -617,10 +627,6 @@ func (r *Registry) Cleanup(olderThan time.Duration) int {
 		}
 	}
 
-	if count > 0 {
-		r.logger.Info("cleaned up completed agents", "count", count)
-	}
-
 	return count
 }
 
@@ -294,6 +294,12 @@ func TestRegistryCleanup(t *testing.T) {
 	if stats["total"] != 0 {
 		t.Errorf("expected 0 agents after cleanup, got %d", stats["total"])
 	}
+
+	// Cleanup is persisted
+	reloaded, _ := NewRegistry(Config{DataDir: tmpDir})
+	if total := reloaded.Stats()["total"]; total != 0 {
+		t.Errorf("expected 0 agents after reload, got %d", total)
+	}
 }
 
 func TestRegistryTimeout(t *testing.T) {
@@ -0,0 +1,100 @@
+// Package telemetry traces the request path with OpenTelemetry. Tracing is
+// off until Setup is given an OTLP endpoint; until then Start returns the
+// context unchanged and a no-op span, so instrumented code costs nothing.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies magabot's spans to the tracer provider.
+const instrumentationName = "github.com/kusa/magabot"
+
+var (
+	enabled atomic.Bool
+	tracer  trace.Tracer
+
+	// noopSpan is returned by Start while tracing is disabled.
+	noopSpan = trace.SpanFromContext(context.Background())
+)
+
+// Config configures the OTLP trace exporter.
+type Config struct {
+	Endpoint       string            // OTLP/HTTP URL, e.g. "http://localhost:4318"; empty disables tracing
+	Headers        map[string]string // Sent with every export, e.g. a backend API key
+	ServiceVersion string
+}
+
+// Setup starts exporting spans to cfg.Endpoint. The returned function
+// flushes pending spans and stops the exporter; call it on shutdown. With
+// no endpoint, tracing stays disabled and shutdown does nothing.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if u, err := url.Parse(cfg.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid otlp endpoint %q: want an http or https URL", cfg.Endpoint)
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(cfg.Endpoint)}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("otlp exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "magabot"),
+			attribute.String("service.version", cfg.ServiceVersion),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	use(provider)
+	return provider.Shutdown, nil
+}
+
+// use sends spans to provider from now on.
+func use(provider trace.TracerProvider) {
+	tracer = provider.Tracer(instrumentationName)
+	enabled.Store(true)
+}
+
+// Enabled reports whether spans are recorded, for callers that would
+// otherwise compute attributes for nothing.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Start starts a span named name as a child of the span in ctx, if any.
+// End the span when the traced work is done.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !enabled.Load() {
+		return ctx, noopSpan
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks span as failed with err. A nil err is ignored.
+func RecordError(span trace.Span, err error) {
+	if err == nil || !span.IsRecording() {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
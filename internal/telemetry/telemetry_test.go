@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStartDisabled(t *testing.T) {
+	ctx := context.Background()
+	got, span := Start(ctx, "message")
+	if got != ctx {
+		t.Error("disabled Start should return the context unchanged")
+	}
+	if span.IsRecording() {
+		t.Error("disabled Start should return a non-recording span")
+	}
+	RecordError(span, errors.New("boom")) // must not panic
+	span.End()
+
+	shutdown, err := Setup(ctx, Config{})
+	if err != nil || Enabled() {
+		t.Fatalf("Setup without endpoint: enabled=%v, err=%v", Enabled(), err)
+	}
+	if err := shutdown(ctx); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+
+	for _, bad := range []string{"localhost:4318", "grpc://collector:4317", "http://"} {
+		if _, err := Setup(ctx, Config{Endpoint: bad}); err == nil || Enabled() {
+			t.Errorf("Setup(%q) should fail", bad)
+		}
+	}
+}
+
+func TestStartEnabled(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	use(provider)
+	t.Cleanup(func() { enabled.Store(false) })
+
+	ctx, root := Start(context.Background(), "message", attribute.String("platform", "telegram"))
+	_, child := Start(ctx, "llm.stream")
+	RecordError(child, errors.New("provider down"))
+	child.End()
+	root.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	llmSpan, msgSpan := spans[0], spans[1]
+	if llmSpan.Parent.SpanID() != msgSpan.SpanContext.SpanID() {
+		t.Error("llm.stream should be a child of message")
+	}
+	if llmSpan.Status.Code != codes.Error || llmSpan.Status.Description != "provider down" {
+		t.Errorf("llm.stream status = %+v", llmSpan.Status)
+	}
+	if len(msgSpan.Attributes) != 1 || msgSpan.Attributes[0] != attribute.String("platform", "telegram") {
+		t.Errorf("message attributes = %v", msgSpan.Attributes)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	ctx := context.Background()
+	if id := RequestID(ctx); id != "" {
+		t.Errorf("RequestID without one = %q", id)
+	}
+	id := NewRequestID()
+	if len(id) != 16 || id == NewRequestID() {
+		t.Errorf("NewRequestID = %q, want 16 random hex digits", id)
+	}
+	if got := RequestID(WithRequestID(ctx, id)); got != id {
+		t.Errorf("RequestID = %q, want %q", got, id)
+	}
+}
@@ -47,6 +47,18 @@ func (s *Search) Description() string {
 	return "Search the web. Params: q (query), count (1-10, default 5)"
 }
 
+// Parameters returns the JSON Schema of the search params
+func (s *Search) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"q":     map[string]any{"type": "string", "description": "Search query"},
+			"count": map[string]any{"type": "integer", "description": "Number of results, 1-10 (default 5)"},
+		},
+		"required": []string{"q"},
+	}
+}
+
 // Execute performs a web search
 func (s *Search) Execute(ctx context.Context, params map[string]string) (string, error) {
 	query := params["q"]
@@ -3,9 +3,19 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/kusa/magabot/internal/util"
+	"github.com/kusandriadi/allm-go"
 )
 
+// maxResultChars caps a tool result sent back to the LLM.
+const maxResultChars = 8000
+
 // Tool interface for all tools
 type Tool interface {
 	Name() string
@@ -13,6 +23,13 @@ type Tool interface {
 	Execute(ctx context.Context, params map[string]string) (string, error)
 }
 
+// Callable is a Tool the LLM can call through function calling.
+type Callable interface {
+	Tool
+	// Parameters returns the JSON Schema of the tool's params
+	Parameters() map[string]any
+}
+
 // Manager manages all available tools
 type Manager struct {
 	tools  map[string]Tool
@@ -65,3 +82,61 @@ func (m *Manager) GetToolDescriptions() string {
 	}
 	return desc
 }
+
+// Definitions returns the registered tools the LLM can call, sorted by
+// name, as function definitions.
+func (m *Manager) Definitions() []allm.Tool {
+	var defs []allm.Tool
+	for _, t := range m.tools {
+		if c, ok := t.(Callable); ok {
+			defs = append(defs, allm.Tool{Name: c.Name(), Description: c.Description(), Parameters: c.Parameters()})
+		}
+	}
+	slices.SortFunc(defs, func(a, b allm.Tool) int { return strings.Compare(a.Name, b.Name) })
+	return defs
+}
+
+// Call runs a tool call from the LLM. Its JSON arguments become the tool's
+// params; failures are reported to the model as error results.
+func (m *Manager) Call(ctx context.Context, call allm.ToolCall) allm.ToolResult {
+	result := allm.ToolResult{ToolCallID: call.ID}
+	t, ok := m.tools[call.Name]
+	if _, callable := t.(Callable); !ok || !callable {
+		result.Content, result.IsError = fmt.Sprintf("unknown tool: %s", call.Name), true
+		return result
+	}
+	params, err := callParams(call.Arguments)
+	if err != nil {
+		result.Content, result.IsError = err.Error(), true
+		return result
+	}
+	out, err := t.Execute(ctx, params)
+	if err != nil {
+		m.logger.Warn("tool call failed", "tool", call.Name, "error", err)
+		result.Content, result.IsError = util.SanitizeErrorMessage(err.Error()), true
+		return result
+	}
+	result.Content = util.Truncate(out, maxResultChars)
+	return result
+}
+
+// callParams turns a tool call's JSON object arguments into string params.
+func callParams(arguments json.RawMessage) (map[string]string, error) {
+	var args map[string]any
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return nil, fmt.Errorf("invalid tool arguments: %w", err)
+		}
+	}
+	params := make(map[string]string, len(args))
+	for k, v := range args {
+		switch v := v.(type) {
+		case string:
+			params[k] = v
+		case nil:
+		default:
+			params[k] = fmt.Sprint(v)
+		}
+	}
+	return params, nil
+}
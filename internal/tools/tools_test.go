@@ -2,6 +2,7 @@ package tools_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/kusa/magabot/internal/tools"
+	"github.com/kusandriadi/allm-go"
 )
 
 // mockTool is a simple implementation of tools.Tool for testing.
@@ -241,3 +243,60 @@ func TestGetToolDescriptions_Format(t *testing.T) {
 		t.Errorf("expected '- weather: Get weather forecasts' in descriptions, got %q", desc)
 	}
 }
+
+// callableTool is a mockTool the LLM can call, recording its params.
+type callableTool struct {
+	mockTool
+	params map[string]string
+}
+
+func (c *callableTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{"q": map[string]any{"type": "string"}}}
+}
+
+func (c *callableTool) Execute(ctx context.Context, params map[string]string) (string, error) {
+	c.params = params
+	return c.mockTool.Execute(ctx, params)
+}
+
+func TestDefinitions(t *testing.T) {
+	mgr := newTestManager()
+	mgr.Register(&callableTool{mockTool: mockTool{name: "weather", description: "Get weather"}})
+	mgr.Register(&callableTool{mockTool: mockTool{name: "search", description: "Search the web"}})
+	mgr.Register(&mockTool{name: "calculator"}) // not callable
+
+	defs := mgr.Definitions()
+	if len(defs) != 2 || defs[0].Name != "search" || defs[1].Name != "weather" {
+		t.Fatalf("Definitions() = %+v, want search and weather", defs)
+	}
+	if defs[0].Description != "Search the web" || defs[0].Parameters["type"] != "object" {
+		t.Errorf("search definition = %+v", defs[0])
+	}
+}
+
+func TestCall(t *testing.T) {
+	mgr := newTestManager()
+	search := &callableTool{mockTool: mockTool{name: "search", result: "3 results"}}
+	mgr.Register(search)
+	mgr.Register(&callableTool{mockTool: mockTool{name: "broken", err: fmt.Errorf("upstream down")}})
+	mgr.Register(&mockTool{name: "calculator", result: "4"})
+
+	res := mgr.Call(context.Background(), allm.ToolCall{ID: "c1", Name: "search", Arguments: json.RawMessage(`{"q":"go","count":3}`)})
+	if res.IsError || res.Content != "3 results" || res.ToolCallID != "c1" {
+		t.Errorf("search result = %+v", res)
+	}
+	if search.params["q"] != "go" || search.params["count"] != "3" {
+		t.Errorf("search params = %v", search.params)
+	}
+
+	for _, call := range []allm.ToolCall{
+		{Name: "broken", Arguments: json.RawMessage(`{}`)},
+		{Name: "calculator", Arguments: json.RawMessage(`{}`)},
+		{Name: "missing"},
+		{Name: "search", Arguments: json.RawMessage(`not json`)},
+	} {
+		if res := mgr.Call(context.Background(), call); !res.IsError {
+			t.Errorf("Call(%s, %s) = %+v, want an error result", call.Name, call.Arguments, res)
+		}
+	}
+}
@@ -33,6 +33,22 @@ func (w *Weather) Description() string {
 	return "Get weather information. Params: location (city name or coordinates)"
 }
 
+// Parameters returns the JSON Schema of the weather params
+func (w *Weather) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"location": map[string]any{"type": "string", "description": "City name or coordinates"},
+			"format": map[string]any{
+				"type":        "string",
+				"enum":        []string{"simple", "detailed", "emoji"},
+				"description": "Report detail (default detailed)",
+			},
+		},
+		"required": []string{"location"},
+	}
+}
+
 // Execute gets weather for a location
 func (w *Weather) Execute(ctx context.Context, params map[string]string) (string, error) {
 	location := params["location"]
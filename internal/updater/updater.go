@@ -8,26 +8,50 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kusa/magabot/internal/util"
 )
 
+// Release channels
+const (
+	ChannelStable = "stable" // latest non-prerelease
+	ChannelBeta   = "beta"   // newest release including pre-releases
+)
+
+var (
+	// ErrNoChecksum is returned when a release publishes no checksum for the
+	// downloaded asset and unverified updates are not allowed.
+	ErrNoChecksum = errors.New("release has no checksum for this binary")
+	// ErrChecksumMismatch is returned when the download does not match its
+	// published SHA-256.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
 // Config holds updater configuration
 type Config struct {
-	RepoOwner      string // GitHub owner (e.g., "kusandriadi")
-	RepoName       string // GitHub repo (e.g., "magabot")
-	CurrentVersion string
-	BinaryName     string
-	CheckInterval  time.Duration
-	AutoUpdate     bool
+	RepoOwner       string // GitHub owner (e.g., "kusandriadi")
+	RepoName        string // GitHub repo (e.g., "magabot")
+	CurrentVersion  string
+	BinaryName      string
+	CheckInterval   time.Duration
+	AutoUpdate      bool
+	Channel         string // stable (default) or beta
+	AllowUnverified bool   // install releases that publish no checksum
+}
+
+// ValidChannel reports whether ch is a known release channel.
+func ValidChannel(ch string) bool {
+	return ch == ChannelStable || ch == ChannelBeta
 }
 
 // Release represents a GitHub release
@@ -53,8 +77,9 @@ type Asset struct {
 
 // Updater handles checking and applying updates
 type Updater struct {
-	config Config
-	client *http.Client
+	config  Config
+	client  *http.Client
+	apiBase string // GitHub API base URL (overridable for tests)
 }
 
 // New creates a new updater
@@ -65,10 +90,14 @@ func New(config Config) *Updater {
 	if config.CheckInterval == 0 {
 		config.CheckInterval = 24 * time.Hour
 	}
+	if config.Channel == "" {
+		config.Channel = ChannelStable
+	}
 
 	return &Updater{
-		config: config,
-		client: util.NewHTTPClient(5 * time.Minute),
+		config:  config,
+		client:  util.NewHTTPClient(5 * time.Minute),
+		apiBase: "https://api.github.com",
 	}
 }
 
@@ -81,14 +110,21 @@ func (u *Updater) CheckUpdate(ctx context.Context) (*Release, bool, error) {
 
 	// Compare versions
 	hasUpdate := isNewerVersion(u.config.CurrentVersion, release.TagName)
+	if !hasUpdate && u.config.Channel == ChannelBeta {
+		hasUpdate = isNewerPrerelease(u.config.CurrentVersion, release.TagName)
+	}
 
 	return release, hasUpdate, nil
 }
 
-// getLatestRelease fetches the latest release from GitHub
+// getLatestRelease fetches the newest release for the configured channel
 func (u *Updater) getLatestRelease(ctx context.Context) (*Release, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest",
-		u.config.RepoOwner, u.config.RepoName)
+	if u.config.Channel == ChannelBeta {
+		return u.getNewestRelease(ctx)
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/latest",
+		u.apiBase, u.config.RepoOwner, u.config.RepoName)
 
 	resp, err := util.DoGET(ctx, u.client, url, map[string]string{
 		"Accept":     "application/vnd.github.v3+json",
@@ -115,6 +151,38 @@ func (u *Updater) getLatestRelease(ctx context.Context) (*Release, error) {
 	return &release, nil
 }
 
+// getNewestRelease fetches the most recent non-draft release, including
+// pre-releases (beta channel). GitHub lists releases newest first.
+func (u *Updater) getNewestRelease(ctx context.Context) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=20",
+		u.apiBase, u.config.RepoOwner, u.config.RepoName)
+
+	resp, err := util.DoGET(ctx, u.client, url, map[string]string{
+		"Accept":     "application/vnd.github.v3+json",
+		"User-Agent": "magabot-updater",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check updates: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("GitHub API error: %d", resp.StatusCode)
+	}
+
+	var releases []Release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10*1024*1024)).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to parse releases: %w", err)
+	}
+
+	for i := range releases {
+		if !releases[i].Draft {
+			return &releases[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no releases found")
+}
+
 // Update downloads and applies the update
 func (u *Updater) Update(ctx context.Context, release *Release) error {
 	// Find the right asset for current platform
@@ -123,8 +191,15 @@ func (u *Updater) Update(ctx context.Context, release *Release) error {
 		return fmt.Errorf("no binary found for %s/%s", runtime.GOOS, runtime.GOARCH)
 	}
 
-	// Look for a checksums file in the release assets
-	checksumAsset := u.findChecksumAsset(release)
+	// Look for a checksum for this asset; refuse to install without one
+	// unless explicitly allowed
+	checksumAsset := u.findAssetChecksum(release, asset.Name)
+	if checksumAsset == nil {
+		checksumAsset = u.findChecksumAsset(release)
+	}
+	if checksumAsset == nil && !u.config.AllowUnverified {
+		return fmt.Errorf("%w (%s)", ErrNoChecksum, asset.Name)
+	}
 
 	// Get current executable path
 	execPath, err := os.Executable()
@@ -349,6 +424,17 @@ func (u *Updater) extractTarGz(archivePath, destDir string) (string, error) {
 	return binaryPath, nil
 }
 
+// findAssetChecksum looks for a per-asset checksum file (e.g.
+// magabot_linux_amd64.tar.gz.sha256) in release assets
+func (u *Updater) findAssetChecksum(release *Release, assetName string) *Asset {
+	for _, asset := range release.Assets {
+		if strings.EqualFold(asset.Name, assetName+".sha256") {
+			return &asset
+		}
+	}
+	return nil
+}
+
 // findChecksumAsset looks for a SHA256 checksums file in release assets
 func (u *Updater) findChecksumAsset(release *Release) *Asset {
 	names := []string{"checksums.txt", "SHA256SUMS", "sha256sums.txt"}
@@ -362,7 +448,9 @@ func (u *Updater) findChecksumAsset(release *Release) *Asset {
 	return nil
 }
 
-// verifyChecksum downloads checksums file and verifies the downloaded file
+// verifyChecksum downloads checksums file and verifies the downloaded file.
+// A checksums file that is missing or doesn't list assetName is only
+// accepted with AllowUnverified.
 func (u *Updater) verifyChecksum(ctx context.Context, checksumAsset *Asset, assetName, filePath string) error {
 	resp, err := util.DoGET(ctx, u.client, checksumAsset.BrowserDownloadURL, nil)
 	if err != nil {
@@ -370,23 +458,24 @@ func (u *Updater) verifyChecksum(ctx context.Context, checksumAsset *Asset, asse
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	body, err := util.ReadHTTPBody(resp, 0)
-	if err != nil {
-		return err
-	}
-
-	// Parse checksums file (format: "hash  filename" per line)
 	var expectedHash string
-	for _, line := range strings.Split(string(body), "\n") {
-		parts := strings.Fields(line)
-		if len(parts) >= 2 && parts[1] == assetName {
-			expectedHash = parts[0]
-			break
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := util.ReadHTTPBody(resp, 0)
+		if err != nil {
+			return err
 		}
+		expectedHash = parseChecksum(string(body), assetName)
+	case http.StatusNotFound:
+	default:
+		return fmt.Errorf("download checksums: HTTP %d", resp.StatusCode)
 	}
-
 	if expectedHash == "" {
-		return fmt.Errorf("no checksum found for %s in checksums file", assetName)
+		// Same as a release without a checksums file
+		if u.config.AllowUnverified {
+			return nil
+		}
+		return fmt.Errorf("%w: not listed in %s", ErrNoChecksum, checksumAsset.Name)
 	}
 
 	// Compute SHA-256 of downloaded file
@@ -402,13 +491,32 @@ func (u *Updater) verifyChecksum(ctx context.Context, checksumAsset *Asset, asse
 	}
 
 	actualHash := hex.EncodeToString(hasher.Sum(nil))
-	if actualHash != expectedHash {
-		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedHash, actualHash)
+	if !strings.EqualFold(actualHash, expectedHash) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expectedHash, actualHash)
 	}
 
 	return nil
 }
 
+// parseChecksum finds the SHA-256 for assetName in a checksums file.
+// Accepts "hash  filename" / "hash *filename" lines (sha256sum format) and
+// single-hash per-asset files.
+func parseChecksum(body, assetName string) string {
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	for _, line := range lines {
+		parts := strings.Fields(line)
+		if len(parts) >= 2 && strings.TrimPrefix(parts[1], "*") == assetName {
+			return parts[0]
+		}
+	}
+	if len(lines) == 1 {
+		if parts := strings.Fields(lines[0]); len(parts) == 1 && len(parts[0]) == sha256.Size*2 {
+			return parts[0]
+		}
+	}
+	return ""
+}
+
 // Rollback restores the previous version
 func (u *Updater) Rollback() error {
 	execPath, err := os.Executable()
@@ -465,6 +573,59 @@ func isNewerVersion(current, latest string) bool {
 	return false
 }
 
+// isNewerPrerelease reports whether latest is a newer build of the same
+// x.y.z as current, ordering pre-release suffixes (1.2.0-beta.1 <
+// 1.2.0-beta.2 < 1.2.0). Used by the beta channel, where isNewerVersion
+// ignores suffixes.
+func isNewerPrerelease(current, latest string) bool {
+	current = strings.TrimPrefix(current, "v")
+	latest = strings.TrimPrefix(latest, "v")
+	if parseVersion(current) != parseVersion(latest) {
+		return false
+	}
+	cPre, lPre := prereleaseSuffix(current), prereleaseSuffix(latest)
+	switch {
+	case cPre == lPre:
+		return false
+	case lPre == "":
+		return true // final release after its pre-releases
+	case cPre == "":
+		return false
+	}
+	return comparePrerelease(cPre, lPre) < 0
+}
+
+// prereleaseSuffix returns the part after "-" (build metadata stripped).
+func prereleaseSuffix(v string) string {
+	if idx := strings.Index(v, "+"); idx >= 0 {
+		v = v[:idx]
+	}
+	if idx := strings.Index(v, "-"); idx >= 0 {
+		return v[idx+1:]
+	}
+	return ""
+}
+
+// comparePrerelease compares dot-separated identifiers, numerically where both are numbers.
+func comparePrerelease(a, b string) int {
+	ap, bp := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		if ap[i] == bp[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(ap[i])
+		bn, bErr := strconv.Atoi(bp[i])
+		if aErr == nil && bErr == nil {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(ap[i], bp[i])
+	}
+	return len(ap) - len(bp)
+}
+
 // parseVersion splits "1.2.3" into [1, 2, 3]
 func parseVersion(v string) [3]int {
 	var parts [3]int
@@ -1,7 +1,15 @@
 package updater
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -292,3 +300,162 @@ func TestNew_CustomConfig(t *testing.T) {
 		t.Errorf("expected RepoOwner 'owner', got %q", u.config.RepoOwner)
 	}
 }
+
+func TestIsNewerPrerelease(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		want            bool
+	}{
+		{"1.2.0-beta.1", "1.2.0-beta.2", true},
+		{"1.2.0-beta.2", "1.2.0-beta.10", true},
+		{"1.2.0-beta.2", "1.2.0-beta.1", false},
+		{"1.2.0-beta.2", "1.2.0", true},
+		{"1.2.0", "1.2.0-beta.3", false},
+		{"v1.2.0-alpha", "v1.2.0-beta", true},
+		{"1.2.0-beta", "1.2.0-beta", false},
+		{"1.1.0-beta", "1.2.0-beta", false}, // handled by isNewerVersion
+	}
+	for _, tt := range tests {
+		if got := isNewerPrerelease(tt.current, tt.latest); got != tt.want {
+			t.Errorf("isNewerPrerelease(%q, %q) = %v, want %v", tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	tests := []struct {
+		name, body, want string
+	}{
+		{"sha256sum format", hash + "  magabot_linux_amd64.tar.gz\nffff  other.tar.gz\n", hash},
+		{"binary mode", hash + " *magabot_linux_amd64.tar.gz\n", hash},
+		{"per-asset file", hash + "\n", hash},
+		{"not listed", hash + "  other.tar.gz\n", ""},
+		{"garbage", "not-a-hash\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseChecksum(tt.body, "magabot_linux_amd64.tar.gz"); got != tt.want {
+				t.Errorf("parseChecksum = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "download")
+	if err := os.WriteFile(file, []byte("binary"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("binary"))
+	good := hex.EncodeToString(sum[:])
+
+	body := good
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s  magabot.tar.gz\n", body)
+	}))
+	defer srv.Close()
+
+	u := New(Config{})
+	asset := &Asset{Name: "checksums.txt", BrowserDownloadURL: srv.URL}
+	if err := u.verifyChecksum(context.Background(), asset, "magabot.tar.gz", file); err != nil {
+		t.Errorf("matching checksum: %v", err)
+	}
+
+	body = strings.Repeat("0", 64)
+	err := u.verifyChecksum(context.Background(), asset, "magabot.tar.gz", file)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestVerifyChecksum_NotListed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "download")
+	if err := os.WriteFile(file, []byte("binary"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, "%s  other.tar.gz\n%s  magabot.zip\n", strings.Repeat("0", 64), strings.Repeat("1", 64))
+	}))
+	defer srv.Close()
+	asset := &Asset{Name: "checksums.txt", BrowserDownloadURL: srv.URL}
+
+	for _, s := range []int{http.StatusOK, http.StatusNotFound} {
+		status = s
+		err := New(Config{}).verifyChecksum(context.Background(), asset, "magabot.tar.gz", file)
+		if !errors.Is(err, ErrNoChecksum) {
+			t.Errorf("HTTP %d: expected ErrNoChecksum, got %v", s, err)
+		}
+		err = New(Config{AllowUnverified: true}).verifyChecksum(context.Background(), asset, "magabot.tar.gz", file)
+		if err != nil {
+			t.Errorf("HTTP %d with AllowUnverified: %v", s, err)
+		}
+	}
+
+	status = http.StatusInternalServerError
+	err := New(Config{AllowUnverified: true}).verifyChecksum(context.Background(), asset, "magabot.tar.gz", file)
+	if err == nil || errors.Is(err, ErrNoChecksum) {
+		t.Errorf("HTTP 500: expected a download error, got %v", err)
+	}
+}
+
+func TestUpdate_RefusesWithoutChecksum(t *testing.T) {
+	u := New(Config{BinaryName: "magabot"})
+	release := &Release{Assets: []Asset{
+		{Name: fmt.Sprintf("magabot_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)},
+	}}
+	if err := u.Update(context.Background(), release); !errors.Is(err, ErrNoChecksum) {
+		t.Errorf("expected ErrNoChecksum, got %v", err)
+	}
+}
+
+func TestFindAssetChecksum(t *testing.T) {
+	u := New(Config{})
+	release := &Release{Assets: []Asset{{Name: "magabot_linux_amd64.tar.gz"}, {Name: "magabot_linux_amd64.tar.gz.sha256"}}}
+	if got := u.findAssetChecksum(release, "magabot_linux_amd64.tar.gz"); got == nil || got.Name != "magabot_linux_amd64.tar.gz.sha256" {
+		t.Errorf("findAssetChecksum = %+v", got)
+	}
+	if got := u.findAssetChecksum(release, "magabot_darwin_arm64.tar.gz"); got != nil {
+		t.Errorf("expected nil, got %+v", got)
+	}
+}
+
+func TestCheckUpdate_Channels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/releases/latest":
+			_, _ = w.Write([]byte(`{"tag_name":"v1.1.0"}`))
+		case "/repos/o/r/releases":
+			_, _ = w.Write([]byte(`[{"tag_name":"v1.3.0-beta.1","draft":true},{"tag_name":"v1.2.0-beta.2","prerelease":true},{"tag_name":"v1.1.0"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		channel, current, wantTag string
+		wantUpdate                bool
+	}{
+		{ChannelStable, "v1.1.0", "v1.1.0", false},
+		{ChannelBeta, "v1.1.0", "v1.2.0-beta.2", true},
+		{ChannelBeta, "v1.2.0-beta.1", "v1.2.0-beta.2", true},
+		{ChannelBeta, "v1.2.0-beta.2", "v1.2.0-beta.2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel+"_"+tt.current, func(t *testing.T) {
+			u := New(Config{RepoOwner: "o", RepoName: "r", CurrentVersion: tt.current, Channel: tt.channel})
+			u.apiBase = srv.URL
+			release, hasUpdate, err := u.CheckUpdate(context.Background())
+			if err != nil {
+				t.Fatalf("CheckUpdate: %v", err)
+			}
+			if release.TagName != tt.wantTag || hasUpdate != tt.wantUpdate {
+				t.Errorf("got (%s, %v), want (%s, %v)", release.TagName, hasUpdate, tt.wantTag, tt.wantUpdate)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Dedicated HTTP clients for SDKs that only use http.DefaultClient
+package util
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// endpoint is a registered base URL and the client for requests under it.
+type endpoint struct {
+	scheme string
+	host   string // lowercase host:port
+	path   string // without a trailing "/"
+	client *http.Client
+}
+
+var (
+	endpointsMu  sync.RWMutex
+	endpoints    []endpoint
+	endpointOnce sync.Once
+)
+
+// RegisterEndpoint sends the requests http.DefaultClient makes under
+// baseURL through client. It is the hook for SDKs that always use
+// http.DefaultClient and take no client of their own, such as the LLM
+// providers. Endpoints are matched by scheme, host, port and path prefix,
+// the longest path winning, so providers sharing a host keep their own
+// settings. Requests under no endpoint go out on http.DefaultTransport as
+// before, and clients of their own are never affected.
+func RegisterEndpoint(baseURL string, client *http.Client) error {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid endpoint URL %q", baseURL)
+	}
+	ep := endpoint{
+		scheme: u.Scheme,
+		host:   endpointHost(u),
+		path:   strings.TrimRight(u.Path, "/"),
+		client: client,
+	}
+	endpointOnce.Do(func() {
+		http.DefaultClient.Transport = endpointTransport{}
+	})
+
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	for i, e := range endpoints {
+		if e.scheme == ep.scheme && e.host == ep.host && e.path == ep.path {
+			endpoints[i] = ep
+			return nil
+		}
+	}
+	endpoints = append(endpoints, ep)
+	return nil
+}
+
+// endpointHost returns u's lowercase host with the scheme's default port
+// made explicit.
+func endpointHost(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// endpointClient returns the client of the most specific endpoint u falls
+// under, or nil.
+func endpointClient(u *url.URL) *http.Client {
+	host := endpointHost(u)
+	endpointsMu.RLock()
+	defer endpointsMu.RUnlock()
+	var best *endpoint
+	for i := range endpoints {
+		ep := &endpoints[i]
+		if ep.scheme != u.Scheme || ep.host != host {
+			continue
+		}
+		if ep.path != "" && u.Path != ep.path && !strings.HasPrefix(u.Path, ep.path+"/") {
+			continue
+		}
+		if best == nil || len(ep.path) > len(best.path) {
+			best = ep
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.client
+}
+
+// endpointTransport is http.DefaultClient's transport once an endpoint is
+// registered: it hands each request to its endpoint's client transport.
+type endpointTransport struct{}
+
+func (endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if c := endpointClient(req.URL); c != nil && c.Transport != nil {
+		return c.Transport.RoundTrip(req)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,90 @@
+// Per-endpoint request changes for SDK clients
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// HostRequest describes changes made to every request sent to one
+// endpoint by its NewEndpointClient.
+type HostRequest struct {
+	Header http.Header // set on each request, replacing SDK values
+	Query  url.Values  // added to the query string, e.g. Azure's api-version
+
+	// APIKeyHeader moves the "Authorization: Bearer <key>" credential into
+	// this header, for APIs such as Azure OpenAI that expect "api-key".
+	APIKeyHeader string
+}
+
+// reservedHeaders are managed by net/http or the SDK and cannot be overridden.
+var reservedHeaders = map[string]bool{
+	"Authorization":     true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Host":              true,
+	"Transfer-Encoding": true,
+}
+
+// ParseHeaders validates configured header names and values and returns
+// them in canonical form.
+func ParseHeaders(headers map[string]string) (http.Header, error) {
+	h := make(http.Header, len(headers))
+	for name, value := range headers {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("invalid value for header %s", name)
+		}
+		canonical := http.CanonicalHeaderKey(name)
+		if reservedHeaders[canonical] {
+			return nil, fmt.Errorf("header %s cannot be overridden", canonical)
+		}
+		h.Set(canonical, value)
+	}
+	return h, nil
+}
+
+// empty reports whether r changes nothing.
+func (r HostRequest) empty() bool {
+	return len(r.Header) == 0 && len(r.Query) == 0 && r.APIKeyHeader == ""
+}
+
+// hostRequestTransport applies hr to each request.
+type hostRequestTransport struct {
+	base http.RoundTripper
+	hr   HostRequest
+}
+
+func (t hostRequestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	hr := t.hr
+	if hr.empty() {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for name, values := range hr.Header {
+		req.Header[name] = values
+	}
+	if len(hr.Query) > 0 {
+		q := req.URL.Query()
+		for name, values := range hr.Query {
+			q[name] = values
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	if hr.APIKeyHeader != "" {
+		if key, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+			req.Header.Del("Authorization")
+			req.Header.Set(hr.APIKeyHeader, key)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,62 @@
+// Outbound HTTP proxy configuration
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// proxySchemes are the proxy URL schemes net/http supports.
+var proxySchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"socks5": true,
+}
+
+// ParseProxyURL validates a proxy URL such as "http://proxy.corp:3128".
+func ParseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if !proxySchemes[u.Scheme] {
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", u.Redacted())
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// NewProxyHTTPClient creates an HTTP client that sends requests through
+// proxy, or through HTTPS_PROXY/HTTP_PROXY (minus NO_PROXY hosts) when proxy
+// is empty. A zero timeout uses DefaultHTTPTimeout.
+func NewProxyHTTPClient(timeout time.Duration, proxy string) (*http.Client, error) {
+	client := NewHTTPClient(timeout)
+	if proxy == "" {
+		return client, nil
+	}
+	u, err := ParseProxyURL(proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+	client.Transport = transport
+	return client, nil
+}
+
+// NewEndpointClient creates the client for one API endpoint. Requests go
+// through proxy, or through HTTPS_PROXY/HTTP_PROXY when proxy is nil, with
+// hr applied. It has a connection pool of its own and no overall timeout,
+// since streamed responses run long; bound requests with their context.
+func NewEndpointClient(proxy *url.URL, hr HostRequest) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Transport: hostRequestTransport{base: transport, hr: hr}}
+}
@@ -21,8 +21,50 @@ var blockedHosts = map[string]bool{
 	"100.100.100.200":          true, // Alibaba Cloud metadata
 }
 
-// ValidateBaseURL validates a base URL for LLM providers to prevent SSRF.
-// If allowLocal is true, localhost/127.0.0.1/private IPs are permitted (for local LLM servers).
+// privateNetworks are the reserved ranges a cloud provider URL must not reach.
+var privateNetworks = mustParseCIDRs(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",  // Carrier-grade NAT
+	"169.254.0.0/16", // Link-local
+	"fc00::/7",       // IPv6 private
+	"fe80::/10",      // IPv6 link-local
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// lookupIP resolves hostnames during validation (replaced in tests).
+var lookupIP = net.LookupIP
+
+// IsPrivateIP reports whether ip is loopback, unspecified or in a
+// private, link-local or carrier-grade NAT range.
+func IsPrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalMulticast() {
+		return true
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateBaseURL validates a base URL for cloud providers to prevent SSRF:
+// localhost, private IPs, cloud metadata hosts and hostnames resolving to
+// private IPs are rejected. Hostnames that fail to resolve are allowed, since
+// the request itself will fail.
 func ValidateBaseURL(rawURL string) error {
 	return validateBaseURL(rawURL, false)
 }
@@ -66,16 +108,25 @@ func validateBaseURL(rawURL string, allowLocal bool) error {
 	}
 
 	// Block localhost variants
-	if host == "localhost" || host == "127.0.0.1" || host == "[::1]" || host == "0.0.0.0" {
-		return fmt.Errorf("blocked host: %s (use ValidateLocalBaseURL for local providers)", host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("blocked host: %s (localhost is only allowed for the local provider)", host)
 	}
 
-	// Block private IP ranges (RFC 1918)
+	// Block private IP ranges, given directly or via DNS
 	if ip := net.ParseIP(host); ip != nil {
-		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		if IsPrivateIP(ip) {
 			return fmt.Errorf("blocked private IP: %s (SSRF protection)", host)
 		}
+		return nil
+	}
+	ips, err := lookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if IsPrivateIP(ip) || blockedHosts[ip.String()] {
+			return fmt.Errorf("blocked host: %s resolves to private IP %s (SSRF protection)", host, ip)
+		}
 	}
-
 	return nil
 }
@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 	"regexp"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 // DefaultHTTPTimeout is the default timeout for HTTP clients
@@ -126,6 +128,139 @@ func Contains(slice []string, item string) bool {
 	return false
 }
 
+// MatchPattern reports whether s matches an allowlist pattern. Patterns use
+// path.Match glob syntax ("*@example.com", "github:org/*", "telegram:*"),
+// except that "/" is an ordinary character, since user IDs may contain it:
+// "github:org/*" matches "github:org/team/alice". Malformed patterns match
+// nothing.
+func MatchPattern(pattern, s string) bool {
+	if pattern == s || pattern == "*" {
+		return true
+	}
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		return false
+	}
+	ok, err := matchGlob(pattern, s)
+	return err == nil && ok
+}
+
+// matchGlob is path.Match without the special meaning of "/".
+func matchGlob(pattern, s string) (bool, error) {
+	p, i := 0, 0
+	starP, starI := -1, 0 // where the last "*" is, and where its match ends
+	for i < len(s) {
+		if p < len(pattern) {
+			r, n := utf8.DecodeRuneInString(s[i:])
+			switch pattern[p] {
+			case '*':
+				starP, starI = p, i
+				p++
+				continue
+			case '?':
+				p, i = p+1, i+n
+				continue
+			case '[':
+				ok, width, err := matchClass(pattern[p:], r)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					p, i = p+width, i+n
+					continue
+				}
+			case '\\':
+				if p+1 == len(pattern) {
+					return false, path.ErrBadPattern
+				}
+				if pattern[p+1] == s[i] {
+					p, i = p+2, i+1
+					continue
+				}
+			default:
+				if pattern[p] == s[i] {
+					p, i = p+1, i+1
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false, nil
+		}
+		// Let the last "*" take one more rune and try again
+		_, n := utf8.DecodeRuneInString(s[starI:])
+		starI += n
+		p, i = starP+1, starI
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern), nil
+}
+
+// matchClass matches r against the character class at the start of
+// pattern, e.g. "[a-z]" or "[^0-9]", and returns the class's length.
+func matchClass(pattern string, r rune) (ok bool, width int, err error) {
+	j := 1
+	negate := j < len(pattern) && pattern[j] == '^'
+	if negate {
+		j++
+	}
+	for ranges := 0; ; ranges++ {
+		if j >= len(pattern) {
+			return false, 0, path.ErrBadPattern
+		}
+		if pattern[j] == ']' {
+			if ranges == 0 {
+				return false, 0, path.ErrBadPattern
+			}
+			return ok != negate, j + 1, nil
+		}
+		lo, n, err := classChar(pattern[j:])
+		if err != nil {
+			return false, 0, err
+		}
+		j += n
+		hi := lo
+		if j < len(pattern) && pattern[j] == '-' {
+			if hi, n, err = classChar(pattern[j+1:]); err != nil {
+				return false, 0, err
+			}
+			j += 1 + n
+		}
+		if lo <= r && r <= hi {
+			ok = true
+		}
+	}
+}
+
+// classChar decodes one, possibly escaped, character of a class.
+func classChar(s string) (rune, int, error) {
+	width := 0
+	if s != "" && s[0] == '\\' {
+		s, width = s[1:], 1
+	} else if s != "" && (s[0] == '-' || s[0] == ']') {
+		return 0, 0, path.ErrBadPattern
+	}
+	if s == "" {
+		return 0, 0, path.ErrBadPattern
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	return r, width + n, nil
+}
+
+// ContainsMatch checks if any pattern in slice matches item (see MatchPattern)
+func ContainsMatch(slice []string, item string) bool {
+	if Contains(slice, item) {
+		return true
+	}
+	for _, pattern := range slice {
+		if MatchPattern(pattern, item) {
+			return true
+		}
+	}
+	return false
+}
+
 // Remove removes item from slice
 func Remove(slice []string, item string) []string {
 	result := make([]string, 0, len(slice))
@@ -1,6 +1,12 @@
 package util
 
 import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 )
@@ -143,6 +149,43 @@ func TestContains(t *testing.T) {
 	}
 }
 
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"alice", "alice", true},
+		{"alice", "bob", false},
+		{"*", "github:org/team/alice", true},
+		{"telegram:*", "telegram:12345", true},
+		{"telegram:*", "slack:12345", false},
+		{"*@example.com", "bob@example.com", true},
+		{"*@example.com", "bob@example.org", false},
+		{"github:org/*", "github:org/alice", true},
+		{"github:org/*", "github:org/team/alice", true},
+		{"github:*/alice", "github:org/team/alice", true},
+		{"github:org/*/alice", "github:org/bob", false},
+		{"github:org/?", "github:org/a", true},
+		{"github:org?a", "github:org/a", true},
+		{"user[0-9]", "user1", true},
+		{"user[^0-9]", "user1", false},
+		{"user[!]", "user!", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"*/*/*", "a/b", false},
+		{"user[1", "user1", false},
+		{"user[]", "user1", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+	if !ContainsMatch([]string{"a", "b*"}, "bee") {
+		t.Error("ContainsMatch should match b*")
+	}
+}
+
 func TestRemove(t *testing.T) {
 	slice := []string{"a", "b", "c"}
 	result := Remove(slice, "b")
@@ -206,3 +249,207 @@ func TestIsValidID(t *testing.T) {
 		}
 	}
 }
+
+func TestParseProxyURL(t *testing.T) {
+	for _, ok := range []string{"http://proxy.corp:3128", "https://user:pw@proxy.corp", "socks5://127.0.0.1:1080"} {
+		if _, err := ParseProxyURL(ok); err != nil {
+			t.Errorf("ParseProxyURL(%q) = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"proxy.corp:3128", "ftp://proxy.corp", "http://", "://x"} {
+		if _, err := ParseProxyURL(bad); err == nil {
+			t.Errorf("ParseProxyURL(%q) should fail", bad)
+		}
+	}
+}
+
+// newTestProxy returns a server that answers every proxied request itself,
+// recording the absolute URLs it was asked for.
+func newTestProxy(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.URL.String())
+		_, _ = io.WriteString(w, "via proxy")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &seen
+}
+
+func TestNewProxyHTTPClient(t *testing.T) {
+	proxy, seen := newTestProxy(t)
+
+	client, err := NewProxyHTTPClient(time.Second, proxy.URL)
+	if err != nil {
+		t.Fatalf("NewProxyHTTPClient: %v", err)
+	}
+	resp, err := client.Get("http://api.example.test/v1/embeddings")
+	if err != nil {
+		t.Fatalf("GET via proxy: %v", err)
+	}
+	_ = resp.Body.Close()
+	if len(*seen) != 1 || (*seen)[0] != "http://api.example.test/v1/embeddings" {
+		t.Errorf("proxy saw %v", *seen)
+	}
+
+	if _, err := NewProxyHTTPClient(0, "not a proxy"); err == nil {
+		t.Error("invalid proxy should be rejected")
+	}
+	if c, err := NewProxyHTTPClient(0, ""); err != nil || c.Transport != nil {
+		t.Errorf("empty proxy should use the default transport: %v", err)
+	}
+}
+
+func TestRegisterEndpoint(t *testing.T) {
+	chatProxy, chatSeen := newTestProxy(t)
+	embedProxy, embedSeen := newTestProxy(t)
+	chatURL, _ := ParseProxyURL(chatProxy.URL)
+	embedURL, _ := ParseProxyURL(embedProxy.URL)
+	if err := RegisterEndpoint("http://LLM.example.test/v1", NewEndpointClient(chatURL, HostRequest{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterEndpoint("http://llm.example.test:80/v1/embed/", NewEndpointClient(embedURL, HostRequest{})); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{"http://llm.example.test/v1/messages", "http://llm.example.test/v1/embed/x"} {
+		resp, err := http.DefaultClient.Get(u)
+		if err != nil {
+			t.Fatalf("GET %s: %v", u, err)
+		}
+		_ = resp.Body.Close()
+	}
+	if len(*chatSeen) != 1 || len(*embedSeen) != 1 {
+		t.Errorf("proxies saw %v and %v, want one request each", *chatSeen, *embedSeen)
+	}
+
+	// Requests under no endpoint, and other clients, are not routed
+	for _, raw := range []string{"http://llm.example.test/v10/x", "http://llm.example.test:8080/v1/x", "https://llm.example.test/v1/x"} {
+		u, _ := url.Parse(raw)
+		if c := endpointClient(u); c != nil {
+			t.Errorf("%s is routed to an endpoint client", raw)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://llm.example.test/v1/messages", nil)
+	if p, _ := http.DefaultTransport.(*http.Transport).Proxy(req); p != nil && p.Host == chatURL.Host {
+		t.Errorf("http.DefaultTransport routes to the endpoint proxy")
+	}
+
+	if err := RegisterEndpoint("llm.example.test", nil); err == nil {
+		t.Error("an endpoint without a scheme should be rejected")
+	}
+}
+
+func TestValidateBaseURL(t *testing.T) {
+	orig := lookupIP
+	defer func() { lookupIP = orig }()
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "internal.example.com":
+			return []net.IP{net.ParseIP("10.1.2.3")}, nil
+		case "metadata.example.com":
+			return []net.IP{net.ParseIP("100.100.100.200")}, nil
+		case "api.example.com":
+			return []net.IP{net.ParseIP("93.184.216.34")}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+
+	tests := []struct {
+		url     string
+		cloudOK bool
+		localOK bool
+	}{
+		{"", true, true},
+		{"https://api.example.com/v1", true, true},
+		{"https://unresolvable.example.com/v1", true, true},
+		{"http://localhost:11434/v1", false, true},
+		{"http://api.localhost/v1", false, true},
+		{"http://127.0.0.1:8080", false, true},
+		{"http://[::1]:8080", false, true},
+		{"http://0.0.0.0:8080", false, true},
+		{"http://10.0.0.5/v1", false, true},
+		{"http://172.16.3.4/v1", false, true},
+		{"http://192.168.1.10/v1", false, true},
+		{"http://100.64.0.1/v1", false, true},
+		{"http://[fd00::1]/v1", false, true},
+		{"https://internal.example.com/v1", false, true},
+		{"https://metadata.example.com/v1", false, true},
+		{"http://169.254.169.254/latest", false, false},
+		{"http://metadata.google.internal", false, false},
+		{"ftp://api.example.com", false, false},
+		{"file:///etc/passwd", false, false},
+		{"http://", false, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateBaseURL(tt.url); (err == nil) != tt.cloudOK {
+			t.Errorf("ValidateBaseURL(%q) error = %v, want ok=%v", tt.url, err, tt.cloudOK)
+		}
+		if err := ValidateLocalBaseURL(tt.url); (err == nil) != tt.localOK {
+			t.Errorf("ValidateLocalBaseURL(%q) error = %v, want ok=%v", tt.url, err, tt.localOK)
+		}
+	}
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	private := []string{"127.0.0.1", "::1", "0.0.0.0", "10.0.0.1", "172.31.255.255", "192.168.0.1", "169.254.1.1", "100.64.1.1", "fc00::1", "fe80::1"}
+	for _, s := range private {
+		if !IsPrivateIP(net.ParseIP(s)) {
+			t.Errorf("IsPrivateIP(%s) = false, want true", s)
+		}
+	}
+	public := []string{"8.8.8.8", "172.32.0.1", "93.184.216.34", "2606:4700::1111"}
+	for _, s := range public {
+		if IsPrivateIP(net.ParseIP(s)) {
+			t.Errorf("IsPrivateIP(%s) = true, want false", s)
+		}
+	}
+}
+
+func TestEndpointClientHostRequest(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	t.Cleanup(srv.Close)
+	proxyURL, _ := ParseProxyURL(srv.URL)
+
+	header, err := ParseHeaders(map[string]string{"openai-organization": "org-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewEndpointClient(proxyURL, HostRequest{
+		Header:       header,
+		Query:        url.Values{"api-version": {"2024-10-21"}},
+		APIKeyHeader: "api-key",
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, "http://headers.example.test/v1/chat/completions?x=1", nil)
+	req.Header.Set("Authorization", "Bearer sk-test")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if got == nil {
+		t.Fatal("request not sent")
+	}
+	if v := got.Header.Get("OpenAI-Organization"); v != "org-1" {
+		t.Errorf("OpenAI-Organization = %q, want org-1", v)
+	}
+	if got.Header.Get("Authorization") != "" || got.Header.Get("api-key") != "sk-test" {
+		t.Errorf("auth headers = %v, want the key moved to api-key", got.Header)
+	}
+	if q := got.URL.Query(); q.Get("api-version") != "2024-10-21" || q.Get("x") != "1" {
+		t.Errorf("query = %q", got.URL.RawQuery)
+	}
+	if req.Header.Get("Authorization") == "" {
+		t.Error("caller's request was modified")
+	}
+
+	// Headers the client manages cannot be overridden
+	if _, err := ParseHeaders(map[string]string{"Host": "x"}); err == nil {
+		t.Error("ParseHeaders should reject Host")
+	}
+}
@@ -0,0 +1,127 @@
+// Package test contains integration tests for the /memory command
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kusa/magabot/internal/bot"
+	"github.com/kusa/magabot/internal/embedding"
+)
+
+// memoryTopics are the axes of the mock embedding space.
+var memoryTopics = [][]string{
+	{"coffee", "espresso", "latte", "caffeine"},
+	{"golang", "code", "compiler", "programming"},
+	{"cat", "kitten", "pet"},
+}
+
+// newMockEmbedServer returns a local-provider embedding server that embeds
+// each text as counts of topic words, so related words land close together.
+func newMockEmbedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Texts []string `json:"texts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var resp struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		for _, text := range req.Texts {
+			vec := make([]float32, len(memoryTopics)+1)
+			vec[len(memoryTopics)] = 0.01 // keep vectors non-zero
+			for _, word := range strings.Fields(strings.ToLower(text)) {
+				for i, topic := range memoryTopics {
+					for _, w := range topic {
+						if strings.Trim(word, ".,!?") == w {
+							vec[i]++
+						}
+					}
+				}
+			}
+			resp.Embeddings = append(resp.Embeddings, vec)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMemorySemanticSearch(t *testing.T) {
+	srv := newMockEmbedServer(t)
+	client := embedding.NewClient(embedding.Config{Provider: embedding.ProviderLocal, BaseURL: srv.URL})
+	h := bot.NewMemoryHandler(t.TempDir(), client)
+	if !h.Semantic() {
+		t.Fatal("Handler with an embedder should be semantic")
+	}
+
+	for _, text := range []string{"I drink an espresso every morning", "I write golang code at work", "My cat is called Miso"} {
+		if _, err := h.HandleCommand("user1", "telegram", append([]string{"add"}, strings.Fields(text)...)); err != nil {
+			t.Fatalf("add %q: %v", text, err)
+		}
+	}
+
+	// "latte" shares no keyword with the stored memory but the same topic
+	resp, err := h.HandleCommand("user1", "telegram", []string{"search", "latte"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	lines := strings.Split(resp, "\n")
+	if len(lines) < 3 || !strings.Contains(lines[2], "espresso") {
+		t.Errorf("Expected espresso memory first, got:\n%s", resp)
+	}
+	if strings.Contains(resp, "Keyword search") {
+		t.Error("Semantic search should not show the keyword note")
+	}
+
+	resp, err = h.HandleCommand("user1", "telegram", []string{"stats"})
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if !strings.Contains(resp, "Total: 3") {
+		t.Errorf("Expected 3 memories, got:\n%s", resp)
+	}
+
+	// Other users don't see these memories
+	resp, _ = h.HandleCommand("user2", "telegram", []string{"list"})
+	if !strings.Contains(resp, "No memories") {
+		t.Errorf("Expected empty list for user2, got:\n%s", resp)
+	}
+}
+
+func TestMemoryKeywordFallback(t *testing.T) {
+	dir := t.TempDir()
+	h := bot.NewMemoryHandler(dir, nil)
+	if h.Semantic() {
+		t.Fatal("Handler without an embedder should use keyword search")
+	}
+	if _, err := h.HandleCommand("user1", "telegram", []string{"add", "I", "drink", "espresso"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	resp, err := h.HandleCommand("user1", "telegram", []string{"search", "espresso"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if !strings.Contains(resp, "espresso") || !strings.Contains(resp, "Keyword search") {
+		t.Errorf("Expected keyword result with note, got:\n%s", resp)
+	}
+
+	// Enabling embeddings later imports the keyword memories
+	srv := newMockEmbedServer(t)
+	client := embedding.NewClient(embedding.Config{Provider: embedding.ProviderLocal, BaseURL: srv.URL})
+	semantic := bot.NewMemoryHandler(dir, client)
+	resp, err = semantic.HandleCommand("user1", "telegram", []string{"search", "coffee"})
+	if err != nil {
+		t.Fatalf("semantic search: %v", err)
+	}
+	if !strings.Contains(resp, "espresso") {
+		t.Errorf("Expected migrated memory, got:\n%s", resp)
+	}
+}
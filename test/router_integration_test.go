@@ -3,10 +3,14 @@ package test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,6 +18,7 @@ import (
 	"github.com/kusa/magabot/internal/router"
 	"github.com/kusa/magabot/internal/security"
 	"github.com/kusa/magabot/internal/storage"
+	"github.com/kusa/magabot/internal/util"
 )
 
 // MockPlatform implements router.Platform for testing
@@ -399,3 +404,759 @@ func TestRouterConcurrency(t *testing.T) {
 		t.Errorf("Expected at least 10 processed messages, got %d", finalCount)
 	}
 }
+
+func TestRouterDeduplication(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	store, err := storage.New(filepath.Join(tmpDir, "dedup.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	cfg, err := config.Load(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Platforms.Telegram = &config.TelegramConfig{Enabled: true, AllowedUsers: []string{"user1"}, AllowDMs: true}
+	window := util.NewDuration(200 * time.Millisecond)
+	cfg.Platforms.DedupWindow = &window
+
+	r := router.NewRouter(store, nil, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+	platform := NewMockPlatform("telegram")
+	r.Register(platform)
+
+	var handled int
+	var mu sync.Mutex
+	r.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+		mu.Lock()
+		handled++
+		mu.Unlock()
+		return "ok", nil
+	})
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled
+	}
+
+	ctx := context.Background()
+	send := func(id, text string, ts time.Time) {
+		t.Helper()
+		if _, err := platform.SimulateMessage(ctx, &router.Message{
+			ID: id, Platform: "telegram", ChatID: "user1", UserID: "user1", Text: text, Timestamp: ts,
+		}); err != nil {
+			t.Fatalf("SimulateMessage: %v", err)
+		}
+	}
+
+	// Redelivery with the same message ID inside the window is dropped
+	ts := time.Now()
+	send("msg-1", "hello", ts)
+	send("msg-1", "hello", ts)
+	if got := count(); got != 1 {
+		t.Fatalf("redelivered message handled %d times, want 1", got)
+	}
+
+	// Without an ID, identical content and timestamp is treated as a redelivery
+	send("", "no id", ts)
+	send("", "no id", ts)
+	if got := count(); got != 2 {
+		t.Fatalf("redelivered message without ID handled, count = %d, want 2", got)
+	}
+
+	// A genuine repeat after the window is processed
+	time.Sleep(250 * time.Millisecond)
+	send("msg-1", "hello", ts)
+	if got := count(); got != 3 {
+		t.Fatalf("repeat after window not handled, count = %d, want 3", got)
+	}
+}
+
+// MockReactor is a MockPlatform that supports reactions.
+type MockReactor struct {
+	*MockPlatform
+	onReaction router.ReactionHandler
+	reactions  []string
+}
+
+func (m *MockReactor) React(chatID, messageID, emoji string) error {
+	m.mu.Lock()
+	m.reactions = append(m.reactions, chatID+"/"+messageID+" "+emoji)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MockReactor) MaxReactions() int { return 0 }
+
+func (m *MockReactor) SetReactionHandler(h router.ReactionHandler) { m.onReaction = h }
+
+func TestRouterFeedback(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	store, err := storage.New(filepath.Join(tmpDir, "feedback.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	cfg, err := config.Load(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Platforms.Telegram = &config.TelegramConfig{Enabled: true, Admins: []string{"user1"}, AllowedUsers: []string{"user1"}, AllowDMs: true}
+	cfg.Platforms.Feedback = true
+
+	r := router.NewRouter(store, nil, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+	platform := &MockReactor{MockPlatform: NewMockPlatform("telegram")}
+	r.Register(platform)
+	if platform.onReaction == nil {
+		t.Fatal("router should register a reaction handler on reactor platforms")
+	}
+
+	r.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+		if msg.Text == "/status" {
+			return "all good", nil // command replies are not rated
+		}
+		msg.Provider, msg.Model = "anthropic", "claude"
+		return "answer", nil
+	})
+
+	ctx := context.Background()
+	cmd := &router.Message{ID: "1", Platform: "telegram", ChatID: "user1", UserID: "user1", Text: "/status", Timestamp: time.Now()}
+	if _, err := platform.SimulateMessage(ctx, cmd); err != nil {
+		t.Fatalf("SimulateMessage: %v", err)
+	}
+	if cmd.OnSent != nil {
+		t.Error("command replies should not request feedback")
+	}
+
+	msg := &router.Message{ID: "2", Platform: "telegram", ChatID: "user1", UserID: "user1", Text: "question", Timestamp: time.Now()}
+	if _, err := platform.SimulateMessage(ctx, msg); err != nil {
+		t.Fatalf("SimulateMessage: %v", err)
+	}
+	if msg.OnSent == nil {
+		t.Fatal("LLM answers should request feedback")
+	}
+	msg.OnSent("user1", "42")
+	if want := []string{"user1/42 👍", "user1/42 👎"}; strings.Join(platform.reactions, ",") != strings.Join(want, ",") {
+		t.Errorf("reactions = %v, want %v", platform.reactions, want)
+	}
+
+	react := func(user, emoji string, removed bool) {
+		platform.onReaction(ctx, &router.Reaction{
+			Platform: "telegram", ChatID: "user1", MessageID: "42", UserID: user, Emoji: emoji, Removed: removed,
+		})
+	}
+	react("user1", "👍🏽", false)
+	react("stranger", "👎", false) // not allowed to talk to the bot
+	react("user1", "🔥", false)    // not a feedback emoji
+
+	stats, err := store.FeedbackStats()
+	if err != nil {
+		t.Fatalf("FeedbackStats: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Up != 1 || stats[0].Down != 0 || stats[0].Model != "claude" {
+		t.Errorf("stats = %+v, want one 👍 for claude", stats)
+	}
+
+	react("user1", "👍", true)
+	if stats, _ = store.FeedbackStats(); len(stats) != 1 || stats[0].Up != 0 {
+		t.Errorf("removed reaction still counted: %+v", stats)
+	}
+}
+
+// connPlatform is a MockPlatform that reports its connection state.
+type connPlatform struct {
+	*MockPlatform
+	connected bool
+}
+
+func (c *connPlatform) IsConnected() bool { return c.connected }
+
+func TestRouterPlatformStatus(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	r := router.NewRouter(nil, nil, nil, nil, security.NewRateLimiter(1000, 100), logger)
+	wa := &connPlatform{MockPlatform: NewMockPlatform("whatsapp")}
+	r.Register(NewMockPlatform("telegram"))
+	r.Register(wa)
+
+	// Nothing is ready before Start
+	if down := r.DisconnectedPlatforms(); strings.Join(down, ",") != "telegram,whatsapp" {
+		t.Errorf("Expected both platforms down before start, got %v", down)
+	}
+
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if down := r.DisconnectedPlatforms(); strings.Join(down, ",") != "whatsapp" {
+		t.Errorf("Expected only disconnected whatsapp down, got %v", down)
+	}
+
+	wa.connected = true
+	if down := r.DisconnectedPlatforms(); len(down) != 0 {
+		t.Errorf("Expected all platforms ready, got %v", down)
+	}
+
+	r.Stop()
+	if status := r.PlatformStatus(); status["telegram"] || status["whatsapp"] {
+		t.Errorf("Expected platforms down after stop, got %v", status)
+	}
+}
+
+// statePlatform is a MockPlatform that describes its connection state.
+type statePlatform struct {
+	*MockPlatform
+	state string
+}
+
+func (s *statePlatform) ConnectionState() string { return s.state }
+
+func TestRouterPlatformStates(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	r := router.NewRouter(nil, nil, nil, nil, security.NewRateLimiter(1000, 100), logger)
+	r.Register(NewMockPlatform("telegram"))
+	r.Register(&statePlatform{MockPlatform: NewMockPlatform("whatsapp"), state: "reconnecting (attempt 2, down 5s)"})
+
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer r.Stop()
+
+	states := r.PlatformStates()
+	if states["telegram"] != "connected" {
+		t.Errorf("telegram state = %q, want connected", states["telegram"])
+	}
+	if states["whatsapp"] != "reconnecting (attempt 2, down 5s)" {
+		t.Errorf("whatsapp state = %q, want the platform's own state", states["whatsapp"])
+	}
+}
+
+// drainPlatform is a MockPlatform that records the drain notification.
+type drainPlatform struct {
+	*MockPlatform
+	drained atomic.Bool
+}
+
+func (d *drainPlatform) Drain() { d.drained.Store(true) }
+
+func TestRouterShutdownDrains(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	store, err := storage.New(filepath.Join(tmpDir, "drain.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	cfg, err := config.Load(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Platforms.Telegram = &config.TelegramConfig{Enabled: true, AllowedUsers: []string{"user1"}, AllowDMs: true}
+
+	r := router.NewRouter(store, nil, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+	platform := &drainPlatform{MockPlatform: NewMockPlatform("telegram")}
+	r.Register(platform)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+		if msg.Text == "slow" {
+			close(started)
+			<-release
+		}
+		return "done", nil
+	})
+
+	ctx := context.Background()
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	msg := func(text string) *router.Message {
+		return &router.Message{Platform: "telegram", ChatID: "user1", UserID: "user1", Text: text, Timestamp: time.Now()}
+	}
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, _ := platform.SimulateMessage(ctx, msg("slow"))
+		slow <- resp
+	}()
+	<-started
+
+	stopped := make(chan int, 1)
+	go func() { stopped <- r.Shutdown(ctx) }()
+
+	// Shutdown waits for the active message and rejects new ones
+	deadline := time.Now().Add(time.Second)
+	for !platform.drained.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !platform.drained.Load() {
+		t.Fatal("Platform was not told to drain")
+	}
+	if _, err := platform.SimulateMessage(ctx, msg("late")); !errors.Is(err, router.ErrDraining) {
+		t.Errorf("Expected ErrDraining for a new message, got %v", err)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("Shutdown returned before the in-flight message finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if resp := <-slow; resp != "done" {
+		t.Errorf("In-flight message got %q, want done", resp)
+	}
+	if active := <-stopped; active != 0 {
+		t.Errorf("Expected no messages left in flight, got %d", active)
+	}
+	platform.mu.Lock()
+	defer platform.mu.Unlock()
+	if !platform.stopped {
+		t.Error("Platform should be stopped after draining")
+	}
+}
+
+func TestRouterShutdownTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	store, err := storage.New(filepath.Join(tmpDir, "drain.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	cfg, err := config.Load(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Platforms.Telegram = &config.TelegramConfig{Enabled: true, AllowedUsers: []string{"user1"}, AllowDMs: true}
+
+	r := router.NewRouter(store, nil, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+	platform := NewMockPlatform("telegram")
+	r.Register(platform)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	r.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	})
+
+	ctx := context.Background()
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	go func() {
+		_, _ = platform.SimulateMessage(ctx, &router.Message{
+			Platform: "telegram", ChatID: "user1", UserID: "user1", Text: "stuck", Timestamp: time.Now(),
+		})
+	}()
+	<-started
+
+	drainCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if active := r.Shutdown(drainCtx); active != 1 {
+		t.Errorf("Expected 1 message still in flight at timeout, got %d", active)
+	}
+}
+
+// buttonPlatform is a MockPlatform that renders buttons natively.
+type buttonPlatform struct {
+	*MockPlatform
+	replies []router.Reply
+}
+
+func (b *buttonPlatform) SendButtons(_ string, reply router.Reply) error {
+	b.replies = append(b.replies, reply)
+	return nil
+}
+
+func TestRouterButtonReplies(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	store, err := storage.New(filepath.Join(tmpDir, "buttons.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	cfg, err := config.Load(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Platforms.Telegram = &config.TelegramConfig{Enabled: true, AllowedUsers: []string{"user1"}, AllowDMs: true}
+	cfg.Platforms.Slack = &config.SlackConfig{Enabled: true, AllowedUsers: []string{"user1"}, AllowDMs: true}
+
+	r := router.NewRouter(store, nil, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+	native := &buttonPlatform{MockPlatform: NewMockPlatform("telegram")}
+	plain := NewMockPlatform("slack")
+	r.Register(native)
+	r.Register(plain)
+
+	var pressed []string
+	r.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+		if data, ok := router.ButtonData(msg.Text); ok {
+			pressed = append(pressed, data)
+			return "picked " + data, nil
+		}
+		return msg.Respond(router.Reply{
+			Text:    "Pick one",
+			Buttons: [][]router.Button{{{Text: "Red", Data: "red"}, {Text: "Blue", Data: "blue"}}},
+		}), nil
+	})
+
+	msg := func(platform, text string) *router.Message {
+		return &router.Message{Platform: platform, ChatID: "user1", UserID: "user1", Text: text, Timestamp: time.Now()}
+	}
+	ctx := context.Background()
+
+	t.Run("Native", func(t *testing.T) {
+		m := msg("telegram", "menu")
+		resp, err := native.SimulateMessage(ctx, m)
+		if err != nil {
+			t.Fatalf("SimulateMessage failed: %v", err)
+		}
+		if resp != "Pick one" {
+			t.Errorf("Expected plain text for native platform, got %q", resp)
+		}
+		if len(m.Buttons) != 1 || len(m.Buttons[0]) != 2 {
+			t.Errorf("Expected buttons left for the platform, got %v", m.Buttons)
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		m := msg("slack", "menu")
+		resp, err := plain.SimulateMessage(ctx, m)
+		if err != nil {
+			t.Fatalf("SimulateMessage failed: %v", err)
+		}
+		if !strings.Contains(resp, "1. Red") || !strings.Contains(resp, "2. Blue") {
+			t.Errorf("Expected numbered fallback, got %q", resp)
+		}
+		if m.Buttons != nil {
+			t.Errorf("Fallback should consume buttons, got %v", m.Buttons)
+		}
+
+		// Answering by number is dispatched as a button press
+		resp, _ = plain.SimulateMessage(ctx, msg("slack", "2"))
+		if resp != "picked blue" {
+			t.Errorf("Expected numeric answer to press Blue, got %q", resp)
+		}
+
+		// Choices are read once: a later number is plain text again
+		if resp, _ = plain.SimulateMessage(ctx, msg("slack", "1")); !strings.Contains(resp, "1. Red") {
+			t.Errorf("Expected a fresh menu for a stale number, got %q", resp)
+		}
+		if len(pressed) != 1 {
+			t.Errorf("Expected one press, got %v", pressed)
+		}
+	})
+
+	t.Run("SendReply", func(t *testing.T) {
+		reply := router.Reply{Text: "Choose", Buttons: [][]router.Button{{{Text: "OK", Data: "ok"}}}}
+		if err := r.SendReply("telegram", "user1", reply); err != nil {
+			t.Fatalf("SendReply telegram failed: %v", err)
+		}
+		if len(native.replies) != 1 {
+			t.Errorf("Expected native SendButtons, got %d calls", len(native.replies))
+		}
+		if err := r.SendReply("slack", "user1", reply); err != nil {
+			t.Fatalf("SendReply slack failed: %v", err)
+		}
+		plain.mu.Lock()
+		defer plain.mu.Unlock()
+		if n := len(plain.messages); n != 1 || !strings.Contains(plain.messages[0], "1. OK") {
+			t.Errorf("Expected numbered fallback sent, got %v", plain.messages)
+		}
+	})
+}
+
+// flakyPlatform is a MockPlatform whose sends fail while failing is set.
+type flakyPlatform struct {
+	*MockPlatform
+	failing  atomic.Bool
+	attempts atomic.Int32
+}
+
+func (f *flakyPlatform) Send(chatID, message string) error {
+	f.attempts.Add(1)
+	if f.failing.Load() {
+		return errors.New("network unreachable")
+	}
+	return f.MockPlatform.Send(chatID, message)
+}
+
+// chunkedPlatform is a MockPlatform that sends "a\n\nb" as two chunks,
+// failing on the second one once.
+type chunkedPlatform struct {
+	*MockPlatform
+	failed atomic.Bool
+}
+
+func (c *chunkedPlatform) Send(chatID, message string) error {
+	first, second, ok := strings.Cut(message, "\n\n")
+	if !ok {
+		return c.MockPlatform.Send(chatID, message)
+	}
+	_ = c.MockPlatform.Send(chatID, first)
+	if !c.failed.Swap(true) {
+		return &router.PartialSendError{Unsent: []string{second}, Err: errors.New("network unreachable")}
+	}
+	return c.MockPlatform.Send(chatID, second)
+}
+
+func TestRouterSendRetries(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	store, err := storage.New(filepath.Join(tmpDir, "outbox.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	vault, err := security.NewVault(security.GenerateKey())
+	if err != nil {
+		t.Fatalf("Failed to create vault: %v", err)
+	}
+
+	cfg, err := config.Load(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	retries := 2
+	backoff := util.NewDuration(10 * time.Millisecond)
+	cfg.Platforms.SendRetries = &retries
+	cfg.Platforms.SendBackoff = &backoff
+	cfg.Platforms.SendLimits = map[string]config.SendLimitConfig{"telegram": {}} // paced by the backoff alone
+
+	newRouter := func() (*router.Router, *flakyPlatform) {
+		r := router.NewRouter(store, vault, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+		p := &flakyPlatform{MockPlatform: NewMockPlatform("telegram")}
+		r.Register(p)
+		return r, p
+	}
+	waitFor := func(cond func() bool) bool {
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if cond() {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("RecoversOnRetry", func(t *testing.T) {
+		r, p := newRouter()
+		p.failing.Store(true)
+		if err := r.Send("telegram", "chat1", "reminder"); !errors.Is(err, router.ErrSendQueued) {
+			t.Fatalf("Send should report a queued retry, got %v", err)
+		}
+		p.failing.Store(false)
+		if !waitFor(func() bool { p.mu.Lock(); defer p.mu.Unlock(); return len(p.messages) == 1 }) {
+			t.Fatal("message not delivered by retry")
+		}
+		r.Stop()
+		if dls, _ := store.ListDeadLetters(10); len(dls) != 0 {
+			t.Errorf("Expected no dead letters, got %+v", dls)
+		}
+	})
+
+	t.Run("RetriesUnsentChunks", func(t *testing.T) {
+		r := router.NewRouter(store, vault, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+		p := &chunkedPlatform{MockPlatform: NewMockPlatform("telegram")}
+		r.Register(p)
+		if err := r.Send("telegram", "chat1", "part one\n\npart two"); !errors.Is(err, router.ErrSendQueued) {
+			t.Fatalf("Send should report a queued retry, got %v", err)
+		}
+		if !waitFor(func() bool { p.mu.Lock(); defer p.mu.Unlock(); return len(p.messages) == 2 }) {
+			t.Fatal("unsent chunk not delivered by retry")
+		}
+		r.Stop()
+		p.mu.Lock()
+		got := p.messages
+		p.mu.Unlock()
+		if len(got) != 2 || got[0] != "part one" || got[1] != "part two" {
+			t.Errorf("Expected each chunk once, got %q", got)
+		}
+	})
+
+	t.Run("DeadLetterAndReplay", func(t *testing.T) {
+		r, p := newRouter()
+		p.failing.Store(true)
+		_ = r.Send("telegram", "chat1", "lost update")
+		if !waitFor(func() bool { dls, _ := store.ListDeadLetters(10); return len(dls) == 1 }) {
+			t.Fatal("message not dead-lettered after retries")
+		}
+		if got := p.attempts.Load(); got != 3 {
+			t.Errorf("Expected 1 send + 2 retries, got %d attempts", got)
+		}
+
+		dls, _ := store.ListDeadLetters(10)
+		if dls[0].Attempts != 3 || dls[0].Content == "lost update" {
+			t.Errorf("Expected encrypted dead letter after 3 attempts, got %+v", dls[0])
+		}
+
+		// Nothing is resent until the dead letter is queued for replay
+		p.failing.Store(false)
+		if n := r.ReplayDeadLetters(); n != 0 {
+			t.Fatalf("Replayed %d unqueued dead letters", n)
+		}
+		if _, err := store.QueueDeadLetterReplay(); err != nil {
+			t.Fatalf("QueueDeadLetterReplay: %v", err)
+		}
+		if n := r.ReplayDeadLetters(); n != 1 {
+			t.Fatalf("Expected 1 replayed dead letter, got %d", n)
+		}
+		p.mu.Lock()
+		got := p.messages
+		p.mu.Unlock()
+		if len(got) != 1 || got[0] != "lost update" {
+			t.Errorf("Expected replayed message, got %v", got)
+		}
+		if dls, _ := store.ListDeadLetters(10); len(dls) != 0 {
+			t.Errorf("Expected replayed dead letter removed, got %+v", dls)
+		}
+		r.Stop()
+	})
+
+	t.Run("ShutdownDeadLettersPending", func(t *testing.T) {
+		slow := util.NewDuration(time.Hour)
+		cfg.Platforms.SendBackoff = &slow
+		defer func() { cfg.Platforms.SendBackoff = &backoff }()
+
+		r, p := newRouter()
+		p.failing.Store(true)
+		_ = r.Send("telegram", "chat1", "pending")
+		r.Stop()
+		dls, _ := store.ListDeadLetters(10)
+		if len(dls) != 1 || dls[0].Attempts != 1 {
+			t.Errorf("Expected pending retry dead-lettered on shutdown, got %+v", dls)
+		}
+	})
+}
+
+func TestRouterNewUser(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	store, err := storage.New(filepath.Join(tmpDir, "newuser.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	cfg, err := config.Load(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Platforms.Telegram = &config.TelegramConfig{Enabled: true, Admins: []string{"admin1"}, AllowDMs: true}
+
+	r := router.NewRouter(store, nil, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+	platform := NewMockPlatform("telegram")
+	r.Register(platform)
+	r.SetHandler(func(ctx context.Context, msg *router.Message) (string, error) {
+		return "ok", nil
+	})
+
+	ctx := context.Background()
+	send := func(userID string) (string, error) {
+		return platform.SimulateMessage(ctx, &router.Message{
+			Platform: "telegram", ChatID: userID, UserID: userID, Text: "hi", Timestamp: time.Now(),
+		})
+	}
+
+	// Without a handler, strangers are denied silently
+	if _, err := send("stranger1"); !errors.Is(err, security.ErrNotAuthorized) {
+		t.Fatalf("err = %v, want ErrNotAuthorized", err)
+	}
+
+	var notified []string
+	r.SetNewUserHandler(func(msg *router.Message) string {
+		notified = append(notified, msg.UserID)
+		return "Ask an admin for access."
+	})
+
+	// A stranger's first message gets the onboarding reply, later ones nothing
+	reply, err := send("stranger2")
+	if err != nil || reply != "Ask an admin for access." {
+		t.Fatalf("first message = %q, %v", reply, err)
+	}
+	if _, err := send("stranger2"); !errors.Is(err, security.ErrNotAuthorized) {
+		t.Fatalf("second message err = %v, want ErrNotAuthorized", err)
+	}
+	if len(notified) != 1 || notified[0] != "stranger2" {
+		t.Errorf("notified = %v, want [stranger2]", notified)
+	}
+
+	// Allowed users never reach the handler
+	if reply, err := send("admin1"); err != nil || reply != "ok" {
+		t.Errorf("admin message = %q, %v", reply, err)
+	}
+	if len(notified) != 1 {
+		t.Errorf("notified = %v after an allowed user's message", notified)
+	}
+}
+
+func TestRouterSendLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	cfg, err := config.Load(filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.Platforms.SendLimits = map[string]config.SendLimitConfig{
+		"telegram": {PerChat: 5},
+		"whatsapp": {PerSecond: 5},
+	}
+	r := router.NewRouter(nil, nil, cfg, nil, security.NewRateLimiter(1000, 100), logger)
+	telegram := NewMockPlatform("telegram")
+	whatsapp := NewMockPlatform("whatsapp")
+	webhook := NewMockPlatform("webhook")
+	r.Register(telegram)
+	r.Register(whatsapp)
+	r.Register(webhook)
+	defer r.Stop()
+
+	// sendAll sends n messages, to one chat or each to its own, and returns
+	// how long that took.
+	sendAll := func(platform string, n int, sameChat bool) time.Duration {
+		start := time.Now()
+		for i := range n {
+			chatID := "chat1"
+			if !sameChat {
+				chatID = fmt.Sprintf("group%d", i)
+			}
+			if err := r.Send(platform, chatID, "broadcast"); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+		}
+		return time.Since(start)
+	}
+
+	// A burst of 5, then one every 200ms
+	if d := sendAll("telegram", 7, true); d < 350*time.Millisecond {
+		t.Errorf("7 sends to one chat at 5/s took %v, want at least 350ms", d)
+	}
+	if d := sendAll("telegram", 5, false); d > 100*time.Millisecond {
+		t.Errorf("sends to other chats waited %v, want no wait", d)
+	}
+	if d := sendAll("whatsapp", 7, false); d < 350*time.Millisecond {
+		t.Errorf("7 sends across chats at 5/s took %v, want at least 350ms", d)
+	}
+	if d := sendAll("webhook", 50, true); d > 100*time.Millisecond {
+		t.Errorf("unlimited platform took %v", d)
+	}
+	if got := len(telegram.messages) + len(whatsapp.messages) + len(webhook.messages); got != 69 {
+		t.Errorf("delivered %d messages, want 69", got)
+	}
+}